@@ -0,0 +1,123 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/check"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/resync"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+	v "github.com/vulcanize/ipfs-blockchain-watcher/version"
+)
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the IPLD blocks of indexed data against the index",
+	Long: `Use this command to find the blocks referenced by the index that are missing from the store of the configured ipfs
+mode, or whose data does not hash to their CID, along with index rows whose mh_key does not match their CID. Besides
+the blocks of every type of indexed data, the trie and merkle nodes that hang off of the headers are checked.
+
+The discrepancies are logged, and written to a CSV file if check.report is set. With check.resync the heights with
+discrepancies are resynced afterwards with the [resync] settings, which default to a full resync; the old data is
+cleared before it is resynced if any block is corrupt, since publishing never overwrites a block.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *log.WithField("SubCommand", subCommand)
+		checkBlocks()
+	},
+}
+
+func checkBlocks() {
+	logWithCommand.Infof("running ipfs-blockchain-watcher version: %s", v.VersionWithMeta)
+	logWithCommand.Debug("loading check configuration variables")
+	cConfig, err := check.NewConfig()
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Infof("check config: %+v", cConfig)
+	if cConfig.IPFSMode == shared.LocalInterface {
+		if err := ipfs.InitIPFSPlugins(); err != nil {
+			logWithCommand.Fatal(err)
+		}
+	}
+	logWithCommand.Debug("initializing new check service")
+	cService, err := check.NewService(cConfig)
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Info("checking blocks")
+	report, err := cService.Check()
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	if len(report.Discrepancies) == 0 {
+		logWithCommand.Infof("all %d %s blocks checked are intact", report.Checked, cConfig.Chain.String())
+		return
+	}
+	if !cConfig.Resync {
+		logWithCommand.Fatalf("found %d discrepancies in %d %s blocks checked", len(report.Discrepancies), report.Checked, cConfig.Chain.String())
+	}
+
+	logWithCommand.Debug("loading resync configuration variables")
+	viper.Set("resync.chain", cConfig.Chain.String())
+	if viper.GetString("resync.type") == "" {
+		viper.Set("resync.type", shared.Full.String())
+	}
+	rConfig, err := resync.NewConfig()
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	rConfig.Ranges = report.Ranges()
+	if report.Has(check.Corrupt) {
+		rConfig.ClearOldCache = true
+	}
+	logWithCommand.Infof("resync config: %+v", rConfig)
+	rService, err := resync.NewResyncService(rConfig)
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Infof("resyncing the %d %s ranges with discrepancies", len(rConfig.Ranges), cConfig.Chain.String())
+	if err := rService.Resync(); err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Infof("%s resync of the ranges with discrepancies finished", cConfig.Chain.String())
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+
+	// flags
+	checkCmd.PersistentFlags().String("check-chain", "", "which chain to check the blocks of, options are currently Ethereum or Bitcoin.")
+	checkCmd.PersistentFlags().Int("check-start", 0, "block height to start checking at")
+	checkCmd.PersistentFlags().Int("check-stop", 0, "block height to stop checking at; 0 checks up to the highest indexed header")
+	checkCmd.PersistentFlags().Int("check-range-size", check.DefaultRangeSize, "number of block heights to check the blocks of in a single db transaction")
+	checkCmd.PersistentFlags().String("check-report", "", "path of a CSV file to write the discrepancies to")
+	checkCmd.PersistentFlags().Bool("check-resync", false, "if true, resync the heights with discrepancies using the resync settings")
+
+	// and their bindings
+	viper.BindPFlag("check.chain", checkCmd.PersistentFlags().Lookup("check-chain"))
+	viper.BindPFlag("check.start", checkCmd.PersistentFlags().Lookup("check-start"))
+	viper.BindPFlag("check.stop", checkCmd.PersistentFlags().Lookup("check-stop"))
+	viper.BindPFlag("check.rangeSize", checkCmd.PersistentFlags().Lookup("check-range-size"))
+	viper.BindPFlag("check.report", checkCmd.PersistentFlags().Lookup("check-report"))
+	viper.BindPFlag("check.resync", checkCmd.PersistentFlags().Lookup("check-resync"))
+}
@@ -0,0 +1,90 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/export"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+	v "github.com/vulcanize/ipfs-blockchain-watcher/version"
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export a range of IPLD blocks to a CAR file",
+	Long: `Use this command to write the IPLD blocks of a range of eth or btc data to a CARv1 file, with the headers of the
+range as its roots, for archival or for loading into another IPFS node.
+
+A manifest of the block number, type of data and CID of every block in the file is written next to it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *log.WithField("SubCommand", subCommand)
+		exportBlocks()
+	},
+}
+
+func exportBlocks() {
+	logWithCommand.Infof("running ipfs-blockchain-watcher version: %s", v.VersionWithMeta)
+	logWithCommand.Debug("loading export configuration variables")
+	eConfig, err := export.NewConfig()
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Infof("export config: %+v", eConfig)
+	if eConfig.IPFSMode == shared.LocalInterface {
+		if err := ipfs.InitIPFSPlugins(); err != nil {
+			logWithCommand.Fatal(err)
+		}
+	}
+	logWithCommand.Debug("initializing new export service")
+	eService, err := export.NewService(eConfig)
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Info("exporting blocks")
+	if err := eService.Export(); err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Infof("%s blocks at heights %d to %d exported to %s", eConfig.Chain.String(), eConfig.Start, eConfig.Stop, eConfig.Output)
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	// flags
+	exportCmd.PersistentFlags().String("export-chain", "", "which chain to export, options are currently Ethereum or Bitcoin.")
+	exportCmd.PersistentFlags().Int("export-start", 0, "first block height to export")
+	exportCmd.PersistentFlags().Int("export-stop", 0, "last block height to export")
+	exportCmd.PersistentFlags().StringSlice("export-data-types", nil, "which types of data to export, the headers are always exported")
+	exportCmd.PersistentFlags().String("export-output", "", "path of the CAR file to write")
+	exportCmd.PersistentFlags().String("export-manifest", "", "path of the manifest to write, defaults to <output>.manifest.csv")
+	exportCmd.PersistentFlags().Int("export-batch-size", export.DefaultBatchSize, "number of block heights to fetch the blocks of at a time")
+
+	// and their bindings
+	viper.BindPFlag("export.chain", exportCmd.PersistentFlags().Lookup("export-chain"))
+	viper.BindPFlag("export.start", exportCmd.PersistentFlags().Lookup("export-start"))
+	viper.BindPFlag("export.stop", exportCmd.PersistentFlags().Lookup("export-stop"))
+	viper.BindPFlag("export.dataTypes", exportCmd.PersistentFlags().Lookup("export-data-types"))
+	viper.BindPFlag("export.output", exportCmd.PersistentFlags().Lookup("export-output"))
+	viper.BindPFlag("export.manifest", exportCmd.PersistentFlags().Lookup("export-manifest"))
+	viper.BindPFlag("export.batchSize", exportCmd.PersistentFlags().Lookup("export-batch-size"))
+}
@@ -0,0 +1,75 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/blockstore"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/config"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/node"
+	"github.com/vulcanize/ipfs-blockchain-watcher/utils"
+	v "github.com/vulcanize/ipfs-blockchain-watcher/version"
+)
+
+// foreignKeysCmd represents the foreignKeys command
+var foreignKeysCmd = &cobra.Command{
+	Use:   "foreignKeys [add|drop]",
+	Short: "Add or drop the foreign keys from the index to public.blocks",
+	Long: `Use this command to add back, or drop again, the foreign keys from the mh_key columns of the index to the
+public.blocks table, which are dropped by the migrations since blocks can be kept in other blockstores.
+
+The keys can only be added while every indexed block is kept in public.blocks: the command refuses to add them while
+public.block_locations records blocks moved to a cold tier, and drops them again if any indexed row references a block
+that is missing from public.blocks. While they exist, deleting a referenced block fails rather than deleting the index
+rows that reference it, and the watchers can only be run with the postgres blockstore in postgres ipfs mode; drop them
+before configuring other blockstores or moving blocks to a cold tier.
+
+Adding the keys briefly locks the index tables against writes, so it is best run while no watcher is running.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *log.WithField("SubCommand", subCommand)
+		foreignKeys(args[0])
+	},
+}
+
+func foreignKeys(action string) {
+	logWithCommand.Infof("running ipfs-blockchain-watcher version: %s", v.VersionWithMeta)
+	var dbConfig config.Database
+	dbConfig.Init()
+	db := utils.LoadPostgres(dbConfig, node.Node{})
+	switch action {
+	case "add":
+		if err := blockstore.AddForeignKeys(&db); err != nil {
+			logWithCommand.Fatal(err)
+		}
+		logWithCommand.Info("foreign keys to public.blocks added")
+	case "drop":
+		if err := blockstore.DropForeignKeys(&db); err != nil {
+			logWithCommand.Fatal(err)
+		}
+		logWithCommand.Info("foreign keys to public.blocks dropped")
+	default:
+		logWithCommand.Fatalf("unrecognized foreignKeys action %s, options are add or drop", action)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(foreignKeysCmd)
+}
@@ -0,0 +1,82 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/gc"
+	v "github.com/vulcanize/ipfs-blockchain-watcher/version"
+)
+
+// gcCmd represents the gc command
+var gcCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Garbage collect the IPLD blocks no index row references",
+	Long: `Use this command to delete the blocks in public.blocks that are not referenced by any index row, such as the blocks
+of data removed by a reorg or by a resync clearing out old data, or left behind by failed publishing.
+
+The blocks that hang off of the indexed headers without being indexed themselves, the nodes of the transaction and
+receipt tries and the Bitcoin merkle trees, are marked first, for both chains. The unmarked blocks are then swept in
+batches; every batch locks public.blocks against publishing while the headers indexed in the meantime are marked and the
+references of the index tables are checked again, so the watcher can keep running. Only the postgres ipfs mode is
+supported, and blocks moved to other blockstores are left alone.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *log.WithField("SubCommand", subCommand)
+		collectGarbage()
+	},
+}
+
+func collectGarbage() {
+	logWithCommand.Infof("running ipfs-blockchain-watcher version: %s", v.VersionWithMeta)
+	logWithCommand.Debug("loading gc configuration variables")
+	gcConfig, err := gc.NewConfig()
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Infof("gc config: %+v", gcConfig)
+	logWithCommand.Debug("initializing new gc service")
+	gcService, err := gc.NewService(gcConfig)
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Info("collecting unreferenced blocks")
+	collected, err := gcService.Collect()
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	if gcConfig.DryRun {
+		logWithCommand.Infof("dry run, %d unreferenced blocks would have been deleted", collected)
+		return
+	}
+	logWithCommand.Infof("%d unreferenced blocks deleted", collected)
+}
+
+func init() {
+	rootCmd.AddCommand(gcCmd)
+
+	// flags
+	gcCmd.PersistentFlags().Int("gc-batch-size", gc.DefaultBatchSize, "number of headers to mark, and of blocks to sweep, in a single db transaction")
+	gcCmd.PersistentFlags().Bool("gc-dry-run", false, "only count the unreferenced blocks, without deleting them")
+
+	// and their bindings
+	viper.BindPFlag("gc.batchSize", gcCmd.PersistentFlags().Lookup("gc-batch-size"))
+	viper.BindPFlag("gc.dryRun", gcCmd.PersistentFlags().Lookup("gc-dry-run"))
+}
@@ -0,0 +1,91 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"os"
+	"os/signal"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/carimport"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+	v "github.com/vulcanize/ipfs-blockchain-watcher/version"
+)
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import [car files...]",
+	Short: "Import the IPLD blocks in CAR files and rebuild their index",
+	Long: `Use this command to load the CAR files written by the export command of another watcher into the configured
+blockstore, and to rebuild the eth or btc CID index of the headers at their roots from the IPLDs in the files, to seed a
+new deployment without an archive node.
+
+The CAR files have to be exported with all of their types of data, and imported in order. The total difficulty of eth
+blocks is summed up from the parent of the first block imported, which has to be indexed already unless its total
+difficulty is given with --import-parent-td.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *log.WithField("SubCommand", subCommand)
+		importCAR(args)
+	},
+}
+
+func importCAR(files []string) {
+	logWithCommand.Infof("running ipfs-blockchain-watcher version: %s", v.VersionWithMeta)
+	logWithCommand.Debug("loading car import configuration variables")
+	iConfig, err := carimport.NewConfig(files)
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Infof("car import config: %+v", iConfig)
+	if iConfig.IPFSMode == shared.LocalInterface {
+		if err := ipfs.InitIPFSPlugins(); err != nil {
+			logWithCommand.Fatal(err)
+		}
+	}
+	logWithCommand.Debug("initializing new car import service")
+	iService, err := carimport.NewImportService(iConfig)
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	// stop between headers on an interrupt, so that no header is left half indexed in the IPFS modes
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, os.Interrupt)
+	go func() {
+		<-shutdown
+		iService.Stop()
+	}()
+	logWithCommand.Info("starting up car import process")
+	if err := iService.Import(); err != nil {
+		logWithCommand.Fatal(err)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	// flags
+	importCmd.PersistentFlags().String("import-parent-td", "", "total difficulty of the parent of the first eth block imported, if it is not indexed")
+
+	// and their bindings
+	viper.BindPFlag("carImport.parentTD", importCmd.PersistentFlags().Lookup("import-parent-td"))
+}
@@ -0,0 +1,66 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// importBtcCmd represents the importBtc command
+var importBtcCmd = &cobra.Command{
+	Use:   "importBtc [bitcoind data dir]",
+	Short: "Import btc blocks from the blk*.dat files of a bitcoind data directory",
+	Long: `Use this command to bootstrap the ipfs-blockchain-watcher database with the Bitcoin blocks in a copy of a bitcoind
+data directory, without a node running: the blocks are read straight out of its blk*.dat files and published and indexed
+like they are by a resync.
+
+The height of every block is resolved by following the previous block hashes out from the genesis block of the network
+of the configured bitcoin.networkID (mainnet if it is not set), and only the chain with the most work is imported, from
+--import-start up to --import-stop or the tip of the files.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *log.WithField("SubCommand", subCommand)
+		importBtc(args[0])
+	},
+}
+
+func importBtc(dataDir string) {
+	// the import is a full resync of the blocks in the data directory
+	viper.Set("resync.chain", shared.Bitcoin.String())
+	viper.Set("resync.type", shared.Full.String())
+	viper.Set("resync.start", viper.GetInt64("btcImport.start"))
+	viper.Set("resync.stop", viper.GetInt64("btcImport.stop"))
+	viper.Set("bitcoin.dataDir", dataDir)
+	rsyncCmdCommand()
+}
+
+func init() {
+	rootCmd.AddCommand(importBtcCmd)
+
+	// flags
+	importBtcCmd.PersistentFlags().Int64("import-start", 0, "block height to start the import at")
+	importBtcCmd.PersistentFlags().Int64("import-stop", 0, "block height to stop the import at; the tip of the files if not given")
+
+	// and their bindings
+	viper.BindPFlag("btcImport.start", importBtcCmd.PersistentFlags().Lookup("import-start"))
+	viper.BindPFlag("btcImport.stop", importBtcCmd.PersistentFlags().Lookup("import-stop"))
+}
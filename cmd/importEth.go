@@ -0,0 +1,93 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	"os"
+	"os/signal"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/importer"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+	v "github.com/vulcanize/ipfs-blockchain-watcher/version"
+)
+
+// importEthCmd represents the importEth command
+var importEthCmd = &cobra.Command{
+	Use:   "importEth [export files...]",
+	Short: "Import eth blocks from geth export files",
+	Long: `Use this command to import the headers, uncles, transactions and receipts of the blocks in the files written by
+'geth export' into the ipfs-blockchain-watcher database, without a statediffing node.
+
+The export files carry no receipts, so the blocks are either re-executed or their receipts are read from the files given
+with --import-receipts. The blocks have no state diffs, so they are indexed with their state marked as absent.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *log.WithField("SubCommand", subCommand)
+		importEth(args)
+	},
+}
+
+func importEth(files []string) {
+	logWithCommand.Infof("running ipfs-blockchain-watcher version: %s", v.VersionWithMeta)
+	logWithCommand.Debug("loading import configuration variables")
+	iConfig, err := importer.NewConfig(files)
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Infof("import config: %+v", iConfig)
+	if iConfig.IPFSMode == shared.LocalInterface {
+		if err := ipfs.InitIPFSPlugins(); err != nil {
+			logWithCommand.Fatal(err)
+		}
+	}
+	logWithCommand.Debug("initializing new import service")
+	iService, err := importer.NewImportService(iConfig)
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	// stop between blocks on an interrupt, so that the chain the blocks are re-executed on is closed cleanly
+	shutdown := make(chan os.Signal, 1)
+	signal.Notify(shutdown, os.Interrupt)
+	go func() {
+		<-shutdown
+		iService.Stop()
+	}()
+	logWithCommand.Info("starting up import process")
+	if err := iService.Import(); err != nil {
+		logWithCommand.Fatal(err)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(importEthCmd)
+
+	// flags
+	importEthCmd.PersistentFlags().StringSlice("import-receipts", nil, "files holding the receipts of the exported blocks; if not given the blocks are re-executed")
+	importEthCmd.PersistentFlags().String("import-chain-data-dir", "", "directory to keep the chain the blocks are re-executed on; kept in memory if not given")
+	importEthCmd.PersistentFlags().String("import-genesis-file", "", "genesis json file of the chain; mainnet if not given")
+
+	// and their bindings
+	viper.BindPFlag("import.receipts", importEthCmd.PersistentFlags().Lookup("import-receipts"))
+	viper.BindPFlag("import.chainDataDir", importEthCmd.PersistentFlags().Lookup("import-chain-data-dir"))
+	viper.BindPFlag("import.genesisFile", importEthCmd.PersistentFlags().Lookup("import-genesis-file"))
+}
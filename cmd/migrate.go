@@ -0,0 +1,82 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/migrate"
+	v "github.com/vulcanize/ipfs-blockchain-watcher/version"
+)
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Copy IPLD blocks between the local IPFS repo and Postgres",
+	Long: `Use this command to switch a deployment between the interface and postgres ipfs modes, by copying the IPLD blocks
+of all of the indexed data, and the trie and merkle nodes that hang off of the headers, from the local IPFS repo at
+ipfs.path to the configured blockstores (by default public.blocks) or the other way around.
+
+Blocks the destination already holds are skipped, so the command can be stopped and rerun at any point. Every block is
+checked against its CID before it is copied; blocks that are missing from the source or don't match fail the migration
+once the other blocks have been copied. The watcher has to be stopped while the IPFS repo is migrated.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *log.WithField("SubCommand", subCommand)
+		migrateBlocks()
+	},
+}
+
+func migrateBlocks() {
+	logWithCommand.Infof("running ipfs-blockchain-watcher version: %s", v.VersionWithMeta)
+	logWithCommand.Debug("loading migrate configuration variables")
+	mConfig, err := migrate.NewConfig()
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Infof("migrate config: %+v", mConfig)
+	if err := ipfs.InitIPFSPlugins(); err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Debug("initializing new migrate service")
+	mService, err := migrate.NewService(mConfig)
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Info("copying blocks")
+	if err := mService.Migrate(); err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Infof("%s blocks copied to the %s store", mConfig.Chain.String(), mConfig.To.String())
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+
+	// flags
+	migrateCmd.PersistentFlags().String("migrate-chain", "", "which chain to copy the blocks of, options are currently Ethereum or Bitcoin.")
+	migrateCmd.PersistentFlags().String("migrate-to", "", "ipfs mode to copy the blocks to, postgres or interface; they are copied from the other")
+	migrateCmd.PersistentFlags().Int("migrate-range-size", migrate.DefaultRangeSize, "number of block heights to copy the blocks of in a single db transaction")
+
+	// and their bindings
+	viper.BindPFlag("migrate.chain", migrateCmd.PersistentFlags().Lookup("migrate-chain"))
+	viper.BindPFlag("migrate.to", migrateCmd.PersistentFlags().Lookup("migrate-to"))
+	viper.BindPFlag("migrate.rangeSize", migrateCmd.PersistentFlags().Lookup("migrate-range-size"))
+}
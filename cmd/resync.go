@@ -86,6 +86,7 @@ func init() {
 	resyncCmd.PersistentFlags().String("btc-client-name", "", "btc client name")
 	resyncCmd.PersistentFlags().String("btc-genesis-block", "", "btc genesis block hash")
 	resyncCmd.PersistentFlags().String("btc-network-id", "", "btc network id")
+	resyncCmd.PersistentFlags().String("btc-data-dir", "", "bitcoind data directory to read blocks from instead of the btc node")
 
 	resyncCmd.PersistentFlags().String("eth-http-path", "", "http url for ethereum node")
 	resyncCmd.PersistentFlags().String("eth-node-id", "", "eth node id")
@@ -113,6 +114,7 @@ func init() {
 	viper.BindPFlag("bitcoin.clientName", resyncCmd.PersistentFlags().Lookup("btc-client-name"))
 	viper.BindPFlag("bitcoin.genesisBlock", resyncCmd.PersistentFlags().Lookup("btc-genesis-block"))
 	viper.BindPFlag("bitcoin.networkID", resyncCmd.PersistentFlags().Lookup("btc-network-id"))
+	viper.BindPFlag("bitcoin.dataDir", resyncCmd.PersistentFlags().Lookup("btc-data-dir"))
 
 	viper.BindPFlag("ethereum.httpPath", resyncCmd.PersistentFlags().Lookup("eth-http-path"))
 	viper.BindPFlag("ethereum.nodeID", resyncCmd.PersistentFlags().Lookup("eth-node-id"))
@@ -0,0 +1,82 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/tier"
+	v "github.com/vulcanize/ipfs-blockchain-watcher/version"
+)
+
+// tierCmd represents the tier command
+var tierCmd = &cobra.Command{
+	Use:   "tier",
+	Short: "Move old IPLD blocks out of Postgres",
+	Long: `Use this command to move the IPLD blocks of data indexed at or below a block height from the public.blocks table
+to a cheaper cold tier blockstore, a directory of block files or an S3-compatible object store.
+
+The moved blocks are recorded in public.block_locations, and watchers configured with the same [tier] blockstore read
+through to it for them.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *log.WithField("SubCommand", subCommand)
+		tierBlocks()
+	},
+}
+
+func tierBlocks() {
+	logWithCommand.Infof("running ipfs-blockchain-watcher version: %s", v.VersionWithMeta)
+	logWithCommand.Debug("loading tier configuration variables")
+	tConfig, err := tier.NewConfig()
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Infof("tier config: %+v", tConfig)
+	logWithCommand.Debug("initializing new tier service")
+	tService, err := tier.NewService(tConfig)
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Info("moving blocks to the cold tier")
+	if err := tService.Tier(); err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Infof("%s blocks at or below height %d moved to the cold tier", tConfig.Chain.String(), tConfig.Height)
+}
+
+func init() {
+	rootCmd.AddCommand(tierCmd)
+
+	// flags
+	tierCmd.PersistentFlags().String("tier-chain", "", "which chain to move blocks of, options are currently Ethereum or Bitcoin.")
+	tierCmd.PersistentFlags().Int("tier-height", 0, "block height at or below which blocks are moved")
+	tierCmd.PersistentFlags().StringSlice("tier-data-types", nil, "which types of data to move the blocks of")
+	tierCmd.PersistentFlags().Int("tier-range-size", tier.DefaultRangeSize, "number of block heights to move the blocks of in a single db transaction")
+	tierCmd.PersistentFlags().String("tier-store", "", "type of the cold tier blockstore, filesystem or s3")
+	tierCmd.PersistentFlags().String("tier-path", "", "directory of the filesystem cold tier blockstore")
+
+	// and their bindings
+	viper.BindPFlag("tier.chain", tierCmd.PersistentFlags().Lookup("tier-chain"))
+	viper.BindPFlag("tier.height", tierCmd.PersistentFlags().Lookup("tier-height"))
+	viper.BindPFlag("tier.dataTypes", tierCmd.PersistentFlags().Lookup("tier-data-types"))
+	viper.BindPFlag("tier.rangeSize", tierCmd.PersistentFlags().Lookup("tier-range-size"))
+	viper.BindPFlag("tier.store", tierCmd.PersistentFlags().Lookup("tier-store"))
+	viper.BindPFlag("tier.path", tierCmd.PersistentFlags().Lookup("tier-path"))
+}
@@ -0,0 +1,93 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package cmd
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/validate"
+	v "github.com/vulcanize/ipfs-blockchain-watcher/version"
+)
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate indexed blocks against their stored IPLDs",
+	Long: `Use this command to re-verify indexed blocks from their stored IPLDs. Every header has to hash to its indexed hash
+and link to an indexed parent, and the transaction root (receipt root and uncle hash for Ethereum, merkle root for
+Bitcoin) has to be recomputed from the indexed IPLDs to the root in the header. With validate.state the stored
+Ethereum state and storage diff nodes also have to hash into the header's state root.
+
+The times_validated of the blocks that pass is incremented; the problems with the others are logged, and the command
+fails if there are any.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		subCommand = cmd.CalledAs()
+		logWithCommand = *log.WithField("SubCommand", subCommand)
+		validateBlocks()
+	},
+}
+
+func validateBlocks() {
+	logWithCommand.Infof("running ipfs-blockchain-watcher version: %s", v.VersionWithMeta)
+	logWithCommand.Debug("loading validate configuration variables")
+	vConfig, err := validate.NewConfig()
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Infof("validate config: %+v", vConfig)
+	if vConfig.IPFSMode == shared.LocalInterface {
+		if err := ipfs.InitIPFSPlugins(); err != nil {
+			logWithCommand.Fatal(err)
+		}
+	}
+	logWithCommand.Debug("initializing new validate service")
+	vService, err := validate.NewService(vConfig)
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	logWithCommand.Info("validating blocks")
+	stats, err := vService.Validate()
+	if err != nil {
+		logWithCommand.Fatal(err)
+	}
+	if stats.Invalid > 0 {
+		logWithCommand.Fatalf("%d of %d %s blocks are invalid", stats.Invalid, stats.Validated+stats.Invalid, vConfig.Chain.String())
+	}
+	logWithCommand.Infof("all %d %s blocks validated", stats.Validated, vConfig.Chain.String())
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+
+	// flags
+	validateCmd.PersistentFlags().String("validate-chain", "", "which chain to validate the blocks of, options are currently Ethereum or Bitcoin.")
+	validateCmd.PersistentFlags().Int("validate-start", 0, "block height to start validating at")
+	validateCmd.PersistentFlags().Int("validate-stop", 0, "block height to stop validating at; 0 validates up to the highest indexed header")
+	validateCmd.PersistentFlags().Int("validate-range-size", validate.DefaultRangeSize, "number of block heights to validate in a single db transaction")
+	validateCmd.PersistentFlags().Bool("validate-state", false, "if true, also verify that the stored state and storage nodes hash into the state root (Ethereum only)")
+
+	// and their bindings
+	viper.BindPFlag("validate.chain", validateCmd.PersistentFlags().Lookup("validate-chain"))
+	viper.BindPFlag("validate.start", validateCmd.PersistentFlags().Lookup("validate-start"))
+	viper.BindPFlag("validate.stop", validateCmd.PersistentFlags().Lookup("validate-stop"))
+	viper.BindPFlag("validate.rangeSize", validateCmd.PersistentFlags().Lookup("validate-range-size"))
+	viper.BindPFlag("validate.state", validateCmd.PersistentFlags().Lookup("validate-state"))
+}
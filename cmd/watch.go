@@ -25,6 +25,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/gateway"
 	h "github.com/vulcanize/ipfs-blockchain-watcher/pkg/historical"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
@@ -42,7 +43,8 @@ The Sync process streams all chain data from the appropriate chain, processes th
 and publishes them to IPFS. It then indexes the CIDs against useful data fields/metadata in Postgres. 
 
 The Serve process creates and exposes a rpc subscription server over ws and ipc. Transformers can subscribe to
-these endpoints to stream
+these endpoints to stream. If a gateway path is configured it also serves the IPLD blocks, and the IPLD paths
+resolved from them, over HTTP
 
 The BackFill process spins up a background process which periodically probes the Postgres database to identify
 and fill in gaps in the data
@@ -124,12 +126,21 @@ func startServers(watcher w.Watcher, settings *w.Config) error {
 		return err
 	}
 	logWithCommand.Debug("starting up WS server")
-	_, _, err = rpc.StartWSEndpoint(settings.WSEndpoint, watcher.APIs(), []string{"vdb"}, nil, true)
+	_, _, err = rpc.StartWSEndpoint(settings.WSEndpoint, watcher.APIs(), []string{"vdb", settings.Chain.API()}, nil, true)
 	if err != nil {
 		return err
 	}
 	logWithCommand.Debug("starting up HTTP server")
 	_, _, err = rpc.StartHTTPEndpoint(settings.HTTPEndpoint, watcher.APIs(), []string{settings.Chain.API()}, nil, nil, rpc.HTTPTimeouts{})
+	if err != nil || settings.GatewayEndpoint == "" {
+		return err
+	}
+	logWithCommand.Debug("starting up IPLD gateway")
+	ipldGateway, err := gateway.NewGateway(settings.IPFSPath, settings.ServeDBConn, settings.IPFSMode, settings.Blockstores)
+	if err != nil {
+		return err
+	}
+	_, err = gateway.StartHTTPEndpoint(settings.GatewayEndpoint, ipldGateway)
 	return err
 }
 
@@ -139,12 +150,14 @@ func init() {
 	// flags for all config variables
 	watchCmd.PersistentFlags().String("ipfs-path", "", "ipfs repository path")
 	watchCmd.PersistentFlags().String("ipfs-mode", "", "ipfs operation mode")
+	watchCmd.PersistentFlags().String("ipfs-api", "", "http api address of the ipfs daemon used in remote mode")
 
 	watchCmd.PersistentFlags().String("watcher-chain", "", "which chain to support, options are currently Ethereum or Bitcoin.")
 	watchCmd.PersistentFlags().Bool("watcher-server", false, "turn vdb server on or off")
 	watchCmd.PersistentFlags().String("watcher-ws-path", "", "vdb server ws path")
 	watchCmd.PersistentFlags().String("watcher-http-path", "", "vdb server http path")
 	watchCmd.PersistentFlags().String("watcher-ipc-path", "", "vdb server ipc path")
+	watchCmd.PersistentFlags().String("watcher-gateway-path", "", "vdb server IPLD gateway path; the gateway is only served if this is set")
 	watchCmd.PersistentFlags().Bool("watcher-sync", false, "turn vdb sync on or off")
 	watchCmd.PersistentFlags().Int("watcher-workers", 0, "how many worker goroutines to publish and index data")
 	watchCmd.PersistentFlags().Bool("watcher-back-fill", false, "turn vdb backfill on or off")
@@ -152,6 +165,7 @@ func init() {
 	watchCmd.PersistentFlags().Int("watcher-batch-size", 0, "data fetching batch size")
 	watchCmd.PersistentFlags().Int("watcher-batch-number", 0, "how many goroutines to fetch data concurrently")
 	watchCmd.PersistentFlags().Int("watcher-validation-level", 0, "backfill will resync any data below this level")
+	watchCmd.PersistentFlags().Bool("watcher-back-fill-state", false, "backfill will fill in the state of blocks indexed without it; the eth node has to serve their state diffs")
 	watchCmd.PersistentFlags().Int("watcher-timeout", 0, "timeout used for backfill http requests")
 
 	watchCmd.PersistentFlags().String("btc-ws-path", "", "ws url for bitcoin node")
@@ -173,12 +187,14 @@ func init() {
 	// and their bindings
 	viper.BindPFlag("ipfs.path", watchCmd.PersistentFlags().Lookup("ipfs-path"))
 	viper.BindPFlag("ipfs.mode", watchCmd.PersistentFlags().Lookup("ipfs-mode"))
+	viper.BindPFlag("ipfs.api", watchCmd.PersistentFlags().Lookup("ipfs-api"))
 
 	viper.BindPFlag("watcher.chain", watchCmd.PersistentFlags().Lookup("watcher-chain"))
 	viper.BindPFlag("watcher.server", watchCmd.PersistentFlags().Lookup("watcher-server"))
 	viper.BindPFlag("watcher.wsPath", watchCmd.PersistentFlags().Lookup("watcher-ws-path"))
 	viper.BindPFlag("watcher.httpPath", watchCmd.PersistentFlags().Lookup("watcher-http-path"))
 	viper.BindPFlag("watcher.ipcPath", watchCmd.PersistentFlags().Lookup("watcher-ipc-path"))
+	viper.BindPFlag("watcher.gatewayPath", watchCmd.PersistentFlags().Lookup("watcher-gateway-path"))
 	viper.BindPFlag("watcher.sync", watchCmd.PersistentFlags().Lookup("watcher-sync"))
 	viper.BindPFlag("watcher.workers", watchCmd.PersistentFlags().Lookup("watcher-workers"))
 	viper.BindPFlag("watcher.backFill", watchCmd.PersistentFlags().Lookup("watcher-back-fill"))
@@ -186,6 +202,7 @@ func init() {
 	viper.BindPFlag("watcher.batchSize", watchCmd.PersistentFlags().Lookup("watcher-batch-size"))
 	viper.BindPFlag("watcher.batchNumber", watchCmd.PersistentFlags().Lookup("watcher-batch-number"))
 	viper.BindPFlag("watcher.validationLevel", watchCmd.PersistentFlags().Lookup("watcher-validation-level"))
+	viper.BindPFlag("watcher.backFillState", watchCmd.PersistentFlags().Lookup("watcher-back-fill-state"))
 	viper.BindPFlag("watcher.timeout", watchCmd.PersistentFlags().Lookup("watcher-timeout"))
 
 	viper.BindPFlag("bitcoin.wsPath", watchCmd.PersistentFlags().Lookup("btc-ws-path"))
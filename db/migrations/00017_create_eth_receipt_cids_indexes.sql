@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE INDEX receipt_cids_log_contracts_index ON eth.receipt_cids USING gin (log_contracts);
+CREATE INDEX receipt_cids_topic0s_index ON eth.receipt_cids USING gin (topic0s);
+CREATE INDEX receipt_cids_topic1s_index ON eth.receipt_cids USING gin (topic1s);
+CREATE INDEX receipt_cids_topic2s_index ON eth.receipt_cids USING gin (topic2s);
+CREATE INDEX receipt_cids_topic3s_index ON eth.receipt_cids USING gin (topic3s);
+
+-- +goose Down
+DROP INDEX eth.receipt_cids_topic3s_index;
+DROP INDEX eth.receipt_cids_topic2s_index;
+DROP INDEX eth.receipt_cids_topic1s_index;
+DROP INDEX eth.receipt_cids_topic0s_index;
+DROP INDEX eth.receipt_cids_log_contracts_index;
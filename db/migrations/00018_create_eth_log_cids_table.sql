@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE eth.log_cids (
+  id                    SERIAL PRIMARY KEY,
+  receipt_id            INTEGER NOT NULL REFERENCES eth.receipt_cids (id) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED,
+  log_index             INTEGER NOT NULL,
+  cid                   TEXT NOT NULL,
+  mh_key                TEXT NOT NULL REFERENCES public.blocks (key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED,
+  address               VARCHAR(66) NOT NULL,
+  topic0                VARCHAR(66),
+  topic1                VARCHAR(66),
+  topic2                VARCHAR(66),
+  topic3                VARCHAR(66),
+  UNIQUE (receipt_id, log_index)
+);
+
+CREATE INDEX log_cids_address_index ON eth.log_cids USING btree (address);
+CREATE INDEX log_cids_topic0_index ON eth.log_cids USING btree (topic0);
+CREATE INDEX log_cids_topic1_index ON eth.log_cids USING btree (topic1);
+CREATE INDEX log_cids_topic2_index ON eth.log_cids USING btree (topic2);
+CREATE INDEX log_cids_topic3_index ON eth.log_cids USING btree (topic3);
+
+COMMENT ON TABLE eth.log_cids IS E'@name EthLogCids';
+
+-- +goose Down
+DROP TABLE eth.log_cids;
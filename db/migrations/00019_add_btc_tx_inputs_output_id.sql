@@ -0,0 +1,24 @@
+-- +goose Up
+ALTER TABLE btc.tx_inputs
+ADD COLUMN output_id INTEGER REFERENCES btc.tx_outputs (id) ON DELETE SET NULL DEFERRABLE INITIALLY DEFERRED;
+
+CREATE INDEX tx_inputs_outpoint_index ON btc.tx_inputs USING btree (outpoint_tx_hash, outpoint_index);
+CREATE INDEX tx_inputs_output_id_index ON btc.tx_inputs USING btree (output_id);
+CREATE INDEX tx_outputs_addresses_index ON btc.tx_outputs USING gin (addresses);
+CREATE INDEX transaction_cids_header_id_index ON btc.transaction_cids USING btree (header_id);
+
+-- link the inputs that have already been indexed to the outputs they spend
+UPDATE btc.tx_inputs
+SET output_id = tx_outputs.id
+FROM btc.tx_outputs, btc.transaction_cids
+WHERE tx_outputs.tx_id = transaction_cids.id
+AND transaction_cids.tx_hash = tx_inputs.outpoint_tx_hash
+AND tx_outputs.index = tx_inputs.outpoint_index;
+
+-- +goose Down
+DROP INDEX btc.transaction_cids_header_id_index;
+DROP INDEX btc.tx_outputs_addresses_index;
+DROP INDEX btc.tx_inputs_output_id_index;
+DROP INDEX btc.tx_inputs_outpoint_index;
+ALTER TABLE btc.tx_inputs
+DROP COLUMN output_id;
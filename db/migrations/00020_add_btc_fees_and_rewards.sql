@@ -0,0 +1,56 @@
+-- +goose Up
+ALTER TABLE btc.header_cids
+ADD COLUMN coinbase_value BIGINT,
+ADD COLUMN subsidy BIGINT,
+ADD COLUMN fees BIGINT;
+
+ALTER TABLE btc.transaction_cids
+ADD COLUMN weight INTEGER,
+ADD COLUMN fee BIGINT,
+ADD COLUMN fee_rate NUMERIC;
+
+-- the coinbase value of the blocks that have already been indexed
+UPDATE btc.header_cids
+SET coinbase_value = (SELECT COALESCE(SUM(tx_outputs.value), 0)
+                      FROM btc.tx_outputs, btc.transaction_cids
+                      WHERE tx_outputs.tx_id = transaction_cids.id
+                      AND transaction_cids.header_id = header_cids.id
+                      AND transaction_cids.index = 0);
+
+-- the subsidy follows the halving schedule of the network, which isn't recorded in the database,
+-- so it is left NULL for the blocks that have already been indexed until they are resynced
+ALTER TABLE btc.header_cids
+ALTER COLUMN coinbase_value SET NOT NULL;
+
+-- the fees of the transactions whose inputs have all been linked to the outputs they spend
+-- the weight of these transactions is unknown until they are resynced, so their fee rate is left NULL
+UPDATE btc.transaction_cids
+SET fee = fees.input_value - fees.output_value
+FROM (SELECT tx_inputs.tx_id, SUM(tx_outputs.value) AS input_value,
+        (SELECT SUM(value) FROM btc.tx_outputs WHERE tx_outputs.tx_id = tx_inputs.tx_id) AS output_value
+      FROM btc.tx_inputs
+      LEFT JOIN btc.tx_outputs ON (tx_inputs.output_id = tx_outputs.id)
+      GROUP BY tx_inputs.tx_id
+      HAVING COUNT(tx_outputs.id) = COUNT(*)) AS fees
+WHERE transaction_cids.id = fees.tx_id
+AND transaction_cids.index > 0;
+
+UPDATE btc.header_cids
+SET fees = (SELECT COALESCE(SUM(fee), 0) FROM btc.transaction_cids
+            WHERE transaction_cids.header_id = header_cids.id
+            AND transaction_cids.index > 0)
+WHERE NOT EXISTS (SELECT 1 FROM btc.transaction_cids
+                  WHERE transaction_cids.header_id = header_cids.id
+                  AND transaction_cids.index > 0
+                  AND transaction_cids.fee IS NULL);
+
+-- +goose Down
+ALTER TABLE btc.transaction_cids
+DROP COLUMN fee_rate,
+DROP COLUMN fee,
+DROP COLUMN weight;
+
+ALTER TABLE btc.header_cids
+DROP COLUMN fees,
+DROP COLUMN subsidy,
+DROP COLUMN coinbase_value;
@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE btc.tx_outputs
+ADD COLUMN op_return_data BYTEA;
+
+-- the data of the OP_RETURN outputs that have already been indexed
+-- this only strips the opcode of the first push, outputs that push more than once are corrected when they are resynced
+UPDATE btc.tx_outputs
+SET op_return_data = CASE
+    WHEN length(pk_script) < 2 THEN ''::BYTEA
+    WHEN get_byte(pk_script, 1) BETWEEN 1 AND 75 THEN substring(pk_script FROM 3)
+    WHEN get_byte(pk_script, 1) = 76 THEN substring(pk_script FROM 4)
+    WHEN get_byte(pk_script, 1) = 77 THEN substring(pk_script FROM 5)
+    WHEN get_byte(pk_script, 1) = 78 THEN substring(pk_script FROM 7)
+    ELSE substring(pk_script FROM 2) END
+WHERE substring(pk_script FROM 1 FOR 1) = '\x6a'::BYTEA;
+
+-- +goose Down
+ALTER TABLE btc.tx_outputs
+DROP COLUMN op_return_data;
@@ -0,0 +1,62 @@
+-- +goose Up
+ALTER TABLE btc.header_cids
+ADD COLUMN chain_work NUMERIC,
+ADD COLUMN orphaned BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX header_cids_block_hash_index ON btc.header_cids USING btree (block_hash);
+CREATE INDEX header_cids_parent_hash_index ON btc.header_cids USING btree (parent_hash);
+
+-- a transaction is indexed once for every block it is included in, so that moving blocks in a reorg keeps its history
+ALTER TABLE btc.transaction_cids
+DROP CONSTRAINT transaction_cids_tx_hash_key,
+ADD CONSTRAINT transaction_cids_header_id_tx_hash_key UNIQUE (header_id, tx_hash);
+
+CREATE INDEX transaction_cids_tx_hash_index ON btc.transaction_cids USING btree (tx_hash);
+
+-- of the headers already indexed at the same height, keep the one that has been built on, or else the first one indexed
+UPDATE btc.header_cids
+SET orphaned = true
+FROM (SELECT id, row_number() OVER (PARTITION BY block_number
+        ORDER BY EXISTS (SELECT 1 FROM btc.header_cids AS child WHERE child.parent_hash = header_cids.block_hash) DESC, id) AS rank
+      FROM btc.header_cids) AS ranked
+WHERE header_cids.id = ranked.id
+AND ranked.rank > 1;
+
+-- the work of a block is 2^256 / (target + 1), where the target is decoded from the compact bits
+UPDATE btc.header_cids
+SET chain_work = works.chain_work
+FROM (SELECT id, SUM(div(power(2::NUMERIC, 256), (bits & 8388607) * power(256::NUMERIC, (bits >> 24) - 3) + 1))
+        OVER (ORDER BY block_number) AS chain_work
+      FROM btc.header_cids
+      WHERE NOT orphaned) AS works
+WHERE header_cids.id = works.id;
+
+UPDATE btc.header_cids
+SET chain_work = COALESCE((SELECT parent.chain_work FROM btc.header_cids AS parent
+                           WHERE parent.block_hash = header_cids.parent_hash
+                           AND NOT parent.orphaned), 0) +
+                 div(power(2::NUMERIC, 256), (bits & 8388607) * power(256::NUMERIC, (bits >> 24) - 3) + 1)
+WHERE orphaned;
+
+-- +goose Down
+-- drop the copies of the transactions that were also included in another block
+DELETE FROM btc.transaction_cids
+USING btc.header_cids
+WHERE transaction_cids.header_id = header_cids.id
+AND header_cids.orphaned
+AND EXISTS (SELECT 1 FROM btc.transaction_cids AS other
+            WHERE other.tx_hash = transaction_cids.tx_hash
+            AND other.id <> transaction_cids.id);
+
+DROP INDEX btc.transaction_cids_tx_hash_index;
+
+ALTER TABLE btc.transaction_cids
+DROP CONSTRAINT transaction_cids_header_id_tx_hash_key,
+ADD CONSTRAINT transaction_cids_tx_hash_key UNIQUE (tx_hash);
+
+DROP INDEX btc.header_cids_parent_hash_index;
+DROP INDEX btc.header_cids_block_hash_index;
+
+ALTER TABLE btc.header_cids
+DROP COLUMN orphaned,
+DROP COLUMN chain_work;
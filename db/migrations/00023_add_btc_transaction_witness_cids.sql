@@ -0,0 +1,23 @@
+-- +goose Up
+-- the witness form of a transaction; the same IPLD as the stripped form for transactions without witness data
+ALTER TABLE btc.transaction_cids
+ADD COLUMN witness_cid TEXT,
+ADD COLUMN witness_mh_key TEXT;
+
+-- transactions indexed before now were published in their witness form, which the cids of their segwit transactions
+-- keep referencing; readers tell them apart by their cid being their witness_cid and strip their witness data
+UPDATE btc.transaction_cids
+SET witness_cid = cid,
+    witness_mh_key = mh_key;
+
+ALTER TABLE btc.transaction_cids
+ALTER COLUMN witness_cid SET NOT NULL,
+ALTER COLUMN witness_mh_key SET NOT NULL,
+ADD CONSTRAINT transaction_cids_witness_mh_key_fkey FOREIGN KEY (witness_mh_key)
+  REFERENCES public.blocks (key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
+
+-- +goose Down
+ALTER TABLE btc.transaction_cids
+DROP CONSTRAINT transaction_cids_witness_mh_key_fkey,
+DROP COLUMN witness_mh_key,
+DROP COLUMN witness_cid;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- headers imported without a state diff, e.g. from block export files, have no state or storage nodes indexed for them
+ALTER TABLE eth.header_cids
+ADD COLUMN state_absent BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE eth.header_cids
+DROP COLUMN state_absent;
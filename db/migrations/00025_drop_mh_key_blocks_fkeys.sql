@@ -0,0 +1,38 @@
+-- +goose Up
+-- IPLDs can be kept in blockstores other than public.blocks, so the mhkeys can no longer reference it
+-- deployments that keep every block in public.blocks can add them back with the foreignKeys command
+ALTER TABLE eth.header_cids DROP CONSTRAINT header_cids_mh_key_fkey;
+ALTER TABLE eth.uncle_cids DROP CONSTRAINT uncle_cids_mh_key_fkey;
+ALTER TABLE eth.transaction_cids DROP CONSTRAINT transaction_cids_mh_key_fkey;
+ALTER TABLE eth.receipt_cids DROP CONSTRAINT receipt_cids_mh_key_fkey;
+ALTER TABLE eth.log_cids DROP CONSTRAINT log_cids_mh_key_fkey;
+ALTER TABLE eth.state_cids DROP CONSTRAINT state_cids_mh_key_fkey;
+ALTER TABLE eth.storage_cids DROP CONSTRAINT storage_cids_mh_key_fkey;
+ALTER TABLE btc.header_cids DROP CONSTRAINT header_cids_mh_key_fkey;
+ALTER TABLE btc.transaction_cids
+DROP CONSTRAINT transaction_cids_mh_key_fkey,
+DROP CONSTRAINT transaction_cids_witness_mh_key_fkey;
+
+-- +goose Down
+-- this fails if any of the IPLDs have been kept outside of public.blocks
+ALTER TABLE btc.transaction_cids
+ADD CONSTRAINT transaction_cids_witness_mh_key_fkey FOREIGN KEY (witness_mh_key)
+  REFERENCES public.blocks (key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED,
+ADD CONSTRAINT transaction_cids_mh_key_fkey FOREIGN KEY (mh_key)
+  REFERENCES public.blocks (key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
+ALTER TABLE btc.header_cids ADD CONSTRAINT header_cids_mh_key_fkey FOREIGN KEY (mh_key)
+  REFERENCES public.blocks (key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
+ALTER TABLE eth.storage_cids ADD CONSTRAINT storage_cids_mh_key_fkey FOREIGN KEY (mh_key)
+  REFERENCES public.blocks (key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
+ALTER TABLE eth.state_cids ADD CONSTRAINT state_cids_mh_key_fkey FOREIGN KEY (mh_key)
+  REFERENCES public.blocks (key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
+ALTER TABLE eth.log_cids ADD CONSTRAINT log_cids_mh_key_fkey FOREIGN KEY (mh_key)
+  REFERENCES public.blocks (key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
+ALTER TABLE eth.receipt_cids ADD CONSTRAINT receipt_cids_mh_key_fkey FOREIGN KEY (mh_key)
+  REFERENCES public.blocks (key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
+ALTER TABLE eth.transaction_cids ADD CONSTRAINT transaction_cids_mh_key_fkey FOREIGN KEY (mh_key)
+  REFERENCES public.blocks (key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
+ALTER TABLE eth.uncle_cids ADD CONSTRAINT uncle_cids_mh_key_fkey FOREIGN KEY (mh_key)
+  REFERENCES public.blocks (key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
+ALTER TABLE eth.header_cids ADD CONSTRAINT header_cids_mh_key_fkey FOREIGN KEY (mh_key)
+  REFERENCES public.blocks (key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
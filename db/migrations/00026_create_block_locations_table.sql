@@ -0,0 +1,11 @@
+-- +goose Up
+-- the blocks that have been moved out of public.blocks to a cold tier blockstore, and the type of that blockstore
+-- a move is recorded before the block is put into the cold tier, and the block is only read from there once it is moved
+CREATE TABLE public.block_locations (
+  key TEXT PRIMARY KEY,
+  store TEXT NOT NULL,
+  moved BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+-- +goose Down
+DROP TABLE public.block_locations;
@@ -0,0 +1,24 @@
+-- +goose Up
+-- the blocks the index rows reference are looked up by their mh_key when unreferenced blocks are garbage collected
+CREATE INDEX header_cids_mh_key_index ON btc.header_cids USING btree (mh_key);
+CREATE INDEX transaction_cids_mh_key_index ON btc.transaction_cids USING btree (mh_key);
+CREATE INDEX transaction_cids_witness_mh_key_index ON btc.transaction_cids USING btree (witness_mh_key);
+CREATE INDEX header_cids_mh_key_index ON eth.header_cids USING btree (mh_key);
+CREATE INDEX uncle_cids_mh_key_index ON eth.uncle_cids USING btree (mh_key);
+CREATE INDEX transaction_cids_mh_key_index ON eth.transaction_cids USING btree (mh_key);
+CREATE INDEX receipt_cids_mh_key_index ON eth.receipt_cids USING btree (mh_key);
+CREATE INDEX log_cids_mh_key_index ON eth.log_cids USING btree (mh_key);
+CREATE INDEX state_cids_mh_key_index ON eth.state_cids USING btree (mh_key);
+CREATE INDEX storage_cids_mh_key_index ON eth.storage_cids USING btree (mh_key);
+
+-- +goose Down
+DROP INDEX eth.storage_cids_mh_key_index;
+DROP INDEX eth.state_cids_mh_key_index;
+DROP INDEX eth.log_cids_mh_key_index;
+DROP INDEX eth.receipt_cids_mh_key_index;
+DROP INDEX eth.transaction_cids_mh_key_index;
+DROP INDEX eth.uncle_cids_mh_key_index;
+DROP INDEX eth.header_cids_mh_key_index;
+DROP INDEX btc.transaction_cids_witness_mh_key_index;
+DROP INDEX btc.transaction_cids_mh_key_index;
+DROP INDEX btc.header_cids_mh_key_index;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- the blocks a garbage collection has marked as hanging off of an indexed header, emptied when the collection ends
+CREATE UNLOGGED TABLE public.gc_marks (
+  key TEXT PRIMARY KEY
+);
+
+-- +goose Down
+DROP TABLE public.gc_marks;
@@ -48,7 +48,12 @@ CREATE TABLE btc.header_cids (
     "timestamp" numeric NOT NULL,
     bits bigint NOT NULL,
     node_id integer NOT NULL,
-    times_validated integer DEFAULT 1 NOT NULL
+    times_validated integer DEFAULT 1 NOT NULL,
+    coinbase_value bigint NOT NULL,
+    subsidy bigint,
+    fees bigint,
+    chain_work numeric,
+    orphaned boolean DEFAULT false NOT NULL
 );
 
 
@@ -98,7 +103,12 @@ CREATE TABLE btc.transaction_cids (
     cid text NOT NULL,
     mh_key text NOT NULL,
     segwit boolean NOT NULL,
-    witness_hash character varying(66)
+    witness_hash character varying(66),
+    weight integer,
+    fee bigint,
+    fee_rate numeric,
+    witness_cid text NOT NULL,
+    witness_mh_key text NOT NULL
 );
 
 
@@ -140,7 +150,8 @@ CREATE TABLE btc.tx_inputs (
     witness character varying[],
     sig_script bytea NOT NULL,
     outpoint_tx_hash character varying(66) NOT NULL,
-    outpoint_index numeric NOT NULL
+    outpoint_index numeric NOT NULL,
+    output_id integer
 );
 
 
@@ -176,7 +187,8 @@ CREATE TABLE btc.tx_outputs (
     pk_script bytea NOT NULL,
     script_class integer NOT NULL,
     addresses character varying(66)[],
-    required_sigs integer NOT NULL
+    required_sigs integer NOT NULL,
+    op_return_data bytea
 );
 
 
@@ -220,7 +232,8 @@ CREATE TABLE eth.header_cids (
     uncle_root character varying(66) NOT NULL,
     bloom bytea NOT NULL,
     "timestamp" numeric NOT NULL,
-    times_validated integer DEFAULT 1 NOT NULL
+    times_validated integer DEFAULT 1 NOT NULL,
+    state_absent boolean DEFAULT false NOT NULL
 );
 
 
@@ -258,6 +271,51 @@ CREATE SEQUENCE eth.header_cids_id_seq
 ALTER SEQUENCE eth.header_cids_id_seq OWNED BY eth.header_cids.id;
 
 
+--
+-- Name: log_cids; Type: TABLE; Schema: eth; Owner: -
+--
+
+CREATE TABLE eth.log_cids (
+    id integer NOT NULL,
+    receipt_id integer NOT NULL,
+    log_index integer NOT NULL,
+    cid text NOT NULL,
+    mh_key text NOT NULL,
+    address character varying(66) NOT NULL,
+    topic0 character varying(66),
+    topic1 character varying(66),
+    topic2 character varying(66),
+    topic3 character varying(66)
+);
+
+
+--
+-- Name: TABLE log_cids; Type: COMMENT; Schema: eth; Owner: -
+--
+
+COMMENT ON TABLE eth.log_cids IS '@name EthLogCids';
+
+
+--
+-- Name: log_cids_id_seq; Type: SEQUENCE; Schema: eth; Owner: -
+--
+
+CREATE SEQUENCE eth.log_cids_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+
+--
+-- Name: log_cids_id_seq; Type: SEQUENCE OWNED BY; Schema: eth; Owner: -
+--
+
+ALTER SEQUENCE eth.log_cids_id_seq OWNED BY eth.log_cids.id;
+
+
 --
 -- Name: receipt_cids; Type: TABLE; Schema: eth; Owner: -
 --
@@ -481,6 +539,17 @@ CREATE SEQUENCE eth.uncle_cids_id_seq
 ALTER SEQUENCE eth.uncle_cids_id_seq OWNED BY eth.uncle_cids.id;
 
 
+--
+-- Name: block_locations; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE TABLE public.block_locations (
+    key text NOT NULL,
+    store text NOT NULL,
+    moved boolean DEFAULT false NOT NULL
+);
+
+
 --
 -- Name: blocks; Type: TABLE; Schema: public; Owner: -
 --
@@ -491,6 +560,15 @@ CREATE TABLE public.blocks (
 );
 
 
+--
+-- Name: gc_marks; Type: TABLE; Schema: public; Owner: -
+--
+
+CREATE UNLOGGED TABLE public.gc_marks (
+    key text NOT NULL
+);
+
+
 --
 -- Name: goose_db_version; Type: TABLE; Schema: public; Owner: -
 --
@@ -605,6 +683,13 @@ ALTER TABLE ONLY btc.tx_outputs ALTER COLUMN id SET DEFAULT nextval('btc.tx_outp
 ALTER TABLE ONLY eth.header_cids ALTER COLUMN id SET DEFAULT nextval('eth.header_cids_id_seq'::regclass);
 
 
+--
+-- Name: log_cids id; Type: DEFAULT; Schema: eth; Owner: -
+--
+
+ALTER TABLE ONLY eth.log_cids ALTER COLUMN id SET DEFAULT nextval('eth.log_cids_id_seq'::regclass);
+
+
 --
 -- Name: receipt_cids id; Type: DEFAULT; Schema: eth; Owner: -
 --
@@ -678,19 +763,19 @@ ALTER TABLE ONLY btc.header_cids
 
 
 --
--- Name: transaction_cids transaction_cids_pkey; Type: CONSTRAINT; Schema: btc; Owner: -
+-- Name: transaction_cids transaction_cids_header_id_tx_hash_key; Type: CONSTRAINT; Schema: btc; Owner: -
 --
 
 ALTER TABLE ONLY btc.transaction_cids
-    ADD CONSTRAINT transaction_cids_pkey PRIMARY KEY (id);
+    ADD CONSTRAINT transaction_cids_header_id_tx_hash_key UNIQUE (header_id, tx_hash);
 
 
 --
--- Name: transaction_cids transaction_cids_tx_hash_key; Type: CONSTRAINT; Schema: btc; Owner: -
+-- Name: transaction_cids transaction_cids_pkey; Type: CONSTRAINT; Schema: btc; Owner: -
 --
 
 ALTER TABLE ONLY btc.transaction_cids
-    ADD CONSTRAINT transaction_cids_tx_hash_key UNIQUE (tx_hash);
+    ADD CONSTRAINT transaction_cids_pkey PRIMARY KEY (id);
 
 
 --
@@ -741,6 +826,22 @@ ALTER TABLE ONLY eth.header_cids
     ADD CONSTRAINT header_cids_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: log_cids log_cids_pkey; Type: CONSTRAINT; Schema: eth; Owner: -
+--
+
+ALTER TABLE ONLY eth.log_cids
+    ADD CONSTRAINT log_cids_pkey PRIMARY KEY (id);
+
+
+--
+-- Name: log_cids log_cids_receipt_id_log_index_key; Type: CONSTRAINT; Schema: eth; Owner: -
+--
+
+ALTER TABLE ONLY eth.log_cids
+    ADD CONSTRAINT log_cids_receipt_id_log_index_key UNIQUE (receipt_id, log_index);
+
+
 --
 -- Name: receipt_cids receipt_cids_pkey; Type: CONSTRAINT; Schema: eth; Owner: -
 --
@@ -837,6 +938,14 @@ ALTER TABLE ONLY eth.uncle_cids
     ADD CONSTRAINT uncle_cids_pkey PRIMARY KEY (id);
 
 
+--
+-- Name: block_locations block_locations_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.block_locations
+    ADD CONSTRAINT block_locations_pkey PRIMARY KEY (key);
+
+
 --
 -- Name: blocks blocks_key_key; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -845,6 +954,14 @@ ALTER TABLE ONLY public.blocks
     ADD CONSTRAINT blocks_key_key UNIQUE (key);
 
 
+--
+-- Name: gc_marks gc_marks_pkey; Type: CONSTRAINT; Schema: public; Owner: -
+--
+
+ALTER TABLE ONLY public.gc_marks
+    ADD CONSTRAINT gc_marks_pkey PRIMARY KEY (key);
+
+
 --
 -- Name: goose_db_version goose_db_version_pkey; Type: CONSTRAINT; Schema: public; Owner: -
 --
@@ -870,11 +987,193 @@ ALTER TABLE ONLY public.nodes
 
 
 --
--- Name: header_cids header_cids_mh_key_fkey; Type: FK CONSTRAINT; Schema: btc; Owner: -
+-- Name: header_cids_block_hash_index; Type: INDEX; Schema: btc; Owner: -
 --
 
-ALTER TABLE ONLY btc.header_cids
-    ADD CONSTRAINT header_cids_mh_key_fkey FOREIGN KEY (mh_key) REFERENCES public.blocks(key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
+CREATE INDEX header_cids_block_hash_index ON btc.header_cids USING btree (block_hash);
+
+
+--
+-- Name: header_cids_mh_key_index; Type: INDEX; Schema: btc; Owner: -
+--
+
+CREATE INDEX header_cids_mh_key_index ON btc.header_cids USING btree (mh_key);
+
+
+--
+-- Name: header_cids_parent_hash_index; Type: INDEX; Schema: btc; Owner: -
+--
+
+CREATE INDEX header_cids_parent_hash_index ON btc.header_cids USING btree (parent_hash);
+
+
+--
+-- Name: transaction_cids_header_id_index; Type: INDEX; Schema: btc; Owner: -
+--
+
+CREATE INDEX transaction_cids_header_id_index ON btc.transaction_cids USING btree (header_id);
+
+
+--
+-- Name: transaction_cids_mh_key_index; Type: INDEX; Schema: btc; Owner: -
+--
+
+CREATE INDEX transaction_cids_mh_key_index ON btc.transaction_cids USING btree (mh_key);
+
+
+--
+-- Name: transaction_cids_tx_hash_index; Type: INDEX; Schema: btc; Owner: -
+--
+
+CREATE INDEX transaction_cids_tx_hash_index ON btc.transaction_cids USING btree (tx_hash);
+
+
+--
+-- Name: transaction_cids_witness_mh_key_index; Type: INDEX; Schema: btc; Owner: -
+--
+
+CREATE INDEX transaction_cids_witness_mh_key_index ON btc.transaction_cids USING btree (witness_mh_key);
+
+
+--
+-- Name: tx_inputs_outpoint_index; Type: INDEX; Schema: btc; Owner: -
+--
+
+CREATE INDEX tx_inputs_outpoint_index ON btc.tx_inputs USING btree (outpoint_tx_hash, outpoint_index);
+
+
+--
+-- Name: tx_inputs_output_id_index; Type: INDEX; Schema: btc; Owner: -
+--
+
+CREATE INDEX tx_inputs_output_id_index ON btc.tx_inputs USING btree (output_id);
+
+
+--
+-- Name: tx_outputs_addresses_index; Type: INDEX; Schema: btc; Owner: -
+--
+
+CREATE INDEX tx_outputs_addresses_index ON btc.tx_outputs USING gin (addresses);
+
+
+--
+-- Name: header_cids_mh_key_index; Type: INDEX; Schema: eth; Owner: -
+--
+
+CREATE INDEX header_cids_mh_key_index ON eth.header_cids USING btree (mh_key);
+
+
+--
+-- Name: log_cids_address_index; Type: INDEX; Schema: eth; Owner: -
+--
+
+CREATE INDEX log_cids_address_index ON eth.log_cids USING btree (address);
+
+
+--
+-- Name: log_cids_mh_key_index; Type: INDEX; Schema: eth; Owner: -
+--
+
+CREATE INDEX log_cids_mh_key_index ON eth.log_cids USING btree (mh_key);
+
+
+--
+-- Name: log_cids_topic0_index; Type: INDEX; Schema: eth; Owner: -
+--
+
+CREATE INDEX log_cids_topic0_index ON eth.log_cids USING btree (topic0);
+
+
+--
+-- Name: log_cids_topic1_index; Type: INDEX; Schema: eth; Owner: -
+--
+
+CREATE INDEX log_cids_topic1_index ON eth.log_cids USING btree (topic1);
+
+
+--
+-- Name: log_cids_topic2_index; Type: INDEX; Schema: eth; Owner: -
+--
+
+CREATE INDEX log_cids_topic2_index ON eth.log_cids USING btree (topic2);
+
+
+--
+-- Name: log_cids_topic3_index; Type: INDEX; Schema: eth; Owner: -
+--
+
+CREATE INDEX log_cids_topic3_index ON eth.log_cids USING btree (topic3);
+
+
+--
+-- Name: receipt_cids_log_contracts_index; Type: INDEX; Schema: eth; Owner: -
+--
+
+CREATE INDEX receipt_cids_log_contracts_index ON eth.receipt_cids USING gin (log_contracts);
+
+
+--
+-- Name: receipt_cids_mh_key_index; Type: INDEX; Schema: eth; Owner: -
+--
+
+CREATE INDEX receipt_cids_mh_key_index ON eth.receipt_cids USING btree (mh_key);
+
+
+--
+-- Name: receipt_cids_topic0s_index; Type: INDEX; Schema: eth; Owner: -
+--
+
+CREATE INDEX receipt_cids_topic0s_index ON eth.receipt_cids USING gin (topic0s);
+
+
+--
+-- Name: receipt_cids_topic1s_index; Type: INDEX; Schema: eth; Owner: -
+--
+
+CREATE INDEX receipt_cids_topic1s_index ON eth.receipt_cids USING gin (topic1s);
+
+
+--
+-- Name: receipt_cids_topic2s_index; Type: INDEX; Schema: eth; Owner: -
+--
+
+CREATE INDEX receipt_cids_topic2s_index ON eth.receipt_cids USING gin (topic2s);
+
+
+--
+-- Name: receipt_cids_topic3s_index; Type: INDEX; Schema: eth; Owner: -
+--
+
+CREATE INDEX receipt_cids_topic3s_index ON eth.receipt_cids USING gin (topic3s);
+
+
+--
+-- Name: state_cids_mh_key_index; Type: INDEX; Schema: eth; Owner: -
+--
+
+CREATE INDEX state_cids_mh_key_index ON eth.state_cids USING btree (mh_key);
+
+
+--
+-- Name: storage_cids_mh_key_index; Type: INDEX; Schema: eth; Owner: -
+--
+
+CREATE INDEX storage_cids_mh_key_index ON eth.storage_cids USING btree (mh_key);
+
+
+--
+-- Name: transaction_cids_mh_key_index; Type: INDEX; Schema: eth; Owner: -
+--
+
+CREATE INDEX transaction_cids_mh_key_index ON eth.transaction_cids USING btree (mh_key);
+
+
+--
+-- Name: uncle_cids_mh_key_index; Type: INDEX; Schema: eth; Owner: -
+--
+
+CREATE INDEX uncle_cids_mh_key_index ON eth.uncle_cids USING btree (mh_key);
+
 
 
 --
@@ -893,12 +1192,14 @@ ALTER TABLE ONLY btc.transaction_cids
     ADD CONSTRAINT transaction_cids_header_id_fkey FOREIGN KEY (header_id) REFERENCES btc.header_cids(id) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
 
 
+
+
 --
--- Name: transaction_cids transaction_cids_mh_key_fkey; Type: FK CONSTRAINT; Schema: btc; Owner: -
+-- Name: tx_inputs tx_inputs_output_id_fkey; Type: FK CONSTRAINT; Schema: btc; Owner: -
 --
 
-ALTER TABLE ONLY btc.transaction_cids
-    ADD CONSTRAINT transaction_cids_mh_key_fkey FOREIGN KEY (mh_key) REFERENCES public.blocks(key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
+ALTER TABLE ONLY btc.tx_inputs
+    ADD CONSTRAINT tx_inputs_output_id_fkey FOREIGN KEY (output_id) REFERENCES btc.tx_outputs(id) ON DELETE SET NULL DEFERRABLE INITIALLY DEFERRED;
 
 
 --
@@ -917,13 +1218,6 @@ ALTER TABLE ONLY btc.tx_outputs
     ADD CONSTRAINT tx_outputs_tx_id_fkey FOREIGN KEY (tx_id) REFERENCES btc.transaction_cids(id) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
 
 
---
--- Name: header_cids header_cids_mh_key_fkey; Type: FK CONSTRAINT; Schema: eth; Owner: -
---
-
-ALTER TABLE ONLY eth.header_cids
-    ADD CONSTRAINT header_cids_mh_key_fkey FOREIGN KEY (mh_key) REFERENCES public.blocks(key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
-
 
 --
 -- Name: header_cids header_cids_node_id_fkey; Type: FK CONSTRAINT; Schema: eth; Owner: -
@@ -933,12 +1227,14 @@ ALTER TABLE ONLY eth.header_cids
     ADD CONSTRAINT header_cids_node_id_fkey FOREIGN KEY (node_id) REFERENCES public.nodes(id) ON DELETE CASCADE;
 
 
+
 --
--- Name: receipt_cids receipt_cids_mh_key_fkey; Type: FK CONSTRAINT; Schema: eth; Owner: -
+-- Name: log_cids log_cids_receipt_id_fkey; Type: FK CONSTRAINT; Schema: eth; Owner: -
 --
 
-ALTER TABLE ONLY eth.receipt_cids
-    ADD CONSTRAINT receipt_cids_mh_key_fkey FOREIGN KEY (mh_key) REFERENCES public.blocks(key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
+ALTER TABLE ONLY eth.log_cids
+    ADD CONSTRAINT log_cids_receipt_id_fkey FOREIGN KEY (receipt_id) REFERENCES eth.receipt_cids(id) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
+
 
 
 --
@@ -965,20 +1261,6 @@ ALTER TABLE ONLY eth.state_cids
     ADD CONSTRAINT state_cids_header_id_fkey FOREIGN KEY (header_id) REFERENCES eth.header_cids(id) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
 
 
---
--- Name: state_cids state_cids_mh_key_fkey; Type: FK CONSTRAINT; Schema: eth; Owner: -
---
-
-ALTER TABLE ONLY eth.state_cids
-    ADD CONSTRAINT state_cids_mh_key_fkey FOREIGN KEY (mh_key) REFERENCES public.blocks(key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
-
-
---
--- Name: storage_cids storage_cids_mh_key_fkey; Type: FK CONSTRAINT; Schema: eth; Owner: -
---
-
-ALTER TABLE ONLY eth.storage_cids
-    ADD CONSTRAINT storage_cids_mh_key_fkey FOREIGN KEY (mh_key) REFERENCES public.blocks(key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
 
 
 --
@@ -997,13 +1279,6 @@ ALTER TABLE ONLY eth.transaction_cids
     ADD CONSTRAINT transaction_cids_header_id_fkey FOREIGN KEY (header_id) REFERENCES eth.header_cids(id) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
 
 
---
--- Name: transaction_cids transaction_cids_mh_key_fkey; Type: FK CONSTRAINT; Schema: eth; Owner: -
---
-
-ALTER TABLE ONLY eth.transaction_cids
-    ADD CONSTRAINT transaction_cids_mh_key_fkey FOREIGN KEY (mh_key) REFERENCES public.blocks(key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
-
 
 --
 -- Name: uncle_cids uncle_cids_header_id_fkey; Type: FK CONSTRAINT; Schema: eth; Owner: -
@@ -1013,13 +1288,6 @@ ALTER TABLE ONLY eth.uncle_cids
     ADD CONSTRAINT uncle_cids_header_id_fkey FOREIGN KEY (header_id) REFERENCES eth.header_cids(id) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
 
 
---
--- Name: uncle_cids uncle_cids_mh_key_fkey; Type: FK CONSTRAINT; Schema: eth; Owner: -
---
-
-ALTER TABLE ONLY eth.uncle_cids
-    ADD CONSTRAINT uncle_cids_mh_key_fkey FOREIGN KEY (mh_key) REFERENCES public.blocks(key) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED;
-
 
 --
 -- PostgreSQL database dump complete
@@ -0,0 +1,208 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockstore_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/multiformats/go-multihash"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/blockstore"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/blockstore/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+var (
+	mockKey1  = "/blocks/CIQPFFYUB6GUSGO5HMY4WZA4OGMPCAJ3HKGM4ZCQPKPMGDS4HLJ4CZI"
+	mockKey2  = "/blocks/CIQOVQ6UEYX6K6AJ3MKMCEYZONWI4Q53YCZFSV2C3G3LH4F6TRW77UQ"
+	mockData1 = []byte("first block")
+	mockData2 = []byte("second block")
+)
+
+// expectBlockstore runs the operations of the shared.Blockstore interface against the blockstore
+func expectBlockstore(store shared.Blockstore) {
+	has, err := store.Has(nil, mockKey1)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(has).To(BeFalse())
+	_, err = store.Get(nil, mockKey1)
+	Expect(err).To(Equal(shared.ErrBlockNotFound))
+
+	Expect(store.Put(nil, mockKey1, mockData1)).To(Succeed())
+	Expect(store.Put(nil, mockKey2, mockData2)).To(Succeed())
+	// putting a block that is already held is a no-op
+	Expect(store.Put(nil, mockKey1, mockData1)).To(Succeed())
+	has, err = store.Has(nil, mockKey1)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(has).To(BeTrue())
+	data, err := store.Get(nil, mockKey1)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(data).To(Equal(mockData1))
+	data, err = store.Get(nil, mockKey2)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(data).To(Equal(mockData2))
+
+	Expect(store.Delete(nil, mockKey1)).To(Succeed())
+	has, err = store.Has(nil, mockKey1)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(has).To(BeFalse())
+	_, err = store.Get(nil, mockKey1)
+	Expect(err).To(Equal(shared.ErrBlockNotFound))
+	// deleting a block that isn't held is a no-op
+	Expect(store.Delete(nil, mockKey1)).To(Succeed())
+	has, err = store.Has(nil, mockKey2)
+	Expect(err).ToNot(HaveOccurred())
+	Expect(has).To(BeTrue())
+
+	_, err = store.Get(nil, "/blocks/../../etc/passwd")
+	Expect(err).To(HaveOccurred())
+}
+
+var _ = Describe("Blockstores", func() {
+	var dir string
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "blockstore")
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("FilesystemBlockstore", func() {
+		It("Puts, gets and deletes blocks", func() {
+			store, err := blockstore.NewFilesystemBlockstore(filepath.Join(dir, "blocks"))
+			Expect(err).ToNot(HaveOccurred())
+			expectBlockstore(store)
+		})
+
+		It("Shards the block files by the next-to-last two characters of their key", func() {
+			store, err := blockstore.NewFilesystemBlockstore(dir)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(store.Put(nil, mockKey1, mockData1)).To(Succeed())
+			data, err := ioutil.ReadFile(filepath.Join(dir, "CZ", "CIQPFFYUB6GUSGO5HMY4WZA4OGMPCAJ3HKGM4ZCQPKPMGDS4HLJ4CZI.data"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal(mockData1))
+		})
+
+		It("Replaces block files whose content doesn't hash to their key", func() {
+			store, err := blockstore.NewFilesystemBlockstore(dir)
+			Expect(err).ToNot(HaveOccurred())
+			c, err := ipld.RawdataToCid(ipld.MEthTx, mockData1, multihash.KECCAK_256)
+			Expect(err).ToNot(HaveOccurred())
+			mhKey := shared.MultihashKeyFromCID(c)
+			Expect(store.Put(nil, mhKey, mockData2)).To(Succeed())
+			data, err := store.Get(nil, mhKey)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal(mockData2))
+
+			Expect(store.Put(nil, mhKey, mockData1)).To(Succeed())
+			data, err = store.Get(nil, mhKey)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal(mockData1))
+			// an intact block file is kept
+			Expect(store.Put(nil, mhKey, mockData2)).To(Succeed())
+			data, err = store.Get(nil, mhKey)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(data).To(Equal(mockData1))
+		})
+	})
+
+	Describe("S3Blockstore", func() {
+		var server *mocks.S3Server
+		BeforeEach(func() {
+			server = mocks.NewS3Server("access")
+		})
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("Puts, gets and deletes blocks", func() {
+			store, err := blockstore.NewS3Blockstore(blockstore.S3Config{
+				Endpoint:  server.URL,
+				Bucket:    "bucket",
+				Prefix:    "eth/",
+				AccessKey: "access",
+				SecretKey: "secret",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			expectBlockstore(store)
+			Expect(server.Len()).To(Equal(1))
+			data, ok := server.Object("/bucket/eth/CIQOVQ6UEYX6K6AJ3MKMCEYZONWI4Q53YCZFSV2C3G3LH4F6TRW77UQ")
+			Expect(ok).To(BeTrue())
+			Expect(data).To(Equal(mockData2))
+		})
+
+		It("Errors when the store rejects its requests", func() {
+			store, err := blockstore.NewS3Blockstore(blockstore.S3Config{
+				Endpoint: server.URL,
+				Bucket:   "bucket",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(store.Put(nil, mockKey1, mockData1)).ToNot(Succeed())
+			_, err = store.Get(nil, mockKey1)
+			Expect(err).To(HaveOccurred())
+			Expect(err).ToNot(Equal(shared.ErrBlockNotFound))
+		})
+	})
+
+	Describe("NewBlockstores", func() {
+		var server *mocks.S3Server
+		BeforeEach(func() {
+			server = mocks.NewS3Server("")
+		})
+		AfterEach(func() {
+			server.Close()
+			viper.Reset()
+		})
+
+		It("Keeps every type of data in Postgres by default", func() {
+			stores, err := blockstore.NewBlockstores()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stores.For(shared.Headers)).To(Equal(shared.PostgresBlockstore{}))
+			Expect(stores.For(shared.State)).To(Equal(shared.PostgresBlockstore{}))
+		})
+
+		It("Keeps the types of data with a blockstore of their own in it", func() {
+			viper.Set("blockstore.type", "filesystem")
+			viper.Set("blockstore.path", dir)
+			viper.Set("blockstore.types", map[string]string{"state": "s3", "storage": "s3", "headers": "postgres"})
+			viper.Set("blockstore.s3.endpoint", server.URL)
+			viper.Set("blockstore.s3.bucket", "bucket")
+			stores, err := blockstore.NewBlockstores()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stores.For(shared.Headers)).To(Equal(shared.PostgresBlockstore{}))
+			Expect(stores.For(shared.Transactions)).To(BeAssignableToTypeOf(&blockstore.FilesystemBlockstore{}))
+			Expect(stores.For(shared.State)).To(BeAssignableToTypeOf(&blockstore.S3Blockstore{}))
+			Expect(stores.For(shared.State)).To(BeIdenticalTo(stores.For(shared.Storage)))
+
+			Expect(stores.For(shared.State).Put(nil, mockKey1, mockData1)).To(Succeed())
+			Expect(server.Len()).To(Equal(1))
+		})
+
+		It("Errors on unknown blockstore types", func() {
+			viper.Set("blockstore.types", map[string]string{"state": "tape"})
+			_, err := blockstore.NewBlockstores()
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,187 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockstore
+
+import (
+	"fmt"
+	"strings"
+
+	ipfsblockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// Env variables
+const (
+	BLOCKSTORE_TYPE          = "BLOCKSTORE_TYPE"
+	BLOCKSTORE_PATH          = "BLOCKSTORE_PATH"
+	BLOCKSTORE_S3_ENDPOINT   = "BLOCKSTORE_S3_ENDPOINT"
+	BLOCKSTORE_S3_REGION     = "BLOCKSTORE_S3_REGION"
+	BLOCKSTORE_S3_BUCKET     = "BLOCKSTORE_S3_BUCKET"
+	BLOCKSTORE_S3_PREFIX     = "BLOCKSTORE_S3_PREFIX"
+	BLOCKSTORE_S3_ACCESS_KEY = "BLOCKSTORE_S3_ACCESS_KEY"
+	BLOCKSTORE_S3_SECRET_KEY = "BLOCKSTORE_S3_SECRET_KEY"
+
+	TIER_STORE         = "TIER_STORE"
+	TIER_PATH          = "TIER_PATH"
+	TIER_S3_ENDPOINT   = "TIER_S3_ENDPOINT"
+	TIER_S3_REGION     = "TIER_S3_REGION"
+	TIER_S3_BUCKET     = "TIER_S3_BUCKET"
+	TIER_S3_PREFIX     = "TIER_S3_PREFIX"
+	TIER_S3_ACCESS_KEY = "TIER_S3_ACCESS_KEY"
+	TIER_S3_SECRET_KEY = "TIER_S3_SECRET_KEY"
+)
+
+// blockPrefix is the prefix of every mhkey, it is stripped from the keys to name the blocks
+var blockPrefix = ipfsblockstore.BlockPrefix.String() + "/"
+
+// Type is the kind of backend a blockstore keeps its blocks in
+type Type string
+
+const (
+	Postgres   Type = "postgres"
+	Filesystem Type = "filesystem"
+	S3         Type = "s3"
+)
+
+// NewType returns the blockstore Type for the provided string
+func NewType(str string) (Type, error) {
+	switch strings.ToLower(str) {
+	case "postgres", "pg", "":
+		return Postgres, nil
+	case "filesystem", "fs", "file":
+		return Filesystem, nil
+	case "s3":
+		return S3, nil
+	default:
+		return "", fmt.Errorf("unrecognized blockstore type: %s", str)
+	}
+}
+
+// NewBlockstores builds the blockstores from the [blockstore] section of the config
+// The blocks of every type of data are kept in the blockstore of type blockstore.type, unless a different type is
+// configured for them under blockstore.types, e.g. `state = "s3"`
+// If a cold tier is configured under [tier], the blockstores read through to it for the blocks that have been moved there
+func NewBlockstores() (*shared.Blockstores, error) {
+	viper.BindEnv("blockstore.type", BLOCKSTORE_TYPE)
+	viper.BindEnv("blockstore.path", BLOCKSTORE_PATH)
+	viper.BindEnv("blockstore.s3.endpoint", BLOCKSTORE_S3_ENDPOINT)
+	viper.BindEnv("blockstore.s3.region", BLOCKSTORE_S3_REGION)
+	viper.BindEnv("blockstore.s3.bucket", BLOCKSTORE_S3_BUCKET)
+	viper.BindEnv("blockstore.s3.prefix", BLOCKSTORE_S3_PREFIX)
+	viper.BindEnv("blockstore.s3.accessKey", BLOCKSTORE_S3_ACCESS_KEY)
+	viper.BindEnv("blockstore.s3.secretKey", BLOCKSTORE_S3_SECRET_KEY)
+	viper.BindEnv("tier.store", TIER_STORE)
+
+	// every type of backend is only built once, however many types of data it keeps
+	stores := make(map[Type]shared.Blockstore)
+	get := func(str string) (shared.Blockstore, error) {
+		t, err := NewType(str)
+		if err != nil {
+			return nil, err
+		}
+		if store, ok := stores[t]; ok {
+			return store, nil
+		}
+		store, err := newBlockstore(t, "blockstore")
+		if err != nil {
+			return nil, err
+		}
+		stores[t] = store
+		return store, nil
+	}
+
+	def, err := get(viper.GetString("blockstore.type"))
+	if err != nil {
+		return nil, err
+	}
+	blockstores := shared.NewBlockstores(def)
+	for name, str := range viper.GetStringMapString("blockstore.types") {
+		dataType, err := shared.GenerateDataTypeFromString(name)
+		if err != nil {
+			return nil, err
+		}
+		if dataType == shared.Full {
+			return nil, fmt.Errorf("blockstore.types: a blockstore has to be configured per type of data, not for %s", name)
+		}
+		store, err := get(str)
+		if err != nil {
+			return nil, err
+		}
+		blockstores.Types[dataType] = store
+	}
+	if viper.GetString("tier.store") == "" {
+		return blockstores, nil
+	}
+	cold, coldType, err := NewColdBlockstore()
+	if err != nil {
+		return nil, err
+	}
+	blockstores.Default = NewTieredBlockstore(blockstores.Default, cold, coldType)
+	for dataType, store := range blockstores.Types {
+		blockstores.Types[dataType] = NewTieredBlockstore(store, cold, coldType)
+	}
+	return blockstores, nil
+}
+
+// NewColdBlockstore builds the blockstore of the cold tier from the [tier] section of the config
+func NewColdBlockstore() (shared.Blockstore, Type, error) {
+	viper.BindEnv("tier.store", TIER_STORE)
+	viper.BindEnv("tier.path", TIER_PATH)
+	viper.BindEnv("tier.s3.endpoint", TIER_S3_ENDPOINT)
+	viper.BindEnv("tier.s3.region", TIER_S3_REGION)
+	viper.BindEnv("tier.s3.bucket", TIER_S3_BUCKET)
+	viper.BindEnv("tier.s3.prefix", TIER_S3_PREFIX)
+	viper.BindEnv("tier.s3.accessKey", TIER_S3_ACCESS_KEY)
+	viper.BindEnv("tier.s3.secretKey", TIER_S3_SECRET_KEY)
+
+	str := viper.GetString("tier.store")
+	if str == "" {
+		return nil, "", fmt.Errorf("no cold tier blockstore configured")
+	}
+	t, err := NewType(str)
+	if err != nil {
+		return nil, "", err
+	}
+	if t == Postgres {
+		return nil, "", fmt.Errorf("the cold tier blockstore cannot be postgres")
+	}
+	store, err := newBlockstore(t, "tier")
+	return store, t, err
+}
+
+// newBlockstore builds a blockstore of the type, configured from the section of the config
+func newBlockstore(t Type, section string) (shared.Blockstore, error) {
+	switch t {
+	case Postgres:
+		return shared.PostgresBlockstore{}, nil
+	case Filesystem:
+		return NewFilesystemBlockstore(viper.GetString(section + ".path"))
+	case S3:
+		return NewS3Blockstore(S3Config{
+			Endpoint:  viper.GetString(section + ".s3.endpoint"),
+			Region:    viper.GetString(section + ".s3.region"),
+			Bucket:    viper.GetString(section + ".s3.bucket"),
+			Prefix:    viper.GetString(section + ".s3.prefix"),
+			AccessKey: viper.GetString(section + ".s3.accessKey"),
+			SecretKey: viper.GetString(section + ".s3.secretKey"),
+		})
+	default:
+		return nil, fmt.Errorf("unrecognized blockstore type: %s", t)
+	}
+}
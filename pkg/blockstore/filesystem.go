@@ -0,0 +1,145 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockstore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+const blockFileExtension = ".data"
+
+// FilesystemBlockstore is a shared.Blockstore that keeps every block in a file of its own in a directory
+// Like flatfs, the files are sharded into subdirectories by the next-to-last two characters of their key
+type FilesystemBlockstore struct {
+	dir string
+}
+
+// NewFilesystemBlockstore creates a FilesystemBlockstore in the directory, creating the directory if it doesn't exist
+func NewFilesystemBlockstore(dir string) (*FilesystemBlockstore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("filesystem blockstore requires a directory")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FilesystemBlockstore{
+		dir: dir,
+	}, nil
+}
+
+// keyName returns the name of the block under the mhkey, which is the key without its blockstore prefix
+func keyName(mhKey string) (string, error) {
+	name := strings.TrimPrefix(mhKey, blockPrefix)
+	if len(name) < 3 || strings.ContainsAny(name, `/\.`) {
+		return "", fmt.Errorf("invalid block key %s", mhKey)
+	}
+	return name, nil
+}
+
+func (fs *FilesystemBlockstore) path(mhKey string) (string, error) {
+	name, err := keyName(mhKey)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(fs.dir, name[len(name)-3:len(name)-1], name+blockFileExtension), nil
+}
+
+// Put satisfies the shared.Blockstore interface
+// The block is written to a temporary file that is then renamed, so that a block file is never seen half written
+// A block file that already exists is only rewritten if its content doesn't hash to its key, i.e. if it is corrupt
+func (fs *FilesystemBlockstore) Put(tx *sqlx.Tx, mhKey string, data []byte) error {
+	path, err := fs.path(mhKey)
+	if err != nil {
+		return err
+	}
+	if existing, err := ioutil.ReadFile(path); err == nil {
+		intact, err := shared.DataMatchesMhKey(mhKey, existing)
+		if err != nil || intact {
+			return err
+		}
+		logrus.Warnf("filesystem blockstore replacing corrupt block %s", mhKey)
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(dir, filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// Get satisfies the shared.Blockstore interface
+func (fs *FilesystemBlockstore) Get(tx *sqlx.Tx, mhKey string) ([]byte, error) {
+	path, err := fs.path(mhKey)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, shared.ErrBlockNotFound
+	}
+	return data, err
+}
+
+// Has satisfies the shared.Blockstore interface
+func (fs *FilesystemBlockstore) Has(tx *sqlx.Tx, mhKey string) (bool, error) {
+	path, err := fs.path(mhKey)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Delete satisfies the shared.Blockstore interface
+func (fs *FilesystemBlockstore) Delete(tx *sqlx.Tx, mhKey string) error {
+	path, err := fs.path(mhKey)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
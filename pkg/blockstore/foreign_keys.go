@@ -0,0 +1,153 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockstore
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// blocksForeignKeys are the foreign keys from the mhkey columns of the index to public.blocks
+var blocksForeignKeys = []struct {
+	table  string
+	name   string
+	column string
+}{
+	{"eth.header_cids", "header_cids_mh_key_fkey", "mh_key"},
+	{"eth.uncle_cids", "uncle_cids_mh_key_fkey", "mh_key"},
+	{"eth.transaction_cids", "transaction_cids_mh_key_fkey", "mh_key"},
+	{"eth.receipt_cids", "receipt_cids_mh_key_fkey", "mh_key"},
+	{"eth.log_cids", "log_cids_mh_key_fkey", "mh_key"},
+	{"eth.state_cids", "state_cids_mh_key_fkey", "mh_key"},
+	{"eth.storage_cids", "storage_cids_mh_key_fkey", "mh_key"},
+	{"btc.header_cids", "header_cids_mh_key_fkey", "mh_key"},
+	{"btc.transaction_cids", "transaction_cids_mh_key_fkey", "mh_key"},
+	{"btc.transaction_cids", "transaction_cids_witness_mh_key_fkey", "witness_mh_key"},
+}
+
+// existsPgStr checks whether a foreign key exists on a table
+const existsPgStr = `SELECT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = $1 AND conrelid = $2::REGCLASS)`
+
+// AddForeignKeys adds the foreign keys from the mhkeys of the index to public.blocks
+// It refuses to while public.block_locations records blocks moved out of public.blocks, and drops the keys again if
+// any indexed row references a block that isn't in public.blocks
+// Unlike the foreign keys dropped by migration 00025, deleting a referenced block fails instead of cascading to the index
+func AddForeignKeys(db *postgres.DB) error {
+	if err := addForeignKeys(db); err != nil {
+		return err
+	}
+	// the keys were added without checking the rows indexed while they were dropped, check them now without
+	// holding the locks that block writes to the index
+	for _, fk := range blocksForeignKeys {
+		logrus.Infof("validating foreign key %s of %s", fk.name, fk.table)
+		if _, err := db.Exec(fmt.Sprintf(`ALTER TABLE %s VALIDATE CONSTRAINT %s`, fk.table, fk.name)); err != nil {
+			if dropErr := DropForeignKeys(db); dropErr != nil {
+				logrus.Errorf("error dropping the foreign keys to public.blocks: %v", dropErr)
+			}
+			return fmt.Errorf("%s references blocks that are not kept in public.blocks: %v", fk.table, err)
+		}
+	}
+	return nil
+}
+
+// addForeignKeys adds the missing foreign keys to public.blocks, without validating them
+func addForeignKeys(db *postgres.DB) (err error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	// keep a concurrent tier command from recording blocks it moves until the keys are in place
+	if _, err = tx.Exec(`LOCK TABLE public.block_locations IN SHARE MODE`); err != nil {
+		return err
+	}
+	var moved int64
+	if err = tx.Get(&moved, `SELECT COUNT(*) FROM public.block_locations`); err != nil {
+		return err
+	}
+	if moved > 0 {
+		return fmt.Errorf("%d blocks have been moved out of public.blocks, the foreign keys to it can't be added", moved)
+	}
+	for _, fk := range blocksForeignKeys {
+		var exists bool
+		if err = tx.Get(&exists, existsPgStr, fk.name, fk.table); err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		logrus.Infof("adding foreign key %s to %s", fk.name, fk.table)
+		_, err = tx.Exec(fmt.Sprintf(`ALTER TABLE %s ADD CONSTRAINT %s FOREIGN KEY (%s)
+			REFERENCES public.blocks (key) DEFERRABLE INITIALLY DEFERRED NOT VALID`, fk.table, fk.name, fk.column))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DropForeignKeys drops the foreign keys from the mhkeys of the index to public.blocks
+func DropForeignKeys(db *postgres.DB) (err error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	for _, fk := range blocksForeignKeys {
+		logrus.Infof("dropping foreign key %s from %s", fk.name, fk.table)
+		if _, err = tx.Exec(fmt.Sprintf(`ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s`, fk.table, fk.name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HasForeignKeys returns whether any of the foreign keys from the mhkeys of the index to public.blocks exist
+func HasForeignKeys(db *postgres.DB) (bool, error) {
+	for _, fk := range blocksForeignKeys {
+		var exists bool
+		if err := db.Get(&exists, existsPgStr, fk.name, fk.table); err != nil {
+			return false, err
+		}
+		if exists {
+			return true, nil
+		}
+	}
+	return false, nil
+}
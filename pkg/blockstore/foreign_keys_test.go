@@ -0,0 +1,96 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockstore_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/blockstore"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+var _ = Describe("ForeignKeys", func() {
+	var db *postgres.DB
+	foreignKeys := func() int {
+		var count int
+		err := db.Get(&count, `SELECT COUNT(*) FROM pg_constraint WHERE conname LIKE '%mh_key_fkey' AND confrelid = 'public.blocks'::REGCLASS`)
+		Expect(err).ToNot(HaveOccurred())
+		return count
+	}
+	BeforeEach(func() {
+		var err error
+		db, err = shared.SetupDB()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = eth.NewIPLDPublisherAndIndexer(db).Publish(mocks.MockConvertedPayload)
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		// the other tests keep blocks outside of public.blocks
+		Expect(blockstore.DropForeignKeys(db)).To(Succeed())
+		eth.TearDownDB(db)
+	})
+
+	It("Adds the foreign keys while every indexed block is kept in public.blocks", func() {
+		Expect(blockstore.AddForeignKeys(db)).To(Succeed())
+		Expect(foreignKeys()).To(Equal(10))
+		// adding them again is a no-op
+		Expect(blockstore.AddForeignKeys(db)).To(Succeed())
+		Expect(foreignKeys()).To(Equal(10))
+		has, err := blockstore.HasForeignKeys(db)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(has).To(BeTrue())
+
+		Expect(blockstore.DropForeignKeys(db)).To(Succeed())
+		Expect(foreignKeys()).To(Equal(0))
+		has, err = blockstore.HasForeignKeys(db)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(has).To(BeFalse())
+	})
+
+	It("Fails to delete referenced blocks instead of deleting the rows that reference them", func() {
+		Expect(blockstore.AddForeignKeys(db)).To(Succeed())
+		_, err := db.Exec(`DELETE FROM public.blocks WHERE key = $1`, mocks.HeaderMhKey)
+		Expect(err).To(HaveOccurred())
+		var count int
+		Expect(db.Get(&count, `SELECT COUNT(*) FROM eth.header_cids WHERE mh_key = $1`, mocks.HeaderMhKey)).To(Succeed())
+		Expect(count).To(Equal(1))
+		Expect(db.Get(&count, `SELECT COUNT(*) FROM public.blocks WHERE key = $1`, mocks.HeaderMhKey)).To(Succeed())
+		Expect(count).To(Equal(1))
+	})
+
+	It("Refuses to add the foreign keys while blocks are recorded to have been moved out of public.blocks", func() {
+		_, err := db.Exec(`INSERT INTO public.block_locations (key, store) VALUES ($1, 'filesystem')`, mocks.State1MhKey)
+		Expect(err).ToNot(HaveOccurred())
+		err = blockstore.AddForeignKeys(db)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("moved out of public.blocks"))
+		Expect(foreignKeys()).To(Equal(0))
+	})
+
+	It("Drops the foreign keys again if indexed rows reference blocks missing from public.blocks", func() {
+		_, err := db.Exec(`DELETE FROM public.blocks WHERE key = $1`, mocks.State1MhKey)
+		Expect(err).ToNot(HaveOccurred())
+		err = blockstore.AddForeignKeys(db)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("eth.state_cids references blocks that are not kept in public.blocks"))
+		Expect(foreignKeys()).To(Equal(0))
+	})
+})
@@ -0,0 +1,83 @@
+package mocks
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// S3Server is a stand-in for an S3-compatible object store, serving path-style object requests from memory
+// If it is given an access key, it rejects requests that are not signed with it
+type S3Server struct {
+	*httptest.Server
+	accessKey string
+	lock      sync.Mutex
+	objects   map[string][]byte
+}
+
+// NewS3Server starts a new S3Server, which has to be closed by the caller
+func NewS3Server(accessKey string) *S3Server {
+	s := &S3Server{
+		accessKey: accessKey,
+		objects:   make(map[string][]byte),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Object returns the object at the path, which is of the form /<bucket>/<key>
+func (s *S3Server) Object(path string) ([]byte, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	data, ok := s.objects[path]
+	return data, ok
+}
+
+// Len returns the number of objects held by the server
+func (s *S3Server) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.objects)
+}
+
+func (s *S3Server) handle(w http.ResponseWriter, r *http.Request) {
+	if s.accessKey != "" {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential="+s.accessKey+"/") || r.Header.Get("X-Amz-Date") == "" ||
+			r.Header.Get("X-Amz-Content-Sha256") == "" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}
+	if strings.Count(r.URL.Path, "/") < 2 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		s.objects[r.URL.Path] = data
+	case http.MethodGet, http.MethodHead:
+		data, ok := s.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	case http.MethodDelete:
+		delete(s.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,255 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockstore
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// S3Config holds the parameters needed to reach an S3-compatible object store
+type S3Config struct {
+	Endpoint  string // e.g. "https://s3.us-east-1.amazonaws.com" or "http://127.0.0.1:9000"
+	Region    string
+	Bucket    string
+	Prefix    string // prefix of the object keys the blocks are kept under
+	AccessKey string // requests are not signed if no access key is set
+	SecretKey string
+}
+
+// S3Blockstore is a shared.Blockstore that keeps every block in an object of its own in an S3-compatible object store
+// Objects are addressed path-style, as <endpoint>/<bucket>/<prefix><key>, which every S3-compatible store supports
+type S3Blockstore struct {
+	config   S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewS3Blockstore creates an S3Blockstore with the provided config
+func NewS3Blockstore(config S3Config) (*S3Blockstore, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, fmt.Errorf("s3 blockstore requires an endpoint and a bucket")
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(config.Endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid s3 endpoint %s: %v", config.Endpoint, err)
+	}
+	if endpoint.Scheme != "http" && endpoint.Scheme != "https" {
+		return nil, fmt.Errorf("invalid s3 endpoint %s: unsupported scheme %s", config.Endpoint, endpoint.Scheme)
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	return &S3Blockstore{
+		config:   config,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+func (s3 *S3Blockstore) objectURL(mhKey string) (*url.URL, error) {
+	name, err := keyName(mhKey)
+	if err != nil {
+		return nil, err
+	}
+	u := *s3.endpoint
+	u.Path = u.Path + "/" + s3.config.Bucket + "/" + s3.config.Prefix + name
+	return &u, nil
+}
+
+// do sends a request for the object of the mhkey and returns the response, which the caller has to close
+func (s3 *S3Blockstore) do(method, mhKey string, body []byte) (*http.Response, error) {
+	u, err := s3.objectURL(mhKey)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body == nil {
+		req.Body = nil
+		req.ContentLength = 0
+	}
+	if s3.config.AccessKey != "" {
+		signV4(req, body, s3.config, time.Now())
+	}
+	return s3.client.Do(req)
+}
+
+// responseError turns an unexpected response into an error
+func responseError(method string, res *http.Response) error {
+	body, _ := ioutil.ReadAll(res.Body)
+	return fmt.Errorf("s3 %s %s: %s %s", method, res.Request.URL.Path, res.Status, strings.TrimSpace(string(body)))
+}
+
+// Put satisfies the shared.Blockstore interface
+func (s3 *S3Blockstore) Put(tx *sqlx.Tx, mhKey string, data []byte) error {
+	if data == nil {
+		data = []byte{}
+	}
+	res, err := s3.do(http.MethodPut, mhKey, data)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return responseError(http.MethodPut, res)
+	}
+	return nil
+}
+
+// Get satisfies the shared.Blockstore interface
+func (s3 *S3Blockstore) Get(tx *sqlx.Tx, mhKey string) ([]byte, error) {
+	res, err := s3.do(http.MethodGet, mhKey, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+		return ioutil.ReadAll(res.Body)
+	case http.StatusNotFound:
+		return nil, shared.ErrBlockNotFound
+	default:
+		return nil, responseError(http.MethodGet, res)
+	}
+}
+
+// Has satisfies the shared.Blockstore interface
+func (s3 *S3Blockstore) Has(tx *sqlx.Tx, mhKey string) (bool, error) {
+	res, err := s3.do(http.MethodHead, mhKey, nil)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, responseError(http.MethodHead, res)
+	}
+}
+
+// Delete satisfies the shared.Blockstore interface
+func (s3 *S3Blockstore) Delete(tx *sqlx.Tx, mhKey string) error {
+	res, err := s3.do(http.MethodDelete, mhKey, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNoContent, http.StatusNotFound:
+		return nil
+	default:
+		return responseError(http.MethodDelete, res)
+	}
+}
+
+// signV4 signs the request with AWS signature version 4, signing the host and every header set on the request
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func signV4(req *http.Request, body []byte, config S3Config, t time.Time) {
+	payloadHash := sha256.Sum256(body)
+	amzDate := t.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := amzDate[:8] + "/" + config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+config.SecretKey), amzDate[:8])
+	key = hmacSHA256(key, config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		config.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// uriEncode percent-encodes every byte but the unreserved characters, and slashes unless encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			encoded.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			encoded.WriteByte(c)
+		default:
+			encoded.WriteString(fmt.Sprintf("%%%02X", c))
+		}
+	}
+	return encoded.String()
+}
+
+func canonicalQuery(query url.Values) string {
+	params := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	sort.Strings(params)
+	return strings.Join(params, "&")
+}
@@ -0,0 +1,147 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockstore
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// TieredBlockstore is a shared.Blockstore that reads through a hot and a cold tier
+// Blocks are put into the hot tier and moved to the cold tier later on; the moves are recorded in the
+// public.block_locations table, so that the cold tier is only asked for the blocks that are recorded to be in it, and
+// the copies put into it by moves that failed are still tracked
+type TieredBlockstore struct {
+	Hot      shared.Blockstore
+	Cold     shared.Blockstore
+	ColdType Type
+}
+
+// NewTieredBlockstore creates a TieredBlockstore from its tiers
+func NewTieredBlockstore(hot, cold shared.Blockstore, coldType Type) *TieredBlockstore {
+	return &TieredBlockstore{
+		Hot:      hot,
+		Cold:     cold,
+		ColdType: coldType,
+	}
+}
+
+// location returns the type of blockstore the block was moved to, or an empty string if it hasn't been moved
+// Unless movedOnly is set, the blockstore a move of the block was recorded to, which may hold a copy of it, is returned
+// as well
+func location(tx *sqlx.Tx, mhKey string, movedOnly bool) (Type, error) {
+	var store string
+	err := tx.Get(&store, `SELECT store FROM public.block_locations WHERE key = $1 AND (moved OR NOT $2)`, mhKey, movedOnly)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return Type(store), err
+}
+
+// coldHas returns whether the block was moved to the cold tier, or, unless movedOnly is set, a move of it was recorded
+func (ts *TieredBlockstore) coldHas(tx *sqlx.Tx, mhKey string, movedOnly bool) (bool, error) {
+	store, err := location(tx, mhKey, movedOnly)
+	if err != nil || store == "" {
+		return false, err
+	}
+	if store != ts.ColdType {
+		return false, fmt.Errorf("block %s was moved to a %s blockstore but the cold tier is a %s blockstore", mhKey, store, ts.ColdType)
+	}
+	return true, nil
+}
+
+// Put satisfies the shared.Blockstore interface
+// Blocks are always put into the hot tier
+func (ts *TieredBlockstore) Put(tx *sqlx.Tx, mhKey string, data []byte) error {
+	return ts.Hot.Put(tx, mhKey, data)
+}
+
+// Get satisfies the shared.Blockstore interface
+func (ts *TieredBlockstore) Get(tx *sqlx.Tx, mhKey string) ([]byte, error) {
+	data, err := ts.Hot.Get(tx, mhKey)
+	if err != shared.ErrBlockNotFound {
+		return data, err
+	}
+	cold, err := ts.coldHas(tx, mhKey, true)
+	if err != nil {
+		return nil, err
+	}
+	if !cold {
+		return nil, shared.ErrBlockNotFound
+	}
+	return ts.Cold.Get(tx, mhKey)
+}
+
+// Has satisfies the shared.Blockstore interface
+func (ts *TieredBlockstore) Has(tx *sqlx.Tx, mhKey string) (bool, error) {
+	has, err := ts.Hot.Has(tx, mhKey)
+	if err != nil || has {
+		return has, err
+	}
+	return ts.coldHas(tx, mhKey, true)
+}
+
+// Delete satisfies the shared.Blockstore interface
+// The block is deleted from both tiers, including a copy left in the cold tier by a move that failed
+func (ts *TieredBlockstore) Delete(tx *sqlx.Tx, mhKey string) error {
+	if err := ts.Hot.Delete(tx, mhKey); err != nil {
+		return err
+	}
+	cold, err := ts.coldHas(tx, mhKey, false)
+	if err != nil || !cold {
+		return err
+	}
+	if err := ts.Cold.Delete(tx, mhKey); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`DELETE FROM public.block_locations WHERE key = $1`, mhKey)
+	return err
+}
+
+// RecordMoves records that the blocks are about to be moved to the cold tier
+// The tx has to be committed before the blocks are moved, so that the copies put into the cold tier stay tracked if
+// the tx moving them is rolled back; the blocks are read from the hot tier until they are moved, and moving them again
+// picks up where a failed move left off
+func (ts *TieredBlockstore) RecordMoves(tx *sqlx.Tx, mhKeys []string) error {
+	_, err := tx.Exec(`INSERT INTO public.block_locations (key, store, moved) SELECT unnest($1::TEXT[]), $2, false
+			ON CONFLICT (key) DO UPDATE SET (store, moved) = ($2, false)`, pq.Array(mhKeys), string(ts.ColdType))
+	return err
+}
+
+// MoveToCold moves the block from the hot tier to the cold tier, its move has to have been recorded with RecordMoves
+func (ts *TieredBlockstore) MoveToCold(tx *sqlx.Tx, mhKey string, data []byte) error {
+	res, err := tx.Exec(`UPDATE public.block_locations SET moved = true WHERE key = $1 AND store = $2`, mhKey, string(ts.ColdType))
+	if err != nil {
+		return err
+	}
+	recorded, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if recorded == 0 {
+		return fmt.Errorf("the move of block %s to the %s cold tier has not been recorded", mhKey, ts.ColdType)
+	}
+	if err := ts.Cold.Put(tx, mhKey, data); err != nil {
+		return err
+	}
+	return ts.Hot.Delete(tx, mhKey)
+}
@@ -0,0 +1,137 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package blockstore_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/jmoiron/sqlx"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/blockstore"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+var _ = Describe("TieredBlockstore", func() {
+	var (
+		db     *postgres.DB
+		tx     *sqlx.Tx
+		dir    string
+		cold   *blockstore.FilesystemBlockstore
+		tiered *blockstore.TieredBlockstore
+	)
+	BeforeEach(func() {
+		var err error
+		db, err = shared.SetupDB()
+		Expect(err).ToNot(HaveOccurred())
+		tx, err = db.Beginx()
+		Expect(err).ToNot(HaveOccurred())
+		dir, err = ioutil.TempDir("", "tiered_blockstore")
+		Expect(err).ToNot(HaveOccurred())
+		cold, err = blockstore.NewFilesystemBlockstore(dir)
+		Expect(err).ToNot(HaveOccurred())
+		tiered = blockstore.NewTieredBlockstore(shared.PostgresBlockstore{}, cold, blockstore.Filesystem)
+	})
+	AfterEach(func() {
+		shared.Rollback(tx)
+		eth.TearDownDB(db)
+		os.RemoveAll(dir)
+	})
+
+	It("Puts blocks into the hot tier and reads them through either tier", func() {
+		Expect(tiered.Put(tx, mockKey1, mockData1)).To(Succeed())
+		Expect(tiered.Put(tx, mockKey2, mockData2)).To(Succeed())
+		has, err := cold.Has(tx, mockKey1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(has).To(BeFalse())
+
+		Expect(tiered.RecordMoves(tx, []string{mockKey1})).To(Succeed())
+		Expect(tiered.MoveToCold(tx, mockKey1, mockData1)).To(Succeed())
+		has, err = shared.PostgresBlockstore{}.Has(tx, mockKey1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(has).To(BeFalse())
+		for key, data := range map[string][]byte{mockKey1: mockData1, mockKey2: mockData2} {
+			has, err = tiered.Has(tx, key)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(has).To(BeTrue())
+			block, err := tiered.Get(tx, key)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(block).To(Equal(data))
+		}
+	})
+
+	It("Only asks the cold tier for the blocks recorded to be in it", func() {
+		Expect(cold.Put(tx, mockKey1, mockData1)).To(Succeed())
+		_, err := tiered.Get(tx, mockKey1)
+		Expect(err).To(Equal(shared.ErrBlockNotFound))
+	})
+
+	It("Deletes blocks from both tiers", func() {
+		Expect(tiered.Put(tx, mockKey1, mockData1)).To(Succeed())
+		Expect(tiered.RecordMoves(tx, []string{mockKey1})).To(Succeed())
+		Expect(tiered.MoveToCold(tx, mockKey1, mockData1)).To(Succeed())
+		Expect(tiered.Delete(tx, mockKey1)).To(Succeed())
+		has, err := tiered.Has(tx, mockKey1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(has).To(BeFalse())
+		has, err = cold.Has(tx, mockKey1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(has).To(BeFalse())
+	})
+	It("Only moves blocks whose moves have been recorded", func() {
+		Expect(tiered.Put(tx, mockKey1, mockData1)).To(Succeed())
+		Expect(tiered.MoveToCold(tx, mockKey1, mockData1)).ToNot(Succeed())
+	})
+
+	It("Keeps track of the cold copies of moves that are rolled back", func() {
+		// the move is recorded in a tx of its own, which is committed before the block is moved
+		Expect(tiered.Put(tx, mockKey1, mockData1)).To(Succeed())
+		Expect(tiered.RecordMoves(tx, []string{mockKey1})).To(Succeed())
+		Expect(tx.Commit()).To(Succeed())
+
+		moveTx, err := db.Beginx()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tiered.MoveToCold(moveTx, mockKey1, mockData1)).To(Succeed())
+		Expect(moveTx.Rollback()).To(Succeed())
+
+		// the block is still read from the hot tier, and the copy left in the cold tier is recorded
+		tx, err = db.Beginx()
+		Expect(err).ToNot(HaveOccurred())
+		has, err := cold.Has(tx, mockKey1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(has).To(BeTrue())
+		var moved bool
+		Expect(tx.Get(&moved, `SELECT moved FROM public.block_locations WHERE key = $1`, mockKey1)).To(Succeed())
+		Expect(moved).To(BeFalse())
+		block, err := tiered.Get(tx, mockKey1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(block).To(Equal(mockData1))
+
+		// deleting the block deletes the copy along with the record
+		Expect(tiered.Delete(tx, mockKey1)).To(Succeed())
+		has, err = cold.Has(tx, mockKey1)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(has).To(BeFalse())
+		var recorded bool
+		Expect(tx.Get(&recorded, `SELECT EXISTS (SELECT 1 FROM public.block_locations WHERE key = $1)`, mockKey1)).To(Succeed())
+		Expect(recorded).To(BeFalse())
+	})
+})
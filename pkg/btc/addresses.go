@@ -0,0 +1,151 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcutil"
+)
+
+// AddressArgs are the arguments to the address index endpoints, in the same shape as bitcore's address index
+// Start and End bound the block range of history queries, a bound of 0 is ignored
+type AddressArgs struct {
+	Addresses []string `json:"addresses"`
+	Start     int64    `json:"start"`
+	End       int64    `json:"end"`
+}
+
+// AddressBalance is the getaddressbalance result, in satoshis
+type AddressBalance struct {
+	Balance  int64 `json:"balance"`
+	Received int64 `json:"received"`
+}
+
+// AddressUTXO is an unspent output in the getaddressutxos result
+type AddressUTXO struct {
+	Address     string `json:"address"`
+	TxID        string `json:"txid"`
+	OutputIndex int64  `json:"outputIndex"`
+	Script      string `json:"script"`
+	Satoshis    int64  `json:"satoshis"`
+	Height      int64  `json:"height"`
+}
+
+// AddressDelta is a change in an address' balance in the getaddressdeltas result
+// Index is the index of the output for credits and of the input for debits
+type AddressDelta struct {
+	Satoshis   int64  `json:"satoshis"`
+	TxID       string `json:"txid"`
+	Index      int64  `json:"index"`
+	BlockIndex int64  `json:"blockindex"`
+	Height     int64  `json:"height"`
+	Address    string `json:"address"`
+}
+
+// GetAddressBalance returns the current balance of, and the total amount received by, the given addresses
+func (pba *PublicBtcAPI) GetAddressBalance(args AddressArgs) (*AddressBalance, error) {
+	if err := pba.validateAddresses(args.Addresses); err != nil {
+		return nil, err
+	}
+	received, spent, err := pba.B.Retriever.RetrieveAddressBalance(args.Addresses)
+	if err != nil {
+		return nil, err
+	}
+	return &AddressBalance{
+		Balance:  received - spent,
+		Received: received,
+	}, nil
+}
+
+// GetAddressUtxos returns the unspent outputs paying to the given addresses
+func (pba *PublicBtcAPI) GetAddressUtxos(args AddressArgs) ([]AddressUTXO, error) {
+	if err := pba.validateAddresses(args.Addresses); err != nil {
+		return nil, err
+	}
+	outputs, err := pba.B.Retriever.RetrieveAddressUTXOs(args.Addresses)
+	if err != nil {
+		return nil, err
+	}
+	utxos := make([]AddressUTXO, len(outputs))
+	for i, output := range outputs {
+		utxos[i] = AddressUTXO{
+			Address:     output.Address,
+			TxID:        output.TxHash,
+			OutputIndex: output.Index,
+			Script:      hex.EncodeToString(output.PkScript),
+			Satoshis:    output.Value,
+			Height:      output.BlockNumber,
+		}
+	}
+	return utxos, nil
+}
+
+// GetAddressDeltas returns every change in the balance of the given addresses over the requested block range
+func (pba *PublicBtcAPI) GetAddressDeltas(args AddressArgs) ([]AddressDelta, error) {
+	if err := pba.validateAddresses(args.Addresses); err != nil {
+		return nil, err
+	}
+	deltaModels, err := pba.B.Retriever.RetrieveAddressDeltas(args.Addresses, args.Start, args.End)
+	if err != nil {
+		return nil, err
+	}
+	deltas := make([]AddressDelta, len(deltaModels))
+	for i, delta := range deltaModels {
+		deltas[i] = AddressDelta{
+			Satoshis:   delta.Value,
+			TxID:       delta.TxHash,
+			Index:      delta.Index,
+			BlockIndex: delta.TxIndex,
+			Height:     delta.BlockNumber,
+			Address:    delta.Address,
+		}
+	}
+	return deltas, nil
+}
+
+// GetAddressTxids returns the ids of the transactions that paid to or spent from the given addresses over the requested block range
+func (pba *PublicBtcAPI) GetAddressTxids(args AddressArgs) ([]string, error) {
+	deltas, err := pba.GetAddressDeltas(args)
+	if err != nil {
+		return nil, err
+	}
+	txids := make([]string, 0, len(deltas))
+	seen := make(map[string]bool, len(deltas))
+	for _, delta := range deltas {
+		if !seen[delta.TxID] {
+			seen[delta.TxID] = true
+			txids = append(txids, delta.TxID)
+		}
+	}
+	return txids, nil
+}
+
+// validateAddresses checks that the addresses are valid on the backend's network
+func (pba *PublicBtcAPI) validateAddresses(addresses []string) error {
+	if len(addresses) == 0 {
+		return errors.New("no addresses provided")
+	}
+	for _, address := range addresses {
+		if _, err := btcutil.DecodeAddress(address, pba.B.Params); err != nil {
+			return fmt.Errorf("invalid address %s: %s", address, err.Error())
+		}
+	}
+	return nil
+}
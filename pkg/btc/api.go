@@ -0,0 +1,529 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+)
+
+// APIName is the namespace for the watcher's btc api
+const APIName = "btc"
+
+// APIVersion is the version of the watcher's btc api
+const APIVersion = "0.0.1"
+
+// PublicBtcAPI serves the bitcoind read calls out of the watcher's database
+type PublicBtcAPI struct {
+	B *Backend
+}
+
+// NewPublicBtcAPI creates a new PublicBtcAPI with the provided underlying Backend
+func NewPublicBtcAPI(b *Backend) *PublicBtcAPI {
+	return &PublicBtcAPI{
+		B: b,
+	}
+}
+
+// GetBlockVerboseTxResult is the getblock result at verbosity 2, where the transactions are returned in full
+// It is the same as btcjson.GetBlockVerboseResult except the transactions are under "tx" like they are in bitcoind
+type GetBlockVerboseTxResult struct {
+	Hash          string        `json:"hash"`
+	Confirmations int64         `json:"confirmations"`
+	StrippedSize  int32         `json:"strippedsize"`
+	Size          int32         `json:"size"`
+	Weight        int32         `json:"weight"`
+	Height        int64         `json:"height"`
+	Version       int32         `json:"version"`
+	VersionHex    string        `json:"versionHex"`
+	MerkleRoot    string        `json:"merkleroot"`
+	Tx            []TxRawResult `json:"tx"`
+	Time          int64         `json:"time"`
+	Nonce         uint32        `json:"nonce"`
+	Bits          string        `json:"bits"`
+	Difficulty    float64       `json:"difficulty"`
+	PreviousHash  string        `json:"previousblockhash"`
+	NextHash      string        `json:"nextblockhash,omitempty"`
+}
+
+// TxRawResult is the verbose transaction result
+// It is the same as btcjson.TxRawResult with the addition of the fee, in BTC, and the fee rate, in satoshis per virtual byte,
+// which are omitted for coinbase transactions and for transactions whose spent outputs haven't been indexed
+type TxRawResult struct {
+	btcjson.TxRawResult
+	Fee     *float64 `json:"fee,omitempty"`
+	FeeRate *float64 `json:"feerate,omitempty"`
+}
+
+// GetBlockStatsResult is the getblockstats result, all amounts are in satoshis
+// The fee statistics are only available once the outputs spent by all of the block's transactions have been indexed
+// CoinbaseValue is not part of bitcoind's result, it is the total value claimed by the block's coinbase transaction
+type GetBlockStatsResult struct {
+	AverageFee     int64  `json:"avgfee"`
+	AverageFeeRate int64  `json:"avgfeerate"`
+	BlockHash      string `json:"blockhash"`
+	CoinbaseValue  int64  `json:"coinbasevalue"`
+	Height         int64  `json:"height"`
+	Ins            int64  `json:"ins"`
+	MaxFee         int64  `json:"maxfee"`
+	MaxFeeRate     int64  `json:"maxfeerate"`
+	MinFee         int64  `json:"minfee"`
+	MinFeeRate     int64  `json:"minfeerate"`
+	Outs           int64  `json:"outs"`
+	Subsidy        int64  `json:"subsidy"`
+	Time           int64  `json:"time"`
+	TotalOut       int64  `json:"total_out"`
+	TotalWeight    int64  `json:"total_weight"`
+	TotalFee       int64  `json:"totalfee"`
+	Txs            int64  `json:"txs"`
+}
+
+// GetBlockCount returns the height of the most recent block we have indexed
+func (pba *PublicBtcAPI) GetBlockCount() (int64, error) {
+	return pba.B.Retriever.RetrieveLastBlockNumber()
+}
+
+// GetBestBlockHash returns the hash of the most recent block we have indexed
+func (pba *PublicBtcAPI) GetBestBlockHash() (string, error) {
+	number, err := pba.B.Retriever.RetrieveLastBlockNumber()
+	if err != nil {
+		return "", err
+	}
+	return pba.GetBlockHash(number)
+}
+
+// GetBlockHash returns the hash of the block at the given height
+func (pba *PublicBtcAPI) GetBlockHash(height int64) (string, error) {
+	hash, err := pba.B.BlockHashByNumber(height)
+	if err != nil {
+		return "", err
+	}
+	return hash.String(), nil
+}
+
+// GetBlock returns the block with the given hash
+// At verbosity 0 it returns the hex encoded block, at verbosity 1 (the default) the block with its transaction ids,
+// and at verbosity 2 the block with its decoded transactions
+func (pba *PublicBtcAPI) GetBlock(blockHash string, verbosity *int) (interface{}, error) {
+	hash, err := chainhash.NewHashFromStr(blockHash)
+	if err != nil {
+		return nil, err
+	}
+	block, height, err := pba.B.BlockByHash(*hash)
+	if err != nil {
+		return nil, err
+	}
+	level := 1
+	if verbosity != nil {
+		level = *verbosity
+	}
+	switch level {
+	case 0:
+		return messageToHex(block)
+	case 1:
+		confirmations, nextHash, err := pba.chainPosition(*hash, height)
+		if err != nil {
+			return nil, err
+		}
+		res := blockVerboseResult(block, height, confirmations, nextHash, pba.B.Params)
+		res.Tx = make([]string, len(block.Transactions))
+		for i, tx := range block.Transactions {
+			res.Tx[i] = tx.TxHash().String()
+		}
+		return res, nil
+	case 2:
+		confirmations, nextHash, err := pba.chainPosition(*hash, height)
+		if err != nil {
+			return nil, err
+		}
+		_, txCIDs, err := pba.B.Retriever.RetrieveBlockByHash(*hash)
+		if err != nil {
+			return nil, err
+		}
+		if len(txCIDs) != len(block.Transactions) {
+			return nil, fmt.Errorf("%d of the %d transactions of block %s are indexed", len(txCIDs), len(block.Transactions), hash.String())
+		}
+		res := blockVerboseResult(block, height, confirmations, nextHash, pba.B.Params)
+		txs := make([]TxRawResult, len(block.Transactions))
+		for i, tx := range block.Transactions {
+			txRes, err := createTxRawResult(tx, pba.B.Params)
+			if err != nil {
+				return nil, err
+			}
+			txs[i] = withFee(*txRes, txCIDs[i])
+		}
+		return GetBlockVerboseTxResult{
+			Hash:          res.Hash,
+			Confirmations: res.Confirmations,
+			StrippedSize:  res.StrippedSize,
+			Size:          res.Size,
+			Weight:        res.Weight,
+			Height:        res.Height,
+			Version:       res.Version,
+			VersionHex:    res.VersionHex,
+			MerkleRoot:    res.MerkleRoot,
+			Tx:            txs,
+			Time:          res.Time,
+			Nonce:         res.Nonce,
+			Bits:          res.Bits,
+			Difficulty:    res.Difficulty,
+			PreviousHash:  res.PreviousHash,
+			NextHash:      res.NextHash,
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid verbosity %d, expected 0, 1 or 2", level)
+	}
+}
+
+// GetBlockHeader returns the header with the given hash
+// If verbose is false it returns the hex encoded header, otherwise (the default) the decoded header
+func (pba *PublicBtcAPI) GetBlockHeader(blockHash string, verbose *bool) (interface{}, error) {
+	hash, err := chainhash.NewHashFromStr(blockHash)
+	if err != nil {
+		return nil, err
+	}
+	header, height, err := pba.B.HeaderByHash(*hash)
+	if err != nil {
+		return nil, err
+	}
+	if verbose != nil && !*verbose {
+		var buf bytes.Buffer
+		if err := header.Serialize(&buf); err != nil {
+			return nil, err
+		}
+		return hex.EncodeToString(buf.Bytes()), nil
+	}
+	confirmations, nextHash, err := pba.chainPosition(*hash, height)
+	if err != nil {
+		return nil, err
+	}
+	return btcjson.GetBlockHeaderVerboseResult{
+		Hash:          header.BlockHash().String(),
+		Confirmations: confirmations,
+		Height:        int32(height),
+		Version:       header.Version,
+		VersionHex:    fmt.Sprintf("%08x", header.Version),
+		MerkleRoot:    header.MerkleRoot.String(),
+		Time:          header.Timestamp.Unix(),
+		Nonce:         uint64(header.Nonce),
+		Bits:          fmt.Sprintf("%08x", header.Bits),
+		Difficulty:    getDifficultyRatio(header.Bits, pba.B.Params),
+		PreviousHash:  header.PrevBlock.String(),
+		NextHash:      nextHash,
+	}, nil
+}
+
+// GetRawTransaction returns the transaction with the given id
+// If verbose is true it returns the decoded transaction along with the block it was included in and its fee,
+// otherwise (the default) the hex encoded transaction
+func (pba *PublicBtcAPI) GetRawTransaction(txid string, verbose *bool) (interface{}, error) {
+	hash, err := chainhash.NewHashFromStr(txid)
+	if err != nil {
+		return nil, err
+	}
+	tx, txCID, headerCID, err := pba.B.TransactionByHash(*hash)
+	if err != nil {
+		return nil, err
+	}
+	if verbose == nil || !*verbose {
+		return messageToHex(tx)
+	}
+	res, err := createTxRawResult(tx, pba.B.Params)
+	if err != nil {
+		return nil, err
+	}
+	blockHash, err := chainhash.NewHashFromStr(headerCID.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+	header, height, err := pba.B.HeaderByHash(*blockHash)
+	if err != nil {
+		return nil, err
+	}
+	confirmations, _, err := pba.chainPosition(*blockHash, height)
+	if err != nil {
+		return nil, err
+	}
+	res.BlockHash = headerCID.BlockHash
+	// like bitcoind, a transaction in a block that is not on the best chain has no confirmations
+	if confirmations > 0 {
+		res.Confirmations = uint64(confirmations)
+	}
+	res.Time = header.Timestamp.Unix()
+	res.Blocktime = header.Timestamp.Unix()
+	return withFee(*res, txCID), nil
+}
+
+// GetBlockStats returns the statistics of the block with the given hash or at the given height
+func (pba *PublicBtcAPI) GetBlockStats(hashOrHeight interface{}) (*GetBlockStatsResult, error) {
+	var hash *chainhash.Hash
+	var err error
+	switch id := hashOrHeight.(type) {
+	case string:
+		hash, err = chainhash.NewHashFromStr(id)
+	case float64:
+		hash, err = pba.B.BlockHashByNumber(int64(id))
+	default:
+		err = fmt.Errorf("expected a block hash or height, got %v", hashOrHeight)
+	}
+	if err != nil {
+		return nil, err
+	}
+	block, height, err := pba.B.BlockByHash(*hash)
+	if err != nil {
+		return nil, err
+	}
+	headerCID, txCIDs, err := pba.B.Retriever.RetrieveBlockByHash(*hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(txCIDs) != len(block.Transactions) {
+		return nil, fmt.Errorf("%d of the %d transactions of block %s are indexed", len(txCIDs), len(block.Transactions), hash.String())
+	}
+	if headerCID.Fees == nil {
+		return nil, fmt.Errorf("the fees of block %s are not known until the outputs its transactions spend are indexed", hash.String())
+	}
+	stats := &GetBlockStatsResult{
+		BlockHash:     hash.String(),
+		CoinbaseValue: headerCID.CoinbaseValue,
+		Height:        height,
+		Subsidy:       blockchain.CalcBlockSubsidy(int32(height), pba.B.Params),
+		Time:          block.Header.Timestamp.Unix(),
+		TotalFee:      *headerCID.Fees,
+		Txs:           int64(len(block.Transactions)),
+	}
+	// the subsidy of blocks indexed before it was is computed from the configured network's halving schedule
+	if headerCID.Subsidy != nil {
+		stats.Subsidy = *headerCID.Subsidy
+	}
+	var totalVSize int64
+	for i, tx := range block.Transactions {
+		weight := blockchain.GetTransactionWeight(btcutil.NewTx(tx))
+		stats.TotalWeight += weight
+		// like bitcoind, the coinbase transaction is excluded from the rest of the statistics
+		if i == 0 {
+			continue
+		}
+		stats.Ins += int64(len(tx.TxIn))
+		stats.Outs += int64(len(tx.TxOut))
+		for _, out := range tx.TxOut {
+			stats.TotalOut += out.Value
+		}
+		vsize := (weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor
+		totalVSize += vsize
+		if txCIDs[i].Fee == nil {
+			return nil, fmt.Errorf("the fee of transaction %s is not known", txCIDs[i].TxHash)
+		}
+		fee := *txCIDs[i].Fee
+		feeRate := fee / vsize
+		if i == 1 || fee < stats.MinFee {
+			stats.MinFee = fee
+		}
+		if fee > stats.MaxFee {
+			stats.MaxFee = fee
+		}
+		if i == 1 || feeRate < stats.MinFeeRate {
+			stats.MinFeeRate = feeRate
+		}
+		if feeRate > stats.MaxFeeRate {
+			stats.MaxFeeRate = feeRate
+		}
+	}
+	if len(block.Transactions) > 1 {
+		stats.AverageFee = stats.TotalFee / int64(len(block.Transactions)-1)
+		stats.AverageFeeRate = stats.TotalFee / totalVSize
+	}
+	return stats, nil
+}
+
+// chainPosition returns the number of confirmations for the block with the given hash and height
+// and the hash of the block after it, if we have it
+// A block that is not on the best chain has -1 confirmations
+func (pba *PublicBtcAPI) chainPosition(hash chainhash.Hash, height int64) (int64, string, error) {
+	var orphaned bool
+	pgStr := `SELECT orphaned FROM btc.header_cids WHERE block_hash = $1 LIMIT 1`
+	if err := pba.B.DB.Get(&orphaned, pgStr, hash.String()); err != nil {
+		return 0, "", err
+	}
+	if orphaned {
+		return -1, "", nil
+	}
+	last, err := pba.B.Retriever.RetrieveLastBlockNumber()
+	if err != nil {
+		return 0, "", err
+	}
+	var nextHash string
+	if height < last {
+		pgStr := `SELECT block_hash FROM btc.header_cids WHERE block_number = $1 AND NOT orphaned LIMIT 1`
+		if err := pba.B.DB.Get(&nextHash, pgStr, height+1); err != nil && err != sql.ErrNoRows {
+			return 0, "", err
+		}
+	}
+	return last - height + 1, nextHash, nil
+}
+
+// messageToHex serializes the message and hex encodes it
+func messageToHex(msg wire.Message) (string, error) {
+	var buf bytes.Buffer
+	if err := msg.BtcEncode(&buf, wire.ProtocolVersion, wire.WitnessEncoding); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf.Bytes()), nil
+}
+
+// blockVerboseResult returns the verbose getblock result for the block, without its transactions
+func blockVerboseResult(block *wire.MsgBlock, height, confirmations int64, nextHash string, params *chaincfg.Params) btcjson.GetBlockVerboseResult {
+	header := block.Header
+	return btcjson.GetBlockVerboseResult{
+		Hash:          header.BlockHash().String(),
+		Confirmations: confirmations,
+		StrippedSize:  int32(block.SerializeSizeStripped()),
+		Size:          int32(block.SerializeSize()),
+		Weight:        int32(blockchain.GetBlockWeight(btcutil.NewBlock(block))),
+		Height:        height,
+		Version:       header.Version,
+		VersionHex:    fmt.Sprintf("%08x", header.Version),
+		MerkleRoot:    header.MerkleRoot.String(),
+		Time:          header.Timestamp.Unix(),
+		Nonce:         header.Nonce,
+		Bits:          fmt.Sprintf("%08x", header.Bits),
+		Difficulty:    getDifficultyRatio(header.Bits, params),
+		PreviousHash:  header.PrevBlock.String(),
+		NextHash:      nextHash,
+	}
+}
+
+// createTxRawResult returns the verbose getrawtransaction result for the transaction, without its block information
+func createTxRawResult(tx *wire.MsgTx, params *chaincfg.Params) (*btcjson.TxRawResult, error) {
+	txHex, err := messageToHex(tx)
+	if err != nil {
+		return nil, err
+	}
+	weight := blockchain.GetTransactionWeight(btcutil.NewTx(tx))
+	return &btcjson.TxRawResult{
+		Hex:      txHex,
+		Txid:     tx.TxHash().String(),
+		Hash:     tx.WitnessHash().String(),
+		Size:     int32(tx.SerializeSize()),
+		Vsize:    int32((weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor),
+		Weight:   int32(weight),
+		Version:  tx.Version,
+		LockTime: tx.LockTime,
+		Vin:      createVinList(tx),
+		Vout:     createVoutList(tx, params),
+	}, nil
+}
+
+// withFee adds the fee and fee rate of the transaction to its verbose result, if they are known
+func withFee(res btcjson.TxRawResult, txCID TxModel) TxRawResult {
+	txRes := TxRawResult{TxRawResult: res}
+	if txCID.Fee != nil {
+		fee := btcutil.Amount(*txCID.Fee).ToBTC()
+		txRes.Fee = &fee
+	}
+	txRes.FeeRate = txCID.FeeRate
+	return txRes
+}
+
+// createVinList returns the json objects for the inputs of the transaction
+func createVinList(tx *wire.MsgTx) []btcjson.Vin {
+	vins := make([]btcjson.Vin, len(tx.TxIn))
+	if blockchain.IsCoinBaseTx(tx) {
+		txIn := tx.TxIn[0]
+		vins[0].Coinbase = hex.EncodeToString(txIn.SignatureScript)
+		vins[0].Sequence = txIn.Sequence
+		vins[0].Witness = witnessToHex(txIn.Witness)
+		return vins
+	}
+	for i, txIn := range tx.TxIn {
+		// the disassembled script contains [error] inline if it doesn't fully parse, so the error can be ignored
+		disasm, _ := txscript.DisasmString(txIn.SignatureScript)
+		vins[i].Txid = txIn.PreviousOutPoint.Hash.String()
+		vins[i].Vout = txIn.PreviousOutPoint.Index
+		vins[i].Sequence = txIn.Sequence
+		vins[i].ScriptSig = &btcjson.ScriptSig{
+			Asm: disasm,
+			Hex: hex.EncodeToString(txIn.SignatureScript),
+		}
+		if tx.HasWitness() {
+			vins[i].Witness = witnessToHex(txIn.Witness)
+		}
+	}
+	return vins
+}
+
+// createVoutList returns the json objects for the outputs of the transaction
+func createVoutList(tx *wire.MsgTx, params *chaincfg.Params) []btcjson.Vout {
+	vouts := make([]btcjson.Vout, len(tx.TxOut))
+	for i, txOut := range tx.TxOut {
+		disasm, _ := txscript.DisasmString(txOut.PkScript)
+		// an error means the script couldn't be parsed, in which case there are no addresses to report
+		class, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(txOut.PkScript, params)
+		encodedAddrs := make([]string, len(addrs))
+		for j, addr := range addrs {
+			encodedAddrs[j] = addr.EncodeAddress()
+		}
+		vouts[i] = btcjson.Vout{
+			Value: btcutil.Amount(txOut.Value).ToBTC(),
+			N:     uint32(i),
+			ScriptPubKey: btcjson.ScriptPubKeyResult{
+				Asm:       disasm,
+				Hex:       hex.EncodeToString(txOut.PkScript),
+				ReqSigs:   int32(reqSigs),
+				Type:      class.String(),
+				Addresses: encodedAddrs,
+			},
+		}
+	}
+	return vouts
+}
+
+// witnessToHex hex encodes each item of the witness
+func witnessToHex(witness wire.TxWitness) []string {
+	if len(witness) == 0 {
+		return nil
+	}
+	strs := make([]string, len(witness))
+	for i, item := range witness {
+		strs[i] = hex.EncodeToString(item)
+	}
+	return strs
+}
+
+// getDifficultyRatio returns the proof-of-work difficulty as a multiple of the network's minimum difficulty
+func getDifficultyRatio(bits uint32, params *chaincfg.Params) float64 {
+	max := blockchain.CompactToBig(params.PowLimitBits)
+	target := blockchain.CompactToBig(bits)
+	difficulty := new(big.Rat).SetFrac(max, target)
+	diff, err := strconv.ParseFloat(difficulty.FloatString(8), 64)
+	if err != nil {
+		return 0
+	}
+	return diff
+}
@@ -0,0 +1,330 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc_test
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	"github.com/btcsuite/btcd/btcjson"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+var _ = Describe("API", func() {
+	var (
+		db        *postgres.DB
+		api       *btc.PublicBtcAPI
+		blockHash = mocks.MockBlock.Header.BlockHash().String()
+	)
+	BeforeEach(func() {
+		var err error
+		db, err = shared.SetupDB()
+		Expect(err).ToNot(HaveOccurred())
+		backend, err := btc.NewBtcBackend(db, &chaincfg.MainNetParams)
+		Expect(err).ToNot(HaveOccurred())
+		api = btc.NewPublicBtcAPI(backend)
+		_, err = btc.NewIPLDPublisherAndIndexer(db).Publish(mocks.MockConvertedPayload)
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		btc.TearDownDB(db)
+	})
+
+	Describe("GetBlockCount and GetBestBlockHash", func() {
+		It("Returns the height and hash of the latest block", func() {
+			count, err := api.GetBlockCount()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(count).To(Equal(mocks.MockBlockHeight))
+			hash, err := api.GetBestBlockHash()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hash).To(Equal(blockHash))
+		})
+	})
+
+	Describe("GetBlockHash", func() {
+		It("Returns the hash of the block at the given height", func() {
+			hash, err := api.GetBlockHash(mocks.MockBlockHeight)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(hash).To(Equal(blockHash))
+		})
+		It("Errors for heights we don't have", func() {
+			_, err := api.GetBlockHash(mocks.MockBlockHeight + 1)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("GetBlock", func() {
+		It("Returns the hex encoded block at verbosity 0", func() {
+			verbosity := 0
+			res, err := api.GetBlock(blockHash, &verbosity)
+			Expect(err).ToNot(HaveOccurred())
+			var buf bytes.Buffer
+			Expect(mocks.MockBlock.Serialize(&buf)).To(Succeed())
+			Expect(res).To(Equal(hex.EncodeToString(buf.Bytes())))
+		})
+		It("Returns the block with its transaction ids by default", func() {
+			res, err := api.GetBlock(blockHash, nil)
+			Expect(err).ToNot(HaveOccurred())
+			block, ok := res.(btcjson.GetBlockVerboseResult)
+			Expect(ok).To(BeTrue())
+			Expect(block.Hash).To(Equal(blockHash))
+			Expect(block.Height).To(Equal(mocks.MockBlockHeight))
+			Expect(block.Confirmations).To(Equal(int64(1)))
+			Expect(block.PreviousHash).To(Equal(mocks.MockBlock.Header.PrevBlock.String()))
+			Expect(block.MerkleRoot).To(Equal(mocks.MockBlock.Header.MerkleRoot.String()))
+			Expect(block.Time).To(Equal(mocks.MockBlock.Header.Timestamp.Unix()))
+			Expect(block.Bits).To(Equal("1b04864c"))
+			Expect(block.Difficulty).To(Equal(14484.16236123))
+			Expect(len(block.Tx)).To(Equal(len(mocks.MockBlock.Transactions)))
+			for i, tx := range mocks.MockBlock.Transactions {
+				Expect(block.Tx[i]).To(Equal(tx.TxHash().String()))
+			}
+		})
+		It("Returns the block with its decoded transactions at verbosity 2", func() {
+			verbosity := 2
+			res, err := api.GetBlock(blockHash, &verbosity)
+			Expect(err).ToNot(HaveOccurred())
+			block, ok := res.(btc.GetBlockVerboseTxResult)
+			Expect(ok).To(BeTrue())
+			Expect(len(block.Tx)).To(Equal(len(mocks.MockBlock.Transactions)))
+			Expect(block.Tx[0].Vin[0].IsCoinBase()).To(BeTrue())
+			for i, tx := range mocks.MockBlock.Transactions {
+				Expect(block.Tx[i].Txid).To(Equal(tx.TxHash().String()))
+				Expect(len(block.Tx[i].Vout)).To(Equal(len(tx.TxOut)))
+			}
+		})
+		It("Errors for blocks whose transactions are not all indexed", func() {
+			_, err := db.Exec(`DELETE FROM btc.transaction_cids WHERE tx_hash = $1`, mocks.MockBlock.Transactions[2].TxHash().String())
+			Expect(err).ToNot(HaveOccurred())
+			verbosity := 2
+			_, err = api.GetBlock(blockHash, &verbosity)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("GetBlockHeader", func() {
+		It("Returns the decoded header by default", func() {
+			res, err := api.GetBlockHeader(blockHash, nil)
+			Expect(err).ToNot(HaveOccurred())
+			header, ok := res.(btcjson.GetBlockHeaderVerboseResult)
+			Expect(ok).To(BeTrue())
+			Expect(header.Hash).To(Equal(blockHash))
+			Expect(header.Height).To(Equal(int32(mocks.MockBlockHeight)))
+			Expect(header.Nonce).To(Equal(uint64(mocks.MockBlock.Header.Nonce)))
+		})
+		It("Returns the hex encoded header when not verbose", func() {
+			verbose := false
+			res, err := api.GetBlockHeader(blockHash, &verbose)
+			Expect(err).ToNot(HaveOccurred())
+			var buf bytes.Buffer
+			Expect(mocks.MockBlock.Header.Serialize(&buf)).To(Succeed())
+			Expect(res).To(Equal(hex.EncodeToString(buf.Bytes())))
+		})
+		It("Returns -1 confirmations for headers that are not on the best chain", func() {
+			_, err := btc.NewIPLDPublisherAndIndexer(db).Publish(mocks.MockForkConvertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+			res, err := api.GetBlockHeader(mocks.MockForkBlock.Header.BlockHash().String(), nil)
+			Expect(err).ToNot(HaveOccurred())
+			header, ok := res.(btcjson.GetBlockHeaderVerboseResult)
+			Expect(ok).To(BeTrue())
+			Expect(header.Confirmations).To(Equal(int64(-1)))
+			Expect(header.NextHash).To(BeEmpty())
+		})
+	})
+
+	Describe("GetRawTransaction", func() {
+		It("Returns the hex encoded transaction by default", func() {
+			tx := mocks.MockBlock.Transactions[1]
+			res, err := api.GetRawTransaction(tx.TxHash().String(), nil)
+			Expect(err).ToNot(HaveOccurred())
+			var buf bytes.Buffer
+			Expect(tx.BtcEncode(&buf, wire.ProtocolVersion, wire.WitnessEncoding)).To(Succeed())
+			Expect(res).To(Equal(hex.EncodeToString(buf.Bytes())))
+		})
+		It("Returns the decoded transaction with its block when verbose", func() {
+			verbose := true
+			tx := mocks.MockBlock.Transactions[2]
+			res, err := api.GetRawTransaction(tx.TxHash().String(), &verbose)
+			Expect(err).ToNot(HaveOccurred())
+			rawTx, ok := res.(btc.TxRawResult)
+			Expect(ok).To(BeTrue())
+			Expect(rawTx.Txid).To(Equal(tx.TxHash().String()))
+			Expect(rawTx.BlockHash).To(Equal(blockHash))
+			Expect(rawTx.Confirmations).To(Equal(uint64(1)))
+			Expect(rawTx.Vin[0].Txid).To(Equal(tx.TxIn[0].PreviousOutPoint.Hash.String()))
+			Expect(rawTx.Vout[0].ScriptPubKey.Addresses).To(Equal([]string(mocks.MockTxsMetaData[2].TxOutputs[0].Addresses)))
+			// the outputs it spends aren't indexed
+			Expect(rawTx.Fee).To(BeNil())
+			Expect(rawTx.FeeRate).To(BeNil())
+		})
+		It("Returns no confirmations for transactions in blocks that are not on the best chain", func() {
+			_, err := btc.NewIPLDPublisherAndIndexer(db).Publish(mocks.MockForkConvertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+			verbose := true
+			tx := mocks.MockForkBlock.Transactions[0]
+			res, err := api.GetRawTransaction(tx.TxHash().String(), &verbose)
+			Expect(err).ToNot(HaveOccurred())
+			rawTx, ok := res.(btc.TxRawResult)
+			Expect(ok).To(BeTrue())
+			Expect(rawTx.BlockHash).To(Equal(mocks.MockForkBlock.Header.BlockHash().String()))
+			Expect(rawTx.Confirmations).To(BeZero())
+		})
+	})
+
+	Describe("Fees", func() {
+		BeforeEach(func() {
+			_, err := btc.NewIPLDPublisherAndIndexer(db).Publish(mocks.MockSpendingConvertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Returns the fees of transactions whose spent outputs are indexed", func() {
+			verbose := true
+			tx := mocks.MockSpendingBlock.Transactions[1]
+			res, err := api.GetRawTransaction(tx.TxHash().String(), &verbose)
+			Expect(err).ToNot(HaveOccurred())
+			rawTx, ok := res.(btc.TxRawResult)
+			Expect(ok).To(BeTrue())
+			Expect(*rawTx.Fee).To(Equal(0.0001))
+			Expect(*rawTx.FeeRate).To(Equal(10000 / float64(rawTx.Vsize)))
+
+			verbosity := 2
+			res, err = api.GetBlock(mocks.MockSpendingBlock.Header.BlockHash().String(), &verbosity)
+			Expect(err).ToNot(HaveOccurred())
+			block, ok := res.(btc.GetBlockVerboseTxResult)
+			Expect(ok).To(BeTrue())
+			Expect(block.Tx[0].Fee).To(BeNil())
+			Expect(*block.Tx[1].Fee).To(Equal(0.0001))
+		})
+
+		It("Returns the block's subsidy, coinbase value and fee statistics", func() {
+			stats, err := api.GetBlockStats(float64(mocks.MockSpendingBlockHeight))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stats.BlockHash).To(Equal(mocks.MockSpendingBlock.Header.BlockHash().String()))
+			Expect(stats.Subsidy).To(Equal(int64(5000000000)))
+			Expect(stats.CoinbaseValue).To(Equal(int64(5000010000)))
+			Expect(stats.TotalFee).To(Equal(int64(10000)))
+			Expect(stats.MinFee).To(Equal(int64(10000)))
+			Expect(stats.MaxFee).To(Equal(int64(10000)))
+			Expect(stats.Txs).To(Equal(int64(2)))
+			Expect(stats.Ins).To(Equal(int64(1)))
+			Expect(stats.Outs).To(Equal(int64(2)))
+			Expect(stats.TotalOut).To(Equal(int64(555990000)))
+		})
+
+		It("Computes the subsidy of blocks indexed before it was from the network's halving schedule", func() {
+			_, err := db.Exec(`UPDATE btc.header_cids SET subsidy = NULL`)
+			Expect(err).ToNot(HaveOccurred())
+			backend, err := btc.NewBtcBackend(db, &chaincfg.RegressionNetParams)
+			Expect(err).ToNot(HaveOccurred())
+			stats, err := btc.NewPublicBtcAPI(backend).GetBlockStats(float64(mocks.MockSpendingBlockHeight))
+			Expect(err).ToNot(HaveOccurred())
+			// regtest halves the subsidy every 150 blocks
+			Expect(stats.Subsidy).To(Equal(int64(5000000000 >> uint(mocks.MockSpendingBlockHeight/150))))
+		})
+
+		It("Errors for blocks whose fees aren't known", func() {
+			_, err := api.GetBlockStats(blockHash)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Errors for blocks whose transactions are not all indexed", func() {
+			_, err := db.Exec(`DELETE FROM btc.transaction_cids WHERE tx_hash = $1`, mocks.MockSpendingBlock.Transactions[1].TxHash().String())
+			Expect(err).ToNot(HaveOccurred())
+			_, err = api.GetBlockStats(float64(mocks.MockSpendingBlockHeight))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Address index", func() {
+		var (
+			// spent in MockSpendingBlock
+			spentAddress = mocks.MockTxsMetaData[1].TxOutputs[0].Addresses[0]
+			// paid to in both MockBlock and MockSpendingBlock
+			paidAddress = mocks.MockTxsMetaData[2].TxOutputs[0].Addresses[0]
+		)
+		BeforeEach(func() {
+			_, err := btc.NewIPLDPublisherAndIndexer(db).Publish(mocks.MockSpendingConvertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("Returns the balance and amount received for addresses", func() {
+			balance, err := api.GetAddressBalance(btc.AddressArgs{Addresses: []string{spentAddress}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(balance.Received).To(Equal(int64(556000000)))
+			Expect(balance.Balance).To(Equal(int64(0)))
+
+			balance, err = api.GetAddressBalance(btc.AddressArgs{Addresses: []string{spentAddress, paidAddress}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(balance.Received).To(Equal(int64(556000000 + 1000000 + 555990000)))
+			Expect(balance.Balance).To(Equal(int64(1000000 + 555990000)))
+		})
+
+		It("Returns the unspent outputs for addresses", func() {
+			utxos, err := api.GetAddressUtxos(btc.AddressArgs{Addresses: []string{spentAddress}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(utxos).To(BeEmpty())
+
+			utxos, err = api.GetAddressUtxos(btc.AddressArgs{Addresses: []string{paidAddress}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(utxos)).To(Equal(2))
+			Expect(utxos[0].TxID).To(Equal(mocks.MockBlock.Transactions[2].TxHash().String()))
+			Expect(utxos[0].Satoshis).To(Equal(int64(1000000)))
+			Expect(utxos[0].Height).To(Equal(mocks.MockBlockHeight))
+			Expect(utxos[1].TxID).To(Equal(mocks.MockSpendingBlock.Transactions[1].TxHash().String()))
+			Expect(utxos[1].Satoshis).To(Equal(int64(555990000)))
+			Expect(utxos[1].Height).To(Equal(mocks.MockSpendingBlockHeight))
+			Expect(utxos[1].Address).To(Equal(paidAddress))
+		})
+
+		It("Returns the history of addresses", func() {
+			deltas, err := api.GetAddressDeltas(btc.AddressArgs{Addresses: []string{spentAddress}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(deltas)).To(Equal(2))
+			Expect(deltas[0].Satoshis).To(Equal(int64(556000000)))
+			Expect(deltas[0].TxID).To(Equal(mocks.MockBlock.Transactions[1].TxHash().String()))
+			Expect(deltas[0].Height).To(Equal(mocks.MockBlockHeight))
+			Expect(deltas[1].Satoshis).To(Equal(int64(-556000000)))
+			Expect(deltas[1].TxID).To(Equal(mocks.MockSpendingBlock.Transactions[1].TxHash().String()))
+			Expect(deltas[1].Height).To(Equal(mocks.MockSpendingBlockHeight))
+
+			deltas, err = api.GetAddressDeltas(btc.AddressArgs{Addresses: []string{spentAddress}, Start: mocks.MockSpendingBlockHeight})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(deltas)).To(Equal(1))
+
+			txids, err := api.GetAddressTxids(btc.AddressArgs{Addresses: []string{spentAddress, paidAddress}})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(txids).To(Equal([]string{
+				mocks.MockBlock.Transactions[1].TxHash().String(),
+				mocks.MockBlock.Transactions[2].TxHash().String(),
+				mocks.MockSpendingBlock.Transactions[1].TxHash().String(),
+			}))
+		})
+
+		It("Rejects invalid addresses", func() {
+			_, err := api.GetAddressBalance(btc.AddressArgs{Addresses: []string{"notAnAddress"}})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
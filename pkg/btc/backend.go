@@ -0,0 +1,211 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// Backend serves bitcoin chain data out of the watcher's database
+type Backend struct {
+	Retriever *CIDRetriever
+	Fetcher   *IPLDPGFetcher
+	DB        *postgres.DB
+	Params    *chaincfg.Params
+}
+
+// NewBtcBackend creates a new Backend for the provided network
+func NewBtcBackend(db *postgres.DB, params *chaincfg.Params) (*Backend, error) {
+	return &Backend{
+		Retriever: NewCIDRetriever(db),
+		Fetcher:   NewIPLDPGFetcher(db),
+		DB:        db,
+		Params:    params,
+	}, nil
+}
+
+// BlockByHash returns the block with the given hash along with its height
+func (b *Backend) BlockByHash(hash chainhash.Hash) (*wire.MsgBlock, int64, error) {
+	headerCID, txCIDs, err := b.Retriever.RetrieveBlockByHash(hash)
+	if err != nil {
+		return nil, 0, err
+	}
+	return b.fetchBlock(headerCID, txCIDs)
+}
+
+// BlockByNumber returns the block at the given height
+func (b *Backend) BlockByNumber(number int64) (*wire.MsgBlock, error) {
+	headerCID, txCIDs, err := b.Retriever.RetrieveBlockByNumber(number)
+	if err != nil {
+		return nil, err
+	}
+	block, _, err := b.fetchBlock(headerCID, txCIDs)
+	return block, err
+}
+
+// fetchBlock fetches and decodes the header and transaction IPLDs for the provided cids
+func (b *Backend) fetchBlock(headerCID HeaderModel, txCIDs []TxModel) (*wire.MsgBlock, int64, error) {
+	height, err := strconv.ParseInt(headerCID.BlockNumber, 10, 64)
+	if err != nil {
+		return nil, 0, err
+	}
+	// Begin tx
+	tx, err := b.DB.Beginx()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	headerIPLD, err := b.Fetcher.FetchHeader(tx, headerCID)
+	if err != nil {
+		return nil, 0, err
+	}
+	block := new(wire.MsgBlock)
+	if err = block.Header.Deserialize(bytes.NewReader(headerIPLD.Data)); err != nil {
+		return nil, 0, err
+	}
+	txIPLDs, err := b.Fetcher.FetchWitnessTrxs(tx, txCIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+	block.Transactions = make([]*wire.MsgTx, len(txIPLDs))
+	for i, txIPLD := range txIPLDs {
+		block.Transactions[i] = new(wire.MsgTx)
+		if err = block.Transactions[i].Deserialize(bytes.NewReader(txIPLD.Data)); err != nil {
+			return nil, 0, err
+		}
+	}
+	return block, height, err // need to return err variable so that we return the err = tx.Commit() assignment in the defer
+}
+
+// HeaderByHash returns the header with the given hash along with its height
+func (b *Backend) HeaderByHash(hash chainhash.Hash) (*wire.BlockHeader, int64, error) {
+	// Begin tx
+	tx, err := b.DB.Beginx()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	headerCID, err := b.Retriever.RetrieveHeaderCIDByHash(tx, hash)
+	if err != nil {
+		return nil, 0, err
+	}
+	height, err := strconv.ParseInt(headerCID.BlockNumber, 10, 64)
+	if err != nil {
+		return nil, 0, err
+	}
+	headerIPLD, err := b.Fetcher.FetchHeader(tx, headerCID)
+	if err != nil {
+		return nil, 0, err
+	}
+	header := new(wire.BlockHeader)
+	err = header.Deserialize(bytes.NewReader(headerIPLD.Data))
+	return header, height, err
+}
+
+// BlockHashByNumber returns the hash of the block at the given height
+func (b *Backend) BlockHashByNumber(number int64) (*chainhash.Hash, error) {
+	// Begin tx
+	tx, err := b.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	headerCIDs, err := b.Retriever.RetrieveHeaderCIDs(tx, number)
+	if err != nil {
+		return nil, err
+	}
+	if len(headerCIDs) < 1 {
+		err = fmt.Errorf("header at block %d is not available", number)
+		return nil, err
+	}
+	hash, err := chainhash.NewHashFromStr(headerCIDs[0].BlockHash)
+	return hash, err
+}
+
+// TransactionByHash returns the transaction with the given hash along with its cid and the header of the block it was included in
+func (b *Backend) TransactionByHash(hash chainhash.Hash) (*wire.MsgTx, TxModel, HeaderModel, error) {
+	// Begin tx
+	tx, err := b.DB.Beginx()
+	if err != nil {
+		return nil, TxModel{}, HeaderModel{}, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	txCID, err := b.Retriever.RetrieveTxCIDByHash(tx, hash)
+	if err != nil {
+		return nil, TxModel{}, HeaderModel{}, err
+	}
+	headerCID, err := b.Retriever.RetrieveHeaderCIDByID(tx, txCID.HeaderID)
+	if err != nil {
+		return nil, TxModel{}, HeaderModel{}, err
+	}
+	txIPLDs, err := b.Fetcher.FetchWitnessTrxs(tx, []TxModel{txCID})
+	if err != nil {
+		return nil, TxModel{}, HeaderModel{}, err
+	}
+	msgTx := new(wire.MsgTx)
+	err = msgTx.Deserialize(bytes.NewReader(txIPLDs[0].Data))
+	return msgTx, txCID, headerCID, err
+}
@@ -0,0 +1,85 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	log "github.com/sirupsen/logrus"
+)
+
+// MaxReorgDepth is the number of blocks behind the best tip we keep around to place the blocks of competing forks
+const MaxReorgDepth = 64
+
+type trackedBlock struct {
+	height    int64
+	chainWork *big.Int
+	best      bool // whether the block extended the best chain when it was first seen
+}
+
+// bestChain tracks the chain work of the most recently streamed blocks
+type bestChain struct {
+	sync.Mutex
+	blocks map[chainhash.Hash]*trackedBlock
+	tip    *trackedBlock
+}
+
+func newBestChain() *bestChain {
+	return &bestChain{
+		blocks: make(map[chainhash.Hash]*trackedBlock),
+	}
+}
+
+// connect records the block and returns whether it extends the best chain; the block seen first wins a tie
+// A block is filtered once for every subscription type, so a block that has been seen before gets the same answer it
+// got the first time
+func (bc *bestChain) connect(header *wire.BlockHeader, height int64) bool {
+	bc.Lock()
+	defer bc.Unlock()
+	hash := header.BlockHash()
+	if block, ok := bc.blocks[hash]; ok {
+		return block.best
+	}
+	work := blockchain.CalcWork(header.Bits)
+	block := &trackedBlock{height: height}
+	if parent, ok := bc.blocks[header.PrevBlock]; ok {
+		block.chainWork = work.Add(work, parent.chainWork)
+	} else if bc.tip != nil {
+		// we missed the blocks between this one and the tip, assume they had the same work as this one
+		block.chainWork = work.Mul(work, big.NewInt(height-bc.tip.height))
+		block.chainWork.Add(block.chainWork, bc.tip.chainWork)
+	} else {
+		block.chainWork = work
+	}
+	bc.blocks[hash] = block
+	if bc.tip != nil && block.chainWork.Cmp(bc.tip.chainWork) <= 0 {
+		log.Infof("btc block %d (%s) is not on the best chain", height, hash.String())
+		return false
+	}
+	block.best = true
+	bc.tip = block
+	for h, b := range bc.blocks {
+		if b.height <= height-MaxReorgDepth {
+			delete(bc.blocks, h)
+		}
+	}
+	return true
+}
@@ -0,0 +1,312 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// blkRecordHeaderSize is the size of the network magic and block size that precede every block in a blk*.dat file
+const blkRecordHeaderSize = 8
+
+// BlkFileConfig holds the settings for fetching payloads from a bitcoind data directory instead of over rpc
+type BlkFileConfig struct {
+	DataDir string
+	Params  *chaincfg.Params
+}
+
+// ParamsFromNetworkID returns the chain params for the network magic in the configured network id, e.g. "0xD9B4BEF9"
+// mainnet is assumed if no network id is configured
+func ParamsFromNetworkID(networkID string) (*chaincfg.Params, error) {
+	if networkID == "" {
+		return &chaincfg.MainNetParams, nil
+	}
+	magic, err := strconv.ParseUint(networkID, 0, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bitcoin network id %s: %v", networkID, err)
+	}
+	for _, params := range []*chaincfg.Params{&chaincfg.MainNetParams, &chaincfg.TestNet3Params, &chaincfg.RegressionNetParams, &chaincfg.SimNetParams} {
+		if uint32(params.Net) == uint32(magic) {
+			return params, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown bitcoin network id %s", networkID)
+}
+
+// blkLocation is where a block is stored in the blk*.dat files
+type blkLocation struct {
+	prevHash chainhash.Hash
+	bits     uint32
+	file     int
+	offset   int64 // offset of the serialized block, past its record header
+	size     uint32
+}
+
+// BlkFileFetcher satisfies the PayloadFetcher interface for bitcoin by reading blocks straight out of the blk*.dat files
+// of a bitcoind data directory, so that no node has to be running
+// The files are indexed once, when the fetcher is created; blocks written to them afterwards are not seen
+type BlkFileFetcher struct {
+	blocksDir string
+	xorKey    []byte
+	// best chain block locations, by height
+	chain []*blkLocation
+}
+
+// NewBlkFileFetcher indexes the blk*.dat files in the provided data directory (or its blocks subdirectory) and returns a
+// BlkFileFetcher for the chain with the most work in them
+// The files are assumed to have been written by a node that validated the blocks; they are not validated again here
+func NewBlkFileFetcher(config *BlkFileConfig) (*BlkFileFetcher, error) {
+	blocksDir := config.DataDir
+	if info, err := os.Stat(filepath.Join(blocksDir, "blocks")); err == nil && info.IsDir() {
+		blocksDir = filepath.Join(blocksDir, "blocks")
+	}
+	fetcher := &BlkFileFetcher{blocksDir: blocksDir}
+	// newer versions of bitcoind obfuscate the blk*.dat files with the key in xor.dat
+	key, err := ioutil.ReadFile(filepath.Join(blocksDir, "xor.dat"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(key) > 0 && !bytes.Equal(key, make([]byte, len(key))) {
+		fetcher.xorKey = key
+	}
+	files, err := blkFiles(blocksDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no blk*.dat files found in %s", blocksDir)
+	}
+	index := make(map[chainhash.Hash]*blkLocation)
+	for _, file := range files {
+		if err := fetcher.indexFile(file, uint32(config.Params.Net), index); err != nil {
+			return nil, err
+		}
+	}
+	fetcher.chain, err = bestBlkChain(index, config.Params.GenesisHash)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("indexed %d btc blocks in %s, best chain height is %d", len(index), blocksDir, fetcher.Height())
+	return fetcher, nil
+}
+
+// Height returns the height of the best chain tip found in the files
+func (fetcher *BlkFileFetcher) Height() uint64 {
+	return uint64(len(fetcher.chain) - 1)
+}
+
+// FetchAt fetches the block payloads at the given block heights
+func (fetcher *BlkFileFetcher) FetchAt(blockHeights []uint64) ([]shared.RawChainData, error) {
+	blockPayloads := make([]shared.RawChainData, len(blockHeights))
+	for i, height := range blockHeights {
+		if height >= uint64(len(fetcher.chain)) {
+			return nil, fmt.Errorf("bitcoin BlkFileFetcher has no block at blockheight %d, the best chain in the files ends at %d", height, fetcher.Height())
+		}
+		block, err := fetcher.readBlock(fetcher.chain[height])
+		if err != nil {
+			return nil, fmt.Errorf("bitcoin BlkFileFetcher read err at blockheight %d: %s", height, err.Error())
+		}
+		blockPayloads[i] = BlockPayload{
+			BlockHeight: int64(height),
+			Header:      &block.Header,
+			Txs:         msgTxsToUtilTxs(block.Transactions),
+		}
+	}
+	return blockPayloads, nil
+}
+
+func (fetcher *BlkFileFetcher) readBlock(loc *blkLocation) (*wire.MsgBlock, error) {
+	f, err := os.Open(fetcher.blkFilePath(loc.file))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data := make([]byte, loc.size)
+	if err := fetcher.readAt(f, data, loc.offset); err != nil {
+		return nil, err
+	}
+	block := new(wire.MsgBlock)
+	if err := block.Deserialize(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// indexFile records the location of every block in the file
+// bitcoind preallocates the files, so the first record without a network magic marks the end of the written data
+func (fetcher *BlkFileFetcher) indexFile(file int, magic uint32, index map[chainhash.Hash]*blkLocation) error {
+	path := fetcher.blkFilePath(file)
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	record := make([]byte, blkRecordHeaderSize+wire.MaxBlockHeaderPayload)
+	for offset := int64(0); offset+int64(len(record)) <= info.Size(); {
+		if err := fetcher.readAt(f, record, offset); err != nil {
+			return err
+		}
+		recordMagic := binary.LittleEndian.Uint32(record[:4])
+		if recordMagic == 0 {
+			break
+		}
+		if recordMagic != magic {
+			return fmt.Errorf("unexpected network magic %#x at offset %d of %s", recordMagic, offset, path)
+		}
+		size := binary.LittleEndian.Uint32(record[4:blkRecordHeaderSize])
+		blockOffset := offset + blkRecordHeaderSize
+		if size < wire.MaxBlockHeaderPayload || blockOffset+int64(size) > info.Size() {
+			log.Warnf("truncated btc block at offset %d of %s, ignoring the rest of the file", offset, path)
+			break
+		}
+		header := new(wire.BlockHeader)
+		if err := header.Deserialize(bytes.NewReader(record[blkRecordHeaderSize:])); err != nil {
+			return err
+		}
+		hash := header.BlockHash()
+		if _, ok := index[hash]; !ok {
+			index[hash] = &blkLocation{
+				prevHash: header.PrevBlock,
+				bits:     header.Bits,
+				file:     file,
+				offset:   blockOffset,
+				size:     size,
+			}
+		}
+		offset = blockOffset + int64(size)
+	}
+	return nil
+}
+
+func (fetcher *BlkFileFetcher) readAt(f *os.File, data []byte, offset int64) error {
+	if _, err := f.ReadAt(data, offset); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if fetcher.xorKey != nil {
+		keyLen := int64(len(fetcher.xorKey))
+		for i := range data {
+			data[i] ^= fetcher.xorKey[(offset+int64(i))%keyLen]
+		}
+	}
+	return nil
+}
+
+func (fetcher *BlkFileFetcher) blkFilePath(file int) string {
+	return filepath.Join(fetcher.blocksDir, fmt.Sprintf("blk%05d.dat", file))
+}
+
+// blkFiles returns the numbers of the blk*.dat files in the directory, in order
+func blkFiles(blocksDir string) ([]int, error) {
+	paths, err := filepath.Glob(filepath.Join(blocksDir, "blk*.dat"))
+	if err != nil {
+		return nil, err
+	}
+	files := make([]int, 0, len(paths))
+	for _, path := range paths {
+		var file int
+		if _, err := fmt.Sscanf(filepath.Base(path), "blk%05d.dat", &file); err != nil {
+			continue
+		}
+		files = append(files, file)
+	}
+	sort.Ints(files)
+	return files, nil
+}
+
+// bestBlkChain resolves the heights of the indexed blocks by following the prev-hash links out from the genesis block
+// and returns the locations of the blocks on the chain with the most work, by height; the branch written first wins a tie
+// Blocks that don't connect to the genesis block, e.g. because the blocks below them were pruned, are left out
+func bestBlkChain(index map[chainhash.Hash]*blkLocation, genesisHash *chainhash.Hash) ([]*blkLocation, error) {
+	genesis, ok := index[*genesisHash]
+	if !ok {
+		return nil, fmt.Errorf("genesis block %s not found in the blk*.dat files", genesisHash.String())
+	}
+	children := make(map[chainhash.Hash][]chainhash.Hash)
+	for hash, loc := range index {
+		if hash != *genesisHash {
+			children[loc.prevHash] = append(children[loc.prevHash], hash)
+		}
+	}
+	// map iteration order is random, order the children the way they were written so that ties are broken consistently
+	for _, hashes := range children {
+		sort.Slice(hashes, func(i, j int) bool {
+			a, b := index[hashes[i]], index[hashes[j]]
+			return a.file < b.file || (a.file == b.file && a.offset < b.offset)
+		})
+	}
+	type chainTip struct {
+		hash      chainhash.Hash
+		height    int
+		chainWork *big.Int
+	}
+	best := chainTip{hash: *genesisHash, chainWork: blockchain.CalcWork(genesis.bits)}
+	connected := 1
+	stack := []chainTip{best}
+	for len(stack) > 0 {
+		tip := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if tip.chainWork.Cmp(best.chainWork) > 0 {
+			best = tip
+		}
+		hashes := children[tip.hash]
+		// push in reverse so the first written child is visited first
+		for i := len(hashes) - 1; i >= 0; i-- {
+			work := blockchain.CalcWork(index[hashes[i]].bits)
+			stack = append(stack, chainTip{
+				hash:      hashes[i],
+				height:    tip.height + 1,
+				chainWork: work.Add(work, tip.chainWork),
+			})
+			connected++
+		}
+	}
+	if unconnected := len(index) - connected; unconnected > 0 {
+		log.Warnf("%d btc blocks in the blk*.dat files do not connect to the genesis block and are ignored", unconnected)
+	}
+	chain := make([]*blkLocation, best.height+1)
+	hash := best.hash
+	for height := best.height; height >= 0; height-- {
+		loc := index[hash]
+		chain[height] = loc
+		hash = loc.prevHash
+	}
+	return chain, nil
+}
@@ -0,0 +1,177 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+)
+
+// newRegtestBlock returns a block with a single coinbase transaction on top of the given parent
+func newRegtestBlock(parent *wire.MsgBlock, nonce uint32) *wire.MsgBlock {
+	coinbase := wire.NewMsgTx(1)
+	coinbase.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, wire.MaxPrevOutIndex), []byte{byte(nonce), 0x51}, nil))
+	coinbase.AddTxOut(wire.NewTxOut(5000000000, []byte{0x51}))
+	block := wire.NewMsgBlock(wire.NewBlockHeader(1, new(chainhash.Hash), new(chainhash.Hash), 0x207fffff, nonce))
+	block.Header.PrevBlock = parent.BlockHash()
+	block.Header.MerkleRoot = coinbase.TxHash()
+	block.Header.Timestamp = parent.Header.Timestamp.Add(10 * time.Minute)
+	Expect(block.AddTransaction(coinbase)).To(Succeed())
+	return block
+}
+
+// writeBlkFile writes the blocks into the numbered blk*.dat file the way bitcoind does, obfuscated with the key if
+// one is given and followed by the zeroed space bitcoind preallocates
+func writeBlkFile(dir string, file int, key []byte, blocks ...*wire.MsgBlock) {
+	var buf bytes.Buffer
+	for _, block := range blocks {
+		record := make([]byte, 8)
+		binary.LittleEndian.PutUint32(record[:4], uint32(chaincfg.RegressionNetParams.Net))
+		binary.LittleEndian.PutUint32(record[4:], uint32(block.SerializeSize()))
+		buf.Write(record)
+		Expect(block.Serialize(&buf)).To(Succeed())
+	}
+	buf.Write(make([]byte, 1024))
+	data := buf.Bytes()
+	for i := range key {
+		for j := i; j < len(data); j += len(key) {
+			data[j] ^= key[i]
+		}
+	}
+	Expect(ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("blk%05d.dat", file)), data, 0644)).To(Succeed())
+}
+
+var _ = Describe("BlkFileFetcher", func() {
+	var (
+		dataDir, blocksDir string
+		genesis            = chaincfg.RegressionNetParams.GenesisBlock
+		block1             = newRegtestBlock(genesis, 1)
+		block2             = newRegtestBlock(block1, 2)
+		block3             = newRegtestBlock(block2, 3)
+		fork2              = newRegtestBlock(block1, 20)
+		config             *btc.BlkFileConfig
+	)
+	BeforeEach(func() {
+		var err error
+		dataDir, err = ioutil.TempDir("", "btc_blk_files")
+		Expect(err).ToNot(HaveOccurred())
+		blocksDir = filepath.Join(dataDir, "blocks")
+		Expect(os.Mkdir(blocksDir, 0755)).To(Succeed())
+		config = &btc.BlkFileConfig{
+			DataDir: dataDir,
+			Params:  &chaincfg.RegressionNetParams,
+		}
+	})
+	AfterEach(func() {
+		os.RemoveAll(dataDir)
+	})
+
+	Describe("FetchAt", func() {
+		It("Resolves the heights of out of order blocks and fetches the best chain", func() {
+			writeBlkFile(blocksDir, 0, nil, genesis, block2, fork2, block1)
+			writeBlkFile(blocksDir, 1, nil, block3)
+			fetcher, err := btc.NewBlkFileFetcher(config)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fetcher.Height()).To(Equal(uint64(3)))
+			payloads, err := fetcher.FetchAt([]uint64{0, 1, 2, 3})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(payloads)).To(Equal(4))
+			for i, block := range []*wire.MsgBlock{genesis, block1, block2, block3} {
+				payload, ok := payloads[i].(btc.BlockPayload)
+				Expect(ok).To(BeTrue())
+				Expect(payload.BlockHeight).To(Equal(int64(i)))
+				Expect(payload.Header.BlockHash()).To(Equal(block.BlockHash()))
+				Expect(len(payload.Txs)).To(Equal(len(block.Transactions)))
+				for j, tx := range payload.Txs {
+					Expect(tx.Index()).To(Equal(j))
+					Expect(tx.MsgTx()).To(Equal(block.Transactions[j]))
+				}
+			}
+		})
+
+		It("Gives a tie in chain work to the branch written first", func() {
+			writeBlkFile(blocksDir, 0, nil, genesis, block1, fork2, block2)
+			fetcher, err := btc.NewBlkFileFetcher(config)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fetcher.Height()).To(Equal(uint64(2)))
+			payloads, err := fetcher.FetchAt([]uint64{2})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(payloads[0].(btc.BlockPayload).Header.BlockHash()).To(Equal(fork2.BlockHash()))
+		})
+
+		It("Reads files obfuscated with the key in xor.dat", func() {
+			key := []byte{0x01, 0x23, 0x45, 0x67, 0x89, 0xab, 0xcd, 0xef}
+			Expect(ioutil.WriteFile(filepath.Join(blocksDir, "xor.dat"), key, 0644)).To(Succeed())
+			writeBlkFile(blocksDir, 0, key, genesis, block1, block2)
+			config.DataDir = blocksDir
+			fetcher, err := btc.NewBlkFileFetcher(config)
+			Expect(err).ToNot(HaveOccurred())
+			payloads, err := fetcher.FetchAt([]uint64{2})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(payloads[0].(btc.BlockPayload).Header.BlockHash()).To(Equal(block2.BlockHash()))
+		})
+
+		It("Errors for heights past the best chain tip", func() {
+			writeBlkFile(blocksDir, 0, nil, genesis, block1)
+			fetcher, err := btc.NewBlkFileFetcher(config)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = fetcher.FetchAt([]uint64{1, 2})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("NewBlkFileFetcher", func() {
+		It("Errors if the genesis block is missing", func() {
+			writeBlkFile(blocksDir, 0, nil, block1, block2)
+			_, err := btc.NewBlkFileFetcher(config)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Errors on blocks from another network", func() {
+			writeBlkFile(blocksDir, 0, nil, genesis)
+			config.Params = &chaincfg.MainNetParams
+			_, err := btc.NewBlkFileFetcher(config)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("ParamsFromNetworkID", func() {
+		It("Looks up the params by network magic", func() {
+			params, err := btc.ParamsFromNetworkID("0xD9B4BEF9")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(params).To(Equal(&chaincfg.MainNetParams))
+			params, err = btc.ParamsFromNetworkID("0xDAB5BFFA")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(params).To(Equal(&chaincfg.RegressionNetParams))
+			_, err = btc.ParamsFromNetworkID("0x12345678")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
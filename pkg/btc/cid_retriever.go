@@ -21,7 +21,8 @@ import (
 	"fmt"
 	"math/big"
 
-	"github.com/ethereum/go-ethereum/common"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 	log "github.com/sirupsen/logrus"
@@ -46,14 +47,14 @@ func NewCIDRetriever(db *postgres.DB) *CIDRetriever {
 // RetrieveFirstBlockNumber is used to retrieve the first block number in the db
 func (bcr *CIDRetriever) RetrieveFirstBlockNumber() (int64, error) {
 	var blockNumber int64
-	err := bcr.db.Get(&blockNumber, "SELECT block_number FROM btc.header_cids ORDER BY block_number ASC LIMIT 1")
+	err := bcr.db.Get(&blockNumber, "SELECT block_number FROM btc.header_cids WHERE NOT orphaned ORDER BY block_number ASC LIMIT 1")
 	return blockNumber, err
 }
 
-// RetrieveLastBlockNumber is used to retrieve the latest block number in the db
+// RetrieveLastBlockNumber is used to retrieve the latest block number of the best chain in the db
 func (bcr *CIDRetriever) RetrieveLastBlockNumber() (int64, error) {
 	var blockNumber int64
-	err := bcr.db.Get(&blockNumber, "SELECT block_number FROM btc.header_cids ORDER BY block_number DESC LIMIT 1 ")
+	err := bcr.db.Get(&blockNumber, "SELECT block_number FROM btc.header_cids WHERE NOT orphaned ORDER BY block_number DESC LIMIT 1 ")
 	return blockNumber, err
 }
 
@@ -92,6 +93,7 @@ func (bcr *CIDRetriever) Retrieve(filter shared.SubscriptionSettings, blockNumbe
 	for i, header := range headers {
 		cw := new(CIDWrapper)
 		cw.BlockNumber = big.NewInt(blockNumber)
+		cw.Witness = streamFilter.TxFilter.Witness
 		if !streamFilter.HeaderFilter.Off {
 			cw.Header = header
 			empty = false
@@ -113,12 +115,13 @@ func (bcr *CIDRetriever) Retrieve(filter shared.SubscriptionSettings, blockNumbe
 	return cws, empty, err
 }
 
-// RetrieveHeaderCIDs retrieves and returns all of the header cids at the provided blockheight
+// RetrieveHeaderCIDs retrieves and returns the best chain header cids at the provided blockheight
 func (bcr *CIDRetriever) RetrieveHeaderCIDs(tx *sqlx.Tx, blockNumber int64) ([]HeaderModel, error) {
 	log.Debug("retrieving header cids for block ", blockNumber)
 	headers := make([]HeaderModel, 0)
 	pgStr := `SELECT * FROM btc.header_cids
-				WHERE block_number = $1`
+				WHERE block_number = $1
+				AND NOT orphaned`
 	return headers, tx.Select(&headers, pgStr, blockNumber)
 }
 
@@ -131,19 +134,20 @@ func (bcr *CIDRetriever) RetrieveTxCIDs(tx *sqlx.Tx, txFilter TxFilter, headerID
 	id := 1
 	pgStr := fmt.Sprintf(`SELECT transaction_cids.id, transaction_cids.header_id,
  			transaction_cids.tx_hash, transaction_cids.cid, transaction_cids.mh_key,
- 			transaction_cids.segwit, transaction_cids.witness_hash, transaction_cids.index
- 			FROM btc.transaction_cids, btc.header_cids, btc.tx_inputs, btc.tx_outputs
-			WHERE transaction_cids.header_id = header_cids.id
-			AND tx_inputs.tx_id = transaction_cids.id
-			AND tx_outputs.tx_id = transaction_cids.id
-			AND header_cids.id = $%d`, id)
+ 			transaction_cids.segwit, transaction_cids.witness_hash, transaction_cids.index,
+ 			transaction_cids.weight, transaction_cids.fee, transaction_cids.fee_rate,
+ 			transaction_cids.witness_cid, transaction_cids.witness_mh_key
+ 			FROM btc.transaction_cids
+			WHERE transaction_cids.header_id = $%d`, id)
 	args = append(args, headerID)
 	id++
+	// each output and input criterion can be satisfied by a different output or input of the tx
+	outputCriterion := ` AND EXISTS (SELECT 1 FROM btc.tx_outputs WHERE tx_outputs.tx_id = transaction_cids.id AND `
 	if txFilter.Segwit {
 		pgStr += ` AND transaction_cids.segwit = true`
 	}
 	if txFilter.MultiSig {
-		pgStr += ` AND tx_outputs.required_sigs > 1`
+		pgStr += outputCriterion + `tx_outputs.required_sigs > 1)`
 	}
 	if len(txFilter.WitnessHashes) > 0 {
 		pgStr += fmt.Sprintf(` AND transaction_cids.witness_hash = ANY($%d::VARCHAR(66)[])`, id)
@@ -151,19 +155,58 @@ func (bcr *CIDRetriever) RetrieveTxCIDs(tx *sqlx.Tx, txFilter TxFilter, headerID
 		id++
 	}
 	if len(txFilter.Addresses) > 0 {
-		pgStr += fmt.Sprintf(` AND tx_outputs.addresses && $%d::VARCHAR(66)[]`, id)
+		pgStr += fmt.Sprintf(outputCriterion+`tx_outputs.addresses && $%d::VARCHAR(66)[])`, id)
 		args = append(args, pq.Array(txFilter.Addresses))
 		id++
 	}
+	if len(txFilter.SpendingAddresses) > 0 {
+		pgStr += fmt.Sprintf(` AND EXISTS (SELECT 1 FROM btc.tx_inputs, btc.tx_outputs
+			WHERE tx_inputs.tx_id = transaction_cids.id
+			AND tx_inputs.output_id = tx_outputs.id
+			AND tx_outputs.addresses && $%d::VARCHAR(66)[])`, id)
+		args = append(args, pq.Array(txFilter.SpendingAddresses))
+		id++
+	}
 	if len(txFilter.Indexes) > 0 {
 		pgStr += fmt.Sprintf(` AND transaction_cids.index = ANY($%d::INTEGER[])`, id)
 		args = append(args, pq.Array(txFilter.Indexes))
 		id++
 	}
 	if len(txFilter.PkScriptClasses) > 0 {
-		pgStr += fmt.Sprintf(` AND tx_outputs.script_class = ANY($%d::INTEGER[])`, id)
+		pgStr += fmt.Sprintf(outputCriterion+`tx_outputs.script_class = ANY($%d::INTEGER[]))`, id)
 		args = append(args, pq.Array(txFilter.PkScriptClasses))
+		id++
+	}
+	if len(txFilter.OpReturnPrefixes) > 0 {
+		prefixes, err := decodeHexPrefixes(txFilter.OpReturnPrefixes)
+		if err != nil {
+			return nil, err
+		}
+		pgStr += fmt.Sprintf(outputCriterion+`EXISTS (SELECT 1 FROM unnest($%d::BYTEA[]) AS prefix
+			WHERE substring(tx_outputs.op_return_data FROM 1 FOR length(prefix)) = prefix))`, id)
+		args = append(args, pq.Array(prefixes))
+		id++
 	}
+	if txFilter.MinValue > 0 {
+		pgStr += fmt.Sprintf(` AND (SELECT SUM(value) FROM btc.tx_outputs WHERE tx_outputs.tx_id = transaction_cids.id) >= $%d`, id)
+		args = append(args, txFilter.MinValue)
+		id++
+	}
+	if txFilter.MaxValue > 0 {
+		pgStr += fmt.Sprintf(` AND (SELECT SUM(value) FROM btc.tx_outputs WHERE tx_outputs.tx_id = transaction_cids.id) <= $%d`, id)
+		args = append(args, txFilter.MaxValue)
+		id++
+	}
+	if txFilter.MinFee > 0 {
+		pgStr += fmt.Sprintf(` AND transaction_cids.fee >= $%d`, id)
+		args = append(args, txFilter.MinFee)
+		id++
+	}
+	if txFilter.MinFeeRate > 0 {
+		pgStr += fmt.Sprintf(` AND transaction_cids.fee_rate >= $%d`, id)
+		args = append(args, txFilter.MinFeeRate)
+	}
+	pgStr += ` ORDER BY transaction_cids.index`
 	return results, tx.Select(&results, pgStr, args...)
 }
 
@@ -217,7 +260,7 @@ func (bcr *CIDRetriever) RetrieveGapsInData(validationLevel int) ([]shared.Gap,
 }
 
 // RetrieveBlockByHash returns all of the CIDs needed to compose an entire block, for a given block hash
-func (bcr *CIDRetriever) RetrieveBlockByHash(blockHash common.Hash) (HeaderModel, []TxModel, error) {
+func (bcr *CIDRetriever) RetrieveBlockByHash(blockHash chainhash.Hash) (HeaderModel, []TxModel, error) {
 	log.Debug("retrieving block cids for block hash ", blockHash.String())
 
 	// Begin new db tx
@@ -283,8 +326,8 @@ func (bcr *CIDRetriever) RetrieveBlockByNumber(blockNumber int64) (HeaderModel,
 	return headerCID[0], txCIDs, err
 }
 
-// RetrieveHeaderCIDByHash returns the header for the given block hash
-func (bcr *CIDRetriever) RetrieveHeaderCIDByHash(tx *sqlx.Tx, blockHash common.Hash) (HeaderModel, error) {
+// RetrieveHeaderCIDByHash returns the header for the given block hash, whether or not it is on the best chain
+func (bcr *CIDRetriever) RetrieveHeaderCIDByHash(tx *sqlx.Tx, blockHash chainhash.Hash) (HeaderModel, error) {
 	log.Debug("retrieving header cids for block hash ", blockHash.String())
 	pgStr := `SELECT * FROM btc.header_cids
 			WHERE block_hash = $1`
@@ -292,11 +335,138 @@ func (bcr *CIDRetriever) RetrieveHeaderCIDByHash(tx *sqlx.Tx, blockHash common.H
 	return headerCID, tx.Get(&headerCID, pgStr, blockHash.String())
 }
 
-// RetrieveTxCIDsByHeaderID retrieves all tx CIDs for the given header id
+// RetrieveHeaderCIDByID returns the header with the given id
+func (bcr *CIDRetriever) RetrieveHeaderCIDByID(tx *sqlx.Tx, headerID int64) (HeaderModel, error) {
+	log.Debug("retrieving header cids for header id ", headerID)
+	pgStr := `SELECT * FROM btc.header_cids
+			WHERE id = $1`
+	var headerCID HeaderModel
+	return headerCID, tx.Get(&headerCID, pgStr, headerID)
+}
+
+// RetrieveTxCIDsByHeaderID retrieves all tx CIDs for the given header id, in the order they appear in the block
 func (bcr *CIDRetriever) RetrieveTxCIDsByHeaderID(tx *sqlx.Tx, headerID int64) ([]TxModel, error) {
 	log.Debug("retrieving tx cids for block id ", headerID)
 	pgStr := `SELECT * FROM btc.transaction_cids
-			WHERE header_id = $1`
+			WHERE header_id = $1
+			ORDER BY index`
 	var txCIDs []TxModel
 	return txCIDs, tx.Select(&txCIDs, pgStr, headerID)
 }
+
+// RetrieveTxCIDByHash returns the tx cid for the given transaction hash in the best chain
+func (bcr *CIDRetriever) RetrieveTxCIDByHash(tx *sqlx.Tx, txHash chainhash.Hash) (TxModel, error) {
+	log.Debug("retrieving tx cid for tx hash ", txHash.String())
+	pgStr := `SELECT transaction_cids.* FROM btc.transaction_cids
+			INNER JOIN btc.header_cids ON (transaction_cids.header_id = header_cids.id)
+			WHERE transaction_cids.tx_hash = $1
+			AND NOT header_cids.orphaned`
+	var txCID TxModel
+	return txCID, tx.Get(&txCID, pgStr, txHash.String())
+}
+
+// spentOutput is true for an output that is spent by an input in the best chain
+const spentOutput = `EXISTS (SELECT 1 FROM btc.tx_inputs, btc.transaction_cids AS spending, btc.header_cids AS spending_header
+				WHERE tx_inputs.output_id = tx_outputs.id
+				AND tx_inputs.tx_id = spending.id
+				AND spending.header_id = spending_header.id
+				AND NOT spending_header.orphaned)`
+
+// RetrieveAddressBalance returns the total value received by the given addresses in the best chain
+// and the portion of it that has been spent
+func (bcr *CIDRetriever) RetrieveAddressBalance(addresses []string) (int64, int64, error) {
+	log.Debug("retrieving balance for addresses ", addresses)
+	pgStr := `SELECT COALESCE(SUM(value), 0) AS received, COALESCE(SUM(value) FILTER (WHERE spent), 0) AS spent
+			FROM (SELECT tx_outputs.value, ` + spentOutput + ` AS spent
+				FROM btc.tx_outputs, unnest(tx_outputs.addresses) AS address, btc.transaction_cids, btc.header_cids
+				WHERE tx_outputs.tx_id = transaction_cids.id
+				AND transaction_cids.header_id = header_cids.id
+				AND NOT header_cids.orphaned
+				AND tx_outputs.addresses && $1
+				AND address = ANY($1)) AS address_outputs`
+	var balance struct {
+		Received int64 `db:"received"`
+		Spent    int64 `db:"spent"`
+	}
+	err := bcr.db.Get(&balance, pgStr, pq.Array(addresses))
+	return balance.Received, balance.Spent, err
+}
+
+// RetrieveAddressUTXOs returns the outputs paying to the given addresses in the best chain that have not been spent
+func (bcr *CIDRetriever) RetrieveAddressUTXOs(addresses []string) ([]AddressOutputModel, error) {
+	log.Debug("retrieving utxos for addresses ", addresses)
+	pgStr := `SELECT address, tx_outputs.*, transaction_cids.tx_hash, header_cids.block_number
+			FROM btc.tx_outputs, unnest(tx_outputs.addresses) AS address, btc.transaction_cids, btc.header_cids
+			WHERE tx_outputs.tx_id = transaction_cids.id
+			AND transaction_cids.header_id = header_cids.id
+			AND NOT header_cids.orphaned
+			AND tx_outputs.addresses && $1
+			AND address = ANY($1)
+			AND NOT ` + spentOutput + `
+			ORDER BY header_cids.block_number, transaction_cids.index, tx_outputs.index`
+	utxos := make([]AddressOutputModel, 0)
+	return utxos, bcr.db.Select(&utxos, pgStr, pq.Array(addresses))
+}
+
+// RetrieveAddressDeltas returns the outputs paying to and inputs spending from the given addresses, in best chain order
+// The start and end block numbers bound the range of blocks searched, a bound of 0 is ignored
+func (bcr *CIDRetriever) RetrieveAddressDeltas(addresses []string, start, end int64) ([]AddressDeltaModel, error) {
+	log.Debug("retrieving deltas for addresses ", addresses)
+	pgStr := `SELECT address, tx_outputs.value, transaction_cids.tx_hash, transaction_cids.index AS tx_index, tx_outputs.index, header_cids.block_number
+			FROM btc.tx_outputs, unnest(tx_outputs.addresses) AS address, btc.transaction_cids, btc.header_cids
+			WHERE tx_outputs.tx_id = transaction_cids.id
+			AND transaction_cids.header_id = header_cids.id
+			AND NOT header_cids.orphaned
+			AND tx_outputs.addresses && $1
+			AND address = ANY($1)
+			AND ($2 = 0 OR header_cids.block_number >= $2)
+			AND ($3 = 0 OR header_cids.block_number <= $3)
+			UNION ALL
+			SELECT address, -tx_outputs.value, transaction_cids.tx_hash, transaction_cids.index AS tx_index, tx_inputs.index, header_cids.block_number
+			FROM btc.tx_inputs, btc.tx_outputs, unnest(tx_outputs.addresses) AS address, btc.transaction_cids, btc.header_cids
+			WHERE tx_inputs.output_id = tx_outputs.id
+			AND tx_inputs.tx_id = transaction_cids.id
+			AND transaction_cids.header_id = header_cids.id
+			AND NOT header_cids.orphaned
+			AND tx_outputs.addresses && $1
+			AND address = ANY($1)
+			AND ($2 = 0 OR header_cids.block_number >= $2)
+			AND ($3 = 0 OR header_cids.block_number <= $3)
+			ORDER BY block_number, tx_index, value DESC, index`
+	deltas := make([]AddressDeltaModel, 0)
+	return deltas, bcr.db.Select(&deltas, pgStr, pq.Array(addresses), start, end)
+}
+
+// RetrieveOutputs returns the indexed best chain outputs referenced by the given outpoints
+func (bcr *CIDRetriever) RetrieveOutputs(outpoints []wire.OutPoint) (map[wire.OutPoint]TxOutput, error) {
+	log.Debug("retrieving outputs for outpoints ", outpoints)
+	hashes := make([]string, len(outpoints))
+	indexes := make([]int64, len(outpoints))
+	for i, outpoint := range outpoints {
+		hashes[i] = outpoint.Hash.String()
+		indexes[i] = int64(outpoint.Index)
+	}
+	pgStr := `SELECT transaction_cids.tx_hash, tx_outputs.*
+			FROM btc.tx_outputs
+			INNER JOIN btc.transaction_cids ON (tx_outputs.tx_id = transaction_cids.id)
+			INNER JOIN btc.header_cids ON (transaction_cids.header_id = header_cids.id)
+			INNER JOIN unnest($1::VARCHAR(66)[], $2::INTEGER[]) AS outpoints (tx_hash, index)
+			ON (transaction_cids.tx_hash = outpoints.tx_hash AND tx_outputs.index = outpoints.index)
+			WHERE NOT header_cids.orphaned`
+	results := make([]struct {
+		TxOutput
+		TxHash string `db:"tx_hash"`
+	}, 0)
+	if err := bcr.db.Select(&results, pgStr, pq.Array(hashes), pq.Array(indexes)); err != nil {
+		return nil, err
+	}
+	outputs := make(map[wire.OutPoint]TxOutput, len(results))
+	for _, res := range results {
+		hash, err := chainhash.NewHashFromStr(res.TxHash)
+		if err != nil {
+			return nil, err
+		}
+		outputs[*wire.NewOutPoint(hash, uint32(res.Index))] = res.TxOutput
+	}
+	return outputs, nil
+}
@@ -0,0 +1,100 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc_test
+
+import (
+	"github.com/btcsuite/btcd/wire"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+var _ = Describe("Retriever", func() {
+	var (
+		db        *postgres.DB
+		retriever *btc.CIDRetriever
+	)
+	BeforeEach(func() {
+		var err error
+		db, err = shared.SetupDB()
+		Expect(err).ToNot(HaveOccurred())
+		retriever = btc.NewCIDRetriever(db)
+		publisher := btc.NewIPLDPublisherAndIndexer(db)
+		_, err = publisher.Publish(mocks.MockConvertedPayload)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = publisher.Publish(mocks.MockSpendingConvertedPayload)
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		btc.TearDownDB(db)
+	})
+
+	Describe("Retrieve", func() {
+		for _, c := range spendingBlockTxFilterCases {
+			c := c
+			It("Retrieves the transactions matching "+c.description, func() {
+				cids, empty, err := retriever.Retrieve(subscriptionSettings(c.filter), mocks.MockSpendingBlockHeight)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(empty).To(BeFalse())
+				Expect(len(cids)).To(Equal(1))
+				cidWrapper, ok := cids[0].(*btc.CIDWrapper)
+				Expect(ok).To(BeTrue())
+				Expect(len(cidWrapper.Transactions)).To(Equal(len(c.expectedIndexes)))
+				for i, index := range c.expectedIndexes {
+					Expect(cidWrapper.Transactions[i].TxHash).To(Equal(mocks.MockSpendingBlock.Transactions[index].TxHash().String()))
+				}
+			})
+		}
+
+		It("Only retrieves the best chain", func() {
+			// the fork has as much work as the best chain, which was seen first
+			publisher := btc.NewIPLDPublisherAndIndexer(db)
+			for _, payload := range []btc.ConvertedPayload{mocks.MockForkConvertedPayload, mocks.MockForkChildConvertedPayload} {
+				_, err := publisher.Publish(payload)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			last, err := retriever.RetrieveLastBlockNumber()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(last).To(Equal(mocks.MockSpendingBlockHeight))
+			for height, block := range map[int64]wire.MsgBlock{
+				mocks.MockBlockHeight:         mocks.MockBlock,
+				mocks.MockSpendingBlockHeight: mocks.MockSpendingBlock,
+			} {
+				cids, _, err := retriever.Retrieve(subscriptionSettings(btc.TxFilter{}), height)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(len(cids)).To(Equal(1))
+				cidWrapper, ok := cids[0].(*btc.CIDWrapper)
+				Expect(ok).To(BeTrue())
+				Expect(cidWrapper.Header.BlockHash).To(Equal(block.Header.BlockHash().String()))
+			}
+
+			// the transaction included in both forks is retrieved from the best chain
+			tx, err := db.Beginx()
+			Expect(err).ToNot(HaveOccurred())
+			defer tx.Rollback()
+			txCID, err := retriever.RetrieveTxCIDByHash(tx, mocks.MockBlock.Transactions[1].TxHash())
+			Expect(err).ToNot(HaveOccurred())
+			header, err := retriever.RetrieveHeaderCIDByID(tx, txCID.HeaderID)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(header.BlockHash).To(Equal(mocks.MockBlock.Header.BlockHash().String()))
+		})
+	})
+})
@@ -28,13 +28,16 @@ import (
 
 // Cleaner satisfies the shared.Cleaner interface fo bitcoin
 type Cleaner struct {
-	db *postgres.DB
+	// Blockstores the IPLDs are deleted from, by type of data
+	Blockstores *shared.Blockstores
+	db          *postgres.DB
 }
 
 // NewCleaner returns a new Cleaner struct that satisfies the shared.Cleaner interface
 func NewCleaner(db *postgres.DB) *Cleaner {
 	return &Cleaner{
-		db: db,
+		Blockstores: shared.NewPostgresBlockstores(),
+		db:          db,
 	}
 }
 
@@ -158,13 +161,14 @@ func (c *Cleaner) cleanFull(tx *sqlx.Tx, rng [2]uint64) error {
 }
 
 func (c *Cleaner) cleanTransactionIPLDs(tx *sqlx.Tx, rng [2]uint64) error {
-	pgStr := `DELETE FROM public.blocks A
-			USING btc.transaction_cids B, btc.header_cids C
-			WHERE A.key = B.mh_key
-			AND B.header_id = C.id
+	pgStr := `SELECT B.mh_key FROM btc.transaction_cids B, btc.header_cids C
+			WHERE B.header_id = C.id
+			AND C.block_number BETWEEN $1 AND $2
+			UNION
+			SELECT B.witness_mh_key FROM btc.transaction_cids B, btc.header_cids C
+			WHERE B.header_id = C.id
 			AND C.block_number BETWEEN $1 AND $2`
-	_, err := tx.Exec(pgStr, rng[0], rng[1])
-	return err
+	return c.Blockstores.DeleteSelected(tx, shared.Transactions, pgStr, rng[0], rng[1])
 }
 
 func (c *Cleaner) cleanTransactionMetaData(tx *sqlx.Tx, rng [2]uint64) error {
@@ -177,12 +181,9 @@ func (c *Cleaner) cleanTransactionMetaData(tx *sqlx.Tx, rng [2]uint64) error {
 }
 
 func (c *Cleaner) cleanHeaderIPLDs(tx *sqlx.Tx, rng [2]uint64) error {
-	pgStr := `DELETE FROM public.blocks A
-			USING btc.header_cids B
-			WHERE A.key = B.mh_key
-			AND B.block_number BETWEEN $1 AND $2`
-	_, err := tx.Exec(pgStr, rng[0], rng[1])
-	return err
+	pgStr := `SELECT B.mh_key FROM btc.header_cids B
+			WHERE B.block_number BETWEEN $1 AND $2`
+	return c.Blockstores.DeleteSelected(tx, shared.Headers, pgStr, rng[0], rng[1])
 }
 
 func (c *Cleaner) cleanHeaderMetaData(tx *sqlx.Tx, rng [2]uint64) error {
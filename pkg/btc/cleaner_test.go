@@ -45,20 +45,24 @@ var (
 	}
 
 	// tx variables
-	tx1CID    = shared.TestCID([]byte("mockTx1CID"))
-	tx1MhKey  = shared.MultihashKeyFromCID(tx1CID)
-	tx2CID    = shared.TestCID([]byte("mockTx2CID"))
-	tx2MhKey  = shared.MultihashKeyFromCID(tx2CID)
-	tx1Hash   = crypto.Keccak256Hash([]byte{01, 01})
-	tx2Hash   = crypto.Keccak256Hash([]byte{01, 02})
-	opHash    = crypto.Keccak256Hash([]byte{02, 01})
-	txModels1 = []btc.TxModelWithInsAndOuts{
+	tx1CID          = shared.TestCID([]byte("mockTx1CID"))
+	tx1MhKey        = shared.MultihashKeyFromCID(tx1CID)
+	tx1WitnessCID   = shared.TestCID([]byte("mockTx1WitnessCID"))
+	tx1WitnessMhKey = shared.MultihashKeyFromCID(tx1WitnessCID)
+	tx2CID          = shared.TestCID([]byte("mockTx2CID"))
+	tx2MhKey        = shared.MultihashKeyFromCID(tx2CID)
+	tx1Hash         = crypto.Keccak256Hash([]byte{01, 01})
+	tx2Hash         = crypto.Keccak256Hash([]byte{01, 02})
+	opHash          = crypto.Keccak256Hash([]byte{02, 01})
+	txModels1       = []btc.TxModelWithInsAndOuts{
 		{
-			Index:  0,
-			CID:    tx1CID.String(),
-			MhKey:  tx1MhKey,
-			TxHash: tx1Hash.String(),
-			SegWit: true,
+			Index:        0,
+			CID:          tx1CID.String(),
+			MhKey:        tx1MhKey,
+			WitnessCID:   tx1WitnessCID.String(),
+			WitnessMhKey: tx1WitnessMhKey,
+			TxHash:       tx1Hash.String(),
+			SegWit:       true,
 			TxInputs: []btc.TxInput{
 				{
 					Index:                 0,
@@ -79,11 +83,13 @@ var (
 			},
 		},
 		{
-			Index:  1,
-			CID:    tx2CID.String(),
-			MhKey:  tx2MhKey,
-			TxHash: tx2Hash.String(),
-			SegWit: true,
+			Index:        1,
+			CID:          tx2CID.String(),
+			MhKey:        tx2MhKey,
+			WitnessCID:   tx2CID.String(),
+			WitnessMhKey: tx2MhKey,
+			TxHash:       tx2Hash.String(),
+			SegWit:       true,
 		},
 	}
 	mockCIDPayload1 = &btc.CIDPayload{
@@ -111,11 +117,13 @@ var (
 	tx3Hash   = crypto.Keccak256Hash([]byte{01, 03})
 	txModels2 = []btc.TxModelWithInsAndOuts{
 		{
-			Index:  0,
-			CID:    tx3CID.String(),
-			MhKey:  tx3MhKey,
-			TxHash: tx3Hash.String(),
-			SegWit: true,
+			Index:        0,
+			CID:          tx3CID.String(),
+			MhKey:        tx3MhKey,
+			WitnessCID:   tx3CID.String(),
+			WitnessMhKey: tx3MhKey,
+			TxHash:       tx3Hash.String(),
+			SegWit:       true,
 		},
 	}
 	mockCIDPayload2 = &btc.CIDPayload{
@@ -127,6 +135,7 @@ var (
 		headerMhKey1,
 		headerMhKey2,
 		tx1MhKey,
+		tx1WitnessMhKey,
 		tx2MhKey,
 		tx3MhKey,
 	}
@@ -176,7 +185,7 @@ var _ = Describe("Cleaner", func() {
 			err = tx.Commit()
 			Expect(err).ToNot(HaveOccurred())
 
-			Expect(startingIPFSBlocksCount).To(Equal(5))
+			Expect(startingIPFSBlocksCount).To(Equal(6))
 			Expect(startingTxCount).To(Equal(3))
 			Expect(startingHeaderCount).To(Equal(2))
 		})
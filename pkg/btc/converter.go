@@ -20,22 +20,35 @@ import (
 	"encoding/hex"
 	"fmt"
 
+	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
 
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
 )
 
 // PayloadConverter satisfies the PayloadConverter interface for bitcoin
 type PayloadConverter struct {
-	chainConfig *chaincfg.Params
+	chainConfig   *chaincfg.Params
+	retriever     *CIDRetriever
+	recentOutputs *outputCache
 }
 
 // NewPayloadConverter creates a pointer to a new PayloadConverter which satisfies the PayloadConverter interface
-func NewPayloadConverter(chainConfig *chaincfg.Params) *PayloadConverter {
-	return &PayloadConverter{
-		chainConfig: chainConfig,
+// The db is used to look up the outputs spent by the converted transactions; if it is nil only the outputs of recently
+// converted blocks are available
+func NewPayloadConverter(chainConfig *chaincfg.Params, db *postgres.DB) *PayloadConverter {
+	pc := &PayloadConverter{
+		chainConfig:   chainConfig,
+		recentOutputs: newOutputCache(),
 	}
+	if db != nil {
+		pc.retriever = NewCIDRetriever(db)
+	}
+	return pc
 }
 
 // Convert method is used to convert a bitcoin BlockPayload to an IPLDPayload
@@ -47,10 +60,12 @@ func (pc *PayloadConverter) Convert(payload shared.RawChainData) (shared.Convert
 	}
 	txMeta := make([]TxModelWithInsAndOuts, len(btcBlockPayload.Txs))
 	for i, tx := range btcBlockPayload.Txs {
+		weight := blockchain.GetTransactionWeight(tx)
 		txModel := TxModelWithInsAndOuts{
 			TxHash:    tx.Hash().String(),
 			Index:     int64(i),
 			SegWit:    tx.HasWitness(),
+			Weight:    &weight,
 			TxOutputs: make([]TxOutput, len(tx.MsgTx().TxOut)),
 			TxInputs:  make([]TxInput, len(tx.MsgTx().TxIn)),
 		}
@@ -84,13 +99,118 @@ func (pc *PayloadConverter) Convert(payload shared.RawChainData) (shared.Convert
 				ScriptClass:  uint8(scriptClass),
 				Addresses:    stringAddrs,
 			}
+			if len(out.PkScript) > 0 && out.PkScript[0] == txscript.OP_RETURN {
+				txModel.TxOutputs[i].OpReturnData = opReturnData(out.PkScript)
+			}
 		}
 		txMeta[i] = txModel
 	}
-	return ConvertedPayload{
+	if err := pc.resolvePreviousOutputs(btcBlockPayload.Txs, txMeta); err != nil {
+		return nil, err
+	}
+	convertedPayload := ConvertedPayload{
 		BlockPayload: btcBlockPayload,
 		TxMetaData:   txMeta,
-	}, nil
+		Subsidy:      blockchain.CalcBlockSubsidy(int32(btcBlockPayload.BlockHeight), pc.chainConfig),
+	}
+	var fees int64
+	feesKnown := true
+	for i, txModel := range txMeta {
+		if i == 0 {
+			for _, out := range txModel.TxOutputs {
+				convertedPayload.CoinbaseValue += out.Value
+			}
+			continue
+		}
+		setFee(&txMeta[i])
+		if txMeta[i].Fee == nil {
+			feesKnown = false
+			continue
+		}
+		fees += *txMeta[i].Fee
+	}
+	if feesKnown {
+		convertedPayload.Fees = &fees
+	}
+	return convertedPayload, nil
+}
+
+// resolvePreviousOutputs sets the outputs spent by the transactions' inputs, looking them up in the block itself,
+// the outputs of recently converted blocks, and then the database
+// The block's own outputs are cached for the blocks that follow it
+func (pc *PayloadConverter) resolvePreviousOutputs(txs []*btcutil.Tx, txMeta []TxModelWithInsAndOuts) error {
+	blockOutputs := make(map[wire.OutPoint]TxOutput)
+	for i, tx := range txs {
+		for _, out := range txMeta[i].TxOutputs {
+			blockOutputs[*wire.NewOutPoint(tx.Hash(), uint32(out.Index))] = out
+		}
+	}
+	pc.recentOutputs.add(blockOutputs)
+
+	unresolved := make([]wire.OutPoint, 0)
+	for i, tx := range txs {
+		// coinbase inputs don't spend anything
+		if i == 0 {
+			continue
+		}
+		for j, in := range tx.MsgTx().TxIn {
+			if out, ok := pc.recentOutputs.get(in.PreviousOutPoint); ok {
+				txMeta[i].TxInputs[j].PreviousOutput = &out
+				continue
+			}
+			unresolved = append(unresolved, in.PreviousOutPoint)
+		}
+	}
+	if len(unresolved) == 0 || pc.retriever == nil {
+		return nil
+	}
+	outputs, err := pc.retriever.RetrieveOutputs(unresolved)
+	if err != nil {
+		return err
+	}
+	for i, tx := range txs {
+		if i == 0 {
+			continue
+		}
+		for j, in := range tx.MsgTx().TxIn {
+			if out, ok := outputs[in.PreviousOutPoint]; ok {
+				txMeta[i].TxInputs[j].PreviousOutput = &out
+			}
+		}
+	}
+	return nil
+}
+
+// setFee sets the fee and fee rate of the transaction if the outputs spent by all of its inputs are known
+func setFee(txModel *TxModelWithInsAndOuts) {
+	var fee int64
+	for _, in := range txModel.TxInputs {
+		if in.PreviousOutput == nil {
+			return
+		}
+		fee += in.PreviousOutput.Value
+	}
+	for _, out := range txModel.TxOutputs {
+		fee -= out.Value
+	}
+	vsize := (*txModel.Weight + blockchain.WitnessScaleFactor - 1) / blockchain.WitnessScaleFactor
+	feeRate := float64(fee) / float64(vsize)
+	txModel.Fee = &fee
+	txModel.FeeRate = &feeRate
+}
+
+// opReturnData returns the concatenation of the data pushed by the OP_RETURN script
+// OP_RETURN outputs are unspendable so their scripts don't have to parse, in which case everything after the OP_RETURN is returned
+func opReturnData(pkScript []byte) []byte {
+	pushes, err := txscript.PushedData(pkScript)
+	if err != nil {
+		return pkScript[1:]
+	}
+	data := make([]byte, 0)
+	for _, push := range pushes {
+		data = append(data, push...)
+	}
+	return data
 }
 
 func convertBytesToHexArray(bytea [][]byte) []string {
@@ -28,7 +28,7 @@ import (
 var _ = Describe("Converter", func() {
 	Describe("Convert", func() {
 		It("Converts mock BlockPayloads into the expected IPLDPayloads", func() {
-			converter := btc.NewPayloadConverter(&chaincfg.MainNetParams)
+			converter := btc.NewPayloadConverter(&chaincfg.MainNetParams, nil)
 			payload, err := converter.Convert(mocks.MockBlockPayload)
 			Expect(err).ToNot(HaveOccurred())
 			convertedPayload, ok := payload.(btc.ConvertedPayload)
@@ -39,5 +39,37 @@ var _ = Describe("Converter", func() {
 			Expect(convertedPayload.Txs).To(Equal(mocks.MockTransactions))
 			Expect(convertedPayload.TxMetaData).To(Equal(mocks.MockTxsMetaData))
 		})
+
+		It("Resolves the outputs spent by previously converted blocks to compute fees", func() {
+			converter := btc.NewPayloadConverter(&chaincfg.MainNetParams, nil)
+			_, err := converter.Convert(mocks.MockBlockPayload)
+			Expect(err).ToNot(HaveOccurred())
+			payload, err := converter.Convert(mocks.MockSpendingBlockPayload)
+			Expect(err).ToNot(HaveOccurred())
+			convertedPayload, ok := payload.(btc.ConvertedPayload)
+			Expect(ok).To(BeTrue())
+			Expect(convertedPayload.Subsidy).To(Equal(int64(5000000000)))
+			Expect(convertedPayload.CoinbaseValue).To(Equal(int64(5000010000)))
+			Expect(*convertedPayload.Fees).To(Equal(int64(10000)))
+			Expect(convertedPayload.TxMetaData[0].Fee).To(BeNil())
+			spendingTx := convertedPayload.TxMetaData[1]
+			Expect(*spendingTx.TxInputs[0].PreviousOutput).To(Equal(mocks.MockTxsMetaData[1].TxOutputs[0]))
+			Expect(*spendingTx.Fee).To(Equal(int64(10000)))
+			vsize := (*spendingTx.Weight + 3) / 4
+			Expect(*spendingTx.FeeRate).To(Equal(float64(10000) / float64(vsize)))
+			Expect(spendingTx.TxOutputs[0].OpReturnData).To(BeNil())
+			Expect(spendingTx.TxOutputs[1].OpReturnData).To(Equal(mocks.MockOpReturnData))
+		})
+
+		It("Leaves the fees unknown when the spent outputs can't be resolved", func() {
+			converter := btc.NewPayloadConverter(&chaincfg.MainNetParams, nil)
+			payload, err := converter.Convert(mocks.MockSpendingBlockPayload)
+			Expect(err).ToNot(HaveOccurred())
+			convertedPayload, ok := payload.(btc.ConvertedPayload)
+			Expect(ok).To(BeTrue())
+			Expect(convertedPayload.Fees).To(BeNil())
+			Expect(convertedPayload.TxMetaData[1].TxInputs[0].PreviousOutput).To(BeNil())
+			Expect(convertedPayload.TxMetaData[1].Fee).To(BeNil())
+		})
 	})
 })
@@ -29,11 +29,15 @@ import (
 )
 
 // ResponseFilterer satisfies the ResponseFilterer interface for bitcoin
-type ResponseFilterer struct{}
+type ResponseFilterer struct {
+	chain *bestChain
+}
 
 // NewResponseFilterer creates a new Filterer satisfying the ResponseFilterer interface
 func NewResponseFilterer() *ResponseFilterer {
-	return &ResponseFilterer{}
+	return &ResponseFilterer{
+		chain: newBestChain(),
+	}
 }
 
 // Filter is used to filter through btc data to extract and package requested data into a Payload
@@ -47,6 +51,10 @@ func (s *ResponseFilterer) Filter(filter shared.SubscriptionSettings, payload sh
 		return IPLDs{}, fmt.Errorf("btc filterer expected payload type %T got %T", ConvertedPayload{}, payload)
 	}
 	height := int64(btcPayload.BlockPayload.BlockHeight)
+	if !s.chain.connect(btcPayload.Header, height) {
+		// blocks on a fork with no more work than the best chain are not served
+		return IPLDs{BlockNumber: big.NewInt(height)}, nil
+	}
 	if checkRange(btcFilters.Start.Int64(), btcFilters.End.Int64(), height) {
 		response := new(IPLDs)
 		if err := s.filterHeaders(btcFilters.HeaderFilter, response, btcPayload); err != nil {
@@ -92,18 +100,19 @@ func (s *ResponseFilterer) filterTransactions(trxFilter TxFilter, response *IPLD
 		response.Transactions = make([]ipfs.BlockModel, 0, len(payload.TxMetaData))
 		for i, txMeta := range payload.TxMetaData {
 			if checkTransaction(txMeta, trxFilter) {
-				trxBuffer := new(bytes.Buffer)
-				if err := payload.Txs[i].MsgTx().Serialize(trxBuffer); err != nil {
-					return err
+				var txNode *ipld.BtcTx
+				var err error
+				if trxFilter.Witness {
+					txNode, err = ipld.NewBtcWitnessTx(payload.Txs[i].MsgTx())
+				} else {
+					txNode, err = ipld.NewBtcTx(payload.Txs[i].MsgTx())
 				}
-				data := trxBuffer.Bytes()
-				cid, err := ipld.RawdataToCid(ipld.MBitcoinTx, data, multihash.DBL_SHA2_256)
 				if err != nil {
 					return err
 				}
 				response.Transactions = append(response.Transactions, ipfs.BlockModel{
-					Data: data,
-					CID:  cid.String(),
+					Data: txNode.RawData(),
+					CID:  txNode.Cid().String(),
 				})
 			}
 		}
@@ -155,5 +164,39 @@ func checkTransaction(txMeta TxModelWithInsAndOuts, txFilter TxFilter) bool {
 			}
 		}
 	}
-	return passesSegwitFilter && passesMultiSigFilter && passesWitnessFilter && passesAddressFilter && passesIndexFilter && passesPkScriptClassFilter
+	// inputs whose spent outputs couldn't be resolved can't match the spending address filter
+	passesSpendingAddressFilter := len(txFilter.SpendingAddresses) == 0
+	for _, wantedAddress := range txFilter.SpendingAddresses {
+		for _, in := range txMeta.TxInputs {
+			if in.PreviousOutput == nil {
+				continue
+			}
+			for _, actualAddress := range in.PreviousOutput.Addresses {
+				if wantedAddress == actualAddress {
+					passesSpendingAddressFilter = true
+				}
+			}
+		}
+	}
+	passesOpReturnFilter := len(txFilter.OpReturnPrefixes) == 0
+	// the prefixes are validated when the subscription is created, one that fails to decode can't match anything
+	prefixes, _ := decodeHexPrefixes(txFilter.OpReturnPrefixes)
+	for _, wantedPrefix := range prefixes {
+		for _, out := range txMeta.TxOutputs {
+			if out.OpReturnData != nil && bytes.HasPrefix(out.OpReturnData, wantedPrefix) {
+				passesOpReturnFilter = true
+			}
+		}
+	}
+	var value int64
+	for _, out := range txMeta.TxOutputs {
+		value += out.Value
+	}
+	passesValueFilter := (txFilter.MinValue <= 0 || value >= txFilter.MinValue) && (txFilter.MaxValue <= 0 || value <= txFilter.MaxValue)
+	// txs whose fees aren't known, because the outputs they spend couldn't be resolved, don't pass the fee filters
+	passesFeeFilter := txFilter.MinFee <= 0 || (txMeta.Fee != nil && *txMeta.Fee >= txFilter.MinFee)
+	passesFeeRateFilter := txFilter.MinFeeRate <= 0 || (txMeta.FeeRate != nil && *txMeta.FeeRate >= txFilter.MinFeeRate)
+	return passesSegwitFilter && passesMultiSigFilter && passesWitnessFilter && passesAddressFilter && passesIndexFilter &&
+		passesPkScriptClassFilter && passesSpendingAddressFilter && passesOpReturnFilter && passesValueFilter &&
+		passesFeeFilter && passesFeeRateFilter
 }
@@ -0,0 +1,170 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc/mocks"
+)
+
+// txFilterCase is a tx filter along with the indexes of the MockSpendingBlock transactions it should match
+// the cases are shared by the filterer and retriever tests so that live and historical subscriptions are checked against each other
+type txFilterCase struct {
+	description     string
+	filter          btc.TxFilter
+	expectedIndexes []int64
+}
+
+var spendingBlockTxFilterCases = []txFilterCase{
+	{
+		description:     "an open filter",
+		filter:          btc.TxFilter{},
+		expectedIndexes: []int64{0, 1},
+	},
+	{
+		description:     "a spending address filter",
+		filter:          btc.TxFilter{SpendingAddresses: []string{mocks.MockTxsMetaData[1].TxOutputs[0].Addresses[0]}},
+		expectedIndexes: []int64{1},
+	},
+	{
+		description:     "a spending address filter for an address that isn't spent from",
+		filter:          btc.TxFilter{SpendingAddresses: []string{mocks.MockTxsMetaData[2].TxOutputs[0].Addresses[0]}},
+		expectedIndexes: []int64{},
+	},
+	{
+		description:     "an OP_RETURN prefix filter",
+		filter:          btc.TxFilter{OpReturnPrefixes: []string{hex.EncodeToString(mocks.MockOpReturnData[:5])}},
+		expectedIndexes: []int64{1},
+	},
+	{
+		description:     "an OP_RETURN prefix filter that doesn't match the start of the data",
+		filter:          btc.TxFilter{OpReturnPrefixes: []string{"0x" + hex.EncodeToString(mocks.MockOpReturnData[6:])}},
+		expectedIndexes: []int64{},
+	},
+	{
+		description:     "a minimum value filter",
+		filter:          btc.TxFilter{MinValue: 555990001},
+		expectedIndexes: []int64{0},
+	},
+	{
+		description:     "a value range filter",
+		filter:          btc.TxFilter{MinValue: 555990000, MaxValue: 555990000},
+		expectedIndexes: []int64{1},
+	},
+	{
+		description:     "a minimum fee filter",
+		filter:          btc.TxFilter{MinFee: 10000},
+		expectedIndexes: []int64{1},
+	},
+	{
+		description:     "an output address and spending address filter",
+		filter:          btc.TxFilter{Addresses: []string{mocks.MockTxsMetaData[2].TxOutputs[0].Addresses[0]}, SpendingAddresses: []string{mocks.MockTxsMetaData[1].TxOutputs[0].Addresses[0]}},
+		expectedIndexes: []int64{1},
+	},
+}
+
+func subscriptionSettings(txFilter btc.TxFilter) *btc.SubscriptionSettings {
+	return &btc.SubscriptionSettings{
+		Start:    big.NewInt(0),
+		End:      big.NewInt(0),
+		TxFilter: txFilter,
+	}
+}
+
+var _ = Describe("Filterer", func() {
+	Describe("Filter", func() {
+		for _, c := range spendingBlockTxFilterCases {
+			c := c
+			It("Returns the transactions matching "+c.description, func() {
+				payload, err := btc.NewResponseFilterer().Filter(subscriptionSettings(c.filter), mocks.MockSpendingConvertedPayload)
+				Expect(err).ToNot(HaveOccurred())
+				iplds, ok := payload.(btc.IPLDs)
+				Expect(ok).To(BeTrue())
+				Expect(iplds.BlockNumber.Int64()).To(Equal(mocks.MockSpendingBlockHeight))
+				Expect(len(iplds.Transactions)).To(Equal(len(c.expectedIndexes)))
+				for i, index := range c.expectedIndexes {
+					var buf bytes.Buffer
+					Expect(mocks.MockSpendingBlock.Transactions[index].Serialize(&buf)).To(Succeed())
+					Expect(iplds.Transactions[i].Data).To(Equal(buf.Bytes()))
+				}
+			})
+		}
+
+		It("Doesn't match spending addresses or fees of inputs that couldn't be resolved", func() {
+			for _, txFilter := range []btc.TxFilter{
+				{SpendingAddresses: mocks.MockTxsMetaData[0].TxOutputs[0].Addresses},
+				{MinFee: 1},
+			} {
+				payload, err := btc.NewResponseFilterer().Filter(subscriptionSettings(txFilter), mocks.MockConvertedPayload)
+				Expect(err).ToNot(HaveOccurred())
+				iplds, ok := payload.(btc.IPLDs)
+				Expect(ok).To(BeTrue())
+				Expect(iplds.Transactions).To(BeEmpty())
+			}
+		})
+
+		It("Returns segwit transactions in their stripped form unless the witness form is requested", func() {
+			for _, witness := range []bool{false, true} {
+				payload, err := btc.NewResponseFilterer().Filter(subscriptionSettings(btc.TxFilter{Witness: witness}), mocks.MockSegwitConvertedPayload)
+				Expect(err).ToNot(HaveOccurred())
+				iplds, ok := payload.(btc.IPLDs)
+				Expect(ok).To(BeTrue())
+				Expect(len(iplds.Transactions)).To(Equal(2))
+				for i, msgTx := range mocks.MockSegwitBlock.Transactions {
+					var buf bytes.Buffer
+					if witness {
+						Expect(msgTx.Serialize(&buf)).To(Succeed())
+					} else {
+						Expect(msgTx.SerializeNoWitness(&buf)).To(Succeed())
+					}
+					Expect(iplds.Transactions[i].Data).To(Equal(buf.Bytes()))
+				}
+			}
+		})
+
+		It("Only returns blocks that extend the best chain", func() {
+			filterer := btc.NewResponseFilterer()
+			settings := subscriptionSettings(btc.TxFilter{})
+			for _, c := range []struct {
+				payload btc.ConvertedPayload
+				served  bool
+			}{
+				{mocks.MockConvertedPayload, true},
+				// same work as MockBlock, which was seen first
+				{mocks.MockForkConvertedPayload, false},
+				{mocks.MockForkChildConvertedPayload, true},
+				// a block gets the same answer every time it is filtered
+				{mocks.MockForkConvertedPayload, false},
+			} {
+				payload, err := filterer.Filter(settings, c.payload)
+				Expect(err).ToNot(HaveOccurred())
+				iplds, ok := payload.(btc.IPLDs)
+				Expect(ok).To(BeTrue())
+				Expect(iplds.BlockNumber.Int64()).To(Equal(c.payload.Height()))
+				Expect(iplds.Header.Data != nil).To(Equal(c.served))
+				Expect(len(iplds.Transactions) > 0).To(Equal(c.served))
+			}
+		})
+	})
+})
@@ -0,0 +1,67 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc
+
+import (
+	"fmt"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+const (
+	headersFrom = `btc.header_cids
+			WHERE header_cids.block_number BETWEEN $1 AND $2`
+	transactionsFrom = `btc.transaction_cids
+			INNER JOIN btc.header_cids ON (transaction_cids.header_id = header_cids.id)
+			WHERE header_cids.block_number BETWEEN $1 AND $2`
+)
+
+// indexedIPLDsPgStr returns the selection of the columns from the index tables of the type of data
+// Transactions are selected in both of their forms, which are the same IPLD for transactions without witness data
+// The full type covers the IPLDs of every type of data
+func indexedIPLDsPgStr(t shared.DataType, columns func(table, prefix string) string) (string, error) {
+	headers := `SELECT ` + columns("header_cids", "") + ` FROM ` + headersFrom
+	transactions := `SELECT ` + columns("transaction_cids", "") + ` FROM ` + transactionsFrom +
+		"\n\t\tUNION SELECT " + columns("transaction_cids", "witness_") + ` FROM ` + transactionsFrom
+	switch t {
+	case shared.Full:
+		return headers + "\n\t\tUNION ALL " + transactions, nil
+	case shared.Headers:
+		return headers, nil
+	case shared.Transactions:
+		return transactions, nil
+	default:
+		return "", fmt.Errorf("btc indexed iplds query unrecognized type: %s", t.String())
+	}
+}
+
+// MhKeysPgStr returns the query for the mhkeys of the indexed IPLDs of the type of data at block heights between $1 and $2
+// Merkle and witness merkle nodes, and witness commitments, are published but not indexed so they are not covered
+func MhKeysPgStr(t shared.DataType) (string, error) {
+	return indexedIPLDsPgStr(t, func(table, prefix string) string {
+		return table + "." + prefix + "mh_key"
+	})
+}
+
+// CIDsPgStr returns the query for the block numbers, cids and mhkeys of the indexed IPLDs of the type of data at block
+// heights between $1 and $2, as the block_number, cid and mh_key columns
+// Merkle and witness merkle nodes, and witness commitments, are published but not indexed so they are not covered
+func CIDsPgStr(t shared.DataType) (string, error) {
+	return indexedIPLDsPgStr(t, func(table, prefix string) string {
+		return `header_cids.block_number, ` + table + `.` + prefix + `cid AS cid, ` + table + `.` + prefix + `mh_key AS mh_key`
+	})
+}
@@ -17,7 +17,9 @@
 package btc
 
 import (
+	"database/sql"
 	"fmt"
+	"math/big"
 
 	"github.com/sirupsen/logrus"
 
@@ -68,17 +70,23 @@ func (in *CIDIndexer) Index(cids shared.CIDsForIndexing) error {
 	err = in.indexTransactionCIDs(tx, cidWrapper.TransactionCIDs, headerID)
 	if err != nil {
 		logrus.Error("btc indexer error when indexing transactions")
+		return err
+	}
+	err = in.connectBlock(tx, headerID)
+	if err != nil {
+		logrus.Error("btc indexer error when connecting block")
 	}
 	return err
 }
 
 func (in *CIDIndexer) indexHeaderCID(tx *sqlx.Tx, header HeaderModel) (int64, error) {
 	var headerID int64
-	err := tx.QueryRowx(`INSERT INTO btc.header_cids (block_number, block_hash, parent_hash, cid, timestamp, bits, node_id, mh_key, times_validated)
-							VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-							ON CONFLICT (block_number, block_hash) DO UPDATE SET (parent_hash, cid, timestamp, bits, node_id, mh_key, times_validated) = ($3, $4, $5, $6, $7, $8, btc.header_cids.times_validated + 1)
+	err := tx.QueryRowx(`INSERT INTO btc.header_cids (block_number, block_hash, parent_hash, cid, timestamp, bits, node_id, mh_key, times_validated, coinbase_value, subsidy, fees)
+							VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+							ON CONFLICT (block_number, block_hash) DO UPDATE SET (parent_hash, cid, timestamp, bits, node_id, mh_key, times_validated, coinbase_value, subsidy, fees) = ($3, $4, $5, $6, $7, $8, btc.header_cids.times_validated + 1, $10, $11, COALESCE($12, btc.header_cids.fees))
 							RETURNING id`,
-		header.BlockNumber, header.BlockHash, header.ParentHash, header.CID, header.Timestamp, header.Bits, in.db.NodeID, header.MhKey, 1).Scan(&headerID)
+		header.BlockNumber, header.BlockHash, header.ParentHash, header.CID, header.Timestamp, header.Bits, in.db.NodeID, header.MhKey, 1,
+		header.CoinbaseValue, header.Subsidy, header.Fees).Scan(&headerID)
 	return headerID, err
 }
 
@@ -107,11 +115,12 @@ func (in *CIDIndexer) indexTransactionCIDs(tx *sqlx.Tx, transactions []TxModelWi
 
 func (in *CIDIndexer) indexTransactionCID(tx *sqlx.Tx, transaction TxModelWithInsAndOuts, headerID int64) (int64, error) {
 	var txID int64
-	err := tx.QueryRowx(`INSERT INTO btc.transaction_cids (header_id, tx_hash, index, cid, segwit, witness_hash, mh_key)
-							VALUES ($1, $2, $3, $4, $5, $6, $7)
-							ON CONFLICT (tx_hash) DO UPDATE SET (header_id, index, cid, segwit, witness_hash, mh_key) = ($1, $3, $4, $5, $6, $7)
+	err := tx.QueryRowx(`INSERT INTO btc.transaction_cids (header_id, tx_hash, index, cid, segwit, witness_hash, mh_key, weight, fee, fee_rate, witness_cid, witness_mh_key)
+							VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+							ON CONFLICT (header_id, tx_hash) DO UPDATE SET (index, cid, segwit, witness_hash, mh_key, weight, fee, fee_rate, witness_cid, witness_mh_key) = ($3, $4, $5, $6, $7, $8, COALESCE($9, btc.transaction_cids.fee), COALESCE($10, btc.transaction_cids.fee_rate), $11, $12)
 							RETURNING id`,
-		headerID, transaction.TxHash, transaction.Index, transaction.CID, transaction.SegWit, transaction.WitnessHash, transaction.MhKey).Scan(&txID)
+		headerID, transaction.TxHash, transaction.Index, transaction.CID, transaction.SegWit, transaction.WitnessHash, transaction.MhKey,
+		transaction.Weight, transaction.Fee, transaction.FeeRate, transaction.WitnessCID, transaction.WitnessMhKey).Scan(&txID)
 	return txID, err
 }
 
@@ -124,9 +133,318 @@ func (in *CIDIndexer) indexTxInput(tx *sqlx.Tx, txInput TxInput, txID int64) err
 }
 
 func (in *CIDIndexer) indexTxOutput(tx *sqlx.Tx, txOuput TxOutput, txID int64) error {
-	_, err := tx.Exec(`INSERT INTO btc.tx_outputs (tx_id, index, value, pk_script, script_class, addresses, required_sigs)
-							VALUES ($1, $2, $3, $4, $5, $6, $7)
-							ON CONFLICT (tx_id, index) DO UPDATE SET (value, pk_script, script_class, addresses, required_sigs) = ($3, $4, $5, $6, $7)`,
-		txID, txOuput.Index, txOuput.Value, txOuput.PkScript, txOuput.ScriptClass, txOuput.Addresses, txOuput.RequiredSigs)
+	_, err := tx.Exec(`INSERT INTO btc.tx_outputs (tx_id, index, value, pk_script, script_class, addresses, required_sigs, op_return_data)
+							VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+							ON CONFLICT (tx_id, index) DO UPDATE SET (value, pk_script, script_class, addresses, required_sigs, op_return_data) = ($3, $4, $5, $6, $7, $8)`,
+		txID, txOuput.Index, txOuput.Value, txOuput.PkScript, txOuput.ScriptClass, txOuput.Addresses, txOuput.RequiredSigs, txOuput.OpReturnData)
+	return err
+}
+
+// blockWork is the work of a header_cids row's block, 2^256 / (target + 1) where the target is decoded from its compact bits
+const blockWork = `div(power(2::NUMERIC, 256), (header_cids.bits & 8388607) * power(256::NUMERIC, (header_cids.bits >> 24) - 3) + 1)`
+
+// chainLockID is the advisory lock held while connecting blocks to the indexed chain
+const chainLockID = 0x627463 // "btc"
+
+// connectBlock connects the header to the indexed chain and links the inputs and outputs of the blocks it connected
+// to the outputs and inputs they spend and are spent by
+// Blocks can be indexed concurrently and in any order, so connecting is serialized with an advisory lock held until
+// the tx commits; whichever of a parent and child, or a spending and spent block, is connected second then sees the
+// other's committed rows
+func (in *CIDIndexer) connectBlock(tx *sqlx.Tx, headerID int64) error {
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, chainLockID); err != nil {
+		return err
+	}
+	connected, disconnected, err := in.updateBestChain(tx, headerID)
+	if err != nil {
+		return err
+	}
+	if len(disconnected) > 0 {
+		// the outputs of the disconnected blocks are relinked to their copies on the best chain, if there are any
+		unlinked := make([]int64, 0)
+		err = tx.Select(&unlinked, `UPDATE btc.tx_inputs
+							SET output_id = NULL
+							FROM btc.tx_outputs, btc.transaction_cids
+							WHERE tx_inputs.output_id = tx_outputs.id
+							AND tx_outputs.tx_id = transaction_cids.id
+							AND transaction_cids.header_id = ANY($1::INTEGER[])
+							RETURNING tx_inputs.tx_id`, pq.Array(disconnected))
+		if err != nil {
+			return err
+		}
+		// and the fees of the transactions spending them, and of their blocks, are unknown until they are relinked
+		_, err = tx.Exec(`WITH unresolved AS (
+								UPDATE btc.transaction_cids
+								SET (fee, fee_rate) = (NULL, NULL)
+								WHERE id = ANY($1::INTEGER[])
+								RETURNING header_id
+							)
+							UPDATE btc.header_cids
+							SET fees = NULL
+							WHERE id IN (SELECT header_id FROM unresolved)`, pq.Array(unlinked))
+		if err != nil {
+			return err
+		}
+	}
+	if err := in.linkSpentOutputs(tx, connected); err != nil {
+		return err
+	}
+	return in.resolveFees(tx, connected)
+}
+
+// updateBestChain sets the chain work of a newly indexed header and orphans whichever of its branch and the
+// competing branch of the best chain has less work, the branch that was indexed first wins a tie
+// Only the blocks built on the same parent as the branch compete with it, so a block whose parent hasn't been indexed
+// yet, e.g. while a gap below it is backfilled, is not compared with the blocks of the rest of the chain; its chain work
+// is left NULL until its parent is connected, which connects it and the blocks built on it in one pass
+// It returns the ids of the headers whose blocks need their inputs and outputs linked and of the headers that were
+// disconnected from the best chain
+func (in *CIDIndexer) updateBestChain(tx *sqlx.Tx, headerID int64) ([]int64, []int64, error) {
+	var header struct {
+		BlockHash  string         `db:"block_hash"`
+		ParentHash string         `db:"parent_hash"`
+		ChainWork  sql.NullString `db:"chain_work"`
+	}
+	err := tx.Get(&header, `SELECT block_hash, parent_hash, chain_work FROM btc.header_cids WHERE id = $1`, headerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if header.ChainWork.Valid {
+		// the block was connected when it was first indexed
+		return []int64{headerID}, nil, nil
+	}
+
+	// the chain work of the blocks built on a block whose parent hasn't been indexed is counted from its own work
+	var parentWork string
+	err = tx.Get(&parentWork, `SELECT COALESCE(chain_work, `+blockWork+`) FROM btc.header_cids WHERE block_hash = $1`, header.ParentHash)
+	var chainWork string
+	switch err {
+	case nil:
+		err = tx.Get(&chainWork, `UPDATE btc.header_cids SET chain_work = $2::NUMERIC + `+blockWork+`
+							WHERE id = $1
+							RETURNING chain_work`, headerID, parentWork)
+	case sql.ErrNoRows:
+		err = tx.Get(&chainWork, `SELECT `+blockWork+` FROM btc.header_cids WHERE id = $1`, headerID)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	tipWork, ok := new(big.Int).SetString(chainWork, 10)
+	if !ok {
+		return nil, nil, fmt.Errorf("btc indexer could not parse chain work %s", chainWork)
+	}
+	// the blocks built on this one were indexed without it, so their chain work is recomputed
+	descendants := make([]struct {
+		ID        int64  `db:"id"`
+		ChainWork string `db:"chain_work"`
+	}, 0)
+	err = tx.Select(&descendants, `WITH RECURSIVE descendants AS (
+								SELECT id, block_hash, $2::NUMERIC + `+blockWork+` AS chain_work
+								FROM btc.header_cids WHERE parent_hash = $1
+								UNION
+								SELECT header_cids.id, header_cids.block_hash, descendants.chain_work + `+blockWork+`
+								FROM btc.header_cids, descendants
+								WHERE header_cids.parent_hash = descendants.block_hash
+							)
+							UPDATE btc.header_cids
+							SET chain_work = descendants.chain_work
+							FROM descendants
+							WHERE header_cids.id = descendants.id
+							RETURNING header_cids.id, header_cids.chain_work`, header.BlockHash, chainWork)
+	if err != nil {
+		return nil, nil, err
+	}
+	ownBranch := []int64{headerID}
+	tipID := headerID
+	for _, descendant := range descendants {
+		work, ok := new(big.Int).SetString(descendant.ChainWork, 10)
+		if !ok {
+			return nil, nil, fmt.Errorf("btc indexer could not parse chain work %s", descendant.ChainWork)
+		}
+		// the descendant indexed first wins a tie
+		if cmp := work.Cmp(tipWork); cmp > 0 || (cmp == 0 && tipID != headerID && descendant.ID < tipID) {
+			tipWork = work
+			tipID = descendant.ID
+		}
+		ownBranch = append(ownBranch, descendant.ID)
+	}
+
+	// the branch runs back from the block to the last of its ancestors that is on the best chain
+	branch := make([]struct {
+		ID          int64  `db:"id"`
+		BlockNumber int64  `db:"block_number"`
+		ParentHash  string `db:"parent_hash"`
+	}, 0)
+	err = tx.Select(&branch, `WITH RECURSIVE branch AS (
+								SELECT id, block_number, parent_hash FROM btc.header_cids WHERE id = $1
+								UNION
+								SELECT header_cids.id, header_cids.block_number, header_cids.parent_hash FROM btc.header_cids, branch
+								WHERE header_cids.block_hash = branch.parent_hash
+								AND header_cids.orphaned
+							)
+							SELECT id, block_number, parent_hash FROM branch`, headerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	forkHeight := branch[0].BlockNumber
+	forkHash := branch[0].ParentHash
+	branchIDs := make([]int64, 0, len(branch)-1)
+	for _, ancestor := range branch[1:] {
+		if ancestor.BlockNumber < forkHeight {
+			forkHeight = ancestor.BlockNumber
+			forkHash = ancestor.ParentHash
+		}
+		branchIDs = append(branchIDs, ancestor.ID)
+	}
+	forkHeight--
+	ownBranch = append(ownBranch, branchIDs...)
+
+	// compare it with the blocks of the best chain built on the same parent, the parent need not be indexed
+	competing := make([]struct {
+		ID        int64  `db:"id"`
+		ChainWork string `db:"chain_work"`
+	}, 0)
+	err = tx.Select(&competing, `WITH RECURSIVE competing AS (
+								SELECT id, block_hash, COALESCE(chain_work, `+blockWork+`) AS chain_work
+								FROM btc.header_cids
+								WHERE parent_hash = $1
+								AND NOT orphaned
+								AND id <> ALL($2::INTEGER[])
+								UNION
+								SELECT header_cids.id, header_cids.block_hash, header_cids.chain_work
+								FROM btc.header_cids, competing
+								WHERE header_cids.parent_hash = competing.block_hash
+								AND NOT header_cids.orphaned
+							)
+							SELECT id, chain_work FROM competing`, forkHash, pq.Array(ownBranch))
+	if err != nil {
+		return nil, nil, err
+	}
+	disconnected := make([]int64, 0, len(competing))
+	for _, block := range competing {
+		work, ok := new(big.Int).SetString(block.ChainWork, 10)
+		if !ok {
+			return nil, nil, fmt.Errorf("btc indexer could not parse chain work %s", block.ChainWork)
+		}
+		if tipWork.Cmp(work) <= 0 {
+			_, err = tx.Exec(`UPDATE btc.header_cids SET orphaned = true WHERE id = ANY($1::INTEGER[])`,
+				pq.Array(ownBranch[:len(descendants)+1]))
+			return []int64{headerID}, nil, err
+		}
+		disconnected = append(disconnected, block.ID)
+	}
+	if len(disconnected) > 0 {
+		if _, err := tx.Exec(`UPDATE btc.header_cids SET orphaned = true WHERE id = ANY($1::INTEGER[])`, pq.Array(disconnected)); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// the blocks built on this one may have been orphaned while it was missing, so the heaviest path through them is
+	// connected and the rest of them are orphaned
+	descendantIDs := ownBranch[1 : len(descendants)+1]
+	path := make([]int64, 0)
+	if tipID != headerID {
+		err = tx.Select(&path, `WITH RECURSIVE path AS (
+								SELECT id, parent_hash FROM btc.header_cids WHERE id = $1
+								UNION
+								SELECT header_cids.id, header_cids.parent_hash FROM btc.header_cids, path
+								WHERE header_cids.block_hash = path.parent_hash
+								AND header_cids.id = ANY($2::INTEGER[])
+							)
+							SELECT id FROM path`, tipID, pq.Array(descendantIDs))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(descendantIDs) > len(path) {
+		offPath := make([]int64, 0)
+		err = tx.Select(&offPath, `UPDATE btc.header_cids
+							SET orphaned = true
+							WHERE id = ANY($1::INTEGER[])
+							AND id <> ALL($2::INTEGER[])
+							AND NOT orphaned
+							RETURNING id`, pq.Array(descendantIDs), pq.Array(path))
+		if err != nil {
+			return nil, nil, err
+		}
+		disconnected = append(disconnected, offPath...)
+	}
+	connected := append(append([]int64{headerID}, branchIDs...), path...)
+	if len(connected) > 1 {
+		if _, err := tx.Exec(`UPDATE btc.header_cids SET orphaned = false WHERE id = ANY($1::INTEGER[])`, pq.Array(connected[1:])); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(disconnected) > 0 {
+		logrus.Infof("btc reorg above block %d: %d blocks disconnected, %d blocks connected", forkHeight, len(disconnected), len(connected))
+	}
+	return connected, disconnected, nil
+}
+
+// linkSpentOutputs links the inputs of the headers' transactions to the best chain outputs they spend,
+// and the inputs that spend the outputs of those of the headers that are on the best chain to those outputs
+func (in *CIDIndexer) linkSpentOutputs(tx *sqlx.Tx, headerIDs []int64) error {
+	_, err := tx.Exec(`UPDATE btc.tx_inputs
+							SET output_id = tx_outputs.id
+							FROM btc.transaction_cids AS spending, btc.transaction_cids AS spent, btc.header_cids, btc.tx_outputs
+							WHERE tx_inputs.tx_id = spending.id
+							AND spending.header_id = ANY($1::INTEGER[])
+							AND spent.tx_hash = tx_inputs.outpoint_tx_hash
+							AND spent.header_id = header_cids.id
+							AND NOT header_cids.orphaned
+							AND tx_outputs.tx_id = spent.id
+							AND tx_outputs.index = tx_inputs.outpoint_index`, pq.Array(headerIDs))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE btc.tx_inputs
+							SET output_id = tx_outputs.id
+							FROM btc.transaction_cids AS spent, btc.header_cids, btc.tx_outputs
+							WHERE spent.header_id = header_cids.id
+							AND header_cids.id = ANY($1::INTEGER[])
+							AND NOT header_cids.orphaned
+							AND tx_outputs.tx_id = spent.id
+							AND tx_inputs.outpoint_tx_hash = spent.tx_hash
+							AND tx_inputs.outpoint_index = tx_outputs.index
+							AND tx_inputs.output_id IS NULL`, pq.Array(headerIDs))
+	return err
+}
+
+// resolveFees sets the fees of the headers' transactions, and of the transactions spending the headers' outputs,
+// whose inputs have now all been linked to the outputs they spend
+// The total fees of the blocks those transactions are in are then set once all of their transactions' fees are known
+func (in *CIDIndexer) resolveFees(tx *sqlx.Tx, headerIDs []int64) error {
+	resolved := make([]int64, 0)
+	err := tx.Select(&resolved, `UPDATE btc.transaction_cids
+							SET (fee, fee_rate) = (fees.fee, fees.fee::NUMERIC / ((transaction_cids.weight + 3) / 4))
+							FROM (SELECT tx_inputs.tx_id, SUM(tx_outputs.value) -
+									(SELECT SUM(value) FROM btc.tx_outputs WHERE tx_outputs.tx_id = tx_inputs.tx_id) AS fee
+								FROM btc.tx_inputs
+								LEFT JOIN btc.tx_outputs ON (tx_inputs.output_id = tx_outputs.id)
+								WHERE tx_inputs.tx_id IN (SELECT id FROM btc.transaction_cids WHERE header_id = ANY($1::INTEGER[])
+									UNION
+									SELECT tx_inputs.tx_id FROM btc.tx_inputs, btc.tx_outputs, btc.transaction_cids
+									WHERE tx_inputs.output_id = tx_outputs.id
+									AND tx_outputs.tx_id = transaction_cids.id
+									AND transaction_cids.header_id = ANY($1::INTEGER[]))
+								GROUP BY tx_inputs.tx_id
+								HAVING COUNT(tx_outputs.id) = COUNT(*)) AS fees
+							WHERE transaction_cids.id = fees.tx_id
+							AND transaction_cids.index > 0
+							AND (transaction_cids.fee IS NULL OR transaction_cids.fee_rate IS NULL)
+							RETURNING transaction_cids.header_id`, pq.Array(headerIDs))
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`UPDATE btc.header_cids
+							SET fees = (SELECT COALESCE(SUM(fee), 0) FROM btc.transaction_cids
+								WHERE transaction_cids.header_id = header_cids.id
+								AND transaction_cids.index > 0)
+							WHERE header_cids.id = ANY($1::INTEGER[])
+							AND header_cids.fees IS NULL
+							AND NOT EXISTS (SELECT 1 FROM btc.transaction_cids
+								WHERE transaction_cids.header_id = header_cids.id
+								AND transaction_cids.index > 0
+								AND transaction_cids.fee IS NULL)`, pq.Array(append(resolved, headerIDs...)))
 	return err
 }
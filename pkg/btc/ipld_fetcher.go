@@ -51,6 +51,18 @@ func NewIPLDFetcher(ipfsPath string) (*IPLDFetcher, error) {
 	}, nil
 }
 
+// NewRemoteIPLDFetcher creates a pointer to a new IPLDFetcher
+// It fetches from the IPFS daemon at the given HTTP API address
+func NewRemoteIPLDFetcher(ipfsAPI string) (*IPLDFetcher, error) {
+	blockService, err := ipfs.InitRemoteBlockService(ipfsAPI)
+	if err != nil {
+		return nil, err
+	}
+	return &IPLDFetcher{
+		BlockService: blockService,
+	}, nil
+}
+
 // Fetch is the exported method for fetching and returning all the IPLDS specified in the CIDWrapper
 func (f *IPLDFetcher) Fetch(cids shared.CIDsForFetching) (shared.IPLDs, error) {
 	cidWrapper, ok := cids.(*CIDWrapper)
@@ -65,7 +77,11 @@ func (f *IPLDFetcher) Fetch(cids shared.CIDsForFetching) (shared.IPLDs, error) {
 	if err != nil {
 		return nil, err
 	}
-	iplds.Transactions, err = f.FetchTrxs(cidWrapper.Transactions)
+	if cidWrapper.Witness {
+		iplds.Transactions, err = f.FetchWitnessTrxs(cidWrapper.Transactions)
+	} else {
+		iplds.Transactions, err = f.FetchTrxs(cidWrapper.Transactions)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -90,13 +106,47 @@ func (f *IPLDFetcher) FetchHeader(c HeaderModel) (ipfs.BlockModel, error) {
 	}, nil
 }
 
-// FetchTrxs fetches transactions
+// FetchTrxs fetches transactions in their stripped form, without witness data
 // It uses the f.fetchBatch method
 func (f *IPLDFetcher) FetchTrxs(cids []TxModel) ([]ipfs.BlockModel, error) {
 	log.Debug("fetching transaction iplds")
+	trxCids := make([]string, len(cids))
+	legacy := make(map[string]bool)
+	for i, c := range cids {
+		trxCids[i] = c.CID
+		if c.LegacyWitnessForm() {
+			legacy[c.CID] = true
+		}
+	}
+	trxIPLDs, err := f.fetchTrxs(trxCids)
+	if err != nil {
+		return trxIPLDs, err
+	}
+	for i, trxIPLD := range trxIPLDs {
+		if legacy[trxIPLD.CID] {
+			if trxIPLDs[i], err = stripWitness(trxIPLD.Data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return trxIPLDs, nil
+}
+
+// FetchWitnessTrxs fetches transactions including their witness data
+// It uses the f.fetchBatch method
+func (f *IPLDFetcher) FetchWitnessTrxs(cids []TxModel) ([]ipfs.BlockModel, error) {
+	log.Debug("fetching witness transaction iplds")
+	trxCids := make([]string, len(cids))
+	for i, c := range cids {
+		trxCids[i] = c.WitnessCID
+	}
+	return f.fetchTrxs(trxCids)
+}
+
+func (f *IPLDFetcher) fetchTrxs(cids []string) ([]ipfs.BlockModel, error) {
 	trxCids := make([]cid.Cid, len(cids))
 	for i, c := range cids {
-		dc, err := cid.Decode(c.CID)
+		dc, err := cid.Decode(c)
 		if err != nil {
 			return nil, err
 		}
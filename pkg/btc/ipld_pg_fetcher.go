@@ -17,26 +17,32 @@
 package btc
 
 import (
+	"bytes"
 	"fmt"
 
+	"github.com/btcsuite/btcd/wire"
 	"github.com/jmoiron/sqlx"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
 )
 
 // IPLDPGFetcher satisfies the IPLDFetcher interface for ethereum
-// it interfaces directly with PG-IPFS instead of going through a node-interface or remote node
+// it interfaces directly with the blockstores, by default PG-IPFS, instead of going through a node-interface or remote node
 type IPLDPGFetcher struct {
-	db *postgres.DB
+	// Blockstores the IPLDs are fetched from, by type of data
+	Blockstores *shared.Blockstores
+	db          *postgres.DB
 }
 
 // NewIPLDPGFetcher creates a pointer to a new IPLDPGFetcher
 func NewIPLDPGFetcher(db *postgres.DB) *IPLDPGFetcher {
 	return &IPLDPGFetcher{
-		db: db,
+		Blockstores: shared.NewPostgresBlockstores(),
+		db:          db,
 	}
 }
 
@@ -69,7 +75,11 @@ func (f *IPLDPGFetcher) Fetch(cids shared.CIDsForFetching) (shared.IPLDs, error)
 	if err != nil {
 		return nil, fmt.Errorf("btc pg fetcher: header fetching error: %s", err.Error())
 	}
-	iplds.Transactions, err = f.FetchTrxs(tx, cidWrapper.Transactions)
+	if cidWrapper.Witness {
+		iplds.Transactions, err = f.FetchWitnessTrxs(tx, cidWrapper.Transactions)
+	} else {
+		iplds.Transactions, err = f.FetchTrxs(tx, cidWrapper.Transactions)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("btc pg fetcher: transaction fetching error: %s", err.Error())
 	}
@@ -79,7 +89,7 @@ func (f *IPLDPGFetcher) Fetch(cids shared.CIDsForFetching) (shared.IPLDs, error)
 // FetchHeaders fetches headers
 func (f *IPLDPGFetcher) FetchHeader(tx *sqlx.Tx, c HeaderModel) (ipfs.BlockModel, error) {
 	log.Debug("fetching header ipld")
-	headerBytes, err := shared.FetchIPLDByMhKey(tx, c.MhKey)
+	headerBytes, err := f.Blockstores.Get(tx, shared.Headers, c.MhKey)
 	if err != nil {
 		return ipfs.BlockModel{}, err
 	}
@@ -89,15 +99,21 @@ func (f *IPLDPGFetcher) FetchHeader(tx *sqlx.Tx, c HeaderModel) (ipfs.BlockModel
 	}, nil
 }
 
-// FetchTrxs fetches transactions
+// FetchTrxs fetches transactions in their stripped form, without witness data
 func (f *IPLDPGFetcher) FetchTrxs(tx *sqlx.Tx, cids []TxModel) ([]ipfs.BlockModel, error) {
 	log.Debug("fetching transaction iplds")
 	trxIPLDs := make([]ipfs.BlockModel, len(cids))
 	for i, c := range cids {
-		trxBytes, err := shared.FetchIPLDByMhKey(tx, c.MhKey)
+		trxBytes, err := f.Blockstores.Get(tx, shared.Transactions, c.MhKey)
 		if err != nil {
 			return nil, err
 		}
+		if c.LegacyWitnessForm() {
+			if trxIPLDs[i], err = stripWitness(trxBytes); err != nil {
+				return nil, err
+			}
+			continue
+		}
 		trxIPLDs[i] = ipfs.BlockModel{
 			Data: trxBytes,
 			CID:  c.CID,
@@ -105,3 +121,36 @@ func (f *IPLDPGFetcher) FetchTrxs(tx *sqlx.Tx, cids []TxModel) ([]ipfs.BlockMode
 	}
 	return trxIPLDs, nil
 }
+
+// FetchWitnessTrxs fetches transactions including their witness data
+func (f *IPLDPGFetcher) FetchWitnessTrxs(tx *sqlx.Tx, cids []TxModel) ([]ipfs.BlockModel, error) {
+	log.Debug("fetching witness transaction iplds")
+	trxIPLDs := make([]ipfs.BlockModel, len(cids))
+	for i, c := range cids {
+		trxBytes, err := f.Blockstores.Get(tx, shared.Transactions, c.WitnessMhKey)
+		if err != nil {
+			return nil, err
+		}
+		trxIPLDs[i] = ipfs.BlockModel{
+			Data: trxBytes,
+			CID:  c.WitnessCID,
+		}
+	}
+	return trxIPLDs, nil
+}
+
+// stripWitness returns the stripped form of a segwit transaction indexed with only its witness form
+func stripWitness(data []byte) (ipfs.BlockModel, error) {
+	msgTx := new(wire.MsgTx)
+	if err := msgTx.Deserialize(bytes.NewReader(data)); err != nil {
+		return ipfs.BlockModel{}, err
+	}
+	txNode, err := ipld.NewBtcTx(msgTx)
+	if err != nil {
+		return ipfs.BlockModel{}, err
+	}
+	return ipfs.BlockModel{
+		Data: txNode.RawData(),
+		CID:  txNode.Cid().String(),
+	}, nil
+}
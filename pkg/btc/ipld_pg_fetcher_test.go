@@ -0,0 +1,73 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc_test
+
+import (
+	"bytes"
+
+	"github.com/multiformats/go-multihash"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+var _ = Describe("IPLDPGFetcher", func() {
+	var (
+		db  *postgres.DB
+		err error
+	)
+	BeforeEach(func() {
+		db, err = shared.SetupDB()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = btc.NewIPLDPublisherAndIndexer(db).Publish(mocks.MockSegwitConvertedPayload)
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		btc.TearDownDB(db)
+	})
+
+	Describe("FetchTrxs", func() {
+		It("Fetches the stripped forms of segwit transactions indexed with only their witness forms", func() {
+			// segwit transactions indexed before their stripped forms were published reference their witness forms
+			_, err = db.Exec(`UPDATE btc.transaction_cids SET (cid, mh_key) = (witness_cid, witness_mh_key)`)
+			Expect(err).ToNot(HaveOccurred())
+			trxs := make([]btc.TxModel, 0)
+			err = db.Select(&trxs, `SELECT * FROM btc.transaction_cids ORDER BY index`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(trxs)).To(Equal(2))
+			tx, err := db.Beginx()
+			Expect(err).ToNot(HaveOccurred())
+			defer tx.Rollback()
+			trxIPLDs, err := btc.NewIPLDPGFetcher(db).FetchTrxs(tx, trxs)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(trxIPLDs)).To(Equal(2))
+			for i, trxIPLD := range trxIPLDs {
+				Expect(trxs[i].LegacyWitnessForm()).To(BeTrue())
+				var stripped bytes.Buffer
+				Expect(mocks.MockSegwitBlock.Transactions[i].SerializeNoWitness(&stripped)).To(Succeed())
+				Expect(trxIPLD.Data).To(Equal(stripped.Bytes()))
+				c, _ := ipld.RawdataToCid(ipld.MBitcoinTx, stripped.Bytes(), multihash.DBL_SHA2_256)
+				Expect(trxIPLD.CID).To(Equal(c.String()))
+			}
+		})
+	})
+})
@@ -0,0 +1,122 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/ipfs/go-cid"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// merkleNodeSize is the size of a merkle node, the hashes of its two children
+const merkleNodeSize = 2 * chainhash.HashSize
+
+// WalkMerkleTree walks the merkle tree of the transactions of a block from its root, handing every block it reaches to
+// visit: the merkle nodes, and the transactions at its leaves in block order
+// Merkle nodes are told apart from transactions by their size, as standard transactions are never 64 bytes long
+// A block get returns no data for is skipped, along with the blocks below it
+func WalkMerkleTree(root chainhash.Hash, get func(hash chainhash.Hash) ([]byte, error), visit func(hash chainhash.Hash, data []byte, isNode bool) error) error {
+	data, err := get(root)
+	if err != nil || data == nil {
+		return err
+	}
+	if len(data) != merkleNodeSize {
+		return visit(root, data, false)
+	}
+	if err := visit(root, data, true); err != nil {
+		return err
+	}
+	var left, right chainhash.Hash
+	copy(left[:], data[:chainhash.HashSize])
+	copy(right[:], data[chainhash.HashSize:])
+	if err := WalkMerkleTree(left, get, visit); err != nil {
+		return err
+	}
+	// the last hash of a level with an odd number of hashes is paired with itself
+	if right == left {
+		return nil
+	}
+	return WalkMerkleTree(right, get, visit)
+}
+
+// WalkHeaderMerkleTree walks the merkle tree of the transactions of the header, handing the blocks of its merkle nodes,
+// which are published but not indexed, and of the transactions at its leaves to visit, all as transaction data
+// The witness commitment and witness merkle tree hanging off of the coinbase, if any, are walked after it
+// get returns the data of the block with the cid; a block it returns no data for is skipped, along with the blocks below it
+func WalkHeaderMerkleTree(headerData []byte, get func(t shared.DataType, c cid.Cid) ([]byte, error), visit func(t shared.DataType, c cid.Cid, data []byte) error) error {
+	var header wire.BlockHeader
+	if err := header.Deserialize(bytes.NewReader(headerData)); err != nil {
+		return err
+	}
+	var coinbase []byte
+	err := WalkMerkleTree(header.MerkleRoot, func(hash chainhash.Hash) ([]byte, error) {
+		return get(shared.Transactions, ipld.DoubleSha256ToCid(ipld.MBitcoinTx, hash[:]))
+	}, func(hash chainhash.Hash, data []byte, isNode bool) error {
+		if !isNode && coinbase == nil {
+			coinbase = data
+		}
+		return visit(shared.Transactions, ipld.DoubleSha256ToCid(ipld.MBitcoinTx, hash[:]), data)
+	})
+	if err != nil || coinbase == nil {
+		return err
+	}
+	return WalkWitnessTree(coinbase, get, visit)
+}
+
+// WalkWitnessTree walks the witness commitment the coinbase carries, if any, and the witness merkle tree below it,
+// handing their blocks, which are published but not indexed, and the witness transactions at the leaves of the tree to
+// visit, all as transaction data
+// The coinbase's own leaf is all zeros and has no block
+func WalkWitnessTree(coinbaseData []byte, get func(t shared.DataType, c cid.Cid) ([]byte, error), visit func(t shared.DataType, c cid.Cid, data []byte) error) error {
+	var coinbase wire.MsgTx
+	if err := coinbase.Deserialize(bytes.NewReader(coinbaseData)); err != nil {
+		return err
+	}
+	commitment, ok := blockchain.ExtractWitnessCommitment(btcutil.NewTx(&coinbase))
+	if !ok {
+		return nil
+	}
+	c := ipld.DoubleSha256ToCid(ipld.MBitcoinWitnessCommitment, commitment)
+	data, err := get(shared.Transactions, c)
+	if err != nil || data == nil {
+		return err
+	}
+	if len(data) != merkleNodeSize {
+		return fmt.Errorf("witness commitment %s is %d bytes long", c.String(), len(data))
+	}
+	if err := visit(shared.Transactions, c, data); err != nil {
+		return err
+	}
+	var root chainhash.Hash
+	copy(root[:], data[:chainhash.HashSize])
+	return WalkMerkleTree(root, func(hash chainhash.Hash) ([]byte, error) {
+		if hash == (chainhash.Hash{}) {
+			return nil, nil
+		}
+		return get(shared.Transactions, ipld.DoubleSha256ToCid(ipld.MBitcoinTx, hash[:]))
+	}, func(hash chainhash.Hash, data []byte, isNode bool) error {
+		return visit(shared.Transactions, ipld.DoubleSha256ToCid(ipld.MBitcoinTx, hash[:]), data)
+	})
+}
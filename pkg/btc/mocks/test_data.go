@@ -20,12 +20,15 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/chaincfg/chainhash"
 	"github.com/btcsuite/btcd/txscript"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
+	"github.com/ipfs/go-cid"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
 )
 
@@ -39,6 +42,7 @@ var (
 	MockTrxMhKey2         = shared.MultihashKeyFromCID(MockTrxCID2)
 	MockTrxMhKey3         = shared.MultihashKeyFromCID(MockTrxCID3)
 	MockBlockHeight int64 = 1337
+	mockSubsidy     int64 = 5000000000
 	MockBlock             = wire.MsgBlock{
 		Header: wire.BlockHeader{
 			Version: 1,
@@ -300,6 +304,7 @@ var (
 			TxHash: MockBlock.Transactions[0].TxHash().String(),
 			Index:  0,
 			SegWit: MockBlock.Transactions[0].HasWitness(),
+			Weight: txWeight(MockBlock.Transactions[0]),
 			TxInputs: []btc.TxInput{
 				{
 					Index: 0,
@@ -337,6 +342,7 @@ var (
 			TxHash: MockBlock.Transactions[1].TxHash().String(),
 			Index:  1,
 			SegWit: MockBlock.Transactions[1].HasWitness(),
+			Weight: txWeight(MockBlock.Transactions[1]),
 			TxInputs: []btc.TxInput{
 				{
 					Index: 0,
@@ -413,6 +419,7 @@ var (
 			TxHash: MockBlock.Transactions[2].TxHash().String(),
 			Index:  2,
 			SegWit: MockBlock.Transactions[2].HasWitness(),
+			Weight: txWeight(MockBlock.Transactions[2]),
 			TxInputs: []btc.TxInput{
 				{
 					Index: 0,
@@ -487,11 +494,14 @@ var (
 	}
 	MockTxsMetaDataPostPublish = []btc.TxModelWithInsAndOuts{
 		{
-			CID:    MockTrxCID1.String(),
-			MhKey:  MockTrxMhKey1,
-			TxHash: MockBlock.Transactions[0].TxHash().String(),
-			Index:  0,
-			SegWit: MockBlock.Transactions[0].HasWitness(),
+			CID:          MockTrxCID1.String(),
+			MhKey:        MockTrxMhKey1,
+			WitnessCID:   MockTrxCID1.String(),
+			WitnessMhKey: MockTrxMhKey1,
+			TxHash:       MockBlock.Transactions[0].TxHash().String(),
+			Index:        0,
+			SegWit:       MockBlock.Transactions[0].HasWitness(),
+			Weight:       txWeight(MockBlock.Transactions[0]),
 			TxInputs: []btc.TxInput{
 				{
 					Index: 0,
@@ -526,11 +536,14 @@ var (
 			},
 		},
 		{
-			CID:    MockTrxCID2.String(),
-			MhKey:  MockTrxMhKey2,
-			TxHash: MockBlock.Transactions[1].TxHash().String(),
-			Index:  1,
-			SegWit: MockBlock.Transactions[1].HasWitness(),
+			CID:          MockTrxCID2.String(),
+			MhKey:        MockTrxMhKey2,
+			WitnessCID:   MockTrxCID2.String(),
+			WitnessMhKey: MockTrxMhKey2,
+			TxHash:       MockBlock.Transactions[1].TxHash().String(),
+			Index:        1,
+			SegWit:       MockBlock.Transactions[1].HasWitness(),
+			Weight:       txWeight(MockBlock.Transactions[1]),
 			TxInputs: []btc.TxInput{
 				{
 					Index: 0,
@@ -604,11 +617,14 @@ var (
 			},
 		},
 		{
-			CID:    MockTrxCID3.String(),
-			MhKey:  MockTrxMhKey3,
-			TxHash: MockBlock.Transactions[2].TxHash().String(),
-			Index:  2,
-			SegWit: MockBlock.Transactions[2].HasWitness(),
+			CID:          MockTrxCID3.String(),
+			MhKey:        MockTrxMhKey3,
+			WitnessCID:   MockTrxCID3.String(),
+			WitnessMhKey: MockTrxMhKey3,
+			TxHash:       MockBlock.Transactions[2].TxHash().String(),
+			Index:        2,
+			SegWit:       MockBlock.Transactions[2].HasWitness(),
+			Weight:       txWeight(MockBlock.Transactions[2]),
 			TxInputs: []btc.TxInput{
 				{
 					Index: 0,
@@ -682,24 +698,347 @@ var (
 		},
 	}
 	MockHeaderMetaData = btc.HeaderModel{
-		CID:         MockHeaderCID.String(),
-		MhKey:       MockHeaderMhKey,
-		ParentHash:  MockBlock.Header.PrevBlock.String(),
-		BlockNumber: strconv.Itoa(int(MockBlockHeight)),
-		BlockHash:   MockBlock.Header.BlockHash().String(),
-		Timestamp:   MockBlock.Header.Timestamp.UnixNano(),
-		Bits:        MockBlock.Header.Bits,
+		CID:           MockHeaderCID.String(),
+		MhKey:         MockHeaderMhKey,
+		ParentHash:    MockBlock.Header.PrevBlock.String(),
+		BlockNumber:   strconv.Itoa(int(MockBlockHeight)),
+		BlockHash:     MockBlock.Header.BlockHash().String(),
+		Timestamp:     MockBlock.Header.Timestamp.UnixNano(),
+		Bits:          MockBlock.Header.Bits,
+		CoinbaseValue: MockBlock.Transactions[0].TxOut[0].Value,
+		Subsidy:       &mockSubsidy,
 	}
 	MockConvertedPayload = btc.ConvertedPayload{
-		BlockPayload: MockBlockPayload,
-		TxMetaData:   MockTxsMetaData,
+		BlockPayload:  MockBlockPayload,
+		TxMetaData:    MockTxsMetaData,
+		CoinbaseValue: MockBlock.Transactions[0].TxOut[0].Value,
+		Subsidy:       5000000000,
 	}
 	MockCIDPayload = btc.CIDPayload{
 		HeaderCID:       MockHeaderMetaData,
 		TransactionCIDs: MockTxsMetaDataPostPublish,
 	}
+
+	// MockOpReturnData is the data carried by the OP_RETURN output of MockSpendingBlock's second transaction
+	MockOpReturnData = []byte("hello world")
+	// MockSpendingBlock is the block after MockBlock, its second transaction spends the first output of MockBlock's second
+	// transaction (556000000 satoshis) into 555990000 satoshis paid to the address of MockBlock's third transaction's first output,
+	// leaving a 10000 satoshi fee which is claimed by its coinbase, and has a second OP_RETURN output carrying MockOpReturnData
+	MockSpendingBlockHeight int64 = MockBlockHeight + 1
+	MockSpendingBlock             = wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   1,
+			PrevBlock: MockBlock.Header.BlockHash(),
+			Timestamp: time.Unix(1293624404, 0),
+			Bits:      0x1b04864c,
+			Nonce:     0x1f2b3c4d,
+		},
+		Transactions: []*wire.MsgTx{
+			{
+				Version: 1,
+				TxIn: []*wire.TxIn{
+					{
+						PreviousOutPoint: wire.OutPoint{
+							Hash:  chainhash.Hash{},
+							Index: 0xffffffff,
+						},
+						SignatureScript: []byte{0x04, 0x4c, 0x86, 0x04, 0x1b, 0x02, 0x07, 0x02},
+						Sequence:        0xffffffff,
+					},
+				},
+				TxOut: []*wire.TxOut{
+					{
+						Value:    5000010000,
+						PkScript: MockBlock.Transactions[0].TxOut[0].PkScript,
+					},
+				},
+				LockTime: 0,
+			},
+			{
+				Version: 1,
+				TxIn: []*wire.TxIn{
+					{
+						PreviousOutPoint: wire.OutPoint{
+							Hash:  MockBlock.Transactions[1].TxHash(),
+							Index: 0,
+						},
+						SignatureScript: []byte{0x00},
+						Sequence:        0xffffffff,
+					},
+				},
+				TxOut: []*wire.TxOut{
+					{
+						Value:    555990000,
+						PkScript: MockBlock.Transactions[2].TxOut[0].PkScript,
+					},
+					{
+						Value:    0,
+						PkScript: append([]byte{txscript.OP_RETURN, txscript.OP_DATA_11}, MockOpReturnData...),
+					},
+				},
+				LockTime: 0,
+			},
+		},
+	}
+	MockSpendingBlockPayload = btc.BlockPayload{
+		Header:      &MockSpendingBlock.Header,
+		Txs:         []*btcutil.Tx{btcutil.NewTx(MockSpendingBlock.Transactions[0]), btcutil.NewTx(MockSpendingBlock.Transactions[1])},
+		BlockHeight: MockSpendingBlockHeight,
+	}
+	// MockSpendingConvertedPayload is converted after MockBlockPayload, so the output its second transaction spends is
+	// resolved and its fees are known
+	MockSpendingConvertedPayload = mustConvert(MockBlockPayload, MockSpendingBlockPayload)
+
+	// MockForkBlock competes with MockBlock at the same height and also includes MockBlock's second transaction
+	// It has the same work as MockBlock, so whichever of the two is seen first stays on the best chain
+	MockForkBlock = withMerkleRoot(wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   1,
+			PrevBlock: MockBlock.Header.PrevBlock,
+			Timestamp: time.Unix(1293623901, 0),
+			Bits:      MockBlock.Header.Bits,
+			Nonce:     0x2a3b4c5d,
+		},
+		Transactions: []*wire.MsgTx{mockCoinbase(0x08, 5000000000), MockBlock.Transactions[1]},
+	})
+	MockForkBlockPayload = btc.BlockPayload{
+		Header:      &MockForkBlock.Header,
+		Txs:         []*btcutil.Tx{btcutil.NewTx(MockForkBlock.Transactions[0]), btcutil.NewTx(MockForkBlock.Transactions[1])},
+		BlockHeight: MockBlockHeight,
+	}
+	MockForkConvertedPayload = mustConvert(MockForkBlockPayload)
+	// MockForkChildBlock builds on MockForkBlock, giving its fork more work than MockBlock
+	MockForkChildBlock = withMerkleRoot(wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   1,
+			PrevBlock: MockForkBlock.Header.BlockHash(),
+			Timestamp: time.Unix(1293624502, 0),
+			Bits:      MockBlock.Header.Bits,
+			Nonce:     0x3b4c5d6e,
+		},
+		Transactions: []*wire.MsgTx{mockCoinbase(0x09, 5000000000)},
+	})
+	MockForkChildBlockPayload = btc.BlockPayload{
+		Header:      &MockForkChildBlock.Header,
+		Txs:         []*btcutil.Tx{btcutil.NewTx(MockForkChildBlock.Transactions[0])},
+		BlockHeight: MockSpendingBlockHeight,
+	}
+	MockForkChildConvertedPayload = mustConvert(MockForkBlockPayload, MockForkChildBlockPayload)
+	// MockForkGrandchildBlock builds on MockForkChildBlock, giving its fork more work than a block built on MockBlock
+	MockForkGrandchildBlock = withMerkleRoot(wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   1,
+			PrevBlock: MockForkChildBlock.Header.BlockHash(),
+			Timestamp: time.Unix(1293625102, 0),
+			Bits:      MockBlock.Header.Bits,
+			Nonce:     0x7f8091a2,
+		},
+		Transactions: []*wire.MsgTx{mockCoinbase(0x0e, 5000000000)},
+	})
+	MockForkGrandchildBlockPayload = btc.BlockPayload{
+		Header:      &MockForkGrandchildBlock.Header,
+		Txs:         []*btcutil.Tx{btcutil.NewTx(MockForkGrandchildBlock.Transactions[0])},
+		BlockHeight: MockSpendingBlockHeight + 1,
+	}
+	MockForkGrandchildConvertedPayload = mustConvert(MockForkGrandchildBlockPayload)
+
+	// MockSegwitBlock is a segwit block built on MockBlock, its coinbase commits to the witness data of its second
+	// transaction which spends the first output of MockBlock's third transaction
+	MockSegwitBlock = withWitnessCommitment(wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   0x20000000,
+			PrevBlock: MockBlock.Header.BlockHash(),
+			Timestamp: time.Unix(1293624604, 0),
+			Bits:      MockBlock.Header.Bits,
+			Nonce:     0x4c5d6e7f,
+		},
+		Transactions: []*wire.MsgTx{
+			mockCoinbase(0x0a, 5000000000),
+			{
+				Version: 2,
+				TxIn: []*wire.TxIn{
+					{
+						PreviousOutPoint: wire.OutPoint{
+							Hash:  MockBlock.Transactions[2].TxHash(),
+							Index: 0,
+						},
+						Witness:  wire.TxWitness{{0x30, 0x44, 0x02, 0x20}, {0x02, 0x79, 0xbe, 0x66}},
+						Sequence: 0xffffffff,
+					},
+				},
+				TxOut: []*wire.TxOut{
+					{
+						Value:    1000000,
+						PkScript: MockBlock.Transactions[1].TxOut[0].PkScript,
+					},
+				},
+				LockTime: 0,
+			},
+		},
+	})
+	MockSegwitBlockPayload = btc.BlockPayload{
+		Header:      &MockSegwitBlock.Header,
+		Txs:         []*btcutil.Tx{btcutil.NewTx(MockSegwitBlock.Transactions[0]), btcutil.NewTx(MockSegwitBlock.Transactions[1])},
+		BlockHeight: MockSpendingBlockHeight,
+	}
+	MockSegwitConvertedPayload = mustConvert(MockSegwitBlockPayload)
+	// the blocks that hang off of the segwit header and are published but not indexed: the root of its merkle tree, and
+	// its witness commitment with the root of the witness merkle tree
+	MockSegwitMerkleRootCID                           = ipld.DoubleSha256ToCid(ipld.MBitcoinTx, MockSegwitBlock.Header.MerkleRoot[:])
+	MockSegwitCommitmentCID, MockSegwitWitnessRootCID = witnessCIDs(MockSegwitBlock)
+
+	// MockProofBlock is a block built on MockBlock with an odd number of transactions, two of which it shares with MockBlock
+	// Unlike the other mock blocks its header satisfies the proof of work of its (regtest) target
+	MockProofBlock = withMerkleRoot(wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   1,
+			PrevBlock: MockBlock.Header.BlockHash(),
+			Timestamp: time.Unix(1293624704, 0),
+			Bits:      0x207fffff,
+			Nonce:     1,
+		},
+		Transactions: []*wire.MsgTx{mockCoinbase(0x0b, 5000000000), MockBlock.Transactions[1], MockBlock.Transactions[2]},
+	})
+	MockProofBlockPayload = btc.BlockPayload{
+		Header: &MockProofBlock.Header,
+		Txs: []*btcutil.Tx{
+			btcutil.NewTx(MockProofBlock.Transactions[0]),
+			btcutil.NewTx(MockProofBlock.Transactions[1]),
+			btcutil.NewTx(MockProofBlock.Transactions[2]),
+		},
+		BlockHeight: MockSpendingBlockHeight,
+	}
+	MockProofConvertedPayload = mustConvert(MockProofBlockPayload)
+
+	// MockExtensionBlock and MockExtensionChildBlock extend MockProofBlock's chain by two blocks
+	MockExtensionBlock = withMerkleRoot(wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   1,
+			PrevBlock: MockProofBlock.Header.BlockHash(),
+			Timestamp: time.Unix(1293624804, 0),
+			Bits:      MockBlock.Header.Bits,
+			Nonce:     0x5d6e7f80,
+		},
+		Transactions: []*wire.MsgTx{mockCoinbase(0x0c, 5000000000)},
+	})
+	MockExtensionBlockPayload = btc.BlockPayload{
+		Header:      &MockExtensionBlock.Header,
+		Txs:         []*btcutil.Tx{btcutil.NewTx(MockExtensionBlock.Transactions[0])},
+		BlockHeight: MockSpendingBlockHeight + 1,
+	}
+	MockExtensionConvertedPayload = mustConvert(MockExtensionBlockPayload)
+	MockExtensionChildBlock       = withMerkleRoot(wire.MsgBlock{
+		Header: wire.BlockHeader{
+			Version:   1,
+			PrevBlock: MockExtensionBlock.Header.BlockHash(),
+			Timestamp: time.Unix(1293624904, 0),
+			Bits:      MockBlock.Header.Bits,
+			Nonce:     0x6e7f8091,
+		},
+		Transactions: []*wire.MsgTx{mockCoinbase(0x0d, 5000000000)},
+	})
+	MockExtensionChildBlockPayload = btc.BlockPayload{
+		Header:      &MockExtensionChildBlock.Header,
+		Txs:         []*btcutil.Tx{btcutil.NewTx(MockExtensionChildBlock.Transactions[0])},
+		BlockHeight: MockSpendingBlockHeight + 2,
+	}
+	MockExtensionChildConvertedPayload = mustConvert(MockExtensionChildBlockPayload)
 )
 
+func init() {
+	merkles := blockchain.BuildMerkleTreeStore(MockSpendingConvertedPayload.Txs, false)
+	MockSpendingBlock.Header.MerkleRoot = *merkles[len(merkles)-1]
+}
+
+// mockCoinbase returns a coinbase transaction paying the value to the address of MockBlock's coinbase
+// The extra nonce distinguishes the coinbases of different blocks
+func mockCoinbase(extraNonce byte, value int64) *wire.MsgTx {
+	return &wire.MsgTx{
+		Version: 1,
+		TxIn: []*wire.TxIn{
+			{
+				PreviousOutPoint: wire.OutPoint{
+					Hash:  chainhash.Hash{},
+					Index: 0xffffffff,
+				},
+				SignatureScript: []byte{0x04, 0x4c, 0x86, 0x04, 0x1b, 0x02, extraNonce, 0x02},
+				Sequence:        0xffffffff,
+			},
+		},
+		TxOut: []*wire.TxOut{
+			{
+				Value:    value,
+				PkScript: MockBlock.Transactions[0].TxOut[0].PkScript,
+			},
+		},
+		LockTime: 0,
+	}
+}
+
+// withMerkleRoot sets the merkle root of the block's header from its transactions
+func withMerkleRoot(block wire.MsgBlock) wire.MsgBlock {
+	txs := make([]*btcutil.Tx, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		txs[i] = btcutil.NewTx(tx)
+	}
+	merkles := blockchain.BuildMerkleTreeStore(txs, false)
+	block.Header.MerkleRoot = *merkles[len(merkles)-1]
+	return block
+}
+
+// withWitnessCommitment adds the witness reserved value to the block's coinbase and commits to the witness data of its
+// transactions in a coinbase output, then sets the merkle root of the block's header
+func withWitnessCommitment(block wire.MsgBlock) wire.MsgBlock {
+	coinbase := block.Transactions[0]
+	nonce := make([]byte, blockchain.CoinbaseWitnessDataLen)
+	coinbase.TxIn[0].Witness = wire.TxWitness{nonce}
+	txs := make([]*btcutil.Tx, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		txs[i] = btcutil.NewTx(tx)
+	}
+	witnessMerkles := blockchain.BuildMerkleTreeStore(txs, true)
+	commitment := chainhash.DoubleHashB(append(witnessMerkles[len(witnessMerkles)-1][:], nonce...))
+	coinbase.TxOut = append(coinbase.TxOut, &wire.TxOut{
+		Value:    0,
+		PkScript: append(append([]byte{}, blockchain.WitnessMagicBytes...), commitment...),
+	})
+	return withMerkleRoot(block)
+}
+
+// witnessCIDs returns the cids of the witness commitment of the segwit block and of the root of its witness merkle tree
+func witnessCIDs(block wire.MsgBlock) (cid.Cid, cid.Cid) {
+	txs := make([]*btcutil.Tx, len(block.Transactions))
+	for i, tx := range block.Transactions {
+		txs[i] = btcutil.NewTx(tx)
+	}
+	commitment, ok := blockchain.ExtractWitnessCommitment(txs[0])
+	if !ok {
+		panic("block has no witness commitment")
+	}
+	witnessMerkles := blockchain.BuildMerkleTreeStore(txs, true)
+	return ipld.DoubleSha256ToCid(ipld.MBitcoinWitnessCommitment, commitment),
+		ipld.DoubleSha256ToCid(ipld.MBitcoinTx, witnessMerkles[len(witnessMerkles)-1][:])
+}
+
+// mustConvert converts the payloads in order with a single converter and returns the last one
+func mustConvert(payloads ...btc.BlockPayload) btc.ConvertedPayload {
+	converter := btc.NewPayloadConverter(&chaincfg.MainNetParams, nil)
+	var converted shared.ConvertedData
+	var err error
+	for _, payload := range payloads {
+		converted, err = converter.Convert(payload)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return converted.(btc.ConvertedPayload)
+}
+
+func txWeight(tx *wire.MsgTx) *int64 {
+	weight := blockchain.GetTransactionWeight(btcutil.NewTx(tx))
+	return &weight
+}
+
 func stringSliceFromAddresses(addrs []btcutil.Address) []string {
 	strs := make([]string, len(addrs))
 	for i, addr := range addrs {
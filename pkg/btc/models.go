@@ -20,42 +20,64 @@ import "github.com/lib/pq"
 
 // HeaderModel is the db model for btc.header_cids table
 type HeaderModel struct {
-	ID             int64  `db:"id"`
-	BlockNumber    string `db:"block_number"`
-	BlockHash      string `db:"block_hash"`
-	ParentHash     string `db:"parent_hash"`
-	CID            string `db:"cid"`
-	MhKey          string `db:"mh_key"`
-	Timestamp      int64  `db:"timestamp"`
-	Bits           uint32 `db:"bits"`
-	NodeID         int64  `db:"node_id"`
-	TimesValidated int64  `db:"times_validated"`
+	ID             int64   `db:"id"`
+	BlockNumber    string  `db:"block_number"`
+	BlockHash      string  `db:"block_hash"`
+	ParentHash     string  `db:"parent_hash"`
+	CID            string  `db:"cid"`
+	MhKey          string  `db:"mh_key"`
+	Timestamp      int64   `db:"timestamp"`
+	Bits           uint32  `db:"bits"`
+	NodeID         int64   `db:"node_id"`
+	TimesValidated int64   `db:"times_validated"`
+	CoinbaseValue  int64   `db:"coinbase_value"`
+	Subsidy        *int64  `db:"subsidy"`    // nil for blocks indexed before the subsidy was, until they are resynced
+	Fees           *int64  `db:"fees"`       // nil until the fees of all of the block's transactions are known
+	ChainWork      *string `db:"chain_work"` // total work of the chain up to and including the block, as a decimal string; nil until its parent is indexed
+	Orphaned       bool    `db:"orphaned"`   // true if the block is not on the best chain
 }
 
 // TxModel is the db model for btc.transaction_cids table
+// Fee and FeeRate are nil for coinbase transactions and until the outputs spent by all of the transaction's inputs are known
 type TxModel struct {
-	ID          int64  `db:"id"`
-	HeaderID    int64  `db:"header_id"`
-	Index       int64  `db:"index"`
-	TxHash      string `db:"tx_hash"`
-	CID         string `db:"cid"`
-	MhKey       string `db:"mh_key"`
-	SegWit      bool   `db:"segwit"`
-	WitnessHash string `db:"witness_hash"`
+	ID           int64    `db:"id"`
+	HeaderID     int64    `db:"header_id"`
+	Index        int64    `db:"index"`
+	TxHash       string   `db:"tx_hash"`
+	CID          string   `db:"cid"`
+	MhKey        string   `db:"mh_key"`
+	SegWit       bool     `db:"segwit"`
+	WitnessHash  string   `db:"witness_hash"`
+	Weight       *int64   `db:"weight"`
+	Fee          *int64   `db:"fee"`
+	FeeRate      *float64 `db:"fee_rate"` // satoshis per virtual byte
+	WitnessCID   string   `db:"witness_cid"`
+	WitnessMhKey string   `db:"witness_mh_key"`
+}
+
+// LegacyWitnessForm reports whether the transaction was indexed before the stripped forms of segwit transactions were
+// published, in which case its cid references its witness form just like its witness cid does
+func (tx TxModel) LegacyWitnessForm() bool {
+	return tx.SegWit && tx.CID == tx.WitnessCID
 }
 
 // TxModelWithInsAndOuts is the db model for btc.transaction_cids table that includes the children tx_input and tx_output tables
 type TxModelWithInsAndOuts struct {
-	ID          int64  `db:"id"`
-	HeaderID    int64  `db:"header_id"`
-	Index       int64  `db:"index"`
-	TxHash      string `db:"tx_hash"`
-	CID         string `db:"cid"`
-	MhKey       string `db:"mh_key"`
-	SegWit      bool   `db:"segwit"`
-	WitnessHash string `db:"witness_hash"`
-	TxInputs    []TxInput
-	TxOutputs   []TxOutput
+	ID           int64    `db:"id"`
+	HeaderID     int64    `db:"header_id"`
+	Index        int64    `db:"index"`
+	TxHash       string   `db:"tx_hash"`
+	CID          string   `db:"cid"`
+	MhKey        string   `db:"mh_key"`
+	SegWit       bool     `db:"segwit"`
+	WitnessHash  string   `db:"witness_hash"`
+	Weight       *int64   `db:"weight"`
+	Fee          *int64   `db:"fee"`
+	FeeRate      *float64 `db:"fee_rate"`
+	WitnessCID   string   `db:"witness_cid"`
+	WitnessMhKey string   `db:"witness_mh_key"`
+	TxInputs     []TxInput
+	TxOutputs    []TxOutput
 }
 
 // TxInput is the db model for btc.tx_inputs table
@@ -65,8 +87,10 @@ type TxInput struct {
 	Index                 int64    `db:"index"`
 	TxWitness             []string `db:"witness"`
 	SignatureScript       []byte   `db:"sig_script"`
-	PreviousOutPointIndex uint32   `db:"outpoint_tx_hash"`
-	PreviousOutPointHash  string   `db:"outpoint_index"`
+	PreviousOutPointIndex uint32   `db:"outpoint_index"`
+	PreviousOutPointHash  string   `db:"outpoint_tx_hash"`
+	// PreviousOutput is the output spent by this input, if it could be resolved when the input was converted
+	PreviousOutput *TxOutput `db:"-"`
 }
 
 // TxOutput is the db model for btc.tx_outputs table
@@ -79,4 +103,24 @@ type TxOutput struct {
 	ScriptClass  uint8          `db:"script_class"`
 	RequiredSigs int64          `db:"required_sigs"`
 	Addresses    pq.StringArray `db:"addresses"`
+	OpReturnData []byte         `db:"op_return_data"` // the data pushed by an OP_RETURN script, nil for other scripts
+}
+
+// AddressOutputModel is an output paying to one of the queried addresses, along with the tx and block it is in
+type AddressOutputModel struct {
+	TxOutput
+	Address     string `db:"address"`
+	TxHash      string `db:"tx_hash"`
+	BlockNumber int64  `db:"block_number"`
+}
+
+// AddressDeltaModel is a change in the balance of an address
+// Value is positive for outputs paying to the address and negative for the inputs spending them
+type AddressDeltaModel struct {
+	Address     string `db:"address"`
+	Value       int64  `db:"value"`
+	TxHash      string `db:"tx_hash"`
+	TxIndex     int64  `db:"tx_index"`
+	Index       int64  `db:"index"`
+	BlockNumber int64  `db:"block_number"`
 }
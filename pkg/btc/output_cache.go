@@ -0,0 +1,68 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc
+
+import (
+	"sync"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// recentBlocksCached is the number of blocks whose outputs the converter keeps in memory
+// Converted blocks are published by a pool of workers, so the outputs of the last few blocks may not be indexed yet
+// when the blocks spending them are converted
+const recentBlocksCached = 16
+
+// outputCache holds the outputs of the most recently converted blocks
+type outputCache struct {
+	sync.Mutex
+	outputs map[wire.OutPoint]TxOutput
+	blocks  [][]wire.OutPoint
+}
+
+func newOutputCache() *outputCache {
+	return &outputCache{
+		outputs: make(map[wire.OutPoint]TxOutput),
+		blocks:  make([][]wire.OutPoint, 0, recentBlocksCached),
+	}
+}
+
+// add caches a block's outputs, evicting the outputs of the oldest cached block if the cache is full
+func (oc *outputCache) add(outputs map[wire.OutPoint]TxOutput) {
+	oc.Lock()
+	defer oc.Unlock()
+	if len(oc.blocks) == recentBlocksCached {
+		for _, outpoint := range oc.blocks[0] {
+			delete(oc.outputs, outpoint)
+		}
+		oc.blocks = oc.blocks[1:]
+	}
+	outpoints := make([]wire.OutPoint, 0, len(outputs))
+	for outpoint, output := range outputs {
+		oc.outputs[outpoint] = output
+		outpoints = append(outpoints, outpoint)
+	}
+	oc.blocks = append(oc.blocks, outpoints)
+}
+
+// get returns the cached output for the outpoint, if there is one
+func (oc *outputCache) get(outpoint wire.OutPoint) (TxOutput, bool) {
+	oc.Lock()
+	defer oc.Unlock()
+	output, ok := oc.outputs[outpoint]
+	return output, ok
+}
@@ -26,16 +26,19 @@ import (
 )
 
 // IPLDPublisherAndIndexer satisfies the IPLDPublisher interface for bitcoin
-// It interfaces directly with the public.blocks table of PG-IPFS rather than going through an ipfs intermediary
+// It interfaces directly with the blockstores, by default the public.blocks table of PG-IPFS, rather than going through an ipfs intermediary
 // It publishes and indexes IPLDs together in a single sqlx.Tx
 type IPLDPublisherAndIndexer struct {
-	indexer *CIDIndexer
+	// Blockstores the IPLDs are published to, by type of data
+	Blockstores *shared.Blockstores
+	indexer     *CIDIndexer
 }
 
 // NewIPLDPublisherAndIndexer creates a pointer to a new IPLDPublisherAndIndexer which satisfies the IPLDPublisher interface
 func NewIPLDPublisherAndIndexer(db *postgres.DB) *IPLDPublisherAndIndexer {
 	return &IPLDPublisherAndIndexer{
-		indexer: NewCIDIndexer(db),
+		Blockstores: shared.NewPostgresBlockstores(),
+		indexer:     NewCIDIndexer(db),
 	}
 }
 
@@ -50,6 +53,10 @@ func (pub *IPLDPublisherAndIndexer) Publish(payload shared.ConvertedData) (share
 	if err != nil {
 		return nil, err
 	}
+	witnessTxNodes, witnessTrieNodes, witnessCommitment, err := ipld.FromWitnessTxs(txNodes)
+	if err != nil {
+		return nil, err
+	}
 
 	// Begin new db tx
 	tx, err := pub.indexer.db.Beginx()
@@ -69,23 +76,38 @@ func (pub *IPLDPublisherAndIndexer) Publish(payload shared.ConvertedData) (share
 
 	// Publish trie nodes
 	for _, node := range txTrieNodes {
-		if err := shared.PublishIPLD(tx, node); err != nil {
+		if err := pub.Blockstores.PutIPLD(tx, shared.Transactions, node); err != nil {
+			return nil, err
+		}
+	}
+
+	// Publish witness trie nodes and the witness commitment
+	for _, node := range witnessTrieNodes {
+		if err := pub.Blockstores.PutIPLD(tx, shared.Transactions, node); err != nil {
+			return nil, err
+		}
+	}
+	if witnessCommitment != nil {
+		if err := pub.Blockstores.PutIPLD(tx, shared.Transactions, witnessCommitment); err != nil {
 			return nil, err
 		}
 	}
 
 	// Publish and index header
-	if err := shared.PublishIPLD(tx, headerNode); err != nil {
+	if err := pub.Blockstores.PutIPLD(tx, shared.Headers, headerNode); err != nil {
 		return nil, err
 	}
 	header := HeaderModel{
-		CID:         headerNode.Cid().String(),
-		MhKey:       shared.MultihashKeyFromCID(headerNode.Cid()),
-		ParentHash:  ipldPayload.Header.PrevBlock.String(),
-		BlockNumber: strconv.Itoa(int(ipldPayload.BlockPayload.BlockHeight)),
-		BlockHash:   ipldPayload.Header.BlockHash().String(),
-		Timestamp:   ipldPayload.Header.Timestamp.UnixNano(),
-		Bits:        ipldPayload.Header.Bits,
+		CID:           headerNode.Cid().String(),
+		MhKey:         shared.MultihashKeyFromCID(headerNode.Cid()),
+		ParentHash:    ipldPayload.Header.PrevBlock.String(),
+		BlockNumber:   strconv.Itoa(int(ipldPayload.BlockPayload.BlockHeight)),
+		BlockHash:     ipldPayload.Header.BlockHash().String(),
+		Timestamp:     ipldPayload.Header.Timestamp.UnixNano(),
+		Bits:          ipldPayload.Header.Bits,
+		CoinbaseValue: ipldPayload.CoinbaseValue,
+		Subsidy:       &ipldPayload.Subsidy,
+		Fees:          ipldPayload.Fees,
 	}
 	headerID, err := pub.indexer.indexHeaderCID(tx, header)
 	if err != nil {
@@ -94,12 +116,20 @@ func (pub *IPLDPublisherAndIndexer) Publish(payload shared.ConvertedData) (share
 
 	// Publish and index txs
 	for i, txNode := range txNodes {
-		if err := shared.PublishIPLD(tx, txNode); err != nil {
+		if err := pub.Blockstores.PutIPLD(tx, shared.Transactions, txNode); err != nil {
 			return nil, err
 		}
+		witnessTxNode := witnessTxNodes[i]
+		if witnessTxNode != txNode {
+			if err := pub.Blockstores.PutIPLD(tx, shared.Transactions, witnessTxNode); err != nil {
+				return nil, err
+			}
+		}
 		txModel := ipldPayload.TxMetaData[i]
 		txModel.CID = txNode.Cid().String()
 		txModel.MhKey = shared.MultihashKeyFromCID(txNode.Cid())
+		txModel.WitnessCID = witnessTxNode.Cid().String()
+		txModel.WitnessMhKey = shared.MultihashKeyFromCID(witnessTxNode.Cid())
 		txID, err := pub.indexer.indexTransactionCID(tx, txModel, headerID)
 		if err != nil {
 			return nil, err
@@ -116,6 +146,9 @@ func (pub *IPLDPublisherAndIndexer) Publish(payload shared.ConvertedData) (share
 		}
 	}
 
+	// Connect the block to the chain and link its inputs and outputs to the outputs and inputs they spend and are spent by
+	err = pub.indexer.connectBlock(tx, headerID)
+
 	// This IPLDPublisher does both publishing and indexing, we do not need to pass anything forward to the indexer
 	return nil, err
 }
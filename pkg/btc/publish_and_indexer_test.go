@@ -18,7 +18,14 @@ package btc_test
 
 import (
 	"bytes"
+	"database/sql"
+	"math/big"
 
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
 	"github.com/ipfs/go-cid"
 	"github.com/ipfs/go-ipfs-blockstore"
 	"github.com/ipfs/go-ipfs-ds-help"
@@ -117,5 +124,290 @@ var _ = Describe("PublishAndIndexer", func() {
 				Expect(data).To(Equal(txData[tx.Index]))
 			}
 		})
+
+		It("Links inputs to the outputs they spend regardless of the order the blocks are published in", func() {
+			spentOutputPgStr := `SELECT tx_inputs.output_id FROM btc.tx_inputs
+				INNER JOIN btc.transaction_cids ON (tx_inputs.tx_id = transaction_cids.id)
+				WHERE transaction_cids.tx_hash = $1`
+			expectedOutputPgStr := `SELECT tx_outputs.id FROM btc.tx_outputs
+				INNER JOIN btc.transaction_cids ON (tx_outputs.tx_id = transaction_cids.id)
+				WHERE transaction_cids.tx_hash = $1 AND tx_outputs.index = 0`
+			spendingTxHash := mocks.MockSpendingBlock.Transactions[1].TxHash().String()
+			spentTxHash := mocks.MockBlock.Transactions[1].TxHash().String()
+			for _, payloads := range [][]btc.ConvertedPayload{
+				{mocks.MockConvertedPayload, mocks.MockSpendingConvertedPayload},
+				{mocks.MockSpendingConvertedPayload, mocks.MockConvertedPayload},
+			} {
+				for _, payload := range payloads {
+					_, err = repo.Publish(payload)
+					Expect(err).ToNot(HaveOccurred())
+				}
+				var outputID sql.NullInt64
+				err = db.Get(&outputID, spentOutputPgStr, spendingTxHash)
+				Expect(err).ToNot(HaveOccurred())
+				var expectedOutputID int64
+				err = db.Get(&expectedOutputID, expectedOutputPgStr, spentTxHash)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(outputID.Valid).To(BeTrue())
+				Expect(outputID.Int64).To(Equal(expectedOutputID))
+
+				// the inputs spending outputs we don't have are left unlinked
+				err = db.Get(&outputID, spentOutputPgStr, spentTxHash)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(outputID.Valid).To(BeFalse())
+				btc.TearDownDB(db)
+			}
+		})
+
+		It("Computes the fees of transactions once the outputs they spend are indexed", func() {
+			// converted without having seen the block it spends from, so its fees are unknown
+			payload, err := btc.NewPayloadConverter(&chaincfg.MainNetParams, nil).Convert(mocks.MockSpendingBlockPayload)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = repo.Publish(payload)
+			Expect(err).ToNot(HaveOccurred())
+			spendingTxHash := mocks.MockSpendingBlock.Transactions[1].TxHash().String()
+			spendingHeaderPgStr := `SELECT * FROM btc.header_cids WHERE block_number = $1`
+			var fee sql.NullInt64
+			err = db.Get(&fee, `SELECT fee FROM btc.transaction_cids WHERE tx_hash = $1`, spendingTxHash)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(fee.Valid).To(BeFalse())
+			header := new(btc.HeaderModel)
+			err = db.Get(header, spendingHeaderPgStr, mocks.MockSpendingBlockHeight)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(header.Fees).To(BeNil())
+			Expect(header.Subsidy).ToNot(BeNil())
+			Expect(*header.Subsidy).To(Equal(int64(5000000000)))
+			Expect(header.CoinbaseValue).To(Equal(int64(5000010000)))
+
+			_, err = repo.Publish(mocks.MockConvertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+			tx := new(btc.TxModel)
+			err = db.Get(tx, `SELECT * FROM btc.transaction_cids WHERE tx_hash = $1`, spendingTxHash)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*tx.Fee).To(Equal(int64(10000)))
+			vsize := (*tx.Weight + 3) / 4
+			Expect(*tx.FeeRate).To(BeNumerically("~", float64(10000)/float64(vsize), 0.000001))
+			err = db.Get(header, spendingHeaderPgStr, mocks.MockSpendingBlockHeight)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(*header.Fees).To(Equal(int64(10000)))
+		})
+
+		It("Orphans the fork with less work and keeps the history of the transactions it included", func() {
+			expectOrphaned := func(block wire.MsgBlock, orphaned bool) {
+				var isOrphaned bool
+				err := db.Get(&isOrphaned, `SELECT orphaned FROM btc.header_cids WHERE block_hash = $1`, block.Header.BlockHash().String())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(isOrphaned).To(Equal(orphaned))
+			}
+			for _, payload := range []btc.ConvertedPayload{mocks.MockConvertedPayload, mocks.MockForkConvertedPayload} {
+				_, err = repo.Publish(payload)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			// the fork has the same work, so the block seen first stays on the best chain
+			expectOrphaned(mocks.MockBlock, false)
+			expectOrphaned(mocks.MockForkBlock, true)
+
+			_, err = repo.Publish(mocks.MockForkChildConvertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+			expectOrphaned(mocks.MockBlock, true)
+			expectOrphaned(mocks.MockForkBlock, false)
+			expectOrphaned(mocks.MockForkChildBlock, false)
+			var chainWork string
+			err = db.Get(&chainWork, `SELECT chain_work FROM btc.header_cids WHERE block_hash = $1`, mocks.MockForkChildBlock.Header.BlockHash().String())
+			Expect(err).ToNot(HaveOccurred())
+			work := blockchain.CalcWork(mocks.MockForkChildBlock.Header.Bits)
+			Expect(chainWork).To(Equal(work.Mul(work, big.NewInt(2)).String()))
+
+			// the transaction included in both blocks is indexed once for each of them
+			blockHashes := make([]string, 0)
+			err = db.Select(&blockHashes, `SELECT header_cids.block_hash FROM btc.transaction_cids
+				INNER JOIN btc.header_cids ON (transaction_cids.header_id = header_cids.id)
+				WHERE transaction_cids.tx_hash = $1
+				ORDER BY header_cids.id`, mocks.MockBlock.Transactions[1].TxHash().String())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blockHashes).To(Equal([]string{mocks.MockBlock.Header.BlockHash().String(), mocks.MockForkBlock.Header.BlockHash().String()}))
+		})
+
+		It("Resets the fees that depended on the outputs of disconnected blocks", func() {
+			for _, payload := range []btc.ConvertedPayload{mocks.MockConvertedPayload, mocks.MockSpendingConvertedPayload, mocks.MockSegwitConvertedPayload} {
+				_, err = repo.Publish(payload)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			expectFees := func(block wire.MsgBlock, fee *int64) {
+				tx := new(btc.TxModel)
+				err := db.Get(tx, `SELECT * FROM btc.transaction_cids WHERE tx_hash = $1`, block.Transactions[1].TxHash().String())
+				Expect(err).ToNot(HaveOccurred())
+				header := new(btc.HeaderModel)
+				err = db.Get(header, `SELECT * FROM btc.header_cids WHERE block_hash = $1`, block.Header.BlockHash().String())
+				Expect(err).ToNot(HaveOccurred())
+				if fee == nil {
+					Expect(tx.Fee).To(BeNil())
+					Expect(tx.FeeRate).To(BeNil())
+					Expect(header.Fees).To(BeNil())
+					return
+				}
+				Expect(*tx.Fee).To(Equal(*fee))
+				Expect(tx.FeeRate).ToNot(BeNil())
+				Expect(*header.Fees).To(Equal(*fee))
+			}
+			spendingFee := int64(10000)
+			segwitFee := mocks.MockBlock.Transactions[2].TxOut[0].Value - mocks.MockSegwitBlock.Transactions[1].TxOut[0].Value
+			expectFees(mocks.MockSpendingBlock, &spendingFee)
+			expectFees(mocks.MockSegwitBlock, &segwitFee)
+
+			// the fork disconnects MockBlock; the output spent by MockSpendingBlock is also in the fork,
+			// but the one spent by MockSegwitBlock is not on the best chain anymore
+			for _, payload := range []btc.ConvertedPayload{mocks.MockForkConvertedPayload, mocks.MockForkChildConvertedPayload, mocks.MockForkGrandchildConvertedPayload} {
+				_, err = repo.Publish(payload)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			var orphaned bool
+			err = db.Get(&orphaned, `SELECT orphaned FROM btc.header_cids WHERE block_hash = $1`, mocks.MockBlock.Header.BlockHash().String())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(orphaned).To(BeTrue())
+			expectFees(mocks.MockSpendingBlock, &spendingFee)
+			expectFees(mocks.MockSegwitBlock, nil)
+			var spentBlockHash string
+			err = db.Get(&spentBlockHash, `SELECT header_cids.block_hash FROM btc.tx_inputs, btc.tx_outputs, btc.transaction_cids AS spending, btc.transaction_cids AS spent, btc.header_cids
+				WHERE tx_inputs.tx_id = spending.id
+				AND tx_inputs.output_id = tx_outputs.id
+				AND tx_outputs.tx_id = spent.id
+				AND spent.header_id = header_cids.id
+				AND spending.tx_hash = $1`, mocks.MockSpendingBlock.Transactions[1].TxHash().String())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(spentBlockHash).To(Equal(mocks.MockForkBlock.Header.BlockHash().String()))
+		})
+
+		It("Connects blocks published before their parents", func() {
+			for _, payload := range []btc.ConvertedPayload{mocks.MockConvertedPayload, mocks.MockForkChildConvertedPayload, mocks.MockForkConvertedPayload} {
+				_, err = repo.Publish(payload)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			headers := make([]btc.HeaderModel, 0)
+			err = db.Select(&headers, `SELECT * FROM btc.header_cids WHERE NOT orphaned ORDER BY block_number`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(headers)).To(Equal(2))
+			Expect(headers[0].BlockHash).To(Equal(mocks.MockForkBlock.Header.BlockHash().String()))
+			Expect(headers[1].BlockHash).To(Equal(mocks.MockForkChildBlock.Header.BlockHash().String()))
+			work := blockchain.CalcWork(mocks.MockForkChildBlock.Header.Bits)
+			Expect(*headers[1].ChainWork).To(Equal(work.Mul(work, big.NewInt(2)).String()))
+		})
+
+		It("Reconnects the blocks above a gap once the gap is filled", func() {
+			// the tip is indexed before the gap below it is backfilled, and stays on the best chain without its chain work
+			// while the lighter first block of the gap is indexed
+			for _, payload := range []btc.ConvertedPayload{mocks.MockConvertedPayload, mocks.MockExtensionChildConvertedPayload, mocks.MockProofConvertedPayload} {
+				_, err = repo.Publish(payload)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			tip := new(btc.HeaderModel)
+			err = db.Get(tip, `SELECT * FROM btc.header_cids WHERE block_hash = $1`, mocks.MockExtensionChildBlock.Header.BlockHash().String())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tip.Orphaned).To(BeFalse())
+			Expect(tip.ChainWork).To(BeNil())
+
+			_, err = repo.Publish(mocks.MockExtensionConvertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+			headers := make([]btc.HeaderModel, 0)
+			err = db.Select(&headers, `SELECT * FROM btc.header_cids WHERE NOT orphaned ORDER BY block_number`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(headers)).To(Equal(4))
+			Expect(headers[0].BlockHash).To(Equal(mocks.MockBlock.Header.BlockHash().String()))
+			Expect(headers[1].BlockHash).To(Equal(mocks.MockProofBlock.Header.BlockHash().String()))
+			Expect(headers[2].BlockHash).To(Equal(mocks.MockExtensionBlock.Header.BlockHash().String()))
+			Expect(headers[3].BlockHash).To(Equal(mocks.MockExtensionChildBlock.Header.BlockHash().String()))
+			work := blockchain.CalcWork(mocks.MockBlock.Header.Bits)
+			work.Mul(work, big.NewInt(3))
+			Expect(*headers[3].ChainWork).To(Equal(work.Add(work, blockchain.CalcWork(mocks.MockProofBlock.Header.Bits)).String()))
+		})
+
+		It("Leaves blocks whose parents aren't indexed out of the comparison with the best chain until they are connected", func() {
+			for _, payload := range []btc.ConvertedPayload{mocks.MockConvertedPayload, mocks.MockSpendingConvertedPayload, mocks.MockForkGrandchildConvertedPayload} {
+				_, err = repo.Publish(payload)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			readHeader := func(block wire.MsgBlock) *btc.HeaderModel {
+				header := new(btc.HeaderModel)
+				err := db.Get(header, `SELECT * FROM btc.header_cids WHERE block_hash = $1`, block.Header.BlockHash().String())
+				Expect(err).ToNot(HaveOccurred())
+				return header
+			}
+			// the grandchild has less work of its own than the best chain, but isn't orphaned by it
+			grandchild := readHeader(mocks.MockForkGrandchildBlock)
+			Expect(grandchild.Orphaned).To(BeFalse())
+			Expect(grandchild.ChainWork).To(BeNil())
+			Expect(readHeader(mocks.MockBlock).Orphaned).To(BeFalse())
+			Expect(readHeader(mocks.MockSpendingBlock).Orphaned).To(BeFalse())
+
+			// the fork is lighter until its child connects the grandchild to it
+			_, err = repo.Publish(mocks.MockForkConvertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(readHeader(mocks.MockForkBlock).Orphaned).To(BeTrue())
+			Expect(readHeader(mocks.MockForkGrandchildBlock).Orphaned).To(BeFalse())
+			_, err = repo.Publish(mocks.MockForkChildConvertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(readHeader(mocks.MockBlock).Orphaned).To(BeTrue())
+			Expect(readHeader(mocks.MockSpendingBlock).Orphaned).To(BeTrue())
+			Expect(readHeader(mocks.MockForkBlock).Orphaned).To(BeFalse())
+			Expect(readHeader(mocks.MockForkChildBlock).Orphaned).To(BeFalse())
+			grandchild = readHeader(mocks.MockForkGrandchildBlock)
+			Expect(grandchild.Orphaned).To(BeFalse())
+			work := blockchain.CalcWork(mocks.MockForkGrandchildBlock.Header.Bits)
+			Expect(*grandchild.ChainWork).To(Equal(work.Mul(work, big.NewInt(3)).String()))
+		})
+
+		It("Publishes the stripped and witness forms of segwit transactions and the block's witness commitment", func() {
+			_, err = repo.Publish(mocks.MockSegwitConvertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+			trxs := make([]btc.TxModel, 0)
+			err = db.Select(&trxs, `SELECT transaction_cids.* FROM btc.transaction_cids
+				INNER JOIN btc.header_cids ON (transaction_cids.header_id = header_cids.id)
+				WHERE header_cids.block_hash = $1
+				ORDER BY transaction_cids.index`, mocks.MockSegwitBlock.Header.BlockHash().String())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(trxs)).To(Equal(2))
+			for i, trx := range trxs {
+				msgTx := mocks.MockSegwitBlock.Transactions[i]
+				Expect(trx.SegWit).To(BeTrue())
+				var stripped, full bytes.Buffer
+				Expect(msgTx.SerializeNoWitness(&stripped)).To(Succeed())
+				Expect(msgTx.Serialize(&full)).To(Succeed())
+				var data []byte
+				err = db.Get(&data, ipfsPgGet, trx.MhKey)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(Equal(stripped.Bytes()))
+				err = db.Get(&data, ipfsPgGet, trx.WitnessMhKey)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(data).To(Equal(full.Bytes()))
+				// the stripped form hashes to the txid and the witness form to the wtxid
+				c, _ := ipld.RawdataToCid(ipld.MBitcoinTx, stripped.Bytes(), multihash.DBL_SHA2_256)
+				Expect(trx.CID).To(Equal(c.String()))
+				c, _ = ipld.RawdataToCid(ipld.MBitcoinTx, full.Bytes(), multihash.DBL_SHA2_256)
+				Expect(trx.WitnessCID).To(Equal(c.String()))
+			}
+
+			// the coinbase links to the commitment, which hashes to the commitment it carries and links to the witness merkle root
+			_, txNodes, _, err := ipld.FromHeaderAndTxs(&mocks.MockSegwitBlock.Header, mocks.MockSegwitConvertedPayload.Txs)
+			Expect(err).ToNot(HaveOccurred())
+			_, witnessTrie, witnessCommitment, err := ipld.FromWitnessTxs(txNodes)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(witnessCommitment).ToNot(BeNil())
+			lnk, _, err := txNodes[0].ResolveLink([]string{"witnessCommitment"})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(lnk.Cid).To(Equal(witnessCommitment.Cid()))
+			var commitmentData []byte
+			err = db.Get(&commitmentData, ipfsPgGet, shared.MultihashKeyFromCID(lnk.Cid))
+			Expect(err).ToNot(HaveOccurred())
+			commitment, ok := blockchain.ExtractWitnessCommitment(btcutil.NewTx(mocks.MockSegwitBlock.Transactions[0]))
+			Expect(ok).To(BeTrue())
+			Expect(chainhash.DoubleHashB(commitmentData)).To(Equal(commitment))
+			witnessMerkles := blockchain.BuildMerkleTreeStore(mocks.MockSegwitConvertedPayload.Txs, true)
+			Expect(commitmentData[:32]).To(Equal(witnessMerkles[len(witnessMerkles)-1][:]))
+			Expect(witnessCommitment.Root.Cid).To(Equal(witnessTrie[len(witnessTrie)-1].Cid()))
+			var rootData []byte
+			err = db.Get(&rootData, ipfsPgGet, shared.MultihashKeyFromCID(witnessCommitment.Root.Cid))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rootData).To(Equal(witnessTrie[len(witnessTrie)-1].RawData()))
+		})
 	})
 })
@@ -28,9 +28,10 @@ import (
 
 // IPLDPublisher satisfies the IPLDPublisher for ethereum
 type IPLDPublisher struct {
-	HeaderPutter          ipfs.DagPutter
-	TransactionPutter     ipfs.DagPutter
-	TransactionTriePutter ipfs.DagPutter
+	HeaderPutter            ipfs.DagPutter
+	TransactionPutter       ipfs.DagPutter
+	TransactionTriePutter   ipfs.DagPutter
+	WitnessCommitmentPutter ipfs.DagPutter
 }
 
 // NewIPLDPublisher creates a pointer to a new Publisher which satisfies the IPLDPublisher interface
@@ -39,11 +40,25 @@ func NewIPLDPublisher(ipfsPath string) (*IPLDPublisher, error) {
 	if err != nil {
 		return nil, err
 	}
+	return newIPLDPublisher(node), nil
+}
+
+// NewRemoteIPLDPublisher creates a pointer to a new IPLDPublisher which publishes to the IPFS daemon at the given HTTP API address
+func NewRemoteIPLDPublisher(ipfsAPI string) (*IPLDPublisher, error) {
+	node, err := ipfs.InitRemoteIPFSNode(ipfsAPI)
+	if err != nil {
+		return nil, err
+	}
+	return newIPLDPublisher(node), nil
+}
+
+func newIPLDPublisher(node *ipfs.IPFS) *IPLDPublisher {
 	return &IPLDPublisher{
-		HeaderPutter:          dag_putters.NewBtcHeaderDagPutter(node),
-		TransactionPutter:     dag_putters.NewBtcTxDagPutter(node),
-		TransactionTriePutter: dag_putters.NewBtcTxTrieDagPutter(node),
-	}, nil
+		HeaderPutter:            dag_putters.NewBtcHeaderDagPutter(node),
+		TransactionPutter:       dag_putters.NewBtcTxDagPutter(node),
+		TransactionTriePutter:   dag_putters.NewBtcTxTrieDagPutter(node),
+		WitnessCommitmentPutter: dag_putters.NewBtcWitnessCommitmentDagPutter(node),
+	}
 }
 
 // Publish publishes an IPLDPayload to IPFS and returns the corresponding CIDPayload
@@ -64,19 +79,26 @@ func (pub *IPLDPublisher) Publish(payload shared.ConvertedData) (shared.CIDsForI
 	}
 	mhKey, _ := shared.MultihashKeyFromCIDString(headerCid)
 	header := HeaderModel{
-		CID:         headerCid,
-		MhKey:       mhKey,
-		ParentHash:  ipldPayload.Header.PrevBlock.String(),
-		BlockNumber: strconv.Itoa(int(ipldPayload.BlockPayload.BlockHeight)),
-		BlockHash:   ipldPayload.Header.BlockHash().String(),
-		Timestamp:   ipldPayload.Header.Timestamp.UnixNano(),
-		Bits:        ipldPayload.Header.Bits,
+		CID:           headerCid,
+		MhKey:         mhKey,
+		ParentHash:    ipldPayload.Header.PrevBlock.String(),
+		BlockNumber:   strconv.Itoa(int(ipldPayload.BlockPayload.BlockHeight)),
+		BlockHash:     ipldPayload.Header.BlockHash().String(),
+		Timestamp:     ipldPayload.Header.Timestamp.UnixNano(),
+		Bits:          ipldPayload.Header.Bits,
+		CoinbaseValue: ipldPayload.CoinbaseValue,
+		Subsidy:       &ipldPayload.Subsidy,
+		Fees:          ipldPayload.Fees,
 	}
 	// Process and publish transactions
 	transactionCids, err := pub.publishTransactions(txNodes, txTrieNodes, ipldPayload.TxMetaData)
 	if err != nil {
 		return nil, err
 	}
+	// Process and publish the witness forms of the transactions
+	if err := pub.publishWitnessTransactions(txNodes, transactionCids); err != nil {
+		return nil, err
+	}
 	// Package CIDs and their metadata into a single struct
 	return &CIDPayload{
 		HeaderCID:       header,
@@ -107,6 +129,9 @@ func (pub *IPLDPublisher) publishTransactions(transactions []*ipld.BtcTx, txTrie
 			TxHash:      trxMeta[i].TxHash,
 			SegWit:      trxMeta[i].SegWit,
 			WitnessHash: trxMeta[i].WitnessHash,
+			Weight:      trxMeta[i].Weight,
+			Fee:         trxMeta[i].Fee,
+			FeeRate:     trxMeta[i].FeeRate,
 			TxInputs:    trxMeta[i].TxInputs,
 			TxOutputs:   trxMeta[i].TxOutputs,
 		}
@@ -119,3 +144,37 @@ func (pub *IPLDPublisher) publishTransactions(transactions []*ipld.BtcTx, txTrie
 	}
 	return txCids, nil
 }
+
+func (pub *IPLDPublisher) publishWitnessTransactions(transactions []*ipld.BtcTx, txCids []TxModelWithInsAndOuts) error {
+	witnessTxs, witnessTrie, witnessCommitment, err := ipld.FromWitnessTxs(transactions)
+	if err != nil {
+		return err
+	}
+	for i, witnessTx := range witnessTxs {
+		// txs without witness data have the same IPLD in both forms
+		if witnessTx == transactions[i] {
+			txCids[i].WitnessCID = txCids[i].CID
+			txCids[i].WitnessMhKey = txCids[i].MhKey
+			continue
+		}
+		cid, err := pub.TransactionPutter.DagPut(witnessTx)
+		if err != nil {
+			return err
+		}
+		mhKey, _ := shared.MultihashKeyFromCIDString(cid)
+		txCids[i].WitnessCID = cid
+		txCids[i].WitnessMhKey = mhKey
+	}
+	for _, witnessNode := range witnessTrie {
+		// We don't do anything with the witness trie cids atm
+		if _, err := pub.TransactionTriePutter.DagPut(witnessNode); err != nil {
+			return err
+		}
+	}
+	if witnessCommitment != nil {
+		if _, err := pub.WitnessCommitmentPutter.DagPut(witnessCommitment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -17,8 +17,10 @@
 package btc
 
 import (
+	"encoding/hex"
 	"errors"
 	"math/big"
+	"strings"
 
 	"github.com/spf13/viper"
 
@@ -42,13 +44,20 @@ type HeaderFilter struct {
 
 // TxFilter contains filter settings for txs
 type TxFilter struct {
-	Off             bool
-	Segwit          bool     // allow filtering for segwit trxs
-	WitnessHashes   []string // allow filtering for specific witness hashes
-	Indexes         []int64  // allow filtering for specific transaction indexes (e.g. 0 for coinbase transactions)
-	PkScriptClasses []uint8  // allow filtering for txs that have at least one tx output with the specified pkscript class
-	MultiSig        bool     // allow filtering for txs that have at least one tx output that requires more than one signature
-	Addresses       []string // allow filtering for txs that have at least one tx output with at least one of the provided addresses
+	Off               bool
+	Segwit            bool     // allow filtering for segwit trxs
+	WitnessHashes     []string // allow filtering for specific witness hashes
+	Indexes           []int64  // allow filtering for specific transaction indexes (e.g. 0 for coinbase transactions)
+	PkScriptClasses   []uint8  // allow filtering for txs that have at least one tx output with the specified pkscript class
+	MultiSig          bool     // allow filtering for txs that have at least one tx output that requires more than one signature
+	Addresses         []string // allow filtering for txs that have at least one tx output with at least one of the provided addresses
+	SpendingAddresses []string // allow filtering for txs that have at least one tx input spending an output with at least one of the provided addresses
+	OpReturnPrefixes  []string // allow filtering for txs that have at least one OP_RETURN tx output whose data starts with one of the provided hex encoded prefixes
+	MinValue          int64    // allow filtering for txs whose outputs total at least this many satoshis
+	MaxValue          int64    // allow filtering for txs whose outputs total at most this many satoshis
+	MinFee            int64    // allow filtering for txs that pay at least this many satoshis in fees
+	MinFeeRate        float64  // allow filtering for txs that pay at least this many satoshis per virtual byte in fees
+	Witness           bool     // return txs including their witness data instead of in their stripped form
 }
 
 // Init is used to initialize a EthSubscription struct with env variables
@@ -77,18 +86,42 @@ func NewBtcSubscriptionConfig() (*SubscriptionSettings, error) {
 	if !ok {
 		return nil, errors.New("watcher.btcSubscription.txFilter.indexes needs to be an array of int64s")
 	}
+	opReturnPrefixes := viper.GetStringSlice("watcher.btcSubscription.txFilter.opReturnPrefixes")
+	if _, err := decodeHexPrefixes(opReturnPrefixes); err != nil {
+		return nil, errors.New("watcher.btcSubscription.txFilter.opReturnPrefixes needs to be an array of hex strings")
+	}
 	sc.TxFilter = TxFilter{
-		Off:             viper.GetBool("watcher.btcSubscription.txFilter.off"),
-		Segwit:          viper.GetBool("watcher.btcSubscription.txFilter.segwit"),
-		WitnessHashes:   viper.GetStringSlice("watcher.btcSubscription.txFilter.witnessHashes"),
-		PkScriptClasses: pkScriptClasses,
-		Indexes:         indexes,
-		MultiSig:        viper.GetBool("watcher.btcSubscription.txFilter.multiSig"),
-		Addresses:       viper.GetStringSlice("watcher.btcSubscription.txFilter.addresses"),
+		Off:               viper.GetBool("watcher.btcSubscription.txFilter.off"),
+		Segwit:            viper.GetBool("watcher.btcSubscription.txFilter.segwit"),
+		WitnessHashes:     viper.GetStringSlice("watcher.btcSubscription.txFilter.witnessHashes"),
+		PkScriptClasses:   pkScriptClasses,
+		Indexes:           indexes,
+		MultiSig:          viper.GetBool("watcher.btcSubscription.txFilter.multiSig"),
+		Addresses:         viper.GetStringSlice("watcher.btcSubscription.txFilter.addresses"),
+		SpendingAddresses: viper.GetStringSlice("watcher.btcSubscription.txFilter.spendingAddresses"),
+		OpReturnPrefixes:  opReturnPrefixes,
+		MinValue:          viper.GetInt64("watcher.btcSubscription.txFilter.minValue"),
+		MaxValue:          viper.GetInt64("watcher.btcSubscription.txFilter.maxValue"),
+		MinFee:            viper.GetInt64("watcher.btcSubscription.txFilter.minFee"),
+		MinFeeRate:        viper.GetFloat64("watcher.btcSubscription.txFilter.minFeeRate"),
+		Witness:           viper.GetBool("watcher.btcSubscription.txFilter.witness"),
 	}
 	return sc, nil
 }
 
+// decodeHexPrefixes decodes the hex encoded OP_RETURN data prefixes, with or without a 0x prefix
+func decodeHexPrefixes(prefixes []string) ([][]byte, error) {
+	decoded := make([][]byte, len(prefixes))
+	for i, prefix := range prefixes {
+		var err error
+		decoded[i], err = hex.DecodeString(strings.TrimPrefix(prefix, "0x"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return decoded, nil
+}
+
 // StartingBlock satisfies the SubscriptionSettings() interface
 func (sc *SubscriptionSettings) StartingBlock() *big.Int {
 	return sc.Start
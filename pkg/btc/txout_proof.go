@@ -0,0 +1,344 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// maxProofTransactions is the most transactions a block can hold, a proof claiming more is invalid
+const maxProofTransactions = blockchain.MaxBlockWeight / (blockchain.WitnessScaleFactor * 60)
+
+// GetTxOutProof returns the hex encoded merkle block proving the inclusion of the transactions with the given ids
+// If no block hash is given the block the first transaction was included in on the best chain is used
+func (pba *PublicBtcAPI) GetTxOutProof(txids []string, blockHash *string) (string, error) {
+	hashes := make([]chainhash.Hash, len(txids))
+	for i, txid := range txids {
+		hash, err := chainhash.NewHashFromStr(txid)
+		if err != nil {
+			return "", err
+		}
+		hashes[i] = *hash
+	}
+	var block *chainhash.Hash
+	if blockHash != nil {
+		var err error
+		block, err = chainhash.NewHashFromStr(*blockHash)
+		if err != nil {
+			return "", err
+		}
+	}
+	proof, err := pba.B.TxOutProof(hashes, block)
+	if err != nil {
+		return "", err
+	}
+	return messageToHex(proof)
+}
+
+// VerifyTxOutProof returns the ids of the transactions the hex encoded merkle block proves the inclusion of
+// Like bitcoind it returns an error if the block is not on the best chain, and no ids if the proof is invalid
+func (pba *PublicBtcAPI) VerifyTxOutProof(proof string) ([]string, error) {
+	proofBytes, err := hex.DecodeString(proof)
+	if err != nil {
+		return nil, err
+	}
+	merkleBlock := new(wire.MsgMerkleBlock)
+	if err := merkleBlock.BtcDecode(bytes.NewReader(proofBytes), wire.ProtocolVersion, wire.WitnessEncoding); err != nil {
+		return nil, err
+	}
+	txids := make([]string, 0)
+	root, matches, err := ExtractMatches(merkleBlock)
+	if err != nil || root != merkleBlock.Header.MerkleRoot {
+		return txids, nil
+	}
+	headerCID, txCIDs, err := pba.B.Retriever.RetrieveBlockByHash(merkleBlock.Header.BlockHash())
+	if err != nil || headerCID.Orphaned || len(txCIDs) == 0 {
+		return nil, fmt.Errorf("block %s not found in chain", merkleBlock.Header.BlockHash().String())
+	}
+	if len(txCIDs) != int(merkleBlock.Transactions) {
+		return txids, nil
+	}
+	for _, match := range matches {
+		txids = append(txids, match.String())
+	}
+	return txids, nil
+}
+
+// TxOutProof returns the merkle block proving the inclusion of the transactions with the provided ids
+// If no block hash is provided the transactions are looked up in the block the first of them was included in on the best chain
+// The proof is built from the transaction merkle tree nodes we have stored, so the block needs to have been indexed with them
+func (b *Backend) TxOutProof(txids []chainhash.Hash, blockHash *chainhash.Hash) (*wire.MsgMerkleBlock, error) {
+	if len(txids) == 0 {
+		return nil, errors.New("no transaction ids provided")
+	}
+	wanted := make(map[chainhash.Hash]bool, len(txids))
+	for _, txid := range txids {
+		if wanted[txid] {
+			return nil, fmt.Errorf("duplicated transaction id %s", txid.String())
+		}
+		wanted[txid] = true
+	}
+	// Begin tx
+	tx, err := b.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var headerCID HeaderModel
+	if blockHash != nil {
+		headerCID, err = b.Retriever.RetrieveHeaderCIDByHash(tx, *blockHash)
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("block %s not found", blockHash.String())
+		}
+	} else {
+		var txCID TxModel
+		txCID, err = b.Retriever.RetrieveTxCIDByHash(tx, txids[0])
+		if err == sql.ErrNoRows {
+			err = fmt.Errorf("transaction %s not found in a block", txids[0].String())
+		}
+		if err != nil {
+			return nil, err
+		}
+		headerCID, err = b.Retriever.RetrieveHeaderCIDByID(tx, txCID.HeaderID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var txCIDs []TxModel
+	txCIDs, err = b.Retriever.RetrieveTxCIDsByHeaderID(tx, headerCID.ID)
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]bool, len(txCIDs))
+	found := 0
+	for i, txCID := range txCIDs {
+		var hash *chainhash.Hash
+		hash, err = chainhash.NewHashFromStr(txCID.TxHash)
+		if err != nil {
+			return nil, err
+		}
+		if wanted[*hash] {
+			matches[i] = true
+			found++
+		}
+	}
+	if found != len(wanted) {
+		err = fmt.Errorf("not all transactions found in block %s", headerCID.BlockHash)
+		return nil, err
+	}
+	var headerIPLD []byte
+	headerIPLD, err = b.Fetcher.Blockstores.Get(tx, shared.Headers, headerCID.MhKey)
+	if err != nil {
+		return nil, err
+	}
+	proof := &wire.MsgMerkleBlock{
+		Transactions: uint32(len(txCIDs)),
+	}
+	if err = proof.Header.Deserialize(bytes.NewReader(headerIPLD)); err != nil {
+		return nil, err
+	}
+	builder := &proofBuilder{
+		tx:      tx,
+		store:   b.Fetcher.Blockstores.For(shared.Transactions),
+		matches: matches,
+		proof:   proof,
+	}
+	if err = builder.build(treeHeight(len(txCIDs)), 0, proof.Header.MerkleRoot); err != nil {
+		return nil, err
+	}
+	proof.Flags = builder.flags()
+	return proof, err // need to return err variable so that we return the err = tx.Commit() assignment in the defer
+}
+
+// proofBuilder walks the stored transaction merkle tree of a block, depth first, to build the partial merkle tree
+// that proves the matched transactions
+type proofBuilder struct {
+	tx      *sqlx.Tx
+	store   shared.Blockstore
+	matches []bool
+	proof   *wire.MsgMerkleBlock
+	bits    []bool
+}
+
+// build adds the node at the provided height and position, whose hash is known from its parent, to the partial tree
+// Only the nodes on the paths from the root to the matched transactions are fetched and descended into
+func (pb *proofBuilder) build(height uint, pos int, hash chainhash.Hash) error {
+	parentOfMatch := false
+	for i := pos << height; i < (pos+1)<<height && i < len(pb.matches); i++ {
+		parentOfMatch = parentOfMatch || pb.matches[i]
+	}
+	pb.bits = append(pb.bits, parentOfMatch)
+	if height == 0 || !parentOfMatch {
+		h := hash
+		pb.proof.Hashes = append(pb.proof.Hashes, &h)
+		return nil
+	}
+	left, right, err := fetchMerkleNode(pb.tx, pb.store, hash)
+	if err != nil {
+		return err
+	}
+	if err := pb.build(height-1, pos*2, left); err != nil {
+		return err
+	}
+	if pos*2+1 < treeWidth(len(pb.matches), height-1) {
+		return pb.build(height-1, pos*2+1, right)
+	}
+	return nil
+}
+
+// flags packs the traversal bits into bytes, least significant bit first
+func (pb *proofBuilder) flags() []byte {
+	flags := make([]byte, (len(pb.bits)+7)/8)
+	for i, bit := range pb.bits {
+		if bit {
+			flags[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return flags
+}
+
+// fetchMerkleNode fetches the transaction merkle tree node with the provided hash and returns the hashes of its children
+func fetchMerkleNode(tx *sqlx.Tx, store shared.Blockstore, hash chainhash.Hash) (chainhash.Hash, chainhash.Hash, error) {
+	var left, right chainhash.Hash
+	mhKey, err := shared.MultihashKeyFromDoubleSha256(hash[:])
+	if err != nil {
+		return left, right, err
+	}
+	node, err := store.Get(tx, mhKey)
+	if err == shared.ErrBlockNotFound {
+		return left, right, fmt.Errorf("missing transaction merkle tree node %s", hash.String())
+	}
+	if err != nil {
+		return left, right, err
+	}
+	if len(node) != 2*chainhash.HashSize || chainhash.DoubleHashH(node) != hash {
+		return left, right, fmt.Errorf("invalid transaction merkle tree node %s", hash.String())
+	}
+	copy(left[:], node[:chainhash.HashSize])
+	copy(right[:], node[chainhash.HashSize:])
+	return left, right, nil
+}
+
+// ExtractMatches checks the partial merkle tree of the merkle block and returns the merkle root it hashes to along with
+// the ids of the transactions it proves; the root still needs to be checked against the merkle root of the block's header
+func ExtractMatches(proof *wire.MsgMerkleBlock) (chainhash.Hash, []chainhash.Hash, error) {
+	if proof.Transactions == 0 {
+		return chainhash.Hash{}, nil, errors.New("merkle block has no transactions")
+	}
+	if proof.Transactions > maxProofTransactions {
+		return chainhash.Hash{}, nil, fmt.Errorf("merkle block claims %d transactions, more than fit in a block", proof.Transactions)
+	}
+	if len(proof.Hashes) > int(proof.Transactions) {
+		return chainhash.Hash{}, nil, errors.New("merkle block has more hashes than transactions")
+	}
+	if len(proof.Flags)*8 < len(proof.Hashes) {
+		return chainhash.Hash{}, nil, errors.New("merkle block has fewer flag bits than hashes")
+	}
+	extractor := &matchExtractor{
+		proof: proof,
+	}
+	root, err := extractor.extract(treeHeight(int(proof.Transactions)), 0)
+	if err != nil {
+		return chainhash.Hash{}, nil, err
+	}
+	// all of the hashes and all but the padding of the flags need to have been used
+	if (extractor.bitsUsed+7)/8 != len(proof.Flags) {
+		return chainhash.Hash{}, nil, errors.New("merkle block has unused flags")
+	}
+	if extractor.hashesUsed != len(proof.Hashes) {
+		return chainhash.Hash{}, nil, errors.New("merkle block has unused hashes")
+	}
+	return root, extractor.matches, nil
+}
+
+// matchExtractor walks the partial merkle tree of a merkle block, depth first, in the order it was built
+type matchExtractor struct {
+	proof      *wire.MsgMerkleBlock
+	bitsUsed   int
+	hashesUsed int
+	matches    []chainhash.Hash
+}
+
+func (me *matchExtractor) extract(height uint, pos int) (chainhash.Hash, error) {
+	if me.bitsUsed >= len(me.proof.Flags)*8 {
+		return chainhash.Hash{}, errors.New("merkle block ran out of flags")
+	}
+	parentOfMatch := me.proof.Flags[me.bitsUsed/8]&(1<<uint(me.bitsUsed%8)) != 0
+	me.bitsUsed++
+	if height == 0 || !parentOfMatch {
+		if me.hashesUsed >= len(me.proof.Hashes) {
+			return chainhash.Hash{}, errors.New("merkle block ran out of hashes")
+		}
+		hash := *me.proof.Hashes[me.hashesUsed]
+		me.hashesUsed++
+		if height == 0 && parentOfMatch {
+			me.matches = append(me.matches, hash)
+		}
+		return hash, nil
+	}
+	left, err := me.extract(height-1, pos*2)
+	if err != nil {
+		return chainhash.Hash{}, err
+	}
+	right := left
+	if pos*2+1 < treeWidth(int(me.proof.Transactions), height-1) {
+		right, err = me.extract(height-1, pos*2+1)
+		if err != nil {
+			return chainhash.Hash{}, err
+		}
+		// identical siblings would let a different list of transactions hash to the same root (CVE-2012-2459)
+		if right == left {
+			return chainhash.Hash{}, errors.New("merkle block has identical sibling hashes")
+		}
+	}
+	return *blockchain.HashMerkleBranches(&left, &right), nil
+}
+
+// treeHeight returns the height of the merkle tree over the provided number of transactions
+func treeHeight(transactions int) uint {
+	var height uint
+	for treeWidth(transactions, height) > 1 {
+		height++
+	}
+	return height
+}
+
+// treeWidth returns the number of nodes at the provided height of the merkle tree over the provided number of transactions
+func treeWidth(transactions int, height uint) int {
+	return (transactions + (1 << height) - 1) >> height
+}
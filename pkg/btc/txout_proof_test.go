@@ -0,0 +1,160 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package btc_test
+
+import (
+	"bytes"
+	"encoding/hex"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/bloom"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/client"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// decodeMerkleBlock decodes the hex encoded merkle block
+func decodeMerkleBlock(proof string) *wire.MsgMerkleBlock {
+	proofBytes, err := hex.DecodeString(proof)
+	Expect(err).ToNot(HaveOccurred())
+	merkleBlock := new(wire.MsgMerkleBlock)
+	Expect(merkleBlock.BtcDecode(bytes.NewReader(proofBytes), wire.ProtocolVersion, wire.WitnessEncoding)).To(Succeed())
+	return merkleBlock
+}
+
+// encodeMerkleBlock hex encodes the merkle block
+func encodeMerkleBlock(merkleBlock *wire.MsgMerkleBlock) string {
+	var buf bytes.Buffer
+	Expect(merkleBlock.BtcEncode(&buf, wire.ProtocolVersion, wire.WitnessEncoding)).To(Succeed())
+	return hex.EncodeToString(buf.Bytes())
+}
+
+var _ = Describe("TxOutProof", func() {
+	var (
+		db        *postgres.DB
+		api       *btc.PublicBtcAPI
+		blockHash = mocks.MockProofBlock.Header.BlockHash().String()
+		txids     = make([]string, len(mocks.MockProofBlock.Transactions))
+	)
+	for i, tx := range mocks.MockProofBlock.Transactions {
+		txids[i] = tx.TxHash().String()
+	}
+	BeforeEach(func() {
+		var err error
+		db, err = shared.SetupDB()
+		Expect(err).ToNot(HaveOccurred())
+		backend, err := btc.NewBtcBackend(db, &chaincfg.RegressionNetParams)
+		Expect(err).ToNot(HaveOccurred())
+		api = btc.NewPublicBtcAPI(backend)
+		_, err = btc.NewIPLDPublisherAndIndexer(db).Publish(mocks.MockProofConvertedPayload)
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		btc.TearDownDB(db)
+	})
+
+	Describe("GetTxOutProof and VerifyTxOutProof", func() {
+		It("Builds the same merkle blocks as bitcoind from the stored merkle tree", func() {
+			for _, indexes := range [][]int{{0}, {1}, {2}, {0, 2}, {0, 1, 2}} {
+				wanted := make([]string, len(indexes))
+				filter := bloom.NewFilter(uint32(len(indexes)), 0, 0.000001, wire.BloomUpdateNone)
+				for i, index := range indexes {
+					wanted[i] = txids[index]
+					hash := mocks.MockProofBlock.Transactions[index].TxHash()
+					filter.AddHash(&hash)
+				}
+				expected, _ := bloom.NewMerkleBlock(btcutil.NewBlock(&mocks.MockProofBlock), filter)
+
+				proof, err := api.GetTxOutProof(wanted, &blockHash)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(proof).To(Equal(encodeMerkleBlock(expected)))
+				proven, err := api.VerifyTxOutProof(proof)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(proven).To(Equal(wanted))
+			}
+		})
+
+		It("Looks up the block of the first transaction when no block hash is given", func() {
+			proof, err := api.GetTxOutProof([]string{txids[2], txids[1]}, nil)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(decodeMerkleBlock(proof).Header.BlockHash().String()).To(Equal(blockHash))
+			proven, err := api.VerifyTxOutProof(proof)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(proven).To(Equal([]string{txids[1], txids[2]}))
+		})
+
+		It("Errors for transactions that aren't in the block", func() {
+			_, err := api.GetTxOutProof([]string{txids[0], mocks.MockSpendingBlock.Transactions[1].TxHash().String()}, &blockHash)
+			Expect(err).To(HaveOccurred())
+			_, err = api.GetTxOutProof([]string{txids[0], txids[0]}, &blockHash)
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("Returns no transactions for proofs that don't hash to the header's merkle root", func() {
+			proof, err := api.GetTxOutProof([]string{txids[1]}, &blockHash)
+			Expect(err).ToNot(HaveOccurred())
+			merkleBlock := decodeMerkleBlock(proof)
+			merkleBlock.Hashes[0] = &chainhash.Hash{0x01}
+			proven, err := api.VerifyTxOutProof(encodeMerkleBlock(merkleBlock))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(proven).To(BeEmpty())
+		})
+	})
+
+	Describe("VerifyTxOutProof client helper", func() {
+		It("Verifies proofs against headers from the watcher", func() {
+			proof, err := api.GetTxOutProof([]string{txids[2]}, &blockHash)
+			Expect(err).ToNot(HaveOccurred())
+			headerHex, err := api.GetBlockHeader(blockHash, new(bool))
+			Expect(err).ToNot(HaveOccurred())
+			headerBytes, err := hex.DecodeString(headerHex.(string))
+			Expect(err).ToNot(HaveOccurred())
+			header := new(wire.BlockHeader)
+			Expect(header.Deserialize(bytes.NewReader(headerBytes))).To(Succeed())
+
+			proven, err := client.VerifyTxOutProof(header, decodeMerkleBlock(proof))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(proven).To(Equal([]chainhash.Hash{mocks.MockProofBlock.Transactions[2].TxHash()}))
+
+			// a proof for a different block
+			_, err = client.VerifyTxOutProof(&mocks.MockSpendingBlock.Header, decodeMerkleBlock(proof))
+			Expect(err).To(HaveOccurred())
+
+			// a header that doesn't satisfy its proof of work
+			badHeader := *header
+			badHeader.Nonce = 2
+			merkleBlock := decodeMerkleBlock(proof)
+			merkleBlock.Header = badHeader
+			_, err = client.VerifyTxOutProof(&badHeader, merkleBlock)
+			Expect(err).To(HaveOccurred())
+
+			// a proof that doesn't hash to the header's merkle root
+			merkleBlock = decodeMerkleBlock(proof)
+			merkleBlock.Hashes[0] = &chainhash.Hash{0x01}
+			_, err = client.VerifyTxOutProof(header, merkleBlock)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
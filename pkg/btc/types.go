@@ -37,7 +37,10 @@ type BlockPayload struct {
 // Passed to IPLDPublisher and ResponseFilterer
 type ConvertedPayload struct {
 	BlockPayload
-	TxMetaData []TxModelWithInsAndOuts
+	TxMetaData    []TxModelWithInsAndOuts
+	CoinbaseValue int64  // total value of the coinbase transaction's outputs
+	Subsidy       int64  // the new coins the block is allowed to create
+	Fees          *int64 // total fees of the block's transactions, nil if any of them are unknown
 }
 
 // Height satisfies the StreamedIPLDs interface
@@ -60,6 +63,7 @@ type CIDWrapper struct {
 	BlockNumber  *big.Int
 	Header       HeaderModel
 	Transactions []TxModel
+	Witness      bool // fetch the transactions including their witness data
 }
 
 // IPLDs is used to package raw IPLD block data fetched from IPFS and returned by the server
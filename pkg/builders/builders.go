@@ -20,10 +20,10 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/btcsuite/btcd/chaincfg"
 	"github.com/btcsuite/btcd/rpcclient"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ipfs/go-cid"
 
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
@@ -113,46 +113,67 @@ func NewPaylaodFetcher(chain shared.ChainType, client interface{}, timeout time.
 		}
 		return eth.NewPayloadFetcher(batchClient, timeout), nil
 	case shared.Bitcoin:
-		connConfig, ok := client.(*rpcclient.ConnConfig)
-		if !ok {
-			return nil, fmt.Errorf("bitcoin payload fetcher constructor expected client type %T got %T", &rpcclient.Client{}, client)
+		switch c := client.(type) {
+		case *rpcclient.ConnConfig:
+			return btc.NewPayloadFetcher(c)
+		case *btc.BlkFileConfig:
+			return btc.NewBlkFileFetcher(c)
+		default:
+			return nil, fmt.Errorf("bitcoin payload fetcher constructor expected client type %T or %T got %T", &rpcclient.ConnConfig{}, &btc.BlkFileConfig{}, client)
 		}
-		return btc.NewPayloadFetcher(connConfig)
 	default:
 		return nil, fmt.Errorf("invalid chain %s for payload fetcher constructor", chain.String())
 	}
 }
 
 // NewPayloadConverter constructs a PayloadConverter for the provided chain type
-func NewPayloadConverter(chain shared.ChainType) (shared.PayloadConverter, error) {
+func NewPayloadConverter(chain shared.ChainType, db *postgres.DB) (shared.PayloadConverter, error) {
 	switch chain {
 	case shared.Ethereum:
 		return eth.NewPayloadConverter(params.MainnetChainConfig), nil
 	case shared.Bitcoin:
-		return btc.NewPayloadConverter(&chaincfg.MainNetParams), nil
+		params, err := btc.ParamsFromNetworkID(db.Node.NetworkID)
+		if err != nil {
+			return nil, err
+		}
+		return btc.NewPayloadConverter(params, db), nil
 	default:
 		return nil, fmt.Errorf("invalid chain %s for converter constructor", chain.String())
 	}
 }
 
 // NewIPLDFetcher constructs an IPLDFetcher for the provided chain type
-func NewIPLDFetcher(chain shared.ChainType, ipfsPath string, db *postgres.DB, ipfsMode shared.IPFSMode) (shared.IPLDFetcher, error) {
+// In RemoteClient mode the ipfsPath is the address of the IPFS daemon's HTTP API
+// In DirectPostgres mode the IPLDs are fetched from the blockstores, or from Postgres if blockstores is nil
+func NewIPLDFetcher(chain shared.ChainType, ipfsPath string, db *postgres.DB, ipfsMode shared.IPFSMode, blockstores *shared.Blockstores) (shared.IPLDFetcher, error) {
 	switch chain {
 	case shared.Ethereum:
 		switch ipfsMode {
-		case shared.LocalInterface, shared.RemoteClient:
+		case shared.LocalInterface:
 			return eth.NewIPLDFetcher(ipfsPath)
+		case shared.RemoteClient:
+			return eth.NewRemoteIPLDFetcher(ipfsPath)
 		case shared.DirectPostgres:
-			return eth.NewIPLDPGFetcher(db), nil
+			fetcher := eth.NewIPLDPGFetcher(db)
+			if blockstores != nil {
+				fetcher.Blockstores = blockstores
+			}
+			return fetcher, nil
 		default:
 			return nil, fmt.Errorf("ethereum IPLDFetcher unexpected ipfs mode %s", ipfsMode.String())
 		}
 	case shared.Bitcoin:
 		switch ipfsMode {
-		case shared.LocalInterface, shared.RemoteClient:
+		case shared.LocalInterface:
 			return btc.NewIPLDFetcher(ipfsPath)
+		case shared.RemoteClient:
+			return btc.NewRemoteIPLDFetcher(ipfsPath)
 		case shared.DirectPostgres:
-			return btc.NewIPLDPGFetcher(db), nil
+			fetcher := btc.NewIPLDPGFetcher(db)
+			if blockstores != nil {
+				fetcher.Blockstores = blockstores
+			}
+			return fetcher, nil
 		default:
 			return nil, fmt.Errorf("bitcoin IPLDFetcher unexpected ipfs mode %s", ipfsMode.String())
 		}
@@ -162,23 +183,37 @@ func NewIPLDFetcher(chain shared.ChainType, ipfsPath string, db *postgres.DB, ip
 }
 
 // NewIPLDPublisher constructs an IPLDPublisher for the provided chain type
-func NewIPLDPublisher(chain shared.ChainType, ipfsPath string, db *postgres.DB, ipfsMode shared.IPFSMode) (shared.IPLDPublisher, error) {
+// In RemoteClient mode the ipfsPath is the address of the IPFS daemon's HTTP API
+// In DirectPostgres mode the IPLDs are published to the blockstores, or to Postgres if blockstores is nil
+func NewIPLDPublisher(chain shared.ChainType, ipfsPath string, db *postgres.DB, ipfsMode shared.IPFSMode, blockstores *shared.Blockstores) (shared.IPLDPublisher, error) {
 	switch chain {
 	case shared.Ethereum:
 		switch ipfsMode {
-		case shared.LocalInterface, shared.RemoteClient:
+		case shared.LocalInterface:
 			return eth.NewIPLDPublisher(ipfsPath)
+		case shared.RemoteClient:
+			return eth.NewRemoteIPLDPublisher(ipfsPath)
 		case shared.DirectPostgres:
-			return eth.NewIPLDPublisherAndIndexer(db), nil
+			pub := eth.NewIPLDPublisherAndIndexer(db)
+			if blockstores != nil {
+				pub.Blockstores = blockstores
+			}
+			return pub, nil
 		default:
 			return nil, fmt.Errorf("ethereum IPLDPublisher unexpected ipfs mode %s", ipfsMode.String())
 		}
 	case shared.Bitcoin:
 		switch ipfsMode {
-		case shared.LocalInterface, shared.RemoteClient:
+		case shared.LocalInterface:
 			return btc.NewIPLDPublisher(ipfsPath)
+		case shared.RemoteClient:
+			return btc.NewRemoteIPLDPublisher(ipfsPath)
 		case shared.DirectPostgres:
-			return btc.NewIPLDPublisherAndIndexer(db), nil
+			pub := btc.NewIPLDPublisherAndIndexer(db)
+			if blockstores != nil {
+				pub.Blockstores = blockstores
+			}
+			return pub, nil
 		default:
 			return nil, fmt.Errorf("bitcoin IPLDPublisher unexpected ipfs mode %s", ipfsMode.String())
 		}
@@ -188,17 +223,41 @@ func NewIPLDPublisher(chain shared.ChainType, ipfsPath string, db *postgres.DB,
 }
 
 // NewPublicAPI constructs a PublicAPI for the provided chain type
-func NewPublicAPI(chain shared.ChainType, db *postgres.DB, ipfsPath string) (rpc.API, error) {
+// The live payloads sent on payloadChan are used to feed the api's subscriptions
+// The api fetches IPLDs from the blockstores, or from Postgres if blockstores is nil
+func NewPublicAPI(chain shared.ChainType, db *postgres.DB, ipfsPath string, blockstores *shared.Blockstores, payloadChan <-chan shared.ConvertedData, quitChan <-chan bool) (rpc.API, error) {
 	switch chain {
 	case shared.Ethereum:
 		backend, err := eth.NewEthBackend(db)
 		if err != nil {
 			return rpc.API{}, err
 		}
+		if blockstores != nil {
+			backend.Fetcher.Blockstores = blockstores
+		}
+		events := eth.NewEventSystem(payloadChan, quitChan)
 		return rpc.API{
 			Namespace: eth.APIName,
 			Version:   eth.APIVersion,
-			Service:   eth.NewPublicEthAPI(backend),
+			Service:   eth.NewPublicEthAPI(backend, events, eth.NewFilterManager(events, eth.FilterTimeout, quitChan)),
+			Public:    true,
+		}, nil
+	case shared.Bitcoin:
+		params, err := btc.ParamsFromNetworkID(db.Node.NetworkID)
+		if err != nil {
+			return rpc.API{}, err
+		}
+		backend, err := btc.NewBtcBackend(db, params)
+		if err != nil {
+			return rpc.API{}, err
+		}
+		if blockstores != nil {
+			backend.Fetcher.Blockstores = blockstores
+		}
+		return rpc.API{
+			Namespace: btc.APIName,
+			Version:   btc.APIVersion,
+			Service:   btc.NewPublicBtcAPI(backend),
 			Public:    true,
 		}, nil
 	default:
@@ -207,13 +266,70 @@ func NewPublicAPI(chain shared.ChainType, db *postgres.DB, ipfsPath string) (rpc
 }
 
 // NewCleaner constructs a Cleaner for the provided chain type
-func NewCleaner(chain shared.ChainType, db *postgres.DB) (shared.Cleaner, error) {
+// The cleaner deletes IPLDs from the blockstores, or from Postgres if blockstores is nil
+func NewCleaner(chain shared.ChainType, db *postgres.DB, blockstores *shared.Blockstores) (shared.Cleaner, error) {
 	switch chain {
 	case shared.Ethereum:
-		return eth.NewCleaner(db), nil
+		cleaner := eth.NewCleaner(db)
+		if blockstores != nil {
+			cleaner.Blockstores = blockstores
+		}
+		return cleaner, nil
 	case shared.Bitcoin:
-		return btc.NewCleaner(db), nil
+		cleaner := btc.NewCleaner(db)
+		if blockstores != nil {
+			cleaner.Blockstores = blockstores
+		}
+		return cleaner, nil
 	default:
 		return nil, fmt.Errorf("invalid chain %s for cleaner constructor", chain.String())
 	}
 }
+
+// MhKeysPgStr returns the query for the mhkeys of the indexed IPLDs of the type of data at block heights between $1 and $2
+// for the provided chain type
+func MhKeysPgStr(chain shared.ChainType, t shared.DataType) (string, error) {
+	switch chain {
+	case shared.Ethereum:
+		return eth.MhKeysPgStr(t)
+	case shared.Bitcoin:
+		return btc.MhKeysPgStr(t)
+	default:
+		return "", fmt.Errorf("invalid chain %s for mhkeys query", chain.String())
+	}
+}
+
+// IndexedIPLD is an indexed IPLD, as selected by the CIDsPgStr queries
+type IndexedIPLD struct {
+	BlockNumber uint64 `db:"block_number"`
+	CID         string `db:"cid"`
+	MhKey       string `db:"mh_key"`
+}
+
+// CIDsPgStr returns the query for the block numbers, cids and mhkeys of the indexed IPLDs of the type of data at block
+// heights between $1 and $2 for the provided chain type
+func CIDsPgStr(chain shared.ChainType, t shared.DataType) (string, error) {
+	switch chain {
+	case shared.Ethereum:
+		return eth.CIDsPgStr(t)
+	case shared.Bitcoin:
+		return btc.CIDsPgStr(t)
+	default:
+		return "", fmt.Errorf("invalid chain %s for cids query", chain.String())
+	}
+}
+
+// WalkUnindexedBlocks walks the blocks that hang off of the header of the provided chain type and are published but not
+// indexed: the nodes of the transaction and receipt tries for Ethereum, and for Bitcoin the merkle nodes along with the
+// transactions at their leaves, and the witness commitment and witness merkle tree
+// get returns the data of the block with the cid; a block it returns no data for is skipped, along with the blocks below it
+func WalkUnindexedBlocks(chain shared.ChainType, headerData []byte, get func(t shared.DataType, c cid.Cid) ([]byte, error), visit func(t shared.DataType, c cid.Cid, data []byte) error) error {
+	switch chain {
+	case shared.Ethereum:
+		return eth.WalkHeaderTries(headerData, get, visit)
+	case shared.Bitcoin:
+		return btc.WalkHeaderMerkleTree(headerData, get, visit)
+	default:
+		return fmt.Errorf("invalid chain %s for walking unindexed blocks", chain.String())
+	}
+}
@@ -0,0 +1,223 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package car
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+)
+
+// Version is the version of the CAR format that is written and read
+const Version = 1
+
+// maxSectionSize bounds the size of the header and sections that are read, so that a corrupt length can't exhaust memory
+const maxSectionSize = 32 << 20
+
+// Header is the header of a CARv1 file
+type Header struct {
+	Roots   []cid.Cid `refmt:"roots"`
+	Version uint64    `refmt:"version"`
+}
+
+func init() {
+	cbor.RegisterCborType(Header{})
+}
+
+// Writer writes the blocks of a CARv1 file
+// See https://ipld.io/specs/transport/car/carv1/
+type Writer struct {
+	w *bufio.Writer
+}
+
+// NewWriter writes the header of a CARv1 file with the roots to w and returns a Writer for its blocks
+func NewWriter(w io.Writer, roots []cid.Cid) (*Writer, error) {
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("a car file needs at least one root")
+	}
+	header, err := cbor.DumpObject(&Header{
+		Roots:   roots,
+		Version: Version,
+	})
+	if err != nil {
+		return nil, err
+	}
+	cw := &Writer{
+		w: bufio.NewWriter(w),
+	}
+	return cw, cw.writeSection(header)
+}
+
+func (cw *Writer) writeSection(data ...[]byte) error {
+	var size int
+	for _, d := range data {
+		size += len(d)
+	}
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, uint64(size))
+	if _, err := cw.w.Write(buf[:n]); err != nil {
+		return err
+	}
+	for _, d := range data {
+		if _, err := cw.w.Write(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Put writes the block with the cid
+func (cw *Writer) Put(c cid.Cid, data []byte) error {
+	return cw.writeSection(c.Bytes(), data)
+}
+
+// Flush writes any buffered blocks to the underlying writer
+func (cw *Writer) Flush() error {
+	return cw.w.Flush()
+}
+
+// Reader reads the blocks of a CARv1 file
+type Reader struct {
+	r      *countingReader
+	header Header
+}
+
+// countingReader counts the bytes read, so that the offsets of the blocks in the file are known
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+func (cr *countingReader) ReadByte() (byte, error) {
+	b, err := cr.r.ReadByte()
+	if err == nil {
+		cr.n++
+	}
+	return b, err
+}
+
+// NewReader reads the header of a CARv1 file from r and returns a Reader for its blocks
+func NewReader(r io.Reader) (*Reader, error) {
+	cr := &Reader{
+		r: &countingReader{r: bufio.NewReader(r)},
+	}
+	data, err := cr.readSection()
+	if err == io.EOF {
+		return nil, fmt.Errorf("car file has no header")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := cbor.DecodeInto(data, &cr.header); err != nil {
+		return nil, fmt.Errorf("invalid car header: %v", err)
+	}
+	if cr.header.Version != Version {
+		return nil, fmt.Errorf("unsupported car version %d", cr.header.Version)
+	}
+	return cr, nil
+}
+
+// Header returns the header of the CAR file
+func (cr *Reader) Header() Header {
+	return cr.header
+}
+
+// readSection reads the next length-prefixed section, returning io.EOF if there are none left
+func (cr *Reader) readSection() ([]byte, error) {
+	size, err := binary.ReadUvarint(cr.r)
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("invalid car section length: %v", err)
+	}
+	if size > maxSectionSize {
+		return nil, fmt.Errorf("car section of %d bytes exceeds the maximum of %d bytes", size, maxSectionSize)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(cr.r, data); err != nil {
+		return nil, fmt.Errorf("truncated car section: %v", err)
+	}
+	return data, nil
+}
+
+// Next returns the cid and data of the next block, or io.EOF once all of the blocks have been read
+// The block is checked against its cid
+func (cr *Reader) Next() (cid.Cid, []byte, error) {
+	section, err := cr.readSection()
+	if err != nil {
+		return cid.Cid{}, nil, err
+	}
+	n, c, err := cid.CidFromBytes(section)
+	if err != nil {
+		return cid.Cid{}, nil, fmt.Errorf("invalid car block cid: %v", err)
+	}
+	data := section[n:]
+	if err := verify(c, data); err != nil {
+		return cid.Cid{}, nil, err
+	}
+	return c, data, nil
+}
+
+// Location is where the data of a block is in a CAR file
+type Location struct {
+	Offset int64
+	Size   int
+}
+
+// NextLocation is Next, but returns where the data of the block is in the file rather than the data, so that the
+// blocks of a file too large to hold in memory can be indexed and read with ReadBlock as they are needed
+func (cr *Reader) NextLocation() (cid.Cid, Location, error) {
+	c, data, err := cr.Next()
+	if err != nil {
+		return cid.Cid{}, Location{}, err
+	}
+	return c, Location{Offset: cr.r.n - int64(len(data)), Size: len(data)}, nil
+}
+
+// ReadBlock reads the data of the block with the cid at the location in the CAR file, and checks it against the cid
+func ReadBlock(r io.ReaderAt, c cid.Cid, loc Location) ([]byte, error) {
+	data := make([]byte, loc.Size)
+	if _, err := r.ReadAt(data, loc.Offset); err != nil {
+		return nil, fmt.Errorf("car block %s: %v", c.String(), err)
+	}
+	if err := verify(c, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func verify(c cid.Cid, data []byte) error {
+	sum, err := c.Prefix().Sum(data)
+	if err != nil {
+		return err
+	}
+	if !sum.Equals(c) {
+		return fmt.Errorf("car block does not match its cid %s", c.String())
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package car_test
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/car"
+)
+
+var (
+	data1 = []byte("block one")
+	data2 = []byte("block two")
+)
+
+func rawCID(data []byte) cid.Cid {
+	c, err := cid.V1Builder{Codec: cid.Raw, MhType: multihash.SHA2_256}.Sum(data)
+	Expect(err).ToNot(HaveOccurred())
+	return c
+}
+
+var _ = Describe("CAR", func() {
+	It("Reads back the roots and blocks that are written", func() {
+		buf := new(bytes.Buffer)
+		w, err := car.NewWriter(buf, []cid.Cid{rawCID(data1)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w.Put(rawCID(data1), data1)).To(Succeed())
+		Expect(w.Put(rawCID(data2), data2)).To(Succeed())
+		Expect(w.Flush()).To(Succeed())
+
+		r, err := car.NewReader(buf)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(r.Header().Version).To(Equal(uint64(car.Version)))
+		Expect(r.Header().Roots).To(Equal([]cid.Cid{rawCID(data1)}))
+		for _, data := range [][]byte{data1, data2} {
+			c, block, err := r.Next()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(c).To(Equal(rawCID(data)))
+			Expect(block).To(Equal(data))
+		}
+		_, _, err = r.Next()
+		Expect(err).To(Equal(io.EOF))
+	})
+
+	It("Requires a root", func() {
+		_, err := car.NewWriter(new(bytes.Buffer), nil)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Rejects a block that does not match its cid", func() {
+		buf := new(bytes.Buffer)
+		w, err := car.NewWriter(buf, []cid.Cid{rawCID(data1)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w.Put(rawCID(data1), data2)).To(Succeed())
+		Expect(w.Flush()).To(Succeed())
+
+		r, err := car.NewReader(buf)
+		Expect(err).ToNot(HaveOccurred())
+		_, _, err = r.Next()
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Rejects a truncated file", func() {
+		buf := new(bytes.Buffer)
+		w, err := car.NewWriter(buf, []cid.Cid{rawCID(data1)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w.Put(rawCID(data1), data1)).To(Succeed())
+		Expect(w.Flush()).To(Succeed())
+
+		r, err := car.NewReader(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+		Expect(err).ToNot(HaveOccurred())
+		_, _, err = r.Next()
+		Expect(err).To(HaveOccurred())
+		Expect(err).ToNot(Equal(io.EOF))
+	})
+	It("Reads blocks back from their locations in the file", func() {
+		buf := new(bytes.Buffer)
+		w, err := car.NewWriter(buf, []cid.Cid{rawCID(data1)})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w.Put(rawCID(data1), data1)).To(Succeed())
+		Expect(w.Put(rawCID(data2), data2)).To(Succeed())
+		Expect(w.Flush()).To(Succeed())
+
+		file := bytes.NewReader(buf.Bytes())
+		r, err := car.NewReader(bytes.NewReader(buf.Bytes()))
+		Expect(err).ToNot(HaveOccurred())
+		locations := make(map[cid.Cid]car.Location)
+		for {
+			c, loc, err := r.NextLocation()
+			if err == io.EOF {
+				break
+			}
+			Expect(err).ToNot(HaveOccurred())
+			locations[c] = loc
+		}
+		Expect(locations).To(HaveLen(2))
+		for _, data := range [][]byte{data2, data1} {
+			block, err := car.ReadBlock(file, rawCID(data), locations[rawCID(data)])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(block).To(Equal(data))
+		}
+		_, err = car.ReadBlock(file, rawCID(data1), locations[rawCID(data2)])
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,179 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package carimport
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/ipfs/go-cid"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// btcBuilder rebuilds btc payloads from the blocks in a CAR file
+type btcBuilder struct {
+	db        *postgres.DB
+	converter *btc.PayloadConverter
+	// heights of the blocks imported, by hash
+	heights map[chainhash.Hash]int64
+	// mhkeys of the witness versions of the transactions in the CAR file being imported, by txid
+	witnesses map[chainhash.Hash]string
+}
+
+func newBtcBuilder(db *postgres.DB, networkID string) (*btcBuilder, error) {
+	params, err := btc.ParamsFromNetworkID(networkID)
+	if err != nil {
+		return nil, err
+	}
+	return &btcBuilder{
+		db:        db,
+		converter: btc.NewPayloadConverter(params, db),
+		heights:   make(map[chainhash.Hash]int64),
+	}, nil
+}
+
+func (bb *btcBuilder) start(cb *carBlocks) error {
+	bb.witnesses = make(map[chainhash.Hash]string)
+	for mhKey, block := range cb.blocks {
+		// merkle nodes share the codec of the transactions
+		if block.cid.Type() != ipld.MBitcoinTx || block.location.Size == 2*chainhash.HashSize {
+			continue
+		}
+		data, err := cb.read(block)
+		if err != nil {
+			return err
+		}
+		var tx wire.MsgTx
+		if err := tx.Deserialize(bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("transaction %s: %v", block.cid.String(), err)
+		}
+		if tx.HasWitness() {
+			bb.witnesses[tx.TxHash()] = mhKey
+		}
+	}
+	return nil
+}
+
+func (bb *btcBuilder) build(cb *carBlocks, root cid.Cid) (shared.ConvertedData, error) {
+	headerData, err := cb.take(shared.MultihashKeyFromCID(root))
+	if err != nil {
+		return nil, err
+	}
+	if headerData == nil {
+		return nil, fmt.Errorf("header is not in the CAR file")
+	}
+	header := new(wire.BlockHeader)
+	if err := header.Deserialize(bytes.NewReader(headerData)); err != nil {
+		return nil, err
+	}
+	var txs []*btcutil.Tx
+	var coinbase []byte
+	err = btc.WalkMerkleTree(header.MerkleRoot, func(hash chainhash.Hash) ([]byte, error) {
+		mhKey, err := shared.MultihashKeyFromDoubleSha256(hash[:])
+		if err != nil {
+			return nil, err
+		}
+		data, err := cb.take(mhKey)
+		if err != nil || data != nil {
+			return data, err
+		}
+		return nil, fmt.Errorf("merkle node or transaction %s is not in the CAR file", hash.String())
+	}, func(hash chainhash.Hash, data []byte, isNode bool) error {
+		if isNode {
+			return nil
+		}
+		// the witness version of the transaction carries all of its data
+		if mhKey, ok := bb.witnesses[hash]; ok {
+			var err error
+			if data, err = cb.take(mhKey); err != nil {
+				return err
+			}
+		}
+		msgTx := new(wire.MsgTx)
+		if err := msgTx.Deserialize(bytes.NewReader(data)); err != nil {
+			return fmt.Errorf("transaction %s: %v", hash.String(), err)
+		}
+		if coinbase == nil {
+			coinbase = data
+		}
+		tx := btcutil.NewTx(msgTx)
+		tx.SetIndex(len(txs))
+		txs = append(txs, tx)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the witness commitment and witness merkle tree are published again from the transactions
+	if coinbase != nil {
+		err = btc.WalkWitnessTree(coinbase, func(t shared.DataType, c cid.Cid) ([]byte, error) {
+			return cb.take(shared.MultihashKeyFromCID(c))
+		}, func(t shared.DataType, c cid.Cid, data []byte) error {
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	height, err := bb.height(header, txs)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := bb.converter.Convert(btc.BlockPayload{
+		BlockHeight: height,
+		Header:      header,
+		Txs:         txs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	bb.heights[header.BlockHash()] = height
+	return payload, nil
+}
+
+// height returns the height of the block; CAR files don't carry it, so it is counted on from the parent of the block
+// if that has been imported or indexed, or else read from the coinbase of blocks that carry it there (BIP34)
+func (bb *btcBuilder) height(header *wire.BlockHeader, txs []*btcutil.Tx) (int64, error) {
+	if header.PrevBlock == (chainhash.Hash{}) {
+		return 0, nil
+	}
+	if height, ok := bb.heights[header.PrevBlock]; ok {
+		return height + 1, nil
+	}
+	pgStr := `SELECT block_number FROM btc.header_cids
+			WHERE block_hash = $1
+			LIMIT 1`
+	var height int64
+	if err := bb.db.Get(&height, pgStr, header.PrevBlock.String()); err == nil {
+		return height + 1, nil
+	}
+	if header.Version >= 2 && len(txs) > 0 {
+		coinbaseHeight, err := blockchain.ExtractCoinbaseHeight(txs[0])
+		if err == nil {
+			return int64(coinbaseHeight), nil
+		}
+	}
+	return 0, fmt.Errorf("height of block %s is unknown, import or sync its parent first", header.BlockHash().String())
+}
@@ -0,0 +1,157 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package carimport
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/blockstore"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/car"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/config"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/node"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+	"github.com/vulcanize/ipfs-blockchain-watcher/utils"
+)
+
+// Env variables
+const (
+	CAR_IMPORT_PARENT_TD = "CAR_IMPORT_PARENT_TD"
+)
+
+// Config holds the parameters needed to import CAR files
+type Config struct {
+	Chain shared.ChainType // The chain of the data in the CAR files, told by the codec of their roots
+	Files []string         // The CAR files to import, in order
+	// Total difficulty of the parent of the first eth block imported, needed if the parent is not indexed
+	ParentTD *big.Int
+
+	// DB info
+	DB          *postgres.DB
+	DBConfig    config.Database
+	IPFSPath    string
+	IPFSMode    shared.IPFSMode
+	Blockstores *shared.Blockstores // The blockstores used in DirectPostgres mode
+
+	NodeInfo node.Node // Info for the node the data came from
+}
+
+// NewConfig fills and returns a CAR import config from toml parameters, for the given CAR files
+func NewConfig(files []string) (*Config, error) {
+	c := new(Config)
+	var err error
+
+	viper.BindEnv("carImport.parentTD", CAR_IMPORT_PARENT_TD)
+	viper.BindEnv("ethereum.nodeID", shared.ETH_NODE_ID)
+	viper.BindEnv("ethereum.clientName", shared.ETH_CLIENT_NAME)
+	viper.BindEnv("ethereum.genesisBlock", shared.ETH_GENESIS_BLOCK)
+	viper.BindEnv("ethereum.networkID", shared.ETH_NETWORK_ID)
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no CAR files to import")
+	}
+	c.Files = files
+	for i, file := range files {
+		chain, err := FileChain(file)
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 && chain != c.Chain {
+			return nil, fmt.Errorf("CAR file %s holds %s data, %s holds %s data", file, chain.String(), files[0], c.Chain.String())
+		}
+		c.Chain = chain
+	}
+	if td := viper.GetString("carImport.parentTD"); td != "" {
+		var ok bool
+		if c.ParentTD, ok = new(big.Int).SetString(td, 10); !ok {
+			return nil, fmt.Errorf("invalid parent total difficulty %s", td)
+		}
+	}
+
+	c.IPFSMode, err = shared.GetIPFSMode()
+	if err != nil {
+		return nil, err
+	}
+	if c.IPFSMode == shared.LocalInterface {
+		c.IPFSPath, err = shared.GetIPFSPath()
+		if err != nil {
+			return nil, err
+		}
+	}
+	// in remote mode the ipfs path is the address of the daemon's HTTP API
+	if c.IPFSMode == shared.RemoteClient {
+		c.IPFSPath = shared.GetIPFSAPI()
+	}
+	if c.IPFSMode == shared.DirectPostgres {
+		c.Blockstores, err = blockstore.NewBlockstores()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// there is no node to ask, so the node info comes from the config
+	switch c.Chain {
+	case shared.Ethereum:
+		c.NodeInfo = node.Node{
+			ID:           viper.GetString("ethereum.nodeID"),
+			ClientName:   viper.GetString("ethereum.clientName"),
+			GenesisBlock: viper.GetString("ethereum.genesisBlock"),
+			NetworkID:    viper.GetString("ethereum.networkID"),
+		}
+	case shared.Bitcoin:
+		c.NodeInfo, _ = shared.GetBtcNodeAndClient("")
+	}
+	c.DBConfig.Init()
+	db := utils.LoadPostgres(c.DBConfig, c.NodeInfo)
+	c.DB = &db
+	return c, nil
+}
+
+// FileChain returns the chain of the data in the CAR file, told by the codec of its roots
+func FileChain(path string) (shared.ChainType, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return shared.UnknownChain, err
+	}
+	defer file.Close()
+	r, err := car.NewReader(file)
+	if err != nil {
+		return shared.UnknownChain, fmt.Errorf("%s: %v", path, err)
+	}
+	var chain shared.ChainType
+	for _, root := range r.Header().Roots {
+		var rootChain shared.ChainType
+		switch root.Type() {
+		case ipld.MEthHeader:
+			rootChain = shared.Ethereum
+		case ipld.MBitcoinHeader:
+			rootChain = shared.Bitcoin
+		default:
+			return shared.UnknownChain, fmt.Errorf("%s: root %s is not an eth or btc header", path, root.String())
+		}
+		if chain != shared.UnknownChain && rootChain != chain {
+			return shared.UnknownChain, fmt.Errorf("%s: roots of more than one chain", path)
+		}
+		chain = rootChain
+	}
+	return chain, nil
+}
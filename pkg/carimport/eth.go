@@ -0,0 +1,379 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package carimport
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/statediff"
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// maxUncleDepth is how many blocks below a block its uncles can be
+const maxUncleDepth = 7
+
+// ethBuilder rebuilds eth payloads from the blocks in a CAR file
+type ethBuilder struct {
+	db        *postgres.DB
+	converter *eth.PayloadConverter
+	// total difficulty of the parent of the first block imported, used if the parent is not indexed
+	parentTD *big.Int
+	// height of the first block imported; zero until the first CAR file is started
+	firstNumber *big.Int
+	// headers of the CAR file being imported, by mhkey; any of them can be the uncle of another
+	headers map[string]*types.Header
+	// total difficulty and state root of the blocks imported, by hash
+	tds        map[common.Hash]*big.Int
+	stateRoots map[common.Hash]common.Hash
+}
+
+func newEthBuilder(db *postgres.DB, parentTD *big.Int, networkID string) (*ethBuilder, error) {
+	chainConfig, err := eth.ChainConfigFromNetworkID(networkID)
+	if err != nil {
+		return nil, err
+	}
+	return &ethBuilder{
+		db:         db,
+		converter:  eth.NewPayloadConverter(chainConfig),
+		parentTD:   parentTD,
+		tds:        make(map[common.Hash]*big.Int),
+		stateRoots: make(map[common.Hash]common.Hash),
+	}, nil
+}
+
+func (eb *ethBuilder) start(cb *carBlocks) error {
+	eb.headers = make(map[string]*types.Header)
+	for mhKey, block := range cb.blocks {
+		if block.cid.Type() != ipld.MEthHeader {
+			continue
+		}
+		data, err := cb.read(block)
+		if err != nil {
+			return err
+		}
+		header := new(types.Header)
+		if err := rlp.DecodeBytes(data, header); err != nil {
+			return fmt.Errorf("header %s: %v", block.cid.String(), err)
+		}
+		eb.headers[mhKey] = header
+	}
+	if eb.firstNumber != nil {
+		return nil
+	}
+	for _, root := range cb.roots {
+		header, ok := eb.headers[shared.MultihashKeyFromCID(root)]
+		if !ok {
+			return fmt.Errorf("header %s at the root of the CAR file is not in it", root.String())
+		}
+		if eb.firstNumber == nil || header.Number.Cmp(eb.firstNumber) < 0 {
+			eb.firstNumber = header.Number
+		}
+	}
+	return nil
+}
+
+func (eb *ethBuilder) build(cb *carBlocks, root cid.Cid) (shared.ConvertedData, error) {
+	rootKey := shared.MultihashKeyFromCID(root)
+	header, ok := eb.headers[rootKey]
+	if !ok {
+		return nil, fmt.Errorf("header is not in the CAR file")
+	}
+	cb.use(rootKey)
+	txs, err := eb.transactions(cb, header)
+	if err != nil {
+		return nil, err
+	}
+	receipts, err := eb.receipts(cb, header)
+	if err != nil {
+		return nil, err
+	}
+	if len(receipts) != len(txs) {
+		return nil, fmt.Errorf("%d transactions have %d receipts", len(txs), len(receipts))
+	}
+	uncles, err := eb.uncles(cb, header)
+	if err != nil {
+		return nil, err
+	}
+	td, err := eb.totalDifficulty(header)
+	if err != nil {
+		return nil, err
+	}
+	block := types.NewBlockWithHeader(header).WithBody(txs, uncles)
+	payload, err := eb.converter.ConvertBlock(block, td, receipts)
+	if err != nil {
+		return nil, err
+	}
+	if err := eb.stateDiff(cb, header, &payload); err != nil {
+		return nil, err
+	}
+	eb.tds[header.Hash()] = td
+	eb.stateRoots[header.Hash()] = header.Root
+	return payload, nil
+}
+
+// trieLeaves walks the transaction or receipt trie with the root and returns the values at its leaves, in the order of
+// the indexes they are keyed by
+func trieLeaves(cb *carBlocks, root common.Hash, name string) ([][]byte, error) {
+	leaves := make(map[uint64][]byte)
+	err := eth.WalkTrie(root, func(hash common.Hash) ([]byte, error) {
+		mhKey, err := shared.MultihashKeyFromKeccak256(hash)
+		if err != nil {
+			return nil, err
+		}
+		data, err := cb.take(mhKey)
+		if err != nil || data != nil {
+			return data, err
+		}
+		return nil, fmt.Errorf("%s trie node %s is not in the CAR file", name, hash.Hex())
+	}, func(node eth.WalkedTrieNode) (bool, error) {
+		if node.Type != statediff.Leaf {
+			return true, nil
+		}
+		var index uint64
+		if err := rlp.DecodeBytes(node.LeafKey, &index); err != nil {
+			return false, fmt.Errorf("%s trie leaf %s has an invalid key: %v", name, node.Hash.Hex(), err)
+		}
+		leaves[index] = node.LeafValue
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	values := make([][]byte, len(leaves))
+	for i := range values {
+		value, ok := leaves[uint64(i)]
+		if !ok {
+			return nil, fmt.Errorf("%s trie has no leaf at index %d of %d", name, i, len(leaves))
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// transactions returns the transactions of the block, from its transaction trie
+func (eb *ethBuilder) transactions(cb *carBlocks, header *types.Header) (types.Transactions, error) {
+	values, err := trieLeaves(cb, header.TxHash, "transaction")
+	if err != nil {
+		return nil, err
+	}
+	txs := make(types.Transactions, len(values))
+	for i, value := range values {
+		txs[i] = new(types.Transaction)
+		if err := rlp.DecodeBytes(value, txs[i]); err != nil {
+			return nil, fmt.Errorf("transaction %d: %v", i, err)
+		}
+		mhKey, err := shared.MultihashKeyFromKeccak256(txs[i].Hash())
+		if err != nil {
+			return nil, err
+		}
+		cb.use(mhKey)
+	}
+	return txs, nil
+}
+
+// receipts returns the receipts of the block, from its receipt trie
+func (eb *ethBuilder) receipts(cb *carBlocks, header *types.Header) (types.Receipts, error) {
+	values, err := trieLeaves(cb, header.ReceiptHash, "receipt")
+	if err != nil {
+		return nil, err
+	}
+	receipts := make(types.Receipts, len(values))
+	for i, value := range values {
+		receipts[i] = new(types.Receipt)
+		if err := rlp.DecodeBytes(value, receipts[i]); err != nil {
+			return nil, fmt.Errorf("receipt %d: %v", i, err)
+		}
+		c, err := ipld.RawdataToCid(ipld.MEthTxReceipt, value, multihash.KECCAK_256)
+		if err != nil {
+			return nil, err
+		}
+		cb.use(shared.MultihashKeyFromCID(c))
+		for _, l := range receipts[i].Logs {
+			logNode, err := ipld.NewLog(l)
+			if err != nil {
+				return nil, err
+			}
+			cb.use(shared.MultihashKeyFromCID(logNode.Cid()))
+		}
+	}
+	return receipts, nil
+}
+
+// uncles returns the uncles of the block, found among the headers in the CAR file by the hash of the uncle list
+func (eb *ethBuilder) uncles(cb *carBlocks, header *types.Header) ([]*types.Header, error) {
+	if header.UncleHash == types.EmptyUncleHash {
+		return nil, nil
+	}
+	var candidates []string
+	for mhKey, candidate := range eb.headers {
+		depth := new(big.Int).Sub(header.Number, candidate.Number)
+		if depth.Sign() > 0 && depth.Cmp(big.NewInt(maxUncleDepth)) <= 0 {
+			candidates = append(candidates, mhKey)
+		}
+	}
+	// a block has at most two uncles
+	for _, first := range candidates {
+		uncles := []*types.Header{eb.headers[first]}
+		if types.CalcUncleHash(uncles) == header.UncleHash {
+			cb.use(first)
+			return uncles, nil
+		}
+		for _, second := range candidates {
+			if second == first {
+				continue
+			}
+			uncles := []*types.Header{eb.headers[first], eb.headers[second]}
+			if types.CalcUncleHash(uncles) == header.UncleHash {
+				cb.use(first)
+				cb.use(second)
+				return uncles, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("uncles %s are not in the CAR file", header.UncleHash.Hex())
+}
+
+// totalDifficulty returns the total difficulty of the block; CAR files don't carry it, so it is summed up from the
+// parent of the first block imported, which has to be indexed already or have its total difficulty configured
+func (eb *ethBuilder) totalDifficulty(header *types.Header) (*big.Int, error) {
+	td := new(big.Int).Set(header.Difficulty)
+	if header.Number.Sign() == 0 {
+		return td, nil
+	}
+	if parentTD, ok := eb.tds[header.ParentHash]; ok {
+		return td.Add(td, parentTD), nil
+	}
+	pgStr := `SELECT td FROM eth.header_cids
+			WHERE block_hash = $1
+			LIMIT 1`
+	var tdStr string
+	if err := eb.db.Get(&tdStr, pgStr, header.ParentHash.Hex()); err == nil {
+		parentTD, ok := new(big.Int).SetString(tdStr, 10)
+		if !ok {
+			return nil, fmt.Errorf("total difficulty retrieved from Postgres cannot be converted to an integer")
+		}
+		return td.Add(td, parentTD), nil
+	}
+	if eb.parentTD != nil && header.Number.Cmp(eb.firstNumber) == 0 {
+		return td.Add(td, eb.parentTD), nil
+	}
+	return nil, fmt.Errorf("total difficulty of the parent of block %d is unknown, import or sync its parent first or configure its total difficulty", header.Number.Uint64())
+}
+
+// parentStateRoot returns the state root of the parent of the block, or the zero hash if it is unknown
+func (eb *ethBuilder) parentStateRoot(header *types.Header) common.Hash {
+	if root, ok := eb.stateRoots[header.ParentHash]; ok {
+		return root
+	}
+	pgStr := `SELECT state_root FROM eth.header_cids
+			WHERE block_hash = $1
+			LIMIT 1`
+	var root string
+	if err := eb.db.Get(&root, pgStr, header.ParentHash.Hex()); err != nil {
+		return common.Hash{}
+	}
+	return common.HexToHash(root)
+}
+
+// stateDiff fills in the state and storage nodes of the payload: the nodes in the CAR file that differ from the node at
+// the same path in the parent's trie, which are the nodes the state diff of the block listed
+// The state is absent if the CAR file doesn't have the state root, e.g. if it was exported without state
+func (eb *ethBuilder) stateDiff(cb *carBlocks, header *types.Header, payload *eth.ConvertedPayload) error {
+	rootKey, err := shared.MultihashKeyFromKeccak256(header.Root)
+	if err != nil {
+		return err
+	}
+	if !cb.has(rootKey) {
+		return nil
+	}
+	payload.StateAbsent = false
+	parentRoot := eb.parentStateRoot(header)
+	return eth.WalkTrieDiff(header.Root, parentRoot, cb.peekHash, func(node eth.WalkedTrieNode) (bool, error) {
+		trieNode, ok, err := cb.useTrieNode(node)
+		if err != nil || !ok {
+			return true, err
+		}
+		payload.StateNodes = append(payload.StateNodes, trieNode)
+		if node.Type != statediff.Leaf {
+			return true, nil
+		}
+		var account state.Account
+		if err := rlp.DecodeBytes(node.LeafValue, &account); err != nil {
+			return false, fmt.Errorf("state leaf %s: %v", node.Hash.Hex(), err)
+		}
+		var parentStorageRoot common.Hash
+		if parentValue, ok, err := eth.TrieLeafValue(parentRoot, node.LeafKey, cb.peekHash); err != nil {
+			return false, err
+		} else if ok {
+			var parentAccount state.Account
+			if err := rlp.DecodeBytes(parentValue, &parentAccount); err != nil {
+				return false, fmt.Errorf("parent state leaf of %s: %v", node.Hash.Hex(), err)
+			}
+			parentStorageRoot = parentAccount.Root
+		}
+		statePath := common.Bytes2Hex(node.Path)
+		return false, eth.WalkTrieDiff(account.Root, parentStorageRoot, cb.peekHash, func(node eth.WalkedTrieNode) (bool, error) {
+			trieNode, ok, err := cb.useTrieNode(node)
+			if err != nil || !ok {
+				return true, err
+			}
+			payload.StorageNodes[statePath] = append(payload.StorageNodes[statePath], trieNode)
+			return true, nil
+		})
+	})
+}
+
+// peekHash returns the data of the block with the keccak256 hash, or nil if it is not in the CAR file
+func (cb *carBlocks) peekHash(hash common.Hash) ([]byte, error) {
+	mhKey, err := shared.MultihashKeyFromKeccak256(hash)
+	if err != nil {
+		return nil, err
+	}
+	return cb.peek(mhKey)
+}
+
+// useTrieNode returns the state diff node for the trie node, and marks its block as used
+// ok is false if the block of the node is not in the CAR file, e.g. for a node embedded in its parent
+func (cb *carBlocks) useTrieNode(node eth.WalkedTrieNode) (eth.TrieNode, bool, error) {
+	mhKey, err := shared.MultihashKeyFromKeccak256(node.Hash)
+	if err != nil {
+		return eth.TrieNode{}, false, err
+	}
+	if !cb.use(mhKey) {
+		return eth.TrieNode{}, false, nil
+	}
+	trieNode := eth.TrieNode{
+		Path:  node.Path,
+		Value: node.Value,
+		Type:  node.Type,
+	}
+	if node.Type == statediff.Leaf {
+		trieNode.LeafKey = common.BytesToHash(node.LeafKey)
+	}
+	return trieNode, true, nil
+}
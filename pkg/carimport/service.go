@@ -0,0 +1,365 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package carimport
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/builders"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/car"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// logInterval is how often, in headers, the progress of an import is logged
+const logInterval = 1000
+
+// putBatchSize is how many of the blocks left over in a CAR file are read and written at a time
+const putBatchSize = 1000
+
+// carBlock is a block of a CAR file, whose data is read from the file when it is needed
+type carBlock struct {
+	cid      cid.Cid
+	location car.Location
+	// whether the block is regenerated from the payload of one of the headers, and published with it
+	used bool
+}
+
+// carBlocks indexes the blocks of a CAR file by mhkey, so that files too large to hold in memory can be imported
+type carBlocks struct {
+	file   *os.File
+	roots  []cid.Cid
+	blocks map[string]*carBlock
+	// mhkeys in the order the blocks were read, so that the blocks left over are written in a stable order
+	order []string
+}
+
+// openCAR indexes the blocks of the CAR file, which is kept open to read them from until it is closed
+func openCAR(path string) (*carBlocks, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := car.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	cb := &carBlocks{
+		file:   file,
+		roots:  r.Header().Roots,
+		blocks: make(map[string]*carBlock),
+	}
+	for {
+		c, location, err := r.NextLocation()
+		if err == io.EOF {
+			return cb, nil
+		}
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		mhKey := shared.MultihashKeyFromCID(c)
+		if _, ok := cb.blocks[mhKey]; ok {
+			continue
+		}
+		cb.blocks[mhKey] = &carBlock{cid: c, location: location}
+		cb.order = append(cb.order, mhKey)
+	}
+}
+
+func (cb *carBlocks) close() error {
+	return cb.file.Close()
+}
+
+// read reads the data of the block from the file
+func (cb *carBlocks) read(block *carBlock) ([]byte, error) {
+	return car.ReadBlock(cb.file, block.cid, block.location)
+}
+
+// has returns whether the block with the mhkey is in the CAR file
+func (cb *carBlocks) has(mhKey string) bool {
+	_, ok := cb.blocks[mhKey]
+	return ok
+}
+
+// peek returns the data of the block with the mhkey, or nil if it is not in the CAR file
+func (cb *carBlocks) peek(mhKey string) ([]byte, error) {
+	block, ok := cb.blocks[mhKey]
+	if !ok {
+		return nil, nil
+	}
+	return cb.read(block)
+}
+
+// use marks the block with the mhkey as used, and returns whether it is in the CAR file
+func (cb *carBlocks) use(mhKey string) bool {
+	block, ok := cb.blocks[mhKey]
+	if ok {
+		block.used = true
+	}
+	return ok
+}
+
+// take returns the data of the block with the mhkey, or nil if it is not in the CAR file, and marks it as used
+func (cb *carBlocks) take(mhKey string) ([]byte, error) {
+	if !cb.use(mhKey) {
+		return nil, nil
+	}
+	return cb.read(cb.blocks[mhKey])
+}
+
+// unused returns the blocks that have not been used
+func (cb *carBlocks) unused() []*carBlock {
+	var left []*carBlock
+	for _, mhKey := range cb.order {
+		if block := cb.blocks[mhKey]; !block.used {
+			left = append(left, block)
+		}
+	}
+	return left
+}
+
+// putUnused reads the blocks that have not been used from the file and writes them to the sink, a batch at a time
+func (cb *carBlocks) putUnused(sink blockSink, left []*carBlock) error {
+	for len(left) > 0 {
+		batch := left
+		if len(batch) > putBatchSize {
+			batch = batch[:putBatchSize]
+		}
+		left = left[len(batch):]
+		ipfsBlocks := make([]blocks.Block, len(batch))
+		for i, block := range batch {
+			data, err := cb.read(block)
+			if err != nil {
+				return err
+			}
+			if ipfsBlocks[i], err = blocks.NewBlockWithCid(data, block.cid); err != nil {
+				return err
+			}
+		}
+		if err := sink.put(ipfsBlocks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// payloadBuilder rebuilds the payloads of the headers in a CAR file from its blocks
+type payloadBuilder interface {
+	// start is called with the blocks of each CAR file before any of its payloads are built
+	start(blocks *carBlocks) error
+	// build returns the payload of the header at the root, marking the blocks it is built from as used
+	build(blocks *carBlocks, root cid.Cid) (shared.ConvertedData, error)
+}
+
+// blockSink is where the blocks of a CAR file that are not regenerated from a payload are written to
+type blockSink interface {
+	put(blocks []blocks.Block) error
+}
+
+// blockstoresSink writes the blocks to the blockstores, in DirectPostgres mode
+type blockstoresSink struct {
+	db          *postgres.DB
+	blockstores *shared.Blockstores
+}
+
+func (s blockstoresSink) put(ipfsBlocks []blocks.Block) (err error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	for _, block := range ipfsBlocks {
+		if err = s.blockstores.For(shared.CodecDataType(block.Cid().Type())).Put(tx, shared.MultihashKeyFromCID(block.Cid()), block.RawData()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// blockServiceSink adds the blocks to IPFS, in the LocalInterface and RemoteClient modes
+type blockServiceSink struct {
+	blockService blockservice.BlockService
+}
+
+func (s blockServiceSink) put(ipfsBlocks []blocks.Block) error {
+	return s.blockService.AddBlocks(ipfsBlocks)
+}
+
+type Importer interface {
+	Import() error
+	Stop() error
+}
+
+// Service imports CAR files written by the export command: it rebuilds the payload of every header at the root of a
+// CAR file from the blocks in the file, then publishes and indexes it as if it had been synced from a node
+type Service struct {
+	// Interface for publishing the IPLD payloads to IPFS
+	Publisher shared.IPLDPublisher
+	// Interface for indexing the CIDs of the published IPLDs in Postgres
+	Indexer shared.CIDIndexer
+	// CAR files, in order
+	Files []string
+	// Channel for receiving quit signal
+	QuitChan chan bool
+	chain    shared.ChainType
+	builder  payloadBuilder
+	sink     blockSink
+}
+
+// NewImportService creates and returns a CAR import service from the provided settings
+func NewImportService(settings *Config) (Importer, error) {
+	publisher, err := builders.NewIPLDPublisher(settings.Chain, settings.IPFSPath, settings.DB, settings.IPFSMode, settings.Blockstores)
+	if err != nil {
+		return nil, err
+	}
+	indexer, err := builders.NewCIDIndexer(settings.Chain, settings.DB, settings.IPFSMode)
+	if err != nil {
+		return nil, err
+	}
+	var builder payloadBuilder
+	switch settings.Chain {
+	case shared.Ethereum:
+		builder, err = newEthBuilder(settings.DB, settings.ParentTD, settings.NodeInfo.NetworkID)
+		if err != nil {
+			return nil, err
+		}
+	case shared.Bitcoin:
+		builder, err = newBtcBuilder(settings.DB, settings.NodeInfo.NetworkID)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("car import: unsupported chain %s", settings.Chain.String())
+	}
+	var sink blockSink
+	switch settings.IPFSMode {
+	case shared.DirectPostgres:
+		blockstores := settings.Blockstores
+		if blockstores == nil {
+			blockstores = shared.NewPostgresBlockstores()
+		}
+		sink = blockstoresSink{db: settings.DB, blockstores: blockstores}
+	case shared.LocalInterface:
+		blockService, err := ipfs.InitIPFSBlockService(settings.IPFSPath)
+		if err != nil {
+			return nil, err
+		}
+		sink = blockServiceSink{blockService: blockService}
+	case shared.RemoteClient:
+		blockService, err := ipfs.InitRemoteBlockService(settings.IPFSPath)
+		if err != nil {
+			return nil, err
+		}
+		sink = blockServiceSink{blockService: blockService}
+	default:
+		return nil, fmt.Errorf("car import: unrecognized ipfs mode %s", settings.IPFSMode.String())
+	}
+	return &Service{
+		Publisher: publisher,
+		Indexer:   indexer,
+		Files:     settings.Files,
+		QuitChan:  make(chan bool),
+		chain:     settings.Chain,
+		builder:   builder,
+		sink:      sink,
+	}, nil
+}
+
+// Import reads the CAR files, and publishes and indexes the payloads of the headers at their roots in order
+func (is *Service) Import() error {
+	for _, path := range is.Files {
+		log.Infof("importing %s blocks from %s", is.chain.String(), path)
+		stopped, err := is.importFile(path)
+		if err != nil {
+			return fmt.Errorf("%s import from %s failed: %v", is.chain.String(), path, err)
+		}
+		if stopped {
+			log.Infof("%s import stopped", is.chain.String())
+			return nil
+		}
+	}
+	log.Infof("%s import finished", is.chain.String())
+	return nil
+}
+
+func (is *Service) importFile(path string) (bool, error) {
+	cb, err := openCAR(path)
+	if err != nil {
+		return false, err
+	}
+	defer cb.close()
+	if err := is.builder.start(cb); err != nil {
+		return false, err
+	}
+	for i, root := range cb.roots {
+		select {
+		case <-is.QuitChan:
+			return true, nil
+		default:
+		}
+		payload, err := is.builder.build(cb, root)
+		if err != nil {
+			return false, fmt.Errorf("header %s: %v", root.String(), err)
+		}
+		cidPayload, err := is.Publisher.Publish(payload)
+		if err != nil {
+			return false, err
+		}
+		if err := is.Indexer.Index(cidPayload); err != nil {
+			return false, err
+		}
+		if (i+1)%logInterval == 0 {
+			log.Infof("imported %d of the %d %s headers in %s", i+1, len(cb.roots), is.chain.String(), path)
+		}
+	}
+	// blocks that no index row could be rebuilt for, e.g. state nodes that are not on a path that changed, are still
+	// written so that no data in the file is lost; nothing references them though, so gc deletes them
+	if left := cb.unused(); len(left) > 0 {
+		log.Warnf("%d of the blocks in %s are not indexed by any of its headers, writing them without an index; they are deleted by the next gc", len(left), path)
+		if err := cb.putUnused(is.sink, left); err != nil {
+			return false, err
+		}
+	}
+	log.Infof("imported %d %s headers and %d blocks from %s", len(cb.roots), is.chain.String(), len(cb.order), path)
+	return false, nil
+}
+
+// Stop stops the import after the header it is importing
+func (is *Service) Stop() error {
+	log.Infof("stopping %s import", is.chain.String())
+	close(is.QuitChan)
+	return nil
+}
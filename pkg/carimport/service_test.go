@@ -0,0 +1,252 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package carimport_test
+
+import (
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/ipfs/go-cid"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	btcmocks "github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/car"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/carimport"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/export"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// indexRows are the columns of the index rows that an import has to rebuild, leaving out ids
+type indexRows struct {
+	Headers      []eth.HeaderModel
+	Transactions []eth.TxModel
+	Receipts     []eth.ReceiptModel
+	Logs         []eth.LogModel
+}
+
+var _ = Describe("Import", func() {
+	var (
+		db   *postgres.DB
+		dir  string
+		file string
+	)
+	BeforeEach(func() {
+		var err error
+		db = mocks.SetupIndexedDB()
+		dir, err = ioutil.TempDir("", "carimport")
+		Expect(err).ToNot(HaveOccurred())
+		file = filepath.Join(dir, "export.car")
+		types, err := export.ExportDataTypes(shared.Ethereum, nil)
+		Expect(err).ToNot(HaveOccurred())
+		exportService, err := export.NewService(&export.Config{
+			Chain:     shared.Ethereum,
+			DataTypes: types,
+			Start:     mocks.BlockNumber.Uint64(),
+			Stop:      mocks.BlockNumber.Uint64(),
+			Output:    file,
+			DB:        db,
+			IPFSMode:  shared.DirectPostgres,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exportService.Export()).To(Succeed())
+	})
+	AfterEach(func() {
+		eth.TearDownDB(db)
+		os.RemoveAll(dir)
+	})
+
+	parentTD := func() *big.Int {
+		return new(big.Int).Sub(mocks.MockConvertedPayload.TotalDifficulty, mocks.MockBlock.Difficulty())
+	}
+	newService := func(parentTD *big.Int) carimport.Importer {
+		service, err := carimport.NewImportService(&carimport.Config{
+			Chain:    shared.Ethereum,
+			Files:    []string{file},
+			ParentTD: parentTD,
+			DB:       db,
+			IPFSMode: shared.DirectPostgres,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		return service
+	}
+	readRows := func() indexRows {
+		var rows indexRows
+		err := db.Select(&rows.Headers, `SELECT block_number, block_hash, parent_hash, cid, mh_key, td, reward, state_root,
+			uncle_root, tx_root, receipt_root, bloom, timestamp FROM eth.header_cids ORDER BY block_number`)
+		Expect(err).ToNot(HaveOccurred())
+		err = db.Select(&rows.Transactions, `SELECT index, tx_hash, cid, mh_key, dst, src FROM eth.transaction_cids ORDER BY index`)
+		Expect(err).ToNot(HaveOccurred())
+		err = db.Select(&rows.Receipts, `SELECT receipt_cids.cid, receipt_cids.mh_key, contract, contract_hash, log_contracts,
+			topic0s, topic1s, topic2s, topic3s FROM eth.receipt_cids
+			INNER JOIN eth.transaction_cids ON (receipt_cids.tx_id = transaction_cids.id)
+			ORDER BY transaction_cids.index`)
+		Expect(err).ToNot(HaveOccurred())
+		err = db.Select(&rows.Logs, `SELECT log_index, cid, mh_key, address, topic0, topic1, topic2, topic3 FROM eth.log_cids
+			ORDER BY cid, log_index`)
+		Expect(err).ToNot(HaveOccurred())
+		return rows
+	}
+
+	It("Rebuilds the index of the headers in the CAR file and writes all of its blocks", func() {
+		expected := readRows()
+		Expect(expected.Transactions).To(HaveLen(3))
+		eth.TearDownDB(db)
+
+		Expect(newService(parentTD()).Import()).To(Succeed())
+		Expect(readRows()).To(Equal(expected))
+
+		f, err := os.Open(file)
+		Expect(err).ToNot(HaveOccurred())
+		defer f.Close()
+		r, err := car.NewReader(f)
+		Expect(err).ToNot(HaveOccurred())
+		for {
+			c, data, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+			Expect(err).ToNot(HaveOccurred())
+			var stored []byte
+			err = db.Get(&stored, `SELECT data FROM public.blocks WHERE key = $1`, shared.MultihashKeyFromCID(c))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(stored).To(Equal(data))
+		}
+	})
+
+	It("Can be repeated without duplicating the index", func() {
+		expected := readRows()
+		Expect(newService(parentTD()).Import()).To(Succeed())
+		Expect(readRows()).To(Equal(expected))
+	})
+
+	It("Fails if the total difficulty of the parent of the first block is unknown", func() {
+		eth.TearDownDB(db)
+		Expect(newService(nil).Import()).ToNot(Succeed())
+	})
+})
+
+var _ = Describe("Import of btc CAR files", func() {
+	var (
+		db   *postgres.DB
+		dir  string
+		file string
+	)
+	BeforeEach(func() {
+		var err error
+		db, err = shared.SetupDB()
+		Expect(err).ToNot(HaveOccurred())
+		btcmocks.PublishAndIndex(db, btcmocks.MockConvertedPayload, btcmocks.MockSegwitConvertedPayload)
+		dir, err = ioutil.TempDir("", "carimport")
+		Expect(err).ToNot(HaveOccurred())
+		file = filepath.Join(dir, "export.car")
+		types, err := export.ExportDataTypes(shared.Bitcoin, nil)
+		Expect(err).ToNot(HaveOccurred())
+		height := uint64(btcmocks.MockSegwitBlockPayload.BlockHeight)
+		exportService, err := export.NewService(&export.Config{
+			Chain:     shared.Bitcoin,
+			DataTypes: types,
+			Start:     height,
+			Stop:      height,
+			Output:    file,
+			DB:        db,
+			IPFSMode:  shared.DirectPostgres,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(exportService.Export()).To(Succeed())
+	})
+	AfterEach(func() {
+		btc.TearDownDB(db)
+		os.RemoveAll(dir)
+	})
+
+	importFile := func() error {
+		service, err := carimport.NewImportService(&carimport.Config{
+			Chain:    shared.Bitcoin,
+			Files:    []string{file},
+			DB:       db,
+			IPFSMode: shared.DirectPostgres,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		return service.Import()
+	}
+	// the index rows of the segwit block, leaving out ids and the columns that depend on the other indexed blocks
+	readRows := func() ([]btc.HeaderModel, []btc.TxModel) {
+		var headers []btc.HeaderModel
+		err := db.Select(&headers, `SELECT block_number, block_hash, parent_hash, cid, mh_key, timestamp, bits, coinbase_value,
+			subsidy, chain_work FROM btc.header_cids WHERE block_hash = $1`, btcmocks.MockSegwitBlock.BlockHash().String())
+		Expect(err).ToNot(HaveOccurred())
+		var txs []btc.TxModel
+		err = db.Select(&txs, `SELECT transaction_cids.index, tx_hash, transaction_cids.cid, transaction_cids.mh_key, segwit,
+			witness_hash, witness_cid, witness_mh_key FROM btc.transaction_cids
+			INNER JOIN btc.header_cids ON (transaction_cids.header_id = header_cids.id)
+			WHERE block_hash = $1 ORDER BY transaction_cids.index`, btcmocks.MockSegwitBlock.BlockHash().String())
+		Expect(err).ToNot(HaveOccurred())
+		return headers, txs
+	}
+
+	It("Rebuilds the index of a segwit block from the stripped and witness forms of its transactions", func() {
+		expectedHeaders, expectedTxs := readRows()
+		Expect(expectedHeaders).To(HaveLen(1))
+		Expect(expectedTxs).To(HaveLen(2))
+		Expect(expectedTxs[1].CID).ToNot(Equal(expectedTxs[1].WitnessCID))
+		// the parent of the block stays indexed, since the height of the block is counted on from it
+		_, err := db.Exec(`DELETE FROM btc.header_cids WHERE block_hash = $1`, btcmocks.MockSegwitBlock.BlockHash().String())
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(importFile()).To(Succeed())
+		headers, txs := readRows()
+		Expect(headers).To(Equal(expectedHeaders))
+		Expect(txs).To(Equal(expectedTxs))
+	})
+
+	It("Can be repeated without duplicating the index", func() {
+		expectedHeaders, expectedTxs := readRows()
+		Expect(importFile()).To(Succeed())
+		headers, txs := readRows()
+		Expect(headers).To(Equal(expectedHeaders))
+		Expect(txs).To(Equal(expectedTxs))
+	})
+})
+
+var _ = Describe("FileChain", func() {
+	It("Tells the chain of the CAR file by the codec of its roots", func() {
+		dir, err := ioutil.TempDir("", "carimport")
+		Expect(err).ToNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "header.car")
+		f, err := os.Create(path)
+		Expect(err).ToNot(HaveOccurred())
+		w, err := car.NewWriter(f, []cid.Cid{mocks.HeaderCID})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(w.Put(mocks.HeaderCID, mocks.MockIPLDs.Header.Data)).To(Succeed())
+		Expect(w.Flush()).To(Succeed())
+		Expect(f.Close()).To(Succeed())
+
+		chain, err := carimport.FileChain(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(chain).To(Equal(shared.Ethereum))
+	})
+})
@@ -0,0 +1,120 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package check
+
+import (
+	"fmt"
+
+	"github.com/ipfs/go-blockservice"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/blockstore"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/config"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/node"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+	"github.com/vulcanize/ipfs-blockchain-watcher/utils"
+)
+
+// Env variables
+const (
+	CHECK_CHAIN      = "CHECK_CHAIN"
+	CHECK_START      = "CHECK_START"
+	CHECK_STOP       = "CHECK_STOP"
+	CHECK_RANGE_SIZE = "CHECK_RANGE_SIZE"
+	CHECK_REPORT     = "CHECK_REPORT"
+	CHECK_RESYNC     = "CHECK_RESYNC"
+)
+
+// DefaultRangeSize is the default number of block heights whose blocks are checked in a single db tx
+const DefaultRangeSize = 100
+
+// Config holds the parameters needed to check the blocks of the indexed data against the index
+type Config struct {
+	Chain     shared.ChainType // The chain whose blocks to check
+	Start     uint64           // The first block height to check
+	Stop      uint64           // The last block height to check; 0 checks up to the highest indexed header
+	RangeSize uint64           // The number of block heights whose blocks are checked in a single db tx
+	Report    string           // Path of a CSV file to write the discrepancies to, if any
+	Resync    bool             // Resync the heights with discrepancies once the check is done
+
+	// DB info
+	DB       *postgres.DB
+	DBConfig config.Database
+
+	IPFSPath string
+	IPFSMode shared.IPFSMode
+	// Block service of the IPFS repo in the LocalInterface and RemoteClient modes; if nil it is opened from IPFSPath
+	BlockService blockservice.BlockService
+	// The blockstores used in DirectPostgres mode
+	Blockstores *shared.Blockstores
+}
+
+// NewConfig fills and returns a check config from toml parameters
+func NewConfig() (*Config, error) {
+	c := new(Config)
+	var err error
+
+	viper.BindEnv("check.chain", CHECK_CHAIN)
+	viper.BindEnv("check.start", CHECK_START)
+	viper.BindEnv("check.stop", CHECK_STOP)
+	viper.BindEnv("check.rangeSize", CHECK_RANGE_SIZE)
+	viper.BindEnv("check.report", CHECK_REPORT)
+	viper.BindEnv("check.resync", CHECK_RESYNC)
+
+	c.Chain, err = shared.NewChainType(viper.GetString("check.chain"))
+	if err != nil {
+		return nil, err
+	}
+	c.Start = uint64(viper.GetInt64("check.start"))
+	c.Stop = uint64(viper.GetInt64("check.stop"))
+	if c.Stop != 0 && c.Stop < c.Start {
+		return nil, fmt.Errorf("check stop height %d is below the start height %d", c.Stop, c.Start)
+	}
+	c.RangeSize = uint64(viper.GetInt64("check.rangeSize"))
+	if c.RangeSize == 0 {
+		c.RangeSize = DefaultRangeSize
+	}
+	c.Report = viper.GetString("check.report")
+	c.Resync = viper.GetBool("check.resync")
+
+	c.IPFSMode, err = shared.GetIPFSMode()
+	if err != nil {
+		return nil, err
+	}
+	if c.IPFSMode == shared.LocalInterface {
+		c.IPFSPath, err = shared.GetIPFSPath()
+		if err != nil {
+			return nil, err
+		}
+	}
+	// in remote mode the ipfs path is the address of the daemon's HTTP API
+	if c.IPFSMode == shared.RemoteClient {
+		c.IPFSPath = shared.GetIPFSAPI()
+	}
+	if c.IPFSMode == shared.DirectPostgres {
+		c.Blockstores, err = blockstore.NewBlockstores()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.DBConfig.Init()
+	db := utils.LoadPostgres(c.DBConfig, node.Node{})
+	c.DB = &db
+	return c, nil
+}
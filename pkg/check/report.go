@@ -0,0 +1,112 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package check
+
+import (
+	"encoding/csv"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// Problem is what is wrong with a block referenced by the index
+type Problem string
+
+const (
+	// Missing blocks are not in the store
+	Missing Problem = "missing"
+	// Corrupt blocks hold data that does not hash to their cid
+	Corrupt Problem = "corrupt"
+	// KeyMismatch is an index row whose mh_key is not the key of its cid
+	KeyMismatch Problem = "key mismatch"
+)
+
+// Discrepancy is a block that does not match the index row, or the header, referencing it
+type Discrepancy struct {
+	BlockNumber uint64
+	DataType    shared.DataType
+	CID         string
+	MhKey       string
+	Problem     Problem
+}
+
+// Report lists the discrepancies a check found
+type Report struct {
+	Checked       int // The number of blocks checked
+	Discrepancies []Discrepancy
+}
+
+func (r *Report) add(d Discrepancy) {
+	r.Discrepancies = append(r.Discrepancies, d)
+}
+
+// Has returns whether any of the discrepancies is the problem
+func (r *Report) Has(problem Problem) bool {
+	for _, d := range r.Discrepancies {
+		if d.Problem == problem {
+			return true
+		}
+	}
+	return false
+}
+
+// Ranges returns the block heights with discrepancies as ranges of consecutive heights, in the form the resync service
+// takes them
+func (r *Report) Ranges() [][2]uint64 {
+	heights := make([]uint64, 0, len(r.Discrepancies))
+	for _, d := range r.Discrepancies {
+		heights = append(heights, d.BlockNumber)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	var ranges [][2]uint64
+	for _, height := range heights {
+		if last := len(ranges) - 1; last >= 0 && height <= ranges[last][1]+1 {
+			if height > ranges[last][1] {
+				ranges[last][1] = height
+			}
+			continue
+		}
+		ranges = append(ranges, [2]uint64{height, height})
+	}
+	return ranges
+}
+
+// WriteCSV writes the discrepancies to a CSV file at the path
+func (r *Report) WriteCSV(path string) (err error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	w := csv.NewWriter(file)
+	if err := w.Write([]string{"block_number", "data_type", "cid", "mh_key", "problem"}); err != nil {
+		return err
+	}
+	for _, d := range r.Discrepancies {
+		if err := w.Write([]string{strconv.FormatUint(d.BlockNumber, 10), d.DataType.String(), d.CID, d.MhKey, string(d.Problem)}); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,194 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package check
+
+import (
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/builders"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// Service checks that every block referenced by the index of a range of data, and by the tries (merkle trees for btc)
+// hanging off of its headers, is in the store and hashes to its cid
+type Service struct {
+	db     *postgres.DB
+	chain  shared.ChainType
+	source shared.BlockSource
+	// Queries for the blocks to check, one per type of data
+	pgStrs    []string
+	dataTypes []shared.DataType
+	start     uint64
+	stop      uint64
+	rangeSize uint64
+	report    string
+}
+
+// NewService creates and returns a check service from the provided settings
+func NewService(settings *Config) (*Service, error) {
+	dataTypes, err := shared.ChainDataTypes(settings.Chain)
+	if err != nil {
+		return nil, err
+	}
+	pgStrs := make([]string, len(dataTypes))
+	for i, dataType := range dataTypes {
+		if pgStrs[i], err = builders.CIDsPgStr(settings.Chain, dataType); err != nil {
+			return nil, err
+		}
+	}
+	source, err := shared.NewBlockSource(settings.IPFSMode, settings.IPFSPath, settings.Blockstores, settings.BlockService)
+	if err != nil {
+		return nil, fmt.Errorf("check: %v", err)
+	}
+	rangeSize := settings.RangeSize
+	if rangeSize == 0 {
+		rangeSize = DefaultRangeSize
+	}
+	return &Service{
+		db:        settings.DB,
+		chain:     settings.Chain,
+		source:    source,
+		pgStrs:    pgStrs,
+		dataTypes: dataTypes,
+		start:     settings.Start,
+		stop:      settings.Stop,
+		rangeSize: rangeSize,
+		report:    settings.Report,
+	}, nil
+}
+
+// Check checks the blocks of the data at the block heights from start to stop, rangeSize heights at a time, and returns
+// the report of the discrepancies it found, which is also written to the report file if one is configured
+// Every discrepancy is logged; a check only fails if the blocks can't be read at all
+func (s *Service) Check() (*Report, error) {
+	stop := s.stop
+	if stop == 0 {
+		pgStr := `SELECT COALESCE(MAX(block_number), 0) FROM ` + s.chain.API() + `.header_cids`
+		if err := s.db.Get(&stop, pgStr); err != nil {
+			return nil, err
+		}
+	}
+	report := new(Report)
+	for start := s.start; start <= stop; start += s.rangeSize {
+		end := start + s.rangeSize - 1
+		if end > stop || end < start {
+			end = stop
+		}
+		checked, found := report.Checked, len(report.Discrepancies)
+		if err := s.checkRange(report, start, end); err != nil {
+			return nil, err
+		}
+		logrus.Infof("checked %d %s blocks at heights %d to %d, found %d discrepancies", report.Checked-checked, s.chain.String(), start, end, len(report.Discrepancies)-found)
+		if end == stop {
+			break
+		}
+	}
+	logrus.Infof("checked %d %s blocks at heights %d to %d, found %d discrepancies", report.Checked, s.chain.String(), s.start, stop, len(report.Discrepancies))
+	if s.report != "" {
+		if err := report.WriteCSV(s.report); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// checkRange checks the blocks of the data at the block heights from start to stop
+func (s *Service) checkRange(report *Report, start, stop uint64) (err error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	// blocks referenced more than once are only checked once
+	seen := make(map[string]bool)
+	for i, dataType := range s.dataTypes {
+		var iplds []builders.IndexedIPLD
+		if err = tx.Select(&iplds, s.pgStrs[i], start, stop); err != nil {
+			return err
+		}
+		for _, ipld := range iplds {
+			var c cid.Cid
+			if c, err = cid.Decode(ipld.CID); err != nil {
+				return err
+			}
+			mhKey := shared.MultihashKeyFromCID(c)
+			if mhKey != ipld.MhKey {
+				logrus.Errorf("%s block %s at height %d is indexed under the key %s", dataType.String(), ipld.CID, ipld.BlockNumber, ipld.MhKey)
+				report.add(Discrepancy{BlockNumber: ipld.BlockNumber, DataType: dataType, CID: ipld.CID, MhKey: ipld.MhKey, Problem: KeyMismatch})
+			}
+			var data []byte
+			if data, err = s.checkBlock(tx, report, seen, ipld.BlockNumber, dataType, c, mhKey); err != nil {
+				return err
+			}
+			if dataType == shared.Headers && data != nil {
+				if err = s.checkUnindexed(tx, report, seen, ipld.BlockNumber, data); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkUnindexed checks the trie and merkle nodes that hang off of the header, which are published but not indexed
+// The nodes below a node with a discrepancy can't be reached, and are not checked
+func (s *Service) checkUnindexed(tx *sqlx.Tx, report *Report, seen map[string]bool, blockNumber uint64, headerData []byte) error {
+	return builders.WalkUnindexedBlocks(s.chain, headerData, func(t shared.DataType, c cid.Cid) ([]byte, error) {
+		return s.checkBlock(tx, report, seen, blockNumber, t, c, shared.MultihashKeyFromCID(c))
+	}, func(t shared.DataType, c cid.Cid, data []byte) error {
+		return nil
+	})
+}
+
+// checkBlock checks that the block is in the store and hashes to its cid, and returns its data if it does
+// The data is nil if the block has been checked before, or has a discrepancy, which is added to the report
+func (s *Service) checkBlock(tx *sqlx.Tx, report *Report, seen map[string]bool, blockNumber uint64, t shared.DataType, c cid.Cid, mhKey string) ([]byte, error) {
+	if seen[mhKey] {
+		return nil, nil
+	}
+	seen[mhKey] = true
+	report.Checked++
+	data, err := s.source.Get(tx, t, c, mhKey)
+	if err == shared.ErrBlockNotFound {
+		logrus.Errorf("%s block %s at height %d is missing", t.String(), c.String(), blockNumber)
+		report.add(Discrepancy{BlockNumber: blockNumber, DataType: t, CID: c.String(), MhKey: mhKey, Problem: Missing})
+		return nil, nil
+	}
+	if err == shared.ErrBlockCorrupt {
+		logrus.Errorf("%s block %s at height %d does not match its cid", t.String(), c.String(), blockNumber)
+		report.add(Discrepancy{BlockNumber: blockNumber, DataType: t, CID: c.String(), MhKey: mhKey, Problem: Corrupt})
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,205 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package check_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	btcmocks "github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/check"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth/mocks"
+	ipfsmocks "github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+var (
+	blockNumber    = mocks.MockBlock.Number().Uint64()
+	btcBlockNumber = uint64(btcmocks.MockSegwitBlockPayload.BlockHeight)
+	rctTrieMhKey   = shared.MultihashKeyFromCID(mocks.RctTrieCID)
+	merkleRootKey  = shared.MultihashKeyFromCID(btcmocks.MockSegwitMerkleRootCID)
+	commitmentKey  = shared.MultihashKeyFromCID(btcmocks.MockSegwitCommitmentCID)
+)
+
+var _ = Describe("Check", func() {
+	var db *postgres.DB
+	AfterEach(func() {
+		eth.TearDownDB(db)
+		btc.TearDownDB(db)
+	})
+
+	newService := func(chain shared.ChainType, report string) *check.Service {
+		service, err := check.NewService(&check.Config{
+			Chain:    chain,
+			IPFSMode: shared.DirectPostgres,
+			Report:   report,
+			DB:       db,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		return service
+	}
+	// discrepancies checks the chain after running the statement, and returns the discrepancies it finds
+	discrepancies := func(chain shared.ChainType, pgStr string, args ...interface{}) []check.Discrepancy {
+		_, err := db.Exec(pgStr, args...)
+		Expect(err).ToNot(HaveOccurred())
+		report, err := newService(chain, "").Check()
+		Expect(err).ToNot(HaveOccurred())
+		return report.Discrepancies
+	}
+
+	Describe("Ethereum", func() {
+		BeforeEach(func() {
+			db = mocks.SetupIndexedDB()
+		})
+
+		It("Finds no discrepancies in intact blocks", func() {
+			report, err := newService(shared.Ethereum, "").Check()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(report.Checked).To(BeNumerically(">", 0))
+			Expect(report.Discrepancies).To(BeEmpty())
+			Expect(report.Ranges()).To(BeEmpty())
+		})
+
+		table.DescribeTable("Finds the discrepancies between the index and the blockstore",
+			func(pgStr string, args []interface{}, expected check.Discrepancy) {
+				Expect(discrepancies(shared.Ethereum, pgStr, args...)).To(ConsistOf(expected))
+			},
+			table.Entry("missing blocks", `DELETE FROM public.blocks WHERE key = $1`, []interface{}{mocks.Trx1MhKey},
+				check.Discrepancy{BlockNumber: blockNumber, DataType: shared.Transactions, CID: mocks.Trx1CID.String(), MhKey: mocks.Trx1MhKey, Problem: check.Missing}),
+			table.Entry("corrupt blocks", `UPDATE public.blocks SET data = $1 WHERE key = $2`, []interface{}{[]byte{1, 2, 3}, mocks.State1MhKey},
+				check.Discrepancy{BlockNumber: blockNumber, DataType: shared.State, CID: mocks.State1CID.String(), MhKey: mocks.State1MhKey, Problem: check.Corrupt}),
+			table.Entry("index rows whose mh_key does not match their cid", `UPDATE eth.receipt_cids SET mh_key = $1 WHERE cid = $2`, []interface{}{mocks.Rct2MhKey, mocks.Rct1CID.String()},
+				check.Discrepancy{BlockNumber: blockNumber, DataType: shared.Receipts, CID: mocks.Rct1CID.String(), MhKey: mocks.Rct2MhKey, Problem: check.KeyMismatch}),
+			table.Entry("missing trie nodes hanging off of the headers", `DELETE FROM public.blocks WHERE key = $1`, []interface{}{rctTrieMhKey},
+				check.Discrepancy{BlockNumber: blockNumber, DataType: shared.Receipts, CID: mocks.RctTrieCID.String(), MhKey: rctTrieMhKey, Problem: check.Missing}),
+			// the nodes below a corrupt trie node or a missing header can't be reached, and are not checked
+			table.Entry("corrupt trie nodes hanging off of the headers", `UPDATE public.blocks SET data = $1 WHERE key = $2`, []interface{}{[]byte{1, 2, 3}, rctTrieMhKey},
+				check.Discrepancy{BlockNumber: blockNumber, DataType: shared.Receipts, CID: mocks.RctTrieCID.String(), MhKey: rctTrieMhKey, Problem: check.Corrupt}),
+			table.Entry("missing headers", `DELETE FROM public.blocks WHERE key = $1`, []interface{}{mocks.HeaderMhKey},
+				check.Discrepancy{BlockNumber: blockNumber, DataType: shared.Headers, CID: mocks.HeaderCID.String(), MhKey: mocks.HeaderMhKey, Problem: check.Missing}),
+		)
+
+		It("Reports the heights and kinds of the discrepancies", func() {
+			_, err := db.Exec(`DELETE FROM public.blocks WHERE key = $1`, mocks.Trx1MhKey)
+			Expect(err).ToNot(HaveOccurred())
+			_, err = db.Exec(`UPDATE public.blocks SET data = $1 WHERE key = $2`, []byte{1, 2, 3}, mocks.State1MhKey)
+			Expect(err).ToNot(HaveOccurred())
+			report, err := newService(shared.Ethereum, "").Check()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(report.Discrepancies).To(HaveLen(2))
+			Expect(report.Has(check.Corrupt)).To(BeTrue())
+			Expect(report.Has(check.KeyMismatch)).To(BeFalse())
+			Expect(report.Ranges()).To(Equal([][2]uint64{{blockNumber, blockNumber}}))
+		})
+
+		It("Writes the discrepancies to the report", func() {
+			dir, err := ioutil.TempDir("", "check")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+			_, err = db.Exec(`DELETE FROM public.blocks WHERE key = $1`, mocks.HeaderMhKey)
+			Expect(err).ToNot(HaveOccurred())
+			path := filepath.Join(dir, "report.csv")
+			_, err = newService(shared.Ethereum, path).Check()
+			Expect(err).ToNot(HaveOccurred())
+			written, err := ioutil.ReadFile(path)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(written)).To(ContainSubstring(mocks.HeaderCID.String() + "," + mocks.HeaderMhKey + ",missing"))
+		})
+
+		It("Finds the blocks an IPFS daemon serves corrupt in RemoteClient mode", func() {
+			server := ipfsmocks.NewIPFSAPIServer()
+			defer server.Close()
+			var blocks []struct {
+				Key  string `db:"key"`
+				Data []byte `db:"data"`
+			}
+			Expect(db.Select(&blocks, `SELECT key, data FROM public.blocks`)).To(Succeed())
+			for _, block := range blocks {
+				hash, err := shared.MultihashFromKey(block.Key)
+				Expect(err).ToNot(HaveOccurred())
+				server.SetBlock(hash, block.Data)
+			}
+			server.SetBlock(mocks.State1CID.Hash(), []byte{1, 2, 3})
+			service, err := check.NewService(&check.Config{
+				Chain:    shared.Ethereum,
+				IPFSMode: shared.RemoteClient,
+				IPFSPath: server.URL,
+				DB:       db,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			report, err := service.Check()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(report.Discrepancies).To(ConsistOf(
+				check.Discrepancy{BlockNumber: blockNumber, DataType: shared.State, CID: mocks.State1CID.String(), MhKey: mocks.State1MhKey, Problem: check.Corrupt},
+			))
+		})
+	})
+
+	Describe("Bitcoin", func() {
+		BeforeEach(func() {
+			var err error
+			db, err = shared.SetupDB()
+			Expect(err).ToNot(HaveOccurred())
+			btcmocks.PublishAndIndex(db, btcmocks.MockSegwitConvertedPayload)
+		})
+
+		It("Finds no discrepancies in intact blocks", func() {
+			report, err := newService(shared.Bitcoin, "").Check()
+			Expect(err).ToNot(HaveOccurred())
+			// the header, the stripped and witness forms of the segwit transaction, the merkle root, and the witness
+			// commitment with the root of the witness tree
+			Expect(report.Checked).To(BeNumerically(">=", 6))
+			Expect(report.Discrepancies).To(BeEmpty())
+		})
+
+		It("Finds missing witness forms of segwit transactions", func() {
+			var witnessCID, witnessMhKey string
+			Expect(db.QueryRow(`SELECT witness_cid, witness_mh_key FROM btc.transaction_cids WHERE index = 1`).Scan(&witnessCID, &witnessMhKey)).To(Succeed())
+			Expect(discrepancies(shared.Bitcoin, `DELETE FROM public.blocks WHERE key = $1`, witnessMhKey)).To(ConsistOf(
+				check.Discrepancy{BlockNumber: btcBlockNumber, DataType: shared.Transactions, CID: witnessCID, MhKey: witnessMhKey, Problem: check.Missing},
+			))
+		})
+
+		table.DescribeTable("Finds the discrepancies in the merkle and witness trees",
+			func(pgStr string, args []interface{}, expected check.Discrepancy) {
+				Expect(discrepancies(shared.Bitcoin, pgStr, args...)).To(ConsistOf(expected))
+			},
+			table.Entry("corrupt witness commitments", `UPDATE public.blocks SET data = $1 WHERE key = $2`, []interface{}{make([]byte, 64), commitmentKey},
+				check.Discrepancy{BlockNumber: btcBlockNumber, DataType: shared.Transactions, CID: btcmocks.MockSegwitCommitmentCID.String(), MhKey: commitmentKey, Problem: check.Corrupt}),
+			// the nodes below a missing merkle root can't be reached, and are not checked
+			table.Entry("missing merkle roots", `DELETE FROM public.blocks WHERE key = $1`, []interface{}{merkleRootKey},
+				check.Discrepancy{BlockNumber: btcBlockNumber, DataType: shared.Transactions, CID: btcmocks.MockSegwitMerkleRootCID.String(), MhKey: merkleRootKey, Problem: check.Missing}),
+		)
+	})
+})
+
+var _ = Describe("Report", func() {
+	It("Merges the heights with discrepancies into ranges", func() {
+		report := &check.Report{Discrepancies: []check.Discrepancy{
+			{BlockNumber: 7}, {BlockNumber: 3}, {BlockNumber: 4}, {BlockNumber: 4}, {BlockNumber: 10}, {BlockNumber: 5},
+		}}
+		Expect(report.Ranges()).To(Equal([][2]uint64{{3, 5}, {7, 7}, {10, 10}}))
+	})
+})
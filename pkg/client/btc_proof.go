@@ -0,0 +1,100 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+)
+
+// GetTxOutProof requests the merkle block proving the inclusion of the provided transactions
+// If blockHash is nil the watcher looks the transactions up in the block the first of them was included in
+func (c *Client) GetTxOutProof(ctx context.Context, txids []chainhash.Hash, blockHash *chainhash.Hash) (*wire.MsgMerkleBlock, error) {
+	ids := make([]string, len(txids))
+	for i, txid := range txids {
+		ids[i] = txid.String()
+	}
+	var proofHex string
+	var err error
+	if blockHash != nil {
+		err = c.c.CallContext(ctx, &proofHex, "btc_getTxOutProof", ids, blockHash.String())
+	} else {
+		err = c.c.CallContext(ctx, &proofHex, "btc_getTxOutProof", ids)
+	}
+	if err != nil {
+		return nil, err
+	}
+	proofBytes, err := hex.DecodeString(proofHex)
+	if err != nil {
+		return nil, err
+	}
+	proof := new(wire.MsgMerkleBlock)
+	return proof, proof.BtcDecode(bytes.NewReader(proofBytes), wire.ProtocolVersion, wire.WitnessEncoding)
+}
+
+// GetBlockHeader requests the header of the block with the provided hash
+func (c *Client) GetBlockHeader(ctx context.Context, blockHash chainhash.Hash) (*wire.BlockHeader, error) {
+	var headerHex string
+	if err := c.c.CallContext(ctx, &headerHex, "btc_getBlockHeader", blockHash.String(), false); err != nil {
+		return nil, err
+	}
+	headerBytes, err := hex.DecodeString(headerHex)
+	if err != nil {
+		return nil, err
+	}
+	header := new(wire.BlockHeader)
+	if err := header.Deserialize(bytes.NewReader(headerBytes)); err != nil {
+		return nil, err
+	}
+	if header.BlockHash() != blockHash {
+		return nil, fmt.Errorf("received header %s, expected header %s", header.BlockHash().String(), blockHash.String())
+	}
+	return header, nil
+}
+
+// VerifyTxOutProof checks the merkle block against the provided header, which should come from a chain of headers the
+// caller has validated, and returns the ids of the transactions it proves were included in the header's block
+// The proof of work of the header is checked against the target encoded in its own bits
+func VerifyTxOutProof(header *wire.BlockHeader, proof *wire.MsgMerkleBlock) ([]chainhash.Hash, error) {
+	if proof.Header.BlockHash() != header.BlockHash() {
+		return nil, fmt.Errorf("proof is for block %s, not block %s", proof.Header.BlockHash().String(), header.BlockHash().String())
+	}
+	target := blockchain.CompactToBig(header.Bits)
+	if target.Sign() <= 0 {
+		return nil, fmt.Errorf("block %s has an invalid target", header.BlockHash().String())
+	}
+	hash := header.BlockHash()
+	if blockchain.HashToBig(&hash).Cmp(target) > 0 {
+		return nil, fmt.Errorf("block %s does not satisfy its proof of work target", hash.String())
+	}
+	root, matches, err := btc.ExtractMatches(proof)
+	if err != nil {
+		return nil, err
+	}
+	if root != header.MerkleRoot {
+		return nil, fmt.Errorf("proof is against merkle root %s, expected merkle root %s", root.String(), header.MerkleRoot.String())
+	}
+	return matches, nil
+}
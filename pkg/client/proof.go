@@ -0,0 +1,103 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+)
+
+// GetTransactionProof requests the proof for the transaction at the provided index in the provided block
+func (c *Client) GetTransactionProof(ctx context.Context, blockHash common.Hash, index uint64) (*eth.InclusionProof, error) {
+	proof := new(eth.InclusionProof)
+	return proof, c.c.CallContext(ctx, proof, "eth_getTransactionProof", blockHash, hexutil.Uint64(index))
+}
+
+// GetReceiptProof requests the proof for the receipt at the provided index in the provided block
+func (c *Client) GetReceiptProof(ctx context.Context, blockHash common.Hash, index uint64) (*eth.InclusionProof, error) {
+	proof := new(eth.InclusionProof)
+	return proof, c.c.CallContext(ctx, proof, "eth_getReceiptProof", blockHash, hexutil.Uint64(index))
+}
+
+// VerifyTransactionProof checks the proof against the transaction root of the provided header
+// and returns the proven transaction
+func VerifyTransactionProof(header *types.Header, proof *eth.InclusionProof) (*types.Transaction, error) {
+	value, err := verifyInclusionProof(header, header.TxHash, proof)
+	if err != nil {
+		return nil, err
+	}
+	tx := new(types.Transaction)
+	return tx, rlp.DecodeBytes(value, tx)
+}
+
+// VerifyReceiptProof checks the proof against the receipt root of the provided header
+// and returns the proven receipt
+func VerifyReceiptProof(header *types.Header, proof *eth.InclusionProof) (*types.Receipt, error) {
+	value, err := verifyInclusionProof(header, header.ReceiptHash, proof)
+	if err != nil {
+		return nil, err
+	}
+	rct := new(types.Receipt)
+	return rct, rlp.DecodeBytes(value, rct)
+}
+
+// verifyInclusionProof checks that the proof is for the provided header and root, and that the proof nodes
+// lead from the root to the proof's value at the key for the proof's index
+func verifyInclusionProof(header *types.Header, root common.Hash, proof *eth.InclusionProof) ([]byte, error) {
+	if proof.BlockHash != header.Hash() {
+		return nil, fmt.Errorf("proof is for block %s, not block %s", proof.BlockHash.Hex(), header.Hash().Hex())
+	}
+	if proof.Root != root {
+		return nil, fmt.Errorf("proof is against root %s, expected root %s", proof.Root.Hex(), root.Hex())
+	}
+	proofDB := memorydb.New()
+	for _, node := range proof.Proof {
+		nodeBytes, err := hexutil.Decode(node)
+		if err != nil {
+			return nil, err
+		}
+		if err := proofDB.Put(crypto.Keccak256(nodeBytes), nodeBytes); err != nil {
+			return nil, err
+		}
+	}
+	key, err := rlp.EncodeToBytes(uint(proof.Index))
+	if err != nil {
+		return nil, err
+	}
+	value, _, err := trie.VerifyProof(root, key, proofDB)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nil, fmt.Errorf("proof shows that index %d is not in the trie", proof.Index)
+	}
+	if !bytes.Equal(value, proof.Value) {
+		return nil, fmt.Errorf("proven value does not match the value in the proof for index %d", proof.Index)
+	}
+	return value, nil
+}
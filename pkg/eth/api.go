@@ -18,15 +18,12 @@ package eth
 
 import (
 	"context"
-	"math/big"
 
-	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
-
-	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rpc"
+	log "github.com/sirupsen/logrus"
 )
 
 // APIName is the namespace for the watcher's eth api
@@ -35,14 +32,22 @@ const APIName = "eth"
 // APIVersion is the version of the watcher's eth api
 const APIVersion = "0.0.1"
 
+// SubscriptionChanBufferSize is the buffer size of the channels used to feed eth_subscribe subscriptions
+const SubscriptionChanBufferSize = 2000
+
 type PublicEthAPI struct {
-	B *Backend
+	B       *Backend
+	events  *EventSystem
+	filters *FilterManager
 }
 
-// NewPublicEthAPI creates a new PublicEthAPI with the provided underlying Backend
-func NewPublicEthAPI(b *Backend) *PublicEthAPI {
+// NewPublicEthAPI creates a new PublicEthAPI with the provided underlying Backend, EventSystem and FilterManager
+// The EventSystem and FilterManager can be nil, in which case the subscription and filter endpoints are unavailable
+func NewPublicEthAPI(b *Backend, events *EventSystem, filters *FilterManager) *PublicEthAPI {
 	return &PublicEthAPI{
-		B: b,
+		B:       b,
+		events:  events,
+		filters: filters,
 	}
 }
 
@@ -53,97 +58,154 @@ func (pea *PublicEthAPI) BlockNumber() hexutil.Uint64 {
 }
 
 // GetLogs returns logs matching the given argument that are stored within the state.
+// The block range and number of results are limited by the backend's LogsConfig
 //
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_getlogs
-func (pea *PublicEthAPI) GetLogs(ctx context.Context, crit ethereum.FilterQuery) ([]*types.Log, error) {
-	// Convert FilterQuery into ReceiptFilter
-	addrStrs := make([]string, len(crit.Addresses))
-	for i, addr := range crit.Addresses {
-		addrStrs[i] = addr.String()
-	}
-	topicStrSets := make([][]string, 4)
-	for i, topicSet := range crit.Topics {
-		if i > 3 {
-			// don't allow more than 4 topics
-			break
-		}
-		for _, topic := range topicSet {
-			topicStrSets[i] = append(topicStrSets[i], topic.String())
-		}
+func (pea *PublicEthAPI) GetLogs(ctx context.Context, crit FilterCriteria) ([]*types.Log, error) {
+	return pea.B.FilterLogs(ctx, crit)
+}
+
+// NewHeads sends a notification each time a new header is appended to the chain, including chain reorganizations.
+//
+// https://github.com/ethereum/go-ethereum/wiki/RPC-PUB-SUB#newheads
+func (pea *PublicEthAPI) NewHeads(ctx context.Context) (*rpc.Subscription, error) {
+	if pea.events == nil {
+		return nil, errSubscriptionsUnavailable
 	}
-	filter := ReceiptFilter{
-		LogAddresses: addrStrs,
-		Topics:       topicStrSets,
+	// ensure that the RPC connection supports subscriptions
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
 	}
 
-	// Begin tx
-	tx, err := pea.B.DB.Beginx()
-	if err != nil {
-		return nil, err
-	}
-	defer func() {
-		if p := recover(); p != nil {
-			shared.Rollback(tx)
-			panic(p)
-		} else if err != nil {
-			shared.Rollback(tx)
-		} else {
-			err = tx.Commit()
+	// create subscription and start waiting for header events
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		headers := make(chan *types.Header, SubscriptionChanBufferSize)
+		pea.events.SubscribeNewHeads(rpcSub.ID, headers)
+		defer pea.events.Unsubscribe(rpcSub.ID)
+
+		for {
+			select {
+			case header := <-headers:
+				if err := notifier.Notify(rpcSub.ID, header); err != nil {
+					log.Errorf("failed to send eth header notification: %v", err)
+					return
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
 		}
 	}()
 
-	// If we have a blockhash to filter on, fire off single retrieval query
-	if crit.BlockHash != nil {
-		rctCIDs, err := pea.B.Retriever.RetrieveRctCIDs(tx, filter, 0, crit.BlockHash, nil)
-		if err != nil {
-			return nil, err
-		}
-		rctIPLDs, err := pea.B.Fetcher.FetchRcts(tx, rctCIDs)
-		if err != nil {
-			return nil, err
-		}
-		if err := tx.Commit(); err != nil {
-			return nil, err
-		}
-		return extractLogsOfInterest(rctIPLDs, filter.Topics)
-	}
-	// Otherwise, create block range from criteria
-	// nil values are filled in; to request a single block have both ToBlock and FromBlock equal that number
-	startingBlock := crit.FromBlock
-	endingBlock := crit.ToBlock
-	if startingBlock == nil {
-		startingBlockInt, err := pea.B.Retriever.RetrieveFirstBlockNumber()
-		if err != nil {
-			return nil, err
-		}
-		startingBlock = big.NewInt(startingBlockInt)
+	return rpcSub, nil
+}
+
+// Logs creates a subscription that fires for all new logs that match the given filter criteria.
+// Logs from blocks that are removed by a reorg are sent again with the removed flag set.
+//
+// https://github.com/ethereum/go-ethereum/wiki/RPC-PUB-SUB#logs
+func (pea *PublicEthAPI) Logs(ctx context.Context, crit FilterCriteria) (*rpc.Subscription, error) {
+	if pea.events == nil {
+		return nil, errSubscriptionsUnavailable
 	}
-	if endingBlock == nil {
-		endingBlockInt, err := pea.B.Retriever.RetrieveLastBlockNumber()
-		if err != nil {
-			return nil, err
-		}
-		endingBlock = big.NewInt(endingBlockInt)
-	}
-	start := startingBlock.Int64()
-	end := endingBlock.Int64()
-	allRctCIDs := make([]ReceiptModel, 0)
-	for i := start; i <= end; i++ {
-		rctCIDs, err := pea.B.Retriever.RetrieveRctCIDs(tx, filter, i, nil, nil)
-		if err != nil {
-			return nil, err
+	// ensure that the RPC connection supports subscriptions
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+
+	// create subscription and start waiting for log events
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		matchedLogs := make(chan []*types.Log, SubscriptionChanBufferSize)
+		pea.events.SubscribeLogs(rpcSub.ID, crit, matchedLogs)
+		defer pea.events.Unsubscribe(rpcSub.ID)
+
+		for {
+			select {
+			case logs := <-matchedLogs:
+				for _, l := range logs {
+					if err := notifier.Notify(rpcSub.ID, l); err != nil {
+						log.Errorf("failed to send eth log notification: %v", err)
+						return
+					}
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
 		}
-		allRctCIDs = append(allRctCIDs, rctCIDs...)
+	}()
+
+	return rpcSub, nil
+}
+
+// NewFilter creates a new filter and returns the filter id. It can be
+// used to retrieve logs when the state changes. This method cannot be
+// used to fetch logs that are already stored in the state.
+//
+// https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_newfilter
+func (pea *PublicEthAPI) NewFilter(crit FilterCriteria) (rpc.ID, error) {
+	if pea.filters == nil {
+		return "", errSubscriptionsUnavailable
+	}
+	return pea.filters.NewLogFilter(crit), nil
+}
+
+// NewBlockFilter creates a filter that fetches blocks that are imported into the chain.
+// It is part of the filter package since polling goes with eth_getFilterChanges.
+//
+// https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_newblockfilter
+func (pea *PublicEthAPI) NewBlockFilter() (rpc.ID, error) {
+	if pea.filters == nil {
+		return "", errSubscriptionsUnavailable
+	}
+	return pea.filters.NewBlockFilter(), nil
+}
+
+// GetFilterChanges returns the logs for the filter with the given id since
+// last time it was called. This can be used for polling.
+//
+// For block filters the result is []common.Hash, for log filters it is []*types.Log
+//
+// https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_getfilterchanges
+func (pea *PublicEthAPI) GetFilterChanges(id rpc.ID) (interface{}, error) {
+	if pea.filters == nil {
+		return nil, errSubscriptionsUnavailable
+	}
+	return pea.filters.Changes(id)
+}
+
+// GetFilterLogs returns the logs for the filter with the given id.
+// If the filter could not be found an empty array of logs is returned.
+// The logs are retrieved from the historical data in Postgres
+//
+// https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_getfilterlogs
+func (pea *PublicEthAPI) GetFilterLogs(ctx context.Context, id rpc.ID) ([]*types.Log, error) {
+	if pea.filters == nil {
+		return nil, errSubscriptionsUnavailable
 	}
-	rctIPLDs, err := pea.B.Fetcher.FetchRcts(tx, allRctCIDs)
+	crit, err := pea.filters.Criteria(id)
 	if err != nil {
 		return nil, err
 	}
-	if err := tx.Commit(); err != nil {
-		return nil, err
+	return pea.B.FilterLogs(ctx, crit)
+}
+
+// UninstallFilter removes the filter with the given filter id.
+//
+// https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_uninstallfilter
+func (pea *PublicEthAPI) UninstallFilter(id rpc.ID) bool {
+	if pea.filters == nil {
+		return false
 	}
-	logs, err := extractLogsOfInterest(rctIPLDs, filter.Topics)
-	return logs, err // need to return err variable so that we return the err = tx.Commit() assignment in the defer
+	return pea.filters.Uninstall(id)
 }
 
 // GetHeaderByNumber returns the requested canonical block header.
@@ -194,3 +256,20 @@ func (pea *PublicEthAPI) GetTransactionByHash(ctx context.Context, hash common.H
 	// Transaction unknown, return as such
 	return nil, nil
 }
+
+// GetProof returns the EIP-1186 merkle proofs for the given account and storage keys at the given block
+// The proofs are built from the stored state and storage trie nodes, so they are only available for blocks
+// whose intermediate trie nodes have been indexed
+func (pea *PublicEthAPI) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNrOrHash rpc.BlockNumberOrHash) (*AccountResult, error) {
+	return pea.B.GetProof(ctx, address, storageKeys, blockNrOrHash)
+}
+
+// GetTransactionProof returns the merkle proof for the transaction at the given index in the given block's transaction trie
+func (pea *PublicEthAPI) GetTransactionProof(ctx context.Context, blockHash common.Hash, index hexutil.Uint64) (*InclusionProof, error) {
+	return pea.B.GetTransactionProof(ctx, blockHash, uint64(index))
+}
+
+// GetReceiptProof returns the merkle proof for the receipt at the given index in the given block's receipt trie
+func (pea *PublicEthAPI) GetReceiptProof(ctx context.Context, blockHash common.Hash, index hexutil.Uint64) (*InclusionProof, error) {
+	return pea.B.GetReceiptProof(ctx, blockHash, uint64(index))
+}
@@ -18,6 +18,7 @@ package eth_test
 
 import (
 	"context"
+	"math/big"
 	"strconv"
 
 	"github.com/ethereum/go-ethereum"
@@ -101,7 +102,7 @@ var _ = Describe("API", func() {
 			Fetcher:   fetcher,
 			DB:        db,
 		}
-		api = eth.NewPublicEthAPI(backend)
+		api = eth.NewPublicEthAPI(backend, nil, nil)
 		_, err = indexAndPublisher.Publish(mocks.MockConvertedPayload)
 		Expect(err).ToNot(HaveOccurred())
 		uncles := mocks.MockBlock.Uncles()
@@ -220,7 +221,7 @@ var _ = Describe("API", func() {
 				FromBlock: mocks.MockBlock.Number(),
 				ToBlock:   mocks.MockBlock.Number(),
 			}
-			logs, err := api.GetLogs(context.Background(), crit)
+			logs, err := api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
 			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1}))
@@ -235,7 +236,7 @@ var _ = Describe("API", func() {
 				FromBlock: mocks.MockBlock.Number(),
 				ToBlock:   mocks.MockBlock.Number(),
 			}
-			logs, err = api.GetLogs(context.Background(), crit)
+			logs, err = api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(2))
 			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1, mocks.MockLog2}))
@@ -250,7 +251,7 @@ var _ = Describe("API", func() {
 				FromBlock: mocks.MockBlock.Number(),
 				ToBlock:   mocks.MockBlock.Number(),
 			}
-			logs, err = api.GetLogs(context.Background(), crit)
+			logs, err = api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
 			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1}))
@@ -267,7 +268,7 @@ var _ = Describe("API", func() {
 				FromBlock: mocks.MockBlock.Number(),
 				ToBlock:   mocks.MockBlock.Number(),
 			}
-			logs, err = api.GetLogs(context.Background(), crit)
+			logs, err = api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(0))
 
@@ -283,7 +284,7 @@ var _ = Describe("API", func() {
 				FromBlock: mocks.MockBlock.Number(),
 				ToBlock:   mocks.MockBlock.Number(),
 			}
-			logs, err = api.GetLogs(context.Background(), crit)
+			logs, err = api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
 			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1}))
@@ -300,7 +301,7 @@ var _ = Describe("API", func() {
 				FromBlock: mocks.MockBlock.Number(),
 				ToBlock:   mocks.MockBlock.Number(),
 			}
-			logs, err = api.GetLogs(context.Background(), crit)
+			logs, err = api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
 			Expect(logs).To(Equal([]*types.Log{mocks.MockLog2}))
@@ -318,7 +319,7 @@ var _ = Describe("API", func() {
 				FromBlock: mocks.MockBlock.Number(),
 				ToBlock:   mocks.MockBlock.Number(),
 			}
-			logs, err = api.GetLogs(context.Background(), crit)
+			logs, err = api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
 			Expect(logs).To(Equal([]*types.Log{mocks.MockLog2}))
@@ -337,7 +338,7 @@ var _ = Describe("API", func() {
 				FromBlock: mocks.MockBlock.Number(),
 				ToBlock:   mocks.MockBlock.Number(),
 			}
-			logs, err = api.GetLogs(context.Background(), crit)
+			logs, err = api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(2))
 			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1, mocks.MockLog2}))
@@ -352,7 +353,7 @@ var _ = Describe("API", func() {
 				FromBlock: mocks.MockBlock.Number(),
 				ToBlock:   mocks.MockBlock.Number(),
 			}
-			logs, err = api.GetLogs(context.Background(), crit)
+			logs, err = api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
 			Expect(logs).To(Equal([]*types.Log{mocks.MockLog2}))
@@ -367,7 +368,7 @@ var _ = Describe("API", func() {
 				FromBlock: mocks.MockBlock.Number(),
 				ToBlock:   mocks.MockBlock.Number(),
 			}
-			logs, err = api.GetLogs(context.Background(), crit)
+			logs, err = api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
 			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1}))
@@ -377,7 +378,7 @@ var _ = Describe("API", func() {
 				FromBlock: mocks.MockBlock.Number(),
 				ToBlock:   mocks.MockBlock.Number(),
 			}
-			logs, err = api.GetLogs(context.Background(), crit)
+			logs, err = api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(2))
 			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1, mocks.MockLog2}))
@@ -394,7 +395,7 @@ var _ = Describe("API", func() {
 					},
 				},
 			}
-			logs, err := api.GetLogs(context.Background(), crit)
+			logs, err := api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
 			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1}))
@@ -410,7 +411,7 @@ var _ = Describe("API", func() {
 					},
 				},
 			}
-			logs, err = api.GetLogs(context.Background(), crit)
+			logs, err = api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
 			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1}))
@@ -424,7 +425,7 @@ var _ = Describe("API", func() {
 					},
 				},
 			}
-			logs, err = api.GetLogs(context.Background(), crit)
+			logs, err = api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
 			Expect(logs).To(Equal([]*types.Log{mocks.MockLog2}))
@@ -440,7 +441,7 @@ var _ = Describe("API", func() {
 					},
 				},
 			}
-			logs, err = api.GetLogs(context.Background(), crit)
+			logs, err = api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
 			Expect(logs).To(Equal([]*types.Log{mocks.MockLog2}))
@@ -456,7 +457,7 @@ var _ = Describe("API", func() {
 					},
 				},
 			}
-			logs, err = api.GetLogs(context.Background(), crit)
+			logs, err = api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(0))
 
@@ -472,7 +473,7 @@ var _ = Describe("API", func() {
 					},
 				},
 			}
-			logs, err = api.GetLogs(context.Background(), crit)
+			logs, err = api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
 			Expect(logs).To(Equal([]*types.Log{mocks.MockLog2}))
@@ -486,7 +487,7 @@ var _ = Describe("API", func() {
 					},
 				},
 			}
-			logs, err = api.GetLogs(context.Background(), crit)
+			logs, err = api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(2))
 			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1, mocks.MockLog2}))
@@ -504,7 +505,7 @@ var _ = Describe("API", func() {
 					},
 				},
 			}
-			logs, err = api.GetLogs(context.Background(), crit)
+			logs, err = api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(2))
 			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1, mocks.MockLog2}))
@@ -513,7 +514,7 @@ var _ = Describe("API", func() {
 				BlockHash: &hash,
 				Topics:    [][]common.Hash{},
 			}
-			logs, err = api.GetLogs(context.Background(), crit)
+			logs, err = api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(2))
 			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1, mocks.MockLog2}))
@@ -537,7 +538,7 @@ var _ = Describe("API", func() {
 					},
 				},
 			}
-			logs, err := api.GetLogs(context.Background(), crit)
+			logs, err := api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(1))
 			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1}))
@@ -560,7 +561,7 @@ var _ = Describe("API", func() {
 					},
 				},
 			}
-			logs, err = api.GetLogs(context.Background(), crit)
+			logs, err = api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(2))
 			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1, mocks.MockLog2}))
@@ -573,10 +574,62 @@ var _ = Describe("API", func() {
 					mocks.AnotherAddress,
 				},
 			}
-			logs, err = api.GetLogs(context.Background(), crit)
+			logs, err = api.GetLogs(context.Background(), eth.FilterCriteria(crit))
 			Expect(err).ToNot(HaveOccurred())
 			Expect(len(logs)).To(Equal(2))
 			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1, mocks.MockLog2}))
 		})
+
+		It("Defaults to the latest block", func() {
+			logs, err := api.GetLogs(context.Background(), eth.FilterCriteria{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1, mocks.MockLog2}))
+		})
+
+		It("Pages through the matching receipts", func() {
+			backend.LogsConfig = eth.LogsConfig{PageSize: 1}
+			logs, err := api.GetLogs(context.Background(), eth.FilterCriteria{
+				FromBlock: mocks.MockBlock.Number(),
+				ToBlock:   mocks.MockBlock.Number(),
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1, mocks.MockLog2}))
+		})
+
+		It("Errors if the block range or number of results exceed the limits", func() {
+			backend.LogsConfig = eth.LogsConfig{MaxBlockRange: 1, PageSize: 1}
+			_, err := api.GetLogs(context.Background(), eth.FilterCriteria{
+				FromBlock: big.NewInt(0),
+				ToBlock:   mocks.MockBlock.Number(),
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("exceeds the max block range"))
+
+			backend.LogsConfig = eth.LogsConfig{MaxResults: 1, PageSize: 1}
+			_, err = api.GetLogs(context.Background(), eth.FilterCriteria{
+				FromBlock: mocks.MockBlock.Number(),
+				ToBlock:   mocks.MockBlock.Number(),
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(err).To(MatchError("query returned more than 1 results"))
+
+			// the max results are checked across pages and within a page larger than them
+			backend.LogsConfig = eth.LogsConfig{MaxResults: 1, PageSize: 100}
+			_, err = api.GetLogs(context.Background(), eth.FilterCriteria{
+				FromBlock: mocks.MockBlock.Number(),
+				ToBlock:   mocks.MockBlock.Number(),
+			})
+			Expect(err).To(MatchError("query returned more than 1 results"))
+		})
+
+		It("Returns as many logs as the max results", func() {
+			backend.LogsConfig = eth.LogsConfig{MaxResults: 2, PageSize: 1}
+			logs, err := api.GetLogs(context.Background(), eth.FilterCriteria{
+				FromBlock: mocks.MockBlock.Number(),
+				ToBlock:   mocks.MockBlock.Number(),
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(logs).To(Equal([]*types.Log{mocks.MockLog1, mocks.MockLog2}))
+		})
 	})
 })
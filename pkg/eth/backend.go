@@ -29,25 +29,28 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/rpc"
-	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
 )
 
 var (
-	errPendingBlockNumber = errors.New("pending block number not supported")
+	errPendingBlockNumber       = errors.New("pending block number not supported")
+	errSubscriptionsUnavailable = errors.New("subscriptions are only available when the watcher is serving live data")
 )
 
 type Backend struct {
-	Retriever *CIDRetriever
-	Fetcher   *IPLDPGFetcher
-	DB        *postgres.DB
+	Retriever  *CIDRetriever
+	Fetcher    *IPLDPGFetcher
+	DB         *postgres.DB
+	LogsConfig LogsConfig
 }
 
 func NewEthBackend(db *postgres.DB) (*Backend, error) {
 	r := NewCIDRetriever(db)
 	return &Backend{
-		Retriever: r,
-		DB:        db,
+		Retriever:  r,
+		Fetcher:    NewIPLDPGFetcher(db),
+		DB:         db,
+		LogsConfig: NewLogsConfig(),
 	}, nil
 }
 
@@ -371,53 +374,6 @@ func (b *Backend) GetTransaction(ctx context.Context, txHash common.Hash) (*type
 }
 
 // extractLogsOfInterest returns logs from the receipt IPLD
-func extractLogsOfInterest(rctIPLDs []ipfs.BlockModel, wantedTopics [][]string) ([]*types.Log, error) {
-	var logs []*types.Log
-	for _, rctIPLD := range rctIPLDs {
-		rctRLP := rctIPLD
-		var rct types.Receipt
-		if err := rlp.DecodeBytes(rctRLP.Data, &rct); err != nil {
-			return nil, err
-		}
-		for _, log := range rct.Logs {
-			if wanted := wantedLog(wantedTopics, log.Topics); wanted == true {
-				logs = append(logs, log)
-			}
-		}
-	}
-	return logs, nil
-}
-
-// returns true if the log matches on the filter
-func wantedLog(wantedTopics [][]string, actualTopics []common.Hash) bool {
-	// actualTopics will always have length <= 4
-	// wantedTopics will always have length 4
-	matches := 0
-	for i, actualTopic := range actualTopics {
-		// If we have topics in this filter slot, count as a match if the actualTopic matches one of the ones in this filter slot
-		if len(wantedTopics[i]) > 0 {
-			matches += sliceContainsHash(wantedTopics[i], actualTopic)
-		} else {
-			// Filter slot is empty, not matching any topics at this slot => counts as a match
-			matches++
-		}
-	}
-	if matches == len(actualTopics) {
-		return true
-	}
-	return false
-}
-
-// returns 1 if the slice contains the hash, 0 if it does not
-func sliceContainsHash(slice []string, hash common.Hash) int {
-	for _, str := range slice {
-		if str == hash.String() {
-			return 1
-		}
-	}
-	return 0
-}
-
 // rpcMarshalHeader uses the generalized output filler, then adds the total difficulty field, which requires
 // a `PublicEthAPI`.
 func (pea *PublicEthAPI) rpcMarshalHeader(header *types.Header) (map[string]interface{}, error) {
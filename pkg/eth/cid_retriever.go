@@ -22,6 +22,7 @@ import (
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
@@ -219,66 +220,23 @@ func (ecr *CIDRetriever) RetrieveRctCIDsByHeaderID(tx *sqlx.Tx, rctFilter Receip
 			AND header_cids.id = $1`
 	id := 2
 	args = append(args, headerID)
-	if len(rctFilter.LogAddresses) > 0 {
-		// Filter on log contract addresses if there are any
-		pgStr += fmt.Sprintf(` AND ((receipt_cids.log_contracts && $%d::VARCHAR(66)[]`, id)
-		args = append(args, pq.Array(rctFilter.LogAddresses))
-		id++
-		// Filter on topics if there are any
-		if hasTopics(rctFilter.Topics) {
-			pgStr += " AND ("
-			first := true
-			for i, topicSet := range rctFilter.Topics {
-				if i < 4 && len(topicSet) > 0 {
-					if first {
-						pgStr += fmt.Sprintf(`receipt_cids.topic%ds && $%d::VARCHAR(66)[]`, i, id)
-						first = false
-					} else {
-						pgStr += fmt.Sprintf(` AND receipt_cids.topic%ds && $%d::VARCHAR(66)[]`, i, id)
-					}
-					args = append(args, pq.Array(topicSet))
-					id++
-				}
-			}
-			pgStr += ")"
-		}
-		pgStr += ")"
+	if len(rctFilter.LogAddresses) > 0 || hasTopics(rctFilter.Topics) {
+		// Filter on the receipts' logs if there are contract addresses or topics to filter on
+		logClause, logArgs, nextID := logMatchClause(rctFilter, id)
+		pgStr += " AND (" + logClause
+		args = append(args, logArgs...)
+		id = nextID
 		// Filter on txIDs if there are any and we are matching txs
 		if rctFilter.MatchTxs && len(trxIds) > 0 {
 			pgStr += fmt.Sprintf(` OR receipt_cids.tx_id = ANY($%d::INTEGER[])`, id)
 			args = append(args, pq.Array(trxIds))
 		}
 		pgStr += ")"
-	} else { // If there are no contract addresses to filter on
-		// Filter on topics if there are any
-		if hasTopics(rctFilter.Topics) {
-			pgStr += " AND (("
-			first := true
-			for i, topicSet := range rctFilter.Topics {
-				if i < 4 && len(topicSet) > 0 {
-					if first {
-						pgStr += fmt.Sprintf(`receipt_cids.topic%ds && $%d::VARCHAR(66)[]`, i, id)
-						first = false
-					} else {
-						pgStr += fmt.Sprintf(` AND receipt_cids.topic%ds && $%d::VARCHAR(66)[]`, i, id)
-					}
-					args = append(args, pq.Array(topicSet))
-					id++
-				}
-			}
-			pgStr += ")"
-			// Filter on txIDs if there are any and we are matching txs
-			if rctFilter.MatchTxs && len(trxIds) > 0 {
-				pgStr += fmt.Sprintf(` OR receipt_cids.tx_id = ANY($%d::INTEGER[])`, id)
-				args = append(args, pq.Array(trxIds))
-			}
-			pgStr += ")"
-		} else if rctFilter.MatchTxs && len(trxIds) > 0 {
-			// If there are no contract addresses or topics to filter on,
-			// Filter on txIDs if there are any and we are matching txs
-			pgStr += fmt.Sprintf(` AND receipt_cids.tx_id = ANY($%d::INTEGER[])`, id)
-			args = append(args, pq.Array(trxIds))
-		}
+	} else if rctFilter.MatchTxs && len(trxIds) > 0 {
+		// If there are no contract addresses or topics to filter on,
+		// Filter on txIDs if there are any and we are matching txs
+		pgStr += fmt.Sprintf(` AND receipt_cids.tx_id = ANY($%d::INTEGER[])`, id)
+		args = append(args, pq.Array(trxIds))
 	}
 	pgStr += ` ORDER BY transaction_cids.index`
 	receiptCids := make([]ReceiptModel, 0)
@@ -307,66 +265,23 @@ func (ecr *CIDRetriever) RetrieveRctCIDs(tx *sqlx.Tx, rctFilter ReceiptFilter, b
 		args = append(args, blockHash.String())
 		id++
 	}
-	if len(rctFilter.LogAddresses) > 0 {
-		// Filter on log contract addresses if there are any
-		pgStr += fmt.Sprintf(` AND ((receipt_cids.log_contracts && $%d::VARCHAR(66)[]`, id)
-		args = append(args, pq.Array(rctFilter.LogAddresses))
-		id++
-		// Filter on topics if there are any
-		if hasTopics(rctFilter.Topics) {
-			pgStr += " AND ("
-			first := true
-			for i, topicSet := range rctFilter.Topics {
-				if i < 4 && len(topicSet) > 0 {
-					if first {
-						pgStr += fmt.Sprintf(`receipt_cids.topic%ds && $%d::VARCHAR(66)[]`, i, id)
-						first = false
-					} else {
-						pgStr += fmt.Sprintf(` AND receipt_cids.topic%ds && $%d::VARCHAR(66)[]`, i, id)
-					}
-					args = append(args, pq.Array(topicSet))
-					id++
-				}
-			}
-			pgStr += ")"
-		}
-		pgStr += ")"
+	if len(rctFilter.LogAddresses) > 0 || hasTopics(rctFilter.Topics) {
+		// Filter on the receipts' logs if there are contract addresses or topics to filter on
+		logClause, logArgs, nextID := logMatchClause(rctFilter, id)
+		pgStr += " AND (" + logClause
+		args = append(args, logArgs...)
+		id = nextID
 		// Filter on txIDs if there are any and we are matching txs
 		if rctFilter.MatchTxs && len(trxIds) > 0 {
 			pgStr += fmt.Sprintf(` OR receipt_cids.tx_id = ANY($%d::INTEGER[])`, id)
 			args = append(args, pq.Array(trxIds))
 		}
 		pgStr += ")"
-	} else { // If there are no contract addresses to filter on
-		// Filter on topics if there are any
-		if hasTopics(rctFilter.Topics) {
-			pgStr += " AND (("
-			first := true
-			for i, topicSet := range rctFilter.Topics {
-				if i < 4 && len(topicSet) > 0 {
-					if first {
-						pgStr += fmt.Sprintf(`receipt_cids.topic%ds && $%d::VARCHAR(66)[]`, i, id)
-						first = false
-					} else {
-						pgStr += fmt.Sprintf(` AND receipt_cids.topic%ds && $%d::VARCHAR(66)[]`, i, id)
-					}
-					args = append(args, pq.Array(topicSet))
-					id++
-				}
-			}
-			pgStr += ")"
-			// Filter on txIDs if there are any and we are matching txs
-			if rctFilter.MatchTxs && len(trxIds) > 0 {
-				pgStr += fmt.Sprintf(` OR receipt_cids.tx_id = ANY($%d::INTEGER[])`, id)
-				args = append(args, pq.Array(trxIds))
-			}
-			pgStr += ")"
-		} else if rctFilter.MatchTxs && len(trxIds) > 0 {
-			// If there are no contract addresses or topics to filter on,
-			// Filter on txIDs if there are any and we are matching txs
-			pgStr += fmt.Sprintf(` AND receipt_cids.tx_id = ANY($%d::INTEGER[])`, id)
-			args = append(args, pq.Array(trxIds))
-		}
+	} else if rctFilter.MatchTxs && len(trxIds) > 0 {
+		// If there are no contract addresses or topics to filter on,
+		// Filter on txIDs if there are any and we are matching txs
+		pgStr += fmt.Sprintf(` AND receipt_cids.tx_id = ANY($%d::INTEGER[])`, id)
+		args = append(args, pq.Array(trxIds))
 	}
 	pgStr += ` ORDER BY transaction_cids.index`
 	receiptCids := make([]ReceiptModel, 0)
@@ -382,6 +297,122 @@ func hasTopics(topics [][]string) bool {
 	return false
 }
 
+// logMatchClause returns a SQL condition that is true if a receipt has a single log which matches
+// all of the filter's contract address and topic criteria, along with its args and the next arg id
+func logMatchClause(rctFilter ReceiptFilter, id int) (string, []interface{}, int) {
+	args := make([]interface{}, 0, 5)
+	clause := `EXISTS (SELECT 1 FROM eth.log_cids WHERE log_cids.receipt_id = receipt_cids.id`
+	if len(rctFilter.LogAddresses) > 0 {
+		clause += fmt.Sprintf(` AND log_cids.address = ANY($%d::VARCHAR(66)[])`, id)
+		args = append(args, pq.Array(rctFilter.LogAddresses))
+		id++
+	}
+	for i, topicSet := range rctFilter.Topics {
+		if i < 4 && len(topicSet) > 0 {
+			clause += fmt.Sprintf(` AND log_cids.topic%d = ANY($%d::VARCHAR(66)[])`, i, id)
+			args = append(args, pq.Array(topicSet))
+			id++
+		}
+	}
+	return clause + ")", args, id
+}
+
+// RetrieveFilteredLogCIDs retrieves a page of the log cids that conform to the provided filter parameters
+// across the block range [startingBlock, endingBlock], or at the provided block hash if there is one
+// Headers are pre-filtered using their log blooms before their logs are checked
+// Results are ordered by their position in the chain; to retrieve the next page pass in the last result of the previous page as after
+func (ecr *CIDRetriever) RetrieveFilteredLogCIDs(tx *sqlx.Tx, rctFilter ReceiptFilter, startingBlock, endingBlock int64, blockHash *common.Hash, after *LogResultModel, limit int) ([]LogResultModel, error) {
+	log.Debugf("retrieving filtered log cids for blocks %d to %d", startingBlock, endingBlock)
+	args := make([]interface{}, 0, 10)
+	pgStr := `SELECT log_cids.id, log_cids.receipt_id, log_cids.log_index, log_cids.cid, log_cids.mh_key,
+			log_cids.address, log_cids.topic0, log_cids.topic1, log_cids.topic2, log_cids.topic3,
+			header_cids.id AS header_id, header_cids.block_number, header_cids.block_hash,
+			transaction_cids.tx_hash, transaction_cids.index AS tx_index
+			FROM eth.header_cids
+			INNER JOIN eth.transaction_cids ON (transaction_cids.header_id = header_cids.id)
+			INNER JOIN eth.receipt_cids ON (receipt_cids.tx_id = transaction_cids.id)
+			INNER JOIN eth.log_cids ON (log_cids.receipt_id = receipt_cids.id)`
+	id := 1
+	if blockHash != nil {
+		pgStr += fmt.Sprintf(` WHERE header_cids.block_hash = $%d`, id)
+		args = append(args, blockHash.String())
+		id++
+	} else {
+		pgStr += fmt.Sprintf(` WHERE header_cids.block_number BETWEEN $%d AND $%d`, id, id+1)
+		args = append(args, startingBlock, endingBlock)
+		id += 2
+	}
+	// Pre-filter on the header blooms
+	if len(rctFilter.LogAddresses) > 0 {
+		values := make([][]byte, len(rctFilter.LogAddresses))
+		for i, addr := range rctFilter.LogAddresses {
+			values[i] = common.HexToAddress(addr).Bytes()
+		}
+		pgStr += ` AND ` + bloomMatchClause(values)
+	}
+	for i, topicSet := range rctFilter.Topics {
+		if i < 4 && len(topicSet) > 0 {
+			values := make([][]byte, len(topicSet))
+			for j, topic := range topicSet {
+				values[j] = common.HexToHash(topic).Bytes()
+			}
+			pgStr += ` AND ` + bloomMatchClause(values)
+		}
+	}
+	// Then filter on the logs' addresses and topics
+	if len(rctFilter.LogAddresses) > 0 {
+		pgStr += fmt.Sprintf(` AND log_cids.address = ANY($%d::VARCHAR(66)[])`, id)
+		args = append(args, pq.Array(rctFilter.LogAddresses))
+		id++
+	}
+	for i, topicSet := range rctFilter.Topics {
+		if i < 4 && len(topicSet) > 0 {
+			pgStr += fmt.Sprintf(` AND log_cids.topic%d = ANY($%d::VARCHAR(66)[])`, i, id)
+			args = append(args, pq.Array(topicSet))
+			id++
+		}
+	}
+	if after != nil {
+		pgStr += fmt.Sprintf(` AND (header_cids.block_number, header_cids.id, log_cids.log_index) > ($%d, $%d, $%d)`, id, id+1, id+2)
+		args = append(args, after.BlockNumber, after.HeaderID, after.LogIndex)
+		id += 3
+	}
+	pgStr += ` ORDER BY header_cids.block_number, header_cids.id, log_cids.log_index`
+	if limit > 0 {
+		pgStr += fmt.Sprintf(` LIMIT $%d`, id)
+		args = append(args, limit)
+	}
+	logCids := make([]LogResultModel, 0)
+	return logCids, tx.Select(&logCids, pgStr, args...)
+}
+
+// bloomMatchClause returns a SQL condition that is true if a header's log bloom could contain any of the provided values
+func bloomMatchClause(values [][]byte) string {
+	clause := "("
+	for i, value := range values {
+		if i > 0 {
+			clause += " OR "
+		}
+		bits := bloomBitPositions(value)
+		clause += fmt.Sprintf(`(get_bit(header_cids.bloom, %d) = 1 AND get_bit(header_cids.bloom, %d) = 1 AND get_bit(header_cids.bloom, %d) = 1)`,
+			bits[0], bits[1], bits[2])
+	}
+	return clause + ")"
+}
+
+// bloomBitPositions returns the positions of the three bits that are set in a log bloom for the provided value
+// The positions are numbered as Postgres' get_bit numbers them: by byte, and then from the least significant bit within that byte
+func bloomBitPositions(value []byte) [3]int {
+	var positions [3]int
+	hash := crypto.Keccak256(value)
+	for i := 0; i < 6; i += 2 {
+		// this is the bit the bloom sets, numbered from the least significant bit of the bloom as a big-endian integer
+		bit := (int(hash[i+1]) + (int(hash[i]) << 8)) & 2047
+		positions[i/2] = (types.BloomByteLength-1-bit/8)*8 + bit%8
+	}
+	return positions
+}
+
 // RetrieveStateCIDs retrieves and returns all of the state node cids at the provided header ID that conform to the provided filter parameters
 func (ecr *CIDRetriever) RetrieveStateCIDs(tx *sqlx.Tx, stateFilter StateFilter, headerID int64) ([]StateNodeModel, error) {
 	log.Debug("retrieving state cids for header id ", headerID)
@@ -487,7 +518,23 @@ func (ecr *CIDRetriever) RetrieveGapsInData(validationLevel int) ([]shared.Gap,
 	if err := ecr.db.Select(&heights, pgStr, validationLevel); err != nil && err != sql.ErrNoRows {
 		return nil, err
 	}
-	return append(append(initialGap, emptyGaps...), utils.MissingHeightsToGaps(heights)...), nil
+	gaps := append(append(initialGap, emptyGaps...), utils.MissingHeightsToGaps(heights)...)
+
+	// Find sections of blocks that were indexed without their state, e.g. imported from block export files
+	// These don't overlap with the gaps above either, since the ones below the validation level are already included
+	pgStr = `SELECT DISTINCT block_number FROM eth.header_cids
+			WHERE state_absent
+			AND times_validated >= $1
+			ORDER BY block_number`
+	var stateHeights []uint64
+	if err := ecr.db.Select(&stateHeights, pgStr, validationLevel); err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	for _, gap := range utils.MissingHeightsToGaps(stateHeights) {
+		gap.DataType = shared.State
+		gaps = append(gaps, gap)
+	}
+	return gaps, nil
 }
 
 // RetrieveBlockByHash returns all of the CIDs needed to compose an entire block, for a given block hash
@@ -685,6 +685,22 @@ var _ = Describe("Retriever", func() {
 			Expect(shared.ListContainsGap(gaps, shared.Gap{Start: 110, Stop: 999})).To(BeTrue())
 			Expect(shared.ListContainsGap(gaps, shared.Gap{Start: 1001, Stop: 1010100})).To(BeTrue())
 		})
+
+		It("Finds blocks that were indexed without their state", func() {
+			for i := uint64(0); i <= 5; i++ {
+				payload := mocks.MockConvertedPayload
+				payload.Block = newMockBlock(i)
+				payload.StateAbsent = i == 2 || i == 3 || i == 5
+				_, err := repo.Publish(payload)
+				Expect(err).ToNot(HaveOccurred())
+			}
+
+			gaps, err := retriever.RetrieveGapsInData(1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(gaps)).To(Equal(2))
+			Expect(shared.ListContainsGap(gaps, shared.Gap{Start: 2, Stop: 3, DataType: shared.State})).To(BeTrue())
+			Expect(shared.ListContainsGap(gaps, shared.Gap{Start: 5, Stop: 5, DataType: shared.State})).To(BeTrue())
+		})
 	})
 })
 
@@ -28,13 +28,16 @@ import (
 
 // Cleaner satisfies the shared.Cleaner interface fo ethereum
 type Cleaner struct {
-	db *postgres.DB
+	// Blockstores the IPLDs are deleted from, by type of data
+	Blockstores *shared.Blockstores
+	db          *postgres.DB
 }
 
 // NewCleaner returns a new Cleaner struct that satisfies the shared.Cleaner interface
 func NewCleaner(db *postgres.DB) *Cleaner {
 	return &Cleaner{
-		db: db,
+		Blockstores: shared.NewPostgresBlockstores(),
+		db:          db,
 	}
 }
 
@@ -87,6 +90,9 @@ func (c *Cleaner) clean(tx *sqlx.Tx, rng [2]uint64, t shared.DataType) error {
 		}
 		return c.cleanUncleMetaData(tx, rng)
 	case shared.Transactions:
+		if err := c.cleanLogIPLDs(tx, rng); err != nil {
+			return err
+		}
 		if err := c.cleanReceiptIPLDs(tx, rng); err != nil {
 			return err
 		}
@@ -95,6 +101,9 @@ func (c *Cleaner) clean(tx *sqlx.Tx, rng [2]uint64, t shared.DataType) error {
 		}
 		return c.cleanTransactionMetaData(tx, rng)
 	case shared.Receipts:
+		if err := c.cleanLogIPLDs(tx, rng); err != nil {
+			return err
+		}
 		if err := c.cleanReceiptIPLDs(tx, rng); err != nil {
 			return err
 		}
@@ -132,10 +141,16 @@ func (c *Cleaner) vacuumAnalyze(t shared.DataType) error {
 		if err := c.vacuumRcts(); err != nil {
 			return err
 		}
+		if err := c.vacuumLogs(); err != nil {
+			return err
+		}
 	case shared.Receipts:
 		if err := c.vacuumRcts(); err != nil {
 			return err
 		}
+		if err := c.vacuumLogs(); err != nil {
+			return err
+		}
 	case shared.State:
 		if err := c.vacuumState(); err != nil {
 			return err
@@ -169,6 +184,9 @@ func (c *Cleaner) vacuumFull() error {
 	if err := c.vacuumRcts(); err != nil {
 		return err
 	}
+	if err := c.vacuumLogs(); err != nil {
+		return err
+	}
 	if err := c.vacuumState(); err != nil {
 		return err
 	}
@@ -198,6 +216,11 @@ func (c *Cleaner) vacuumRcts() error {
 	return err
 }
 
+func (c *Cleaner) vacuumLogs() error {
+	_, err := c.db.Exec(`VACUUM ANALYZE eth.log_cids`)
+	return err
+}
+
 func (c *Cleaner) vacuumState() error {
 	_, err := c.db.Exec(`VACUUM ANALYZE eth.state_cids`)
 	return err
@@ -225,6 +248,9 @@ func (c *Cleaner) cleanFull(tx *sqlx.Tx, rng [2]uint64) error {
 	if err := c.cleanStateIPLDs(tx, rng); err != nil {
 		return err
 	}
+	if err := c.cleanLogIPLDs(tx, rng); err != nil {
+		return err
+	}
 	if err := c.cleanReceiptIPLDs(tx, rng); err != nil {
 		return err
 	}
@@ -241,14 +267,11 @@ func (c *Cleaner) cleanFull(tx *sqlx.Tx, rng [2]uint64) error {
 }
 
 func (c *Cleaner) cleanStorageIPLDs(tx *sqlx.Tx, rng [2]uint64) error {
-	pgStr := `DELETE FROM public.blocks A
-			USING eth.storage_cids B, eth.state_cids C, eth.header_cids D
-			WHERE A.key = B.mh_key
-			AND B.state_id = C.id
+	pgStr := `SELECT B.mh_key FROM eth.storage_cids B, eth.state_cids C, eth.header_cids D
+			WHERE B.state_id = C.id
 			AND C.header_id = D.id
 			AND D.block_number BETWEEN $1 AND $2`
-	_, err := tx.Exec(pgStr, rng[0], rng[1])
-	return err
+	return c.Blockstores.DeleteSelected(tx, shared.Storage, pgStr, rng[0], rng[1])
 }
 
 func (c *Cleaner) cleanStorageMetaData(tx *sqlx.Tx, rng [2]uint64) error {
@@ -262,13 +285,10 @@ func (c *Cleaner) cleanStorageMetaData(tx *sqlx.Tx, rng [2]uint64) error {
 }
 
 func (c *Cleaner) cleanStateIPLDs(tx *sqlx.Tx, rng [2]uint64) error {
-	pgStr := `DELETE FROM public.blocks A
-			USING eth.state_cids B, eth.header_cids C
-			WHERE A.key = B.mh_key
-			AND B.header_id = C.id
+	pgStr := `SELECT B.mh_key FROM eth.state_cids B, eth.header_cids C
+			WHERE B.header_id = C.id
 			AND C.block_number BETWEEN $1 AND $2`
-	_, err := tx.Exec(pgStr, rng[0], rng[1])
-	return err
+	return c.Blockstores.DeleteSelected(tx, shared.State, pgStr, rng[0], rng[1])
 }
 
 func (c *Cleaner) cleanStateMetaData(tx *sqlx.Tx, rng [2]uint64) error {
@@ -280,15 +300,21 @@ func (c *Cleaner) cleanStateMetaData(tx *sqlx.Tx, rng [2]uint64) error {
 	return err
 }
 
+func (c *Cleaner) cleanLogIPLDs(tx *sqlx.Tx, rng [2]uint64) error {
+	pgStr := `SELECT B.mh_key FROM eth.log_cids B, eth.receipt_cids C, eth.transaction_cids D, eth.header_cids E
+			WHERE B.receipt_id = C.id
+			AND C.tx_id = D.id
+			AND D.header_id = E.id
+			AND E.block_number BETWEEN $1 AND $2`
+	return c.Blockstores.DeleteSelected(tx, shared.Receipts, pgStr, rng[0], rng[1])
+}
+
 func (c *Cleaner) cleanReceiptIPLDs(tx *sqlx.Tx, rng [2]uint64) error {
-	pgStr := `DELETE FROM public.blocks A
-			USING eth.receipt_cids B, eth.transaction_cids C, eth.header_cids D
-			WHERE A.key = B.mh_key
-			AND B.tx_id = C.id
+	pgStr := `SELECT B.mh_key FROM eth.receipt_cids B, eth.transaction_cids C, eth.header_cids D
+			WHERE B.tx_id = C.id
 			AND C.header_id = D.id
 			AND D.block_number BETWEEN $1 AND $2`
-	_, err := tx.Exec(pgStr, rng[0], rng[1])
-	return err
+	return c.Blockstores.DeleteSelected(tx, shared.Receipts, pgStr, rng[0], rng[1])
 }
 
 func (c *Cleaner) cleanReceiptMetaData(tx *sqlx.Tx, rng [2]uint64) error {
@@ -302,13 +328,10 @@ func (c *Cleaner) cleanReceiptMetaData(tx *sqlx.Tx, rng [2]uint64) error {
 }
 
 func (c *Cleaner) cleanTransactionIPLDs(tx *sqlx.Tx, rng [2]uint64) error {
-	pgStr := `DELETE FROM public.blocks A
-			USING eth.transaction_cids B, eth.header_cids C
-			WHERE A.key = B.mh_key
-			AND B.header_id = C.id
+	pgStr := `SELECT B.mh_key FROM eth.transaction_cids B, eth.header_cids C
+			WHERE B.header_id = C.id
 			AND C.block_number BETWEEN $1 AND $2`
-	_, err := tx.Exec(pgStr, rng[0], rng[1])
-	return err
+	return c.Blockstores.DeleteSelected(tx, shared.Transactions, pgStr, rng[0], rng[1])
 }
 
 func (c *Cleaner) cleanTransactionMetaData(tx *sqlx.Tx, rng [2]uint64) error {
@@ -321,13 +344,10 @@ func (c *Cleaner) cleanTransactionMetaData(tx *sqlx.Tx, rng [2]uint64) error {
 }
 
 func (c *Cleaner) cleanUncleIPLDs(tx *sqlx.Tx, rng [2]uint64) error {
-	pgStr := `DELETE FROM public.blocks A
-			USING eth.uncle_cids B, eth.header_cids C
-			WHERE A.key = B.mh_key
-			AND B.header_id = C.id
+	pgStr := `SELECT B.mh_key FROM eth.uncle_cids B, eth.header_cids C
+			WHERE B.header_id = C.id
 			AND C.block_number BETWEEN $1 AND $2`
-	_, err := tx.Exec(pgStr, rng[0], rng[1])
-	return err
+	return c.Blockstores.DeleteSelected(tx, shared.Uncles, pgStr, rng[0], rng[1])
 }
 
 func (c *Cleaner) cleanUncleMetaData(tx *sqlx.Tx, rng [2]uint64) error {
@@ -340,12 +360,9 @@ func (c *Cleaner) cleanUncleMetaData(tx *sqlx.Tx, rng [2]uint64) error {
 }
 
 func (c *Cleaner) cleanHeaderIPLDs(tx *sqlx.Tx, rng [2]uint64) error {
-	pgStr := `DELETE FROM public.blocks A
-			USING eth.header_cids B
-			WHERE A.key = B.mh_key
-			AND B.block_number BETWEEN $1 AND $2`
-	_, err := tx.Exec(pgStr, rng[0], rng[1])
-	return err
+	pgStr := `SELECT B.mh_key FROM eth.header_cids B
+			WHERE B.block_number BETWEEN $1 AND $2`
+	return c.Blockstores.DeleteSelected(tx, shared.Headers, pgStr, rng[0], rng[1])
 }
 
 func (c *Cleaner) cleanHeaderMetaData(tx *sqlx.Tx, rng [2]uint64) error {
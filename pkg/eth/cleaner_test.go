@@ -17,13 +17,16 @@
 package eth_test
 
 import (
+	"io/ioutil"
 	"math/big"
+	"os"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/blockstore"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
@@ -635,6 +638,36 @@ var _ = Describe("Cleaner", func() {
 			Expect(storageCount).To(Equal(0))
 			Expect(blocksCount).To(Equal(12))
 		})
+
+		It("Cleans the IPLDs out of the blockstores the types of data are kept in", func() {
+			dir, err := ioutil.TempDir("", "cleaner")
+			Expect(err).ToNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+			fs, err := blockstore.NewFilesystemBlockstore(dir)
+			Expect(err).ToNot(HaveOccurred())
+			for _, key := range mhKeys {
+				Expect(fs.Put(nil, key, mockData)).To(Succeed())
+			}
+			cleaner.Blockstores.Types[shared.State] = fs
+			cleaner.Blockstores.Types[shared.Storage] = fs
+			err = cleaner.Clean(rngs, shared.State)
+			Expect(err).ToNot(HaveOccurred())
+
+			for _, key := range mhKeys {
+				has, err := fs.Has(nil, key)
+				Expect(err).ToNot(HaveOccurred())
+				switch key {
+				case state1MhKey1, state2MhKey1, state1MhKey2, storageMhKey:
+					Expect(has).To(BeFalse())
+				default:
+					Expect(has).To(BeTrue())
+				}
+			}
+			var blocksCount int
+			err = db.Get(&blocksCount, `SELECT COUNT(*) FROM public.blocks`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blocksCount).To(Equal(13))
+		})
 	})
 
 	Describe("ResetValidation", func() {
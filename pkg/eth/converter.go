@@ -18,6 +18,7 @@ package eth
 
 import (
 	"fmt"
+	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -41,6 +42,22 @@ func NewPayloadConverter(chainConfig *params.ChainConfig) *PayloadConverter {
 	}
 }
 
+// ChainConfigFromNetworkID returns the chain config of the public network with the network id, mainnet's if it is empty
+func ChainConfigFromNetworkID(networkID string) (*params.ChainConfig, error) {
+	switch networkID {
+	case "", "1":
+		return params.MainnetChainConfig, nil
+	case "3":
+		return params.TestnetChainConfig, nil
+	case "4":
+		return params.RinkebyChainConfig, nil
+	case "5":
+		return params.GoerliChainConfig, nil
+	default:
+		return nil, fmt.Errorf("no chain config is known for the ethereum network id %s", networkID)
+	}
+}
+
 // Convert method is used to convert a eth statediff.Payload to an IPLDPayload
 // Satisfies the shared.PayloadConverter interface
 func (pc *PayloadConverter) Convert(payload shared.RawChainData) (shared.ConvertedData, error) {
@@ -53,9 +70,57 @@ func (pc *PayloadConverter) Convert(payload shared.RawChainData) (shared.Convert
 	if err := rlp.DecodeBytes(stateDiffPayload.BlockRlp, block); err != nil {
 		return nil, err
 	}
+	// Decode receipts for this block
+	receipts := make(types.Receipts, 0)
+	if err := rlp.DecodeBytes(stateDiffPayload.ReceiptsRlp, &receipts); err != nil {
+		return nil, err
+	}
+	convertedPayload, err := pc.convertBlock(block, stateDiffPayload.TotalDifficulty, receipts)
+	if err != nil {
+		return nil, err
+	}
+
+	// Unpack state diff rlp to access fields
+	stateDiff := new(statediff.StateObject)
+	if err := rlp.DecodeBytes(stateDiffPayload.StateObjectRlp, stateDiff); err != nil {
+		return nil, err
+	}
+	for _, stateNode := range stateDiff.Nodes {
+		statePath := common.Bytes2Hex(stateNode.Path)
+		convertedPayload.StateNodes = append(convertedPayload.StateNodes, TrieNode{
+			Path:    stateNode.Path,
+			Value:   stateNode.NodeValue,
+			Type:    stateNode.NodeType,
+			LeafKey: common.BytesToHash(stateNode.LeafKey),
+		})
+		for _, storageNode := range stateNode.StorageNodes {
+			convertedPayload.StorageNodes[statePath] = append(convertedPayload.StorageNodes[statePath], TrieNode{
+				Path:    storageNode.Path,
+				Value:   storageNode.NodeValue,
+				Type:    storageNode.NodeType,
+				LeafKey: common.BytesToHash(storageNode.LeafKey),
+			})
+		}
+	}
+
+	return convertedPayload, nil
+}
+
+// ConvertBlock converts a block and its receipts, e.g. read from block export files rather than streamed with a state
+// diff, into an IPLDPayload that is marked as missing its state
+func (pc *PayloadConverter) ConvertBlock(block *types.Block, td *big.Int, receipts types.Receipts) (ConvertedPayload, error) {
+	convertedPayload, err := pc.convertBlock(block, td, receipts)
+	if err != nil {
+		return ConvertedPayload{}, err
+	}
+	convertedPayload.StateAbsent = true
+	return convertedPayload, nil
+}
+
+func (pc *PayloadConverter) convertBlock(block *types.Block, td *big.Int, receipts types.Receipts) (ConvertedPayload, error) {
 	trxLen := len(block.Transactions())
 	convertedPayload := ConvertedPayload{
-		TotalDifficulty: stateDiffPayload.TotalDifficulty,
+		TotalDifficulty: td,
 		Block:           block,
 		TxMetaData:      make([]TxModel, 0, trxLen),
 		Receipts:        make(types.Receipts, 0, trxLen),
@@ -69,7 +134,7 @@ func (pc *PayloadConverter) Convert(payload shared.RawChainData) (shared.Convert
 		// Extract to and from data from the the transactions for indexing
 		from, err := types.Sender(signer, trx)
 		if err != nil {
-			return nil, err
+			return ConvertedPayload{}, err
 		}
 		txMeta := TxModel{
 			Dst:    shared.HandleZeroAddrPointer(trx.To()),
@@ -81,14 +146,9 @@ func (pc *PayloadConverter) Convert(payload shared.RawChainData) (shared.Convert
 		convertedPayload.TxMetaData = append(convertedPayload.TxMetaData, txMeta)
 	}
 
-	// Decode receipts for this block
-	receipts := make(types.Receipts, 0)
-	if err := rlp.DecodeBytes(stateDiffPayload.ReceiptsRlp, &receipts); err != nil {
-		return nil, err
-	}
 	// Derive any missing fields
 	if err := receipts.DeriveFields(pc.chainConfig, block.Hash(), block.NumberU64(), block.Transactions()); err != nil {
-		return nil, err
+		return ConvertedPayload{}, err
 	}
 	for _, receipt := range receipts {
 		// Extract topic and contract data from the receipt for indexing
@@ -124,29 +184,5 @@ func (pc *PayloadConverter) Convert(payload shared.RawChainData) (shared.Convert
 		convertedPayload.Receipts = append(convertedPayload.Receipts, receipt)
 		convertedPayload.ReceiptMetaData = append(convertedPayload.ReceiptMetaData, rctMeta)
 	}
-
-	// Unpack state diff rlp to access fields
-	stateDiff := new(statediff.StateObject)
-	if err := rlp.DecodeBytes(stateDiffPayload.StateObjectRlp, stateDiff); err != nil {
-		return nil, err
-	}
-	for _, stateNode := range stateDiff.Nodes {
-		statePath := common.Bytes2Hex(stateNode.Path)
-		convertedPayload.StateNodes = append(convertedPayload.StateNodes, TrieNode{
-			Path:    stateNode.Path,
-			Value:   stateNode.NodeValue,
-			Type:    stateNode.NodeType,
-			LeafKey: common.BytesToHash(stateNode.LeafKey),
-		})
-		for _, storageNode := range stateNode.StorageNodes {
-			convertedPayload.StorageNodes[statePath] = append(convertedPayload.StorageNodes[statePath], TrieNode{
-				Path:    storageNode.Path,
-				Value:   storageNode.NodeValue,
-				Type:    storageNode.NodeType,
-				LeafKey: common.BytesToHash(storageNode.LeafKey),
-			})
-		}
-	}
-
 	return convertedPayload, nil
 }
@@ -17,6 +17,7 @@
 package eth_test
 
 import (
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 	. "github.com/onsi/ginkgo"
@@ -49,6 +50,40 @@ var _ = Describe("Converter", func() {
 			Expect(gotHeader).To(Equal(mocks.MockHeaderRlp))
 			Expect(convertedPayload.TxMetaData).To(Equal(mocks.MockTrxMeta))
 			Expect(convertedPayload.ReceiptMetaData).To(Equal(mocks.MockRctMeta))
+			Expect(convertedPayload.StateAbsent).To(BeFalse())
+		})
+	})
+
+	Describe("ConvertBlock", func() {
+		It("Converts a block and its receipts into an IPLDPayload with its state absent", func() {
+			// decode a copy of the receipts, converting them fills in their derived fields
+			var receipts types.Receipts
+			Expect(rlp.DecodeBytes(mocks.MockStateDiffPayload.ReceiptsRlp, &receipts)).To(Succeed())
+			converter := eth.NewPayloadConverter(params.MainnetChainConfig)
+			convertedPayload, err := converter.ConvertBlock(mocks.MockBlock, mocks.MockStateDiffPayload.TotalDifficulty, receipts)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(convertedPayload.Block.Hash()).To(Equal(mocks.MockBlock.Hash()))
+			Expect(convertedPayload.TotalDifficulty).To(Equal(mocks.MockStateDiffPayload.TotalDifficulty))
+			Expect(convertedPayload.TxMetaData).To(Equal(mocks.MockTrxMeta))
+			Expect(convertedPayload.ReceiptMetaData).To(Equal(mocks.MockRctMeta))
+			Expect(convertedPayload.StateNodes).To(BeEmpty())
+			Expect(convertedPayload.StorageNodes).To(BeEmpty())
+			Expect(convertedPayload.StateAbsent).To(BeTrue())
+		})
+	})
+	Describe("ChainConfigFromNetworkID", func() {
+		It("Returns the chain config of the public network, mainnet's by default", func() {
+			for networkID, expected := range map[string]*params.ChainConfig{
+				"":  params.MainnetChainConfig,
+				"1": params.MainnetChainConfig,
+				"5": params.GoerliChainConfig,
+			} {
+				chainConfig, err := eth.ChainConfigFromNetworkID(networkID)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(chainConfig).To(Equal(expected))
+			}
+			_, err := eth.ChainConfigFromNetworkID("1337")
+			Expect(err).To(HaveOccurred())
 		})
 	})
 })
@@ -0,0 +1,235 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// MaxReorgDepth is the number of blocks behind the head we keep around to detect and unwind reorgs
+const MaxReorgDepth = 64
+
+// servedBlock holds what we need to know about a block we have already sent to subscribers
+type servedBlock struct {
+	header *types.Header
+	logs   []*types.Log
+}
+
+// logSubscription holds the criteria and channel for a logs subscription
+type logSubscription struct {
+	crit FilterCriteria
+	logs chan<- []*types.Log
+}
+
+// EventSystem receives the live converted payloads from the watcher and feeds them out to
+// the standard eth_subscribe newHeads and logs subscriptions
+// It keeps a short window of recently served blocks so that it can emit removed logs on reorgs
+type EventSystem struct {
+	sync.Mutex
+	headerSubs map[rpc.ID]chan<- *types.Header
+	logSubs    map[rpc.ID]logSubscription
+	// canonical block hash at each height within the reorg window
+	canonical map[int64]common.Hash
+	// all blocks seen within the reorg window, including those that have since been orphaned
+	blocks map[common.Hash]*servedBlock
+	head   *types.Header
+}
+
+// NewEventSystem creates a new EventSystem and starts it listening on the provided payload channel
+func NewEventSystem(payloadChan <-chan shared.ConvertedData, quitChan <-chan bool) *EventSystem {
+	es := &EventSystem{
+		headerSubs: make(map[rpc.ID]chan<- *types.Header),
+		logSubs:    make(map[rpc.ID]logSubscription),
+		canonical:  make(map[int64]common.Hash),
+		blocks:     make(map[common.Hash]*servedBlock),
+	}
+	go es.serve(payloadChan, quitChan)
+	return es
+}
+
+func (es *EventSystem) serve(payloadChan <-chan shared.ConvertedData, quitChan <-chan bool) {
+	for {
+		select {
+		case payload := <-payloadChan:
+			ethPayload, ok := payload.(ConvertedPayload)
+			if !ok {
+				log.Errorf("eth event system: expected payload type %T got %T", ConvertedPayload{}, payload)
+				continue
+			}
+			es.process(ethPayload)
+		case <-quitChan:
+			log.Info("quiting eth event system")
+			return
+		}
+	}
+}
+
+// SubscribeNewHeads registers a channel to receive new canonical headers
+func (es *EventSystem) SubscribeNewHeads(id rpc.ID, headers chan<- *types.Header) {
+	es.Lock()
+	defer es.Unlock()
+	es.headerSubs[id] = headers
+}
+
+// SubscribeLogs registers a channel to receive logs matching the provided criteria as they arrive
+// The block hash criteria is not used
+func (es *EventSystem) SubscribeLogs(id rpc.ID, crit FilterCriteria, logs chan<- []*types.Log) {
+	es.Lock()
+	defer es.Unlock()
+	es.logSubs[id] = logSubscription{
+		crit: crit,
+		logs: logs,
+	}
+}
+
+// Unsubscribe removes the subscription with the provided id
+func (es *EventSystem) Unsubscribe(id rpc.ID) {
+	es.Lock()
+	defer es.Unlock()
+	delete(es.headerSubs, id)
+	delete(es.logSubs, id)
+}
+
+// process works out where the payload's block sits relative to the current head,
+// unwinds any blocks it orphans, and sends the resulting events to subscribers
+func (es *EventSystem) process(payload ConvertedPayload) {
+	es.Lock()
+	defer es.Unlock()
+	header := payload.Block.Header()
+	hash := header.Hash()
+	number := header.Number.Int64()
+	if _, seen := es.blocks[hash]; seen {
+		return
+	}
+	block := &servedBlock{
+		header: header,
+		logs:   make([]*types.Log, 0),
+	}
+	for _, rct := range payload.Receipts {
+		block.logs = append(block.logs, rct.Logs...)
+	}
+
+	if es.head != nil && header.ParentHash != es.head.Hash() {
+		headNumber := es.head.Number.Int64()
+		if number <= headNumber-MaxReorgDepth {
+			log.Debugf("eth event system ignoring block %d outside of the reorg window", number)
+			return
+		}
+		parent, knownParent := es.blocks[header.ParentHash]
+		if number <= headNumber && !knownParent {
+			// this is backfilled or otherwise out of order data, not a new head
+			log.Debugf("eth event system ignoring out of order block %d", number)
+			return
+		}
+		es.blocks[hash] = block
+		// walk back from the new block until we reach the canonical chain
+		newChain := []*servedBlock{block}
+		ancestor := number - 1
+		for knownParent {
+			parentNumber := parent.header.Number.Int64()
+			if es.canonical[parentNumber] == parent.header.Hash() {
+				ancestor = parentNumber
+				break
+			}
+			newChain = append([]*servedBlock{parent}, newChain...)
+			ancestor = parentNumber - 1
+			parent, knownParent = es.blocks[parent.header.ParentHash]
+		}
+		// everything above the common ancestor on the old chain has been orphaned
+		for i := headNumber; i > ancestor; i-- {
+			oldHash, ok := es.canonical[i]
+			if !ok {
+				continue
+			}
+			delete(es.canonical, i)
+			if old, ok := es.blocks[oldHash]; ok {
+				log.Infof("eth event system detected reorg, block %d (%s) removed from canonical chain", i, oldHash.Hex())
+				es.sendLogs(old.logs, true)
+			}
+		}
+		for _, b := range newChain {
+			es.extend(b)
+		}
+	} else {
+		es.blocks[hash] = block
+		es.extend(block)
+	}
+	es.prune()
+}
+
+// extend makes the provided block the new head and sends it out to subscribers
+func (es *EventSystem) extend(block *servedBlock) {
+	es.head = block.header
+	es.canonical[block.header.Number.Int64()] = block.header.Hash()
+	for id, sub := range es.headerSubs {
+		select {
+		case sub <- block.header:
+		default:
+			log.Infof("unable to send header to eth subscription %s; channel has no receiver", id)
+		}
+	}
+	es.sendLogs(block.logs, false)
+}
+
+// sendLogs filters the provided logs for each logs subscription and sends out any matches
+func (es *EventSystem) sendLogs(logs []*types.Log, removed bool) {
+	if len(logs) == 0 {
+		return
+	}
+	if removed {
+		removedLogs := make([]*types.Log, len(logs))
+		for i, l := range logs {
+			cpy := *l
+			cpy.Removed = true
+			removedLogs[i] = &cpy
+		}
+		logs = removedLogs
+	}
+	for id, sub := range es.logSubs {
+		matches := filterLogs(logs, sub.crit.FromBlock, sub.crit.ToBlock, sub.crit.Addresses, sub.crit.Topics)
+		if len(matches) == 0 {
+			continue
+		}
+		select {
+		case sub.logs <- matches:
+		default:
+			log.Infof("unable to send logs to eth subscription %s; channel has no receiver", id)
+		}
+	}
+}
+
+// prune drops blocks that have fallen out of the reorg window
+func (es *EventSystem) prune() {
+	cutoff := es.head.Number.Int64() - MaxReorgDepth
+	for hash, b := range es.blocks {
+		if b.header.Number.Int64() <= cutoff {
+			delete(es.blocks, hash)
+		}
+	}
+	for number := range es.canonical {
+		if number <= cutoff {
+			delete(es.canonical, number)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth_test
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+var (
+	eventAddress        = common.HexToAddress("0xaE9BEa628c4Ce503DcFD7E305CaB4e29E7476592")
+	eventAnotherAddress = common.HexToAddress("0xaE9BEa628c4Ce503DcFD7E305CaB4e29E7476593")
+	eventTopic          = common.HexToHash("0x04")
+)
+
+func eventPayload(number int64, parent common.Hash, extra []byte, logs ...*types.Log) eth.ConvertedPayload {
+	header := &types.Header{
+		Number:     big.NewInt(number),
+		ParentHash: parent,
+		Extra:      extra,
+		Difficulty: big.NewInt(1),
+	}
+	return eth.ConvertedPayload{
+		Block:    types.NewBlockWithHeader(header),
+		Receipts: types.Receipts{&types.Receipt{Logs: logs}},
+	}
+}
+
+var _ = Describe("EventSystem", func() {
+	var (
+		payloadChan chan shared.ConvertedData
+		quitChan    chan bool
+		events      *eth.EventSystem
+	)
+	BeforeEach(func() {
+		payloadChan = make(chan shared.ConvertedData, 10)
+		quitChan = make(chan bool)
+		events = eth.NewEventSystem(payloadChan, quitChan)
+	})
+	AfterEach(func() {
+		close(quitChan)
+	})
+
+	It("Sends new headers to newHeads subscriptions", func() {
+		headers := make(chan *types.Header, 10)
+		events.SubscribeNewHeads(rpc.ID("heads"), headers)
+		block1 := eventPayload(1, common.Hash{}, nil)
+		block2 := eventPayload(2, block1.Block.Hash(), nil)
+		payloadChan <- block1
+		payloadChan <- block2
+		var header *types.Header
+		Eventually(headers).Should(Receive(&header))
+		Expect(header.Hash()).To(Equal(block1.Block.Hash()))
+		Eventually(headers).Should(Receive(&header))
+		Expect(header.Hash()).To(Equal(block2.Block.Hash()))
+	})
+
+	It("Only sends logs matching the subscription criteria", func() {
+		logs := make(chan []*types.Log, 10)
+		events.SubscribeLogs(rpc.ID("logs"), eth.FilterCriteria{
+			Addresses: []common.Address{eventAddress},
+			Topics:    [][]common.Hash{{eventTopic}},
+		}, logs)
+		wanted := &types.Log{Address: eventAddress, Topics: []common.Hash{eventTopic}}
+		wrongAddress := &types.Log{Address: eventAnotherAddress, Topics: []common.Hash{eventTopic}}
+		wrongTopic := &types.Log{Address: eventAddress, Topics: []common.Hash{common.HexToHash("0x05")}}
+		payloadChan <- eventPayload(1, common.Hash{}, nil, wanted, wrongAddress, wrongTopic)
+		var received []*types.Log
+		Eventually(logs).Should(Receive(&received))
+		Expect(len(received)).To(Equal(1))
+		Expect(received[0]).To(Equal(wanted))
+		Consistently(logs, 100*time.Millisecond).ShouldNot(Receive())
+	})
+
+	It("Sends removed logs when a reorg orphans a block", func() {
+		headers := make(chan *types.Header, 10)
+		logs := make(chan []*types.Log, 10)
+		events.SubscribeNewHeads(rpc.ID("heads"), headers)
+		events.SubscribeLogs(rpc.ID("logs"), eth.FilterCriteria{}, logs)
+		orphanedLog := &types.Log{Address: eventAddress, Topics: []common.Hash{eventTopic}}
+		newLog := &types.Log{Address: eventAnotherAddress, Topics: []common.Hash{eventTopic}}
+		block1 := eventPayload(1, common.Hash{}, nil)
+		block2 := eventPayload(2, block1.Block.Hash(), nil, orphanedLog)
+		block2Prime := eventPayload(2, block1.Block.Hash(), []byte{1}, newLog)
+		payloadChan <- block1
+		payloadChan <- block2
+		payloadChan <- block2Prime
+
+		var received []*types.Log
+		Eventually(logs).Should(Receive(&received))
+		Expect(received[0].Removed).To(BeFalse())
+		Expect(received[0].Address).To(Equal(eventAddress))
+		Eventually(logs).Should(Receive(&received))
+		Expect(received[0].Removed).To(BeTrue())
+		Expect(received[0].Address).To(Equal(eventAddress))
+		Eventually(logs).Should(Receive(&received))
+		Expect(received[0].Removed).To(BeFalse())
+		Expect(received[0].Address).To(Equal(eventAnotherAddress))
+		// the original log is not mutated
+		Expect(orphanedLog.Removed).To(BeFalse())
+
+		var header *types.Header
+		Eventually(headers).Should(Receive(&header))
+		Eventually(headers).Should(Receive(&header))
+		Eventually(headers).Should(Receive(&header))
+		Expect(header.Hash()).To(Equal(block2Prime.Block.Hash()))
+	})
+
+	It("Unsubscribes", func() {
+		headers := make(chan *types.Header, 10)
+		events.SubscribeNewHeads(rpc.ID("heads"), headers)
+		events.Unsubscribe(rpc.ID("heads"))
+		payloadChan <- eventPayload(1, common.Hash{}, nil)
+		Consistently(headers, 100*time.Millisecond).ShouldNot(Receive())
+	})
+})
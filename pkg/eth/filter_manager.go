@@ -0,0 +1,222 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	log "github.com/sirupsen/logrus"
+)
+
+// FilterTimeout is how long a filter can go without being polled before it is uninstalled
+const FilterTimeout = 5 * time.Minute
+
+var errFilterNotFound = errors.New("filter not found")
+
+type filterType int
+
+const (
+	logsFilter filterType = iota
+	blocksFilter
+)
+
+// filter is a polling filter installed through eth_newFilter or eth_newBlockFilter
+// it accumulates the hashes or logs received from the EventSystem until they are polled
+type filter struct {
+	typ      filterType
+	deadline *time.Timer
+	crit     FilterCriteria
+	hashes   []common.Hash
+	logs     []*types.Log
+	done     chan struct{}
+}
+
+// FilterManager holds the polling filters for the eth api
+type FilterManager struct {
+	sync.Mutex
+	events  *EventSystem
+	filters map[rpc.ID]*filter
+	timeout time.Duration
+}
+
+// NewFilterManager creates a new FilterManager which feeds its filters from the provided EventSystem
+// Filters that are not polled within the timeout are uninstalled
+func NewFilterManager(events *EventSystem, timeout time.Duration, quitChan <-chan bool) *FilterManager {
+	fm := &FilterManager{
+		events:  events,
+		filters: make(map[rpc.ID]*filter),
+		timeout: timeout,
+	}
+	go fm.timeoutLoop(quitChan)
+	return fm
+}
+
+// timeoutLoop periodically uninstalls filters that have not been polled within the timeout
+func (fm *FilterManager) timeoutLoop(quitChan <-chan bool) {
+	ticker := time.NewTicker(fm.timeout)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			fm.Lock()
+			expired := make([]rpc.ID, 0)
+			for id, f := range fm.filters {
+				select {
+				case <-f.deadline.C:
+					expired = append(expired, id)
+				default:
+				}
+			}
+			fm.Unlock()
+			for _, id := range expired {
+				log.Debugf("eth filter %s expired", id)
+				fm.Uninstall(id)
+			}
+		case <-quitChan:
+			log.Info("quiting eth filter manager")
+			return
+		}
+	}
+}
+
+// NewLogFilter installs a filter which accumulates new logs matching the provided criteria
+func (fm *FilterManager) NewLogFilter(crit FilterCriteria) rpc.ID {
+	id := rpc.NewID()
+	f := &filter{
+		typ:      logsFilter,
+		deadline: time.NewTimer(fm.timeout),
+		crit:     crit,
+		logs:     make([]*types.Log, 0),
+		done:     make(chan struct{}),
+	}
+	fm.Lock()
+	fm.filters[id] = f
+	fm.Unlock()
+
+	logs := make(chan []*types.Log, SubscriptionChanBufferSize)
+	fm.events.SubscribeLogs(id, crit, logs)
+	go func() {
+		for {
+			select {
+			case l := <-logs:
+				fm.Lock()
+				f.logs = append(f.logs, l...)
+				fm.Unlock()
+			case <-f.done:
+				return
+			}
+		}
+	}()
+	return id
+}
+
+// NewBlockFilter installs a filter which accumulates the hashes of new blocks
+func (fm *FilterManager) NewBlockFilter() rpc.ID {
+	id := rpc.NewID()
+	f := &filter{
+		typ:      blocksFilter,
+		deadline: time.NewTimer(fm.timeout),
+		hashes:   make([]common.Hash, 0),
+		done:     make(chan struct{}),
+	}
+	fm.Lock()
+	fm.filters[id] = f
+	fm.Unlock()
+
+	headers := make(chan *types.Header, SubscriptionChanBufferSize)
+	fm.events.SubscribeNewHeads(id, headers)
+	go func() {
+		for {
+			select {
+			case h := <-headers:
+				fm.Lock()
+				f.hashes = append(f.hashes, h.Hash())
+				fm.Unlock()
+			case <-f.done:
+				return
+			}
+		}
+	}()
+	return id
+}
+
+// Changes returns the hashes or logs accumulated by the filter since it was last polled
+// For block filters a []common.Hash is returned, for log filters a []*types.Log
+func (fm *FilterManager) Changes(id rpc.ID) (interface{}, error) {
+	fm.Lock()
+	defer fm.Unlock()
+	f, ok := fm.filters[id]
+	if !ok {
+		return nil, errFilterNotFound
+	}
+	fm.resetDeadline(f)
+	switch f.typ {
+	case blocksFilter:
+		hashes := f.hashes
+		f.hashes = make([]common.Hash, 0)
+		return hashes, nil
+	default:
+		logs := f.logs
+		f.logs = make([]*types.Log, 0)
+		return logs, nil
+	}
+}
+
+// Criteria returns the criteria for the log filter with the provided id
+func (fm *FilterManager) Criteria(id rpc.ID) (FilterCriteria, error) {
+	fm.Lock()
+	defer fm.Unlock()
+	f, ok := fm.filters[id]
+	if !ok || f.typ != logsFilter {
+		return FilterCriteria{}, errFilterNotFound
+	}
+	fm.resetDeadline(f)
+	return f.crit, nil
+}
+
+// Uninstall removes the filter with the provided id, it returns whether or not the filter existed
+func (fm *FilterManager) Uninstall(id rpc.ID) bool {
+	fm.Lock()
+	f, ok := fm.filters[id]
+	delete(fm.filters, id)
+	fm.Unlock()
+	if !ok {
+		return false
+	}
+	fm.events.Unsubscribe(id)
+	f.deadline.Stop()
+	close(f.done)
+	return true
+}
+
+// resetDeadline pushes back the filter's expiry after it has been polled
+// resetDeadline needs to be called with filter access locked
+func (fm *FilterManager) resetDeadline(f *filter) {
+	if !f.deadline.Stop() {
+		// timer expired but the filter was polled before it was uninstalled, drain the channel
+		select {
+		case <-f.deadline.C:
+		default:
+		}
+	}
+	f.deadline.Reset(fm.timeout)
+}
@@ -0,0 +1,91 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth_test
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+var _ = Describe("FilterManager", func() {
+	var (
+		payloadChan chan shared.ConvertedData
+		quitChan    chan bool
+		filters     *eth.FilterManager
+	)
+	BeforeEach(func() {
+		payloadChan = make(chan shared.ConvertedData, 10)
+		quitChan = make(chan bool)
+		filters = eth.NewFilterManager(eth.NewEventSystem(payloadChan, quitChan), 200*time.Millisecond, quitChan)
+	})
+	AfterEach(func() {
+		close(quitChan)
+	})
+
+	It("Accumulates block hashes until they are polled", func() {
+		id := filters.NewBlockFilter()
+		block1 := eventPayload(1, common.Hash{}, nil)
+		block2 := eventPayload(2, block1.Block.Hash(), nil)
+		payloadChan <- block1
+		payloadChan <- block2
+		Eventually(func() []common.Hash {
+			changes, err := filters.Changes(id)
+			Expect(err).ToNot(HaveOccurred())
+			return changes.([]common.Hash)
+		}).Should(Equal([]common.Hash{block1.Block.Hash(), block2.Block.Hash()}))
+		changes, err := filters.Changes(id)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changes).To(BeEmpty())
+	})
+
+	It("Accumulates matching logs until they are polled", func() {
+		id := filters.NewLogFilter(eth.FilterCriteria{Addresses: []common.Address{eventAddress}})
+		wanted := &types.Log{Address: eventAddress, Topics: []common.Hash{eventTopic}}
+		unwanted := &types.Log{Address: eventAnotherAddress, Topics: []common.Hash{eventTopic}}
+		payloadChan <- eventPayload(1, common.Hash{}, nil, wanted, unwanted)
+		Eventually(func() []*types.Log {
+			changes, err := filters.Changes(id)
+			Expect(err).ToNot(HaveOccurred())
+			return changes.([]*types.Log)
+		}).Should(Equal([]*types.Log{wanted}))
+		crit, err := filters.Criteria(id)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(crit.Addresses).To(Equal([]common.Address{eventAddress}))
+	})
+
+	It("Uninstalls filters", func() {
+		id := filters.NewBlockFilter()
+		Expect(filters.Uninstall(id)).To(BeTrue())
+		Expect(filters.Uninstall(id)).To(BeFalse())
+		_, err := filters.Changes(id)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Expires filters that are not polled", func() {
+		id := filters.NewBlockFilter()
+		time.Sleep(500 * time.Millisecond)
+		_, err := filters.Changes(id)
+		Expect(err).To(HaveOccurred())
+	})
+})
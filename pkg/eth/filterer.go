@@ -169,10 +169,8 @@ func checkTransactionAddrs(wantedSrc, wantedDst []string, actualSrc, actualDst s
 func (s *ResponseFilterer) filerReceipts(receiptFilter ReceiptFilter, response *IPLDs, payload ConvertedPayload, trxHashes []common.Hash) error {
 	if !receiptFilter.Off {
 		response.Receipts = make([]ipfs.BlockModel, 0, len(payload.Receipts))
-		for i, receipt := range payload.Receipts {
-			// topics is always length 4
-			topics := [][]string{payload.ReceiptMetaData[i].Topic0s, payload.ReceiptMetaData[i].Topic1s, payload.ReceiptMetaData[i].Topic2s, payload.ReceiptMetaData[i].Topic3s}
-			if checkReceipts(receipt, receiptFilter.Topics, topics, receiptFilter.LogAddresses, payload.ReceiptMetaData[i].LogContracts, trxHashes) {
+		for _, receipt := range payload.Receipts {
+			if checkReceipts(receipt, receiptFilter.Topics, receiptFilter.LogAddresses, trxHashes) {
 				receiptBuffer := new(bytes.Buffer)
 				if err := receipt.EncodeRLP(receiptBuffer); err != nil {
 					return err
@@ -192,9 +190,9 @@ func (s *ResponseFilterer) filerReceipts(receiptFilter ReceiptFilter, response *
 	return nil
 }
 
-func checkReceipts(rct *types.Receipt, wantedTopics, actualTopics [][]string, wantedAddresses []string, actualAddresses []string, wantedTrxHashes []common.Hash) bool {
-	// If we aren't filtering for any topics, contracts, or corresponding trxs then all receipts are a go
-	if len(wantedTopics) == 0 && len(wantedAddresses) == 0 && len(wantedTrxHashes) == 0 {
+func checkReceipts(rct *types.Receipt, wantedTopics [][]string, wantedAddresses []string, wantedTrxHashes []common.Hash) bool {
+	// If we aren't filtering for any topics or contracts then all receipts are a go
+	if len(wantedAddresses) == 0 && !hasTopics(wantedTopics) {
 		return true
 	}
 	// Keep receipts that are from watched txs
@@ -203,25 +201,30 @@ func checkReceipts(rct *types.Receipt, wantedTopics, actualTopics [][]string, wa
 			return true
 		}
 	}
-	// If there are no wanted contract addresses, we keep all receipts that match the topic filter
-	if len(wantedAddresses) == 0 {
-		if match := filterMatch(wantedTopics, actualTopics); match == true {
+	// Otherwise we keep the receipt if one of its logs matches on both the contract and topic filters
+	for _, l := range rct.Logs {
+		if checkLog(l, wantedTopics, wantedAddresses) {
 			return true
 		}
 	}
-	// If there are wanted contract addresses to filter on
-	for _, wantedAddr := range wantedAddresses {
-		// and this is an address of interest
-		for _, actualAddr := range actualAddresses {
-			if wantedAddr == actualAddr {
-				// we keep the receipt if it matches on the topic filter
-				if match := filterMatch(wantedTopics, actualTopics); match == true {
-					return true
-				}
-			}
+	return false
+}
+
+// checkLog returns true if the log is from one of the wanted contract addresses and conforms to the wanted topics filter
+// If there are no wanted contract addresses, logs from any contract are kept
+func checkLog(l *types.Log, wantedTopics [][]string, wantedAddresses []string) bool {
+	if len(wantedAddresses) > 0 && slicesShareString([]string{l.Address.String()}, wantedAddresses) == 0 {
+		return false
+	}
+	// actualTopics is always length 4, with a nil slice at each position the log has no topic for
+	actualTopics := make([][]string, 4)
+	for i, topic := range l.Topics {
+		if i > 3 {
+			break
 		}
+		actualTopics[i] = []string{topic.Hex()}
 	}
-	return false
+	return filterMatch(wantedTopics, actualTopics)
 }
 
 // filterMatch returns true if the actualTopics conform to the wantedTopics filter
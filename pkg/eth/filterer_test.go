@@ -19,6 +19,8 @@ package eth_test
 import (
 	"bytes"
 
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/statediff"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -204,5 +206,27 @@ var _ = Describe("Filterer", func() {
 			Expect(len(iplds8.StateNodes)).To(Equal(0))
 			Expect(len(iplds8.Receipts)).To(Equal(0))
 		})
+
+		It("Matches the contract address and topics against the same log", func() {
+			// a single receipt holding both of the mock logs
+			rct := types.NewReceipt(common.HexToHash("0x0").Bytes(), false, 50)
+			rct.Logs = []*types.Log{mocks.MockLog1, mocks.MockLog2}
+			rct.TxHash = mocks.MockTransactions[0].Hash()
+			convertedPayload := mocks.MockConvertedPayload
+			convertedPayload.Receipts = types.Receipts{rct}
+
+			payload1, err := filterer.Filter(rctTopicsAndAddressFilter, convertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+			iplds1, ok := payload1.(eth.IPLDs)
+			Expect(ok).To(BeTrue())
+			Expect(len(iplds1.Receipts)).To(Equal(1))
+
+			// the address and first topic are from the first log but the second topic is from the other log
+			payload2, err := filterer.Filter(rctTopicsAndAddressFilterFail, convertedPayload)
+			Expect(err).ToNot(HaveOccurred())
+			iplds2, ok := payload2.(eth.IPLDs)
+			Expect(ok).To(BeTrue())
+			Expect(len(iplds2.Receipts)).To(Equal(0))
+		})
 	})
 })
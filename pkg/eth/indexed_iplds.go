@@ -0,0 +1,115 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// ipldIndex is a table the IPLDs of a type of data are indexed in
+type ipldIndex struct {
+	table string
+	// the joins and conditions that select the IPLDs indexed at block heights between $1 and $2
+	from string
+}
+
+var ipldIndexes = map[shared.DataType][]ipldIndex{
+	shared.Headers: {{
+		table: "header_cids",
+		from: `eth.header_cids
+			WHERE header_cids.block_number BETWEEN $1 AND $2`,
+	}},
+	shared.Uncles: {{
+		table: "uncle_cids",
+		from: `eth.uncle_cids
+			INNER JOIN eth.header_cids ON (uncle_cids.header_id = header_cids.id)
+			WHERE header_cids.block_number BETWEEN $1 AND $2`,
+	}},
+	shared.Transactions: {{
+		table: "transaction_cids",
+		from: `eth.transaction_cids
+			INNER JOIN eth.header_cids ON (transaction_cids.header_id = header_cids.id)
+			WHERE header_cids.block_number BETWEEN $1 AND $2`,
+	}},
+	shared.Receipts: {{
+		table: "receipt_cids",
+		from: `eth.receipt_cids
+			INNER JOIN eth.transaction_cids ON (receipt_cids.tx_id = transaction_cids.id)
+			INNER JOIN eth.header_cids ON (transaction_cids.header_id = header_cids.id)
+			WHERE header_cids.block_number BETWEEN $1 AND $2`,
+	}, {
+		table: "log_cids",
+		from: `eth.log_cids
+			INNER JOIN eth.receipt_cids ON (log_cids.receipt_id = receipt_cids.id)
+			INNER JOIN eth.transaction_cids ON (receipt_cids.tx_id = transaction_cids.id)
+			INNER JOIN eth.header_cids ON (transaction_cids.header_id = header_cids.id)
+			WHERE header_cids.block_number BETWEEN $1 AND $2`,
+	}},
+	shared.State: {{
+		table: "state_cids",
+		from: `eth.state_cids
+			INNER JOIN eth.header_cids ON (state_cids.header_id = header_cids.id)
+			WHERE header_cids.block_number BETWEEN $1 AND $2`,
+	}},
+	shared.Storage: {{
+		table: "storage_cids",
+		from: `eth.storage_cids
+			INNER JOIN eth.state_cids ON (storage_cids.state_id = state_cids.id)
+			INNER JOIN eth.header_cids ON (state_cids.header_id = header_cids.id)
+			WHERE header_cids.block_number BETWEEN $1 AND $2`,
+	}},
+}
+
+// indexedIPLDsPgStr returns the union of the selection from the index tables of the type of data
+// The full type covers the IPLDs of every type of data
+func indexedIPLDsPgStr(t shared.DataType, selection func(table string) string) (string, error) {
+	dataTypes := []shared.DataType{t}
+	if t == shared.Full {
+		dataTypes = []shared.DataType{shared.Headers, shared.Uncles, shared.Transactions, shared.Receipts, shared.State, shared.Storage}
+	}
+	var pgStrs []string
+	for _, dataType := range dataTypes {
+		indexes, ok := ipldIndexes[dataType]
+		if !ok {
+			return "", fmt.Errorf("eth indexed iplds query unrecognized type: %s", t.String())
+		}
+		for _, index := range indexes {
+			pgStrs = append(pgStrs, `SELECT `+selection(index.table)+` FROM `+index.from)
+		}
+	}
+	return strings.Join(pgStrs, "\n\t\tUNION ALL "), nil
+}
+
+// MhKeysPgStr returns the query for the mhkeys of the indexed IPLDs of the type of data at block heights between $1 and $2
+// Trie nodes that are published but not indexed (transaction and receipt trie nodes) are not covered
+func MhKeysPgStr(t shared.DataType) (string, error) {
+	return indexedIPLDsPgStr(t, func(table string) string {
+		return table + ".mh_key"
+	})
+}
+
+// CIDsPgStr returns the query for the block numbers, cids and mhkeys of the indexed IPLDs of the type of data at block
+// heights between $1 and $2, as the block_number, cid and mh_key columns
+// Trie nodes that are published but not indexed (transaction and receipt trie nodes) are not covered
+func CIDsPgStr(t shared.DataType) (string, error) {
+	return indexedIPLDsPgStr(t, func(table string) string {
+		return `header_cids.block_number, ` + table + `.cid, ` + table + `.mh_key`
+	})
+}
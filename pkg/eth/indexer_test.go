@@ -45,6 +45,8 @@ var _ = Describe("Indexer", func() {
 		shared.PublishMockIPLD(db, mocks.Rct1MhKey, mockData)
 		shared.PublishMockIPLD(db, mocks.Rct2MhKey, mockData)
 		shared.PublishMockIPLD(db, mocks.Rct3MhKey, mockData)
+		shared.PublishMockIPLD(db, mocks.Log1MhKey, mockData)
+		shared.PublishMockIPLD(db, mocks.Log2MhKey, mockData)
 		shared.PublishMockIPLD(db, mocks.State1MhKey, mockData)
 		shared.PublishMockIPLD(db, mocks.State2MhKey, mockData)
 		shared.PublishMockIPLD(db, mocks.StorageMhKey, mockData)
@@ -95,6 +97,21 @@ var _ = Describe("Indexer", func() {
 			Expect(shared.ListContainsString(rcts, mocks.Rct1CID.String())).To(BeTrue())
 			Expect(shared.ListContainsString(rcts, mocks.Rct2CID.String())).To(BeTrue())
 			Expect(shared.ListContainsString(rcts, mocks.Rct3CID.String())).To(BeTrue())
+			// check logs were properly indexed
+			logs := make([]eth.LogModel, 0)
+			pgStr = `SELECT log_cids.log_index, log_cids.cid, log_cids.mh_key, log_cids.address, log_cids.topic0, log_cids.topic1, log_cids.topic2, log_cids.topic3
+				FROM eth.log_cids, eth.receipt_cids, eth.transaction_cids, eth.header_cids
+				WHERE log_cids.receipt_id = receipt_cids.id
+				AND receipt_cids.tx_id = transaction_cids.id
+				AND transaction_cids.header_id = header_cids.id
+				AND header_cids.block_number = $1
+				ORDER BY log_cids.log_index`
+			err = db.Select(&logs, pgStr, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(logs).To(Equal([]eth.LogModel{
+				mocks.MockCIDPayload.LogCIDs[mocks.MockTransactions[0].Hash()][0],
+				mocks.MockCIDPayload.LogCIDs[mocks.MockTransactions[1].Hash()][0],
+			}))
 			// check that state nodes were properly indexed
 			stateNodes := make([]eth.StateNodeModel, 0)
 			pgStr = `SELECT state_cids.cid, state_cids.state_leaf_key, state_cids.node_type, state_cids.state_path, state_cids.header_id
@@ -52,6 +52,18 @@ func NewIPLDFetcher(ipfsPath string) (*IPLDFetcher, error) {
 	}, nil
 }
 
+// NewRemoteIPLDFetcher creates a pointer to a new IPLDFetcher
+// It fetches from the IPFS daemon at the given HTTP API address
+func NewRemoteIPLDFetcher(ipfsAPI string) (*IPLDFetcher, error) {
+	blockService, err := ipfs.InitRemoteBlockService(ipfsAPI)
+	if err != nil {
+		return nil, err
+	}
+	return &IPLDFetcher{
+		BlockService: blockService,
+	}, nil
+}
+
 // Fetch is the exported method for fetching and returning all the IPLDS specified in the CIDWrapper
 func (f *IPLDFetcher) Fetch(cids shared.CIDsForFetching) (shared.IPLDs, error) {
 	cidWrapper, ok := cids.(*CIDWrapper)
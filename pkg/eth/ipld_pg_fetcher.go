@@ -31,15 +31,18 @@ import (
 )
 
 // IPLDPGFetcher satisfies the IPLDFetcher interface for ethereum
-// It interfaces directly with PG-IPFS
+// It interfaces directly with the blockstores, by default PG-IPFS
 type IPLDPGFetcher struct {
-	db *postgres.DB
+	// Blockstores the IPLDs are fetched from, by type of data
+	Blockstores *shared.Blockstores
+	db          *postgres.DB
 }
 
 // NewIPLDPGFetcher creates a pointer to a new IPLDPGFetcher
 func NewIPLDPGFetcher(db *postgres.DB) *IPLDPGFetcher {
 	return &IPLDPGFetcher{
-		db: db,
+		Blockstores: shared.NewPostgresBlockstores(),
+		db:          db,
 	}
 }
 
@@ -102,7 +105,7 @@ func (f *IPLDPGFetcher) Fetch(cids shared.CIDsForFetching) (shared.IPLDs, error)
 // FetchHeaders fetches headers
 func (f *IPLDPGFetcher) FetchHeader(tx *sqlx.Tx, c HeaderModel) (ipfs.BlockModel, error) {
 	log.Debug("fetching header ipld")
-	headerBytes, err := shared.FetchIPLDByMhKey(tx, c.MhKey)
+	headerBytes, err := f.Blockstores.Get(tx, shared.Headers, c.MhKey)
 	if err != nil {
 		return ipfs.BlockModel{}, err
 	}
@@ -117,7 +120,7 @@ func (f *IPLDPGFetcher) FetchUncles(tx *sqlx.Tx, cids []UncleModel) ([]ipfs.Bloc
 	log.Debug("fetching uncle iplds")
 	uncleIPLDs := make([]ipfs.BlockModel, len(cids))
 	for i, c := range cids {
-		uncleBytes, err := shared.FetchIPLDByMhKey(tx, c.MhKey)
+		uncleBytes, err := f.Blockstores.Get(tx, shared.Uncles, c.MhKey)
 		if err != nil {
 			return nil, err
 		}
@@ -134,7 +137,7 @@ func (f *IPLDPGFetcher) FetchTrxs(tx *sqlx.Tx, cids []TxModel) ([]ipfs.BlockMode
 	log.Debug("fetching transaction iplds")
 	trxIPLDs := make([]ipfs.BlockModel, len(cids))
 	for i, c := range cids {
-		txBytes, err := shared.FetchIPLDByMhKey(tx, c.MhKey)
+		txBytes, err := f.Blockstores.Get(tx, shared.Transactions, c.MhKey)
 		if err != nil {
 			return nil, err
 		}
@@ -151,7 +154,7 @@ func (f *IPLDPGFetcher) FetchRcts(tx *sqlx.Tx, cids []ReceiptModel) ([]ipfs.Bloc
 	log.Debug("fetching receipt iplds")
 	rctIPLDs := make([]ipfs.BlockModel, len(cids))
 	for i, c := range cids {
-		rctBytes, err := shared.FetchIPLDByMhKey(tx, c.MhKey)
+		rctBytes, err := f.Blockstores.Get(tx, shared.Receipts, c.MhKey)
 		if err != nil {
 			return nil, err
 		}
@@ -163,6 +166,23 @@ func (f *IPLDPGFetcher) FetchRcts(tx *sqlx.Tx, cids []ReceiptModel) ([]ipfs.Bloc
 	return rctIPLDs, nil
 }
 
+// FetchLogs fetches logs
+func (f *IPLDPGFetcher) FetchLogs(tx *sqlx.Tx, cids []LogResultModel) ([]ipfs.BlockModel, error) {
+	log.Debug("fetching log iplds")
+	logIPLDs := make([]ipfs.BlockModel, len(cids))
+	for i, c := range cids {
+		logBytes, err := f.Blockstores.Get(tx, shared.Receipts, c.MhKey)
+		if err != nil {
+			return nil, err
+		}
+		logIPLDs[i] = ipfs.BlockModel{
+			Data: logBytes,
+			CID:  c.CID,
+		}
+	}
+	return logIPLDs, nil
+}
+
 // FetchState fetches state nodes
 func (f *IPLDPGFetcher) FetchState(tx *sqlx.Tx, cids []StateNodeModel) ([]StateNode, error) {
 	log.Debug("fetching state iplds")
@@ -171,7 +191,7 @@ func (f *IPLDPGFetcher) FetchState(tx *sqlx.Tx, cids []StateNodeModel) ([]StateN
 		if stateNode.CID == "" {
 			continue
 		}
-		stateBytes, err := shared.FetchIPLDByMhKey(tx, stateNode.MhKey)
+		stateBytes, err := f.Blockstores.Get(tx, shared.State, stateNode.MhKey)
 		if err != nil {
 			return nil, err
 		}
@@ -196,7 +216,7 @@ func (f *IPLDPGFetcher) FetchStorage(tx *sqlx.Tx, cids []StorageNodeWithStateKey
 		if storageNode.CID == "" || storageNode.StateKey == "" {
 			continue
 		}
-		storageBytes, err := shared.FetchIPLDByMhKey(tx, storageNode.MhKey)
+		storageBytes, err := f.Blockstores.Get(tx, shared.Storage, storageNode.MhKey)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,206 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/jmoiron/sqlx"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// Default limits for log queries
+const (
+	DefaultLogsMaxBlockRange = 10000
+	DefaultLogsMaxResults    = 10000
+	DefaultLogsPageSize      = 1000
+)
+
+// LogsConfig holds the limits placed on log queries
+// A max block range or max results that is not positive is not enforced
+type LogsConfig struct {
+	MaxBlockRange int64
+	MaxResults    int
+	PageSize      int
+}
+
+// NewLogsConfig returns the log query limits from the config or env variables, falling back to the defaults
+func NewLogsConfig() LogsConfig {
+	viper.BindEnv("ethereum.logs.maxBlockRange", shared.ETH_LOGS_MAX_BLOCK_RANGE)
+	viper.BindEnv("ethereum.logs.maxResults", shared.ETH_LOGS_MAX_RESULTS)
+	viper.BindEnv("ethereum.logs.pageSize", shared.ETH_LOGS_PAGE_SIZE)
+
+	c := LogsConfig{
+		MaxBlockRange: DefaultLogsMaxBlockRange,
+		MaxResults:    DefaultLogsMaxResults,
+		PageSize:      DefaultLogsPageSize,
+	}
+	if viper.IsSet("ethereum.logs.maxBlockRange") {
+		c.MaxBlockRange = viper.GetInt64("ethereum.logs.maxBlockRange")
+	}
+	if viper.IsSet("ethereum.logs.maxResults") {
+		c.MaxResults = viper.GetInt("ethereum.logs.maxResults")
+	}
+	if pageSize := viper.GetInt("ethereum.logs.pageSize"); pageSize > 0 {
+		c.PageSize = pageSize
+	}
+	return c
+}
+
+// FilterLogs returns the logs matching the provided criteria
+// nil or latest block numbers resolve to the latest block number in the database, and
+// the range and number of results are checked against the backend's LogsConfig: a query that matches more than the
+// max results is rejected before their IPLDs are fetched, like geth's providers do, rather than truncated
+func (b *Backend) FilterLogs(ctx context.Context, crit FilterCriteria) ([]*types.Log, error) {
+	var start, end int64
+	if crit.BlockHash == nil {
+		var err error
+		if start, end, err = b.resolveLogRange(crit); err != nil {
+			return nil, err
+		}
+	}
+	filter := receiptFilterFromCriteria(crit)
+
+	// Begin tx
+	tx, err := b.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	logs := make([]*types.Log, 0)
+	var after *LogResultModel
+	for {
+		// no more than one log past the max results is retrieved, which is enough to tell that the query returns too many
+		limit := b.LogsConfig.PageSize
+		if remaining := b.LogsConfig.MaxResults + 1 - len(logs); b.LogsConfig.MaxResults > 0 && (limit <= 0 || remaining < limit) {
+			limit = remaining
+		}
+		var logCIDs []LogResultModel
+		logCIDs, err = b.Retriever.RetrieveFilteredLogCIDs(tx, filter, start, end, crit.BlockHash, after, limit)
+		if err != nil {
+			return nil, err
+		}
+		if len(logCIDs) == 0 {
+			break
+		}
+		if b.LogsConfig.MaxResults > 0 && len(logs)+len(logCIDs) > b.LogsConfig.MaxResults {
+			err = fmt.Errorf("query returned more than %d results", b.LogsConfig.MaxResults)
+			return nil, err
+		}
+		var pageLogs []*types.Log
+		pageLogs, err = b.fetchLogs(tx, logCIDs)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, pageLogs...)
+		if limit <= 0 || len(logCIDs) < limit {
+			break
+		}
+		after = &logCIDs[len(logCIDs)-1]
+	}
+	return logs, err // need to return err variable so that we return the err = tx.Commit() assignment in the defer
+}
+
+// resolveLogRange returns the block range for the criteria, checking it against the max block range
+func (b *Backend) resolveLogRange(crit FilterCriteria) (int64, int64, error) {
+	if crit.FromBlock != nil && crit.FromBlock.Int64() == rpc.PendingBlockNumber.Int64() {
+		return 0, 0, errPendingBlockNumber
+	}
+	var start, end int64
+	// geth defaults both ends of the range to the latest block
+	if crit.FromBlock == nil || crit.ToBlock == nil || crit.FromBlock.Sign() < 0 || crit.ToBlock.Sign() < 0 {
+		last, err := b.Retriever.RetrieveLastBlockNumber()
+		if err != nil {
+			return 0, 0, err
+		}
+		start, end = last, last
+	}
+	if crit.FromBlock != nil && crit.FromBlock.Sign() >= 0 {
+		start = crit.FromBlock.Int64()
+	}
+	if crit.ToBlock != nil && crit.ToBlock.Sign() >= 0 {
+		end = crit.ToBlock.Int64()
+	}
+	if start > end {
+		return 0, 0, fmt.Errorf("invalid block range: fromBlock %d is greater than toBlock %d", start, end)
+	}
+	if b.LogsConfig.MaxBlockRange > 0 && end-start+1 > b.LogsConfig.MaxBlockRange {
+		return 0, 0, fmt.Errorf("block range %d to %d exceeds the max block range of %d blocks", start, end, b.LogsConfig.MaxBlockRange)
+	}
+	return start, end, nil
+}
+
+// fetchLogs fetches the provided logs and fills in their derived fields from the position they were indexed at
+func (b *Backend) fetchLogs(tx *sqlx.Tx, logCIDs []LogResultModel) ([]*types.Log, error) {
+	logIPLDs, err := b.Fetcher.FetchLogs(tx, logCIDs)
+	if err != nil {
+		return nil, err
+	}
+	logs := make([]*types.Log, len(logIPLDs))
+	for i, logIPLD := range logIPLDs {
+		l := new(types.Log)
+		if err := rlp.DecodeBytes(logIPLD.Data, l); err != nil {
+			return nil, err
+		}
+		l.BlockNumber = uint64(logCIDs[i].BlockNumber)
+		l.BlockHash = common.HexToHash(logCIDs[i].BlockHash)
+		l.TxHash = common.HexToHash(logCIDs[i].TxHash)
+		l.TxIndex = uint(logCIDs[i].TxIndex)
+		l.Index = uint(logCIDs[i].LogIndex)
+		logs[i] = l
+	}
+	return logs, nil
+}
+
+// receiptFilterFromCriteria converts the FilterCriteria into a ReceiptFilter
+func receiptFilterFromCriteria(crit FilterCriteria) ReceiptFilter {
+	addrStrs := make([]string, len(crit.Addresses))
+	for i, addr := range crit.Addresses {
+		addrStrs[i] = addr.String()
+	}
+	topicStrSets := make([][]string, 4)
+	for i, topicSet := range crit.Topics {
+		if i > 3 {
+			// don't allow more than 4 topics
+			break
+		}
+		for _, topic := range topicSet {
+			topicStrSets[i] = append(topicStrSets[i], topic.String())
+		}
+	}
+	return ReceiptFilter{
+		LogAddresses: addrStrs,
+		Topics:       topicStrSets,
+	}
+}
@@ -91,13 +91,22 @@ var (
 	Rct2MhKey     = shared.MultihashKeyFromCID(Rct2CID)
 	Rct3CID, _    = ipld.RawdataToCid(ipld.MEthTxReceipt, MockReceipts.GetRlp(2), multihash.KECCAK_256)
 	Rct3MhKey     = shared.MultihashKeyFromCID(Rct3CID)
+	Log1Rlp, _    = rlp.EncodeToBytes(MockLog1)
+	Log1CID, _    = ipld.RawdataToCid(ipld.MEthLog, Log1Rlp, multihash.KECCAK_256)
+	Log1MhKey     = shared.MultihashKeyFromCID(Log1CID)
+	Log2Rlp, _    = rlp.EncodeToBytes(MockLog2)
+	Log2CID, _    = ipld.RawdataToCid(ipld.MEthLog, Log2Rlp, multihash.KECCAK_256)
+	Log2MhKey     = shared.MultihashKeyFromCID(Log2CID)
 	State1CID, _  = ipld.RawdataToCid(ipld.MEthStateTrie, ContractLeafNode, multihash.KECCAK_256)
 	State1MhKey   = shared.MultihashKeyFromCID(State1CID)
 	State2CID, _  = ipld.RawdataToCid(ipld.MEthStateTrie, AccountLeafNode, multihash.KECCAK_256)
 	State2MhKey   = shared.MultihashKeyFromCID(State2CID)
 	StorageCID, _ = ipld.RawdataToCid(ipld.MEthStorageTrie, StorageLeafNode, multihash.KECCAK_256)
 	StorageMhKey  = shared.MultihashKeyFromCID(StorageCID)
-	MockTrxMeta   = []eth.TxModel{
+	// the roots of the transaction and receipt tries, which are published but not indexed
+	TxTrieCID   = ipld.Keccak256ToCid(ipld.MEthTxTrie, MockBlock.TxHash().Bytes())
+	RctTrieCID  = ipld.Keccak256ToCid(ipld.MEthTxReceiptTrie, MockBlock.ReceiptHash().Bytes())
+	MockTrxMeta = []eth.TxModel{
 		{
 			CID:    "", // This is empty until we go to publish to ipfs
 			MhKey:  "",
@@ -385,6 +394,29 @@ var (
 			MockTransactions[1].Hash(): MockRctMetaPostPublish[1],
 			MockTransactions[2].Hash(): MockRctMetaPostPublish[2],
 		},
+		LogCIDs: map[common.Hash][]eth.LogModel{
+			MockTransactions[0].Hash(): {
+				{
+					LogIndex: 0,
+					CID:      Log1CID.String(),
+					MhKey:    Log1MhKey,
+					Address:  Address.String(),
+					Topic0:   mockTopic11.String(),
+					Topic1:   mockTopic12.String(),
+				},
+			},
+			MockTransactions[1].Hash(): {
+				{
+					LogIndex: 1,
+					CID:      Log2CID.String(),
+					MhKey:    Log2MhKey,
+					Address:  AnotherAddress.String(),
+					Topic0:   mockTopic21.String(),
+					Topic1:   mockTopic22.String(),
+				},
+			},
+			MockTransactions[2].Hash(): {},
+		},
 		StateNodeCIDs: MockStateMetaPostPublish,
 		StorageNodeCIDs: map[string][]eth.StorageNodeModel{
 			contractPath: {
@@ -527,6 +559,11 @@ var (
 	}
 )
 
+func init() {
+	MockLog1.BlockHash = MockBlock.Hash()
+	MockLog2.BlockHash = MockBlock.Hash()
+}
+
 // createTransactionsAndReceipts is a helper function to generate signed mock transactions and mock receipts with mock logs
 func createTransactionsAndReceipts() (types.Transactions, types.Receipts, common.Address) {
 	// make transactions
@@ -555,6 +592,15 @@ func createTransactionsAndReceipts() (types.Transactions, types.Receipts, common
 	if err != nil {
 		log.Fatal(err)
 	}
+	// fill in the derived fields of the logs, the block hash is filled in once the block has been made
+	MockLog1.BlockNumber = BlockNumber.Uint64()
+	MockLog1.TxHash = signedTrx1.Hash()
+	MockLog1.TxIndex = 0
+	MockLog1.Index = 0
+	MockLog2.BlockNumber = BlockNumber.Uint64()
+	MockLog2.TxHash = signedTrx2.Hash()
+	MockLog2.TxIndex = 1
+	MockLog2.Index = 1
 	// make receipts
 	mockReceipt1 := types.NewReceipt(common.HexToHash("0x0").Bytes(), false, 50)
 	mockReceipt1.Logs = []*types.Log{MockLog1}
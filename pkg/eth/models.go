@@ -36,6 +36,7 @@ type HeaderModel struct {
 	Bloom           []byte `db:"bloom"`
 	Timestamp       uint64 `db:"timestamp"`
 	TimesValidated  int64  `db:"times_validated"`
+	StateAbsent     bool   `db:"state_absent"`
 }
 
 // UncleModel is the db model for eth.uncle_cids
@@ -76,6 +77,31 @@ type ReceiptModel struct {
 	Topic3s      pq.StringArray `db:"topic3s"`
 }
 
+// LogModel is the db model for eth.log_cids
+type LogModel struct {
+	ID        int64  `db:"id"`
+	ReceiptID int64  `db:"receipt_id"`
+	LogIndex  int64  `db:"log_index"`
+	CID       string `db:"cid"`
+	MhKey     string `db:"mh_key"`
+	Address   string `db:"address"`
+	Topic0    string `db:"topic0"`
+	Topic1    string `db:"topic1"`
+	Topic2    string `db:"topic2"`
+	Topic3    string `db:"topic3"`
+}
+
+// LogResultModel is a LogModel along with the position of the log in the chain
+// It carries the metadata needed to fill in the log's derived fields and to page through logs across a range of blocks
+type LogResultModel struct {
+	LogModel
+	HeaderID    int64  `db:"header_id"`
+	BlockNumber int64  `db:"block_number"`
+	BlockHash   string `db:"block_hash"`
+	TxHash      string `db:"tx_hash"`
+	TxIndex     int64  `db:"tx_index"`
+}
+
 // StateNodeModel is the db model for eth.state_cids
 type StateNodeModel struct {
 	ID       int64  `db:"id"`
@@ -0,0 +1,391 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// AccountResult is the EIP-1186 response for eth_getProof
+type AccountResult struct {
+	Address      common.Address  `json:"address"`
+	AccountProof []string        `json:"accountProof"`
+	Balance      *hexutil.Big    `json:"balance"`
+	CodeHash     common.Hash     `json:"codeHash"`
+	Nonce        hexutil.Uint64  `json:"nonce"`
+	StorageHash  common.Hash     `json:"storageHash"`
+	StorageProof []StorageResult `json:"storageProof"`
+}
+
+// StorageResult is the EIP-1186 proof for a single storage key
+type StorageResult struct {
+	Key   string       `json:"key"`
+	Value *hexutil.Big `json:"value"`
+	Proof []string     `json:"proof"`
+}
+
+// GetProof returns the account and storage proofs for the provided address and storage keys at the provided block
+// The proofs are built from the trie nodes we have stored, so the intermediate state and storage nodes need to have been indexed
+func (b *Backend) GetProof(ctx context.Context, address common.Address, storageKeys []string, blockNrOrHash rpc.BlockNumberOrHash) (*AccountResult, error) {
+	// Begin tx
+	tx, err := b.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var stateRoot common.Hash
+	stateRoot, err = b.stateRootByNumberOrHash(tx, blockNrOrHash)
+	if err != nil {
+		return nil, err
+	}
+	var accountProof [][]byte
+	var accountRLP []byte
+	accountProof, accountRLP, err = proveTrieKey(tx, b.Fetcher.Blockstores.For(shared.State), stateRoot, crypto.Keccak256(address.Bytes()))
+	if err != nil {
+		return nil, err
+	}
+	result := &AccountResult{
+		Address:      address,
+		AccountProof: toHexArray(accountProof),
+		Balance:      new(hexutil.Big),
+		CodeHash:     crypto.Keccak256Hash(nil),
+		StorageHash:  types.EmptyRootHash,
+		StorageProof: make([]StorageResult, len(storageKeys)),
+	}
+	if accountRLP != nil {
+		var account state.Account
+		if err = rlp.DecodeBytes(accountRLP, &account); err != nil {
+			return nil, err
+		}
+		result.Balance = (*hexutil.Big)(account.Balance)
+		result.CodeHash = common.BytesToHash(account.CodeHash)
+		result.Nonce = hexutil.Uint64(account.Nonce)
+		result.StorageHash = account.Root
+	}
+	for i, key := range storageKeys {
+		result.StorageProof[i] = StorageResult{
+			Key:   key,
+			Value: new(hexutil.Big),
+			Proof: []string{},
+		}
+		if accountRLP == nil || result.StorageHash == types.EmptyRootHash {
+			// the account doesn't exist or has no storage, so there is nothing to prove against
+			continue
+		}
+		var storageProof [][]byte
+		var storageRLP []byte
+		storageProof, storageRLP, err = proveTrieKey(tx, b.Fetcher.Blockstores.For(shared.Storage), result.StorageHash, crypto.Keccak256(common.HexToHash(key).Bytes()))
+		if err != nil {
+			return nil, err
+		}
+		result.StorageProof[i].Proof = toHexArray(storageProof)
+		if storageRLP != nil {
+			var value []byte
+			if err = rlp.DecodeBytes(storageRLP, &value); err != nil {
+				return nil, err
+			}
+			result.StorageProof[i].Value = (*hexutil.Big)(new(big.Int).SetBytes(value))
+		}
+	}
+	return result, err // need to return err variable so that we return the err = tx.Commit() assignment in the defer
+}
+
+// InclusionProof is the merkle proof for a transaction or receipt in the transaction or receipt trie of a block
+// Root is the header's transaction or receipt root that the proof is for and Value is the consensus encoding of the proven object
+type InclusionProof struct {
+	BlockHash   common.Hash    `json:"blockHash"`
+	BlockNumber hexutil.Uint64 `json:"blockNumber"`
+	Root        common.Hash    `json:"root"`
+	Index       hexutil.Uint64 `json:"index"`
+	Value       hexutil.Bytes  `json:"value"`
+	Proof       []string       `json:"proof"`
+}
+
+// GetTransactionProof returns the proof for the transaction at the provided index in the block with the provided hash
+func (b *Backend) GetTransactionProof(ctx context.Context, blockHash common.Hash, index uint64) (*InclusionProof, error) {
+	return b.getInclusionProof(blockHash, index, "transaction", shared.Transactions, func(header HeaderModel) string {
+		return header.TxRoot
+	})
+}
+
+// GetReceiptProof returns the proof for the receipt at the provided index in the block with the provided hash
+func (b *Backend) GetReceiptProof(ctx context.Context, blockHash common.Hash, index uint64) (*InclusionProof, error) {
+	return b.getInclusionProof(blockHash, index, "receipt", shared.Receipts, func(header HeaderModel) string {
+		return header.RctRoot
+	})
+}
+
+// getInclusionProof proves the provided index against the root selected from the header of the provided block
+// Transaction and receipt tries are keyed by the rlp encoded index
+func (b *Backend) getInclusionProof(blockHash common.Hash, index uint64, kind string, t shared.DataType, root func(HeaderModel) string) (*InclusionProof, error) {
+	key, err := rlp.EncodeToBytes(uint(index))
+	if err != nil {
+		return nil, err
+	}
+	// Begin tx
+	tx, err := b.DB.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var header HeaderModel
+	header, err = b.Retriever.RetrieveHeaderCIDByHash(tx, blockHash)
+	if err == sql.ErrNoRows {
+		err = fmt.Errorf("header for block %s is not available", blockHash.Hex())
+	}
+	if err != nil {
+		return nil, err
+	}
+	var blockNumber uint64
+	blockNumber, err = strconv.ParseUint(header.BlockNumber, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	result := &InclusionProof{
+		BlockHash:   blockHash,
+		BlockNumber: hexutil.Uint64(blockNumber),
+		Root:        common.HexToHash(root(header)),
+		Index:       hexutil.Uint64(index),
+	}
+	var proof [][]byte
+	proof, result.Value, err = proveTrieKey(tx, b.Fetcher.Blockstores.For(t), result.Root, key)
+	if err != nil {
+		return nil, err
+	}
+	if result.Value == nil {
+		err = fmt.Errorf("%s index %d not found in block %s", kind, index, blockHash.Hex())
+		return nil, err
+	}
+	result.Proof = toHexArray(proof)
+	return result, err // need to return err variable so that we return the err = tx.Commit() assignment in the defer
+}
+
+// stateRootByNumberOrHash returns the state root of the header at the provided block number or hash
+func (b *Backend) stateRootByNumberOrHash(tx *sqlx.Tx, blockNrOrHash rpc.BlockNumberOrHash) (common.Hash, error) {
+	if hash, ok := blockNrOrHash.Hash(); ok {
+		header, err := b.Retriever.RetrieveHeaderCIDByHash(tx, hash)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		return common.HexToHash(header.StateRoot), nil
+	}
+	blockNumber, ok := blockNrOrHash.Number()
+	if !ok {
+		return common.Hash{}, fmt.Errorf("invalid arguments; neither block nor hash specified")
+	}
+	if blockNumber == rpc.PendingBlockNumber {
+		return common.Hash{}, errPendingBlockNumber
+	}
+	number := blockNumber.Int64()
+	if blockNumber == rpc.LatestBlockNumber {
+		var err error
+		number, err = b.Retriever.RetrieveLastBlockNumber()
+		if err != nil {
+			return common.Hash{}, err
+		}
+	}
+	headers, err := b.Retriever.RetrieveHeaderCIDs(tx, number)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if len(headers) < 1 {
+		return common.Hash{}, fmt.Errorf("header at block %d is not available", number)
+	}
+	return common.HexToHash(headers[0].StateRoot), nil
+}
+
+// proveTrieKey walks the stored trie nodes from the provided root down the path of the provided key
+// It returns the nodes that make up the merkle proof for the key and the value stored at the key
+// If the key is not in the trie the value is nil and the proof proves its absence
+// Nodes are looked up by their hash, so nodes that last changed in an earlier block than the root are found as well
+func proveTrieKey(tx *sqlx.Tx, store shared.Blockstore, root common.Hash, key []byte) ([][]byte, []byte, error) {
+	proof := make([][]byte, 0)
+	if root == types.EmptyRootHash {
+		return proof, nil, nil
+	}
+	path := keyToNibbles(key)
+	node, err := fetchTrieNode(tx, store, root)
+	if err != nil {
+		return nil, nil, err
+	}
+	proof = append(proof, node)
+	for {
+		elems, err := decodeTrieNode(node)
+		if err != nil {
+			return nil, nil, err
+		}
+		var child []byte
+		switch len(elems) {
+		case 17:
+			if len(path) == 0 {
+				value, err := trieNodeValue(elems[16])
+				return proof, value, err
+			}
+			child = elems[path[0]]
+			path = path[1:]
+		case 2:
+			_, compactKey, _, err := rlp.Split(elems[0])
+			if err != nil {
+				return nil, nil, err
+			}
+			nodeKey, isLeaf := compactToNibbles(compactKey)
+			if isLeaf {
+				if !bytes.Equal(nodeKey, path) {
+					return proof, nil, nil
+				}
+				value, err := trieNodeValue(elems[1])
+				return proof, value, err
+			}
+			if len(path) < len(nodeKey) || !bytes.Equal(nodeKey, path[:len(nodeKey)]) {
+				return proof, nil, nil
+			}
+			child = elems[1]
+			path = path[len(nodeKey):]
+		default:
+			return nil, nil, fmt.Errorf("invalid trie node with %d elements", len(elems))
+		}
+		// the child is either a reference to another node by its hash, an empty reference, or a small node embedded in its parent
+		kind, content, _, err := rlp.Split(child)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case kind == rlp.List:
+			node = child
+		case len(content) == 0:
+			return proof, nil, nil
+		case len(content) == common.HashLength:
+			node, err = fetchTrieNode(tx, store, common.BytesToHash(content))
+			if err != nil {
+				return nil, nil, err
+			}
+			proof = append(proof, node)
+		default:
+			return nil, nil, fmt.Errorf("invalid trie node reference %x", content)
+		}
+	}
+}
+
+// fetchTrieNode fetches the trie node with the provided hash from the blockstore
+func fetchTrieNode(tx *sqlx.Tx, store shared.Blockstore, hash common.Hash) ([]byte, error) {
+	mhKey, err := shared.MultihashKeyFromKeccak256(hash)
+	if err != nil {
+		return nil, err
+	}
+	node, err := store.Get(tx, mhKey)
+	if err == shared.ErrBlockNotFound {
+		return nil, fmt.Errorf("missing trie node %s", hash.Hex())
+	}
+	return node, err
+}
+
+// decodeTrieNode splits the rlp encoded trie node into its (still encoded) elements
+func decodeTrieNode(node []byte) ([][]byte, error) {
+	content, _, err := rlp.SplitList(node)
+	if err != nil {
+		return nil, err
+	}
+	elems := make([][]byte, 0, 17)
+	for len(content) > 0 {
+		_, _, rest, err := rlp.Split(content)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, content[:len(content)-len(rest)])
+		content = rest
+	}
+	return elems, nil
+}
+
+// trieNodeValue returns the value held in the encoded value element of a leaf or branch node, or nil if it is empty
+func trieNodeValue(elem []byte) ([]byte, error) {
+	value, _, err := rlp.SplitString(elem)
+	if err != nil || len(value) == 0 {
+		return nil, err
+	}
+	return value, nil
+}
+
+// keyToNibbles splits the key into the nibbles that make up its path through the trie
+func keyToNibbles(key []byte) []byte {
+	nibbles := make([]byte, len(key)*2)
+	for i, b := range key {
+		nibbles[i*2] = b / 16
+		nibbles[i*2+1] = b % 16
+	}
+	return nibbles
+}
+
+// compactToNibbles decodes the hex prefix encoded key of a leaf or extension node
+// It returns the nibbles of the key and whether or not the node is a leaf
+func compactToNibbles(compact []byte) ([]byte, bool) {
+	if len(compact) == 0 {
+		return []byte{}, false
+	}
+	isLeaf := compact[0]>>4 >= 2
+	nibbles := keyToNibbles(compact)
+	// drop the flag nibble, and the padding nibble if the key has an even length
+	if compact[0]>>4&1 == 1 {
+		return nibbles[1:], isLeaf
+	}
+	return nibbles[2:], isLeaf
+}
+
+// toHexArray hex encodes each of the proof nodes
+func toHexArray(nodes [][]byte) []string {
+	strs := make([]string, len(nodes))
+	for i, node := range nodes {
+		strs[i] = hexutil.Encode(node)
+	}
+	return strs
+}
@@ -0,0 +1,207 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth_test
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/multiformats/go-multihash"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/client"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+var (
+	proofAccount        = common.HexToAddress("0x1111111111111111111111111111111111111111")
+	proofAnotherAccount = common.HexToAddress("0x2222222222222222222222222222222222222222")
+	proofMissingAccount = common.HexToAddress("0x3333333333333333333333333333333333333333")
+	proofStorageKey     = common.HexToHash("0x01")
+	proofStorageValue   = common.HexToHash("0x0539")
+)
+
+// verifyProof checks the hex encoded proof against the root and returns the proven value
+func verifyProof(root common.Hash, key []byte, proof []string) []byte {
+	proofDB := memorydb.New()
+	for _, node := range proof {
+		nodeBytes, err := hexutil.Decode(node)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(proofDB.Put(crypto.Keccak256(nodeBytes), nodeBytes)).To(Succeed())
+	}
+	value, _, err := trie.VerifyProof(root, key, proofDB)
+	Expect(err).ToNot(HaveOccurred())
+	return value
+}
+
+var _ = Describe("GetProof", func() {
+	var (
+		db        *postgres.DB
+		backend   *eth.Backend
+		stateRoot common.Hash
+	)
+	BeforeEach(func() {
+		var err error
+		db, err = shared.SetupDB()
+		Expect(err).ToNot(HaveOccurred())
+		backend, err = eth.NewEthBackend(db)
+		Expect(err).ToNot(HaveOccurred())
+
+		// build a state with a contract account holding storage and a plain account
+		diskDB := rawdb.NewMemoryDatabase()
+		stateDB, err := state.New(common.Hash{}, state.NewDatabase(diskDB))
+		Expect(err).ToNot(HaveOccurred())
+		stateDB.SetBalance(proofAccount, big.NewInt(1000))
+		stateDB.SetNonce(proofAccount, 1)
+		stateDB.SetCode(proofAccount, []byte{1, 2, 3})
+		stateDB.SetState(proofAccount, proofStorageKey, proofStorageValue)
+		stateDB.SetBalance(proofAnotherAccount, big.NewInt(2000))
+		stateRoot, err = stateDB.Commit(true)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stateDB.Database().TrieDB().Commit(stateRoot, false)).To(Succeed())
+
+		// publish all of the resulting trie nodes into the blockstore
+		tx, err := db.Beginx()
+		Expect(err).ToNot(HaveOccurred())
+		it := diskDB.NewIterator()
+		for it.Next() {
+			if len(it.Key()) == common.HashLength {
+				_, err = shared.PublishRaw(tx, ipld.MEthStateTrie, multihash.KECCAK_256, common.CopyBytes(it.Value()))
+				Expect(err).ToNot(HaveOccurred())
+			}
+		}
+		it.Release()
+		Expect(tx.Commit()).To(Succeed())
+
+		// and index a block with the resulting state root
+		header := mocks.MockHeader
+		header.Root = stateRoot
+		payload := mocks.MockConvertedPayload
+		payload.Block = types.NewBlock(&header, mocks.MockTransactions, nil, mocks.MockReceipts)
+		_, err = eth.NewIPLDPublisherAndIndexer(db).Publish(payload)
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		eth.TearDownDB(db)
+	})
+
+	It("Proves an account and its storage", func() {
+		res, err := backend.GetProof(context.Background(), proofAccount, []string{proofStorageKey.Hex()}, rpc.BlockNumberOrHashWithNumber(1))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Address).To(Equal(proofAccount))
+		Expect(res.Balance.ToInt().Int64()).To(Equal(int64(1000)))
+		Expect(uint64(res.Nonce)).To(Equal(uint64(1)))
+		Expect(res.CodeHash).To(Equal(crypto.Keccak256Hash([]byte{1, 2, 3})))
+		Expect(verifyProof(stateRoot, crypto.Keccak256(proofAccount.Bytes()), res.AccountProof)).ToNot(BeNil())
+
+		Expect(len(res.StorageProof)).To(Equal(1))
+		Expect(res.StorageProof[0].Key).To(Equal(proofStorageKey.Hex()))
+		Expect(res.StorageProof[0].Value.ToInt().Int64()).To(Equal(int64(1337)))
+		Expect(verifyProof(res.StorageHash, crypto.Keccak256(proofStorageKey.Bytes()), res.StorageProof[0].Proof)).ToNot(BeNil())
+	})
+
+	It("Proves the absence of an account", func() {
+		res, err := backend.GetProof(context.Background(), proofMissingAccount, []string{proofStorageKey.Hex()}, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(res.Balance.ToInt().Int64()).To(Equal(int64(0)))
+		Expect(res.StorageHash).To(Equal(types.EmptyRootHash))
+		Expect(res.CodeHash).To(Equal(crypto.Keccak256Hash(nil)))
+		Expect(len(res.AccountProof)).ToNot(Equal(0))
+		Expect(verifyProof(stateRoot, crypto.Keccak256(proofMissingAccount.Bytes()), res.AccountProof)).To(BeNil())
+		Expect(res.StorageProof[0].Proof).To(BeEmpty())
+	})
+
+	It("Errors for blocks that are not indexed", func() {
+		_, err := backend.GetProof(context.Background(), proofAccount, nil, rpc.BlockNumberOrHashWithNumber(2))
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Inclusion proofs", func() {
+	var (
+		db      *postgres.DB
+		backend *eth.Backend
+	)
+	BeforeEach(func() {
+		var err error
+		db, err = shared.SetupDB()
+		Expect(err).ToNot(HaveOccurred())
+		backend, err = eth.NewEthBackend(db)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = eth.NewIPLDPublisherAndIndexer(db).Publish(mocks.MockConvertedPayload)
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		eth.TearDownDB(db)
+	})
+
+	It("Proves each transaction and receipt against the header", func() {
+		header := mocks.MockBlock.Header()
+		for i, trx := range mocks.MockTransactions {
+			txProof, err := backend.GetTransactionProof(context.Background(), header.Hash(), uint64(i))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(txProof.Root).To(Equal(header.TxHash))
+			Expect(uint64(txProof.BlockNumber)).To(Equal(header.Number.Uint64()))
+			provenTx, err := client.VerifyTransactionProof(header, txProof)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(provenTx.Hash()).To(Equal(trx.Hash()))
+
+			rctProof, err := backend.GetReceiptProof(context.Background(), header.Hash(), uint64(i))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rctProof.Root).To(Equal(header.ReceiptHash))
+			provenRct, err := client.VerifyReceiptProof(header, rctProof)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(provenRct.CumulativeGasUsed).To(Equal(mocks.MockReceipts[i].CumulativeGasUsed))
+			Expect(len(provenRct.Logs)).To(Equal(len(mocks.MockReceipts[i].Logs)))
+		}
+	})
+
+	It("Rejects a proof that has been tampered with", func() {
+		header := mocks.MockBlock.Header()
+		txProof, err := backend.GetTransactionProof(context.Background(), header.Hash(), 0)
+		Expect(err).ToNot(HaveOccurred())
+		txProof.Value = mocks.MockTransactions.GetRlp(1)
+		_, err = client.VerifyTransactionProof(header, txProof)
+		Expect(err).To(HaveOccurred())
+
+		otherHeader := mocks.MockHeader
+		otherHeader.Number = big.NewInt(2)
+		_, err = client.VerifyTransactionProof(&otherHeader, txProof)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Errors for indexes that are not in the block", func() {
+		_, err := backend.GetTransactionProof(context.Background(), mocks.MockBlock.Hash(), uint64(len(mocks.MockTransactions)))
+		Expect(err).To(HaveOccurred())
+		_, err = backend.GetReceiptProof(context.Background(), common.HexToHash("0x01"), 0)
+		Expect(err).To(HaveOccurred())
+	})
+})
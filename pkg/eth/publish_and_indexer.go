@@ -32,16 +32,19 @@ import (
 )
 
 // IPLDPublisherAndIndexer satisfies the IPLDPublisher interface for ethereum
-// It interfaces directly with the public.blocks table of PG-IPFS rather than going through an ipfs intermediary
+// It interfaces directly with the blockstores, by default the public.blocks table of PG-IPFS, rather than going through an ipfs intermediary
 // It publishes and indexes IPLDs together in a single sqlx.Tx
 type IPLDPublisherAndIndexer struct {
-	indexer *CIDIndexer
+	// Blockstores the IPLDs are published to, by type of data
+	Blockstores *shared.Blockstores
+	indexer     *CIDIndexer
 }
 
 // NewIPLDPublisherAndIndexer creates a pointer to a new IPLDPublisherAndIndexer which satisfies the IPLDPublisher interface
 func NewIPLDPublisherAndIndexer(db *postgres.DB) *IPLDPublisherAndIndexer {
 	return &IPLDPublisherAndIndexer{
-		indexer: NewCIDIndexer(db),
+		Blockstores: shared.NewPostgresBlockstores(),
+		indexer:     NewCIDIndexer(db),
 	}
 }
 
@@ -75,18 +78,18 @@ func (pub *IPLDPublisherAndIndexer) Publish(payload shared.ConvertedData) (share
 
 	// Publish trie nodes
 	for _, node := range txTrieNodes {
-		if err := shared.PublishIPLD(tx, node); err != nil {
+		if err := pub.Blockstores.PutIPLD(tx, shared.Transactions, node); err != nil {
 			return nil, err
 		}
 	}
 	for _, node := range rctTrieNodes {
-		if err := shared.PublishIPLD(tx, node); err != nil {
+		if err := pub.Blockstores.PutIPLD(tx, shared.Receipts, node); err != nil {
 			return nil, err
 		}
 	}
 
 	// Publish and index header
-	if err := shared.PublishIPLD(tx, headerNode); err != nil {
+	if err := pub.Blockstores.PutIPLD(tx, shared.Headers, headerNode); err != nil {
 		return nil, err
 	}
 	reward := CalcEthBlockReward(ipldPayload.Block.Header(), ipldPayload.Block.Uncles(), ipldPayload.Block.Transactions(), ipldPayload.Receipts)
@@ -104,6 +107,7 @@ func (pub *IPLDPublisherAndIndexer) Publish(payload shared.ConvertedData) (share
 		TxRoot:          ipldPayload.Block.TxHash().String(),
 		UncleRoot:       ipldPayload.Block.UncleHash().String(),
 		Timestamp:       ipldPayload.Block.Time(),
+		StateAbsent:     ipldPayload.StateAbsent,
 	}
 	headerID, err := pub.indexer.indexHeaderCID(tx, header)
 	if err != nil {
@@ -112,7 +116,7 @@ func (pub *IPLDPublisherAndIndexer) Publish(payload shared.ConvertedData) (share
 
 	// Publish and index uncles
 	for _, uncleNode := range uncleNodes {
-		if err := shared.PublishIPLD(tx, uncleNode); err != nil {
+		if err := pub.Blockstores.PutIPLD(tx, shared.Uncles, uncleNode); err != nil {
 			return nil, err
 		}
 		uncleReward := CalcUncleMinerReward(ipldPayload.Block.Number().Int64(), uncleNode.Number.Int64())
@@ -130,11 +134,11 @@ func (pub *IPLDPublisherAndIndexer) Publish(payload shared.ConvertedData) (share
 
 	// Publish and index txs and receipts
 	for i, txNode := range txNodes {
-		if err := shared.PublishIPLD(tx, txNode); err != nil {
+		if err := pub.Blockstores.PutIPLD(tx, shared.Transactions, txNode); err != nil {
 			return nil, err
 		}
 		rctNode := rctNodes[i]
-		if err := shared.PublishIPLD(tx, rctNode); err != nil {
+		if err := pub.Blockstores.PutIPLD(tx, shared.Receipts, rctNode); err != nil {
 			return nil, err
 		}
 		txModel := ipldPayload.TxMetaData[i]
@@ -147,7 +151,22 @@ func (pub *IPLDPublisherAndIndexer) Publish(payload shared.ConvertedData) (share
 		rctModel := ipldPayload.ReceiptMetaData[i]
 		rctModel.CID = rctNode.Cid().String()
 		rctModel.MhKey = shared.MultihashKeyFromCID(rctNode.Cid())
-		if err := pub.indexer.indexReceiptCID(tx, rctModel, txID); err != nil {
+		rctID, err := pub.indexer.indexReceiptCID(tx, rctModel, txID)
+		if err != nil {
+			return nil, err
+		}
+		logModels := make([]LogModel, 0, len(rctNode.Logs))
+		for _, l := range rctNode.Logs {
+			logNode, err := ipld.NewLog(l)
+			if err != nil {
+				return nil, err
+			}
+			if err := pub.Blockstores.PutIPLD(tx, shared.Receipts, logNode); err != nil {
+				return nil, err
+			}
+			logModels = append(logModels, NewLogModel(l, logNode.Cid().String(), shared.MultihashKeyFromCID(logNode.Cid())))
+		}
+		if err := pub.indexer.indexLogCIDs(tx, logModels, rctID); err != nil {
 			return nil, err
 		}
 	}
@@ -162,7 +181,7 @@ func (pub *IPLDPublisherAndIndexer) Publish(payload shared.ConvertedData) (share
 func (pub *IPLDPublisherAndIndexer) publishAndIndexStateAndStorage(tx *sqlx.Tx, ipldPayload ConvertedPayload, headerID int64) error {
 	// Publish and index state and storage
 	for _, stateNode := range ipldPayload.StateNodes {
-		stateCIDStr, err := shared.PublishRaw(tx, ipld.MEthStateTrie, multihash.KECCAK_256, stateNode.Value)
+		stateCIDStr, err := pub.Blockstores.PutRaw(tx, shared.State, ipld.MEthStateTrie, multihash.KECCAK_256, stateNode.Value)
 		if err != nil {
 			return err
 		}
@@ -201,7 +220,7 @@ func (pub *IPLDPublisherAndIndexer) publishAndIndexStateAndStorage(tx *sqlx.Tx,
 				return err
 			}
 			for _, storageNode := range ipldPayload.StorageNodes[common.Bytes2Hex(stateNode.Path)] {
-				storageCIDStr, err := shared.PublishRaw(tx, ipld.MEthStorageTrie, multihash.KECCAK_256, storageNode.Value)
+				storageCIDStr, err := pub.Blockstores.PutRaw(tx, shared.Storage, ipld.MEthStorageTrie, multihash.KECCAK_256, storageNode.Value)
 				if err != nil {
 					return err
 				}
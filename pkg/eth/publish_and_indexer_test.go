@@ -148,6 +148,39 @@ var _ = Describe("PublishAndIndexer", func() {
 			}
 		})
 
+		It("Publishes and indexes log IPLDs in a single tx", func() {
+			emptyReturn, err := repo.Publish(mocks.MockConvertedPayload)
+			Expect(emptyReturn).To(BeNil())
+			Expect(err).ToNot(HaveOccurred())
+			// check logs were properly indexed
+			logs := make([]eth.LogModel, 0)
+			pgStr := `SELECT log_cids.log_index, log_cids.cid, log_cids.mh_key, log_cids.address, log_cids.topic0, log_cids.topic1, log_cids.topic2, log_cids.topic3
+				FROM eth.log_cids, eth.receipt_cids, eth.transaction_cids, eth.header_cids
+				WHERE log_cids.receipt_id = receipt_cids.id
+				AND receipt_cids.tx_id = transaction_cids.id
+				AND transaction_cids.header_id = header_cids.id
+				AND header_cids.block_number = $1
+				ORDER BY log_cids.log_index`
+			err = db.Select(&logs, pgStr, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(logs).To(Equal([]eth.LogModel{
+				mocks.MockCIDPayload.LogCIDs[mocks.MockTransactions[0].Hash()][0],
+				mocks.MockCIDPayload.LogCIDs[mocks.MockTransactions[1].Hash()][0],
+			}))
+			// and published
+			for _, l := range logs {
+				var data []byte
+				err = db.Get(&data, ipfsPgGet, l.MhKey)
+				Expect(err).ToNot(HaveOccurred())
+				switch l.CID {
+				case mocks.Log1CID.String():
+					Expect(data).To(Equal(mocks.Log1Rlp))
+				case mocks.Log2CID.String():
+					Expect(data).To(Equal(mocks.Log2Rlp))
+				}
+			}
+		})
+
 		It("Publishes and indexes state IPLDs in a single tx", func() {
 			emptyReturn, err := repo.Publish(mocks.MockConvertedPayload)
 			Expect(emptyReturn).To(BeNil())
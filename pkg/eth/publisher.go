@@ -38,6 +38,7 @@ type IPLDPublisher struct {
 	TransactionTriePutter ipfs.DagPutter
 	ReceiptPutter         ipfs.DagPutter
 	ReceiptTriePutter     ipfs.DagPutter
+	LogPutter             ipfs.DagPutter
 	StatePutter           ipfs.DagPutter
 	StoragePutter         ipfs.DagPutter
 }
@@ -48,15 +49,29 @@ func NewIPLDPublisher(ipfsPath string) (*IPLDPublisher, error) {
 	if err != nil {
 		return nil, err
 	}
+	return newIPLDPublisher(node), nil
+}
+
+// NewRemoteIPLDPublisher creates a pointer to a new IPLDPublisher which publishes to the IPFS daemon at the given HTTP API address
+func NewRemoteIPLDPublisher(ipfsAPI string) (*IPLDPublisher, error) {
+	node, err := ipfs.InitRemoteIPFSNode(ipfsAPI)
+	if err != nil {
+		return nil, err
+	}
+	return newIPLDPublisher(node), nil
+}
+
+func newIPLDPublisher(node *ipfs.IPFS) *IPLDPublisher {
 	return &IPLDPublisher{
 		HeaderPutter:          dag_putters.NewEthBlockHeaderDagPutter(node),
 		TransactionPutter:     dag_putters.NewEthTxsDagPutter(node),
 		TransactionTriePutter: dag_putters.NewEthTxTrieDagPutter(node),
 		ReceiptPutter:         dag_putters.NewEthReceiptDagPutter(node),
 		ReceiptTriePutter:     dag_putters.NewEthRctTrieDagPutter(node),
+		LogPutter:             dag_putters.NewEthLogDagPutter(node),
 		StatePutter:           dag_putters.NewEthStateDagPutter(node),
 		StoragePutter:         dag_putters.NewEthStorageDagPutter(node),
-	}, nil
+	}
 }
 
 // Publish publishes an IPLDPayload to IPFS and returns the corresponding CIDPayload
@@ -91,6 +106,7 @@ func (pub *IPLDPublisher) Publish(payload shared.ConvertedData) (shared.CIDsForI
 		TxRoot:          ipldPayload.Block.TxHash().String(),
 		UncleRoot:       ipldPayload.Block.UncleHash().String(),
 		Timestamp:       ipldPayload.Block.Time(),
+		StateAbsent:     ipldPayload.StateAbsent,
 	}
 
 	// Process and publish uncles
@@ -122,6 +138,12 @@ func (pub *IPLDPublisher) Publish(payload shared.ConvertedData) (shared.CIDsForI
 		return nil, err
 	}
 
+	// Process and publish the individual logs
+	logCids, err := pub.publishLogs(rctNodes)
+	if err != nil {
+		return nil, err
+	}
+
 	// Process and publish state leafs
 	stateNodeCids, stateAccounts, err := pub.publishStateNodes(ipldPayload.StateNodes)
 	if err != nil {
@@ -140,6 +162,7 @@ func (pub *IPLDPublisher) Publish(payload shared.ConvertedData) (shared.CIDsForI
 		UncleCIDs:       uncleCids,
 		TransactionCIDs: transactionCids,
 		ReceiptCIDs:     receiptsCids,
+		LogCIDs:         logCids,
 		StateNodeCIDs:   stateNodeCids,
 		StorageNodeCIDs: storageNodeCids,
 		StateAccounts:   stateAccounts,
@@ -208,6 +231,46 @@ func (pub *IPLDPublisher) publishReceipts(receipts []*ipld.EthReceipt, receiptTr
 	return rctCids, nil
 }
 
+func (pub *IPLDPublisher) publishLogs(receipts []*ipld.EthReceipt) (map[common.Hash][]LogModel, error) {
+	logCids := make(map[common.Hash][]LogModel)
+	for _, rct := range receipts {
+		logCids[rct.TxHash] = make([]LogModel, 0, len(rct.Logs))
+		for _, l := range rct.Logs {
+			logNode, err := ipld.NewLog(l)
+			if err != nil {
+				return nil, err
+			}
+			cid, err := pub.LogPutter.DagPut(logNode)
+			if err != nil {
+				return nil, err
+			}
+			logCids[rct.TxHash] = append(logCids[rct.TxHash], NewLogModel(l, cid, shared.MultihashKeyFromCID(logNode.Cid())))
+		}
+	}
+	return logCids, nil
+}
+
+// NewLogModel creates the LogModel for indexing the provided log, missing topics are left empty
+func NewLogModel(l *types.Log, cid, mhKey string) LogModel {
+	topics := make([]string, 4)
+	for i, topic := range l.Topics {
+		if i > 3 {
+			break
+		}
+		topics[i] = topic.Hex()
+	}
+	return LogModel{
+		LogIndex: int64(l.Index),
+		CID:      cid,
+		MhKey:    mhKey,
+		Address:  l.Address.String(),
+		Topic0:   topics[0],
+		Topic1:   topics[1],
+		Topic2:   topics[2],
+		Topic3:   topics[3],
+	}
+}
+
 func (pub *IPLDPublisher) publishStateNodes(stateNodes []TrieNode) ([]StateNodeModel, map[string]StateAccountModel, error) {
 	stateNodeCids := make([]StateNodeModel, 0, len(stateNodes))
 	stateAccounts := make(map[string]StateAccountModel)
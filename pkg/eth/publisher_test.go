@@ -32,6 +32,7 @@ var (
 	mockTrxTrieDagPutter *mocks2.DagPutter
 	mockRctDagPutter     *mocks2.MappedDagPutter
 	mockRctTrieDagPutter *mocks2.DagPutter
+	mockLogDagPutter     *mocks2.DagPutter
 	mockStateDagPutter   *mocks2.MappedDagPutter
 	mockStorageDagPutter *mocks2.MappedDagPutter
 )
@@ -43,6 +44,7 @@ var _ = Describe("Publisher", func() {
 		mockTrxTrieDagPutter = new(mocks2.DagPutter)
 		mockRctDagPutter = new(mocks2.MappedDagPutter)
 		mockRctTrieDagPutter = new(mocks2.DagPutter)
+		mockLogDagPutter = new(mocks2.DagPutter)
 		mockStateDagPutter = new(mocks2.MappedDagPutter)
 		mockStorageDagPutter = new(mocks2.MappedDagPutter)
 	})
@@ -75,6 +77,7 @@ var _ = Describe("Publisher", func() {
 				TransactionTriePutter: mockTrxTrieDagPutter,
 				ReceiptPutter:         mockRctDagPutter,
 				ReceiptTriePutter:     mockRctTrieDagPutter,
+				LogPutter:             mockLogDagPutter,
 				StatePutter:           mockStateDagPutter,
 				StoragePutter:         mockStorageDagPutter,
 			}
@@ -96,6 +99,7 @@ var _ = Describe("Publisher", func() {
 			Expect(cidPayload.ReceiptCIDs[mocks.MockTransactions[0].Hash()]).To(Equal(mocks.MockCIDPayload.ReceiptCIDs[mocks.MockTransactions[0].Hash()]))
 			Expect(cidPayload.ReceiptCIDs[mocks.MockTransactions[1].Hash()]).To(Equal(mocks.MockCIDPayload.ReceiptCIDs[mocks.MockTransactions[1].Hash()]))
 			Expect(cidPayload.ReceiptCIDs[mocks.MockTransactions[2].Hash()]).To(Equal(mocks.MockCIDPayload.ReceiptCIDs[mocks.MockTransactions[2].Hash()]))
+			Expect(cidPayload.LogCIDs).To(Equal(mocks.MockCIDPayload.LogCIDs))
 			Expect(len(cidPayload.StateNodeCIDs)).To(Equal(2))
 			Expect(cidPayload.StateNodeCIDs[0]).To(Equal(mocks.MockCIDPayload.StateNodeCIDs[0]))
 			Expect(cidPayload.StateNodeCIDs[1]).To(Equal(mocks.MockCIDPayload.StateNodeCIDs[1]))
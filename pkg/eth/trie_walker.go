@@ -0,0 +1,325 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/statediff"
+	"github.com/ipfs/go-cid"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// WalkedTrieNode is a node of a trie reached by WalkTrie
+type WalkedTrieNode struct {
+	Path  []byte      // path of the node in nibbles, one per byte, as in state diffs
+	Hash  common.Hash // keccak256 hash of the node's rlp
+	Value []byte      // rlp of the node
+	Type  statediff.NodeType
+	// Embedded nodes are small enough to be held in their parent node rather than referenced by their hash
+	Embedded bool
+	// Full key and value of a leaf node
+	LeafKey   []byte
+	LeafValue []byte
+}
+
+// WalkHeaderTries walks the nodes of the transaction and receipt tries of the header, which are published but not indexed,
+// handing the blocks of the nodes to visit with their type of data; embedded nodes have no block of their own
+// get returns the data of the block with the cid; a block it returns no data for is skipped, along with the nodes below it
+func WalkHeaderTries(headerData []byte, get func(t shared.DataType, c cid.Cid) ([]byte, error), visit func(t shared.DataType, c cid.Cid, data []byte) error) error {
+	var header types.Header
+	if err := rlp.DecodeBytes(headerData, &header); err != nil {
+		return err
+	}
+	if err := walkTrieBlocks(header.TxHash, ipld.MEthTxTrie, shared.Transactions, get, visit); err != nil {
+		return err
+	}
+	return walkTrieBlocks(header.ReceiptHash, ipld.MEthTxReceiptTrie, shared.Receipts, get, visit)
+}
+
+func walkTrieBlocks(root common.Hash, codec uint64, t shared.DataType, get func(t shared.DataType, c cid.Cid) ([]byte, error), visit func(t shared.DataType, c cid.Cid, data []byte) error) error {
+	return WalkTrie(root, func(hash common.Hash) ([]byte, error) {
+		return get(t, ipld.Keccak256ToCid(codec, hash.Bytes()))
+	}, func(node WalkedTrieNode) (bool, error) {
+		if node.Embedded {
+			return true, nil
+		}
+		return true, visit(t, ipld.Keccak256ToCid(codec, node.Hash.Bytes()), node.Value)
+	})
+}
+
+// trieChild is a reference from a trie node to one of its children
+type trieChild struct {
+	path     []byte
+	hash     common.Hash
+	embedded []byte
+}
+
+// WalkTrie walks the trie below the root depth first, in path order, handing every node it reaches to visit, which
+// returns whether to walk on below the node
+// get returns the rlp of the node with the hash; a node it returns no rlp for is skipped, along with the nodes below it
+func WalkTrie(root common.Hash, get func(hash common.Hash) ([]byte, error), visit func(node WalkedTrieNode) (bool, error)) error {
+	if root == types.EmptyRootHash {
+		return nil
+	}
+	return walkTrie(trieChild{path: []byte{}, hash: root}, get, visit)
+}
+
+func walkTrie(ref trieChild, get func(hash common.Hash) ([]byte, error), visit func(node WalkedTrieNode) (bool, error)) error {
+	node := WalkedTrieNode{
+		Path:     ref.path,
+		Hash:     ref.hash,
+		Value:    ref.embedded,
+		Embedded: ref.embedded != nil,
+	}
+	if node.Embedded {
+		node.Hash = crypto.Keccak256Hash(node.Value)
+	} else {
+		var err error
+		if node.Value, err = get(ref.hash); err != nil {
+			return err
+		}
+		if node.Value == nil {
+			return nil
+		}
+	}
+	elements, err := decodeTrieNode(node.Value)
+	if err != nil {
+		return fmt.Errorf("trie node %s at path %x: %v", node.Hash.Hex(), node.Path, err)
+	}
+	var children []trieChild
+	switch len(elements) {
+	case 17:
+		node.Type = statediff.Branch
+		for i := 0; i < 16; i++ {
+			child, ok, err := trieChildRef(appendNibbles(node.Path, byte(i)), elements[i])
+			if err != nil {
+				return fmt.Errorf("trie node %s at path %x: %v", node.Hash.Hex(), node.Path, err)
+			}
+			if ok {
+				children = append(children, child)
+			}
+		}
+	case 2:
+		key, _, err := rlp.SplitString(elements[0])
+		if err != nil {
+			return fmt.Errorf("trie node %s at path %x: invalid key", node.Hash.Hex(), node.Path)
+		}
+		nibbles, leaf := compactToNibbles(key)
+		if leaf {
+			node.Type = statediff.Leaf
+			node.LeafKey = nibblesToBytes(appendNibbles(node.Path, nibbles...))
+			if node.LeafValue, err = trieNodeValue(elements[1]); err != nil {
+				return fmt.Errorf("trie node %s at path %x: invalid leaf value", node.Hash.Hex(), node.Path)
+			}
+			break
+		}
+		node.Type = statediff.Extension
+		child, ok, err := trieChildRef(appendNibbles(node.Path, nibbles...), elements[1])
+		if err != nil || !ok {
+			return fmt.Errorf("trie node %s at path %x: invalid extension", node.Hash.Hex(), node.Path)
+		}
+		children = append(children, child)
+	default:
+		return fmt.Errorf("trie node %s at path %x: unexpected number of elements %d", node.Hash.Hex(), node.Path, len(elements))
+	}
+	descend, err := visit(node)
+	if err != nil || !descend {
+		return err
+	}
+	for _, child := range children {
+		if err := walkTrie(child, get, visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trieChildRef returns the reference to the child in the raw rlp element, which is empty if there is no child,
+// a hash, or the child node itself if it is embedded
+func trieChildRef(path, element []byte) (trieChild, bool, error) {
+	kind, content, _, err := rlp.Split(element)
+	if err != nil {
+		return trieChild{}, false, err
+	}
+	if kind == rlp.List {
+		return trieChild{path: path, embedded: element}, true, nil
+	}
+	switch len(content) {
+	case 0:
+		return trieChild{}, false, nil
+	case common.HashLength:
+		return trieChild{path: path, hash: common.BytesToHash(content)}, true, nil
+	default:
+		return trieChild{}, false, fmt.Errorf("invalid child reference of %d bytes", len(content))
+	}
+}
+
+// nibblesToBytes packs an even number of nibbles back into bytes
+func nibblesToBytes(nibbles []byte) []byte {
+	b := make([]byte, len(nibbles)/2)
+	for i := range b {
+		b[i] = nibbles[2*i]<<4 | nibbles[2*i+1]
+	}
+	return b
+}
+
+// appendNibbles returns a new path of the nibbles appended to the path, so that paths are never shared between nodes
+func appendNibbles(path []byte, nibbles ...byte) []byte {
+	p := make([]byte, 0, len(path)+len(nibbles))
+	return append(append(p, path...), nibbles...)
+}
+
+// WalkTrieDiff walks the nodes of the trie with the root that differ from the node at the same path in the trie with the
+// parent root, the nodes a state diff between the two tries lists, handing them to visit like WalkTrie does
+// Below a node of the parent trie that get returns no rlp for the nodes can't be compared, so they are taken to differ
+func WalkTrieDiff(root, parentRoot common.Hash, get func(hash common.Hash) ([]byte, error), visit func(node WalkedTrieNode) (bool, error)) error {
+	parent := trieView{root: parentRoot, get: get}
+	return WalkTrie(root, get, func(node WalkedTrieNode) (bool, error) {
+		hash, ok, err := parent.hashAt(node.Path)
+		if err != nil {
+			return false, err
+		}
+		if ok && hash == node.Hash {
+			return false, nil
+		}
+		return visit(node)
+	})
+}
+
+// TrieLeafValue returns the value stored at the key in the trie with the root
+// ok is false if the key is not in the trie, or if get returns no rlp for one of the nodes on its path
+func TrieLeafValue(root common.Hash, key []byte, get func(hash common.Hash) ([]byte, error)) (value []byte, ok bool, err error) {
+	view := trieView{root: root, get: get}
+	nibbles := keyToNibbles(key)
+	err = view.walkPath(nibbles, func(pos int, elements [][]byte) (bool, error) {
+		if len(elements) != 2 {
+			return true, nil
+		}
+		compact, _, err := rlp.SplitString(elements[0])
+		if err != nil {
+			return false, err
+		}
+		rest, leaf := compactToNibbles(compact)
+		if !leaf {
+			return true, nil
+		}
+		if bytes.Equal(rest, nibbles[pos:]) {
+			value, err = trieNodeValue(elements[1])
+			ok = err == nil
+		}
+		return false, err
+	})
+	return value, ok, err
+}
+
+// TrieNodeHash returns the hash of the node at the path, in nibbles, in the trie with the root
+// ok is false if there is no node at the path, or if get returns no rlp for one of the nodes above it
+func TrieNodeHash(root common.Hash, path []byte, get func(hash common.Hash) ([]byte, error)) (hash common.Hash, ok bool, err error) {
+	return trieView{root: root, get: get}.hashAt(path)
+}
+
+// trieView looks up the nodes of a trie by their path
+type trieView struct {
+	root common.Hash
+	get  func(hash common.Hash) ([]byte, error)
+}
+
+// hashAt returns the hash of the node at the path
+// ok is false if there is no node at the path, or if get returns no rlp for one of the nodes above it
+func (v trieView) hashAt(path []byte) (hash common.Hash, ok bool, err error) {
+	if len(path) == 0 {
+		return v.root, v.root != types.EmptyRootHash && v.root != (common.Hash{}), nil
+	}
+	err = v.walkPath(path, func(pos int, elements [][]byte) (bool, error) {
+		child, found, err := pathChild(path[pos:], elements)
+		if err != nil || !found {
+			return false, err
+		}
+		if pos+len(child.path) == len(path) {
+			hash, ok = child.hash, true
+			if child.embedded != nil {
+				hash = crypto.Keccak256Hash(child.embedded)
+			}
+			return false, nil
+		}
+		return true, nil
+	})
+	return hash, ok, err
+}
+
+// walkPath follows the nibbles down from the root, handing the elements of every node on the way, and how many of the
+// nibbles lead up to it, to fn until fn returns false or the path ends
+func (v trieView) walkPath(nibbles []byte, fn func(pos int, elements [][]byte) (bool, error)) error {
+	if v.root == types.EmptyRootHash || v.root == (common.Hash{}) {
+		return nil
+	}
+	value, err := v.get(v.root)
+	if err != nil || value == nil {
+		return err
+	}
+	for pos := 0; ; {
+		elements, err := decodeTrieNode(value)
+		if err != nil {
+			return err
+		}
+		next, err := fn(pos, elements)
+		if err != nil || !next {
+			return err
+		}
+		child, ok, err := pathChild(nibbles[pos:], elements)
+		if err != nil || !ok {
+			return err
+		}
+		pos += len(child.path)
+		if value = child.embedded; value == nil {
+			if value, err = v.get(child.hash); err != nil || value == nil {
+				return err
+			}
+		}
+	}
+}
+
+// pathChild returns the child of the node with the elements that the nibbles lead to, with its path relative to the node
+func pathChild(nibbles []byte, elements [][]byte) (trieChild, bool, error) {
+	switch len(elements) {
+	case 17:
+		if len(nibbles) == 0 {
+			return trieChild{}, false, nil
+		}
+		return trieChildRef(nibbles[:1], elements[nibbles[0]])
+	case 2:
+		compact, _, err := rlp.SplitString(elements[0])
+		if err != nil {
+			return trieChild{}, false, err
+		}
+		key, leaf := compactToNibbles(compact)
+		if leaf || !bytes.HasPrefix(nibbles, key) {
+			return trieChild{}, false, nil
+		}
+		return trieChildRef(key, elements[1])
+	default:
+		return trieChild{}, false, fmt.Errorf("unexpected number of trie node elements %d", len(elements))
+	}
+}
@@ -0,0 +1,134 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package eth_test
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/statediff"
+	"github.com/ethereum/go-ethereum/trie"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+)
+
+// trieNodeHashes returns the hashes of the nodes of the trie that are referenced by hash, by path
+func trieNodeHashes(tr *trie.Trie) map[string]common.Hash {
+	hashes := make(map[string]common.Hash)
+	it := tr.NodeIterator(nil)
+	for it.Next(true) {
+		if it.Hash() != (common.Hash{}) {
+			hashes[string(it.Path())] = it.Hash()
+		}
+	}
+	return hashes
+}
+
+var _ = Describe("Trie walker", func() {
+	var (
+		trieDB     *trie.Database
+		tr         *trie.Trie
+		root       common.Hash
+		parentRoot common.Hash
+		parent     map[string]common.Hash
+		get        func(hash common.Hash) ([]byte, error)
+	)
+	BeforeEach(func() {
+		trieDB = trie.NewDatabase(memorydb.New())
+		var err error
+		tr, err = trie.New(common.Hash{}, trieDB)
+		Expect(err).ToNot(HaveOccurred())
+		for i := byte(0); i < 100; i++ {
+			Expect(tr.TryUpdate(crypto.Keccak256([]byte{i}), []byte{i, i, i})).To(Succeed())
+		}
+		parentRoot, err = tr.Commit(nil)
+		Expect(err).ToNot(HaveOccurred())
+		parent = trieNodeHashes(tr)
+		for i := byte(0); i < 5; i++ {
+			Expect(tr.TryUpdate(crypto.Keccak256([]byte{i}), []byte{i})).To(Succeed())
+		}
+		Expect(tr.TryUpdate(crypto.Keccak256([]byte{200}), []byte{200})).To(Succeed())
+		root, err = tr.Commit(nil)
+		Expect(err).ToNot(HaveOccurred())
+		get = func(hash common.Hash) ([]byte, error) {
+			return trieDB.Node(hash)
+		}
+	})
+
+	It("Walks every node of the trie with its path", func() {
+		visited := make(map[string]common.Hash)
+		leaves := 0
+		Expect(eth.WalkTrie(root, get, func(node eth.WalkedTrieNode) (bool, error) {
+			Expect(crypto.Keccak256Hash(node.Value)).To(Equal(node.Hash))
+			if node.Type == statediff.Leaf {
+				leaves++
+				value, err := tr.TryGet(node.LeafKey)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(node.LeafValue).To(Equal(value))
+			}
+			if !node.Embedded {
+				visited[string(node.Path)] = node.Hash
+			}
+			return true, nil
+		})).To(Succeed())
+		Expect(visited).To(Equal(trieNodeHashes(tr)))
+		Expect(leaves).To(Equal(101))
+	})
+
+	It("Skips the nodes below a node that get returns no rlp for", func() {
+		visited := 0
+		Expect(eth.WalkTrie(root, func(hash common.Hash) ([]byte, error) {
+			if hash == root {
+				return trieDB.Node(hash)
+			}
+			return nil, nil
+		}, func(node eth.WalkedTrieNode) (bool, error) {
+			visited++
+			return true, nil
+		})).To(Succeed())
+		Expect(visited).To(Equal(1))
+	})
+
+	It("Walks the nodes that differ from the parent trie", func() {
+		expected := make(map[string]common.Hash)
+		for path, hash := range trieNodeHashes(tr) {
+			if parent[path] != hash {
+				expected[path] = hash
+			}
+		}
+		visited := make(map[string]common.Hash)
+		Expect(eth.WalkTrieDiff(root, parentRoot, get, func(node eth.WalkedTrieNode) (bool, error) {
+			if !node.Embedded {
+				visited[string(node.Path)] = node.Hash
+			}
+			return true, nil
+		})).To(Succeed())
+		Expect(visited).To(Equal(expected))
+	})
+
+	It("Looks up the value of a key", func() {
+		value, ok, err := eth.TrieLeafValue(parentRoot, crypto.Keccak256([]byte{1}), get)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(value).To(Equal([]byte{1, 1, 1}))
+		_, ok, err = eth.TrieLeafValue(parentRoot, crypto.Keccak256([]byte{200}), get)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ok).To(BeFalse())
+	})
+})
@@ -37,6 +37,7 @@ type ConvertedPayload struct {
 	ReceiptMetaData []ReceiptModel
 	StateNodes      []TrieNode
 	StorageNodes    map[string][]TrieNode
+	StateAbsent     bool // true if the payload was not derived from a state diff, so the state and storage nodes are missing
 }
 
 // Height satisfies the StreamedIPLDs interface
@@ -60,6 +61,7 @@ type CIDPayload struct {
 	UncleCIDs       []UncleModel
 	TransactionCIDs []TxModel
 	ReceiptCIDs     map[common.Hash]ReceiptModel
+	LogCIDs         map[common.Hash][]LogModel
 	StateNodeCIDs   []StateNodeModel
 	StateAccounts   map[string]StateAccountModel
 	StorageNodeCIDs map[string][]StorageNodeModel
@@ -0,0 +1,173 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package export
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/blockstore"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/config"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/node"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+	"github.com/vulcanize/ipfs-blockchain-watcher/utils"
+)
+
+// Env variables
+const (
+	EXPORT_CHAIN      = "EXPORT_CHAIN"
+	EXPORT_START      = "EXPORT_START"
+	EXPORT_STOP       = "EXPORT_STOP"
+	EXPORT_DATA_TYPES = "EXPORT_DATA_TYPES"
+	EXPORT_OUTPUT     = "EXPORT_OUTPUT"
+	EXPORT_MANIFEST   = "EXPORT_MANIFEST"
+	EXPORT_BATCH_SIZE = "EXPORT_BATCH_SIZE"
+)
+
+// DefaultBatchSize is the default number of block heights whose blocks are fetched at a time
+const DefaultBatchSize = 100
+
+// Config holds the parameters needed to export a range of data to a CAR file
+type Config struct {
+	Chain     shared.ChainType  // The chain to export the data of
+	DataTypes []shared.DataType // The types of data to export; the headers are always exported, as they are the roots
+	Start     uint64            // The first block height to export
+	Stop      uint64            // The last block height to export
+	Output    string            // Path of the CAR file to write
+	Manifest  string            // Path of the manifest of the CIDs written to the CAR file
+	BatchSize uint64            // Number of block heights whose blocks are fetched at a time
+
+	// DB info
+	DB          *postgres.DB
+	DBConfig    config.Database
+	IPFSPath    string
+	IPFSMode    shared.IPFSMode
+	Blockstores *shared.Blockstores // The blockstores used in DirectPostgres mode
+}
+
+// NewConfig fills and returns an export config from toml parameters
+func NewConfig() (*Config, error) {
+	c := new(Config)
+	var err error
+
+	viper.BindEnv("export.chain", EXPORT_CHAIN)
+	viper.BindEnv("export.start", EXPORT_START)
+	viper.BindEnv("export.stop", EXPORT_STOP)
+	viper.BindEnv("export.dataTypes", EXPORT_DATA_TYPES)
+	viper.BindEnv("export.output", EXPORT_OUTPUT)
+	viper.BindEnv("export.manifest", EXPORT_MANIFEST)
+	viper.BindEnv("export.batchSize", EXPORT_BATCH_SIZE)
+
+	c.Chain, err = shared.NewChainType(viper.GetString("export.chain"))
+	if err != nil {
+		return nil, err
+	}
+	c.Start = uint64(viper.GetInt64("export.start"))
+	c.Stop = uint64(viper.GetInt64("export.stop"))
+	if c.Stop < c.Start {
+		return nil, fmt.Errorf("export stop height %d is below the start height %d", c.Stop, c.Start)
+	}
+	c.DataTypes, err = ExportDataTypes(c.Chain, viper.GetStringSlice("export.dataTypes"))
+	if err != nil {
+		return nil, err
+	}
+	c.Output = viper.GetString("export.output")
+	if c.Output == "" {
+		return nil, fmt.Errorf("no export output file provided")
+	}
+	c.Manifest = viper.GetString("export.manifest")
+	if c.Manifest == "" {
+		c.Manifest = ManifestPath(c.Output)
+	}
+	c.BatchSize = uint64(viper.GetInt64("export.batchSize"))
+
+	c.IPFSMode, err = shared.GetIPFSMode()
+	if err != nil {
+		return nil, err
+	}
+	if c.IPFSMode == shared.LocalInterface {
+		c.IPFSPath, err = shared.GetIPFSPath()
+		if err != nil {
+			return nil, err
+		}
+	}
+	// in remote mode the ipfs path is the address of the daemon's HTTP API
+	if c.IPFSMode == shared.RemoteClient {
+		c.IPFSPath = shared.GetIPFSAPI()
+	}
+	if c.IPFSMode == shared.DirectPostgres {
+		c.Blockstores, err = blockstore.NewBlockstores()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.DBConfig.Init()
+	db := utils.LoadPostgres(c.DBConfig, node.Node{})
+	c.DB = &db
+	return c, nil
+}
+
+// ExportDataTypes returns the types of data of the chain to export for the provided type names, in export order
+// The headers are always included and the full type, or no types at all, stands for every type of data
+func ExportDataTypes(chain shared.ChainType, names []string) ([]shared.DataType, error) {
+	chainTypes, err := shared.ChainDataTypes(chain)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return chainTypes, nil
+	}
+	selected := map[shared.DataType]bool{shared.Headers: true}
+	for _, name := range names {
+		dataType, err := shared.GenerateDataTypeFromString(name)
+		if err != nil {
+			return nil, err
+		}
+		if dataType == shared.Full {
+			return chainTypes, nil
+		}
+		if !supports(chainTypes, dataType) {
+			return nil, fmt.Errorf("chain type %s does not support data type %s", chain.String(), dataType.String())
+		}
+		selected[dataType] = true
+	}
+	types := make([]shared.DataType, 0, len(selected))
+	for _, dataType := range chainTypes {
+		if selected[dataType] {
+			types = append(types, dataType)
+		}
+	}
+	return types, nil
+}
+
+func supports(chainTypes []shared.DataType, dataType shared.DataType) bool {
+	for _, t := range chainTypes {
+		if t == dataType {
+			return true
+		}
+	}
+	return false
+}
+
+// ManifestPath returns the default path of the manifest of the CAR file at the output path
+func ManifestPath(output string) string {
+	return strings.TrimSuffix(output, ".car") + ".manifest.csv"
+}
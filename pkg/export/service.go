@@ -0,0 +1,264 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/ipfs/go-cid"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/builders"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/car"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// Service writes the IPLDs of a range of data to a CAR file, with the headers as its roots
+type Service struct {
+	db     *postgres.DB
+	chain  shared.ChainType
+	source shared.BlockSource
+	// Queries for the IPLDs to export, one per type of data
+	pgStrs    map[shared.DataType]string
+	dataTypes []shared.DataType
+	start     uint64
+	stop      uint64
+	output    string
+	manifest  string
+	batchSize uint64
+}
+
+// NewService creates and returns an export service from the provided settings
+func NewService(settings *Config) (*Service, error) {
+	pgStrs := make(map[shared.DataType]string, len(settings.DataTypes))
+	for _, dataType := range settings.DataTypes {
+		cidsPgStr, err := builders.CIDsPgStr(settings.Chain, dataType)
+		if err != nil {
+			return nil, err
+		}
+		pgStrs[dataType] = cidsPgStr + ` ORDER BY block_number`
+	}
+	source, err := shared.NewBlockSource(settings.IPFSMode, settings.IPFSPath, settings.Blockstores, nil)
+	if err != nil {
+		return nil, fmt.Errorf("export: %v", err)
+	}
+	batchSize := settings.BatchSize
+	if batchSize == 0 {
+		batchSize = DefaultBatchSize
+	}
+	manifest := settings.Manifest
+	if manifest == "" {
+		manifest = ManifestPath(settings.Output)
+	}
+	return &Service{
+		db:        settings.DB,
+		chain:     settings.Chain,
+		source:    source,
+		pgStrs:    pgStrs,
+		dataTypes: settings.DataTypes,
+		start:     settings.Start,
+		stop:      settings.Stop,
+		output:    settings.Output,
+		manifest:  manifest,
+		batchSize: batchSize,
+	}, nil
+}
+
+// Export writes the CAR file and its manifest
+// The files are removed again if the export fails, so that a partial export is never mistaken for a complete one
+func (s *Service) Export() (err error) {
+	var roots []string
+	pgStr := `SELECT cid FROM ` + s.chain.API() + `.header_cids WHERE block_number BETWEEN $1 AND $2 ORDER BY block_number, id`
+	if err := s.db.Select(&roots, pgStr, s.start, s.stop); err != nil {
+		return err
+	}
+	if len(roots) == 0 {
+		return fmt.Errorf("no %s headers found at heights %d to %d", s.chain.String(), s.start, s.stop)
+	}
+	rootCIDs := make([]cid.Cid, len(roots))
+	for i, root := range roots {
+		if rootCIDs[i], err = cid.Decode(root); err != nil {
+			return err
+		}
+	}
+
+	carFile, err := os.Create(s.output)
+	if err != nil {
+		return err
+	}
+	manifestFile, err := os.Create(s.manifest)
+	if err != nil {
+		carFile.Close()
+		os.Remove(s.output)
+		return err
+	}
+	defer func() {
+		if closeErr := carFile.Close(); err == nil {
+			err = closeErr
+		}
+		if closeErr := manifestFile.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(s.output)
+			os.Remove(s.manifest)
+		}
+	}()
+
+	carWriter, err := car.NewWriter(carFile, rootCIDs)
+	if err != nil {
+		return err
+	}
+	w := &exportWriter{
+		car:      carWriter,
+		manifest: csv.NewWriter(manifestFile),
+		written:  make(map[string]bool),
+	}
+	if err := w.manifest.Write([]string{"block_number", "data_type", "cid"}); err != nil {
+		return err
+	}
+	for start := s.start; start <= s.stop; start += s.batchSize {
+		stop := start + s.batchSize - 1
+		if stop > s.stop || stop < start {
+			stop = s.stop
+		}
+		exported, err := s.exportRange(w, start, stop)
+		if err != nil {
+			return err
+		}
+		logrus.Infof("exported %d %s blocks at heights %d to %d", exported, s.chain.String(), start, stop)
+		if stop == s.stop {
+			break
+		}
+	}
+	if err := carWriter.Flush(); err != nil {
+		return err
+	}
+	w.manifest.Flush()
+	if err := w.manifest.Error(); err != nil {
+		return err
+	}
+	logrus.Infof("exported %d %s blocks at heights %d to %d to %s", w.count, s.chain.String(), s.start, s.stop, s.output)
+	return nil
+}
+
+// exportWriter writes the blocks to the CAR file and lists them in the manifest
+type exportWriter struct {
+	car      *car.Writer
+	manifest *csv.Writer
+	// IPLDs can be referenced from more than one index row, e.g. storage nodes shared between contracts, and are only
+	// written once
+	written map[string]bool
+	count   int
+}
+
+func (w *exportWriter) put(blockNumber uint64, t shared.DataType, c cid.Cid, mhKey string, data []byte) error {
+	if w.written[mhKey] {
+		return nil
+	}
+	if err := w.car.Put(c, data); err != nil {
+		return err
+	}
+	if err := w.manifest.Write([]string{strconv.FormatUint(blockNumber, 10), t.String(), c.String()}); err != nil {
+		return err
+	}
+	w.written[mhKey] = true
+	w.count++
+	return nil
+}
+
+// exportRange writes the IPLDs of the data at the block heights from start to stop
+func (s *Service) exportRange(w *exportWriter, start, stop uint64) (exported int, err error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	count := w.count
+	for _, dataType := range s.dataTypes {
+		var iplds []builders.IndexedIPLD
+		if err = tx.Select(&iplds, s.pgStrs[dataType], start, stop); err != nil {
+			return 0, err
+		}
+		for _, ipld := range iplds {
+			if w.written[ipld.MhKey] {
+				continue
+			}
+			var c cid.Cid
+			if c, err = cid.Decode(ipld.CID); err != nil {
+				return 0, err
+			}
+			var data []byte
+			if data, err = s.source.Get(tx, dataType, c, ipld.MhKey); err != nil {
+				return 0, fmt.Errorf("%s block %s at height %d: %v", dataType.String(), ipld.CID, ipld.BlockNumber, err)
+			}
+			if err = w.put(ipld.BlockNumber, dataType, c, ipld.MhKey, data); err != nil {
+				return 0, err
+			}
+			if dataType == shared.Headers {
+				if err = s.exportTries(tx, w, ipld.BlockNumber, data); err != nil {
+					return 0, err
+				}
+			}
+		}
+	}
+	return w.count - count, nil
+}
+
+// exportTries writes the blocks that hang off of the header and are published but not indexed, the nodes of the tries
+// (merkle tree for btc) that order the transactions and receipts, which tell an import which transactions and receipts
+// belong to the header and in which order
+func (s *Service) exportTries(tx *sqlx.Tx, w *exportWriter, blockNumber uint64, headerData []byte) error {
+	return builders.WalkUnindexedBlocks(s.chain, headerData, func(t shared.DataType, c cid.Cid) ([]byte, error) {
+		mhKey := shared.MultihashKeyFromCID(c)
+		// a trie that has been written before, e.g. the identical receipt trie of another block, is not walked again
+		if !s.exports(t) || w.written[mhKey] {
+			return nil, nil
+		}
+		data, err := s.source.Get(tx, t, c, mhKey)
+		if err != nil {
+			return nil, fmt.Errorf("%s trie node %s at height %d: %v", t.String(), c.String(), blockNumber, err)
+		}
+		return data, nil
+	}, func(t shared.DataType, c cid.Cid, data []byte) error {
+		return w.put(blockNumber, t, c, shared.MultihashKeyFromCID(c), data)
+	})
+}
+
+// exports returns whether the type of data is exported
+func (s *Service) exports(t shared.DataType) bool {
+	for _, dataType := range s.dataTypes {
+		if dataType == t {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,236 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package export_test
+
+import (
+	"encoding/csv"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/ipfs/go-cid"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/car"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/carimport"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/export"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+var blockNumber = mocks.BlockNumber.Uint64()
+
+var _ = Describe("Export", func() {
+	var (
+		db     *postgres.DB
+		dir    string
+		output string
+	)
+	BeforeEach(func() {
+		var err error
+		db = mocks.SetupIndexedDB()
+		dir, err = ioutil.TempDir("", "export")
+		Expect(err).ToNot(HaveOccurred())
+		output = filepath.Join(dir, "export.car")
+	})
+	AfterEach(func() {
+		eth.TearDownDB(db)
+		os.RemoveAll(dir)
+	})
+
+	newService := func(start, stop uint64, dataTypes ...string) *export.Service {
+		types, err := export.ExportDataTypes(shared.Ethereum, dataTypes)
+		Expect(err).ToNot(HaveOccurred())
+		service, err := export.NewService(&export.Config{
+			Chain:     shared.Ethereum,
+			DataTypes: types,
+			Start:     start,
+			Stop:      stop,
+			Output:    output,
+			DB:        db,
+			IPFSMode:  shared.DirectPostgres,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		return service
+	}
+	readCAR := func(path string) (car.Header, map[cid.Cid][]byte) {
+		f, err := os.Open(path)
+		Expect(err).ToNot(HaveOccurred())
+		defer f.Close()
+		r, err := car.NewReader(f)
+		Expect(err).ToNot(HaveOccurred())
+		blocks := make(map[cid.Cid][]byte)
+		for {
+			c, data, err := r.Next()
+			if err == io.EOF {
+				break
+			}
+			Expect(err).ToNot(HaveOccurred())
+			blocks[c] = data
+		}
+		return r.Header(), blocks
+	}
+	readManifest := func(path string) [][]string {
+		f, err := os.Open(export.ManifestPath(path))
+		Expect(err).ToNot(HaveOccurred())
+		defer f.Close()
+		records, err := csv.NewReader(f).ReadAll()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(records[0]).To(Equal([]string{"block_number", "data_type", "cid"}))
+		return records[1:]
+	}
+	expectNoOutput := func() {
+		_, err := os.Stat(output)
+		Expect(os.IsNotExist(err)).To(BeTrue())
+		_, err = os.Stat(export.ManifestPath(output))
+		Expect(os.IsNotExist(err)).To(BeTrue())
+	}
+
+	It("Writes the blocks of the range to a CAR file with the headers as its roots", func() {
+		Expect(newService(blockNumber, blockNumber).Export()).To(Succeed())
+		header, blocks := readCAR(output)
+		Expect(header.Roots).To(Equal([]cid.Cid{mocks.HeaderCID}))
+		Expect(blocks[mocks.HeaderCID]).To(Equal(mocks.MockIPLDs.Header.Data))
+		Expect(blocks[mocks.Trx1CID]).To(Equal(mocks.MockIPLDs.Transactions[0].Data))
+		Expect(blocks[mocks.Rct1CID]).To(Equal(mocks.MockIPLDs.Receipts[0].Data))
+		Expect(blocks).To(HaveKey(mocks.State1CID))
+		Expect(blocks).To(HaveKey(mocks.State2CID))
+		Expect(blocks).To(HaveKey(mocks.StorageCID))
+		// the roots of the transaction and receipt tries, which tie them to the header
+		Expect(blocks).To(HaveKey(mocks.TxTrieCID))
+		Expect(blocks).To(HaveKey(mocks.RctTrieCID))
+
+		manifest := readManifest(output)
+		Expect(manifest).To(HaveLen(len(blocks)))
+		Expect(manifest[0]).To(Equal([]string{mocks.BlockNumber.String(), shared.Headers.String(), mocks.HeaderCID.String()}))
+		for _, record := range manifest {
+			c, err := cid.Decode(record[2])
+			Expect(err).ToNot(HaveOccurred())
+			Expect(blocks).To(HaveKey(c))
+		}
+	})
+
+	table.DescribeTable("Only writes the blocks of the selected types of data and the headers",
+		func(dataType string, expected []cid.Cid) {
+			Expect(newService(blockNumber, blockNumber, dataType).Export()).To(Succeed())
+			_, blocks := readCAR(output)
+			Expect(blocks).To(HaveLen(len(expected) + 1))
+			Expect(blocks).To(HaveKey(mocks.HeaderCID))
+			for _, c := range expected {
+				Expect(blocks).To(HaveKey(c))
+			}
+			Expect(readManifest(output)).To(HaveLen(len(expected) + 1))
+		},
+		table.Entry("state", "state", []cid.Cid{mocks.State1CID, mocks.State2CID}),
+		table.Entry("storage", "storage", []cid.Cid{mocks.StorageCID}),
+	)
+
+	table.DescribeTable("Fails without leaving files behind",
+		func(start, stop uint64, missing string) {
+			if missing != "" {
+				_, err := db.Exec(`DELETE FROM public.blocks WHERE key = $1`, missing)
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(newService(start, stop).Export()).ToNot(Succeed())
+			expectNoOutput()
+		},
+		table.Entry("if there are no headers in the range", uint64(0), uint64(0), ""),
+		table.Entry("if an indexed block is missing", blockNumber, blockNumber, mocks.State1MhKey),
+		table.Entry("if a trie node is missing", blockNumber, blockNumber, shared.MultihashKeyFromCID(mocks.TxTrieCID)),
+	)
+
+	Describe("Round trip", func() {
+		importFile := func(path string) error {
+			parentTD := new(big.Int).Sub(mocks.MockConvertedPayload.TotalDifficulty, mocks.MockBlock.Difficulty())
+			service, err := carimport.NewImportService(&carimport.Config{
+				Chain:    shared.Ethereum,
+				Files:    []string{path},
+				ParentTD: parentTD,
+				DB:       db,
+				IPFSMode: shared.DirectPostgres,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			return service.Import()
+		}
+
+		It("Exports the same blocks again after importing the file into an empty database", func() {
+			// the state nodes of the mocks don't hash into the state root of the header, so an import can't index them
+			Expect(newService(blockNumber, blockNumber, "transactions", "receipts").Export()).To(Succeed())
+			exported := output
+			header, blocks := readCAR(exported)
+			manifest := readManifest(exported)
+			eth.TearDownDB(db)
+
+			Expect(importFile(exported)).To(Succeed())
+			output = filepath.Join(dir, "reexport.car")
+			Expect(newService(blockNumber, blockNumber, "transactions", "receipts").Export()).To(Succeed())
+			reexportedHeader, reexported := readCAR(output)
+			Expect(reexportedHeader).To(Equal(header))
+			Expect(reexported).To(Equal(blocks))
+			Expect(readManifest(output)).To(ConsistOf(manifest))
+		})
+
+		It("Doesn't import a file with blocks missing from the header's tries", func() {
+			Expect(newService(blockNumber, blockNumber).Export()).To(Succeed())
+			header, blocks := readCAR(output)
+			delete(blocks, mocks.TxTrieCID)
+			incomplete := filepath.Join(dir, "incomplete.car")
+			f, err := os.Create(incomplete)
+			Expect(err).ToNot(HaveOccurred())
+			w, err := car.NewWriter(f, header.Roots)
+			Expect(err).ToNot(HaveOccurred())
+			for c, data := range blocks {
+				Expect(w.Put(c, data)).To(Succeed())
+			}
+			Expect(w.Flush()).To(Succeed())
+			Expect(f.Close()).To(Succeed())
+			eth.TearDownDB(db)
+
+			err = importFile(incomplete)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("is not in the CAR file"))
+			var headers int
+			Expect(db.Get(&headers, `SELECT COUNT(*) FROM eth.header_cids`)).To(Succeed())
+			Expect(headers).To(Equal(0))
+		})
+	})
+})
+
+var _ = Describe("ExportDataTypes", func() {
+	It("Always includes the headers, in export order", func() {
+		types, err := export.ExportDataTypes(shared.Ethereum, []string{"storage", "state"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(types).To(Equal([]shared.DataType{shared.Headers, shared.State, shared.Storage}))
+	})
+
+	It("Expands full to every type of data of the chain", func() {
+		types, err := export.ExportDataTypes(shared.Bitcoin, []string{"full"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(types).To(Equal([]shared.DataType{shared.Headers, shared.Transactions}))
+	})
+
+	It("Rejects types of data the chain does not have", func() {
+		_, err := export.ExportDataTypes(shared.Bitcoin, []string{"state"})
+		Expect(err).To(HaveOccurred())
+	})
+})
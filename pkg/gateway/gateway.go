@@ -0,0 +1,249 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-format"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// Media types of the responses, as served by IPFS gateways
+const (
+	RawMediaType     = "application/vnd.ipld.raw"
+	DagJSONMediaType = "application/vnd.ipld.dag-json"
+)
+
+// statusError is an error answered with an HTTP status other than 500
+type statusError struct {
+	status int
+	msg    string
+}
+
+func (e statusError) Error() string {
+	return e.msg
+}
+
+func errorf(status int, format string, args ...interface{}) error {
+	return statusError{status: status, msg: fmt.Sprintf(format, args...)}
+}
+
+// Gateway is an http.Handler that serves IPLD blocks by their CID, and the values IPLD paths resolve to
+// GET /<cid> serves the block with the cid, and GET /<cid>/<path> resolves the path from it across the links of the
+// blocks, e.g. /<eth header cid>/tx/8/0/value or /<state trie node cid>/<nibble>/<nibble>/...
+// The response is the raw block, or the dag-json of the block or value, as selected by the format query parameter
+// (raw or dag-json) or the Accept header; without either, blocks are served raw and paths as dag-json
+type Gateway struct {
+	// only set in DirectPostgres mode, whose blockstores read within a db tx
+	db     *postgres.DB
+	source shared.BlockSource
+}
+
+// NewGateway creates and returns a gateway backed by the blockstore of the ipfs mode
+// In RemoteClient mode the ipfsPath is the address of the IPFS daemon's HTTP API
+// In DirectPostgres mode the blocks are read from the blockstores, or from Postgres if blockstores is nil
+func NewGateway(ipfsPath string, db *postgres.DB, ipfsMode shared.IPFSMode, blockstores *shared.Blockstores) (*Gateway, error) {
+	source, err := shared.NewBlockSource(ipfsMode, ipfsPath, blockstores, nil)
+	if err != nil {
+		return nil, fmt.Errorf("gateway: %v", err)
+	}
+	if ipfsMode != shared.DirectPostgres {
+		db = nil
+	}
+	return &Gateway{db: db, source: source}, nil
+}
+
+// StartHTTPEndpoint starts serving the gateway on the endpoint, and returns the listener it is served on
+func StartHTTPEndpoint(endpoint string, gateway *Gateway) (net.Listener, error) {
+	listener, err := net.Listen("tcp", endpoint)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Infof("IPLD gateway started: http://%s", listener.Addr().String())
+	go func() {
+		if err := http.Serve(listener, gateway); err != nil {
+			logrus.Errorf("IPLD gateway at %s stopped: %v", endpoint, err)
+		}
+	}()
+	return listener, nil
+}
+
+// ServeHTTP satisfies the http.Handler interface
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := g.serve(w, r); err != nil {
+		if statusErr, ok := err.(statusError); ok {
+			http.Error(w, statusErr.msg, statusErr.status)
+			return
+		}
+		logrus.Errorf("IPLD gateway request %s failed: %v", r.URL.Path, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (g *Gateway) serve(w http.ResponseWriter, r *http.Request) (err error) {
+	var segments []string
+	for _, segment := range strings.Split(r.URL.Path, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	if len(segments) == 0 {
+		return errorf(http.StatusBadRequest, "no cid provided")
+	}
+	root, err := cid.Decode(segments[0])
+	if err != nil {
+		return errorf(http.StatusBadRequest, "invalid cid %s: %v", segments[0], err)
+	}
+	path := segments[1:]
+	format, err := responseFormat(r, len(path) > 0)
+	if err != nil {
+		return err
+	}
+	var tx *sqlx.Tx
+	if g.db != nil {
+		if tx, err = g.db.Beginx(); err != nil {
+			return err
+		}
+		defer shared.Rollback(tx)
+	}
+
+	var body []byte
+	if format == RawMediaType && len(path) == 0 {
+		// the block is served without decoding it, so that blocks of every codec can be served
+		if body, err = g.fetch(tx, root); err != nil {
+			return err
+		}
+	} else {
+		value, err := g.resolve(tx, root, path)
+		if err != nil {
+			return err
+		}
+		if format == RawMediaType {
+			block, ok := value.(node.Node)
+			if !ok {
+				return errorf(http.StatusNotAcceptable, "/%s does not resolve to a block, it can only be served as dag-json", strings.Join(segments, "/"))
+			}
+			body = block.RawData()
+		} else if body, err = json.Marshal(value); err != nil {
+			return err
+		}
+	}
+	w.Header().Set("Content-Type", format)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	// the response is addressed by the root cid, and never changes
+	w.Header().Set("Cache-Control", "public, max-age=29030400, immutable")
+	_, err = w.Write(body)
+	return err
+}
+
+// responseFormat returns the media type of the response to the request
+func responseFormat(r *http.Request, hasPath bool) (string, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "raw":
+		return RawMediaType, nil
+	case "dag-json", "json":
+		return DagJSONMediaType, nil
+	case "":
+	default:
+		return "", errorf(http.StatusBadRequest, "unsupported format %s, options are raw and dag-json", format)
+	}
+	accept := r.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, RawMediaType):
+		return RawMediaType, nil
+	case strings.Contains(accept, DagJSONMediaType), strings.Contains(accept, "application/json"):
+		return DagJSONMediaType, nil
+	case hasPath:
+		return DagJSONMediaType, nil
+	default:
+		return RawMediaType, nil
+	}
+}
+
+// resolve returns the node of the root block, or the value the path resolves to from it; the links the path crosses
+// are followed to the blocks they point to, so that a path ending in a link resolves to the node of the linked block
+func (g *Gateway) resolve(tx *sqlx.Tx, root cid.Cid, path []string) (interface{}, error) {
+	nd, err := g.node(tx, root)
+	if err != nil {
+		return nil, err
+	}
+	for len(path) > 0 {
+		value, rest, err := nd.Resolve(path)
+		if err != nil {
+			return nil, errorf(http.StatusNotFound, "can not resolve %s in block %s: %v", strings.Join(path, "/"), nd.Cid().String(), err)
+		}
+		if link, ok := value.(*node.Link); ok {
+			if nd, err = g.node(tx, link.Cid); err != nil {
+				return nil, err
+			}
+			path = rest
+			continue
+		}
+		if len(rest) > 0 {
+			return nil, errorf(http.StatusNotFound, "can not resolve %s past a value in block %s", strings.Join(rest, "/"), nd.Cid().String())
+		}
+		return value, nil
+	}
+	return nd, nil
+}
+
+// node returns the decoded IPLD node of the block with the cid
+func (g *Gateway) node(tx *sqlx.Tx, c cid.Cid) (node.Node, error) {
+	data, err := g.fetch(tx, c)
+	if err != nil {
+		return nil, err
+	}
+	nd, err := ipld.DecodeNode(c, data)
+	if err != nil {
+		return nil, errorf(http.StatusUnprocessableEntity, "can not decode block %s: %v", c.String(), err)
+	}
+	return nd, nil
+}
+
+// fetch returns the data of the block with the cid, which has to hash to the cid
+func (g *Gateway) fetch(tx *sqlx.Tx, c cid.Cid) ([]byte, error) {
+	mhKey := shared.MultihashKeyFromCID(c)
+	t := shared.CodecDataType(c.Type())
+	data, err := g.source.Get(tx, t, c, mhKey)
+	// eth uncles are headers too, but can be kept in a blockstore of their own
+	if err == shared.ErrBlockNotFound && c.Type() == ipld.MEthHeader {
+		data, err = g.source.Get(tx, shared.Uncles, c, mhKey)
+	}
+	if err == shared.ErrBlockNotFound {
+		return nil, errorf(http.StatusNotFound, "block %s not found", c.String())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("block %s: %v", c.String(), err)
+	}
+	return data, nil
+}
@@ -0,0 +1,114 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gateway_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/gateway"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+var _ = Describe("Gateway", func() {
+	var (
+		db *postgres.DB
+		gw *gateway.Gateway
+	)
+	BeforeEach(func() {
+		var err error
+		db, err = shared.SetupDB()
+		Expect(err).ToNot(HaveOccurred())
+		_, err = eth.NewIPLDPublisherAndIndexer(db).Publish(mocks.MockConvertedPayload)
+		Expect(err).ToNot(HaveOccurred())
+		gw, err = gateway.NewGateway("", db, shared.DirectPostgres, nil)
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		eth.TearDownDB(db)
+	})
+
+	request := func(method, target, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rec := httptest.NewRecorder()
+		gw.ServeHTTP(rec, req)
+		return rec
+	}
+	fetch := func(mhKey string) []byte {
+		var data []byte
+		err := db.Get(&data, `SELECT data FROM public.blocks WHERE key = $1`, mhKey)
+		Expect(err).ToNot(HaveOccurred())
+		return data
+	}
+	header := "/" + mocks.HeaderCID.String()
+
+	It("Serves blocks raw by their cid", func() {
+		rec := request(http.MethodGet, header, "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get("Content-Type")).To(Equal(gateway.RawMediaType))
+		Expect(rec.Body.Bytes()).To(Equal(fetch(mocks.HeaderMhKey)))
+	})
+
+	It("Serves blocks as dag-json", func() {
+		rec := request(http.MethodGet, header, gateway.DagJSONMediaType)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get("Content-Type")).To(Equal(gateway.DagJSONMediaType))
+		var fields map[string]interface{}
+		Expect(json.Unmarshal(rec.Body.Bytes(), &fields)).To(Succeed())
+		Expect(fields["number"]).To(BeNumerically("==", mocks.BlockNumber.Int64()))
+		txRoot := ipld.Keccak256ToCid(ipld.MEthTxTrie, mocks.MockBlock.TxHash().Bytes())
+		Expect(fields["tx"]).To(Equal(map[string]interface{}{"/": txRoot.String()}))
+	})
+
+	It("Resolves paths across blocks", func() {
+		rec := request(http.MethodGet, header+"/number", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(Equal("1"))
+
+		// the key of the first transaction in the transaction trie is rlp(0), 0x80
+		rec = request(http.MethodGet, header+"/tx/8/0/value", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Header().Get("Content-Type")).To(Equal(gateway.DagJSONMediaType))
+		Expect(rec.Body.String()).To(Equal(`"0x3e8"`))
+
+		rec = request(http.MethodGet, header+"/tx/8/0?format=raw", "")
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.Bytes()).To(Equal(fetch(mocks.Trx1MhKey)))
+	})
+
+	It("Fails requests it can not answer", func() {
+		Expect(request(http.MethodGet, "/", "").Code).To(Equal(http.StatusBadRequest))
+		Expect(request(http.MethodGet, "/not-a-cid", "").Code).To(Equal(http.StatusBadRequest))
+		Expect(request(http.MethodGet, header+"?format=cbor", "").Code).To(Equal(http.StatusBadRequest))
+		Expect(request(http.MethodPost, header, "").Code).To(Equal(http.StatusMethodNotAllowed))
+		// the parent of the mock header is not indexed
+		Expect(request(http.MethodGet, header+"/parent", "").Code).To(Equal(http.StatusNotFound))
+		Expect(request(http.MethodGet, header+"/nothing", "").Code).To(Equal(http.StatusNotFound))
+		Expect(request(http.MethodGet, header+"/number?format=raw", "").Code).To(Equal(http.StatusNotAcceptable))
+	})
+})
@@ -0,0 +1,86 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gc
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/blockstore"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/config"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/node"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+	"github.com/vulcanize/ipfs-blockchain-watcher/utils"
+)
+
+// Env variables
+const (
+	GC_BATCH_SIZE = "GC_BATCH_SIZE"
+	GC_DRY_RUN    = "GC_DRY_RUN"
+)
+
+// DefaultBatchSize is the default number of headers marked, and of blocks swept, in a single db tx
+const DefaultBatchSize = 1000
+
+// Config holds the parameters needed to garbage collect the blocks in public.blocks
+type Config struct {
+	BatchSize uint64 // The number of headers marked, and of blocks swept, in a single db tx
+	DryRun    bool   // Only count the unreferenced blocks, without deleting them
+
+	// DB info
+	DB       *postgres.DB
+	DBConfig config.Database
+
+	// The blockstores the headers and the nodes hanging off of them are read from, and the cold tier is swept through
+	Blockstores *shared.Blockstores
+}
+
+// NewConfig fills and returns a gc config from toml parameters
+func NewConfig() (*Config, error) {
+	c := new(Config)
+	var err error
+
+	viper.BindEnv("gc.batchSize", GC_BATCH_SIZE)
+	viper.BindEnv("gc.dryRun", GC_DRY_RUN)
+
+	// in the other modes public.blocks can back the datastore of an IPFS repo, which holds blocks of its own and is
+	// written to outside of the index txs; those repos are collected by ipfs itself
+	ipfsMode, err := shared.GetIPFSMode()
+	if err != nil {
+		return nil, err
+	}
+	if ipfsMode != shared.DirectPostgres {
+		return nil, fmt.Errorf("blocks can only be garbage collected in postgres ipfs mode, not %s", ipfsMode.String())
+	}
+	c.BatchSize = uint64(viper.GetInt64("gc.batchSize"))
+	if c.BatchSize == 0 {
+		c.BatchSize = DefaultBatchSize
+	}
+	c.DryRun = viper.GetBool("gc.dryRun")
+
+	c.Blockstores, err = blockstore.NewBlockstores()
+	if err != nil {
+		return nil, err
+	}
+
+	c.DBConfig.Init()
+	db := utils.LoadPostgres(c.DBConfig, node.Node{})
+	c.DB = &db
+	return c, nil
+}
@@ -0,0 +1,497 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	ipfsblockstore "github.com/ipfs/go-ipfs-blockstore"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/blockstore"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/builders"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// chains share public.blocks, so the blocks of both are marked before any block is swept
+var chains = []shared.ChainType{shared.Ethereum, shared.Bitcoin}
+
+// references are the columns of the index tables that reference blocks by their mh_key
+var references = []struct {
+	table, column string
+}{
+	{"eth.header_cids", "mh_key"},
+	{"eth.uncle_cids", "mh_key"},
+	{"eth.transaction_cids", "mh_key"},
+	{"eth.receipt_cids", "mh_key"},
+	{"eth.log_cids", "mh_key"},
+	{"eth.state_cids", "mh_key"},
+	{"eth.storage_cids", "mh_key"},
+	{"btc.header_cids", "mh_key"},
+	{"btc.transaction_cids", "mh_key"},
+	{"btc.transaction_cids", "witness_mh_key"},
+}
+
+// unreferencedPgStr is the condition on a block b of public.blocks that it is neither marked nor referenced by any of
+// the index tables
+func unreferencedPgStr() string {
+	conditions := []string{`NOT EXISTS (SELECT 1 FROM public.gc_marks m WHERE m.key = b.key)`}
+	for _, ref := range references {
+		conditions = append(conditions, fmt.Sprintf(`NOT EXISTS (SELECT 1 FROM %s WHERE %s = b.key)`, ref.table, ref.column))
+	}
+	return strings.Join(conditions, ` AND `)
+}
+
+// collectionLockID is the advisory lock held for the whole of a collection, so that only one runs at a time
+const collectionLockID = 0x6763 // "gc"
+
+// blockKeyPattern matches the keys of the IPLD blocks in public.blocks
+var blockKeyPattern = ipfsblockstore.BlockPrefix.String() + "/%"
+
+// Service garbage collects the blocks in public.blocks and the cold tier that no index row references, directly or
+// through the header whose tries (merkle trees for btc) they belong to: blocks whose index rows have been removed, e.g.
+// by a reorg or a clean up, or that were left behind by failed publishing
+type Service struct {
+	db          *postgres.DB
+	blockstores *shared.Blockstores
+	// The blockstore that reads through to the cold tier, nil if no cold tier is configured
+	tiered    *blockstore.TieredBlockstore
+	batchSize uint64
+	dryRun    bool
+}
+
+// NewService creates and returns a gc service from the provided settings
+func NewService(settings *Config) (*Service, error) {
+	blockstores := settings.Blockstores
+	if blockstores == nil {
+		blockstores = shared.NewPostgresBlockstores()
+	}
+	tiered, err := coldTier(blockstores)
+	if err != nil {
+		return nil, err
+	}
+	batchSize := settings.BatchSize
+	if batchSize == 0 {
+		batchSize = DefaultBatchSize
+	}
+	return &Service{
+		db:          settings.DB,
+		blockstores: blockstores,
+		tiered:      tiered,
+		batchSize:   batchSize,
+		dryRun:      settings.DryRun,
+	}, nil
+}
+
+// coldTier returns the blockstore that reads through to the cold tier, if one is configured
+// Blocks can only be collected from public.blocks and the cold tier: blocks written to the other blockstores are not
+// covered by the lock on public.blocks that keeps a collection from sweeping the blocks of publishing txs in flight
+func coldTier(blockstores *shared.Blockstores) (*blockstore.TieredBlockstore, error) {
+	var tiered *blockstore.TieredBlockstore
+	stores := []shared.Blockstore{blockstores.Default}
+	for _, store := range blockstores.Types {
+		stores = append(stores, store)
+	}
+	for _, store := range stores {
+		hot := store
+		if ts, ok := store.(*blockstore.TieredBlockstore); ok {
+			tiered = ts
+			hot = ts.Hot
+		}
+		if _, ok := hot.(shared.PostgresBlockstore); !ok {
+			return nil, fmt.Errorf("blocks can only be garbage collected from public.blocks and the cold tier, not from a %T", hot)
+		}
+	}
+	return tiered, nil
+}
+
+// collection is a single run of the garbage collector
+type collection struct {
+	*Service
+	// The highest id of the headers of each chain whose blocks have been marked
+	marked map[shared.ChainType]int64
+	// The oldest txid that could still write to the header tables when their rows were last marked; rows written by it
+	// or any later tx, such as the headers a resync indexes again along with the nodes of their tries that were missing,
+	// are marked again
+	since int64
+}
+
+// Collect marks the blocks that hang off of the indexed headers but are not indexed themselves, the nodes of the
+// transaction and receipt tries and the btc merkle trees, and then sweeps the blocks in public.blocks and the cold tier
+// that are neither marked nor referenced by an index row, in batches; it returns the number of blocks swept, or found in
+// a dry run
+// Every batch is swept with public.blocks locked against publishing, after the headers indexed in the meantime have
+// been marked, and the references of the index tables are checked again, so a block referenced by a header or index row
+// is never swept, whether it was indexed before the collection started or while it ran
+// The marks are kept in public.gc_marks, which is emptied when the collection starts and ends; only one collection can
+// run at a time, which an advisory lock held for the whole collection ensures
+func (s *Service) Collect() (int, error) {
+	ctx := context.Background()
+	// advisory locks are held by a session, so the lock is taken and released on a connection of its own
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, collectionLockID).Scan(&locked); err != nil {
+		return 0, err
+	}
+	if !locked {
+		return 0, fmt.Errorf("another collection is running")
+	}
+	if s.tiered == nil {
+		var moved bool
+		if err := s.db.Get(&moved, `SELECT EXISTS (SELECT 1 FROM public.block_locations)`); err != nil {
+			return 0, err
+		}
+		if moved {
+			return 0, fmt.Errorf("blocks have been moved to a cold tier, configure its blockstore under [tier] to collect them")
+		}
+	}
+	defer func() {
+		if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, collectionLockID); err != nil {
+			logrus.Errorf("failed to release the gc lock: %v", err)
+		}
+	}()
+	// the marks of an interrupted collection are stale
+	if _, err := s.db.Exec(`TRUNCATE public.gc_marks`); err != nil {
+		return 0, err
+	}
+	defer func() {
+		if _, err := s.db.Exec(`TRUNCATE public.gc_marks`); err != nil {
+			logrus.Errorf("failed to empty public.gc_marks: %v", err)
+		}
+	}()
+	c := &collection{
+		Service: s,
+		marked:  make(map[shared.ChainType]int64, len(chains)),
+	}
+	if err := c.mark(); err != nil {
+		return 0, err
+	}
+	return c.sweep()
+}
+
+// mark marks the blocks hanging off of the headers indexed before the collection started
+func (c *collection) mark() error {
+	tops, err := c.topHeaderIDs()
+	if err != nil {
+		return err
+	}
+	for _, chain := range chains {
+		marked := 0
+		for from := int64(0); from < tops[chain]; from += int64(c.batchSize) {
+			to := from + int64(c.batchSize)
+			if to > tops[chain] {
+				to = tops[chain]
+			}
+			count, err := c.markRange(chain, from, to)
+			if err != nil {
+				return err
+			}
+			marked += count
+		}
+		c.marked[chain] = tops[chain]
+		logrus.Infof("marked %d blocks hanging off of the %s headers", marked, chain.String())
+	}
+	return nil
+}
+
+// topHeaderIDs returns the highest header id of each chain, and sets the txid the headers are marked since
+// They are read with public.blocks locked, once every publishing tx that has written to it has committed, so that no
+// header below them is still to be indexed
+func (c *collection) topHeaderIDs() (tops map[shared.ChainType]int64, err error) {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	if err = lockBlocks(tx); err != nil {
+		return nil, err
+	}
+	if err = tx.Get(&c.since, snapshotXminPgStr); err != nil {
+		return nil, err
+	}
+	tops = make(map[shared.ChainType]int64, len(chains))
+	for _, chain := range chains {
+		var top int64
+		if err = tx.Get(&top, `SELECT COALESCE(MAX(id), 0) FROM `+chain.API()+`.header_cids`); err != nil {
+			return nil, err
+		}
+		tops[chain] = top
+	}
+	return tops, nil
+}
+
+// snapshotXminPgStr selects the oldest txid that is still running, or the next one if none is; every row written by a
+// tx that has not committed yet has an xmin at or above it
+const snapshotXminPgStr = `SELECT txid_snapshot_xmin(txid_current_snapshot())`
+
+// lockBlocks locks public.blocks until the end of the tx, waiting for the txs that have written to it to commit and
+// holding off any tx that is about to
+// Publishing writes the blocks in the same tx as the index rows, and before them, so once the lock is held every
+// indexed row is visible and no row can be indexed against a block that is being swept
+func lockBlocks(tx *sqlx.Tx) error {
+	_, err := tx.Exec(`LOCK TABLE public.blocks IN SHARE ROW EXCLUSIVE MODE`)
+	return err
+}
+
+// markRange marks the blocks hanging off of the headers of the chain with ids above from up to and including to
+func (c *collection) markRange(chain shared.ChainType, from, to int64) (marked int, err error) {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	return c.markHeaders(tx, chain, `id > $1 AND id <= $2`, from, to)
+}
+
+// indexedHeader is a header indexed in one of the header_cids tables
+type indexedHeader struct {
+	ID    int64  `db:"id"`
+	CID   string `db:"cid"`
+	MhKey string `db:"mh_key"`
+}
+
+// markHeaders walks the blocks hanging off of the headers of the chain that meet the condition, and marks them
+// A header whose block is missing fails the collection, as the blocks hanging off of it can't be told apart from the
+// unreferenced ones
+func (c *collection) markHeaders(tx *sqlx.Tx, chain shared.ChainType, condition string, args ...interface{}) (int, error) {
+	var headers []indexedHeader
+	pgStr := `SELECT id, cid, mh_key FROM ` + chain.API() + `.header_cids WHERE ` + condition + ` ORDER BY id`
+	if err := tx.Select(&headers, pgStr, args...); err != nil {
+		return 0, err
+	}
+	// tries can be shared between headers, e.g. the receipt tries of blocks with identical receipts, and are only walked once
+	seen := make(map[string]bool)
+	var keys []string
+	for _, header := range headers {
+		headerData, err := c.blockstores.Get(tx, shared.Headers, header.MhKey)
+		if err == shared.ErrBlockNotFound {
+			return 0, fmt.Errorf("the block of %s header %s is missing, the blocks hanging off of it can not be marked", chain.String(), header.CID)
+		}
+		if err != nil {
+			return 0, err
+		}
+		err = builders.WalkUnindexedBlocks(chain, headerData, func(t shared.DataType, blockCID cid.Cid) ([]byte, error) {
+			mhKey := shared.MultihashKeyFromCID(blockCID)
+			if seen[mhKey] {
+				return nil, nil
+			}
+			seen[mhKey] = true
+			data, err := c.blockstores.Get(tx, t, mhKey)
+			if err == shared.ErrBlockNotFound {
+				return nil, nil
+			}
+			return data, err
+		}, func(t shared.DataType, blockCID cid.Cid, data []byte) error {
+			keys = append(keys, shared.MultihashKeyFromCID(blockCID))
+			return nil
+		})
+		if err != nil {
+			return 0, fmt.Errorf("%s header %s: %v", chain.String(), header.CID, err)
+		}
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	res, err := tx.Exec(`INSERT INTO public.gc_marks (key) SELECT unnest($1::TEXT[]) ON CONFLICT (key) DO NOTHING`, pq.Array(keys))
+	if err != nil {
+		return 0, err
+	}
+	marked, err := res.RowsAffected()
+	return int(marked), err
+}
+
+// sweep deletes the unmarked blocks that no index row references, in batches in key order, first from public.blocks
+// and then from the cold tier, whose blocks are recorded in public.block_locations
+func (c *collection) sweep() (int, error) {
+	swept, err := c.sweepKeys(`SELECT key FROM public.blocks b
+			WHERE key LIKE $1 AND key > $2 AND NOT EXISTS (SELECT 1 FROM public.gc_marks m WHERE m.key = b.key)
+			ORDER BY key LIMIT $3`, c.sweepBatch)
+	if err != nil {
+		return swept, err
+	}
+	if c.tiered != nil {
+		moved, err := c.sweepKeys(`SELECT key FROM public.block_locations b
+			WHERE key LIKE $1 AND key > $2 AND NOT EXISTS (SELECT 1 FROM public.gc_marks m WHERE m.key = b.key)
+			ORDER BY key LIMIT $3`, c.sweepColdBatch)
+		swept += moved
+		if err != nil {
+			return swept, err
+		}
+	}
+	if c.dryRun {
+		logrus.Infof("found %d unreferenced blocks", swept)
+	} else {
+		logrus.Infof("swept %d unreferenced blocks", swept)
+	}
+	return swept, nil
+}
+
+// sweepKeys sweeps the keys the query selects in batches, passing them to sweepBatch
+func (c *collection) sweepKeys(pgStr string, sweepBatch func(keys []string) (int, error)) (int, error) {
+	swept := 0
+	for after := ""; ; {
+		var keys []string
+		if err := c.db.Select(&keys, pgStr, blockKeyPattern, after, c.batchSize); err != nil {
+			return swept, err
+		}
+		if len(keys) == 0 {
+			return swept, nil
+		}
+		count, err := sweepBatch(keys)
+		if err != nil {
+			return swept, err
+		}
+		swept += count
+		after = keys[len(keys)-1]
+		logrus.Debugf("swept %d of %d unmarked blocks up to %s", count, len(keys), after)
+	}
+}
+
+// sweepBatch deletes the blocks with the keys from public.blocks that are still unreferenced once public.blocks is
+// locked and the headers indexed or written again since the last batch have been marked, or only counts them in a dry run
+func (c *collection) sweepBatch(keys []string) (swept int, err error) {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	if err = c.markWritten(tx); err != nil {
+		return 0, err
+	}
+	if c.dryRun {
+		err = tx.Get(&swept, `SELECT COUNT(*) FROM public.blocks b WHERE b.key = ANY($1) AND `+unreferencedPgStr(), pq.Array(keys))
+		return swept, err
+	}
+	res, err := tx.Exec(`DELETE FROM public.blocks b WHERE b.key = ANY($1) AND `+unreferencedPgStr(), pq.Array(keys))
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := res.RowsAffected()
+	return int(deleted), err
+}
+
+// sweepColdBatch deletes the blocks with the keys from the cold tier, and their records from public.block_locations,
+// that are still unreferenced once public.blocks is locked and the headers written since the last batch have been
+// marked, or only counts them in a dry run
+// Moving blocks to the cold tier deletes them from public.blocks, so the lock holds off the tier command as well
+// The copies of failed moves are deleted along with them, but not counted, as their blocks are kept in public.blocks
+func (c *collection) sweepColdBatch(keys []string) (swept int, err error) {
+	tx, err := c.db.Beginx()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	if err = c.markWritten(tx); err != nil {
+		return 0, err
+	}
+	var unreferenced []struct {
+		Key   string `db:"key"`
+		Moved bool   `db:"moved"`
+	}
+	err = tx.Select(&unreferenced, `SELECT key, moved FROM public.block_locations b WHERE b.key = ANY($1) AND `+unreferencedPgStr(), pq.Array(keys))
+	if err != nil {
+		return 0, err
+	}
+	for _, block := range unreferenced {
+		if block.Moved {
+			swept++
+		}
+		if c.dryRun {
+			continue
+		}
+		if err = c.tiered.Delete(tx, block.Key); err != nil {
+			return 0, err
+		}
+	}
+	return swept, nil
+}
+
+// markWritten locks public.blocks until the end of the tx and marks the headers indexed or written again since the
+// last batch
+// Headers are written again when they are indexed again, e.g. by a resync without clearOldCache, which keeps their ids
+// and can publish nodes of their tries that were missing when they were marked; their rows are told by their xmin
+func (c *collection) markWritten(tx *sqlx.Tx) error {
+	if err := lockBlocks(tx); err != nil {
+		return err
+	}
+	// the age of an xmin is counted back from the txid of this tx; frozen rows are older than any age
+	var current, since int64
+	if err := tx.Get(&current, `SELECT txid_current()`); err != nil {
+		return err
+	}
+	if err := tx.Get(&since, snapshotXminPgStr); err != nil {
+		return err
+	}
+	for _, chain := range chains {
+		var top int64
+		if err := tx.Get(&top, `SELECT COALESCE(MAX(id), 0) FROM `+chain.API()+`.header_cids`); err != nil {
+			return err
+		}
+		if _, err := c.markHeaders(tx, chain, `id > $1 OR age(xmin) <= $2`, c.marked[chain], current-c.since); err != nil {
+			return err
+		}
+		c.marked[chain] = top
+	}
+	c.since = since
+	return nil
+}
@@ -0,0 +1,285 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package gc_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/blockstore"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	btcmocks "github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/gc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// the blocks the mocks publish that are indexed, and the roots of the transaction and receipt tries, which are not
+var referenced = []string{mocks.HeaderMhKey, mocks.Trx1MhKey, mocks.Rct1MhKey, mocks.State1MhKey, mocks.StorageMhKey,
+	shared.MultihashKeyFromCID(mocks.TxTrieCID), shared.MultihashKeyFromCID(mocks.RctTrieCID)}
+
+// insertBlock writes a state trie node that nothing references to public.blocks, and returns its key
+func insertBlock(db *postgres.DB, data []byte) string {
+	c, err := ipld.RawdataToCid(ipld.MEthStateTrie, data, multihash.KECCAK_256)
+	Expect(err).ToNot(HaveOccurred())
+	key := shared.MultihashKeyFromCID(c)
+	_, err = db.Exec(`INSERT INTO public.blocks (key, data) VALUES ($1, $2)`, key, data)
+	Expect(err).ToNot(HaveOccurred())
+	return key
+}
+
+var _ = Describe("GC", func() {
+	var (
+		db     *postgres.DB
+		orphan string
+	)
+	BeforeEach(func() {
+		db = mocks.SetupIndexedDB()
+		orphan = insertBlock(db, []byte{1, 2, 3})
+	})
+	AfterEach(func() {
+		eth.TearDownDB(db)
+	})
+
+	newService := func(dryRun bool) *gc.Service {
+		service, err := gc.NewService(&gc.Config{
+			DB:        db,
+			BatchSize: 2,
+			DryRun:    dryRun,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		return service
+	}
+	has := func(key string) bool {
+		var exists bool
+		err := db.Get(&exists, `SELECT EXISTS (SELECT 1 FROM public.blocks WHERE key = $1)`, key)
+		Expect(err).ToNot(HaveOccurred())
+		return exists
+	}
+
+	table.DescribeTable("Collects the unreferenced blocks and keeps the referenced ones",
+		func(dryRun bool) {
+			collected, err := newService(dryRun).Collect()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(collected).To(Equal(1))
+			Expect(has(orphan)).To(Equal(dryRun))
+			for _, key := range referenced {
+				Expect(has(key)).To(BeTrue())
+			}
+			var marks int
+			err = db.Get(&marks, `SELECT COUNT(*) FROM public.gc_marks`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(marks).To(Equal(0))
+		},
+		table.Entry("deleting them", false),
+		table.Entry("only counting them in a dry run", true),
+	)
+
+	It("Collects nothing when every block is referenced", func() {
+		_, err := newService(false).Collect()
+		Expect(err).ToNot(HaveOccurred())
+		collected, err := newService(false).Collect()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(collected).To(Equal(0))
+		for _, key := range referenced {
+			Expect(has(key)).To(BeTrue())
+		}
+	})
+
+	It("Deletes the blocks of removed index rows", func() {
+		_, err := db.Exec(`DELETE FROM eth.header_cids`)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = newService(false).Collect()
+		Expect(err).ToNot(HaveOccurred())
+		for _, key := range append(referenced, orphan) {
+			Expect(has(key)).To(BeFalse())
+		}
+	})
+
+	It("Leaves keys that are not blocks alone", func() {
+		_, err := db.Exec(`INSERT INTO public.blocks (key, data) VALUES ('/local/filesroot', $1)`, []byte{1})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = newService(false).Collect()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(has("/local/filesroot")).To(BeTrue())
+	})
+
+	It("Does not run while another collection is running", func() {
+		conn, err := db.Conn(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		defer conn.Close()
+		// the advisory lock a collection holds
+		_, err = conn.ExecContext(context.Background(), `SELECT pg_advisory_lock($1)`, 0x6763)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = newService(false).Collect()
+		Expect(err).To(HaveOccurred())
+		Expect(has(orphan)).To(BeTrue())
+		_, err = conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, 0x6763)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("Discards the marks of an interrupted collection", func() {
+		_, err := db.Exec(`INSERT INTO public.gc_marks (key) VALUES ($1)`, orphan)
+		Expect(err).ToNot(HaveOccurred())
+		collected, err := newService(false).Collect()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(collected).To(Equal(1))
+		Expect(has(orphan)).To(BeFalse())
+	})
+
+	Describe("Cold tier", func() {
+		var (
+			dir    string
+			cold   *blockstore.FilesystemBlockstore
+			tiered *blockstore.TieredBlockstore
+		)
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "gc")
+			Expect(err).ToNot(HaveOccurred())
+			cold, err = blockstore.NewFilesystemBlockstore(dir)
+			Expect(err).ToNot(HaveOccurred())
+			tiered = blockstore.NewTieredBlockstore(shared.PostgresBlockstore{}, cold, blockstore.Filesystem)
+			tx, err := db.Beginx()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tiered.RecordMoves(tx, []string{orphan, mocks.State1MhKey})).To(Succeed())
+			for _, key := range []string{orphan, mocks.State1MhKey} {
+				data, err := shared.FetchIPLDByMhKey(tx, key)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(tiered.MoveToCold(tx, key, data)).To(Succeed())
+			}
+			Expect(tx.Commit()).To(Succeed())
+		})
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+		coldHas := func(key string) bool {
+			has, err := cold.Has(nil, key)
+			Expect(err).ToNot(HaveOccurred())
+			var recorded bool
+			err = db.Get(&recorded, `SELECT EXISTS (SELECT 1 FROM public.block_locations WHERE key = $1)`, key)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(recorded).To(Equal(has))
+			return has
+		}
+
+		It("Deletes the unreferenced blocks moved to the cold tier and keeps the referenced ones", func() {
+			service, err := gc.NewService(&gc.Config{
+				DB:          db,
+				BatchSize:   2,
+				Blockstores: shared.NewBlockstores(tiered),
+			})
+			Expect(err).ToNot(HaveOccurred())
+			collected, err := service.Collect()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(collected).To(Equal(1))
+			Expect(coldHas(orphan)).To(BeFalse())
+			Expect(coldHas(mocks.State1MhKey)).To(BeTrue())
+		})
+
+		It("Deletes the cold copies of failed moves along with the unreferenced blocks they are of", func() {
+			unmovedKey := insertBlock(db, []byte{4, 5, 6})
+			tx, err := db.Beginx()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(tiered.RecordMoves(tx, []string{unmovedKey})).To(Succeed())
+			Expect(tx.Commit()).To(Succeed())
+			Expect(cold.Put(nil, unmovedKey, []byte{4, 5, 6})).To(Succeed())
+
+			service, err := gc.NewService(&gc.Config{
+				DB:          db,
+				BatchSize:   2,
+				Blockstores: shared.NewBlockstores(tiered),
+			})
+			Expect(err).ToNot(HaveOccurred())
+			collected, err := service.Collect()
+			Expect(err).ToNot(HaveOccurred())
+			// the block of the failed move is only counted once, from public.blocks
+			Expect(collected).To(Equal(2))
+			Expect(has(unmovedKey)).To(BeFalse())
+			Expect(coldHas(unmovedKey)).To(BeFalse())
+		})
+
+		It("Refuses to collect blocks moved to a cold tier that is not configured", func() {
+			_, err := newService(false).Collect()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("configure its blockstore under [tier]"))
+			Expect(coldHas(orphan)).To(BeTrue())
+		})
+
+		It("Refuses to collect blocks kept in other blockstores", func() {
+			_, err := gc.NewService(&gc.Config{
+				DB:          db,
+				Blockstores: shared.NewBlockstores(cold),
+			})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Bitcoin", func() {
+		// the blocks the segwit mock publishes: the header, both forms of its transactions, and the merkle root, witness
+		// commitment and witness merkle root, which are not indexed
+		var btcReferenced []string
+		BeforeEach(func() {
+			btcmocks.PublishAndIndex(db, btcmocks.MockSegwitConvertedPayload)
+			btcReferenced = nil
+			err := db.Select(&btcReferenced, `SELECT mh_key FROM btc.header_cids
+					UNION SELECT mh_key FROM btc.transaction_cids
+					UNION SELECT witness_mh_key FROM btc.transaction_cids`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(btcReferenced).To(HaveLen(5))
+			for _, c := range []cid.Cid{btcmocks.MockSegwitMerkleRootCID, btcmocks.MockSegwitCommitmentCID, btcmocks.MockSegwitWitnessRootCID} {
+				btcReferenced = append(btcReferenced, shared.MultihashKeyFromCID(c))
+			}
+		})
+		AfterEach(func() {
+			btc.TearDownDB(db)
+		})
+
+		It("Keeps the merkle and witness blocks hanging off of the headers", func() {
+			collected, err := newService(false).Collect()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(collected).To(Equal(1))
+			for _, key := range append(referenced, btcReferenced...) {
+				Expect(has(key)).To(BeTrue())
+			}
+		})
+
+		It("Deletes them along with the headers they hang off of", func() {
+			_, err := db.Exec(`DELETE FROM btc.header_cids`)
+			Expect(err).ToNot(HaveOccurred())
+			collected, err := newService(false).Collect()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(collected).To(Equal(len(btcReferenced) + 1))
+			for _, key := range btcReferenced {
+				Expect(has(key)).To(BeFalse())
+			}
+			for _, key := range referenced {
+				Expect(has(key)).To(BeTrue())
+			}
+		})
+	})
+})
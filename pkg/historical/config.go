@@ -22,6 +22,7 @@ import (
 
 	"github.com/spf13/viper"
 
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/blockstore"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/config"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/node"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
@@ -36,6 +37,7 @@ const (
 	SUPERNODE_BATCH_SIZE       = "SUPERNODE_BATCH_SIZE"
 	SUPERNODE_BATCH_NUMBER     = "SUPERNODE_BATCH_NUMBER"
 	SUPERNODE_VALIDATION_LEVEL = "SUPERNODE_VALIDATION_LEVEL"
+	SUPERNODE_BACKFILL_STATE   = "SUPERNODE_BACKFILL_STATE"
 
 	BACKFILL_MAX_IDLE_CONNECTIONS = "BACKFILL_MAX_IDLE_CONNECTIONS"
 	BACKFILL_MAX_OPEN_CONNECTIONS = "BACKFILL_MAX_OPEN_CONNECTIONS"
@@ -44,10 +46,11 @@ const (
 
 // Config struct
 type Config struct {
-	Chain    shared.ChainType
-	IPFSPath string
-	IPFSMode shared.IPFSMode
-	DBConfig config.Database
+	Chain       shared.ChainType
+	IPFSPath    string
+	IPFSMode    shared.IPFSMode
+	Blockstores *shared.Blockstores // The blockstores used in DirectPostgres mode
+	DBConfig    config.Database
 
 	DB              *postgres.DB
 	HTTPClient      interface{}
@@ -55,6 +58,7 @@ type Config struct {
 	BatchSize       uint64
 	BatchNumber     uint64
 	ValidationLevel int
+	BackFillState   bool          // Fill in the state of blocks indexed without it; the node has to serve their state diffs
 	Timeout         time.Duration // HTTP connection timeout in seconds
 	NodeInfo        node.Node
 }
@@ -75,12 +79,22 @@ func NewConfig() (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
-	if c.IPFSMode == shared.LocalInterface || c.IPFSMode == shared.RemoteClient {
+	if c.IPFSMode == shared.LocalInterface {
 		c.IPFSPath, err = shared.GetIPFSPath()
 		if err != nil {
 			return nil, err
 		}
 	}
+	// in remote mode the ipfs path is the address of the daemon's HTTP API
+	if c.IPFSMode == shared.RemoteClient {
+		c.IPFSPath = shared.GetIPFSAPI()
+	}
+	if c.IPFSMode == shared.DirectPostgres {
+		c.Blockstores, err = blockstore.NewBlockstores()
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	c.DBConfig.Init()
 
@@ -100,6 +114,7 @@ func (c *Config) init() error {
 	viper.BindEnv("watcher.batchSize", SUPERNODE_BATCH_SIZE)
 	viper.BindEnv("watcher.batchNumber", SUPERNODE_BATCH_NUMBER)
 	viper.BindEnv("watcher.validationLevel", SUPERNODE_VALIDATION_LEVEL)
+	viper.BindEnv("watcher.backFillState", SUPERNODE_BACKFILL_STATE)
 	viper.BindEnv("watcher.timeout", shared.HTTP_TIMEOUT)
 
 	timeout := viper.GetInt("watcher.timeout")
@@ -131,6 +146,7 @@ func (c *Config) init() error {
 	c.BatchSize = uint64(viper.GetInt64("watcher.batchSize"))
 	c.BatchNumber = uint64(viper.GetInt64("watcher.batchNumber"))
 	c.ValidationLevel = viper.GetInt("watcher.validationLevel")
+	c.BackFillState = viper.GetBool("watcher.backFillState")
 
 	dbConn := overrideDBConnConfig(c.DBConfig)
 	db := utils.LoadPostgres(dbConn, c.NodeInfo)
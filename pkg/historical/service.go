@@ -54,6 +54,8 @@ type BackFillService struct {
 	BatchSize uint64
 	// Number of goroutines
 	BatchNumber int64
+	// Whether the gaps in the state of blocks indexed without it are filled in, from a node serving their state diffs
+	BackFillState bool
 	// Channel for receiving quit signal
 	QuitChan chan bool
 	// Chain type
@@ -64,7 +66,7 @@ type BackFillService struct {
 
 // NewBackFillService returns a new BackFillInterface
 func NewBackFillService(settings *Config, screenAndServeChan chan shared.ConvertedData) (BackFillInterface, error) {
-	publisher, err := builders.NewIPLDPublisher(settings.Chain, settings.IPFSPath, settings.DB, settings.IPFSMode)
+	publisher, err := builders.NewIPLDPublisher(settings.Chain, settings.IPFSPath, settings.DB, settings.IPFSMode, settings.Blockstores)
 	if err != nil {
 		return nil, err
 	}
@@ -72,7 +74,7 @@ func NewBackFillService(settings *Config, screenAndServeChan chan shared.Convert
 	if err != nil {
 		return nil, err
 	}
-	converter, err := builders.NewPayloadConverter(settings.Chain)
+	converter, err := builders.NewPayloadConverter(settings.Chain, settings.DB)
 	if err != nil {
 		return nil, err
 	}
@@ -102,6 +104,7 @@ func NewBackFillService(settings *Config, screenAndServeChan chan shared.Convert
 		BatchSize:          batchSize,
 		BatchNumber:        int64(batchNumber),
 		ScreenAndServeChan: screenAndServeChan,
+		BackFillState:      settings.BackFillState,
 		QuitChan:           make(chan bool),
 		chain:              settings.Chain,
 		validationLevel:    settings.ValidationLevel,
@@ -133,7 +136,13 @@ func (bfs *BackFillService) BackFill(wg *sync.WaitGroup) {
 					go bfs.backFill(wg, i, heightsChan)
 				}
 				for _, gap := range gaps {
-					log.Infof("backFilling %s data from %d to %d", bfs.chain.String(), gap.Start, gap.Stop)
+					// the payloads are always fetched in full, which only fills in a gap in the state of the blocks if
+					// the node serves their state diffs
+					if gap.DataType != shared.Full && !bfs.BackFillState {
+						log.Debugf("skipping %s %s gap from %d to %d", bfs.chain.String(), gap.DataType.String(), gap.Start, gap.Stop)
+						continue
+					}
+					log.Infof("backFilling %s %s data from %d to %d", bfs.chain.String(), gap.DataType.String(), gap.Start, gap.Stop)
 					blockRangeBins, err := utils.GetBlockHeightBins(gap.Start, gap.Stop, bfs.BatchSize)
 					if err != nil {
 						log.Errorf("%s watcher db backFill GetBlockHeightBins error: %v", bfs.chain.String(), err)
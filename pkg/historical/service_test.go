@@ -140,6 +140,58 @@ var _ = Describe("BackFiller", func() {
 			Expect(mockFetcher.CalledAtBlockHeights[0]).To(Equal([]uint64{100}))
 		})
 
+		It("Skips gaps in the state of blocks unless it is configured to fill them in", func() {
+			mockCidRepo := &mocks.CIDIndexer{
+				ReturnErr: nil,
+			}
+			mockPublisher := &mocks.IterativeIPLDPublisher{
+				ReturnCIDPayload: []*eth.CIDPayload{mocks.MockCIDPayload, mocks.MockCIDPayload},
+				ReturnErr:        nil,
+			}
+			mockConverter := &mocks.IterativePayloadConverter{
+				ReturnIPLDPayload: []eth.ConvertedPayload{mocks.MockConvertedPayload, mocks.MockConvertedPayload},
+				ReturnErr:         nil,
+			}
+			mockRetriever := &mocks2.CIDRetriever{
+				FirstBlockNumberToReturn: 0,
+				GapsToRetrieve: []shared.Gap{
+					{
+						Start: 100, Stop: 100,
+					},
+					{
+						Start: 102, Stop: 103, DataType: shared.State,
+					},
+				},
+			}
+			mockFetcher := &mocks2.PayloadFetcher{
+				PayloadsToReturn: map[uint64]shared.RawChainData{
+					100: mocks.MockStateDiffPayload,
+					102: mocks.MockStateDiffPayload,
+					103: mocks.MockStateDiffPayload,
+				},
+			}
+			quitChan := make(chan bool, 1)
+			backfiller := &historical.BackFillService{
+				Indexer:           mockCidRepo,
+				Publisher:         mockPublisher,
+				Converter:         mockConverter,
+				Fetcher:           mockFetcher,
+				Retriever:         mockRetriever,
+				GapCheckFrequency: time.Second * 2,
+				BatchSize:         shared.DefaultMaxBatchSize,
+				BatchNumber:       shared.DefaultMaxBatchNumber,
+				QuitChan:          quitChan,
+			}
+			wg := &sync.WaitGroup{}
+			backfiller.BackFill(wg)
+			time.Sleep(time.Second * 3)
+			quitChan <- true
+			Expect(len(mockCidRepo.PassedCIDPayload)).To(Equal(1))
+			Expect(mockRetriever.CalledTimes).To(Equal(1))
+			Expect(len(mockFetcher.CalledAtBlockHeights)).To(Equal(1))
+			Expect(mockFetcher.CalledAtBlockHeights[0]).To(Equal([]uint64{100}))
+		})
+
 		It("Finds beginning gap", func() {
 			mockCidRepo := &mocks.CIDIndexer{
 				ReturnErr: nil,
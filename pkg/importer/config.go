@@ -0,0 +1,135 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package importer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/blockstore"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/config"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/node"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+	"github.com/vulcanize/ipfs-blockchain-watcher/utils"
+)
+
+// Env variables
+const (
+	IMPORT_RECEIPTS       = "IMPORT_RECEIPTS"
+	IMPORT_CHAIN_DATA_DIR = "IMPORT_CHAIN_DATA_DIR"
+	IMPORT_GENESIS_FILE   = "IMPORT_GENESIS_FILE"
+)
+
+// Config holds the parameters needed to import blocks from block export files
+type Config struct {
+	Files         []string      // The `geth export` files to import, in order
+	ReceiptsFiles []string      // The receipts of the blocks in the export files, in order; if empty the blocks are re-executed
+	ChainDataDir  string        // Directory to keep the chain the blocks are re-executed on; it is kept in memory if empty
+	Genesis       *core.Genesis // Genesis of the chain, mainnet if no genesis file is configured
+
+	// DB info
+	DB          *postgres.DB
+	DBConfig    config.Database
+	IPFSPath    string
+	IPFSMode    shared.IPFSMode
+	Blockstores *shared.Blockstores // The blockstores used in DirectPostgres mode
+
+	NodeInfo node.Node // Info for the node that exported the blocks
+}
+
+// NewConfig fills and returns an import config from toml parameters, for the given export files
+func NewConfig(files []string) (*Config, error) {
+	c := new(Config)
+	var err error
+
+	viper.BindEnv("import.receipts", IMPORT_RECEIPTS)
+	viper.BindEnv("import.chainDataDir", IMPORT_CHAIN_DATA_DIR)
+	viper.BindEnv("import.genesisFile", IMPORT_GENESIS_FILE)
+	viper.BindEnv("ethereum.nodeID", shared.ETH_NODE_ID)
+	viper.BindEnv("ethereum.clientName", shared.ETH_CLIENT_NAME)
+	viper.BindEnv("ethereum.genesisBlock", shared.ETH_GENESIS_BLOCK)
+	viper.BindEnv("ethereum.networkID", shared.ETH_NETWORK_ID)
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no block export files to import")
+	}
+	c.Files = files
+	c.ReceiptsFiles = viper.GetStringSlice("import.receipts")
+	c.ChainDataDir = viper.GetString("import.chainDataDir")
+	c.Genesis = core.DefaultGenesisBlock()
+	if genesisFile := viper.GetString("import.genesisFile"); genesisFile != "" {
+		c.Genesis, err = loadGenesis(genesisFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.IPFSMode, err = shared.GetIPFSMode()
+	if err != nil {
+		return nil, err
+	}
+	if c.IPFSMode == shared.LocalInterface {
+		c.IPFSPath, err = shared.GetIPFSPath()
+		if err != nil {
+			return nil, err
+		}
+	}
+	// in remote mode the ipfs path is the address of the daemon's HTTP API
+	if c.IPFSMode == shared.RemoteClient {
+		c.IPFSPath = shared.GetIPFSAPI()
+	}
+	if c.IPFSMode == shared.DirectPostgres {
+		c.Blockstores, err = blockstore.NewBlockstores()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// there is no node to ask, so the node info comes from the config
+	c.NodeInfo = node.Node{
+		ID:           viper.GetString("ethereum.nodeID"),
+		ClientName:   viper.GetString("ethereum.clientName"),
+		GenesisBlock: viper.GetString("ethereum.genesisBlock"),
+		NetworkID:    viper.GetString("ethereum.networkID"),
+	}
+	c.DBConfig.Init()
+	db := utils.LoadPostgres(c.DBConfig, c.NodeInfo)
+	c.DB = &db
+	return c, nil
+}
+
+// loadGenesis reads a genesis json file, the same format `geth init` takes
+func loadGenesis(path string) (*core.Genesis, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	genesis := new(core.Genesis)
+	if err := json.NewDecoder(file).Decode(genesis); err != nil {
+		return nil, fmt.Errorf("invalid genesis file %s: %v", path, err)
+	}
+	if genesis.Config == nil {
+		return nil, fmt.Errorf("genesis file %s has no chain config", path)
+	}
+	return genesis, nil
+}
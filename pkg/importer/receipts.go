@@ -0,0 +1,167 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package importer
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// ReceiptSource supplies the receipts of the imported blocks, which are called for in order
+type ReceiptSource interface {
+	Receipts(block *types.Block) (types.Receipts, error)
+	Close() error
+}
+
+// Executor is a ReceiptSource that produces the receipts by re-executing the blocks on top of a chain it keeps
+type Executor struct {
+	db    ethdb.Database
+	chain *core.BlockChain
+}
+
+// NewExecutor creates an Executor for the chain with the given genesis, kept in the data directory or, if that is
+// empty, in memory; the chain config of the genesis is returned along with it
+// Blocks re-executed before in the same data directory are not re-executed again
+func NewExecutor(dataDir string, genesis *core.Genesis) (*Executor, *params.ChainConfig, error) {
+	var db ethdb.Database
+	var err error
+	if dataDir == "" {
+		db = rawdb.NewMemoryDatabase()
+	} else {
+		db, err = rawdb.NewLevelDBDatabase(dataDir, 512, 256, "")
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	chainConfig, _, err := core.SetupGenesisBlock(db, genesis)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	// the blocks come out of a chain that verified them, so the proof of work isn't checked again
+	chain, err := core.NewBlockChain(db, nil, chainConfig, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return &Executor{
+		db:    db,
+		chain: chain,
+	}, chainConfig, nil
+}
+
+// Receipts satisfies the ReceiptSource interface
+func (e *Executor) Receipts(block *types.Block) (types.Receipts, error) {
+	if block.NumberU64() == 0 {
+		if block.Hash() != e.chain.Genesis().Hash() {
+			return nil, fmt.Errorf("genesis block %s does not match the configured genesis %s", block.Hash().Hex(), e.chain.Genesis().Hash().Hex())
+		}
+		return types.Receipts{}, nil
+	}
+	if !e.chain.HasBlock(block.Hash(), block.NumberU64()) {
+		if _, err := e.chain.InsertChain(types.Blocks{block}); err != nil {
+			return nil, fmt.Errorf("re-executing block %d failed: %v", block.NumberU64(), err)
+		}
+	}
+	receipts := e.chain.GetReceiptsByHash(block.Hash())
+	if len(receipts) != len(block.Transactions()) {
+		return nil, fmt.Errorf("re-executing block %d produced %d receipts for %d transactions", block.NumberU64(), len(receipts), len(block.Transactions()))
+	}
+	if receipts == nil {
+		receipts = types.Receipts{}
+	}
+	return receipts, nil
+}
+
+// Close satisfies the ReceiptSource interface
+func (e *Executor) Close() error {
+	e.chain.Stop()
+	return e.db.Close()
+}
+
+// ReceiptFiles is a ReceiptSource that reads the receipts from files holding an rlp encoded list of receipts for every
+// block, in the same order as the blocks, in the encoding geth stores them in its database
+type ReceiptFiles struct {
+	paths  []string
+	file   io.Closer
+	stream *rlp.Stream
+}
+
+// NewReceiptFiles creates a ReceiptFiles that reads from the given files in order
+func NewReceiptFiles(paths []string) *ReceiptFiles {
+	return &ReceiptFiles{
+		paths: paths,
+	}
+}
+
+// Receipts satisfies the ReceiptSource interface
+// The receipts are checked against the receipt root of the block, so receipts that are out of step with the blocks are caught
+func (rf *ReceiptFiles) Receipts(block *types.Block) (types.Receipts, error) {
+	for {
+		if rf.stream == nil {
+			if len(rf.paths) == 0 {
+				return nil, fmt.Errorf("receipts files ended before block %d", block.NumberU64())
+			}
+			file, err := openExportFile(rf.paths[0])
+			if err != nil {
+				return nil, err
+			}
+			rf.paths = rf.paths[1:]
+			rf.file = file
+			rf.stream = rlp.NewStream(file, 0)
+		}
+		var storageReceipts []*types.ReceiptForStorage
+		err := rf.stream.Decode(&storageReceipts)
+		if err == io.EOF {
+			if err := rf.Close(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding the receipts of block %d failed: %v", block.NumberU64(), err)
+		}
+		receipts := make(types.Receipts, len(storageReceipts))
+		for i, storageReceipt := range storageReceipts {
+			receipts[i] = (*types.Receipt)(storageReceipt)
+		}
+		if root := types.DeriveSha(receipts); root != block.ReceiptHash() {
+			return nil, fmt.Errorf("receipts do not match the receipt root of block %d", block.NumberU64())
+		}
+		return receipts, nil
+	}
+}
+
+// Close satisfies the ReceiptSource interface
+func (rf *ReceiptFiles) Close() error {
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.file.Close()
+	rf.file = nil
+	rf.stream = nil
+	return err
+}
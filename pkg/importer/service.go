@@ -0,0 +1,241 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package importer
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/builders"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// logInterval is how often, in blocks, the progress of an import is logged
+const logInterval = 1000
+
+type Importer interface {
+	Import() error
+	Stop() error
+}
+
+// Service imports blocks from `geth export` files; they have no state diffs, so they are indexed with their state absent
+type Service struct {
+	// Converter for the blocks and their receipts
+	Converter *eth.PayloadConverter
+	// Interface for publishing the IPLD payloads to IPFS
+	Publisher shared.IPLDPublisher
+	// Interface for indexing the CIDs of the published IPLDs in Postgres
+	Indexer shared.CIDIndexer
+	// Source of the receipts for the blocks
+	Receipts ReceiptSource
+	// Block export files, in order
+	Files []string
+	// Channel for receiving quit signal
+	QuitChan chan bool
+	// DB used to look up the total difficulty of the block before the first one imported
+	db *postgres.DB
+	// Header and total difficulty of the last block imported
+	last   *types.Header
+	lastTD *big.Int
+}
+
+// NewImportService creates and returns an import service from the provided settings
+func NewImportService(settings *Config) (Importer, error) {
+	publisher, err := builders.NewIPLDPublisher(shared.Ethereum, settings.IPFSPath, settings.DB, settings.IPFSMode, settings.Blockstores)
+	if err != nil {
+		return nil, err
+	}
+	indexer, err := builders.NewCIDIndexer(shared.Ethereum, settings.DB, settings.IPFSMode)
+	if err != nil {
+		return nil, err
+	}
+	var receipts ReceiptSource
+	chainConfig := settings.Genesis.Config
+	if len(settings.ReceiptsFiles) > 0 {
+		receipts = NewReceiptFiles(settings.ReceiptsFiles)
+	} else {
+		receipts, chainConfig, err = NewExecutor(settings.ChainDataDir, settings.Genesis)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Service{
+		Converter: eth.NewPayloadConverter(chainConfig),
+		Publisher: publisher,
+		Indexer:   indexer,
+		Receipts:  receipts,
+		Files:     settings.Files,
+		QuitChan:  make(chan bool),
+		db:        settings.DB,
+	}, nil
+}
+
+// errStopped is returned by the block reader when the import has been stopped
+var errStopped = errors.New("import stopped")
+
+// Import reads the blocks in the export files and publishes and indexes them, along with their receipts
+func (is *Service) Import() error {
+	defer is.Receipts.Close()
+	for _, path := range is.Files {
+		log.Infof("importing eth blocks from %s", path)
+		err := readBlocks(path, func(block *types.Block) error {
+			select {
+			case <-is.QuitChan:
+				return errStopped
+			default:
+			}
+			return is.importBlock(block)
+		})
+		if err == errStopped {
+			log.Infof("eth import stopped after block %d", is.lastNumber())
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("eth import from %s failed: %v", path, err)
+		}
+	}
+	log.Infof("eth import finished at block %d", is.lastNumber())
+	return nil
+}
+
+func (is *Service) importBlock(block *types.Block) error {
+	receipts, err := is.Receipts.Receipts(block)
+	if err != nil {
+		return err
+	}
+	td, err := is.totalDifficulty(block)
+	if err != nil {
+		return err
+	}
+	payload, err := is.Converter.ConvertBlock(block, td, receipts)
+	if err != nil {
+		return err
+	}
+	cidPayload, err := is.Publisher.Publish(payload)
+	if err != nil {
+		return err
+	}
+	if err := is.Indexer.Index(cidPayload); err != nil {
+		return err
+	}
+	is.last = block.Header()
+	is.lastTD = td
+	if block.NumberU64()%logInterval == 0 {
+		log.Infof("imported eth blocks up to %d", block.NumberU64())
+	}
+	return nil
+}
+
+// totalDifficulty returns the total difficulty of the block; the export files don't carry it, so it is summed up from
+// the first block imported, whose parent has to be indexed already unless it is the genesis block
+func (is *Service) totalDifficulty(block *types.Block) (*big.Int, error) {
+	td := new(big.Int).Set(block.Difficulty())
+	if block.NumberU64() == 0 {
+		return td, nil
+	}
+	if is.last != nil && block.ParentHash() == is.last.Hash() {
+		return td.Add(td, is.lastTD), nil
+	}
+	pgStr := `SELECT td FROM eth.header_cids
+			WHERE block_hash = $1
+			LIMIT 1`
+	var tdStr string
+	if err := is.db.Get(&tdStr, pgStr, block.ParentHash().Hex()); err != nil {
+		return nil, fmt.Errorf("total difficulty of the parent of block %d is unknown, import or sync its parent first: %v", block.NumberU64(), err)
+	}
+	parentTD, ok := new(big.Int).SetString(tdStr, 10)
+	if !ok {
+		return nil, fmt.Errorf("total difficulty retrieved from Postgres cannot be converted to an integer")
+	}
+	return td.Add(td, parentTD), nil
+}
+
+// lastNumber returns the number of the last block imported, or -1 if none have been
+func (is *Service) lastNumber() int64 {
+	if is.last == nil {
+		return -1
+	}
+	return is.last.Number.Int64()
+}
+
+// Stop stops the import after the block it is importing
+func (is *Service) Stop() error {
+	log.Info("stopping eth import")
+	close(is.QuitChan)
+	return nil
+}
+
+// readBlocks decodes the blocks in the export file, gzipped if its name ends in .gz like `geth export` writes it, and
+// hands them to the callback in order
+func readBlocks(path string, fn func(block *types.Block) error) error {
+	file, err := openExportFile(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	stream := rlp.NewStream(file, 0)
+	for {
+		block := new(types.Block)
+		if err := stream.Decode(block); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := fn(block); err != nil {
+			return err
+		}
+	}
+}
+
+type exportFile struct {
+	io.Reader
+	file *os.File
+}
+
+func (ef *exportFile) Close() error {
+	return ef.file.Close()
+}
+
+func openExportFile(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, ".gz") {
+		return file, nil
+	}
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &exportFile{
+		Reader: reader,
+		file:   file,
+	}, nil
+}
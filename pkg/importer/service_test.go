@@ -0,0 +1,191 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package importer_test
+
+import (
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/importer"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddress = crypto.PubkeyToAddress(testKey.PublicKey)
+	testGenesis = &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{testAddress: {Balance: big.NewInt(params.Ether)}},
+	}
+)
+
+// generateChain returns the genesis block and a chain of blocks on top of it with a transfer in every block, along with
+// their receipts
+func generateChain(n int) ([]*types.Block, []types.Receipts) {
+	db := rawdb.NewMemoryDatabase()
+	genesis := testGenesis.MustCommit(db)
+	signer := types.NewEIP155Signer(params.TestChainConfig.ChainID)
+	blocks, receipts := core.GenerateChain(params.TestChainConfig, genesis, ethash.NewFaker(), db, n, func(i int, gen *core.BlockGen) {
+		tx, err := types.SignTx(types.NewTransaction(gen.TxNonce(testAddress), common.HexToAddress("0xdeadbeef"), big.NewInt(1000), params.TxGas, big.NewInt(1), nil), signer, testKey)
+		Expect(err).ToNot(HaveOccurred())
+		gen.AddTx(tx)
+	})
+	return append([]*types.Block{genesis}, blocks...), append([]types.Receipts{{}}, receipts...)
+}
+
+// writeRLPFile rlp encodes the items one after the other into the file, the way `geth export` writes blocks, gzipping
+// them if the file name ends in .gz
+func writeRLPFile(path string, items ...interface{}) {
+	file, err := os.Create(path)
+	Expect(err).ToNot(HaveOccurred())
+	defer file.Close()
+	var writer io.Writer = file
+	if filepath.Ext(path) == ".gz" {
+		gzWriter := gzip.NewWriter(file)
+		defer gzWriter.Close()
+		writer = gzWriter
+	}
+	for _, item := range items {
+		Expect(rlp.Encode(writer, item)).To(Succeed())
+	}
+}
+
+// storageReceipts converts the receipts into the encoding geth stores them in
+func storageReceipts(receipts types.Receipts) []*types.ReceiptForStorage {
+	storage := make([]*types.ReceiptForStorage, len(receipts))
+	for i, receipt := range receipts {
+		storage[i] = (*types.ReceiptForStorage)(receipt)
+	}
+	return storage
+}
+
+func newImportService(receipts importer.ReceiptSource, publisher *mocks.IterativeIPLDPublisher, indexer *mocks.CIDIndexer, files ...string) *importer.Service {
+	return &importer.Service{
+		Converter: eth.NewPayloadConverter(params.TestChainConfig),
+		Publisher: publisher,
+		Indexer:   indexer,
+		Receipts:  receipts,
+		Files:     files,
+		QuitChan:  make(chan bool),
+	}
+}
+
+var _ = Describe("Import", func() {
+	var (
+		dir       string
+		blocks    []*types.Block
+		receipts  []types.Receipts
+		publisher *mocks.IterativeIPLDPublisher
+		indexer   *mocks.CIDIndexer
+	)
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "eth_import")
+		Expect(err).ToNot(HaveOccurred())
+		blocks, receipts = generateChain(4)
+		publisher = &mocks.IterativeIPLDPublisher{
+			ReturnCIDPayload: make([]*eth.CIDPayload, len(blocks)),
+		}
+		for i := range blocks {
+			publisher.ReturnCIDPayload[i] = mocks.MockCIDPayload
+		}
+		indexer = new(mocks.CIDIndexer)
+	})
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	// expectImported checks that all of the generated blocks were converted, with their receipts and without state
+	expectImported := func() {
+		Expect(len(publisher.PassedIPLDPayload)).To(Equal(len(blocks)))
+		Expect(len(indexer.PassedCIDPayload)).To(Equal(len(blocks)))
+		td := new(big.Int)
+		for i, payload := range publisher.PassedIPLDPayload {
+			td.Add(td, blocks[i].Difficulty())
+			Expect(payload.Block.Hash()).To(Equal(blocks[i].Hash()))
+			Expect(payload.TotalDifficulty).To(Equal(td))
+			Expect(payload.StateAbsent).To(BeTrue())
+			Expect(payload.StateNodes).To(BeEmpty())
+			Expect(payload.StorageNodes).To(BeEmpty())
+			Expect(len(payload.TxMetaData)).To(Equal(len(blocks[i].Transactions())))
+			Expect(len(payload.Receipts)).To(Equal(len(receipts[i])))
+			Expect(types.DeriveSha(payload.Receipts)).To(Equal(blocks[i].ReceiptHash()))
+			for j, receipt := range payload.Receipts {
+				Expect(receipt.TxHash).To(Equal(blocks[i].Transactions()[j].Hash()))
+				Expect(receipt.Status).To(Equal(types.ReceiptStatusSuccessful))
+			}
+		}
+	}
+
+	It("Re-executes the exported blocks to produce their receipts", func() {
+		exportFile := filepath.Join(dir, "export.rlp")
+		writeRLPFile(exportFile, blocks[0], blocks[1], blocks[2], blocks[3], blocks[4])
+		executor, chainConfig, err := importer.NewExecutor("", testGenesis)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(chainConfig).To(Equal(params.TestChainConfig))
+		Expect(newImportService(executor, publisher, indexer, exportFile).Import()).To(Succeed())
+		expectImported()
+	})
+
+	It("Reads the receipts of the exported blocks from receipts files", func() {
+		exportFiles := []string{filepath.Join(dir, "export1.rlp"), filepath.Join(dir, "export2.rlp.gz")}
+		writeRLPFile(exportFiles[0], blocks[0], blocks[1])
+		writeRLPFile(exportFiles[1], blocks[2], blocks[3], blocks[4])
+		receiptsFiles := []string{filepath.Join(dir, "receipts1.rlp.gz"), filepath.Join(dir, "receipts2.rlp")}
+		writeRLPFile(receiptsFiles[0], storageReceipts(receipts[0]), storageReceipts(receipts[1]), storageReceipts(receipts[2]))
+		writeRLPFile(receiptsFiles[1], storageReceipts(receipts[3]), storageReceipts(receipts[4]))
+		service := newImportService(importer.NewReceiptFiles(receiptsFiles), publisher, indexer, exportFiles...)
+		Expect(service.Import()).To(Succeed())
+		expectImported()
+	})
+
+	It("Errors when the receipts don't match the blocks", func() {
+		exportFile := filepath.Join(dir, "export.rlp")
+		writeRLPFile(exportFile, blocks[0], blocks[1], blocks[2])
+		receiptsFile := filepath.Join(dir, "receipts.rlp")
+		// the receipts of the genesis block are missing, so every block is handed the receipts of the block after it
+		writeRLPFile(receiptsFile, storageReceipts(receipts[1]), storageReceipts(receipts[2]))
+		service := newImportService(importer.NewReceiptFiles([]string{receiptsFile}), publisher, indexer, exportFile)
+		Expect(service.Import()).ToNot(Succeed())
+		Expect(indexer.PassedCIDPayload).To(BeEmpty())
+	})
+
+	It("Errors when the genesis block doesn't match the configured genesis", func() {
+		exportFile := filepath.Join(dir, "export.rlp")
+		writeRLPFile(exportFile, blocks[0], blocks[1])
+		executor, _, err := importer.NewExecutor("", core.DefaultGenesisBlock())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(newImportService(executor, publisher, indexer, exportFile).Import()).ToNot(Succeed())
+		Expect(indexer.PassedCIDPayload).To(BeEmpty())
+	})
+})
@@ -18,6 +18,7 @@ package ipfs
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/sirupsen/logrus"
 
@@ -62,13 +63,29 @@ func InitIPFSBlockService(ipfsPath string) (blockservice.BlockService, error) {
 	return ipfsNode.Blocks, nil
 }
 
+// InitRemoteBlockService returns a block service for the blockstore of the IPFS daemon with the given HTTP API address
+// No local repo is opened, so the daemon can keep running against its own repo
+func InitRemoteBlockService(apiAddr string) (blockservice.BlockService, error) {
+	logrus.Debug("initializing remote IPFS block service interface")
+	bs, err := NewHTTPBlockstore(apiAddr)
+	if err != nil {
+		return nil, err
+	}
+	version, err := bs.Version()
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach IPFS daemon at %s: %v", apiAddr, err)
+	}
+	logrus.Infof("using IPFS daemon version %s at %s", version, apiAddr)
+	// the daemon checks for the block itself, so the block service doesn't have to ask for it first
+	return blockservice.NewWriteThrough(bs, nil), nil
+}
+
 type IPFS struct {
-	n   *core.IpfsNode
-	ctx context.Context
+	blocks blockservice.BlockService
 }
 
 func (ipfs IPFS) Add(node ipld.Node) error {
-	return ipfs.n.DAG.Add(ipfs.n.Context(), node)
+	return ipfs.blocks.AddBlock(node)
 }
 
 func InitIPFSNode(repoPath string) (*IPFS, error) {
@@ -86,5 +103,14 @@ func InitIPFSNode(repoPath string) (*IPFS, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &IPFS{n: ipfsNode, ctx: ctx}, nil
+	return &IPFS{blocks: ipfsNode.Blocks}, nil
+}
+
+// InitRemoteIPFSNode returns an IPFS node interface that adds the nodes to the IPFS daemon with the given HTTP API address
+func InitRemoteIPFSNode(apiAddr string) (*IPFS, error) {
+	blockService, err := InitRemoteBlockService(apiAddr)
+	if err != nil {
+		return nil, err
+	}
+	return &IPFS{blocks: blockService}, nil
 }
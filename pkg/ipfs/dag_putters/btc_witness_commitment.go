@@ -0,0 +1,46 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package dag_putters
+
+import (
+	"fmt"
+	"strings"
+
+	node "github.com/ipfs/go-ipld-format"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
+)
+
+type BtcWitnessCommitmentDagPutter struct {
+	adder *ipfs.IPFS
+}
+
+func NewBtcWitnessCommitmentDagPutter(adder *ipfs.IPFS) *BtcWitnessCommitmentDagPutter {
+	return &BtcWitnessCommitmentDagPutter{adder: adder}
+}
+
+func (wcdp *BtcWitnessCommitmentDagPutter) DagPut(n node.Node) (string, error) {
+	commitmentNode, ok := n.(*ipld.BtcWitnessCommitment)
+	if !ok {
+		return "", fmt.Errorf("BtcWitnessCommitmentDagPutter expected input type %T got %T", &ipld.BtcWitnessCommitment{}, n)
+	}
+	if err := wcdp.adder.Add(commitmentNode); err != nil && !strings.Contains(err.Error(), duplicateKeyErrorString) {
+		return "", err
+	}
+	return commitmentNode.Cid().String(), nil
+}
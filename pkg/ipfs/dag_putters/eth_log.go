@@ -0,0 +1,46 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package dag_putters
+
+import (
+	"fmt"
+	"strings"
+
+	node "github.com/ipfs/go-ipld-format"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
+)
+
+type EthLogDagPutter struct {
+	adder *ipfs.IPFS
+}
+
+func NewEthLogDagPutter(adder *ipfs.IPFS) *EthLogDagPutter {
+	return &EthLogDagPutter{adder: adder}
+}
+
+func (eldp *EthLogDagPutter) DagPut(n node.Node) (string, error) {
+	log, ok := n.(*ipld.EthLog)
+	if !ok {
+		return "", fmt.Errorf("EthLogDagPutter expected input type %T got type %T", &ipld.EthLog{}, n)
+	}
+	if err := eldp.adder.Add(log); err != nil && !strings.Contains(err.Error(), duplicateKeyErrorString) {
+		return "", err
+	}
+	return log.Cid().String(), nil
+}
@@ -0,0 +1,332 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package ipfs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultHTTPTimeout is the timeout for a single request to the IPFS HTTP API
+const DefaultHTTPTimeout = time.Minute
+
+// HTTPBlockstore is a blockstore.Blockstore backed by the blockstore of an IPFS daemon, accessed through its HTTP API
+// Blocks are only ever looked up in the daemon's own blockstore, the daemon is never asked to search the network for them
+type HTTPBlockstore struct {
+	apiURL string
+	client *http.Client
+}
+
+// NewHTTPBlockstore creates an HTTPBlockstore for the IPFS daemon with the given API address, e.g. "http://127.0.0.1:5001"
+func NewHTTPBlockstore(apiAddr string) (*HTTPBlockstore, error) {
+	apiURL, err := normalizeAPIAddr(apiAddr)
+	if err != nil {
+		return nil, err
+	}
+	return &HTTPBlockstore{
+		apiURL: apiURL,
+		client: &http.Client{Timeout: DefaultHTTPTimeout},
+	}, nil
+}
+
+// normalizeAPIAddr turns a host:port or URL into the base URL of the API
+func normalizeAPIAddr(apiAddr string) (string, error) {
+	if apiAddr == "" {
+		return "", fmt.Errorf("no IPFS API address provided")
+	}
+	if !strings.Contains(apiAddr, "://") {
+		apiAddr = "http://" + apiAddr
+	}
+	u, err := url.Parse(apiAddr)
+	if err != nil {
+		return "", fmt.Errorf("invalid IPFS API address %s: %v", apiAddr, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid IPFS API address %s: unsupported scheme %s", apiAddr, u.Scheme)
+	}
+	return strings.TrimSuffix(u.String(), "/") + "/api/v0", nil
+}
+
+// apiError is the body the API responds with when a command fails
+type apiError struct {
+	Message string
+	Code    int
+}
+
+// request posts a command to the API and returns the response body, which the caller has to close
+// The API only accepts POST requests
+func (bs *HTTPBlockstore) request(ctx context.Context, command string, args url.Values, body io.Reader, contentType string) (io.ReadCloser, error) {
+	endpoint := bs.apiURL + "/" + command
+	if len(args) > 0 {
+		endpoint += "?" + args.Encode()
+	}
+	req, err := http.NewRequest(http.MethodPost, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	res, err := bs.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		resBody, _ := ioutil.ReadAll(res.Body)
+		var apiErr apiError
+		if err := json.Unmarshal(resBody, &apiErr); err == nil && apiErr.Message != "" {
+			return nil, fmt.Errorf("ipfs api %s: %s", command, apiErr.Message)
+		}
+		return nil, fmt.Errorf("ipfs api %s: %s %s", command, res.Status, strings.TrimSpace(string(resBody)))
+	}
+	return res.Body, nil
+}
+
+// offlineArgs returns the arguments for a command on the cid that is to be answered from the daemon's blockstore only
+func offlineArgs(c cid.Cid) url.Values {
+	return url.Values{
+		"arg":     {c.String()},
+		"offline": {"true"},
+	}
+}
+
+// isNotFound returns whether the API error message is the daemon reporting a block it does not have
+func isNotFound(message string) bool {
+	return strings.Contains(message, "not found")
+}
+
+// Version returns the version of the daemon, it is used to check that the daemon can be reached
+func (bs *HTTPBlockstore) Version() (string, error) {
+	body, err := bs.request(context.Background(), "version", nil, nil, "")
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	var version struct {
+		Version string
+	}
+	if err := json.NewDecoder(body).Decode(&version); err != nil {
+		return "", err
+	}
+	return version.Version, nil
+}
+
+// DeleteBlock satisfies the blockstore.Blockstore interface
+func (bs *HTTPBlockstore) DeleteBlock(c cid.Cid) error {
+	body, err := bs.request(context.Background(), "block/rm", url.Values{"arg": {c.String()}}, nil, "")
+	if err != nil {
+		if isNotFound(err.Error()) {
+			return blockstore.ErrNotFound
+		}
+		return err
+	}
+	defer body.Close()
+	var res struct {
+		Hash  string
+		Error string
+	}
+	if err := json.NewDecoder(body).Decode(&res); err != nil {
+		return err
+	}
+	if res.Error != "" {
+		if isNotFound(res.Error) {
+			return blockstore.ErrNotFound
+		}
+		return fmt.Errorf("ipfs api block/rm: %s", res.Error)
+	}
+	return nil
+}
+
+// Has satisfies the blockstore.Blockstore interface
+func (bs *HTTPBlockstore) Has(c cid.Cid) (bool, error) {
+	_, err := bs.GetSize(c)
+	if err == blockstore.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Get satisfies the blockstore.Blockstore interface
+// The block is always checked against its cid, since it has come over the wire
+func (bs *HTTPBlockstore) Get(c cid.Cid) (blocks.Block, error) {
+	body, err := bs.request(context.Background(), "block/get", offlineArgs(c), nil, "")
+	if err != nil {
+		if isNotFound(err.Error()) {
+			return nil, blockstore.ErrNotFound
+		}
+		return nil, err
+	}
+	defer body.Close()
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !sum.Equals(c) {
+		return nil, blockstore.ErrHashMismatch
+	}
+	return blocks.NewBlockWithCid(data, c)
+}
+
+// GetSize satisfies the blockstore.Blockstore interface
+func (bs *HTTPBlockstore) GetSize(c cid.Cid) (int, error) {
+	body, err := bs.request(context.Background(), "block/stat", offlineArgs(c), nil, "")
+	if err != nil {
+		if isNotFound(err.Error()) {
+			return -1, blockstore.ErrNotFound
+		}
+		return -1, err
+	}
+	defer body.Close()
+	var stat struct {
+		Key  string
+		Size int
+	}
+	if err := json.NewDecoder(body).Decode(&stat); err != nil {
+		return -1, err
+	}
+	return stat.Size, nil
+}
+
+// Put satisfies the blockstore.Blockstore interface
+// The daemon is told the codec and multihash of the block's cid, so that it stores the block under the same cid
+// Codecs the daemon has no name for are put as raw blocks with the same multihash, which daemons that key their
+// blockstore by multihash serve under any cid with that multihash
+func (bs *HTTPBlockstore) Put(block blocks.Block) error {
+	prefix := block.Cid().Prefix()
+	mhType, ok := mh.Codes[prefix.MhType]
+	if !ok {
+		return fmt.Errorf("ipfs api block/put: unknown multihash type %d", prefix.MhType)
+	}
+	format, ok := cid.CodecToStr[prefix.Codec]
+	if !ok {
+		format = "raw"
+	}
+	args := url.Values{
+		"format": {format},
+		"mhtype": {mhType},
+		"mhlen":  {strconv.Itoa(prefix.MhLength)},
+	}
+	buf := new(bytes.Buffer)
+	form := multipart.NewWriter(buf)
+	part, err := form.CreateFormFile("file", block.Cid().String())
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(block.RawData()); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+	body, err := bs.request(context.Background(), "block/put", args, buf, form.FormDataContentType())
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	var res struct {
+		Key  string
+		Size int
+	}
+	if err := json.NewDecoder(body).Decode(&res); err != nil {
+		return err
+	}
+	key, err := cid.Decode(res.Key)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(key.Hash(), block.Cid().Hash()) {
+		return fmt.Errorf("ipfs api block/put: daemon stored block %s under %s", block.Cid().String(), res.Key)
+	}
+	return nil
+}
+
+// PutMany satisfies the blockstore.Blockstore interface
+func (bs *HTTPBlockstore) PutMany(blocks []blocks.Block) error {
+	for _, block := range blocks {
+		if err := bs.Put(block); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AllKeysChan satisfies the blockstore.Blockstore interface
+func (bs *HTTPBlockstore) AllKeysChan(ctx context.Context) (<-chan cid.Cid, error) {
+	body, err := bs.request(ctx, "refs/local", nil, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	keys := make(chan cid.Cid)
+	go func() {
+		defer close(keys)
+		defer body.Close()
+		decoder := json.NewDecoder(body)
+		for {
+			var ref struct {
+				Ref string
+				Err string
+			}
+			if err := decoder.Decode(&ref); err != nil {
+				if err != io.EOF {
+					logrus.Errorf("ipfs api refs/local: %s", err.Error())
+				}
+				return
+			}
+			if ref.Err != "" {
+				logrus.Errorf("ipfs api refs/local: %s", ref.Err)
+				continue
+			}
+			c, err := cid.Decode(ref.Ref)
+			if err != nil {
+				logrus.Errorf("ipfs api refs/local: %s", err.Error())
+				continue
+			}
+			select {
+			case keys <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return keys, nil
+}
+
+// HashOnRead satisfies the blockstore.Blockstore interface
+// Blocks read from the daemon are always rehashed, so it is a no-op
+func (bs *HTTPBlockstore) HashOnRead(enabled bool) {}
@@ -0,0 +1,147 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package ipfs_test
+
+import (
+	"context"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/dag_putters"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/mocks"
+)
+
+var (
+	mockHeader = &types.Header{
+		Number:     big.NewInt(1),
+		Difficulty: big.NewInt(100),
+		GasLimit:   8000000,
+		Time:       1600000000,
+	}
+	mockLog = &types.Log{
+		Address: common.HexToAddress("0xaE9BEa628c4Ce503DcFD7E305CaB4e29E7476592"),
+		Topics:  []common.Hash{common.HexToHash("0x04")},
+		Data:    []byte{1, 2, 3},
+	}
+)
+
+var _ = Describe("HTTPBlockstore", func() {
+	var (
+		server     *mocks.IPFSAPIServer
+		bs         *ipfs.HTTPBlockstore
+		headerNode *ipld.EthHeader
+		logNode    *ipld.EthLog
+	)
+	BeforeEach(func() {
+		var err error
+		server = mocks.NewIPFSAPIServer()
+		bs, err = ipfs.NewHTTPBlockstore(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		headerNode, err = ipld.NewEthHeader(mockHeader)
+		Expect(err).ToNot(HaveOccurred())
+		logNode, err = ipld.NewLog(mockLog)
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("Puts blocks to the daemon and gets them back under their cid", func() {
+		Expect(bs.Put(headerNode)).To(Succeed())
+		has, err := bs.Has(headerNode.Cid())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(has).To(BeTrue())
+		size, err := bs.GetSize(headerNode.Cid())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(size).To(Equal(len(headerNode.RawData())))
+		block, err := bs.Get(headerNode.Cid())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(block.Cid()).To(Equal(headerNode.Cid()))
+		Expect(block.RawData()).To(Equal(headerNode.RawData()))
+	})
+
+	It("Puts blocks with codecs the daemon doesn't know under the same multihash", func() {
+		Expect(bs.Put(logNode)).To(Succeed())
+		block, err := bs.Get(logNode.Cid())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(block.Cid()).To(Equal(logNode.Cid()))
+		Expect(block.RawData()).To(Equal(logNode.RawData()))
+	})
+
+	It("Reports blocks the daemon doesn't have as not found", func() {
+		has, err := bs.Has(headerNode.Cid())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(has).To(BeFalse())
+		_, err = bs.Get(headerNode.Cid())
+		Expect(err).To(Equal(blockstore.ErrNotFound))
+		_, err = bs.GetSize(headerNode.Cid())
+		Expect(err).To(Equal(blockstore.ErrNotFound))
+	})
+
+	It("Lists and deletes blocks", func() {
+		Expect(bs.PutMany([]blocks.Block{headerNode, logNode})).To(Succeed())
+		keys, err := bs.AllKeysChan(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		var hashes []string
+		for key := range keys {
+			hashes = append(hashes, string(key.Hash()))
+		}
+		Expect(hashes).To(ConsistOf(string(headerNode.Cid().Hash()), string(logNode.Cid().Hash())))
+		Expect(bs.DeleteBlock(headerNode.Cid())).To(Succeed())
+		Expect(server.Len()).To(Equal(1))
+		Expect(bs.DeleteBlock(headerNode.Cid())).To(Equal(blockstore.ErrNotFound))
+	})
+
+	It("Accepts the API address without a scheme", func() {
+		var err error
+		bs, err = ipfs.NewHTTPBlockstore(server.Listener.Addr().String())
+		Expect(err).ToNot(HaveOccurred())
+		version, err := bs.Version()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(version).ToNot(BeEmpty())
+	})
+
+	It("Publishes and fetches through a remote IPFS node", func() {
+		node, err := ipfs.InitRemoteIPFSNode(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		headerCID, err := dag_putters.NewEthBlockHeaderDagPutter(node).DagPut(headerNode)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(headerCID).To(Equal(headerNode.Cid().String()))
+		blockService, err := ipfs.InitRemoteBlockService(server.URL)
+		Expect(err).ToNot(HaveOccurred())
+		c, err := cid.Decode(headerCID)
+		Expect(err).ToNot(HaveOccurred())
+		block, err := blockService.GetBlock(context.Background(), c)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(block.RawData()).To(Equal(headerNode.RawData()))
+	})
+
+	It("Fails to initialize when the daemon can't be reached", func() {
+		server.Close()
+		_, err := ipfs.InitRemoteBlockService(server.URL)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -19,6 +19,7 @@ package ipld
 import (
 	"bytes"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 
 	"github.com/btcsuite/btcd/wire"
@@ -59,6 +60,24 @@ func NewBtcHeader(header *wire.BlockHeader) (*BtcHeader, error) {
 	}, nil
 }
 
+/*
+ OUTPUT
+*/
+
+// DecodeBtcHeader takes a cid and its raw binary data
+// from IPFS and returns a BtcHeader object for further processing.
+func DecodeBtcHeader(c cid.Cid, b []byte) (*BtcHeader, error) {
+	header := new(wire.BlockHeader)
+	if err := header.Deserialize(bytes.NewReader(b)); err != nil {
+		return nil, err
+	}
+	return &BtcHeader{
+		BlockHeader: header,
+		cid:         c,
+		rawdata:     b,
+	}, nil
+}
+
 /*
    Block INTERFACE
 */
@@ -174,6 +193,20 @@ func (b *BtcHeader) Copy() node.Node {
 	return &nb
 }
 
+// MarshalJSON processes the block header into readable JSON format,
+// converting the links into their cids.
+func (b *BtcHeader) MarshalJSON() ([]byte, error) {
+	out := map[string]interface{}{
+		"version":   b.Version,
+		"timestamp": b.Timestamp,
+		"bits":      b.Bits,
+		"nonce":     b.Nonce,
+		"parent":    sha256ToCid(MBitcoinHeader, b.PrevBlock.CloneBytes()),
+		"tx":        sha256ToCid(MBitcoinTx, b.MerkleRoot.CloneBytes()),
+	}
+	return json.Marshal(out)
+}
+
 func revString(s []byte) []byte {
 	b := make([]byte, len(s))
 	for i, v := range []byte(s) {
@@ -17,8 +17,10 @@
 package ipld
 
 import (
+	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/wire"
 	"github.com/btcsuite/btcutil"
+	"github.com/ipfs/go-cid"
 	node "github.com/ipfs/go-ipld-format"
 )
 
@@ -33,42 +35,78 @@ func FromHeaderAndTxs(header *wire.BlockHeader, txs []*btcutil.Tx) (*BtcHeader,
 		}
 		txNodes = append(txNodes, txNode)
 	}
-	txTrie, err := mkMerkleTree(txNodes)
-	if err != nil {
-		return nil, nil, nil, err
-	}
+	txTrie := mkMerkleTree(txCids(txNodes))
 	headerNode, err := NewBtcHeader(header)
 	return headerNode, txNodes, txTrie, err
 }
 
-func mkMerkleTree(txs []*BtcTx) ([]*BtcTxTrie, error) {
-	layer := make([]node.Node, len(txs))
+// FromWitnessTxs returns the IPLDs of the transactions including their witness data, the nodes of the block's witness
+// merkle tree, and the witness commitment linking the coinbase to the tree's root
+// The witness tree and commitment are nil for blocks whose coinbase carries no witness commitment
+func FromWitnessTxs(txNodes []*BtcTx) ([]*BtcTx, []*BtcTxTrie, *BtcWitnessCommitment, error) {
+	witnessTxNodes := make([]*BtcTx, len(txNodes))
+	for i, txNode := range txNodes {
+		if !txNode.HasWitness() {
+			witnessTxNodes[i] = txNode
+			continue
+		}
+		witnessTxNode, err := NewBtcWitnessTx(txNode.MsgTx)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		witnessTxNodes[i] = witnessTxNode
+	}
+	if len(txNodes) == 0 {
+		return witnessTxNodes, nil, nil, nil
+	}
+	coinbase := txNodes[0].MsgTx
+	if _, ok := blockchain.ExtractWitnessCommitment(btcutil.NewTx(coinbase)); !ok || len(coinbase.TxIn) == 0 || len(coinbase.TxIn[0].Witness) != 1 {
+		return witnessTxNodes, nil, nil, nil
+	}
+	// the coinbase's wtxid is taken to be all zeros
+	leaves := make([]cid.Cid, len(witnessTxNodes))
+	leaves[0] = sha256ToCid(MBitcoinTx, make([]byte, 32))
+	for i, witnessTxNode := range witnessTxNodes[1:] {
+		leaves[i+1] = witnessTxNode.Cid()
+	}
+	witnessTrie := mkMerkleTree(leaves)
+	root := leaves[0]
+	if len(witnessTrie) > 0 {
+		root = witnessTrie[len(witnessTrie)-1].Cid()
+	}
+	commitment, err := NewBtcWitnessCommitment(root, coinbase.TxIn[0].Witness[0])
+	return witnessTxNodes, witnessTrie, commitment, err
+}
+
+func txCids(txs []*BtcTx) []cid.Cid {
+	cids := make([]cid.Cid, len(txs))
 	for i, tx := range txs {
-		layer[i] = tx
+		cids[i] = tx.Cid()
 	}
+	return cids
+}
+
+func mkMerkleTree(leaves []cid.Cid) []*BtcTxTrie {
+	layer := leaves
 	var out []*BtcTxTrie
-	var next []node.Node
+	var next []cid.Cid
 	for len(layer) > 1 {
 		if len(layer)%2 != 0 {
 			layer = append(layer, layer[len(layer)-1])
 		}
 		for i := 0; i < len(layer)/2; i++ {
-			var left, right node.Node
-			left = layer[i*2]
-			right = layer[(i*2)+1]
-
 			t := &BtcTxTrie{
-				Left:  &node.Link{Cid: left.Cid()},
-				Right: &node.Link{Cid: right.Cid()},
+				Left:  &node.Link{Cid: layer[i*2]},
+				Right: &node.Link{Cid: layer[(i*2)+1]},
 			}
 
 			out = append(out, t)
-			next = append(next, t)
+			next = append(next, t.Cid())
 		}
 
 		layer = next
 		next = nil
 	}
 
-	return out, nil
+	return out
 }
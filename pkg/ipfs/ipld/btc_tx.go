@@ -19,10 +19,13 @@ package ipld
 import (
 	"bytes"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"strconv"
 
+	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
 	"github.com/ipfs/go-cid"
 	node "github.com/ipfs/go-ipld-format"
 	mh "github.com/multiformats/go-multihash"
@@ -43,12 +46,26 @@ var _ node.Node = (*BtcTx)(nil)
 */
 
 // NewBtcTx converts a *wire.MsgTx into an BtcTx IPLD node
+// NewBtcTx returns the IPLD of the transaction without its witness data, its cid is derived from the txid
 func NewBtcTx(tx *wire.MsgTx) (*BtcTx, error) {
+	w := bytes.NewBuffer(make([]byte, 0, tx.SerializeSizeStripped()))
+	if err := tx.SerializeNoWitness(w); err != nil {
+		return nil, err
+	}
+	return newBtcTx(tx, w.Bytes())
+}
+
+// NewBtcWitnessTx returns the IPLD of the transaction including its witness data, its cid is derived from the wtxid
+// For a transaction without witness data this is the same as the IPLD returned by NewBtcTx
+func NewBtcWitnessTx(tx *wire.MsgTx) (*BtcTx, error) {
 	w := bytes.NewBuffer(make([]byte, 0, tx.SerializeSize()))
 	if err := tx.Serialize(w); err != nil {
 		return nil, err
 	}
-	rawdata := w.Bytes()
+	return newBtcTx(tx, w.Bytes())
+}
+
+func newBtcTx(tx *wire.MsgTx, rawdata []byte) (*BtcTx, error) {
 	c, err := RawdataToCid(MBitcoinTx, rawdata, mh.DBL_SHA2_256)
 	if err != nil {
 		return nil, err
@@ -60,6 +77,28 @@ func NewBtcTx(tx *wire.MsgTx) (*BtcTx, error) {
 	}, nil
 }
 
+/*
+ OUTPUT
+*/
+
+// DecodeBtcTx takes a cid and its raw binary data, with or without
+// witness data, from IPFS and returns a BtcTx object for further processing.
+func DecodeBtcTx(c cid.Cid, b []byte) (*BtcTx, error) {
+	tx := new(wire.MsgTx)
+	r := bytes.NewReader(b)
+	if err := tx.Deserialize(r); err != nil {
+		return nil, err
+	}
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%d bytes left after the transaction", r.Len())
+	}
+	return &BtcTx{
+		MsgTx:   tx,
+		cid:     c,
+		rawdata: b,
+	}, nil
+}
+
 /*
    Block INTERFACE
 */
@@ -93,15 +132,34 @@ func (t *BtcTx) Links() []*node.Link {
 		lnk.Name = fmt.Sprintf("inputs/%d/prevTx", i)
 		out = append(out, lnk)
 	}
+	if lnk, ok := t.witnessCommitmentLink(); ok {
+		lnk.Name = "witnessCommitment"
+		out = append(out, lnk)
+	}
 	return out
 }
 
+// witnessCommitmentLink returns the link to the witness commitment of the block, if this is a coinbase that carries one
+func (t *BtcTx) witnessCommitmentLink() (*node.Link, bool) {
+	commitment, ok := blockchain.ExtractWitnessCommitment(btcutil.NewTx(t.MsgTx))
+	if !ok {
+		return nil, false
+	}
+	return &node.Link{Cid: sha256ToCid(MBitcoinWitnessCommitment, commitment)}, true
+}
+
 func (t *BtcTx) Resolve(path []string) (interface{}, []string, error) {
 	switch path[0] {
 	case "version":
 		return t.Version, path[1:], nil
 	case "lockTime":
 		return t.LockTime, path[1:], nil
+	case "witnessCommitment":
+		lnk, ok := t.witnessCommitmentLink()
+		if !ok {
+			return nil, nil, fmt.Errorf("no such link")
+		}
+		return lnk, path[1:], nil
 	case "inputs":
 		if len(path) == 1 {
 			return t.MsgTx.TxIn, nil, nil
@@ -210,6 +268,9 @@ func (t *BtcTx) Tree(p string, depth int) []string {
 		return t.treeOutputs(nil, depth+1)
 	case "":
 		out := []string{"version", "timeLock", "inputs", "outputs"}
+		if _, ok := t.witnessCommitmentLink(); ok {
+			out = append(out, "witnessCommitment")
+		}
 		out = t.treeInputs(out, depth)
 		out = t.treeOutputs(out, depth)
 		return out
@@ -256,3 +317,33 @@ func (t *BtcTx) BTCSha() []byte {
 func (t *BtcTx) HexHash() string {
 	return hex.EncodeToString(revString(t.BTCSha()))
 }
+
+// MarshalJSON processes the transaction into readable JSON format,
+// converting the links into their cids.
+func (t *BtcTx) MarshalJSON() ([]byte, error) {
+	inputs := make([]map[string]interface{}, len(t.TxIn))
+	for i, in := range t.TxIn {
+		inputs[i] = map[string]interface{}{
+			"prevTx": sha256ToCid(MBitcoinTx, in.PreviousOutPoint.Hash.CloneBytes()),
+			"seqNo":  in.Sequence,
+			"script": fmt.Sprintf("0x%x", in.SignatureScript),
+		}
+	}
+	outputs := make([]map[string]interface{}, len(t.TxOut))
+	for i, outp := range t.TxOut {
+		outputs[i] = map[string]interface{}{
+			"value":  outp.Value,
+			"script": fmt.Sprintf("0x%x", outp.PkScript),
+		}
+	}
+	out := map[string]interface{}{
+		"version":  t.Version,
+		"lockTime": t.LockTime,
+		"inputs":   inputs,
+		"outputs":  outputs,
+	}
+	if lnk, ok := t.witnessCommitmentLink(); ok {
+		out["witnessCommitment"] = lnk.Cid
+	}
+	return json.Marshal(out)
+}
@@ -17,6 +17,7 @@
 package ipld
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/ipfs/go-cid"
@@ -29,6 +30,18 @@ type BtcTxTrie struct {
 	Right *node.Link
 }
 
+// DecodeBtcTxTrie takes a cid and its raw binary data, the hashes
+// of its two children, from IPFS and returns a BtcTxTrie object for further processing.
+func DecodeBtcTxTrie(c cid.Cid, b []byte) (*BtcTxTrie, error) {
+	if len(b) != 64 {
+		return nil, fmt.Errorf("bitcoin merkle node must be 64 bytes, got %d", len(b))
+	}
+	return &BtcTxTrie{
+		Left:  &node.Link{Cid: hashToCid(b[:32], MBitcoinTx)},
+		Right: &node.Link{Cid: hashToCid(b[32:], MBitcoinTx)},
+	}, nil
+}
+
 func (t *BtcTxTrie) BTCSha() []byte {
 	return cidToHash(t.Cid())
 }
@@ -108,3 +121,12 @@ func (t *BtcTxTrie) String() string {
 func (t *BtcTxTrie) Tree(p string, depth int) []string {
 	return []string{"0", "1"}
 }
+
+// MarshalJSON processes the merkle node into readable JSON format,
+// converting the links into their cids.
+func (t *BtcTxTrie) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"0": t.Left.Cid,
+		"1": t.Right.Cid,
+	})
+}
@@ -0,0 +1,171 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package ipld
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-format"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// BtcWitnessCommitment is the IPLD of a block's witness commitment, the root of its witness merkle tree and the
+// witness reserved value from its coinbase; its double sha256 hash is the commitment carried by the coinbase
+type BtcWitnessCommitment struct {
+	Root  *node.Link
+	Nonce []byte
+
+	rawdata []byte
+	cid     cid.Cid
+}
+
+// Static (compile time) check that BtcWitnessCommitment satisfies the node.Node interface.
+var _ node.Node = (*BtcWitnessCommitment)(nil)
+
+/*
+  INPUT
+*/
+
+// NewBtcWitnessCommitment returns the witness commitment IPLD for the given witness merkle root and reserved value
+func NewBtcWitnessCommitment(root cid.Cid, nonce []byte) (*BtcWitnessCommitment, error) {
+	if len(nonce) != 32 {
+		return nil, fmt.Errorf("witness reserved value must be 32 bytes, got %d", len(nonce))
+	}
+	rawdata := make([]byte, 64)
+	copy(rawdata[:32], cidToHash(root))
+	copy(rawdata[32:], nonce)
+	c, err := RawdataToCid(MBitcoinWitnessCommitment, rawdata, mh.DBL_SHA2_256)
+	if err != nil {
+		return nil, err
+	}
+	return &BtcWitnessCommitment{
+		Root:    &node.Link{Cid: root},
+		Nonce:   nonce,
+		rawdata: rawdata,
+		cid:     c,
+	}, nil
+}
+
+/*
+ OUTPUT
+*/
+
+// DecodeBtcWitnessCommitment takes a cid and its raw binary data
+// from IPFS and returns a BtcWitnessCommitment object for further processing.
+func DecodeBtcWitnessCommitment(c cid.Cid, b []byte) (*BtcWitnessCommitment, error) {
+	if len(b) != 64 {
+		return nil, fmt.Errorf("witness commitment must be 64 bytes, got %d", len(b))
+	}
+	return &BtcWitnessCommitment{
+		Root:    &node.Link{Cid: hashToCid(b[:32], MBitcoinTx)},
+		Nonce:   b[32:],
+		rawdata: b,
+		cid:     c,
+	}, nil
+}
+
+/*
+   Block INTERFACE
+*/
+
+func (wc *BtcWitnessCommitment) Cid() cid.Cid {
+	return wc.cid
+}
+
+func (wc *BtcWitnessCommitment) RawData() []byte {
+	return wc.rawdata
+}
+
+func (wc *BtcWitnessCommitment) String() string {
+	return fmt.Sprintf("<BtcWitnessCommitment %s>", wc.cid)
+}
+
+func (wc *BtcWitnessCommitment) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "bitcoin_witness_commitment",
+	}
+}
+
+/*
+   Node INTERFACE
+*/
+
+func (wc *BtcWitnessCommitment) Links() []*node.Link {
+	return []*node.Link{
+		{
+			Name: "witnessMerkleRoot",
+			Cid:  wc.Root.Cid,
+		},
+	}
+}
+
+func (wc *BtcWitnessCommitment) Resolve(path []string) (interface{}, []string, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("zero length path")
+	}
+
+	switch path[0] {
+	case "witnessMerkleRoot":
+		return wc.Root, path[1:], nil
+	case "nonce":
+		return wc.Nonce, path[1:], nil
+	default:
+		return nil, nil, fmt.Errorf("no such link")
+	}
+}
+
+func (wc *BtcWitnessCommitment) ResolveLink(path []string) (*node.Link, []string, error) {
+	out, rest, err := wc.Resolve(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lnk, ok := out.(*node.Link)
+	if !ok {
+		return nil, nil, fmt.Errorf("object at path was not a link")
+	}
+
+	return lnk, rest, nil
+}
+
+func (wc *BtcWitnessCommitment) Copy() node.Node {
+	nwc := *wc
+	return &nwc
+}
+
+func (wc *BtcWitnessCommitment) Size() (uint64, error) {
+	return uint64(len(wc.rawdata)), nil
+}
+
+func (wc *BtcWitnessCommitment) Stat() (*node.NodeStat, error) {
+	return &node.NodeStat{}, nil
+}
+
+func (wc *BtcWitnessCommitment) Tree(p string, depth int) []string {
+	return []string{"witnessMerkleRoot", "nonce"}
+}
+
+// MarshalJSON processes the witness commitment into readable JSON format,
+// converting the link into its cid.
+func (wc *BtcWitnessCommitment) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"witnessMerkleRoot": wc.Root.Cid,
+		"nonce":             fmt.Sprintf("0x%x", wc.Nonce),
+	})
+}
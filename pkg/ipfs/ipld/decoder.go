@@ -0,0 +1,60 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package ipld
+
+import (
+	"fmt"
+
+	"github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-format"
+)
+
+// DecodeNode returns the IPLD node of the block with the cid and raw binary data, for the codecs of the blocks that are
+// published; the MBitcoinTx codec is shared by transactions and merkle nodes, and a 64 byte block is taken as a merkle
+// node unless all of it decodes as a transaction
+func DecodeNode(c cid.Cid, b []byte) (node.Node, error) {
+	switch c.Type() {
+	case MEthHeader:
+		return DecodeEthHeader(c, b)
+	case MEthTxTrie:
+		return DecodeEthTxTrie(c, b)
+	case MEthTx:
+		return DecodeEthTx(c, b)
+	case MEthTxReceiptTrie:
+		return DecodeEthRctTrie(c, b)
+	case MEthTxReceipt:
+		return DecodeEthReceipt(c, b)
+	case MEthStateTrie:
+		return DecodeEthStateTrie(c, b)
+	case MEthStorageTrie:
+		return DecodeEthStorageTrie(c, b)
+	case MEthLog:
+		return DecodeEthLog(c, b)
+	case MBitcoinHeader:
+		return DecodeBtcHeader(c, b)
+	case MBitcoinTx:
+		tx, err := DecodeBtcTx(c, b)
+		if err != nil && len(b) == 64 {
+			return DecodeBtcTxTrie(c, b)
+		}
+		return tx, err
+	case MBitcoinWitnessCommitment:
+		return DecodeBtcWitnessCommitment(c, b)
+	default:
+		return nil, fmt.Errorf("no decoder for codec %#x", c.Type())
+	}
+}
@@ -0,0 +1,185 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package ipld
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ipfs/go-cid"
+	node "github.com/ipfs/go-ipld-format"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// EthLog is the IPLD node for a single log emitted in a receipt
+// Only the consensus fields (address, topics, data) are encoded, the same as they are within the receipt
+type EthLog struct {
+	*types.Log
+
+	rawdata []byte
+	cid     cid.Cid
+}
+
+// Static (compile time) check that EthLog satisfies the node.Node interface.
+var _ node.Node = (*EthLog)(nil)
+
+/*
+  INPUT
+*/
+
+// NewLog converts a *types.Log to an EthLog IPLD node
+func NewLog(log *types.Log) (*EthLog, error) {
+	logRLP, err := rlp.EncodeToBytes(log)
+	if err != nil {
+		return nil, err
+	}
+	c, err := RawdataToCid(MEthLog, logRLP, mh.KECCAK_256)
+	if err != nil {
+		return nil, err
+	}
+	return &EthLog{
+		Log:     log,
+		cid:     c,
+		rawdata: logRLP,
+	}, nil
+}
+
+/*
+ OUTPUT
+*/
+
+// DecodeEthLog takes a cid and its raw binary data
+// from IPFS and returns an EthLog object for further processing.
+func DecodeEthLog(c cid.Cid, b []byte) (*EthLog, error) {
+	l := new(types.Log)
+	if err := rlp.DecodeBytes(b, l); err != nil {
+		return nil, err
+	}
+	return &EthLog{
+		Log:     l,
+		cid:     c,
+		rawdata: b,
+	}, nil
+}
+
+/*
+  Block INTERFACE
+*/
+
+func (node *EthLog) RawData() []byte {
+	return node.rawdata
+}
+
+func (node *EthLog) Cid() cid.Cid {
+	return node.cid
+}
+
+// String is a helper for output
+func (l *EthLog) String() string {
+	return fmt.Sprintf("<EthereumLog %s>", l.cid)
+}
+
+// Loggable returns in a map the type of IPLD Link.
+func (l *EthLog) Loggable() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "eth-receipt-log",
+	}
+}
+
+// Resolve resolves a path through this node, stopping at any link boundary
+// and returning the object found as well as the remaining path to traverse
+func (l *EthLog) Resolve(p []string) (interface{}, []string, error) {
+	if len(p) == 0 {
+		return l, nil, nil
+	}
+
+	if len(p) > 1 {
+		return nil, nil, fmt.Errorf("unexpected path elements past %s", p[0])
+	}
+
+	switch p[0] {
+
+	case "address":
+		return l.Address, nil, nil
+	case "topics":
+		return l.Topics, nil, nil
+	case "data":
+		return l.Data, nil, nil
+	default:
+		return nil, nil, fmt.Errorf("no such link")
+	}
+}
+
+// Tree lists all paths within the object under 'path', and up to the given depth.
+// To list the entire object (similar to `find .`) pass "" and -1
+func (l *EthLog) Tree(p string, depth int) []string {
+	if p != "" || depth == 0 {
+		return nil
+	}
+	return []string{"address", "topics", "data"}
+}
+
+// ResolveLink is a helper function that calls resolve and asserts the
+// output is a link
+func (l *EthLog) ResolveLink(p []string) (*node.Link, []string, error) {
+	obj, rest, err := l.Resolve(p)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if lnk, ok := obj.(*node.Link); ok {
+		return lnk, rest, nil
+	}
+
+	return nil, nil, fmt.Errorf("resolved item was not a link")
+}
+
+// Copy will go away. It is here to comply with the Node interface.
+func (*EthLog) Copy() node.Node {
+	panic("implement me")
+}
+
+// Links is a helper function that returns all links within this object
+func (*EthLog) Links() []*node.Link {
+	return nil
+}
+
+// Stat will go away. It is here to comply with the interface.
+func (l *EthLog) Stat() (*node.NodeStat, error) {
+	return &node.NodeStat{}, nil
+}
+
+// Size will go away. It is here to comply with the interface.
+func (l *EthLog) Size() (uint64, error) {
+	return uint64(len(l.rawdata)), nil
+}
+
+/*
+  EthLog functions
+*/
+
+// MarshalJSON processes the log into readable JSON format.
+func (l *EthLog) MarshalJSON() ([]byte, error) {
+	out := map[string]interface{}{
+		"address": l.Address,
+		"topics":  l.Topics,
+		"data":    l.Data,
+	}
+	return json.Marshal(out)
+}
@@ -66,7 +66,7 @@ func NewReceipt(receipt *types.Receipt) (*EthReceipt, error) {
 // DecodeEthReceipt takes a cid and its raw binary data
 // from IPFS and returns an EthTx object for further processing.
 func DecodeEthReceipt(c cid.Cid, b []byte) (*EthReceipt, error) {
-	var r *types.Receipt
+	r := new(types.Receipt)
 	if err := rlp.DecodeBytes(b, r); err != nil {
 		return nil, err
 	}
@@ -122,16 +122,12 @@ func newRctTrie() *rctTrie {
 	}
 }
 
-// getNodes invokes the localTrie, which computes the root hash of the
-// transaction trie and returns its database keys, to return a slice
+// getNodes invokes the localTrie, which commits the trie and
+// returns the keys of its stored nodes, to return a slice
 // of EthRctTrie nodes.
 func (rt *rctTrie) getNodes() []*EthRctTrie {
 	keys := rt.getKeys()
 	var out []*EthRctTrie
-	it := rt.trie.NodeIterator([]byte{})
-	for it.Next(true) {
-
-	}
 	for _, k := range keys {
 		rawdata, err := rt.db.Get(k)
 		if err != nil {
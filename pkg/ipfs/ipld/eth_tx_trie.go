@@ -122,16 +122,12 @@ func newTxTrie() *txTrie {
 	}
 }
 
-// getNodes invokes the localTrie, which computes the root hash of the
-// transaction trie and returns its database keys, to return a slice
+// getNodes invokes the localTrie, which commits the trie and
+// returns the keys of its stored nodes, to return a slice
 // of EthTxTrie nodes.
 func (tt *txTrie) getNodes() []*EthTxTrie {
 	keys := tt.getKeys()
 	var out []*EthTxTrie
-	it := tt.trie.NodeIterator([]byte{})
-	for it.Next(true) {
-
-	}
 	for _, k := range keys {
 		rawdata, err := tt.db.Get(k)
 		if err != nil {
@@ -32,18 +32,20 @@ import (
 // See the authoritative document:
 // https://github.com/multiformats/multicodec/blob/master/table.csv
 const (
-	RawBinary           = 0x55
-	MEthHeader          = 0x90
-	MEthHeaderList      = 0x91
-	MEthTxTrie          = 0x92
-	MEthTx              = 0x93
-	MEthTxReceiptTrie   = 0x94
-	MEthTxReceipt       = 0x95
-	MEthStateTrie       = 0x96
-	MEthAccountSnapshot = 0x97
-	MEthStorageTrie     = 0x98
-	MBitcoinHeader      = 0xb0
-	MBitcoinTx          = 0xb1
+	RawBinary                 = 0x55
+	MEthHeader                = 0x90
+	MEthHeaderList            = 0x91
+	MEthTxTrie                = 0x92
+	MEthTx                    = 0x93
+	MEthTxReceiptTrie         = 0x94
+	MEthTxReceipt             = 0x95
+	MEthStateTrie             = 0x96
+	MEthAccountSnapshot       = 0x97
+	MEthStorageTrie           = 0x98
+	MEthLog                   = 0x9a
+	MBitcoinHeader            = 0xb0
+	MBitcoinTx                = 0xb1
+	MBitcoinWitnessCommitment = 0xb2
 )
 
 // RawdataToCid takes the desired codec and a slice of bytes
@@ -94,6 +96,16 @@ func sha256ToCid(codec uint64, h []byte) cid.Cid {
 	return cid.NewCidV1(codec, hash)
 }
 
+// Keccak256ToCid returns the cid with the given codec of the block with the keccak256 hash
+func Keccak256ToCid(codec uint64, h []byte) cid.Cid {
+	return keccak256ToCid(codec, h)
+}
+
+// DoubleSha256ToCid returns the cid with the given codec of the block with the double sha256 hash
+func DoubleSha256ToCid(codec uint64, h []byte) cid.Cid {
+	return sha256ToCid(codec, h)
+}
+
 // getRLP encodes the given object to RLP returning its bytes.
 func getRLP(object interface{}) []byte {
 	buf := new(bytes.Buffer)
@@ -107,9 +119,9 @@ func getRLP(object interface{}) []byte {
 // localTrie wraps a go-ethereum trie and its underlying memory db.
 // It contributes to the creation of the trie node objects.
 type localTrie struct {
-	keys [][]byte
-	db   ethdb.Database
-	trie *trie.Trie
+	db     ethdb.Database
+	trieDB *trie.Database
+	trie   *trie.Trie
 }
 
 // newLocalTrie initializes and returns a localTrie object
@@ -117,7 +129,8 @@ func newLocalTrie() *localTrie {
 	var err error
 	lt := &localTrie{}
 	lt.db = rawdb.NewMemoryDatabase()
-	lt.trie, err = trie.New(common.Hash{}, trie.NewDatabase(lt.db))
+	lt.trieDB = trie.NewDatabase(lt.db)
+	lt.trie, err = trie.New(common.Hash{}, lt.trieDB)
 	if err != nil {
 		panic(err)
 	}
@@ -131,10 +144,6 @@ func (lt *localTrie) add(idx int, rawdata []byte) {
 	if err != nil {
 		panic(err)
 	}
-	lt.keys = append(lt.keys, key)
-	if err := lt.db.Put(key, rawdata); err != nil {
-		panic(err)
-	}
 	lt.trie.Update(key, rawdata)
 }
 
@@ -144,8 +153,22 @@ func (lt *localTrie) rootHash() []byte {
 	return lt.trie.Hash().Bytes()
 }
 
-// getKeys returns the stored keys of the memory database
-// of the localTrie for further processing.
+// getKeys commits the trie to the memory database and returns the keys
+// of the stored trie nodes, which are the hashes of the nodes, for further processing.
+// Nodes smaller than a hash are embedded in their parent and are not stored on their own.
 func (lt *localTrie) getKeys() [][]byte {
-	return lt.keys
+	root, err := lt.trie.Commit(nil)
+	if err != nil {
+		panic(err)
+	}
+	if err := lt.trieDB.Commit(root, false); err != nil {
+		panic(err)
+	}
+	var keys [][]byte
+	it := lt.db.NewIterator()
+	defer it.Release()
+	for it.Next() {
+		keys = append(keys, common.CopyBytes(it.Key()))
+	}
+	return keys
 }
@@ -0,0 +1,162 @@
+package mocks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// IPFSAPIServer is a stand-in for the HTTP API of an IPFS daemon, serving the block commands from memory
+// Like newer daemons, it keys the blocks by their multihash
+type IPFSAPIServer struct {
+	*httptest.Server
+	lock   sync.Mutex
+	blocks map[string][]byte
+	keys   []cid.Cid
+}
+
+// NewIPFSAPIServer starts a new IPFSAPIServer, which has to be closed by the caller
+func NewIPFSAPIServer() *IPFSAPIServer {
+	s := &IPFSAPIServer{
+		blocks: make(map[string][]byte),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v0/version", s.version)
+	mux.HandleFunc("/api/v0/block/put", s.blockPut)
+	mux.HandleFunc("/api/v0/block/get", s.blockGet)
+	mux.HandleFunc("/api/v0/block/stat", s.blockStat)
+	mux.HandleFunc("/api/v0/block/rm", s.blockRm)
+	mux.HandleFunc("/api/v0/refs/local", s.refsLocal)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Len returns the number of blocks held by the server
+func (s *IPFSAPIServer) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.blocks)
+}
+
+// SetBlock stores the data under the multihash as is, without hashing it, so that corrupt blocks can be served
+func (s *IPFSAPIServer) SetBlock(hash mh.Multihash, data []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if _, ok := s.blocks[string(hash)]; !ok {
+		s.keys = append(s.keys, cid.NewCidV1(cid.Raw, hash))
+	}
+	s.blocks[string(hash)] = data
+}
+
+func writeError(w http.ResponseWriter, message string) {
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"Message": message,
+		"Code":    0,
+		"Type":    "error",
+	})
+}
+
+func (s *IPFSAPIServer) version(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]string{"Version": "0.5.1"})
+}
+
+func (s *IPFSAPIServer) blockPut(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, err.Error())
+		return
+	}
+	data, err := ioutil.ReadAll(file)
+	if err != nil {
+		writeError(w, err.Error())
+		return
+	}
+	codec, ok := cid.Codecs[r.URL.Query().Get("format")]
+	if !ok {
+		writeError(w, fmt.Sprintf("unrecognized format: %s", r.URL.Query().Get("format")))
+		return
+	}
+	mhType, ok := mh.Names[r.URL.Query().Get("mhtype")]
+	if !ok {
+		writeError(w, fmt.Sprintf("unrecognized multihash function: %s", r.URL.Query().Get("mhtype")))
+		return
+	}
+	c, err := cid.Prefix{Version: 1, Codec: codec, MhType: mhType, MhLength: -1}.Sum(data)
+	if err != nil {
+		writeError(w, err.Error())
+		return
+	}
+	s.lock.Lock()
+	if _, ok := s.blocks[string(c.Hash())]; !ok {
+		s.keys = append(s.keys, c)
+	}
+	s.blocks[string(c.Hash())] = data
+	s.lock.Unlock()
+	json.NewEncoder(w).Encode(map[string]interface{}{"Key": c.String(), "Size": len(data)})
+}
+
+func (s *IPFSAPIServer) lookup(w http.ResponseWriter, r *http.Request) (cid.Cid, []byte, bool) {
+	c, err := cid.Decode(r.URL.Query().Get("arg"))
+	if err != nil {
+		writeError(w, err.Error())
+		return cid.Cid{}, nil, false
+	}
+	s.lock.Lock()
+	data, ok := s.blocks[string(c.Hash())]
+	s.lock.Unlock()
+	if !ok {
+		writeError(w, "blockservice: key not found")
+		return cid.Cid{}, nil, false
+	}
+	return c, data, true
+}
+
+func (s *IPFSAPIServer) blockGet(w http.ResponseWriter, r *http.Request) {
+	if _, data, ok := s.lookup(w, r); ok {
+		w.Write(data)
+	}
+}
+
+func (s *IPFSAPIServer) blockStat(w http.ResponseWriter, r *http.Request) {
+	if c, data, ok := s.lookup(w, r); ok {
+		json.NewEncoder(w).Encode(map[string]interface{}{"Key": c.String(), "Size": len(data)})
+	}
+}
+
+func (s *IPFSAPIServer) blockRm(w http.ResponseWriter, r *http.Request) {
+	c, _, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	s.lock.Lock()
+	delete(s.blocks, string(c.Hash()))
+	for i, key := range s.keys {
+		if string(key.Hash()) == string(c.Hash()) {
+			s.keys = append(s.keys[:i], s.keys[i+1:]...)
+			break
+		}
+	}
+	s.lock.Unlock()
+	json.NewEncoder(w).Encode(map[string]string{"Hash": c.String()})
+}
+
+func (s *IPFSAPIServer) refsLocal(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	keys := append([]cid.Cid{}, s.keys...)
+	s.lock.Unlock()
+	encoder := json.NewEncoder(w)
+	for _, key := range keys {
+		encoder.Encode(map[string]string{"Ref": key.String()})
+	}
+}
@@ -0,0 +1,99 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migrate
+
+import (
+	"fmt"
+
+	"github.com/ipfs/go-blockservice"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/blockstore"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/config"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/node"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+	"github.com/vulcanize/ipfs-blockchain-watcher/utils"
+)
+
+// Env variables
+const (
+	MIGRATE_CHAIN      = "MIGRATE_CHAIN"
+	MIGRATE_TO         = "MIGRATE_TO"
+	MIGRATE_RANGE_SIZE = "MIGRATE_RANGE_SIZE"
+)
+
+// DefaultRangeSize is the default number of block heights whose blocks are copied in a single db tx
+const DefaultRangeSize = 100
+
+// Config holds the parameters needed to copy blocks between the local IPFS repo and the blockstores
+type Config struct {
+	Chain     shared.ChainType // The chain whose blocks to copy
+	To        shared.IPFSMode  // The mode whose store to copy the blocks to, from the store of the other mode
+	RangeSize uint64           // The number of block heights whose blocks are copied in a single db tx
+
+	// DB info
+	DB       *postgres.DB
+	DBConfig config.Database
+
+	// The local IPFS repo, used in LocalInterface mode
+	IPFSPath string
+	// Block service of the repo; if nil the repo at IPFSPath is opened
+	BlockService blockservice.BlockService
+	// The blockstores, used in DirectPostgres mode
+	Blockstores *shared.Blockstores
+}
+
+// NewConfig fills and returns a migrate config from toml parameters
+func NewConfig() (*Config, error) {
+	c := new(Config)
+	var err error
+
+	viper.BindEnv("migrate.chain", MIGRATE_CHAIN)
+	viper.BindEnv("migrate.to", MIGRATE_TO)
+	viper.BindEnv("migrate.rangeSize", MIGRATE_RANGE_SIZE)
+
+	c.Chain, err = shared.NewChainType(viper.GetString("migrate.chain"))
+	if err != nil {
+		return nil, err
+	}
+	c.To, err = shared.NewIPFSMode(viper.GetString("migrate.to"))
+	if err != nil {
+		return nil, err
+	}
+	if c.To != shared.DirectPostgres && c.To != shared.LocalInterface {
+		return nil, fmt.Errorf("blocks can only be migrated to postgres or to the local ipfs repo (interface), not %s", viper.GetString("migrate.to"))
+	}
+	c.RangeSize = uint64(viper.GetInt64("migrate.rangeSize"))
+	if c.RangeSize == 0 {
+		c.RangeSize = DefaultRangeSize
+	}
+
+	c.IPFSPath, err = shared.GetIPFSPath()
+	if err != nil {
+		return nil, err
+	}
+	c.Blockstores, err = blockstore.NewBlockstores()
+	if err != nil {
+		return nil, err
+	}
+
+	c.DBConfig.Init()
+	db := utils.LoadPostgres(c.DBConfig, node.Node{})
+	c.DB = &db
+	return c, nil
+}
@@ -0,0 +1,285 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migrate
+
+import (
+	"context"
+	"fmt"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/builders"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// store is one end of a migration
+type store interface {
+	has(tx *sqlx.Tx, t shared.DataType, c cid.Cid, mhKey string) (bool, error)
+	get(tx *sqlx.Tx, t shared.DataType, c cid.Cid, mhKey string) ([]byte, error)
+	put(tx *sqlx.Tx, t shared.DataType, c cid.Cid, mhKey string, data []byte) error
+}
+
+// blockstoresStore is the store of DirectPostgres mode, the blockstores
+type blockstoresStore struct {
+	blockstores *shared.Blockstores
+}
+
+func (s blockstoresStore) has(tx *sqlx.Tx, t shared.DataType, c cid.Cid, mhKey string) (bool, error) {
+	return s.blockstores.For(t).Has(tx, mhKey)
+}
+
+func (s blockstoresStore) get(tx *sqlx.Tx, t shared.DataType, c cid.Cid, mhKey string) ([]byte, error) {
+	return s.blockstores.Get(tx, t, mhKey)
+}
+
+func (s blockstoresStore) put(tx *sqlx.Tx, t shared.DataType, c cid.Cid, mhKey string, data []byte) error {
+	return s.blockstores.For(t).Put(tx, mhKey, data)
+}
+
+// blockServiceStore is the store of LocalInterface mode, the local IPFS repo
+type blockServiceStore struct {
+	blockService blockservice.BlockService
+}
+
+func (s blockServiceStore) has(tx *sqlx.Tx, t shared.DataType, c cid.Cid, mhKey string) (bool, error) {
+	return s.blockService.Blockstore().Has(c)
+}
+
+func (s blockServiceStore) get(tx *sqlx.Tx, t shared.DataType, c cid.Cid, mhKey string) ([]byte, error) {
+	block, err := s.blockService.GetBlock(context.Background(), c)
+	if err == blockservice.ErrNotFound {
+		return nil, shared.ErrBlockNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return block.RawData(), nil
+}
+
+func (s blockServiceStore) put(tx *sqlx.Tx, t shared.DataType, c cid.Cid, mhKey string, data []byte) error {
+	block, err := blocks.NewBlockWithCid(data, c)
+	if err != nil {
+		return err
+	}
+	return s.blockService.AddBlock(block)
+}
+
+// Service copies the blocks of the indexed data between the local IPFS repo and the blockstores, so that a deployment
+// can switch between the LocalInterface and DirectPostgres modes
+type Service struct {
+	db       *postgres.DB
+	chain    shared.ChainType
+	from, to store
+	// Queries for the blocks to copy, one per type of data
+	pgStrs    []string
+	dataTypes []shared.DataType
+	rangeSize uint64
+}
+
+// NewService creates and returns a migrate service from the provided settings
+func NewService(settings *Config) (*Service, error) {
+	dataTypes, err := shared.ChainDataTypes(settings.Chain)
+	if err != nil {
+		return nil, err
+	}
+	pgStrs := make([]string, len(dataTypes))
+	for i, dataType := range dataTypes {
+		if pgStrs[i], err = builders.CIDsPgStr(settings.Chain, dataType); err != nil {
+			return nil, err
+		}
+	}
+	blockService := settings.BlockService
+	if blockService == nil {
+		if blockService, err = ipfs.InitIPFSBlockService(settings.IPFSPath); err != nil {
+			return nil, err
+		}
+	}
+	blockstores := settings.Blockstores
+	if blockstores == nil {
+		blockstores = shared.NewPostgresBlockstores()
+	}
+	var from, to store = blockServiceStore{blockService: blockService}, blockstoresStore{blockstores: blockstores}
+	switch settings.To {
+	case shared.DirectPostgres:
+	case shared.LocalInterface:
+		from, to = to, from
+	default:
+		return nil, fmt.Errorf("migrate: blocks can not be migrated to ipfs mode %s", settings.To.String())
+	}
+	rangeSize := settings.RangeSize
+	if rangeSize == 0 {
+		rangeSize = DefaultRangeSize
+	}
+	return &Service{
+		db:        settings.DB,
+		chain:     settings.Chain,
+		from:      from,
+		to:        to,
+		pgStrs:    pgStrs,
+		dataTypes: dataTypes,
+		rangeSize: rangeSize,
+	}, nil
+}
+
+// stats counts the blocks of a migration
+type stats struct {
+	copied, present, failed int
+}
+
+func (s *stats) add(o stats) {
+	s.copied += o.copied
+	s.present += o.present
+	s.failed += o.failed
+}
+
+// Migrate copies the blocks of all of the indexed data, and the trie and merkle nodes that hang off of the headers, that
+// the destination does not hold yet; every block is checked against its cid before it is copied
+// It can be stopped and rerun at any point, the blocks that have already been copied are skipped
+// Blocks that are missing from the source, or don't match their cid, are logged and left out, and fail the migration
+// once all of the other blocks have been copied
+func (s *Service) Migrate() error {
+	var bounds struct {
+		Start uint64 `db:"start"`
+		Stop  uint64 `db:"stop"`
+	}
+	pgStr := `SELECT COALESCE(MIN(block_number), 0) AS start, COALESCE(MAX(block_number), 0) AS stop FROM ` + s.chain.API() + `.header_cids`
+	if err := s.db.Get(&bounds, pgStr); err != nil {
+		return err
+	}
+	var total stats
+	for start := bounds.Start; start <= bounds.Stop; start += s.rangeSize {
+		stop := start + s.rangeSize - 1
+		if stop > bounds.Stop || stop < start {
+			stop = bounds.Stop
+		}
+		rangeStats, err := s.migrateRange(start, stop)
+		if err != nil {
+			return err
+		}
+		logrus.Infof("copied %d %s blocks at heights %d to %d, %d were already present and %d failed", rangeStats.copied, s.chain.String(), start, stop, rangeStats.present, rangeStats.failed)
+		total.add(rangeStats)
+		if stop == bounds.Stop {
+			break
+		}
+	}
+	logrus.Infof("copied %d %s blocks in total, %d were already present", total.copied, s.chain.String(), total.present)
+	if total.failed > 0 {
+		return fmt.Errorf("%d %s blocks are missing from the source or do not match their cid", total.failed, s.chain.String())
+	}
+	return nil
+}
+
+// migrateRange copies the blocks of the data at the block heights from start to stop in a single tx
+func (s *Service) migrateRange(start, stop uint64) (rangeStats stats, err error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return stats{}, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	// blocks can be referenced more than once, e.g. storage nodes shared between contracts, and are only looked at once
+	seen := make(map[string]bool)
+	for i, dataType := range s.dataTypes {
+		var iplds []builders.IndexedIPLD
+		if err = tx.Select(&iplds, s.pgStrs[i], start, stop); err != nil {
+			return stats{}, err
+		}
+		for _, ipld := range iplds {
+			var c cid.Cid
+			if c, err = cid.Decode(ipld.CID); err != nil {
+				return stats{}, err
+			}
+			var data []byte
+			if data, err = s.migrateBlock(tx, &rangeStats, seen, dataType, c, ipld.MhKey, dataType == shared.Headers); err != nil {
+				return stats{}, err
+			}
+			if dataType == shared.Headers && data != nil {
+				if err = s.migrateUnindexed(tx, &rangeStats, seen, data); err != nil {
+					return stats{}, err
+				}
+			}
+		}
+	}
+	return rangeStats, nil
+}
+
+// migrateUnindexed copies the trie and merkle nodes that hang off of the header, which are published but not indexed
+// The nodes are copied as they are fetched, to walk on to the nodes below them
+func (s *Service) migrateUnindexed(tx *sqlx.Tx, rangeStats *stats, seen map[string]bool, headerData []byte) error {
+	return builders.WalkUnindexedBlocks(s.chain, headerData, func(t shared.DataType, c cid.Cid) ([]byte, error) {
+		return s.migrateBlock(tx, rangeStats, seen, t, c, shared.MultihashKeyFromCID(c), true)
+	}, func(t shared.DataType, c cid.Cid, data []byte) error {
+		return nil
+	})
+}
+
+// migrateBlock copies the block if the destination does not hold it yet, and returns its data if it is needed
+// The data is nil if the block has been looked at before, or is missing from the source or doesn't match its cid
+func (s *Service) migrateBlock(tx *sqlx.Tx, rangeStats *stats, seen map[string]bool, t shared.DataType, c cid.Cid, mhKey string, needData bool) ([]byte, error) {
+	if seen[mhKey] {
+		return nil, nil
+	}
+	seen[mhKey] = true
+	present, err := s.to.has(tx, t, c, mhKey)
+	if err != nil {
+		return nil, err
+	}
+	if present {
+		rangeStats.present++
+		if !needData {
+			return nil, nil
+		}
+		return s.to.get(tx, t, c, mhKey)
+	}
+	data, err := s.from.get(tx, t, c, mhKey)
+	if err == shared.ErrBlockNotFound {
+		logrus.Errorf("%s block %s is missing from the source", t.String(), c.String())
+		rangeStats.failed++
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sum, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !sum.Equals(c) {
+		logrus.Errorf("%s block %s in the source does not match its cid", t.String(), c.String())
+		rangeStats.failed++
+		return nil, nil
+	}
+	if err := s.to.put(tx, t, c, mhKey, data); err != nil {
+		return nil, err
+	}
+	rangeStats.copied++
+	return data, nil
+}
@@ -0,0 +1,139 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package migrate_test
+
+import (
+	"context"
+
+	"github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	dssync "github.com/ipfs/go-datastore/sync"
+	blockstore "github.com/ipfs/go-ipfs-blockstore"
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/migrate"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// the blocks the mocks publish that are indexed, and the root of the transaction trie, which is not
+var migrated = []cid.Cid{mocks.HeaderCID, mocks.Trx1CID, mocks.Rct1CID, mocks.State1CID, mocks.StorageCID, mocks.TxTrieCID}
+
+var _ = Describe("Migrate", func() {
+	var (
+		db           *postgres.DB
+		blockService blockservice.BlockService
+	)
+	BeforeEach(func() {
+		db = mocks.SetupIndexedDB()
+		blockService = blockservice.New(blockstore.NewBlockstore(dssync.MutexWrap(datastore.NewMapDatastore())), nil)
+	})
+	AfterEach(func() {
+		eth.TearDownDB(db)
+	})
+
+	newService := func(to shared.IPFSMode) *migrate.Service {
+		service, err := migrate.NewService(&migrate.Config{
+			Chain:        shared.Ethereum,
+			To:           to,
+			DB:           db,
+			BlockService: blockService,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		return service
+	}
+	fetch := func(c cid.Cid) []byte {
+		var data []byte
+		err := db.Get(&data, `SELECT data FROM public.blocks WHERE key = $1`, shared.MultihashKeyFromCID(c))
+		Expect(err).ToNot(HaveOccurred())
+		return data
+	}
+	repoHas := func(c cid.Cid) bool {
+		has, err := blockService.Blockstore().Has(c)
+		Expect(err).ToNot(HaveOccurred())
+		return has
+	}
+
+	It("Copies the blocks from Postgres to the IPFS repo", func() {
+		Expect(newService(shared.LocalInterface).Migrate()).To(Succeed())
+		for _, c := range migrated {
+			block, err := blockService.GetBlock(context.Background(), c)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(block.RawData()).To(Equal(fetch(c)))
+		}
+	})
+
+	It("Copies the blocks from the IPFS repo to Postgres", func() {
+		Expect(newService(shared.LocalInterface).Migrate()).To(Succeed())
+		expected := make(map[cid.Cid][]byte)
+		for _, c := range migrated {
+			expected[c] = fetch(c)
+		}
+		_, err := db.Exec(`DELETE FROM public.blocks`)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(newService(shared.DirectPostgres).Migrate()).To(Succeed())
+		for _, c := range migrated {
+			Expect(fetch(c)).To(Equal(expected[c]))
+		}
+	})
+
+	It("Can be rerun", func() {
+		Expect(newService(shared.LocalInterface).Migrate()).To(Succeed())
+		Expect(newService(shared.LocalInterface).Migrate()).To(Succeed())
+	})
+
+	It("Copies the blocks a failed migration left out once they are fixed", func() {
+		data := fetch(mocks.State1CID)
+		_, err := db.Exec(`UPDATE public.blocks SET data = $1 WHERE key = $2`, []byte{1, 2, 3}, mocks.State1MhKey)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(newService(shared.LocalInterface).Migrate()).ToNot(Succeed())
+		Expect(repoHas(mocks.State1CID)).To(BeFalse())
+
+		_, err = db.Exec(`UPDATE public.blocks SET data = $1 WHERE key = $2`, data, mocks.State1MhKey)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(newService(shared.LocalInterface).Migrate()).To(Succeed())
+		Expect(repoHas(mocks.State1CID)).To(BeTrue())
+	})
+
+	table.DescribeTable("Leaves out the blocks that can't be copied and fails",
+		func(pgStr string, args []interface{}, left []cid.Cid) {
+			_, err := db.Exec(pgStr, args...)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(newService(shared.LocalInterface).Migrate()).ToNot(Succeed())
+			leftOut := make(map[cid.Cid]bool, len(left))
+			for _, c := range left {
+				leftOut[c] = true
+			}
+			for _, c := range migrated {
+				Expect(repoHas(c)).To(Equal(!leftOut[c]))
+			}
+		},
+		table.Entry("blocks that do not match their cid", `UPDATE public.blocks SET data = $1 WHERE key = $2`,
+			[]interface{}{[]byte{1, 2, 3}, mocks.State1MhKey}, []cid.Cid{mocks.State1CID}),
+		table.Entry("missing blocks", `DELETE FROM public.blocks WHERE key = $1`,
+			[]interface{}{mocks.Trx1MhKey}, []cid.Cid{mocks.Trx1CID}),
+		// the trie nodes are found by walking down from the header
+		table.Entry("the trie nodes below a missing header", `DELETE FROM public.blocks WHERE key = $1`,
+			[]interface{}{mocks.HeaderMhKey}, []cid.Cid{mocks.HeaderCID, mocks.TxTrieCID}),
+	)
+})
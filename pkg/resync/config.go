@@ -22,6 +22,8 @@ import (
 
 	"github.com/spf13/viper"
 
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/blockstore"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/config"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/node"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
@@ -49,12 +51,13 @@ type Config struct {
 	ResetValidation bool             // If true, resync will reset the validation level to 0 for the given range
 
 	// DB info
-	DB       *postgres.DB
-	DBConfig config.Database
-	IPFSPath string
-	IPFSMode shared.IPFSMode
+	DB          *postgres.DB
+	DBConfig    config.Database
+	IPFSPath    string
+	IPFSMode    shared.IPFSMode
+	Blockstores *shared.Blockstores // The blockstores used in DirectPostgres mode
 
-	HTTPClient  interface{}   // Note this client is expected to support the retrieval of the specified data type(s)
+	HTTPClient  interface{}   // Note this client is expected to support the retrieval of the specified data type(s); for bitcoin it can also be a *btc.BlkFileConfig
 	NodeInfo    node.Node     // Info for the associated node
 	Ranges      [][2]uint64   // The block height ranges to resync
 	BatchSize   uint64        // BatchSize for the resync http calls (client has to support batch sizing)
@@ -95,12 +98,22 @@ func NewConfig() (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
-	if c.IPFSMode == shared.LocalInterface || c.IPFSMode == shared.RemoteClient {
+	if c.IPFSMode == shared.LocalInterface {
 		c.IPFSPath, err = shared.GetIPFSPath()
 		if err != nil {
 			return nil, err
 		}
 	}
+	// in remote mode the ipfs path is the address of the daemon's HTTP API
+	if c.IPFSMode == shared.RemoteClient {
+		c.IPFSPath = shared.GetIPFSAPI()
+	}
+	if c.IPFSMode == shared.DirectPostgres {
+		c.Blockstores, err = blockstore.NewBlockstores()
+		if err != nil {
+			return nil, err
+		}
+	}
 	resyncType := viper.GetString("resync.type")
 	c.ResyncType, err = shared.GenerateDataTypeFromString(resyncType)
 	if err != nil {
@@ -126,8 +139,25 @@ func NewConfig() (*Config, error) {
 			return nil, err
 		}
 	case shared.Bitcoin:
-		btcHTTP := viper.GetString("bitcoin.httpPath")
-		c.NodeInfo, c.HTTPClient = shared.GetBtcNodeAndClient(btcHTTP)
+		// if a data directory is configured blocks are read out of its blk*.dat files, and no node has to be running
+		viper.BindEnv("bitcoin.dataDir", shared.BTC_DATA_DIR)
+		if dataDir := viper.GetString("bitcoin.dataDir"); dataDir != "" {
+			c.NodeInfo = shared.GetBtcNodeInfo()
+			params, err := btc.ParamsFromNetworkID(c.NodeInfo.NetworkID)
+			if err != nil {
+				return nil, err
+			}
+			if c.NodeInfo.GenesisBlock == "" {
+				c.NodeInfo.GenesisBlock = params.GenesisHash.String()
+			}
+			c.HTTPClient = &btc.BlkFileConfig{
+				DataDir: dataDir,
+				Params:  params,
+			}
+		} else {
+			btcHTTP := viper.GetString("bitcoin.httpPath")
+			c.NodeInfo, c.HTTPClient = shared.GetBtcNodeAndClient(btcHTTP)
+		}
 	}
 
 	c.DBConfig.Init()
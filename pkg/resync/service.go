@@ -63,7 +63,7 @@ type Service struct {
 
 // NewResyncService creates and returns a resync service from the provided settings
 func NewResyncService(settings *Config) (Resync, error) {
-	publisher, err := builders.NewIPLDPublisher(settings.Chain, settings.IPFSPath, settings.DB, settings.IPFSMode)
+	publisher, err := builders.NewIPLDPublisher(settings.Chain, settings.IPFSPath, settings.DB, settings.IPFSMode, settings.Blockstores)
 	if err != nil {
 		return nil, err
 	}
@@ -71,7 +71,7 @@ func NewResyncService(settings *Config) (Resync, error) {
 	if err != nil {
 		return nil, err
 	}
-	converter, err := builders.NewPayloadConverter(settings.Chain)
+	converter, err := builders.NewPayloadConverter(settings.Chain, settings.DB)
 	if err != nil {
 		return nil, err
 	}
@@ -83,10 +83,21 @@ func NewResyncService(settings *Config) (Resync, error) {
 	if err != nil {
 		return nil, err
 	}
-	cleaner, err := builders.NewCleaner(settings.Chain, settings.DB)
+	cleaner, err := builders.NewCleaner(settings.Chain, settings.DB, settings.Blockstores)
 	if err != nil {
 		return nil, err
 	}
+	// a range without a stop runs to the tip of a fetcher that knows it, e.g. the best chain of a bitcoind data directory
+	ranges := settings.Ranges
+	if tip, ok := fetcher.(interface{ Height() uint64 }); ok {
+		ranges = make([][2]uint64, len(settings.Ranges))
+		for i, rng := range settings.Ranges {
+			if rng[1] == 0 {
+				rng[1] = tip.Height()
+			}
+			ranges[i] = rng
+		}
+	}
 	batchSize := settings.BatchSize
 	if batchSize == 0 {
 		batchSize = shared.DefaultMaxBatchSize
@@ -106,7 +117,7 @@ func NewResyncService(settings *Config) (Resync, error) {
 		BatchNumber:     int64(batchNumber),
 		quitChan:        make(chan bool),
 		chain:           settings.Chain,
-		ranges:          settings.Ranges,
+		ranges:          ranges,
 		data:            settings.ResyncType,
 		clearOldCache:   settings.ClearOldCache,
 		resetValidation: settings.ResetValidation,
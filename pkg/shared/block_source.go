@@ -0,0 +1,116 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package shared
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ipfs/go-blockservice"
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ipfs-blockstore"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs"
+)
+
+// BlockSource is where the blocks of indexed IPLDs are read from, be it the Blockstores or IPFS
+type BlockSource interface {
+	// Get returns the data of the block with the cid, which is kept under the mhkey, ErrBlockNotFound if it is missing,
+	// or ErrBlockCorrupt if its data doesn't hash to the cid
+	Get(tx *sqlx.Tx, t DataType, c cid.Cid, mhKey string) ([]byte, error)
+}
+
+// BlockstoresSource reads the blocks from the Blockstores, in DirectPostgres mode
+// The blocks are read within the tx, so that those in public.blocks are read from a consistent snapshot
+type BlockstoresSource struct {
+	Blockstores *Blockstores
+}
+
+// Get satisfies the BlockSource interface
+func (s BlockstoresSource) Get(tx *sqlx.Tx, t DataType, c cid.Cid, mhKey string) ([]byte, error) {
+	data, err := s.Blockstores.Get(tx, t, mhKey)
+	if err != nil {
+		return nil, err
+	}
+	return verifyBlock(c, data)
+}
+
+// BlockServiceSource reads the blocks from IPFS, in the LocalInterface and RemoteClient modes
+// It needs no tx, and reads the blocks by their cid
+type BlockServiceSource struct {
+	BlockService blockservice.BlockService
+}
+
+// Get satisfies the BlockSource interface
+func (s BlockServiceSource) Get(tx *sqlx.Tx, t DataType, c cid.Cid, mhKey string) ([]byte, error) {
+	block, err := s.BlockService.GetBlock(context.Background(), c)
+	switch err {
+	case nil:
+		return verifyBlock(c, block.RawData())
+	case blockservice.ErrNotFound:
+		return nil, ErrBlockNotFound
+	case blockstore.ErrHashMismatch:
+		// blocks read over the HTTP API of an IPFS daemon, or from a repo that hashes on read, are checked on the way
+		return nil, ErrBlockCorrupt
+	default:
+		return nil, err
+	}
+}
+
+// verifyBlock returns the data, or ErrBlockCorrupt if it doesn't hash to the cid
+func verifyBlock(c cid.Cid, data []byte) ([]byte, error) {
+	sum, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, err
+	}
+	if !sum.Equals(c) {
+		return nil, ErrBlockCorrupt
+	}
+	return data, nil
+}
+
+// NewBlockSource returns the BlockSource of the ipfs mode
+// In DirectPostgres mode the blocks are read from the blockstores, or from Postgres if blockstores is nil
+// In the other modes they are read through the blockService, which if nil is started on the IPFS repo at the ipfsPath,
+// or in RemoteClient mode on the IPFS daemon whose HTTP API is at the ipfsPath
+func NewBlockSource(ipfsMode IPFSMode, ipfsPath string, blockstores *Blockstores, blockService blockservice.BlockService) (BlockSource, error) {
+	var err error
+	switch ipfsMode {
+	case DirectPostgres:
+		if blockstores == nil {
+			blockstores = NewPostgresBlockstores()
+		}
+		return BlockstoresSource{Blockstores: blockstores}, nil
+	case LocalInterface:
+		if blockService == nil {
+			if blockService, err = ipfs.InitIPFSBlockService(ipfsPath); err != nil {
+				return nil, err
+			}
+		}
+		return BlockServiceSource{BlockService: blockService}, nil
+	case RemoteClient:
+		if blockService == nil {
+			if blockService, err = ipfs.InitRemoteBlockService(ipfsPath); err != nil {
+				return nil, err
+			}
+		}
+		return BlockServiceSource{BlockService: blockService}, nil
+	default:
+		return nil, fmt.Errorf("unrecognized ipfs mode %s", ipfsMode.String())
+	}
+}
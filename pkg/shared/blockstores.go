@@ -0,0 +1,133 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package shared
+
+import (
+	"database/sql"
+	"errors"
+
+	node "github.com/ipfs/go-ipld-format"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
+)
+
+// ErrBlockNotFound is returned by a Blockstore for a block it does not hold
+var ErrBlockNotFound = errors.New("block not found")
+
+// ErrBlockCorrupt is returned by a BlockSource for a block whose data doesn't hash to its cid
+var ErrBlockCorrupt = errors.New("block does not match its cid")
+
+// PostgresBlockstore is the Blockstore backed by the public.blocks table of PG-IPFS
+type PostgresBlockstore struct{}
+
+// Put satisfies the Blockstore interface
+// An existing block is only overwritten if its data differs, which, as the key is the hash of the data, means it is corrupt
+func (PostgresBlockstore) Put(tx *sqlx.Tx, mhKey string, data []byte) error {
+	_, err := tx.Exec(`INSERT INTO public.blocks (key, data) VALUES ($1, $2)
+			ON CONFLICT (key) DO UPDATE SET data = $2 WHERE blocks.data <> $2`, mhKey, data)
+	return err
+}
+
+// Get satisfies the Blockstore interface
+func (PostgresBlockstore) Get(tx *sqlx.Tx, mhKey string) ([]byte, error) {
+	data, err := FetchIPLDByMhKey(tx, mhKey)
+	if err == sql.ErrNoRows {
+		return nil, ErrBlockNotFound
+	}
+	return data, err
+}
+
+// Has satisfies the Blockstore interface
+func (PostgresBlockstore) Has(tx *sqlx.Tx, mhKey string) (bool, error) {
+	var exists bool
+	err := tx.Get(&exists, `SELECT EXISTS(SELECT 1 FROM public.blocks WHERE key = $1)`, mhKey)
+	return exists, err
+}
+
+// Delete satisfies the Blockstore interface
+func (PostgresBlockstore) Delete(tx *sqlx.Tx, mhKey string) error {
+	_, err := tx.Exec(`DELETE FROM public.blocks WHERE key = $1`, mhKey)
+	return err
+}
+
+// Blockstores selects the Blockstore the blocks of each type of data are kept in
+type Blockstores struct {
+	// Blockstore for the types of data that have none of their own
+	Default Blockstore
+	// Blockstores for specific types of data
+	Types map[DataType]Blockstore
+}
+
+// NewBlockstores returns Blockstores that keep every type of data in the given Blockstore
+func NewBlockstores(def Blockstore) *Blockstores {
+	return &Blockstores{
+		Default: def,
+		Types:   make(map[DataType]Blockstore),
+	}
+}
+
+// NewPostgresBlockstores returns Blockstores that keep every type of data in Postgres
+func NewPostgresBlockstores() *Blockstores {
+	return NewBlockstores(PostgresBlockstore{})
+}
+
+// For returns the Blockstore for the type of data
+func (bs *Blockstores) For(t DataType) Blockstore {
+	if store, ok := bs.Types[t]; ok {
+		return store
+	}
+	return bs.Default
+}
+
+// PutIPLD puts the ipld in the Blockstore for the type of data
+func (bs *Blockstores) PutIPLD(tx *sqlx.Tx, t DataType, i node.Node) error {
+	return bs.For(t).Put(tx, MultihashKeyFromCID(i.Cid()), i.RawData())
+}
+
+// PutRaw derives a cid from raw bytes and provided codec and multihash type, and puts it in the Blockstore for the type of data
+func (bs *Blockstores) PutRaw(tx *sqlx.Tx, t DataType, codec, mh uint64, raw []byte) (string, error) {
+	c, err := ipld.RawdataToCid(codec, raw, mh)
+	if err != nil {
+		return "", err
+	}
+	return c.String(), bs.For(t).Put(tx, MultihashKeyFromCID(c), raw)
+}
+
+// DeleteSelected deletes the blocks of the mhkeys the query selects from the Blockstore for the type of data
+func (bs *Blockstores) DeleteSelected(tx *sqlx.Tx, t DataType, pgStr string, args ...interface{}) error {
+	store := bs.For(t)
+	if _, ok := store.(PostgresBlockstore); ok {
+		_, err := tx.Exec(`DELETE FROM public.blocks WHERE key IN (`+pgStr+`)`, args...)
+		return err
+	}
+	mhKeys := make([]string, 0)
+	if err := tx.Select(&mhKeys, pgStr, args...); err != nil {
+		return err
+	}
+	for _, mhKey := range mhKeys {
+		if err := store.Delete(tx, mhKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get fetches the block with the mhkey from the Blockstore for the type of data
+func (bs *Blockstores) Get(tx *sqlx.Tx, t DataType, mhKey string) ([]byte, error) {
+	return bs.For(t).Get(tx, mhKey)
+}
@@ -19,6 +19,8 @@ package shared
 import (
 	"fmt"
 	"strings"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
 )
 
 // DataType is an enum to loosely represent type of chain data
@@ -142,3 +144,33 @@ func SupportedDataType(d DataType, c ChainType) (bool, error) {
 		return false, fmt.Errorf("unrecognized chain type %s", c.String())
 	}
 }
+
+// ChainDataTypes returns the types of data the chain has, headers first and the rest in the order they hang off of them
+func ChainDataTypes(c ChainType) ([]DataType, error) {
+	switch c {
+	case Ethereum:
+		return []DataType{Headers, Uncles, Transactions, Receipts, State, Storage}, nil
+	case Bitcoin:
+		return []DataType{Headers, Transactions}, nil
+	default:
+		return nil, fmt.Errorf("no types of data known for chain %s", c.String())
+	}
+}
+
+// CodecDataType returns the type of data that blocks with the codec hold
+func CodecDataType(codec uint64) DataType {
+	switch codec {
+	case ipld.MEthHeader, ipld.MBitcoinHeader:
+		return Headers
+	case ipld.MEthTxTrie, ipld.MEthTx, ipld.MBitcoinTx, ipld.MBitcoinWitnessCommitment:
+		return Transactions
+	case ipld.MEthTxReceiptTrie, ipld.MEthTxReceipt, ipld.MEthLog:
+		return Receipts
+	case ipld.MEthStateTrie:
+		return State
+	case ipld.MEthStorageTrie:
+		return Storage
+	default:
+		return Full
+	}
+}
@@ -31,6 +31,7 @@ import (
 const (
 	IPFS_PATH    = "IPFS_PATH"
 	IPFS_MODE    = "IPFS_MODE"
+	IPFS_API     = "IPFS_API"
 	HTTP_TIMEOUT = "HTTP_TIMEOUT"
 
 	ETH_WS_PATH       = "ETH_WS_PATH"
@@ -40,6 +41,10 @@ const (
 	ETH_GENESIS_BLOCK = "ETH_GENESIS_BLOCK"
 	ETH_NETWORK_ID    = "ETH_NETWORK_ID"
 
+	ETH_LOGS_MAX_BLOCK_RANGE = "ETH_LOGS_MAX_BLOCK_RANGE"
+	ETH_LOGS_MAX_RESULTS     = "ETH_LOGS_MAX_RESULTS"
+	ETH_LOGS_PAGE_SIZE       = "ETH_LOGS_PAGE_SIZE"
+
 	BTC_WS_PATH       = "BTC_WS_PATH"
 	BTC_HTTP_PATH     = "BTC_HTTP_PATH"
 	BTC_NODE_PASSWORD = "BTC_NODE_PASSWORD"
@@ -48,6 +53,7 @@ const (
 	BTC_CLIENT_NAME   = "BTC_CLIENT_NAME"
 	BTC_GENESIS_BLOCK = "BTC_GENESIS_BLOCK"
 	BTC_NETWORK_ID    = "BTC_NETWORK_ID"
+	BTC_DATA_DIR      = "BTC_DATA_DIR"
 )
 
 // GetEthNodeAndClient returns eth node info and client from path url
@@ -83,6 +89,16 @@ func GetIPFSPath() (string, error) {
 	return ipfsPath, nil
 }
 
+// GetIPFSAPI returns the address of the IPFS daemon's HTTP API from the config or env variable
+func GetIPFSAPI() string {
+	viper.BindEnv("ipfs.api", IPFS_API)
+	ipfsAPI := viper.GetString("ipfs.api")
+	if ipfsAPI == "" {
+		return "http://127.0.0.1:5001"
+	}
+	return ipfsAPI
+}
+
 // GetIPFSMode returns the ipfs mode of operation from the config or env variable
 func GetIPFSMode() (IPFSMode, error) {
 	viper.BindEnv("ipfs.mode", IPFS_MODE)
@@ -93,26 +109,32 @@ func GetIPFSMode() (IPFSMode, error) {
 	return NewIPFSMode(ipfsMode)
 }
 
-// GetBtcNodeAndClient returns btc node info from path url
-func GetBtcNodeAndClient(path string) (node.Node, *rpcclient.ConnConfig) {
+// GetBtcNodeInfo returns the btc node info from the config
+// For bitcoin we load in node info from the config because there is no RPC endpoint to retrieve this from the node
+func GetBtcNodeInfo() node.Node {
 	viper.BindEnv("bitcoin.nodeID", BTC_NODE_ID)
 	viper.BindEnv("bitcoin.clientName", BTC_CLIENT_NAME)
 	viper.BindEnv("bitcoin.genesisBlock", BTC_GENESIS_BLOCK)
 	viper.BindEnv("bitcoin.networkID", BTC_NETWORK_ID)
+
+	return node.Node{
+		ID:           viper.GetString("bitcoin.nodeID"),
+		ClientName:   viper.GetString("bitcoin.clientName"),
+		GenesisBlock: viper.GetString("bitcoin.genesisBlock"),
+		NetworkID:    viper.GetString("bitcoin.networkID"),
+	}
+}
+
+// GetBtcNodeAndClient returns btc node info from path url
+func GetBtcNodeAndClient(path string) (node.Node, *rpcclient.ConnConfig) {
 	viper.BindEnv("bitcoin.pass", BTC_NODE_PASSWORD)
 	viper.BindEnv("bitcoin.user", BTC_NODE_USER)
 
-	// For bitcoin we load in node info from the config because there is no RPC endpoint to retrieve this from the node
-	return node.Node{
-			ID:           viper.GetString("bitcoin.nodeID"),
-			ClientName:   viper.GetString("bitcoin.clientName"),
-			GenesisBlock: viper.GetString("bitcoin.genesisBlock"),
-			NetworkID:    viper.GetString("bitcoin.networkID"),
-		}, &rpcclient.ConnConfig{
-			Host:         path,
-			HTTPPostMode: true, // Bitcoin core only supports HTTP POST mode
-			DisableTLS:   true, // Bitcoin core does not provide TLS by default
-			Pass:         viper.GetString("bitcoin.pass"),
-			User:         viper.GetString("bitcoin.user"),
-		}
+	return GetBtcNodeInfo(), &rpcclient.ConnConfig{
+		Host:         path,
+		HTTPPostMode: true, // Bitcoin core only supports HTTP POST mode
+		DisableTLS:   true, // Bitcoin core does not provide TLS by default
+		Pass:         viper.GetString("bitcoin.pass"),
+		User:         viper.GetString("bitcoin.user"),
+	}
 }
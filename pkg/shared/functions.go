@@ -17,12 +17,17 @@
 package shared
 
 import (
+	"bytes"
+	"strings"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
 	"github.com/ipfs/go-ipfs-blockstore"
 	"github.com/ipfs/go-ipfs-ds-help"
 	node "github.com/ipfs/go-ipld-format"
 	"github.com/jmoiron/sqlx"
+	"github.com/multiformats/go-multihash"
 	"github.com/sirupsen/logrus"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
 )
@@ -93,6 +98,48 @@ func MultihashKeyFromCIDString(c string) (string, error) {
 	return blockstore.BlockPrefix.String() + dbKey.String(), nil
 }
 
+// MultihashFromKey converts a blockstore-prefixed multihash db key string back into its multihash
+func MultihashFromKey(mhKey string) (multihash.Multihash, error) {
+	return dshelp.BinaryFromDsKey(datastore.NewKey(strings.TrimPrefix(mhKey, blockstore.BlockPrefix.String())))
+}
+
+// DataMatchesMhKey returns whether the data hashes to the multihash of the blockstore-prefixed multihash db key
+func DataMatchesMhKey(mhKey string, data []byte) (bool, error) {
+	mh, err := MultihashFromKey(mhKey)
+	if err != nil {
+		return false, err
+	}
+	decoded, err := multihash.Decode(mh)
+	if err != nil {
+		return false, err
+	}
+	sum, err := multihash.Sum(data, decoded.Code, decoded.Length)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(sum, mh), nil
+}
+
+// MultihashKeyFromKeccak256 converts a keccak256 hash into a blockstore-prefixed multihash db key string
+func MultihashKeyFromKeccak256(h common.Hash) (string, error) {
+	mh, err := multihash.Encode(h.Bytes(), multihash.KECCAK_256)
+	if err != nil {
+		return "", err
+	}
+	dbKey := dshelp.MultihashToDsKey(mh)
+	return blockstore.BlockPrefix.String() + dbKey.String(), nil
+}
+
+// MultihashKeyFromDoubleSha256 converts a double sha256 hash, in the byte order it is hashed to, into a blockstore-prefixed multihash db key string
+func MultihashKeyFromDoubleSha256(h []byte) (string, error) {
+	mh, err := multihash.Encode(h, multihash.DBL_SHA2_256)
+	if err != nil {
+		return "", err
+	}
+	dbKey := dshelp.MultihashToDsKey(mh)
+	return blockstore.BlockPrefix.String() + dbKey.String(), nil
+}
+
 // PublishRaw derives a cid from raw bytes and provided codec and multihash type, and writes it to the db tx
 func PublishRaw(tx *sqlx.Tx, codec, mh uint64, raw []byte) (string, error) {
 	c, err := ipld.RawdataToCid(codec, raw, mh)
@@ -18,6 +18,8 @@ package shared
 
 import (
 	"math/big"
+
+	"github.com/jmoiron/sqlx"
 )
 
 // PayloadStreamer streams chain-specific payloads to the provided channel
@@ -75,6 +77,16 @@ type Cleaner interface {
 	ResetValidation(rngs [][2]uint64) error
 }
 
+// Blockstore stores IPLD blocks under their blockstore-prefixed multihash keys
+// Blockstores backed by Postgres work within the provided tx, so that blocks are published in the same transaction their
+// CIDs are indexed in; other blockstores ignore it
+type Blockstore interface {
+	Put(tx *sqlx.Tx, mhKey string, data []byte) error
+	Get(tx *sqlx.Tx, mhKey string) ([]byte, error)
+	Has(tx *sqlx.Tx, mhKey string) (bool, error)
+	Delete(tx *sqlx.Tx, mhKey string) error
+}
+
 // SubscriptionSettings is the interface every subscription filter type needs to satisfy, no matter the chain
 // Further specifics of the underlying filter type depend on the internal needs of the types
 // which satisfy the ResponseFilterer and CIDRetriever interfaces for a specific chain
@@ -79,7 +79,7 @@ func TestCID(b []byte) cid.Cid {
 	return c
 }
 
-// PublishMockIPLD writes a mhkey-data pair to the public.blocks table so that test data can FK reference the mhkey
+// PublishMockIPLD writes a mhkey-data pair to the public.blocks table so that test data can be fetched by the mhkey
 func PublishMockIPLD(db *postgres.DB, mhKey string, mockData []byte) error {
 	_, err := db.Exec(`INSERT INTO public.blocks (key, data) VALUES ($1, $2) ON CONFLICT (key) DO NOTHING`, mhKey, mockData)
 	return err
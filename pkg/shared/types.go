@@ -33,6 +33,7 @@ type IPLDs interface {
 }
 
 type Gap struct {
-	Start uint64
-	Stop  uint64
+	Start    uint64
+	Stop     uint64
+	DataType DataType // the type of data missing in the range; Full if the blocks are missing altogether
 }
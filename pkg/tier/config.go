@@ -0,0 +1,117 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tier
+
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/blockstore"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/config"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/node"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+	"github.com/vulcanize/ipfs-blockchain-watcher/utils"
+)
+
+// Env variables
+const (
+	TIER_CHAIN      = "TIER_CHAIN"
+	TIER_HEIGHT     = "TIER_HEIGHT"
+	TIER_DATA_TYPES = "TIER_DATA_TYPES"
+	TIER_RANGE_SIZE = "TIER_RANGE_SIZE"
+)
+
+// DefaultRangeSize is the default number of block heights whose blocks are moved in a single db tx
+const DefaultRangeSize = 10
+
+// Config holds the parameters needed to move blocks to the cold tier
+type Config struct {
+	Chain     shared.ChainType  // The chain whose blocks to move
+	DataTypes []shared.DataType // The types of data whose blocks to move
+	Height    uint64            // The blocks of the data indexed at or below this height are moved
+	RangeSize uint64            // The number of block heights whose blocks are moved in a single db tx
+
+	// DB info
+	DB       *postgres.DB
+	DBConfig config.Database
+
+	// The cold tier blockstore
+	Cold     shared.Blockstore
+	ColdType blockstore.Type
+}
+
+// NewConfig fills and returns a tier config from toml parameters
+func NewConfig() (*Config, error) {
+	c := new(Config)
+	var err error
+
+	viper.BindEnv("tier.chain", TIER_CHAIN)
+	viper.BindEnv("tier.height", TIER_HEIGHT)
+	viper.BindEnv("tier.dataTypes", TIER_DATA_TYPES)
+	viper.BindEnv("tier.rangeSize", TIER_RANGE_SIZE)
+
+	c.Chain, err = shared.NewChainType(viper.GetString("tier.chain"))
+	if err != nil {
+		return nil, err
+	}
+	height := viper.GetInt64("tier.height")
+	if height <= 0 {
+		return nil, fmt.Errorf("tier height needs to be set to a height above 0")
+	}
+	c.Height = uint64(height)
+	c.RangeSize = uint64(viper.GetInt64("tier.rangeSize"))
+	if c.RangeSize == 0 {
+		c.RangeSize = DefaultRangeSize
+	}
+
+	dataTypes := viper.GetStringSlice("tier.dataTypes")
+	if len(dataTypes) == 0 {
+		// the state and storage nodes make up most of the ethereum blocks, and the transactions most of the bitcoin blocks
+		switch c.Chain {
+		case shared.Ethereum:
+			dataTypes = []string{"state", "storage"}
+		default:
+			dataTypes = []string{"transactions"}
+		}
+	}
+	for _, str := range dataTypes {
+		dataType, err := shared.GenerateDataTypeFromString(str)
+		if err != nil {
+			return nil, err
+		}
+		ok, err := shared.SupportedDataType(dataType, c.Chain)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("chain type %s does not support data type %s", c.Chain.String(), dataType.String())
+		}
+		c.DataTypes = append(c.DataTypes, dataType)
+	}
+
+	c.Cold, c.ColdType, err = blockstore.NewColdBlockstore()
+	if err != nil {
+		return nil, err
+	}
+
+	c.DBConfig.Init()
+	db := utils.LoadPostgres(c.DBConfig, node.Node{})
+	c.DB = &db
+	return c, nil
+}
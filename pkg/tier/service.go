@@ -0,0 +1,175 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tier
+
+import (
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/blockstore"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/builders"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// Service moves the blocks of old data out of Postgres to the cold tier
+type Service struct {
+	// Tiers the blocks are moved between; the hot tier is public.blocks
+	Store *blockstore.TieredBlockstore
+	db    *postgres.DB
+	chain shared.ChainType
+	// Queries for the keys of the blocks to move, one per type of data
+	pgStrs    []string
+	height    uint64
+	rangeSize uint64
+}
+
+// NewService creates and returns a tier service from the provided settings
+func NewService(settings *Config) (*Service, error) {
+	pgStrs := make([]string, 0, len(settings.DataTypes))
+	for _, dataType := range settings.DataTypes {
+		mhKeysPgStr, err := builders.MhKeysPgStr(settings.Chain, dataType)
+		if err != nil {
+			return nil, err
+		}
+		pgStrs = append(pgStrs, `SELECT key FROM public.blocks WHERE key IN (`+mhKeysPgStr+`)`)
+	}
+	rangeSize := settings.RangeSize
+	if rangeSize == 0 {
+		rangeSize = DefaultRangeSize
+	}
+	return &Service{
+		Store:     blockstore.NewTieredBlockstore(shared.PostgresBlockstore{}, settings.Cold, settings.ColdType),
+		db:        settings.DB,
+		chain:     settings.Chain,
+		pgStrs:    pgStrs,
+		height:    settings.Height,
+		rangeSize: rangeSize,
+	}, nil
+}
+
+// Tier moves the blocks of the data indexed at or below the configured height to the cold tier
+// It can be stopped and rerun at any point, the blocks that have already been moved are not found in Postgres again
+// and the blocks whose moves failed are moved again
+func (s *Service) Tier() error {
+	// the moved blocks stay indexed, so they can't be deleted from public.blocks while the index references it
+	hasKeys, err := blockstore.HasForeignKeys(s.db)
+	if err != nil {
+		return err
+	}
+	if hasKeys {
+		return fmt.Errorf("the index references public.blocks through foreign keys, drop them with the foreignKeys command before moving blocks out of it")
+	}
+	var start uint64
+	pgStr := `SELECT COALESCE(MIN(block_number), 0) FROM ` + s.chain.API() + `.header_cids`
+	if err := s.db.Get(&start, pgStr); err != nil {
+		return err
+	}
+	var totalBlocks, totalBytes int
+	for ; start <= s.height; start += s.rangeSize {
+		stop := start + s.rangeSize - 1
+		if stop > s.height {
+			stop = s.height
+		}
+		moved, size, err := s.tierRange(start, stop)
+		if err != nil {
+			return err
+		}
+		logrus.Infof("moved %d %s blocks (%d bytes) at heights %d to %d to the cold tier", moved, s.chain.String(), size, start, stop)
+		totalBlocks += moved
+		totalBytes += size
+	}
+	logrus.Infof("moved %d %s blocks (%d bytes) in total to the cold tier", totalBlocks, s.chain.String(), totalBytes)
+	return nil
+}
+
+// tierRange moves the blocks of the data at the block heights from start to stop
+// The moves are recorded in a tx of their own before the blocks are put into the cold tier, so that the copies put there
+// stay tracked if moving the blocks fails; the blocks are then moved in a single tx
+func (s *Service) tierRange(start, stop uint64) (int, int, error) {
+	keys, err := s.recordRange(start, stop)
+	if err != nil || len(keys) == 0 {
+		return 0, 0, err
+	}
+	return s.moveBlocks(keys)
+}
+
+// recordRange records the moves of the blocks of the data at the block heights from start to stop, and returns their keys
+func (s *Service) recordRange(start, stop uint64) (keys []string, err error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	for _, pgStr := range s.pgStrs {
+		var dataKeys []string
+		if err = tx.Select(&dataKeys, pgStr, start, stop); err != nil {
+			return nil, err
+		}
+		keys = append(keys, dataKeys...)
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	return keys, s.Store.RecordMoves(tx, keys)
+}
+
+type block struct {
+	Key  string `db:"key"`
+	Data []byte `db:"data"`
+}
+
+// moveBlocks moves the blocks with the keys, whose moves have been recorded, to the cold tier in a single tx
+func (s *Service) moveBlocks(keys []string) (moved, size int, err error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	var blocks []block
+	if err = tx.Select(&blocks, `SELECT key, data FROM public.blocks WHERE key = ANY($1)`, pq.Array(keys)); err != nil {
+		return 0, 0, err
+	}
+	for _, b := range blocks {
+		if err = s.Store.MoveToCold(tx, b.Key, b.Data); err != nil {
+			return 0, 0, err
+		}
+		moved++
+		size += len(b.Data)
+	}
+	return moved, size, nil
+}
@@ -0,0 +1,154 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package tier_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/blockstore"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/tier"
+)
+
+var (
+	nodes       = []shared.DataType{shared.State, shared.Storage}
+	nodeKeys    = []string{mocks.State1MhKey, mocks.State2MhKey, mocks.StorageMhKey}
+	blockNumber = mocks.BlockNumber.Uint64()
+)
+
+var _ = Describe("Tier", func() {
+	var (
+		db   *postgres.DB
+		dir  string
+		cold *blockstore.FilesystemBlockstore
+	)
+	BeforeEach(func() {
+		var err error
+		db = mocks.SetupIndexedDB()
+		dir, err = ioutil.TempDir("", "tier")
+		Expect(err).ToNot(HaveOccurred())
+		cold, err = blockstore.NewFilesystemBlockstore(dir)
+		Expect(err).ToNot(HaveOccurred())
+	})
+	AfterEach(func() {
+		eth.TearDownDB(db)
+		os.RemoveAll(dir)
+	})
+
+	newService := func(height uint64, dataTypes []shared.DataType) *tier.Service {
+		service, err := tier.NewService(&tier.Config{
+			Chain:     shared.Ethereum,
+			DataTypes: dataTypes,
+			Height:    height,
+			DB:        db,
+			Cold:      cold,
+			ColdType:  blockstore.Filesystem,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		return service
+	}
+	hotHas := func(mhKey string) bool {
+		var has bool
+		Expect(db.Get(&has, `SELECT EXISTS (SELECT 1 FROM public.blocks WHERE key = $1)`, mhKey)).To(Succeed())
+		return has
+	}
+	coldHas := func(mhKey string) bool {
+		has, err := cold.Has(nil, mhKey)
+		Expect(err).ToNot(HaveOccurred())
+		return has
+	}
+	movedKeys := func() []string {
+		var keys []string
+		Expect(db.Select(&keys, `SELECT key FROM public.block_locations WHERE moved AND store = 'filesystem'`)).To(Succeed())
+		return keys
+	}
+
+	table.DescribeTable("Moves the blocks of the types of data at or below the height to the cold tier",
+		func(height uint64, dataTypes []shared.DataType, moved []string) {
+			Expect(newService(height, dataTypes).Tier()).To(Succeed())
+			Expect(movedKeys()).To(ConsistOf(moved))
+			for _, mhKey := range moved {
+				Expect(hotHas(mhKey)).To(BeFalse())
+				Expect(coldHas(mhKey)).To(BeTrue())
+			}
+			// the other types of data are left in Postgres
+			Expect(hotHas(mocks.HeaderMhKey)).To(BeTrue())
+		},
+		table.Entry("state and storage nodes", blockNumber, nodes, nodeKeys),
+		table.Entry("transactions", blockNumber, []shared.DataType{shared.Transactions},
+			[]string{mocks.Trx1MhKey, mocks.Trx2MhKey, mocks.Trx3MhKey}),
+		table.Entry("nothing above the height", blockNumber-1, nodes, []string{}),
+	)
+
+	It("Lets the IPLDPGFetcher read through both tiers", func() {
+		service := newService(blockNumber, nodes)
+		Expect(service.Tier()).To(Succeed())
+		// running it again finds nothing left to move
+		Expect(service.Tier()).To(Succeed())
+		fetcher := eth.NewIPLDPGFetcher(db)
+		fetcher.Blockstores = shared.NewBlockstores(service.Store)
+		i, err := fetcher.Fetch(mocks.MockCIDWrapper)
+		Expect(err).ToNot(HaveOccurred())
+		iplds, ok := i.(eth.IPLDs)
+		Expect(ok).To(BeTrue())
+		Expect(iplds.Header).To(Equal(mocks.MockIPLDs.Header))
+		Expect(iplds.Transactions).To(Equal(mocks.MockIPLDs.Transactions))
+		Expect(iplds.Receipts).To(Equal(mocks.MockIPLDs.Receipts))
+		Expect(iplds.StateNodes).To(Equal(mocks.MockIPLDs.StateNodes))
+		Expect(iplds.StorageNodes).To(Equal(mocks.MockIPLDs.StorageNodes))
+	})
+
+	It("Moves the blocks of a failed move again on the next run", func() {
+		// a move that was recorded and put into the cold tier, but rolled back in Postgres
+		service := newService(blockNumber, nodes)
+		tx, err := db.Beginx()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(service.Store.RecordMoves(tx, []string{mocks.State1MhKey})).To(Succeed())
+		data, err := shared.FetchIPLDByMhKey(tx, mocks.State1MhKey)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(tx.Commit()).To(Succeed())
+		Expect(cold.Put(nil, mocks.State1MhKey, data)).To(Succeed())
+		Expect(movedKeys()).To(BeEmpty())
+
+		Expect(service.Tier()).To(Succeed())
+		Expect(movedKeys()).To(ConsistOf(nodeKeys))
+		Expect(hotHas(mocks.State1MhKey)).To(BeFalse())
+		Expect(coldHas(mocks.State1MhKey)).To(BeTrue())
+	})
+
+	It("Refuses to move blocks while the index references public.blocks through foreign keys", func() {
+		Expect(blockstore.AddForeignKeys(db)).To(Succeed())
+		defer func() {
+			Expect(blockstore.DropForeignKeys(db)).To(Succeed())
+		}()
+		err := newService(blockNumber, nodes).Tier()
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("drop them with the foreignKeys command"))
+		Expect(movedKeys()).To(BeEmpty())
+		for _, mhKey := range nodeKeys {
+			Expect(hotHas(mhKey)).To(BeTrue())
+		}
+	})
+})
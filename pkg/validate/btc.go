@@ -0,0 +1,85 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package validate
+
+import (
+	"bytes"
+
+	"github.com/btcsuite/btcd/blockchain"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+const btcTxsPgStr = `SELECT index, cid, mh_key, segwit, witness_cid FROM btc.transaction_cids WHERE header_id = $1 ORDER BY index`
+
+// validateBtc validates a btc block: the header has to hash to its indexed hash and parent hash, and the merkle root
+// has to be recomputed from the indexed transactions
+func (s *Service) validateBtc(tx *sqlx.Tx, v *validation, headerData []byte) error {
+	var header wire.BlockHeader
+	if err := header.Deserialize(bytes.NewReader(headerData)); err != nil {
+		v.fail("header does not decode: %v", err)
+		return nil
+	}
+	if hash := header.BlockHash(); hash.String() != v.header.BlockHash {
+		v.fail("header hashes to %s", hash.String())
+	}
+	if header.PrevBlock.String() != v.header.ParentHash {
+		v.fail("header has the parent hash %s, not the indexed %s", header.PrevBlock.String(), v.header.ParentHash)
+	}
+	trxs := make([]btc.TxModel, 0)
+	if err := tx.Select(&trxs, btcTxsPgStr, v.header.ID); err != nil {
+		return err
+	}
+	if len(trxs) == 0 {
+		v.fail("no transactions are indexed")
+		return nil
+	}
+	txs := make([]*btcutil.Tx, 0, len(trxs))
+	for _, trx := range trxs {
+		data, err := s.fetch(tx, v, shared.Transactions, trx.CID, trx.MhKey)
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			continue
+		}
+		msgTx := new(wire.MsgTx)
+		// the transaction blocks are serialized without their witnesses, except for those of the segwit transactions
+		// indexed before the stripped forms were published
+		deserialize := msgTx.DeserializeNoWitness
+		if trx.LegacyWitnessForm() {
+			deserialize = msgTx.Deserialize
+		}
+		if err := deserialize(bytes.NewReader(data)); err != nil {
+			v.fail("transaction %d does not decode: %v", trx.Index, err)
+			return nil
+		}
+		txs = append(txs, btcutil.NewTx(msgTx))
+	}
+	if len(txs) < len(trxs) {
+		return nil
+	}
+	merkles := blockchain.BuildMerkleTreeStore(txs, false)
+	if root := merkles[len(merkles)-1]; !root.IsEqual(&header.MerkleRoot) {
+		v.fail("merkle root %s derived from the indexed transactions does not match the header's %s", root.String(), header.MerkleRoot.String())
+	}
+	return nil
+}
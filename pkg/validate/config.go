@@ -0,0 +1,116 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package validate
+
+import (
+	"fmt"
+
+	"github.com/ipfs/go-blockservice"
+	"github.com/spf13/viper"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/blockstore"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/config"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/node"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+	"github.com/vulcanize/ipfs-blockchain-watcher/utils"
+)
+
+// Env variables
+const (
+	VALIDATE_CHAIN      = "VALIDATE_CHAIN"
+	VALIDATE_START      = "VALIDATE_START"
+	VALIDATE_STOP       = "VALIDATE_STOP"
+	VALIDATE_RANGE_SIZE = "VALIDATE_RANGE_SIZE"
+	VALIDATE_STATE      = "VALIDATE_STATE"
+)
+
+// DefaultRangeSize is the default number of block heights validated in a single db tx
+const DefaultRangeSize = 100
+
+// Config holds the parameters needed to validate the indexed blocks against their stored IPLDs
+type Config struct {
+	Chain     shared.ChainType // The chain whose blocks to validate
+	Start     uint64           // The first block height to validate
+	Stop      uint64           // The last block height to validate; 0 validates up to the highest indexed header
+	RangeSize uint64           // The number of block heights validated in a single db tx
+	State     bool             // Also verify that the stored state and storage nodes hash into the state root (eth only)
+
+	// DB info
+	DB       *postgres.DB
+	DBConfig config.Database
+
+	IPFSPath string
+	IPFSMode shared.IPFSMode
+	// Block service of the IPFS repo in the LocalInterface and RemoteClient modes; if nil it is opened from IPFSPath
+	BlockService blockservice.BlockService
+	// The blockstores used in DirectPostgres mode
+	Blockstores *shared.Blockstores
+}
+
+// NewConfig fills and returns a validate config from toml parameters
+func NewConfig() (*Config, error) {
+	c := new(Config)
+	var err error
+
+	viper.BindEnv("validate.chain", VALIDATE_CHAIN)
+	viper.BindEnv("validate.start", VALIDATE_START)
+	viper.BindEnv("validate.stop", VALIDATE_STOP)
+	viper.BindEnv("validate.rangeSize", VALIDATE_RANGE_SIZE)
+	viper.BindEnv("validate.state", VALIDATE_STATE)
+
+	c.Chain, err = shared.NewChainType(viper.GetString("validate.chain"))
+	if err != nil {
+		return nil, err
+	}
+	c.Start = uint64(viper.GetInt64("validate.start"))
+	c.Stop = uint64(viper.GetInt64("validate.stop"))
+	if c.Stop != 0 && c.Stop < c.Start {
+		return nil, fmt.Errorf("validate stop height %d is below the start height %d", c.Stop, c.Start)
+	}
+	c.RangeSize = uint64(viper.GetInt64("validate.rangeSize"))
+	if c.RangeSize == 0 {
+		c.RangeSize = DefaultRangeSize
+	}
+	c.State = viper.GetBool("validate.state")
+
+	c.IPFSMode, err = shared.GetIPFSMode()
+	if err != nil {
+		return nil, err
+	}
+	if c.IPFSMode == shared.LocalInterface {
+		c.IPFSPath, err = shared.GetIPFSPath()
+		if err != nil {
+			return nil, err
+		}
+	}
+	// in remote mode the ipfs path is the address of the daemon's HTTP API
+	if c.IPFSMode == shared.RemoteClient {
+		c.IPFSPath = shared.GetIPFSAPI()
+	}
+	if c.IPFSMode == shared.DirectPostgres {
+		c.Blockstores, err = blockstore.NewBlockstores()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	c.DBConfig.Init()
+	db := utils.LoadPostgres(c.DBConfig, node.Node{})
+	c.DB = &db
+	return c, nil
+}
@@ -0,0 +1,206 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package validate
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/statediff"
+	"github.com/ipfs/go-cid"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+const (
+	ethTxsPgStr  = `SELECT cid, mh_key FROM eth.transaction_cids WHERE header_id = $1 ORDER BY index`
+	ethRctsPgStr = `SELECT receipt_cids.cid, receipt_cids.mh_key FROM eth.receipt_cids
+			INNER JOIN eth.transaction_cids ON (receipt_cids.tx_id = transaction_cids.id)
+			WHERE transaction_cids.header_id = $1 ORDER BY transaction_cids.index`
+	ethUnclesPgStr  = `SELECT cid, mh_key FROM eth.uncle_cids WHERE header_id = $1 ORDER BY id`
+	ethStatePgStr   = `SELECT state_path AS path, node_type, cid, mh_key FROM eth.state_cids WHERE header_id = $1`
+	ethStoragePgStr = `SELECT storage_cids.storage_path AS path, storage_cids.node_type, storage_cids.cid, storage_cids.mh_key,
+			state_accounts.storage_root FROM eth.storage_cids
+			INNER JOIN eth.state_cids ON (storage_cids.state_id = state_cids.id)
+			INNER JOIN eth.state_accounts ON (state_accounts.state_id = state_cids.id)
+			WHERE state_cids.header_id = $1`
+)
+
+// ethTrieNode is a state or storage node indexed for an eth header, with the root of the trie it belongs to for
+// storage nodes
+type ethTrieNode struct {
+	Path        []byte `db:"path"`
+	NodeType    int    `db:"node_type"`
+	CID         string `db:"cid"`
+	MhKey       string `db:"mh_key"`
+	StorageRoot string `db:"storage_root"`
+}
+
+// validateEth validates an eth block: the header has to hash to its indexed hash and parent hash, carry the indexed
+// roots, and the transaction root, receipt root and uncle hash have to be recomputed from the indexed IPLDs
+func (s *Service) validateEth(tx *sqlx.Tx, v *validation, headerData []byte) error {
+	var header types.Header
+	if err := rlp.DecodeBytes(headerData, &header); err != nil {
+		v.fail("header does not decode: %v", err)
+		return nil
+	}
+	if header.Hash().String() != v.header.BlockHash {
+		v.fail("header hashes to %s", header.Hash().String())
+	}
+	if header.ParentHash.String() != v.header.ParentHash {
+		v.fail("header has the parent hash %s, not the indexed %s", header.ParentHash.String(), v.header.ParentHash)
+	}
+	var indexed eth.HeaderModel
+	pgStr := `SELECT state_root, tx_root, receipt_root, uncle_root, state_absent FROM eth.header_cids WHERE id = $1`
+	if err := tx.Get(&indexed, pgStr, v.header.ID); err != nil {
+		return err
+	}
+	compareRoot(v, "state root", header.Root, indexed.StateRoot)
+	compareRoot(v, "transaction root", header.TxHash, indexed.TxRoot)
+	compareRoot(v, "receipt root", header.ReceiptHash, indexed.RctRoot)
+	compareRoot(v, "uncle hash", header.UncleHash, indexed.UncleRoot)
+
+	txDatas, complete, err := s.fetchAll(tx, v, shared.Transactions, ethTxsPgStr)
+	if err != nil {
+		return err
+	}
+	if complete {
+		txs := make(types.Transactions, len(txDatas))
+		for i, data := range txDatas {
+			txs[i] = new(types.Transaction)
+			if err := rlp.DecodeBytes(data, txs[i]); err != nil {
+				v.fail("transaction %d does not decode: %v", i, err)
+				complete = false
+			}
+		}
+		if complete {
+			compareDerived(v, "transaction root", types.DeriveSha(txs), header.TxHash)
+		}
+	}
+
+	rctDatas, complete, err := s.fetchAll(tx, v, shared.Receipts, ethRctsPgStr)
+	if err != nil {
+		return err
+	}
+	if complete {
+		rcts := make(types.Receipts, len(rctDatas))
+		for i, data := range rctDatas {
+			rcts[i] = new(types.Receipt)
+			if err := rlp.DecodeBytes(data, rcts[i]); err != nil {
+				v.fail("receipt %d does not decode: %v", i, err)
+				complete = false
+			}
+		}
+		if complete {
+			compareDerived(v, "receipt root", types.DeriveSha(rcts), header.ReceiptHash)
+		}
+	}
+
+	uncleDatas, complete, err := s.fetchAll(tx, v, shared.Uncles, ethUnclesPgStr)
+	if err != nil {
+		return err
+	}
+	if complete {
+		uncles := make([]*types.Header, len(uncleDatas))
+		for i, data := range uncleDatas {
+			uncles[i] = new(types.Header)
+			if err := rlp.DecodeBytes(data, uncles[i]); err != nil {
+				v.fail("uncle %d does not decode: %v", i, err)
+				complete = false
+			}
+		}
+		if complete {
+			compareDerived(v, "uncle hash", types.CalcUncleHash(uncles), header.UncleHash)
+		}
+	}
+
+	if s.state && !indexed.StateAbsent {
+		return s.validateEthState(tx, v, header.Root)
+	}
+	return nil
+}
+
+// validateEthState verifies that the state and storage nodes indexed for the header hash into its state root: every
+// node has to be stored, and be the node found at its path when walking down from the root of its trie
+// Removed nodes are not in the trie, and are skipped
+func (s *Service) validateEthState(tx *sqlx.Tx, v *validation, stateRoot common.Hash) error {
+	var stateNodes []ethTrieNode
+	if err := tx.Select(&stateNodes, ethStatePgStr, v.header.ID); err != nil {
+		return err
+	}
+	getState := s.trieNodes(tx, v, shared.State, ipld.MEthStateTrie)
+	for _, node := range stateNodes {
+		if err := s.validateEthTrieNode(tx, v, shared.State, ipld.MEthStateTrie, stateRoot, node, getState); err != nil {
+			return err
+		}
+	}
+	var storageNodes []ethTrieNode
+	if err := tx.Select(&storageNodes, ethStoragePgStr, v.header.ID); err != nil {
+		return err
+	}
+	getStorage := s.trieNodes(tx, v, shared.Storage, ipld.MEthStorageTrie)
+	for _, node := range storageNodes {
+		storageRoot := common.HexToHash(node.StorageRoot)
+		if err := s.validateEthTrieNode(tx, v, shared.Storage, ipld.MEthStorageTrie, storageRoot, node, getStorage); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateEthTrieNode verifies that the node is stored and is the node at its path in the trie with the root
+func (s *Service) validateEthTrieNode(tx *sqlx.Tx, v *validation, t shared.DataType, codec uint64, root common.Hash,
+	node ethTrieNode, get func(hash common.Hash) ([]byte, error)) error {
+	if eth.ResolveToNodeType(node.NodeType) == statediff.Removed {
+		return nil
+	}
+	data, err := s.fetch(tx, v, t, node.CID, node.MhKey)
+	if err != nil || data == nil {
+		return err
+	}
+	hash, ok, err := eth.TrieNodeHash(root, node.Path, get)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		v.fail("%s node %s at path %x can not be reached from the root %s", t.String(), node.CID, node.Path, root.String())
+		return nil
+	}
+	// the cid decodes, as fetch found the block
+	nodeCID, _ := cid.Decode(node.CID)
+	if c := ipld.Keccak256ToCid(codec, hash.Bytes()); !c.Equals(nodeCID) {
+		v.fail("%s node at path %x under the root %s is %s, not the indexed %s", t.String(), node.Path, root.String(), c.String(), node.CID)
+	}
+	return nil
+}
+
+// compareRoot adds a problem to the validation if the indexed root is not the one in the header
+func compareRoot(v *validation, name string, root common.Hash, indexed string) {
+	if root.String() != indexed {
+		v.fail("header has the %s %s, not the indexed %s", name, root.String(), indexed)
+	}
+}
+
+// compareDerived adds a problem to the validation if the root derived from the indexed IPLDs is not the one in the header
+func compareDerived(v *validation, name string, derived, root common.Hash) {
+	if derived != root {
+		v.fail("%s %s derived from the indexed IPLDs does not match the header's %s", name, derived.String(), root.String())
+	}
+}
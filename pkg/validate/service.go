@@ -1 +1,288 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
 package validate
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ipfs/go-cid"
+	"github.com/jmoiron/sqlx"
+	"github.com/sirupsen/logrus"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/ipfs/ipld"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+)
+
+// Service validates the indexed blocks of a range against their stored IPLDs, and counts every block that passes in
+// the times_validated column of its header
+type Service struct {
+	db        *postgres.DB
+	chain     shared.ChainType
+	source    shared.BlockSource
+	start     uint64
+	stop      uint64
+	rangeSize uint64
+	state     bool
+}
+
+// NewService creates and returns a validate service from the provided settings
+func NewService(settings *Config) (*Service, error) {
+	if settings.Chain != shared.Ethereum && settings.Chain != shared.Bitcoin {
+		return nil, fmt.Errorf("validate: unsupported chain %s", settings.Chain.String())
+	}
+	source, err := shared.NewBlockSource(settings.IPFSMode, settings.IPFSPath, settings.Blockstores, settings.BlockService)
+	if err != nil {
+		return nil, fmt.Errorf("validate: %v", err)
+	}
+	rangeSize := settings.RangeSize
+	if rangeSize == 0 {
+		rangeSize = DefaultRangeSize
+	}
+	return &Service{
+		db:        settings.DB,
+		chain:     settings.Chain,
+		source:    source,
+		start:     settings.Start,
+		stop:      settings.Stop,
+		rangeSize: rangeSize,
+		state:     settings.State,
+	}, nil
+}
+
+// Stats counts the blocks of a validation
+type Stats struct {
+	Validated int // Blocks that passed, whose times_validated was incremented
+	Invalid   int // Blocks with at least one problem, whose times_validated was left as it was
+}
+
+// indexedHeader is a header indexed in one of the header_cids tables
+type indexedHeader struct {
+	ID          int64  `db:"id"`
+	BlockNumber uint64 `db:"block_number"`
+	BlockHash   string `db:"block_hash"`
+	ParentHash  string `db:"parent_hash"`
+	CID         string `db:"cid"`
+	MhKey       string `db:"mh_key"`
+}
+
+// validation collects the problems found with a block
+type validation struct {
+	chain    shared.ChainType
+	header   indexedHeader
+	problems []string
+}
+
+func (v *validation) fail(format string, args ...interface{}) {
+	problem := fmt.Sprintf(format, args...)
+	logrus.Warnf("%s block %d %s: %s", v.chain.String(), v.header.BlockNumber, v.header.BlockHash, problem)
+	v.problems = append(v.problems, problem)
+}
+
+// Validate validates the blocks at the block heights from start to stop, rangeSize heights at a time
+// Every block is checked against its stored IPLDs: the header has to hash to its indexed hash and link to an indexed
+// parent (one on the best chain for btc blocks on it), and the roots of its transactions (and receipts and uncles for eth) have to be recomputed from the indexed
+// IPLDs to the roots in the header; optionally the stored eth state and storage nodes have to hash into the state root
+// The problems are logged, and only the blocks without any have their times_validated incremented
+func (s *Service) Validate() (Stats, error) {
+	var bounds struct {
+		Lowest  uint64 `db:"lowest"`
+		Highest uint64 `db:"highest"`
+	}
+	pgStr := `SELECT COALESCE(MIN(block_number), 0) AS lowest, COALESCE(MAX(block_number), 0) AS highest FROM ` + s.chain.API() + `.header_cids`
+	if err := s.db.Get(&bounds, pgStr); err != nil {
+		return Stats{}, err
+	}
+	stop := s.stop
+	if stop == 0 {
+		stop = bounds.Highest
+	}
+	var stats Stats
+	for start := s.start; start <= stop; start += s.rangeSize {
+		end := start + s.rangeSize - 1
+		if end > stop || end < start {
+			end = stop
+		}
+		rangeStats, err := s.validateRange(start, end, bounds.Lowest)
+		if err != nil {
+			return stats, err
+		}
+		logrus.Infof("validated %d %s blocks at heights %d to %d, %d were invalid", rangeStats.Validated, s.chain.String(), start, end, rangeStats.Invalid)
+		stats.Validated += rangeStats.Validated
+		stats.Invalid += rangeStats.Invalid
+		if end == stop {
+			break
+		}
+	}
+	logrus.Infof("validated %d %s blocks at heights %d to %d, %d were invalid", stats.Validated, s.chain.String(), s.start, stop, stats.Invalid)
+	return stats, nil
+}
+
+// validateRange validates the blocks at the block heights from start to stop in a single tx
+// The parents of the blocks at the lowest indexed height are not indexed, and their linkage is not checked
+func (s *Service) validateRange(start, stop, lowest uint64) (rangeStats Stats, err error) {
+	tx, err := s.db.Beginx()
+	if err != nil {
+		return Stats{}, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			shared.Rollback(tx)
+			panic(p)
+		} else if err != nil {
+			shared.Rollback(tx)
+		} else {
+			err = tx.Commit()
+		}
+	}()
+	var headers []indexedHeader
+	pgStr := `SELECT id, block_number, block_hash, parent_hash, cid, mh_key FROM ` + s.chain.API() + `.header_cids
+			WHERE block_number BETWEEN $1 AND $2 ORDER BY block_number, id`
+	if err = tx.Select(&headers, pgStr, start, stop); err != nil {
+		return Stats{}, err
+	}
+	for _, header := range headers {
+		v := &validation{chain: s.chain, header: header}
+		if err = s.validateHeader(tx, v, lowest); err != nil {
+			return Stats{}, err
+		}
+		if len(v.problems) > 0 {
+			rangeStats.Invalid++
+			continue
+		}
+		pgStr := `UPDATE ` + s.chain.API() + `.header_cids SET times_validated = times_validated + 1 WHERE id = $1`
+		if _, err = tx.Exec(pgStr, header.ID); err != nil {
+			return Stats{}, err
+		}
+		rangeStats.Validated++
+	}
+	return rangeStats, nil
+}
+
+// validateHeader validates the block of the header, adding the problems it finds to the validation
+func (s *Service) validateHeader(tx *sqlx.Tx, v *validation, lowest uint64) error {
+	headerData, err := s.fetch(tx, v, shared.Headers, v.header.CID, v.header.MhKey)
+	if err != nil || headerData == nil {
+		return err
+	}
+	if v.header.BlockNumber > lowest {
+		var linked bool
+		pgStr := `SELECT EXISTS (SELECT 1 FROM ` + s.chain.API() + `.header_cids WHERE block_number = $1 AND block_hash = $2)`
+		if err := tx.Get(&linked, pgStr, v.header.BlockNumber-1, v.header.ParentHash); err != nil {
+			return err
+		}
+		if !linked {
+			v.fail("parent %s is not indexed at height %d", v.header.ParentHash, v.header.BlockNumber-1)
+		} else if s.chain == shared.Bitcoin {
+			// a btc block on the best chain has to link to a parent that is on it as well
+			var orphanedParent bool
+			pgStr := `SELECT parent.orphaned AND NOT child.orphaned FROM btc.header_cids AS parent, btc.header_cids AS child
+					WHERE child.id = $1 AND parent.block_number = $2 AND parent.block_hash = $3 LIMIT 1`
+			if err := tx.Get(&orphanedParent, pgStr, v.header.ID, v.header.BlockNumber-1, v.header.ParentHash); err != nil {
+				return err
+			}
+			if orphanedParent {
+				v.fail("parent %s is orphaned while the block is on the best chain", v.header.ParentHash)
+			}
+		}
+	}
+	switch s.chain {
+	case shared.Ethereum:
+		return s.validateEth(tx, v, headerData)
+	case shared.Bitcoin:
+		return s.validateBtc(tx, v, headerData)
+	default:
+		return fmt.Errorf("validate: unsupported chain %s", s.chain.String())
+	}
+}
+
+// fetch returns the data of the indexed IPLD, or nil if it is missing, does not match its cid or is indexed under
+// another key than that of its cid, which are added to the validation as problems
+func (s *Service) fetch(tx *sqlx.Tx, v *validation, t shared.DataType, cidStr, mhKey string) ([]byte, error) {
+	c, err := cid.Decode(cidStr)
+	if err != nil {
+		v.fail("%s cid %s is invalid: %v", t.String(), cidStr, err)
+		return nil, nil
+	}
+	if shared.MultihashKeyFromCID(c) != mhKey {
+		v.fail("%s block %s is indexed under the key %s", t.String(), cidStr, mhKey)
+		return nil, nil
+	}
+	data, err := s.source.Get(tx, t, c, mhKey)
+	if err == shared.ErrBlockNotFound {
+		v.fail("%s block %s is missing", t.String(), cidStr)
+		return nil, nil
+	}
+	if err == shared.ErrBlockCorrupt {
+		v.fail("%s block %s does not match its cid", t.String(), cidStr)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// trieNodes returns a function that gets the eth trie nodes of the type of data and codec by their hash, for walking
+// a trie; a node that is missing is returned as nil, and one that does not match its hash is added to the validation
+func (s *Service) trieNodes(tx *sqlx.Tx, v *validation, t shared.DataType, codec uint64) func(hash common.Hash) ([]byte, error) {
+	return func(hash common.Hash) ([]byte, error) {
+		c := ipld.Keccak256ToCid(codec, hash.Bytes())
+		data, err := s.source.Get(tx, t, c, shared.MultihashKeyFromCID(c))
+		if err == shared.ErrBlockNotFound {
+			return nil, nil
+		}
+		if err == shared.ErrBlockCorrupt {
+			v.fail("%s trie node %s does not match its cid", t.String(), c.String())
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+}
+
+// indexedBlock is the cid and key of an indexed IPLD
+type indexedBlock struct {
+	CID   string `db:"cid"`
+	MhKey string `db:"mh_key"`
+}
+
+// fetchAll returns the data of the IPLDs the query selects for the header, in the order the query returns them
+// complete is false if one of them is missing or invalid, in which case the roots they hash into can't be recomputed
+func (s *Service) fetchAll(tx *sqlx.Tx, v *validation, t shared.DataType, pgStr string) (datas [][]byte, complete bool, err error) {
+	var blocks []indexedBlock
+	if err := tx.Select(&blocks, pgStr, v.header.ID); err != nil {
+		return nil, false, err
+	}
+	datas = make([][]byte, 0, len(blocks))
+	complete = true
+	for _, block := range blocks {
+		data, err := s.fetch(tx, v, t, block.CID, block.MhKey)
+		if err != nil {
+			return nil, false, err
+		}
+		if data == nil {
+			complete = false
+			continue
+		}
+		datas = append(datas, data)
+	}
+	return datas, complete, nil
+}
@@ -0,0 +1,163 @@
+// VulcanizeDB
+// Copyright © 2020 Vulcanize
+
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package validate_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc"
+	btcmocks "github.com/vulcanize/ipfs-blockchain-watcher/pkg/btc/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/eth/mocks"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/validate"
+)
+
+var segwitHash = btcmocks.MockSegwitBlock.BlockHash().String()
+
+var _ = Describe("Validate", func() {
+	var db *postgres.DB
+	AfterEach(func() {
+		eth.TearDownDB(db)
+		btc.TearDownDB(db)
+	})
+
+	validateBlocks := func(chain shared.ChainType, state bool) validate.Stats {
+		service, err := validate.NewService(&validate.Config{
+			Chain:    chain,
+			IPFSMode: shared.DirectPostgres,
+			State:    state,
+			DB:       db,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		stats, err := service.Validate()
+		Expect(err).ToNot(HaveOccurred())
+		return stats
+	}
+
+	Describe("Ethereum", func() {
+		var timesValidated int
+		BeforeEach(func() {
+			db = mocks.SetupIndexedDB()
+			err := db.Get(&timesValidated, `SELECT times_validated FROM eth.header_cids`)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		expectTimesValidated := func(expected int) {
+			var actual int
+			err := db.Get(&actual, `SELECT times_validated FROM eth.header_cids`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(actual).To(Equal(expected))
+		}
+
+		It("Increments times_validated of intact blocks", func() {
+			Expect(validateBlocks(shared.Ethereum, false)).To(Equal(validate.Stats{Validated: 1}))
+			expectTimesValidated(timesValidated + 1)
+			Expect(validateBlocks(shared.Ethereum, false)).To(Equal(validate.Stats{Validated: 1}))
+			expectTimesValidated(timesValidated + 2)
+		})
+
+		table.DescribeTable("Leaves invalid blocks as they are",
+			func(pgStr string, args ...interface{}) {
+				_, err := db.Exec(pgStr, args...)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(validateBlocks(shared.Ethereum, false)).To(Equal(validate.Stats{Invalid: 1}))
+				expectTimesValidated(timesValidated)
+			},
+			table.Entry("with corrupt transactions", `UPDATE public.blocks SET data = $1 WHERE key = $2`, []byte{1, 2, 3}, mocks.Trx1MhKey),
+			table.Entry("with missing transactions", `DELETE FROM public.blocks WHERE key = $1`, mocks.Trx1MhKey),
+			table.Entry("with receipts that do not derive the receipt root", `DELETE FROM eth.receipt_cids WHERE cid = $1`, mocks.Rct2CID.String()),
+			table.Entry("with indexed roots that do not match the header", `UPDATE eth.header_cids SET uncle_root = $1`, mocks.MockBlock.TxHash().String()),
+			table.Entry("with a corrupt header", `UPDATE public.blocks SET data = $1 WHERE key = $2`, []byte{1, 2, 3}, mocks.HeaderMhKey),
+			table.Entry("with a missing header", `DELETE FROM public.blocks WHERE key = $1`, mocks.HeaderMhKey),
+			table.Entry("with a parent hash the header does not carry", `UPDATE eth.header_cids SET parent_hash = $1`, mocks.MockBlock.TxHash().String()),
+		)
+
+		It("Reports missing state nodes without walking the trie to them", func() {
+			_, err := db.Exec(`DELETE FROM public.blocks WHERE key = $1`, mocks.State1MhKey)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(validateBlocks(shared.Ethereum, true)).To(Equal(validate.Stats{Invalid: 1}))
+			expectTimesValidated(timesValidated)
+		})
+
+		It("Optionally verifies that the state nodes hash into the state root", func() {
+			// the state root of the mock header is not the root of the mock state nodes
+			Expect(validateBlocks(shared.Ethereum, true)).To(Equal(validate.Stats{Invalid: 1}))
+			expectTimesValidated(timesValidated)
+		})
+	})
+
+	Describe("Bitcoin", func() {
+		BeforeEach(func() {
+			var err error
+			db, err = shared.SetupDB()
+			Expect(err).ToNot(HaveOccurred())
+			btcmocks.PublishAndIndex(db, btcmocks.MockConvertedPayload, btcmocks.MockSegwitConvertedPayload)
+		})
+
+		timesValidated := func() []int {
+			var actual []int
+			err := db.Select(&actual, `SELECT times_validated FROM btc.header_cids ORDER BY block_number`)
+			Expect(err).ToNot(HaveOccurred())
+			return actual
+		}
+
+		It("Increments times_validated of intact blocks, segwit blocks included", func() {
+			before := timesValidated()
+			Expect(validateBlocks(shared.Bitcoin, false)).To(Equal(validate.Stats{Validated: 2}))
+			Expect(timesValidated()).To(Equal([]int{before[0] + 1, before[1] + 1}))
+		})
+
+		It("Validates segwit transactions indexed in their witness-serialized form", func() {
+			_, err := db.Exec(`UPDATE btc.transaction_cids SET cid = witness_cid, mh_key = witness_mh_key
+					WHERE segwit AND header_id = (SELECT id FROM btc.header_cids WHERE block_hash = $1)`, segwitHash)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(validateBlocks(shared.Bitcoin, false)).To(Equal(validate.Stats{Validated: 2}))
+		})
+
+		It("Accepts orphaned parents of orphaned blocks", func() {
+			_, err := db.Exec(`UPDATE btc.header_cids SET orphaned = true`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(validateBlocks(shared.Bitcoin, false)).To(Equal(validate.Stats{Validated: 2}))
+		})
+
+		// the segwit block is built on the other one, which stays valid
+		table.DescribeTable("Leaves invalid blocks as they are",
+			func(pgStr string, args ...interface{}) {
+				_, err := db.Exec(pgStr, args...)
+				Expect(err).ToNot(HaveOccurred())
+				before := timesValidated()
+				Expect(validateBlocks(shared.Bitcoin, false)).To(Equal(validate.Stats{Validated: 1, Invalid: 1}))
+				Expect(timesValidated()).To(Equal([]int{before[0] + 1, before[1]}))
+			},
+			table.Entry("with corrupt transactions", `UPDATE public.blocks SET data = $1 WHERE key = (
+					SELECT mh_key FROM btc.transaction_cids WHERE index = 1
+					AND header_id = (SELECT id FROM btc.header_cids WHERE block_hash = $2))`, []byte{1, 2, 3}, segwitHash),
+			table.Entry("with transactions that do not derive the merkle root", `DELETE FROM btc.transaction_cids WHERE index = 1
+					AND header_id = (SELECT id FROM btc.header_cids WHERE block_hash = $1)`, segwitHash),
+			table.Entry("with a missing header", `DELETE FROM public.blocks WHERE key = (
+					SELECT mh_key FROM btc.header_cids WHERE block_hash = $1)`, segwitHash),
+			table.Entry("whose parent is not indexed", `UPDATE btc.header_cids SET parent_hash = $1 WHERE block_hash = $2`,
+				btcmocks.MockForkBlock.BlockHash().String(), segwitHash),
+			table.Entry("on the best chain with an orphaned parent", `UPDATE btc.header_cids SET orphaned = true WHERE block_hash = $1`,
+				btcmocks.MockBlock.BlockHash().String()),
+		)
+	})
+})
@@ -23,6 +23,7 @@ import (
 
 	"github.com/spf13/viper"
 
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/blockstore"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/config"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/node"
 	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/postgres"
@@ -41,6 +42,8 @@ const (
 	SUPERNODE_HTTP_PATH = "SUPERNODE_HTTP_PATH"
 	SUPERNODE_BACKFILL  = "SUPERNODE_BACKFILL"
 
+	SUPERNODE_GATEWAY_PATH = "SUPERNODE_GATEWAY_PATH"
+
 	SYNC_MAX_IDLE_CONNECTIONS = "SYNC_MAX_IDLE_CONNECTIONS"
 	SYNC_MAX_OPEN_CONNECTIONS = "SYNC_MAX_OPEN_CONNECTIONS"
 	SYNC_MAX_CONN_LIFETIME    = "SYNC_MAX_CONN_LIFETIME"
@@ -52,16 +55,19 @@ const (
 
 // Config struct
 type Config struct {
-	Chain    shared.ChainType
-	IPFSPath string
-	IPFSMode shared.IPFSMode
-	DBConfig config.Database
+	Chain       shared.ChainType
+	IPFSPath    string
+	IPFSMode    shared.IPFSMode
+	Blockstores *shared.Blockstores // The blockstores used in DirectPostgres mode
+	DBConfig    config.Database
 	// Server fields
 	Serve        bool
 	ServeDBConn  *postgres.DB
 	WSEndpoint   string
 	HTTPEndpoint string
 	IPCEndpoint  string
+	// Address of the IPLD gateway; the gateway is not served if it is empty
+	GatewayEndpoint string
 	// Sync params
 	Sync       bool
 	SyncDBConn *postgres.DB
@@ -88,6 +94,7 @@ func NewConfig() (*Config, error) {
 	viper.BindEnv("watcher.ipcPath", SUPERNODE_IPC_PATH)
 	viper.BindEnv("watcher.httpPath", SUPERNODE_HTTP_PATH)
 	viper.BindEnv("watcher.backFill", SUPERNODE_BACKFILL)
+	viper.BindEnv("watcher.gatewayPath", SUPERNODE_GATEWAY_PATH)
 
 	c.Historical = viper.GetBool("watcher.backFill")
 	chain := viper.GetString("watcher.chain")
@@ -100,12 +107,22 @@ func NewConfig() (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
-	if c.IPFSMode == shared.LocalInterface || c.IPFSMode == shared.RemoteClient {
+	if c.IPFSMode == shared.LocalInterface {
 		c.IPFSPath, err = shared.GetIPFSPath()
 		if err != nil {
 			return nil, err
 		}
 	}
+	// in remote mode the ipfs path is the address of the daemon's HTTP API
+	if c.IPFSMode == shared.RemoteClient {
+		c.IPFSPath = shared.GetIPFSAPI()
+	}
+	if c.IPFSMode == shared.DirectPostgres {
+		c.Blockstores, err = blockstore.NewBlockstores()
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	c.DBConfig.Init()
 
@@ -153,6 +170,7 @@ func NewConfig() (*Config, error) {
 			httpPath = "127.0.0.1:8081"
 		}
 		c.HTTPEndpoint = httpPath
+		c.GatewayEndpoint = viper.GetString("watcher.gatewayPath")
 		serveDBConn := overrideDBConnConfig(c.DBConfig, Serve)
 		serveDB := utils.LoadPostgres(serveDBConn, c.NodeInfo)
 		c.ServeDBConn = &serveDB
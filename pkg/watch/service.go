@@ -92,10 +92,17 @@ type Service struct {
 	chain shared.ChainType
 	// Path to ipfs data dir
 	ipfsPath string
+	// Blockstores the chain-specific api fetches IPLDs from
+	blockstores *shared.Blockstores
 	// Underlying db
 	db *postgres.DB
 	// wg for syncing serve processes
 	serveWg *sync.WaitGroup
+	// Chan used to forward live payloads to the chain-specific api
+	apiPayloadChan chan shared.ConvertedData
+	// The chain-specific api, this is only constructed once so that its subscriptions share the apiPayloadChan
+	chainAPI     *rpc.API
+	chainAPIOnce sync.Once
 }
 
 // NewWatcher creates a new Watcher using an underlying Service struct
@@ -108,11 +115,11 @@ func NewWatcher(settings *Config) (Watcher, error) {
 		if err != nil {
 			return nil, err
 		}
-		sn.Converter, err = builders.NewPayloadConverter(settings.Chain)
+		sn.Converter, err = builders.NewPayloadConverter(settings.Chain, settings.SyncDBConn)
 		if err != nil {
 			return nil, err
 		}
-		sn.Publisher, err = builders.NewIPLDPublisher(settings.Chain, settings.IPFSPath, settings.SyncDBConn, settings.IPFSMode)
+		sn.Publisher, err = builders.NewIPLDPublisher(settings.Chain, settings.IPFSPath, settings.SyncDBConn, settings.IPFSMode, settings.Blockstores)
 		if err != nil {
 			return nil, err
 		}
@@ -131,11 +138,12 @@ func NewWatcher(settings *Config) (Watcher, error) {
 		if err != nil {
 			return nil, err
 		}
-		sn.IPLDFetcher, err = builders.NewIPLDFetcher(settings.Chain, settings.IPFSPath, settings.ServeDBConn, settings.IPFSMode)
+		sn.IPLDFetcher, err = builders.NewIPLDFetcher(settings.Chain, settings.IPFSPath, settings.ServeDBConn, settings.IPFSMode, settings.Blockstores)
 		if err != nil {
 			return nil, err
 		}
 		sn.db = settings.ServeDBConn
+		sn.apiPayloadChan = make(chan shared.ConvertedData, PayloadChanBufferSize)
 	}
 	sn.QuitChan = make(chan bool)
 	sn.Subscriptions = make(map[common.Hash]map[rpc.ID]Subscription)
@@ -143,6 +151,7 @@ func NewWatcher(settings *Config) (Watcher, error) {
 	sn.WorkerPoolSize = settings.Workers
 	sn.NodeInfo = &settings.NodeInfo
 	sn.ipfsPath = settings.IPFSPath
+	sn.blockstores = settings.Blockstores
 	sn.chain = settings.Chain
 	return sn, nil
 }
@@ -181,12 +190,18 @@ func (sap *Service) APIs() []rpc.API {
 			Public:    true,
 		},
 	}
-	chainAPI, err := builders.NewPublicAPI(sap.chain, sap.db, sap.ipfsPath)
-	if err != nil {
-		log.Error(err)
+	sap.chainAPIOnce.Do(func() {
+		chainAPI, err := builders.NewPublicAPI(sap.chain, sap.db, sap.ipfsPath, sap.blockstores, sap.apiPayloadChan, sap.QuitChan)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		sap.chainAPI = &chainAPI
+	})
+	if sap.chainAPI == nil {
 		return apis
 	}
-	return append(apis, chainAPI)
+	return append(apis, *sap.chainAPI)
 }
 
 // Sync streams incoming raw chain data and converts it for further processing
@@ -291,6 +306,11 @@ func (sap *Service) Serve(wg *sync.WaitGroup, screenAndServePayload <-chan share
 // filterAndServe filters the payload according to each subscription type and sends to the subscriptions
 func (sap *Service) filterAndServe(payload shared.ConvertedData) {
 	log.Debugf("sending %s payload to subscriptions", sap.chain.String())
+	// Forward the payload to the chain-specific api subscriptions, if they are listening
+	select {
+	case sap.apiPayloadChan <- payload:
+	default:
+	}
 	sap.Lock()
 	sap.serveWg.Add(1)
 	defer sap.Unlock()
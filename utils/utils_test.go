@@ -20,6 +20,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/vulcanize/ipfs-blockchain-watcher/pkg/shared"
 	"github.com/vulcanize/ipfs-blockchain-watcher/utils"
 )
 
@@ -72,3 +73,19 @@ var _ = Describe("GetBlockHeightBins", func() {
 		Expect(err.Error()).To(ContainSubstring("batchsize needs to be greater than zero"))
 	})
 })
+
+var _ = Describe("MissingHeightsToGaps", func() {
+	It("groups consecutive heights into gaps", func() {
+		gaps := utils.MissingHeightsToGaps([]uint64{1, 2, 3, 5, 7, 8})
+		Expect(gaps).To(Equal([]shared.Gap{{Start: 1, Stop: 3}, {Start: 5, Stop: 5}, {Start: 7, Stop: 8}}))
+	})
+
+	It("returns a gap for a single height", func() {
+		gaps := utils.MissingHeightsToGaps([]uint64{4})
+		Expect(gaps).To(Equal([]shared.Gap{{Start: 4, Stop: 4}}))
+	})
+
+	It("returns no gaps for no heights", func() {
+		Expect(utils.MissingHeightsToGaps(nil)).To(BeEmpty())
+	})
+})